@@ -0,0 +1,79 @@
+package aiclient
+
+import (
+	"sync"
+	"time"
+)
+
+// 熔断器状态
+const (
+    StateClosed   = "closed"
+    StateOpen     = "open"
+    StateHalfOpen = "half_open"
+)
+
+// breaker 连续失败计数熔断：连续 threshold 次失败后打开，cooldown 后半开放行一个探测请求，
+// 探测成功则关闭，失败则重新打开。threshold <= 0 表示不熔断。
+type breaker struct {
+    mu        sync.Mutex
+    threshold int
+    cooldown  time.Duration
+    now       func() time.Time
+
+    state    string
+    failures int
+    openedAt time.Time
+    probing  bool // 半开状态下已有探测请求在途
+}
+
+func newBreaker(threshold int, cooldown time.Duration) *breaker {
+    return &breaker{threshold: threshold, cooldown: cooldown, now: time.Now, state: StateClosed}
+}
+
+// allow 判断是否放行一次请求；放行后必须调用 done 报告结果。
+func (b *breaker) allow() bool {
+    if b.threshold <= 0 { return true }
+    b.mu.Lock()
+    defer b.mu.Unlock()
+    switch b.state {
+    case StateOpen:
+        if b.now().Sub(b.openedAt) < b.cooldown { return false }
+        b.state, b.probing = StateHalfOpen, true
+        return true
+    case StateHalfOpen:
+        if b.probing { return false }
+        b.probing = true
+        return true
+    }
+    return true
+}
+
+// done 报告一次放行请求的结果；ok=false 计入连续失败。
+func (b *breaker) done(ok bool) {
+    if b.threshold <= 0 { return }
+    b.mu.Lock()
+    defer b.mu.Unlock()
+    if ok {
+        b.state, b.failures, b.probing = StateClosed, 0, false
+        return
+    }
+    b.failures++
+    if b.state == StateHalfOpen || b.failures >= b.threshold {
+        b.state, b.openedAt, b.probing = StateOpen, b.now(), false
+    }
+}
+
+// release 放行的请求未产生可判定的结果（如调用方取消）：半开状态下归还探测名额，不影响计数。
+func (b *breaker) release() {
+    if b.threshold <= 0 { return }
+    b.mu.Lock()
+    defer b.mu.Unlock()
+    if b.state == StateHalfOpen { b.probing = false }
+}
+
+func (b *breaker) current() string {
+    b.mu.Lock()
+    defer b.mu.Unlock()
+    if b.state == StateOpen && b.now().Sub(b.openedAt) >= b.cooldown { return StateHalfOpen }
+    return b.state
+}
@@ -0,0 +1,202 @@
+// Package aiclient 调用 Python AI 服务（/ai/generate 题目生成、/ai/detect 代码检测）的 HTTP 客户端：
+// 单次尝试超时、对网络错误 / 5xx / 429 指数退避重试、连续失败熔断，并为每次尝试创建客户端 span、
+// 经 traceparent 头把链路传给 AI 服务。
+package aiclient
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+
+	"github.com/YangYuS8/codyssey/backend/internal/logging"
+	"github.com/YangYuS8/codyssey/backend/internal/metrics"
+	"github.com/YangYuS8/codyssey/backend/internal/tracing"
+)
+
+const (
+    EndpointGenerate = "/ai/generate"
+    EndpointDetect   = "/ai/detect"
+
+    maxResponseBytes = 1 << 20
+    maxBackoff       = 5 * time.Second
+    maxErrorBody     = 200 // 错误信息中保留的响应体长度
+)
+
+var (
+    // ErrUnavailable AI 服务不可用：网络错误、超时或 5xx / 429 在重试后仍失败，或熔断打开。
+    ErrUnavailable = errors.New("ai service unavailable")
+    // ErrCircuitOpen 熔断打开期间直接拒绝，不发出请求；同时满足 errors.Is(err, ErrUnavailable)。
+    ErrCircuitOpen = errors.New("ai service circuit open")
+    // ErrBadResponse AI 服务拒绝请求（4xx）或返回无法解析的响应，重试无意义。
+    ErrBadResponse = errors.New("ai service bad response")
+)
+
+// Options 客户端配置；零值字段取默认值（MaxRetries 为 0 表示不重试，BreakerThreshold 为 0 表示不熔断）。
+type Options struct {
+    BaseURL          string        // 如 http://py-backend:8000
+    Timeout          time.Duration // 单次尝试超时，默认 30s
+    MaxRetries       int           // 失败后的最多重试次数
+    RetryBackoff     time.Duration // 首次重试等待，之后逐次翻倍（带抖动，上限 5s），默认 200ms
+    BreakerThreshold int           // 连续失败多少次后熔断
+    BreakerCooldown  time.Duration // 熔断持续时长，之后放行一个探测请求，默认 30s
+    HTTPClient       *http.Client  // 默认 http.DefaultClient 的副本（超时由 Timeout 控制）
+}
+
+type Client struct {
+    base    string
+    opts    Options
+    http    *http.Client
+    breaker *breaker
+    sleep   func(ctx context.Context, d time.Duration) error
+}
+
+// GeneratedProblem /ai/generate 的结果；Model 为生成所用的模型名。
+type GeneratedProblem struct {
+    Title       string `json:"title"`
+    Description string `json:"description"`
+    Model       string `json:"model"`
+}
+
+// Detection /ai/detect 的结果；Score 在 [0,1]，越高越可能为 AI 生成。
+type Detection struct {
+    Suspicious bool    `json:"suspicious"`
+    Score      float64 `json:"score"`
+    Model      string  `json:"model"`
+}
+
+func New(o Options) (*Client, error) {
+    base := strings.TrimRight(strings.TrimSpace(o.BaseURL), "/")
+    if !strings.HasPrefix(base, "http://") && !strings.HasPrefix(base, "https://") {
+        return nil, fmt.Errorf("aiclient: base url must start with http:// or https://, got %q", o.BaseURL)
+    }
+    if o.Timeout <= 0 { o.Timeout = 30 * time.Second }
+    if o.MaxRetries < 0 { o.MaxRetries = 0 }
+    if o.RetryBackoff <= 0 { o.RetryBackoff = 200 * time.Millisecond }
+    if o.BreakerCooldown <= 0 { o.BreakerCooldown = 30 * time.Second }
+    hc := o.HTTPClient
+    if hc == nil { hc = &http.Client{} }
+    return &Client{base: base, opts: o, http: hc, breaker: newBreaker(o.BreakerThreshold, o.BreakerCooldown), sleep: sleepCtx}, nil
+}
+
+// Generate 按提示词生成一道题目。
+func (c *Client) Generate(ctx context.Context, prompt string) (_ GeneratedProblem, err error) {
+    ctx, end := tracing.Start(ctx, "AIClient.Generate", attribute.Int("ai.prompt.length", len(prompt)))
+    defer end(&err)
+    var out GeneratedProblem
+    if err := c.call(ctx, EndpointGenerate, map[string]string{"prompt": prompt}, &out); err != nil { return GeneratedProblem{}, err }
+    return out, nil
+}
+
+// Detect 检测代码是否疑似 AI 生成。
+func (c *Client) Detect(ctx context.Context, code string) (_ Detection, err error) {
+    ctx, end := tracing.Start(ctx, "AIClient.Detect", attribute.Int("ai.code.length", len(code)))
+    defer end(&err)
+    var out Detection
+    if err := c.call(ctx, EndpointDetect, map[string]string{"code": code}, &out); err != nil { return Detection{}, err }
+    return out, nil
+}
+
+// BreakerState 熔断器当前状态（closed / open / half_open），供健康检查与排错。
+func (c *Client) BreakerState() string { return c.breaker.current() }
+
+// call 发送请求并按需重试；调用方取消时直接返回 ctx.Err()，不计入熔断。
+func (c *Client) call(ctx context.Context, endpoint string, in, out any) error {
+    body, err := json.Marshal(in)
+    if err != nil { return err }
+    for attempt := 0; ; attempt++ {
+        if !c.breaker.allow() {
+            metrics.ObserveAIRequest(endpoint, "circuit_open", 0)
+            return fmt.Errorf("%w: %w", ErrUnavailable, ErrCircuitOpen)
+        }
+        start := time.Now()
+        transient, err := c.attempt(ctx, endpoint, body, attempt, out)
+        if err != nil && ctx.Err() != nil {
+            c.breaker.release()
+            return ctx.Err()
+        }
+        c.breaker.done(!transient)
+        switch {
+        case err == nil:
+            metrics.ObserveAIRequest(endpoint, "ok", time.Since(start))
+            return nil
+        case !transient:
+            metrics.ObserveAIRequest(endpoint, "bad_response", time.Since(start))
+            return fmt.Errorf("%w: %s: %v", ErrBadResponse, endpoint, err)
+        }
+        metrics.ObserveAIRequest(endpoint, "error", time.Since(start))
+        if attempt >= c.opts.MaxRetries { return fmt.Errorf("%w: %s: %v", ErrUnavailable, endpoint, err) }
+        wait := c.backoff(attempt)
+        logging.FromContext(ctx).Warn("ai request failed, retrying", zap.String("endpoint", endpoint), zap.Int("attempt", attempt+1), zap.Duration("backoff", wait), zap.Error(err))
+        if err := c.sleep(ctx, wait); err != nil { return err }
+    }
+}
+
+// attempt 单次请求；transient 表示失败可重试且计入熔断（网络错误、超时、5xx、429）。
+func (c *Client) attempt(ctx context.Context, endpoint string, body []byte, attempt int, out any) (transient bool, err error) {
+    ctx, cancel := context.WithTimeout(ctx, c.opts.Timeout)
+    defer cancel()
+    url := c.base + endpoint
+    ctx, span := tracing.Tracer().Start(ctx, "POST "+endpoint, trace.WithSpanKind(trace.SpanKindClient), trace.WithAttributes(
+        attribute.String("http.request.method", http.MethodPost),
+        attribute.String("url.full", url),
+        attribute.Int("http.request.resend_count", attempt),
+    ))
+    defer func() {
+        if err != nil {
+            span.RecordError(err)
+            span.SetStatus(codes.Error, err.Error())
+        }
+        span.End()
+    }()
+    req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+    if err != nil { return false, err }
+    req.Header.Set("Content-Type", "application/json")
+    req.Header.Set("Accept", "application/json")
+    otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(req.Header))
+    resp, err := c.http.Do(req)
+    if err != nil { return true, err }
+    defer resp.Body.Close()
+    span.SetAttributes(attribute.Int("http.response.status_code", resp.StatusCode))
+    data, err := io.ReadAll(io.LimitReader(resp.Body, maxResponseBytes+1))
+    if err != nil { return true, err }
+    if resp.StatusCode < 200 || resp.StatusCode > 299 {
+        msg := strings.TrimSpace(string(data))
+        if len(msg) > maxErrorBody { msg = msg[:maxErrorBody] }
+        return resp.StatusCode >= 500 || resp.StatusCode == http.StatusTooManyRequests, fmt.Errorf("status %d: %s", resp.StatusCode, msg)
+    }
+    if len(data) > maxResponseBytes { return false, fmt.Errorf("response exceeds %d bytes", maxResponseBytes) }
+    if err := json.Unmarshal(data, out); err != nil { return false, fmt.Errorf("decode response: %w", err) }
+    return false, nil
+}
+
+// backoff 第 attempt 次失败后的等待：RetryBackoff * 2^attempt，取 [d/2, d) 的随机值避免多实例同时重试。
+func (c *Client) backoff(attempt int) time.Duration {
+    d := c.opts.RetryBackoff << attempt
+    if d <= 0 || d > maxBackoff { d = maxBackoff }
+    return d/2 + rand.N(d/2+1)
+}
+
+func sleepCtx(ctx context.Context, d time.Duration) error {
+    t := time.NewTimer(d)
+    defer t.Stop()
+    select {
+    case <-ctx.Done():
+        return ctx.Err()
+    case <-t.C:
+        return nil
+    }
+}
@@ -0,0 +1,184 @@
+package aiclient
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// fakeAI 模拟 Python AI 服务：fail 次请求返回 failStatus（0 表示挂起直到超时），之后正常响应。
+type fakeAI struct {
+    calls      atomic.Int32
+    fail       int32
+    failStatus int
+    headers    atomic.Value // 最近一次请求头
+}
+
+func (f *fakeAI) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+    n := f.calls.Add(1)
+    f.headers.Store(r.Header.Clone())
+    var in map[string]string
+    _ = json.NewDecoder(r.Body).Decode(&in) // 读完请求体后服务端才能感知客户端断开
+    if n <= f.fail {
+        if f.failStatus == 0 { <-r.Context().Done(); return }
+        http.Error(w, `{"detail":"boom"}`, f.failStatus)
+        return
+    }
+    w.Header().Set("Content-Type", "application/json")
+    switch r.URL.Path {
+    case EndpointGenerate:
+        _ = json.NewEncoder(w).Encode(map[string]any{"problem_id": "stub-1", "title": "Generated: " + in["prompt"], "description": "desc", "model": "stub-model"})
+    case EndpointDetect:
+        _ = json.NewEncoder(w).Encode(map[string]any{"suspicious": len(in["code"]) > 10, "score": 0.75})
+    default:
+        http.NotFound(w, r)
+    }
+}
+
+func newTestClient(t *testing.T, f *fakeAI, o Options) *Client {
+    t.Helper()
+    srv := httptest.NewServer(f)
+    t.Cleanup(srv.Close)
+    o.BaseURL = srv.URL + "/"
+    c, err := New(o)
+    require.NoError(t, err)
+    c.sleep = func(ctx context.Context, d time.Duration) error { return ctx.Err() }
+    return c
+}
+
+func TestClient_GenerateAndDetect(t *testing.T) {
+    rec := tracetest.NewSpanRecorder()
+    tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(rec))
+    prevTP, prevProp := otel.GetTracerProvider(), otel.GetTextMapPropagator()
+    otel.SetTracerProvider(tp)
+    otel.SetTextMapPropagator(propagation.TraceContext{})
+    t.Cleanup(func() { otel.SetTracerProvider(prevTP); otel.SetTextMapPropagator(prevProp) })
+
+    f := &fakeAI{}
+    c := newTestClient(t, f, Options{})
+    g, err := c.Generate(context.Background(), "two sum")
+    require.NoError(t, err)
+    require.Equal(t, GeneratedProblem{Title: "Generated: two sum", Description: "desc", Model: "stub-model"}, g)
+    d, err := c.Detect(context.Background(), "print('hello world')")
+    require.NoError(t, err)
+    require.Equal(t, Detection{Suspicious: true, Score: 0.75}, d)
+
+    // 每次尝试一个客户端 span，父 span 为 AIClient.*，traceparent 传给 AI 服务
+    spans := rec.Ended()
+    require.Len(t, spans, 4)
+    require.Equal(t, "POST /ai/generate", spans[0].Name())
+    require.Equal(t, trace.SpanKindClient, spans[0].SpanKind())
+    require.Equal(t, "AIClient.Generate", spans[1].Name())
+    require.Equal(t, spans[1].SpanContext().SpanID(), spans[0].Parent().SpanID())
+    h := f.headers.Load().(http.Header)
+    require.Contains(t, h.Get("traceparent"), spans[2].SpanContext().TraceID().String())
+
+    _, err = New(Options{BaseURL: "py-backend:8000"})
+    require.Error(t, err)
+}
+
+func TestClient_RetriesTransientFailures(t *testing.T) {
+    f := &fakeAI{fail: 2, failStatus: http.StatusServiceUnavailable}
+    c := newTestClient(t, f, Options{MaxRetries: 2})
+    _, err := c.Generate(context.Background(), "p")
+    require.NoError(t, err)
+    require.EqualValues(t, 3, f.calls.Load())
+
+    // 重试耗尽返回 ErrUnavailable
+    f = &fakeAI{fail: 5, failStatus: http.StatusBadGateway}
+    c = newTestClient(t, f, Options{MaxRetries: 1})
+    _, err = c.Generate(context.Background(), "p")
+    require.ErrorIs(t, err, ErrUnavailable)
+    require.EqualValues(t, 2, f.calls.Load())
+
+    // 4xx 不重试
+    f = &fakeAI{fail: 5, failStatus: http.StatusUnprocessableEntity}
+    c = newTestClient(t, f, Options{MaxRetries: 3})
+    _, err = c.Generate(context.Background(), "p")
+    require.ErrorIs(t, err, ErrBadResponse)
+    require.Contains(t, err.Error(), "status 422")
+    require.EqualValues(t, 1, f.calls.Load())
+}
+
+func TestClient_TimeoutPerAttempt(t *testing.T) {
+    f := &fakeAI{fail: 1} // 第一次挂起
+    c := newTestClient(t, f, Options{Timeout: 50 * time.Millisecond, MaxRetries: 1})
+    start := time.Now()
+    g, err := c.Generate(context.Background(), "slow")
+    require.NoError(t, err)
+    require.Equal(t, "Generated: slow", g.Title)
+    require.Less(t, time.Since(start), 2*time.Second)
+
+    // 调用方取消不重试、不计入熔断
+    f = &fakeAI{fail: 10}
+    c = newTestClient(t, f, Options{Timeout: time.Minute, MaxRetries: 3, BreakerThreshold: 1})
+    ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+    defer cancel()
+    _, err = c.Detect(ctx, "x")
+    require.True(t, errors.Is(err, context.DeadlineExceeded), err)
+    require.EqualValues(t, 1, f.calls.Load())
+    require.Equal(t, StateClosed, c.BreakerState())
+}
+
+func TestClient_CircuitBreaker(t *testing.T) {
+    f := &fakeAI{fail: 3, failStatus: http.StatusInternalServerError}
+    c := newTestClient(t, f, Options{BreakerThreshold: 3, BreakerCooldown: time.Minute})
+    now := time.Now()
+    c.breaker.now = func() time.Time { return now }
+    for i := 0; i < 3; i++ {
+        _, err := c.Generate(context.Background(), "p")
+        require.ErrorIs(t, err, ErrUnavailable)
+    }
+    require.Equal(t, StateOpen, c.BreakerState())
+
+    // 打开期间不发出请求
+    _, err := c.Generate(context.Background(), "p")
+    require.ErrorIs(t, err, ErrCircuitOpen)
+    require.ErrorIs(t, err, ErrUnavailable)
+    require.EqualValues(t, 3, f.calls.Load())
+
+    // 冷却后半开，探测成功即关闭
+    now = now.Add(time.Minute)
+    require.Equal(t, StateHalfOpen, c.BreakerState())
+    _, err = c.Generate(context.Background(), "p")
+    require.NoError(t, err)
+    require.Equal(t, StateClosed, c.BreakerState())
+    require.EqualValues(t, 4, f.calls.Load())
+}
+
+func TestBreaker_HalfOpenFailureReopens(t *testing.T) {
+    b := newBreaker(2, time.Second)
+    now := time.Now()
+    b.now = func() time.Time { return now }
+    require.True(t, b.allow()); b.done(false)
+    require.True(t, b.allow()); b.done(true) // 成功清零连续失败
+    require.True(t, b.allow()); b.done(false)
+    require.True(t, b.allow()); b.done(false)
+    require.False(t, b.allow())
+
+    now = now.Add(time.Second)
+    require.True(t, b.allow())
+    require.False(t, b.allow()) // 半开只放行一个探测
+    b.done(false)
+    require.Equal(t, StateOpen, b.current())
+    require.False(t, b.allow())
+
+    now = now.Add(time.Second)
+    require.True(t, b.allow())
+    b.release() // 探测被取消，名额归还
+    require.True(t, b.allow())
+    b.done(true)
+    require.Equal(t, StateClosed, b.current())
+}
@@ -0,0 +1,203 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base32"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/YangYuS8/codyssey/backend/internal/domain"
+	"github.com/YangYuS8/codyssey/backend/internal/repository"
+	"github.com/google/uuid"
+)
+
+var (
+    ErrAPITokenNotFound     = errors.New("api token not found")
+    ErrAPITokenInvalid      = errors.New("invalid, expired or revoked api token")
+    ErrAPITokenScope        = errors.New("api token permissions must be a non-empty subset of caller permissions")
+    ErrAPITokenForbidden    = errors.New("not allowed to manage this api token")
+    ErrAPITokenExpiry       = errors.New("api token expiry out of range")
+    // ErrAPITokenUserScope service 令牌不代表具体用户，不能持有以调用者为所有者的权限。
+    ErrAPITokenUserScope    = fmt.Errorf("%w: user-owned permissions are not allowed for service tokens", ErrAPITokenScope)
+)
+
+// serviceUserPrefix service 令牌身份的 UserID 前缀，不是合法的用户 ID。
+const serviceUserPrefix = "service:"
+
+// userOwnedPermissions 以调用者身份创建归属数据的权限（如提交记录的 user_id），service 令牌不可持有。
+var userOwnedPermissions = map[Permission]struct{}{PermSubmissionCreate: {}}
+
+// IsService 是否为 service 令牌身份（不代表具体用户，不能创建归属于用户的数据）。
+func (i *Identity) IsService() bool { return i != nil && i.TokenID != "" && strings.HasPrefix(i.UserID, serviceUserPrefix) }
+
+const (
+    apiTokenPrefix      = "cdy_"
+    DefaultAPITokenTTL  = 90 * 24 * time.Hour
+    MaxAPITokenTTL      = 366 * 24 * time.Hour
+    apiTokenTouchPeriod = time.Minute // last_used_at 写入节流
+)
+
+var apiTokenEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// APITokenService 签发、校验与吊销 API 令牌。
+type APITokenService struct {
+    repo  repository.APITokenRepository
+    users repository.UserRepository
+    now   func() time.Time
+}
+
+func NewAPITokenService(repo repository.APITokenRepository, users repository.UserRepository) *APITokenService {
+    return &APITokenService{repo: repo, users: users, now: time.Now}
+}
+
+// IssueAPITokenInput 签发参数；TTL 为 0 使用默认 90 天。
+type IssueAPITokenInput struct {
+    Name        string
+    Kind        string
+    Permissions []Permission
+    TTL         time.Duration
+}
+
+// IssuedAPIToken 签发结果，Token 为明文（仅此一次返回）。
+type IssuedAPIToken struct {
+    domain.APIToken
+    Token string `json:"token"`
+}
+
+func hashAPIToken(raw string) string {
+    sum := sha256.Sum256([]byte(raw))
+    return hex.EncodeToString(sum[:])
+}
+
+// Issue 由 caller 签发令牌：作用域不得超出 caller 当前权限；service 令牌需 api_token.manage；
+// 通过 API Token 认证的请求不能再签发令牌（防止用短期泄露换取长期凭据）。
+func (s *APITokenService) Issue(ctx context.Context, caller *Identity, in IssueAPITokenInput) (IssuedAPIToken, error) {
+    if caller == nil || caller.TokenID != "" { return IssuedAPIToken{}, ErrAPITokenForbidden }
+    name := strings.TrimSpace(in.Name)
+    if name == "" || len(name) > 100 { return IssuedAPIToken{}, errors.New("name required (max 100 chars)") }
+    kind := in.Kind
+    if kind == "" { kind = domain.APITokenKindPersonal }
+    switch kind {
+    case domain.APITokenKindPersonal:
+        if !caller.Has(PermAPITokenCreate) { return IssuedAPIToken{}, ErrAPITokenForbidden }
+    case domain.APITokenKindService:
+        if !caller.Has(PermAPITokenManage) { return IssuedAPIToken{}, ErrAPITokenForbidden }
+    default:
+        return IssuedAPIToken{}, errors.New("kind must be personal or service")
+    }
+    perms, err := normalizeScope(caller, in.Permissions)
+    if err != nil { return IssuedAPIToken{}, err }
+    if kind == domain.APITokenKindService {
+        for _, p := range perms {
+            if _, ok := userOwnedPermissions[Permission(p)]; ok { return IssuedAPIToken{}, ErrAPITokenUserScope }
+        }
+    }
+    ttl := in.TTL
+    if ttl == 0 { ttl = DefaultAPITokenTTL }
+    if ttl < 0 || ttl > MaxAPITokenTTL { return IssuedAPIToken{}, ErrAPITokenExpiry }
+
+    buf := make([]byte, 32)
+    if _, err := rand.Read(buf); err != nil { return IssuedAPIToken{}, err }
+    raw := apiTokenPrefix + strings.ToLower(apiTokenEncoding.EncodeToString(buf))
+    now := s.now().UTC()
+    t := domain.APIToken{
+        ID: uuid.New().String(), UserID: caller.UserID, Name: name, Kind: kind,
+        Prefix: raw[:len(apiTokenPrefix)+6], Hash: hashAPIToken(raw), Permissions: perms,
+        ExpiresAt: now.Add(ttl), CreatedAt: now,
+    }
+    if err := s.repo.Create(ctx, t); err != nil { return IssuedAPIToken{}, err }
+    return IssuedAPIToken{APIToken: t, Token: raw}, nil
+}
+
+// normalizeScope 去重并校验：必须是已定义权限，且 caller 自身持有。
+func normalizeScope(caller *Identity, in []Permission) ([]string, error) {
+    known := make(map[Permission]struct{}, len(AllPermissions))
+    for _, p := range AllPermissions { known[p] = struct{}{} }
+    seen := map[Permission]struct{}{}
+    out := make([]string, 0, len(in))
+    for _, p := range in {
+        p = Permission(strings.TrimSpace(string(p)))
+        if _, dup := seen[p]; dup { continue }
+        if _, ok := known[p]; !ok || !caller.Has(p) { return nil, ErrAPITokenScope }
+        seen[p] = struct{}{}
+        out = append(out, string(p))
+    }
+    if len(out) == 0 { return nil, ErrAPITokenScope }
+    return out, nil
+}
+
+// Resolve 校验明文令牌并构造身份：
+// personal 令牌的有效权限 = 令牌作用域 ∩ 所有者当前角色权限（降级后令牌随之收缩）；
+// service 令牌使用作用域（去掉 userOwnedPermissions，兼容此前签发的令牌）。身份不携带角色，避免基于角色的放行绕过作用域。
+func (s *APITokenService) Resolve(ctx context.Context, raw string) (*Identity, error) {
+    raw = strings.TrimSpace(raw)
+    if !strings.HasPrefix(raw, apiTokenPrefix) { return nil, ErrAPITokenInvalid }
+    t, err := s.repo.GetByHash(ctx, hashAPIToken(raw))
+    if err != nil {
+        if errors.Is(err, repository.ErrAPITokenNotFound) { return nil, ErrAPITokenInvalid }
+        return nil, err
+    }
+    now := s.now().UTC()
+    if t.RevokedAt != nil || !now.Before(t.ExpiresAt) { return nil, ErrAPITokenInvalid }
+
+    id := &Identity{UserID: serviceUserPrefix + t.ID, Roles: []string{}, Permissions: map[Permission]struct{}{}, TokenID: t.ID}
+    var allowed map[Permission]struct{}
+    if t.Kind == domain.APITokenKindPersonal {
+        owner, err := s.users.GetByID(ctx, t.UserID)
+        if err != nil {
+            if errors.Is(err, repository.ErrUserNotFound) { return nil, ErrAPITokenInvalid }
+            return nil, err
+        }
+        id.UserID = owner.ID
+        ownerID := &Identity{Roles: owner.Roles}
+        mergeRolePermissions(ownerID)
+        allowed = ownerID.Permissions
+    }
+    for _, p := range t.Permissions {
+        if allowed != nil {
+            if _, ok := allowed[Permission(p)]; !ok { continue }
+        } else if _, owned := userOwnedPermissions[Permission(p)]; owned {
+            continue
+        }
+        id.Permissions[Permission(p)] = struct{}{}
+    }
+    if t.LastUsedAt == nil || now.Sub(*t.LastUsedAt) >= apiTokenTouchPeriod {
+        _ = s.repo.TouchLastUsed(ctx, t.ID, now) // 仅用于展示，失败不影响认证
+    }
+    return id, nil
+}
+
+// List 列出 caller 自己的令牌；all=true 且具备 api_token.manage 时列出全部。
+func (s *APITokenService) List(ctx context.Context, caller *Identity, all bool) ([]domain.APIToken, error) {
+    if caller == nil || caller.TokenID != "" { return nil, ErrAPITokenForbidden }
+    owner := caller.UserID
+    if all {
+        if !caller.Has(PermAPITokenManage) { return nil, ErrAPITokenForbidden }
+        owner = ""
+    }
+    list, err := s.repo.ListByUser(ctx, owner)
+    if err != nil { return nil, err }
+    if list == nil { list = []domain.APIToken{} }
+    return list, nil
+}
+
+// Revoke 所有者或 api_token.manage 可吊销；已吊销视为不存在。
+func (s *APITokenService) Revoke(ctx context.Context, caller *Identity, id string) error {
+    if caller == nil || caller.TokenID != "" { return ErrAPITokenForbidden }
+    t, err := s.repo.GetByID(ctx, id)
+    if err != nil {
+        if errors.Is(err, repository.ErrAPITokenNotFound) { return ErrAPITokenNotFound }
+        return err
+    }
+    if t.UserID != caller.UserID && !caller.Has(PermAPITokenManage) { return ErrAPITokenNotFound }
+    if err := s.repo.Revoke(ctx, id, s.now().UTC()); err != nil {
+        if errors.Is(err, repository.ErrAPITokenNotFound) { return ErrAPITokenNotFound }
+        return err
+    }
+    return nil
+}
@@ -0,0 +1,107 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/YangYuS8/codyssey/backend/internal/domain"
+	"github.com/YangYuS8/codyssey/backend/internal/repository"
+)
+
+func newTokenFixture(t *testing.T) (*APITokenService, *repository.MemoryUserRepository, *Identity) {
+    t.Helper()
+    users := repository.NewMemoryUserRepository()
+    _ = users.Create(context.Background(), domain.User{ID: "t1", Username: "teacher1", Roles: []string{RoleTeacher}})
+    svc := NewAPITokenService(repository.NewMemoryAPITokenRepository(), users)
+    caller := &Identity{UserID: "t1", Roles: []string{RoleTeacher}}
+    mergeRolePermissions(caller)
+    return svc, users, caller
+}
+
+func tokenRouter(svc *APITokenService) *gin.Engine {
+    gin.SetMode(gin.TestMode)
+    r := gin.New()
+    r.Use(StrictJWTAuth("test-secret", svc))
+    r.GET("/list", Require(PermSubmissionList), protectedHandler())
+    r.POST("/enqueue", Require(PermJudgeRunEnqueue), protectedHandler())
+    return r
+}
+
+func callWithToken(r *gin.Engine, method, path, token string) int {
+    w := httptest.NewRecorder()
+    req, _ := http.NewRequest(method, path, nil)
+    req.Header.Set("Authorization", "Token "+token)
+    r.ServeHTTP(w, req)
+    return w.Code
+}
+
+func TestAPIToken_ScopedIdentity(t *testing.T) {
+    svc, _, caller := newTokenFixture(t)
+    ctx := context.Background()
+    out, err := svc.Issue(ctx, caller, IssueAPITokenInput{Name: "ci", Permissions: []Permission{PermSubmissionList}})
+    if err != nil { t.Fatalf("issue: %v", err) }
+    if out.Token == "" || out.Hash == out.Token || out.Kind != domain.APITokenKindPersonal { t.Fatalf("unexpected token %+v", out.APIToken) }
+
+    r := tokenRouter(svc)
+    if code := callWithToken(r, http.MethodGet, "/list", out.Token); code != 200 { t.Fatalf("granted permission expected 200 got %d", code) }
+    // 教师角色本身有 judge_run.enqueue，但令牌未授予
+    if code := callWithToken(r, http.MethodPost, "/enqueue", out.Token); code != 403 { t.Fatalf("ungranted permission expected 403 got %d", code) }
+    if code := callWithToken(r, http.MethodGet, "/list", out.Token+"x"); code != 401 { t.Fatalf("bad token expected 401 got %d", code) }
+
+    id, err := svc.Resolve(ctx, out.Token)
+    if err != nil || id.UserID != "t1" || id.TokenID != out.ID || len(id.Roles) != 0 { t.Fatalf("unexpected identity %+v %v", id, err) }
+    // 令牌认证的请求不能再签发 / 管理令牌
+    if _, err := svc.Issue(ctx, id, IssueAPITokenInput{Name: "x", Permissions: []Permission{PermSubmissionList}}); err != ErrAPITokenForbidden { t.Fatalf("expected forbidden got %v", err) }
+
+    if err := svc.Revoke(ctx, caller, out.ID); err != nil { t.Fatalf("revoke: %v", err) }
+    if code := callWithToken(r, http.MethodGet, "/list", out.Token); code != 401 { t.Fatalf("revoked token expected 401 got %d", code) }
+    if err := svc.Revoke(ctx, caller, out.ID); err != ErrAPITokenNotFound { t.Fatalf("double revoke expected not found got %v", err) }
+}
+
+func TestAPIToken_IssueRules(t *testing.T) {
+    svc, _, caller := newTokenFixture(t)
+    ctx := context.Background()
+    if _, err := svc.Issue(ctx, caller, IssueAPITokenInput{Name: "x", Permissions: []Permission{PermJudgeRunManage}}); err != ErrAPITokenScope { t.Fatalf("escalation expected scope error got %v", err) }
+    if _, err := svc.Issue(ctx, caller, IssueAPITokenInput{Name: "x", Permissions: []Permission{"bogus.perm"}}); err != ErrAPITokenScope { t.Fatalf("unknown perm expected scope error got %v", err) }
+    if _, err := svc.Issue(ctx, caller, IssueAPITokenInput{Name: "x"}); err != ErrAPITokenScope { t.Fatalf("empty scope expected scope error got %v", err) }
+    if _, err := svc.Issue(ctx, caller, IssueAPITokenInput{Name: "x", Kind: domain.APITokenKindService, Permissions: []Permission{PermSubmissionList}}); err != ErrAPITokenForbidden { t.Fatalf("teacher service token expected forbidden got %v", err) }
+    if _, err := svc.Issue(ctx, caller, IssueAPITokenInput{Name: "x", Permissions: []Permission{PermSubmissionList}, TTL: 2 * MaxAPITokenTTL}); err != ErrAPITokenExpiry { t.Fatalf("expected expiry error got %v", err) }
+
+    student := &Identity{UserID: "s1", Roles: []string{RoleStudent}}
+    mergeRolePermissions(student)
+    if _, err := svc.Issue(ctx, student, IssueAPITokenInput{Name: "x", Permissions: []Permission{PermSubmissionList}}); err != ErrAPITokenForbidden { t.Fatalf("student expected forbidden got %v", err) }
+}
+
+func TestAPIToken_ServiceTokenForJudgeWorker(t *testing.T) {
+    svc, _, _ := newTokenFixture(t)
+    ctx := context.Background()
+    admin := &Identity{UserID: "t1", Roles: []string{RoleSystemAdmin}}
+    mergeRolePermissions(admin)
+    out, err := svc.Issue(ctx, admin, IssueAPITokenInput{Name: "judge-worker-1", Kind: domain.APITokenKindService, Permissions: []Permission{PermJudgeRunManage}, TTL: time.Hour})
+    if err != nil { t.Fatalf("issue: %v", err) }
+    id, err := svc.Resolve(ctx, out.Token)
+    if err != nil || !id.Has(PermJudgeRunManage) || id.Has(PermUserDelete) || id.UserID != "service:"+out.ID || !id.IsService() { t.Fatalf("unexpected identity %+v %v", id, err) }
+    // 以调用者为所有者的权限不能签发给 service 令牌
+    if _, err := svc.Issue(ctx, admin, IssueAPITokenInput{Name: "bot", Kind: domain.APITokenKindService, Permissions: []Permission{PermSubmissionCreate}}); !errors.Is(err, ErrAPITokenScope) { t.Fatalf("service submission.create expected scope error got %v", err) }
+
+    // 过期
+    svc.now = func() time.Time { return time.Now().Add(2 * time.Hour) }
+    if _, err := svc.Resolve(ctx, out.Token); err != ErrAPITokenInvalid { t.Fatalf("expired token expected invalid got %v", err) }
+}
+
+func TestAPIToken_PersonalShrinksWithOwnerRoles(t *testing.T) {
+    svc, users, caller := newTokenFixture(t)
+    ctx := context.Background()
+    out, err := svc.Issue(ctx, caller, IssueAPITokenInput{Name: "ci", Permissions: []Permission{PermSubmissionList, PermJudgeRunEnqueue}})
+    if err != nil { t.Fatal(err) }
+    if err := users.UpdateRoles(ctx, "t1", []string{RoleStudent}); err != nil { t.Fatal(err) }
+    id, err := svc.Resolve(ctx, out.Token)
+    if err != nil { t.Fatal(err) }
+    if !id.Has(PermSubmissionList) || id.Has(PermJudgeRunEnqueue) { t.Fatalf("demoted owner token should lose enqueue, got %v", id.Permissions) }
+}
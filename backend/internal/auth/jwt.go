@@ -1,10 +1,13 @@
 package auth
 
 import (
+	"crypto/hmac"
+	"crypto/sha256"
 	"errors"
 	"time"
 
 	jwt "github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
 )
 
 var ErrInvalidToken = errors.New("invalid token")
@@ -18,11 +21,16 @@ type TokenPair struct {
 type JWTManager struct {
     accessTTL  time.Duration
     refreshTTL time.Duration
+    mfaTTL     time.Duration
     secret     []byte
+    mfaSecret  []byte
 }
 
 func NewJWTManager(secret string, accessTTL, refreshTTL time.Duration) *JWTManager {
-    return &JWTManager{secret: []byte(secret), accessTTL: accessTTL, refreshTTL: refreshTTL}
+    // MFA 待验证令牌使用派生密钥签名：即使被当作 Bearer 提交，也无法通过访问令牌校验
+    mac := hmac.New(sha256.New, []byte(secret))
+    mac.Write([]byte("codyssey/mfa-pending"))
+    return &JWTManager{secret: []byte(secret), mfaSecret: mac.Sum(nil), accessTTL: accessTTL, refreshTTL: refreshTTL, mfaTTL: 5 * time.Minute}
 }
 
 type AccessClaims struct {
@@ -36,6 +44,13 @@ type RefreshClaims struct {
     jwt.RegisteredClaims
 }
 
+// MFAPendingClaims 密码已通过、等待二次验证（或强制登记）的短期令牌；jti 用于标记已使用（只能完成一次登录）。
+type MFAPendingClaims struct {
+    UserID            string `json:"sub"`
+    EnrollmentPending bool   `json:"enroll,omitempty"`
+    jwt.RegisteredClaims
+}
+
 func (m *JWTManager) GenerateAccess(userID string, roles []string) (string, time.Time, error) {
     now := time.Now().UTC()
     exp := now.Add(m.accessTTL)
@@ -54,6 +69,22 @@ func (m *JWTManager) GenerateRefresh(userID string) (string, time.Time, error) {
     return s, exp, err
 }
 
+func (m *JWTManager) GenerateMFAPending(userID string, enrollment bool) (string, time.Time, error) {
+    now := time.Now().UTC()
+    exp := now.Add(m.mfaTTL)
+    claims := MFAPendingClaims{UserID: userID, EnrollmentPending: enrollment, RegisteredClaims: jwt.RegisteredClaims{ID: uuid.New().String(), Subject: userID, IssuedAt: jwt.NewNumericDate(now), ExpiresAt: jwt.NewNumericDate(exp)}}
+    token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+    s, err := token.SignedString(m.mfaSecret)
+    return s, exp, err
+}
+
+func (m *JWTManager) ParseMFAPending(tokenStr string) (*MFAPendingClaims, error) {
+    claims := &MFAPendingClaims{}
+    t, err := jwt.ParseWithClaims(tokenStr, claims, func(token *jwt.Token) (interface{}, error) { return m.mfaSecret, nil })
+    if err != nil || !t.Valid || claims.ID == "" || claims.ExpiresAt == nil { return nil, ErrInvalidToken }
+    return claims, nil
+}
+
 func (m *JWTManager) ParseAccess(tokenStr string) (*AccessClaims, error) {
     claims := &AccessClaims{}
     t, err := jwt.ParseWithClaims(tokenStr, claims, func(token *jwt.Token) (interface{}, error) { return m.secret, nil })
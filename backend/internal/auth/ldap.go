@@ -0,0 +1,149 @@
+package auth
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/YangYuS8/codyssey/backend/internal/domain"
+	"github.com/YangYuS8/codyssey/backend/internal/repository"
+	"github.com/go-ldap/ldap/v3"
+	"github.com/google/uuid"
+)
+
+// LDAPConfig LDAP 认证配置（由 server 从 config.LDAPConfig 映射而来）。
+type LDAPConfig struct {
+    URL          string            // ldap://host:389 或 ldaps://host:636
+    StartTLS     bool
+    BindDN       string            // 服务账号 DN；为空则匿名搜索
+    BindPassword string
+    BaseDN       string
+    UserFilter   string            // 含一个 %s 占位符，例如 (&(objectClass=inetOrgPerson)(uid=%s))
+    GroupAttr    string            // 用户条目上记录所属组的属性，默认 memberOf
+    GroupRoles   map[string]string // 组 DN -> 角色
+    DefaultRoles []string          // 未命中任何组映射时赋予的角色
+    Timeout      time.Duration
+}
+
+// LDAPConn 抽象一条 LDAP 连接；*ldap.Conn 直接满足，测试中可替换为进程内替身。
+type LDAPConn interface {
+    Bind(username, password string) error
+    Search(req *ldap.SearchRequest) (*ldap.SearchResult, error)
+    Close() error
+}
+
+// LDAPDialer 建立连接（每次认证一条短连接，避免绑定状态串用）。
+type LDAPDialer func(ctx context.Context) (LDAPConn, error)
+
+// DialLDAP 返回基于 go-ldap 的默认拨号器。
+func DialLDAP(cfg LDAPConfig) LDAPDialer {
+    return func(ctx context.Context) (LDAPConn, error) {
+        timeout := cfg.Timeout
+        if timeout <= 0 { timeout = 5 * time.Second }
+        conn, err := ldap.DialURL(cfg.URL, ldap.DialWithDialer(&net.Dialer{Timeout: timeout}))
+        if err != nil { return nil, err }
+        if cfg.StartTLS {
+            host := cfg.URL
+            if u, err := url.Parse(cfg.URL); err == nil { host = u.Hostname() }
+            if err := conn.StartTLS(&tls.Config{ServerName: host}); err != nil { conn.Close(); return nil, err }
+        }
+        conn.SetTimeout(timeout)
+        return conn, nil
+    }
+}
+
+// LDAPProvider 先用服务账号搜索用户 DN，再以用户 DN + 密码绑定校验；
+// 首次登录成功时按组映射角色自动开通本地用户（无本地密码）。
+type LDAPProvider struct {
+    cfg   LDAPConfig
+    dial  LDAPDialer
+    users repository.UserRepository
+}
+
+func NewLDAPProvider(cfg LDAPConfig, dial LDAPDialer, users repository.UserRepository) *LDAPProvider {
+    if cfg.UserFilter == "" { cfg.UserFilter = "(uid=%s)" }
+    if cfg.GroupAttr == "" { cfg.GroupAttr = "memberOf" }
+    if dial == nil { dial = DialLDAP(cfg) }
+    return &LDAPProvider{cfg: cfg, dial: dial, users: users}
+}
+
+func (p *LDAPProvider) Name() string { return "ldap" }
+
+func (p *LDAPProvider) Authenticate(ctx context.Context, username, password string) (domain.User, error) {
+    username = strings.TrimSpace(username)
+    // 空密码会被多数目录视为匿名绑定并"成功"，必须提前拒绝
+    if username == "" || password == "" { return domain.User{}, ErrInvalidLogin }
+    conn, err := p.dial(ctx)
+    if err != nil { return domain.User{}, err }
+    defer conn.Close()
+
+    if p.cfg.BindDN != "" {
+        if err := conn.Bind(p.cfg.BindDN, p.cfg.BindPassword); err != nil { return domain.User{}, fmt.Errorf("service bind: %w", err) }
+    }
+    req := ldap.NewSearchRequest(p.cfg.BaseDN, ldap.ScopeWholeSubtree, ldap.NeverDerefAliases, 2, 0, false,
+        fmt.Sprintf(p.cfg.UserFilter, ldap.EscapeFilter(username)), []string{p.cfg.GroupAttr}, nil)
+    res, err := conn.Search(req)
+    if err != nil {
+        if ldap.IsErrorWithCode(err, ldap.LDAPResultNoSuchObject) { return domain.User{}, ErrInvalidLogin }
+        return domain.User{}, err
+    }
+    // 0 条：用户不存在；多条：过滤器不唯一，拒绝以免绑定到错误条目
+    if len(res.Entries) != 1 { return domain.User{}, ErrInvalidLogin }
+    entry := res.Entries[0]
+    if err := conn.Bind(entry.DN, password); err != nil {
+        if ldap.IsErrorWithCode(err, ldap.LDAPResultInvalidCredentials) { return domain.User{}, ErrInvalidLogin }
+        return domain.User{}, err
+    }
+    return p.provision(ctx, username, p.mapRoles(entry.GetAttributeValues(p.cfg.GroupAttr)))
+}
+
+// mapRoles 组 DN 大小写不敏感匹配；未命中时回退到 DefaultRoles。
+func (p *LDAPProvider) mapRoles(groups []string) []string {
+    roles := make([]string, 0, len(groups))
+    seen := map[string]struct{}{}
+    for _, g := range groups {
+        for dn, role := range p.cfg.GroupRoles {
+            if !strings.EqualFold(strings.TrimSpace(dn), strings.TrimSpace(g)) { continue }
+            if _, ok := seen[role]; ok { continue }
+            seen[role] = struct{}{}
+            roles = append(roles, role)
+        }
+    }
+    if len(roles) == 0 { roles = append(roles, p.cfg.DefaultRoles...) }
+    return roles
+}
+
+// provision 已开通的目录用户直接使用（角色以本地为准），否则创建。
+// 同名本地密码账号不会被目录登录接管。
+func (p *LDAPProvider) provision(ctx context.Context, username string, roles []string) (domain.User, error) {
+    u, err := p.users.GetByUsername(ctx, username)
+    if err == nil {
+        if u.PasswordHash != "" { return domain.User{}, ErrInvalidLogin }
+        return u, nil
+    }
+    if !errors.Is(err, repository.ErrUserNotFound) { return domain.User{}, err }
+    u = domain.User{ID: uuid.New().String(), Username: username, Roles: roles, CreatedAt: time.Now().UTC()}
+    if err := p.users.Create(ctx, u); err != nil {
+        // 并发首登：另一请求已创建
+        if errors.Is(err, repository.ErrUserDuplicate) { return p.users.GetByUsername(ctx, username) }
+        return domain.User{}, err
+    }
+    return u, nil
+}
+
+// ParseGroupRoles 解析 "组DN:角色;组DN:角色" 形式的映射（DN 内含逗号与等号，故以最后一个冒号分隔）。
+func ParseGroupRoles(raw string) map[string]string {
+    out := map[string]string{}
+    for _, part := range strings.Split(raw, ";") {
+        part = strings.TrimSpace(part)
+        i := strings.LastIndex(part, ":")
+        if i <= 0 || i == len(part)-1 { continue }
+        out[strings.TrimSpace(part[:i])] = strings.TrimSpace(part[i+1:])
+    }
+    return out
+}
@@ -0,0 +1,162 @@
+package auth
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	ber "github.com/go-asn1-ber/asn1-ber"
+	"github.com/go-ldap/ldap/v3"
+	"golang.org/x/crypto/bcrypt"
+
+	"github.com/YangYuS8/codyssey/backend/internal/domain"
+	"github.com/YangYuS8/codyssey/backend/internal/repository"
+)
+
+// fakeDirectory 进程内 LDAP 替身：按 DN 保存密码与属性，支持 bind 与常见过滤器（&、|、!、=、=*）。
+type fakeDirectory struct {
+    entries map[string]fakeEntry
+    binds   []string
+}
+
+type fakeEntry struct {
+    password string
+    attrs    map[string][]string
+}
+
+type fakeConn struct { dir *fakeDirectory; closed bool }
+
+func (d *fakeDirectory) dialer() LDAPDialer {
+    return func(ctx context.Context) (LDAPConn, error) { return &fakeConn{dir: d}, nil }
+}
+
+func (c *fakeConn) Bind(dn, password string) error {
+    c.dir.binds = append(c.dir.binds, dn)
+    e, ok := c.dir.entries[dn]
+    if !ok || e.password != password || password == "" {
+        return ldap.NewError(ldap.LDAPResultInvalidCredentials, nil)
+    }
+    return nil
+}
+
+func (c *fakeConn) Search(req *ldap.SearchRequest) (*ldap.SearchResult, error) {
+    packet, err := ldap.CompileFilter(req.Filter)
+    if err != nil { return nil, err }
+    res := &ldap.SearchResult{}
+    for dn, e := range c.dir.entries {
+        if !strings.HasSuffix(strings.ToLower(dn), strings.ToLower(req.BaseDN)) { continue }
+        if !matchFilter(packet, e.attrs) { continue }
+        entry := &ldap.Entry{DN: dn}
+        for _, a := range req.Attributes { entry.Attributes = append(entry.Attributes, &ldap.EntryAttribute{Name: a, Values: e.attrs[a]}) }
+        res.Entries = append(res.Entries, entry)
+    }
+    return res, nil
+}
+
+func (c *fakeConn) Close() error { c.closed = true; return nil }
+
+func matchFilter(p *ber.Packet, attrs map[string][]string) bool {
+    switch p.Tag {
+    case ldap.FilterAnd:
+        for _, ch := range p.Children { if !matchFilter(ch, attrs) { return false } }
+        return true
+    case ldap.FilterOr:
+        for _, ch := range p.Children { if matchFilter(ch, attrs) { return true } }
+        return false
+    case ldap.FilterNot:
+        return !matchFilter(p.Children[0], attrs)
+    case ldap.FilterPresent:
+        return len(attrs[p.Data.String()]) > 0
+    case ldap.FilterEqualityMatch:
+        want := p.Children[1].Data.String()
+        for _, v := range attrs[p.Children[0].Data.String()] { if strings.EqualFold(v, want) { return true } }
+        return false
+    }
+    return false
+}
+
+func newFakeDirectory() *fakeDirectory {
+    return &fakeDirectory{entries: map[string]fakeEntry{
+        "cn=svc,dc=lab,dc=edu": {password: "svc-pass"},
+        "uid=alice,ou=people,dc=lab,dc=edu": {password: "alice-ldap", attrs: map[string][]string{
+            "objectClass": {"inetOrgPerson"}, "uid": {"alice"},
+            "memberOf": {"CN=Teachers,OU=Groups,DC=lab,DC=edu"},
+        }},
+        "uid=bob,ou=people,dc=lab,dc=edu": {password: "bob-ldap", attrs: map[string][]string{
+            "objectClass": {"inetOrgPerson"}, "uid": {"bob"},
+        }},
+    }}
+}
+
+func newTestLDAPProvider(dir *fakeDirectory, users repository.UserRepository) *LDAPProvider {
+    cfg := LDAPConfig{
+        BindDN: "cn=svc,dc=lab,dc=edu", BindPassword: "svc-pass",
+        BaseDN: "dc=lab,dc=edu", UserFilter: "(&(objectClass=inetOrgPerson)(uid=%s))",
+        GroupRoles:   ParseGroupRoles("cn=teachers,ou=groups,dc=lab,dc=edu:teacher"),
+        DefaultRoles: []string{RoleStudent},
+    }
+    return NewLDAPProvider(cfg, dir.dialer(), users)
+}
+
+func TestLDAPProvider_ProvisionsWithGroupRoles(t *testing.T) {
+    users := repository.NewMemoryUserRepository()
+    p := newTestLDAPProvider(newFakeDirectory(), users)
+    u, err := p.Authenticate(context.Background(), "alice", "alice-ldap")
+    if err != nil { t.Fatalf("authenticate: %v", err) }
+    if len(u.Roles) != 1 || u.Roles[0] != RoleTeacher { t.Fatalf("expected teacher role got %v", u.Roles) }
+    stored, err := users.GetByUsername(context.Background(), "alice")
+    if err != nil { t.Fatalf("expected provisioned user: %v", err) }
+    if stored.ID != u.ID || stored.PasswordHash != "" { t.Fatalf("unexpected stored user %+v", stored) }
+
+    // 二次登录复用已开通用户
+    again, err := p.Authenticate(context.Background(), "alice", "alice-ldap")
+    if err != nil || again.ID != u.ID { t.Fatalf("expected same user, got %v %v", again.ID, err) }
+}
+
+func TestLDAPProvider_DefaultRolesAndBadPassword(t *testing.T) {
+    users := repository.NewMemoryUserRepository()
+    p := newTestLDAPProvider(newFakeDirectory(), users)
+    u, err := p.Authenticate(context.Background(), "bob", "bob-ldap")
+    if err != nil { t.Fatalf("authenticate: %v", err) }
+    if len(u.Roles) != 1 || u.Roles[0] != RoleStudent { t.Fatalf("expected default student role got %v", u.Roles) }
+    if _, err := p.Authenticate(context.Background(), "bob", "wrong"); err != ErrInvalidLogin { t.Fatalf("expected ErrInvalidLogin got %v", err) }
+    if _, err := p.Authenticate(context.Background(), "bob", ""); err != ErrInvalidLogin { t.Fatalf("empty password must be rejected, got %v", err) }
+    if _, err := p.Authenticate(context.Background(), "nobody", "x"); err != ErrInvalidLogin { t.Fatalf("unknown user expected ErrInvalidLogin got %v", err) }
+}
+
+func TestLDAPProvider_FilterInjectionEscaped(t *testing.T) {
+    dir := newFakeDirectory()
+    p := newTestLDAPProvider(dir, repository.NewMemoryUserRepository())
+    // 未转义时 "*" 会匹配所有条目
+    if _, err := p.Authenticate(context.Background(), "*)(uid=*", "alice-ldap"); err != ErrInvalidLogin { t.Fatalf("expected ErrInvalidLogin got %v", err) }
+    for _, dn := range dir.binds {
+        if strings.HasPrefix(dn, "uid=") { t.Fatalf("user bind must not be attempted, got %s", dn) }
+    }
+}
+
+func TestLDAPProvider_DoesNotTakeOverLocalAccount(t *testing.T) {
+    users := repository.NewMemoryUserRepository()
+    hash, _ := bcrypt.GenerateFromPassword([]byte("local-pass"), bcrypt.MinCost)
+    _ = users.Create(context.Background(), domain.User{Username: "alice", Roles: []string{RoleStudent}, PasswordHash: string(hash)})
+    p := newTestLDAPProvider(newFakeDirectory(), users)
+    if _, err := p.Authenticate(context.Background(), "alice", "alice-ldap"); err != ErrInvalidLogin { t.Fatalf("expected ErrInvalidLogin got %v", err) }
+}
+
+func TestAuthService_ProviderChain(t *testing.T) {
+    users := repository.NewMemoryUserRepository()
+    jwtMgr := NewJWTManager("test-secret", time.Minute, time.Hour)
+    svc := NewAuthService(users, jwtMgr, NewLocalProvider(users), newTestLDAPProvider(newFakeDirectory(), users))
+    ctx := context.Background()
+
+    if _, err := svc.Register(ctx, "carol", "local-secret", []string{RoleStudent}); err != nil { t.Fatalf("register: %v", err) }
+    // 本地用户走 bcrypt
+    if _, tokens, err := svc.Authenticate(ctx, "carol", "local-secret"); err != nil || tokens.AccessToken == "" { t.Fatalf("local login failed: %v", err) }
+    // 目录用户回落到 LDAP
+    u, tokens, err := svc.Authenticate(ctx, "alice", "alice-ldap")
+    if err != nil || tokens.AccessToken == "" { t.Fatalf("ldap login failed: %v", err) }
+    claims, err := jwtMgr.ParseAccess(tokens.AccessToken)
+    if err != nil || claims.UserID != u.ID { t.Fatalf("unexpected claims %+v %v", claims, err) }
+    // 两者都不认识
+    if _, _, err := svc.Authenticate(ctx, "alice", "nope"); err != ErrInvalidLogin { t.Fatalf("expected ErrInvalidLogin got %v", err) }
+}
@@ -0,0 +1,224 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/YangYuS8/codyssey/backend/internal/domain"
+	"github.com/YangYuS8/codyssey/backend/internal/metrics"
+	"github.com/YangYuS8/codyssey/backend/internal/repository"
+)
+
+var (
+    ErrLoginBlocked     = errors.New("too many failed login attempts")
+    ErrLockoutNotFound  = errors.New("no lockout record")
+)
+
+const (
+    LockoutScopeUsername = "username"
+    LockoutScopeIP       = "ip"
+)
+
+// LockoutPolicy 登录失败限制策略。
+// 用户名维度：超过 FreeAttempts 次后每次失败都要等待指数增长的间隔，达到 UsernameMaxFailures 次临时锁定；
+// IP 维度：只做锁定不做延迟，阈值需足够高（机房/校园网常见多人共用出口 IP）。
+type LockoutPolicy struct {
+    UsernameMaxFailures int
+    IPMaxFailures       int
+    Window              time.Duration // 失败计数窗口，距上次失败超过该时长则重新计数
+    LockoutDuration     time.Duration
+    FreeAttempts        int
+    BaseDelay           time.Duration
+    MaxDelay            time.Duration
+}
+
+func DefaultLockoutPolicy() LockoutPolicy {
+    return LockoutPolicy{
+        UsernameMaxFailures: 5, IPMaxFailures: 50,
+        Window: 15 * time.Minute, LockoutDuration: 15 * time.Minute,
+        FreeAttempts: 2, BaseDelay: time.Second, MaxDelay: 30 * time.Second,
+    }
+}
+
+// LoginBlockedError 登录被拒绝（锁定或处于渐进延迟中），errors.Is(err, ErrLoginBlocked) 为真。
+type LoginBlockedError struct {
+    Scope      string
+    Locked     bool
+    RetryAfter time.Duration
+}
+
+func (e *LoginBlockedError) Error() string {
+    if e.Locked { return fmt.Sprintf("login locked by %s, retry after %s", e.Scope, e.RetryAfter.Round(time.Second)) }
+    return fmt.Sprintf("login throttled by %s, retry after %s", e.Scope, e.RetryAfter.Round(time.Second))
+}
+
+func (e *LoginBlockedError) Is(target error) bool { return target == ErrLoginBlocked }
+
+type loginGuard struct {
+    repo   repository.LoginAttemptRepository
+    policy LockoutPolicy
+    now    func() time.Time
+}
+
+// EnableLockout 为服务启用失败计数与锁定。
+func (s *AuthService) EnableLockout(repo repository.LoginAttemptRepository, policy LockoutPolicy) {
+    s.guard = &loginGuard{repo: repo, policy: policy, now: time.Now}
+}
+
+func (s *AuthService) LockoutEnabled() bool { return s.guard != nil }
+
+func usernameKey(username string) string { return "user:" + strings.ToLower(strings.TrimSpace(username)) }
+func ipKey(ip string) string             { return "ip:" + ip }
+
+func scopeOf(key string) string {
+    if strings.HasPrefix(key, "ip:") { return LockoutScopeIP }
+    return LockoutScopeUsername
+}
+
+func attemptKeys(username, clientIP string) []string {
+    keys := []string{usernameKey(username)}
+    if clientIP != "" { keys = append(keys, ipKey(clientIP)) }
+    return keys
+}
+
+// delay 第 failures 次失败后到下次允许尝试前需等待的时长。
+func (g *loginGuard) delay(failures int) time.Duration {
+    n := failures - g.policy.FreeAttempts
+    if n <= 0 || g.policy.BaseDelay <= 0 { return 0 }
+    d := g.policy.BaseDelay
+    for i := 1; i < n; i++ {
+        d *= 2
+        if g.policy.MaxDelay > 0 && d >= g.policy.MaxDelay { return g.policy.MaxDelay }
+    }
+    if g.policy.MaxDelay > 0 && d > g.policy.MaxDelay { d = g.policy.MaxDelay }
+    return d
+}
+
+func (g *loginGuard) maxFailures(scope string) int {
+    if scope == LockoutScopeIP { return g.policy.IPMaxFailures }
+    return g.policy.UsernameMaxFailures
+}
+
+// check 被锁定或仍在延迟期内时返回 *LoginBlockedError，否则返回各 key 窗口内已有的失败次数。
+func (g *loginGuard) check(ctx context.Context, keys []string) (map[string]int, error) {
+    now := g.now()
+    seen := map[string]int{}
+    for _, key := range keys {
+        a, err := g.repo.Get(ctx, key)
+        if err != nil {
+            if errors.Is(err, repository.ErrLoginAttemptNotFound) { continue }
+            return nil, err
+        }
+        scope := scopeOf(key)
+        if a.LockedUntil != nil && a.LockedUntil.After(now) {
+            metrics.IncLoginBlocked(scope, "locked")
+            return nil, &LoginBlockedError{Scope: scope, Locked: true, RetryAfter: a.LockedUntil.Sub(now)}
+        }
+        if now.Sub(a.LastFailedAt) > g.policy.Window { continue }
+        seen[key] = a.Failures
+        if scope != LockoutScopeUsername { continue }
+        if next := a.LastFailedAt.Add(g.delay(a.Failures)); next.After(now) {
+            metrics.IncLoginBlocked(scope, "throttled")
+            return nil, &LoginBlockedError{Scope: scope, RetryAfter: next.Sub(now)}
+        }
+    }
+    return seen, nil
+}
+
+// reserve 在校验凭据之前调用：先按 check 拒绝已锁定或延迟期内的尝试，再把本次尝试原子地计入失败次数。
+// 并发尝试各自拿到不同的计数，超过锁定阈值、或在 check 之后被其他尝试插队且已越过免延迟次数的直接拒绝，
+// 因此不会出现多个请求都通过检查、稍后才记录失败而绕过延迟与锁定。返回的预占记录交给 fail / succeed / release。
+func (g *loginGuard) reserve(ctx context.Context, keys []string) ([]domain.LoginAttempt, error) {
+    seen, err := g.check(ctx, keys)
+    if err != nil { return nil, err }
+    now := g.now()
+    var held []domain.LoginAttempt
+    for _, key := range keys {
+        a, err := g.repo.RecordFailure(ctx, key, now, now.Add(-g.policy.Window))
+        if err != nil { _ = g.release(ctx, held); return nil, err }
+        held = append(held, a)
+        scope := scopeOf(key)
+        if max := g.maxFailures(scope); max > 0 && a.Failures > max {
+            // 并发尝试已用尽本窗口的次数
+            if err := g.repo.Lock(ctx, key, now.Add(g.policy.LockoutDuration)); err != nil { _ = g.release(ctx, held); return nil, err }
+            metrics.IncLoginBlocked(scope, "locked")
+            _ = g.release(ctx, held)
+            return nil, &LoginBlockedError{Scope: scope, Locked: true, RetryAfter: g.policy.LockoutDuration}
+        }
+        if scope == LockoutScopeUsername && a.Failures != seen[key]+1 {
+            if d := g.delay(a.Failures - 1); d > 0 {
+                metrics.IncLoginBlocked(scope, "throttled")
+                _ = g.release(ctx, held)
+                return nil, &LoginBlockedError{Scope: scope, RetryAfter: d}
+            }
+        }
+    }
+    return held, nil
+}
+
+// fail 凭据确认错误：预占的计数即为本次失败，达到阈值时加锁。
+func (g *loginGuard) fail(ctx context.Context, held []domain.LoginAttempt) error {
+    now := g.now()
+    for _, a := range held {
+        scope := scopeOf(a.Key)
+        if max := g.maxFailures(scope); max > 0 && a.Failures >= max {
+            if err := g.repo.Lock(ctx, a.Key, now.Add(g.policy.LockoutDuration)); err != nil { return err }
+            metrics.IncLoginLockout(scope)
+        }
+    }
+    return nil
+}
+
+// release 撤回预占的计数（提供者故障、被拒绝的并发尝试等未能给出结论的情况）。
+func (g *loginGuard) release(ctx context.Context, held []domain.LoginAttempt) error {
+    for _, a := range held {
+        if err := g.repo.ReleaseFailure(ctx, a.Key); err != nil && !errors.Is(err, repository.ErrLoginAttemptNotFound) { return err }
+    }
+    return nil
+}
+
+// succeed 登录成功后清除用户名计数；IP 计数只撤回本次预占，避免攻击者用自有账号穿插登录来重置。
+func (g *loginGuard) succeed(ctx context.Context, held []domain.LoginAttempt) error {
+    for _, a := range held {
+        var err error
+        if scopeOf(a.Key) == LockoutScopeUsername {
+            err = g.repo.Reset(ctx, a.Key)
+        } else {
+            err = g.repo.ReleaseFailure(ctx, a.Key)
+        }
+        if err != nil && !errors.Is(err, repository.ErrLoginAttemptNotFound) { return err }
+    }
+    return nil
+}
+
+// ListLockouts 当前处于锁定状态的记录（管理端）。
+func (s *AuthService) ListLockouts(ctx context.Context) ([]domain.LoginAttempt, error) {
+    if s.guard == nil { return []domain.LoginAttempt{}, nil }
+    list, err := s.guard.repo.ListLocked(ctx, s.guard.now())
+    if err != nil { return nil, err }
+    if list == nil { list = []domain.LoginAttempt{} }
+    return list, nil
+}
+
+// UnlockUsername 管理员解除用户名锁定并清空其失败计数。
+func (s *AuthService) UnlockUsername(ctx context.Context, username string) error {
+    return s.unlock(ctx, usernameKey(username))
+}
+
+// UnlockIP 管理员解除 IP 锁定。
+func (s *AuthService) UnlockIP(ctx context.Context, ip string) error {
+    return s.unlock(ctx, ipKey(strings.TrimSpace(ip)))
+}
+
+func (s *AuthService) unlock(ctx context.Context, key string) error {
+    if s.guard == nil { return ErrLockoutNotFound }
+    if err := s.guard.repo.Reset(ctx, key); err != nil {
+        if errors.Is(err, repository.ErrLoginAttemptNotFound) { return ErrLockoutNotFound }
+        return err
+    }
+    metrics.IncLoginUnlock(scopeOf(key))
+    return nil
+}
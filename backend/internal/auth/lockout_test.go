@@ -0,0 +1,175 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/YangYuS8/codyssey/backend/internal/domain"
+	"github.com/YangYuS8/codyssey/backend/internal/repository"
+)
+
+type lockoutFixture struct {
+    svc   *AuthService
+    clock time.Time
+}
+
+func newLockoutFixture(t *testing.T, policy LockoutPolicy) *lockoutFixture {
+    t.Helper()
+    f := &lockoutFixture{clock: time.Unix(1700000000, 0)}
+    f.svc = NewAuthService(repository.NewMemoryUserRepository(), NewJWTManager("test-secret", time.Minute, time.Hour))
+    f.svc.EnableLockout(repository.NewMemoryLoginAttemptRepository(), policy)
+    f.svc.guard.now = func() time.Time { return f.clock }
+    if _, err := f.svc.Register(context.Background(), "alice", "secret123", []string{RoleStudent}); err != nil { t.Fatal(err) }
+    return f
+}
+
+func blocked(t *testing.T, err error) *LoginBlockedError {
+    t.Helper()
+    var be *LoginBlockedError
+    if !errors.As(err, &be) || !errors.Is(err, ErrLoginBlocked) { t.Fatalf("expected LoginBlockedError got %v", err) }
+    return be
+}
+
+func TestLockout_ProgressiveDelayThenLock(t *testing.T) {
+    p := DefaultLockoutPolicy()
+    f := newLockoutFixture(t, p)
+    ctx := context.Background()
+
+    // 前 FreeAttempts 次失败无需等待
+    for i := 0; i < p.FreeAttempts; i++ {
+        if _, err := f.svc.LoginFrom(ctx, "alice", "bad", "10.0.0.1"); err != ErrInvalidLogin { t.Fatalf("attempt %d: %v", i, err) }
+    }
+    // 第 3 次失败后需等待 1s，第 4 次后 2s
+    if _, err := f.svc.LoginFrom(ctx, "alice", "bad", "10.0.0.1"); err != ErrInvalidLogin { t.Fatal(err) }
+    be := blocked(t, func() error { _, err := f.svc.LoginFrom(ctx, "alice", "secret123", "10.0.0.1"); return err }())
+    if be.Locked || be.RetryAfter != time.Second || be.Scope != LockoutScopeUsername { t.Fatalf("unexpected %+v", be) }
+    f.clock = f.clock.Add(time.Second)
+    if _, err := f.svc.LoginFrom(ctx, "alice", "bad", "10.0.0.1"); err != ErrInvalidLogin { t.Fatal(err) }
+    be = blocked(t, func() error { _, err := f.svc.LoginFrom(ctx, "alice", "bad", "10.0.0.1"); return err }())
+    if be.RetryAfter != 2*time.Second { t.Fatalf("expected 2s delay got %s", be.RetryAfter) }
+
+    // 第 5 次失败触发锁定，正确密码也被拒绝
+    f.clock = f.clock.Add(2 * time.Second)
+    if _, err := f.svc.LoginFrom(ctx, "alice", "bad", "10.0.0.1"); err != ErrInvalidLogin { t.Fatal(err) }
+    f.clock = f.clock.Add(time.Minute)
+    be = blocked(t, func() error { _, err := f.svc.LoginFrom(ctx, "alice", "secret123", "10.0.0.2"); return err }())
+    if !be.Locked || be.RetryAfter != p.LockoutDuration-time.Minute { t.Fatalf("unexpected %+v", be) }
+    list, _ := f.svc.ListLockouts(ctx)
+    if len(list) != 1 || list[0].Key != "user:alice" { t.Fatalf("unexpected lockouts %+v", list) }
+
+    // 管理员解锁后可立即登录，计数清零
+    if err := f.svc.UnlockUsername(ctx, "Alice"); err != nil { t.Fatalf("unlock: %v", err) }
+    if err := f.svc.UnlockUsername(ctx, "alice"); err != ErrLockoutNotFound { t.Fatalf("expected ErrLockoutNotFound got %v", err) }
+    if res, err := f.svc.LoginFrom(ctx, "alice", "secret123", "10.0.0.1"); err != nil || res.Tokens == nil { t.Fatalf("login after unlock: %v", err) }
+}
+
+func TestLockout_WindowExpiryAndSuccessReset(t *testing.T) {
+    f := newLockoutFixture(t, DefaultLockoutPolicy())
+    ctx := context.Background()
+    for i := 0; i < 3; i++ { _, _ = f.svc.Login(ctx, "alice", "bad"); f.clock = f.clock.Add(10 * time.Second) }
+    // 成功登录清零用户名计数
+    if _, err := f.svc.Login(ctx, "alice", "secret123"); err != nil { t.Fatalf("login: %v", err) }
+    if _, err := f.svc.Login(ctx, "alice", "bad"); err != ErrInvalidLogin { t.Fatal(err) }
+    if _, err := f.svc.Login(ctx, "alice", "bad"); err != ErrInvalidLogin { t.Fatalf("count should restart after success: %v", err) }
+    // 超过窗口后重新计数
+    f.clock = f.clock.Add(16 * time.Minute)
+    for i := 0; i < 2; i++ {
+        if _, err := f.svc.Login(ctx, "alice", "bad"); err != ErrInvalidLogin { t.Fatalf("window should reset count: %v", err) }
+    }
+}
+
+func TestLockout_IPScope(t *testing.T) {
+    p := DefaultLockoutPolicy()
+    p.IPMaxFailures = 3
+    f := newLockoutFixture(t, p)
+    ctx := context.Background()
+    // 同一 IP 尝试不同用户名（撞库），IP 维度锁定
+    for _, name := range []string{"u1", "u2", "u3"} {
+        if _, err := f.svc.LoginFrom(ctx, name, "bad", "203.0.113.9"); err != ErrInvalidLogin { t.Fatal(err) }
+    }
+    be := blocked(t, func() error { _, err := f.svc.LoginFrom(ctx, "alice", "secret123", "203.0.113.9"); return err }())
+    if !be.Locked || be.Scope != LockoutScopeIP { t.Fatalf("unexpected %+v", be) }
+    // 其他 IP 不受影响
+    if _, err := f.svc.LoginFrom(ctx, "alice", "secret123", "203.0.113.10"); err != nil { t.Fatalf("other ip: %v", err) }
+    if err := f.svc.UnlockIP(ctx, "203.0.113.9"); err != nil { t.Fatal(err) }
+    if _, err := f.svc.LoginFrom(ctx, "alice", "secret123", "203.0.113.9"); err != nil { t.Fatalf("after ip unlock: %v", err) }
+}
+
+// concurrentLogins 同时发起 n 次错误密码登录，返回凭据错误与被拒绝（锁定 / 延迟）的次数。
+func concurrentLogins(t *testing.T, svc *AuthService, n int) (invalid, blockedCount int) {
+    t.Helper()
+    var mu sync.Mutex
+    var wg sync.WaitGroup
+    start := make(chan struct{})
+    for i := 0; i < n; i++ {
+        wg.Add(1)
+        go func() {
+            defer wg.Done()
+            <-start
+            _, err := svc.LoginFrom(context.Background(), "alice", "bad", "10.0.0.1")
+            mu.Lock(); defer mu.Unlock()
+            switch {
+            case errors.Is(err, ErrInvalidLogin):
+                invalid++
+            case errors.Is(err, ErrLoginBlocked):
+                blockedCount++
+            default:
+                t.Errorf("unexpected error %v", err)
+            }
+        }()
+    }
+    close(start)
+    wg.Wait()
+    return invalid, blockedCount
+}
+
+// 并发尝试在校验密码前就预占计数：不会全部通过检查后才记录失败。
+func TestLockout_ConcurrentAttempts(t *testing.T) {
+    ctx := context.Background()
+
+    // 只看锁定：并发 20 次最多有 UsernameMaxFailures 次真正比对密码，随后正确密码也被拒绝
+    p := DefaultLockoutPolicy()
+    p.FreeAttempts = 100
+    f := newLockoutFixture(t, p)
+    invalid, rejected := concurrentLogins(t, f.svc, 20)
+    if invalid > p.UsernameMaxFailures || invalid+rejected != 20 { t.Fatalf("invalid=%d rejected=%d", invalid, rejected) }
+    be := blocked(t, func() error { _, err := f.svc.LoginFrom(ctx, "alice", "secret123", "10.0.0.2"); return err }())
+    if !be.Locked || be.Scope != LockoutScopeUsername { t.Fatalf("unexpected %+v", be) }
+
+    // 渐进延迟：免延迟次数之后的并发尝试直接被拒绝
+    f = newLockoutFixture(t, DefaultLockoutPolicy())
+    invalid, rejected = concurrentLogins(t, f.svc, 20)
+    if invalid > DefaultLockoutPolicy().FreeAttempts+1 || invalid+rejected != 20 { t.Fatalf("invalid=%d rejected=%d", invalid, rejected) }
+}
+
+type downProvider struct{}
+
+func (downProvider) Name() string { return "ldap" }
+
+func (downProvider) Authenticate(ctx context.Context, username, password string) (domain.User, error) {
+    return domain.User{}, errors.New("dial tcp 10.0.0.9:389: connection refused")
+}
+
+// 目录不可达时：本地已明确拒绝的密码照常计数；没有任何否定结论时返回 ErrLoginUnavailable 且不计数。
+func TestLockout_ProviderOutage(t *testing.T) {
+    ctx := context.Background()
+    p := DefaultLockoutPolicy()
+    p.FreeAttempts = 100
+    f := newLockoutFixture(t, p)
+    f.svc.providers = append(f.svc.providers, downProvider{})
+
+    for i := 0; i < p.UsernameMaxFailures; i++ {
+        if _, err := f.svc.LoginFrom(ctx, "alice", "bad", "10.0.0.1"); err != ErrInvalidLogin { t.Fatalf("attempt %d: %v", i, err) }
+    }
+    be := blocked(t, func() error { _, err := f.svc.LoginFrom(ctx, "alice", "secret123", "10.0.0.1"); return err }())
+    if !be.Locked { t.Fatalf("unexpected %+v", be) }
+
+    for i := 0; i < p.UsernameMaxFailures+1; i++ {
+        _, err := f.svc.LoginFrom(ctx, "dir-user", "whatever", "10.0.0.3")
+        if !errors.Is(err, ErrLoginUnavailable) || err == ErrLoginUnavailable { t.Fatalf("expected wrapped ErrLoginUnavailable got %v", err) }
+    }
+    if a, err := f.svc.guard.repo.Get(ctx, usernameKey("dir-user")); err == nil && a.Failures != 0 { t.Fatalf("outage must not count failures: %+v", a) }
+}
@@ -0,0 +1,270 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/YangYuS8/codyssey/backend/internal/domain"
+	"github.com/YangYuS8/codyssey/backend/internal/repository"
+)
+
+var (
+    ErrMFARequired         = errors.New("mfa verification required")
+    ErrMFAInvalidCode      = errors.New("invalid mfa code")
+    ErrMFANotEnrolled      = errors.New("mfa not enrolled")
+    ErrMFAAlreadyEnabled   = errors.New("mfa already enabled")
+    ErrMFARequiredByPolicy = errors.New("mfa is required for this account")
+    ErrMFADisabled         = errors.New("mfa not configured on server")
+)
+
+const recoveryCodeCount = 10
+
+// MFAPolicy 二次验证策略：RequiredRoles 中任一角色的用户必须启用 TOTP。
+type MFAPolicy struct {
+    Issuer        string
+    RequiredRoles []string
+    TOTP          TOTPOptions
+}
+
+// DefaultMFAPolicy 管理员与教师可删除题目、改写判题结果，默认强制 MFA。
+func DefaultMFAPolicy() MFAPolicy {
+    return MFAPolicy{Issuer: "Codyssey", RequiredRoles: []string{RoleSystemAdmin, RoleTeacher}, TOTP: DefaultTOTPOptions}
+}
+
+func (p MFAPolicy) requires(roles []string) bool {
+    for _, r := range roles {
+        for _, want := range p.RequiredRoles { if r == want { return true } }
+    }
+    return false
+}
+
+type mfaConfig struct {
+    repo   repository.UserMFARepository
+    policy MFAPolicy
+    now    func() time.Time
+}
+
+// MFAChallenge 登录第一步通过后返回，客户端凭 Token 调用 /auth/mfa/challenge（或先登记）。
+type MFAChallenge struct {
+    Token              string `json:"mfa_token"`
+    ExpiresIn          int64  `json:"expires_in"`
+    EnrollmentRequired bool   `json:"enrollment_required"`
+}
+
+// MFAEnrollment 登记第一步：返回密钥与 otpauth URI（仅此一次明文返回）。
+type MFAEnrollment struct {
+    Secret string `json:"secret"`
+    URI    string `json:"otpauth_uri"`
+}
+
+// MFAStatus 当前用户的二次验证状态。
+type MFAStatus struct {
+    Enabled                bool       `json:"enabled"`
+    Required               bool       `json:"required"`
+    RecoveryCodesRemaining int        `json:"recovery_codes_remaining"`
+    ConfirmedAt            *time.Time `json:"confirmed_at,omitempty"`
+}
+
+// EnableMFA 为服务启用 TOTP 二次验证。
+func (s *AuthService) EnableMFA(repo repository.UserMFARepository, policy MFAPolicy) {
+    if policy.Issuer == "" { policy.Issuer = "Codyssey" }
+    s.mfa = &mfaConfig{repo: repo, policy: policy, now: time.Now}
+}
+
+func (s *AuthService) MFAEnabled() bool { return s.mfa != nil }
+
+// mfaChallengeFor 已启用 TOTP 或被策略强制的用户返回挑战，否则返回 nil。
+func (s *AuthService) mfaChallengeFor(ctx context.Context, u domain.User) (*MFAChallenge, error) {
+    if s.mfa == nil { return nil, nil }
+    rec, err := s.mfa.repo.Get(ctx, u.ID)
+    if err != nil && !errors.Is(err, repository.ErrMFANotFound) { return nil, err }
+    enabled := err == nil && rec.Enabled
+    if !enabled && !s.mfa.policy.requires(u.Roles) { return nil, nil }
+    tok, exp, err := s.jwt.GenerateMFAPending(u.ID, !enabled)
+    if err != nil { return nil, err }
+    return &MFAChallenge{Token: tok, ExpiresIn: int64(exp.Sub(time.Now().UTC()).Seconds()), EnrollmentRequired: !enabled}, nil
+}
+
+// ResolveMFAToken 解析待验证令牌，返回用户 ID（用于强制登记阶段的身份识别）。
+// 只校验签名与有效期；令牌完成登录后被标记为已使用，见 consumeMFAToken。
+func (s *AuthService) ResolveMFAToken(token string) (string, error) {
+    claims, err := s.jwt.ParseMFAPending(strings.TrimSpace(token))
+    if err != nil { return "", ErrInvalidToken }
+    return claims.UserID, nil
+}
+
+// consumeMFAToken 第二因素通过后、签发令牌前标记 jti 已使用：同一 mfa_token 只能完成一次登录。
+// 验证码错误不消耗令牌（失败次数由登录失败限制约束），登记流程中 begin 与 confirm 共用同一令牌。
+func (s *AuthService) consumeMFAToken(ctx context.Context, token string) error {
+    claims, err := s.jwt.ParseMFAPending(strings.TrimSpace(token))
+    if err != nil { return ErrInvalidToken }
+    ok, err := s.mfa.repo.ConsumeChallenge(ctx, claims.ID, claims.ExpiresAt.Time)
+    if err != nil { return err }
+    if !ok { return ErrInvalidToken }
+    return nil
+}
+
+// BeginMFAEnrollment 生成（或重置未确认的）TOTP 密钥。
+func (s *AuthService) BeginMFAEnrollment(ctx context.Context, userID string) (MFAEnrollment, error) {
+    if s.mfa == nil { return MFAEnrollment{}, ErrMFADisabled }
+    u, err := s.users.GetByID(ctx, userID)
+    if err != nil { return MFAEnrollment{}, err }
+    rec, err := s.mfa.repo.Get(ctx, userID)
+    if err == nil && rec.Enabled { return MFAEnrollment{}, ErrMFAAlreadyEnabled }
+    if err != nil && !errors.Is(err, repository.ErrMFANotFound) { return MFAEnrollment{}, err }
+    secret, err := GenerateTOTPSecret()
+    if err != nil { return MFAEnrollment{}, err }
+    if err := s.mfa.repo.Save(ctx, domain.UserMFA{UserID: userID, Secret: secret, CreatedAt: rec.CreatedAt}); err != nil { return MFAEnrollment{}, err }
+    return MFAEnrollment{Secret: secret, URI: TOTPURI(s.mfa.policy.Issuer, u.Username, secret, s.mfa.policy.TOTP)}, nil
+}
+
+// ConfirmMFAEnrollment 用首个验证码确认登记并启用，返回一次性恢复码明文。
+func (s *AuthService) ConfirmMFAEnrollment(ctx context.Context, userID, code string) ([]string, error) {
+    if s.mfa == nil { return nil, ErrMFADisabled }
+    rec, err := s.mfa.repo.Get(ctx, userID)
+    if err != nil {
+        if errors.Is(err, repository.ErrMFANotFound) { return nil, ErrMFANotEnrolled }
+        return nil, err
+    }
+    if rec.Enabled { return nil, ErrMFAAlreadyEnabled }
+    step, ok := VerifyTOTP(rec.Secret, code, s.mfa.now(), s.mfa.policy.TOTP)
+    if !ok { return nil, ErrMFAInvalidCode }
+    codes, hashes, err := generateRecoveryCodes(recoveryCodeCount)
+    if err != nil { return nil, err }
+    now := s.mfa.now().UTC()
+    rec.Enabled = true
+    rec.LastUsedStep = step
+    rec.RecoveryCodes = hashes
+    rec.ConfirmedAt = &now
+    // 条件启用：并发确认或期间重新生成了密钥时只有一个成功
+    enabled, err := s.mfa.repo.Enable(ctx, rec)
+    if err != nil { return nil, err }
+    if !enabled { return nil, ErrMFAAlreadyEnabled }
+    return codes, nil
+}
+
+// CompleteEnrollmentLogin 强制登记流程：凭待验证令牌确认登记后直接签发令牌。
+func (s *AuthService) CompleteEnrollmentLogin(ctx context.Context, mfaToken, code string) (LoginResult, []string, error) {
+    userID, err := s.ResolveMFAToken(mfaToken)
+    if err != nil { return LoginResult{}, nil, err }
+    codes, err := s.ConfirmMFAEnrollment(ctx, userID, code)
+    if err != nil { return LoginResult{}, nil, err }
+    if err := s.consumeMFAToken(ctx, mfaToken); err != nil { return LoginResult{}, nil, err }
+    u, err := s.users.GetByID(ctx, userID)
+    if err != nil { return LoginResult{}, nil, ErrInvalidToken }
+    pair, err := s.issueTokens(u)
+    if err != nil { return LoginResult{}, nil, err }
+    return LoginResult{User: u, Tokens: &pair}, codes, nil
+}
+
+// CompleteMFALogin 登录第二步：TOTP 验证码或恢复码二选一。
+func (s *AuthService) CompleteMFALogin(ctx context.Context, mfaToken, code, recoveryCode string) (LoginResult, error) {
+    if s.mfa == nil { return LoginResult{}, ErrMFADisabled }
+    userID, err := s.ResolveMFAToken(mfaToken)
+    if err != nil { return LoginResult{}, err }
+    u, err := s.users.GetByID(ctx, userID)
+    if err != nil { return LoginResult{}, ErrInvalidToken }
+    // 第二因素同样计入用户名失败次数，防止在 mfa_token 有效期内穷举验证码
+    var held []domain.LoginAttempt
+    if s.guard != nil {
+        if held, err = s.guard.reserve(ctx, []string{usernameKey(u.Username)}); err != nil { return LoginResult{}, err }
+    }
+    if err := s.verifySecondFactor(ctx, userID, code, recoveryCode); err != nil {
+        if s.guard != nil {
+            settle := s.guard.release
+            if errors.Is(err, ErrMFAInvalidCode) { settle = s.guard.fail }
+            if gerr := settle(ctx, held); gerr != nil { return LoginResult{}, gerr }
+        }
+        return LoginResult{}, err
+    }
+    if s.guard != nil {
+        if err := s.guard.release(ctx, held); err != nil { return LoginResult{}, err }
+    }
+    if err := s.consumeMFAToken(ctx, mfaToken); err != nil { return LoginResult{}, err }
+    pair, err := s.issueTokens(u)
+    if err != nil { return LoginResult{}, err }
+    return LoginResult{User: u, Tokens: &pair}, nil
+}
+
+// DisableMFA 需当前验证码（或恢复码）；被策略强制的账号不可关闭。
+func (s *AuthService) DisableMFA(ctx context.Context, userID, code, recoveryCode string) error {
+    if s.mfa == nil { return ErrMFADisabled }
+    u, err := s.users.GetByID(ctx, userID)
+    if err != nil { return err }
+    if s.mfa.policy.requires(u.Roles) { return ErrMFARequiredByPolicy }
+    if err := s.verifySecondFactor(ctx, userID, code, recoveryCode); err != nil { return err }
+    return s.mfa.repo.Delete(ctx, userID)
+}
+
+// GetMFAStatus 查询登记状态。
+func (s *AuthService) GetMFAStatus(ctx context.Context, userID string) (MFAStatus, error) {
+    if s.mfa == nil { return MFAStatus{}, ErrMFADisabled }
+    u, err := s.users.GetByID(ctx, userID)
+    if err != nil { return MFAStatus{}, err }
+    st := MFAStatus{Required: s.mfa.policy.requires(u.Roles)}
+    rec, err := s.mfa.repo.Get(ctx, userID)
+    if err != nil {
+        if errors.Is(err, repository.ErrMFANotFound) { return st, nil }
+        return MFAStatus{}, err
+    }
+    st.Enabled = rec.Enabled
+    st.RecoveryCodesRemaining = len(rec.RecoveryCodes)
+    st.ConfirmedAt = rec.ConfirmedAt
+    return st, nil
+}
+
+// verifySecondFactor 校验在内存中完成，消耗（恢复码移除、时间步前移）由仓储条件更新完成，
+// 并发使用同一验证码或恢复码只有一个请求通过。
+func (s *AuthService) verifySecondFactor(ctx context.Context, userID, code, recoveryCode string) error {
+    rec, err := s.mfa.repo.Get(ctx, userID)
+    if err != nil {
+        if errors.Is(err, repository.ErrMFANotFound) { return ErrMFANotEnrolled }
+        return err
+    }
+    if !rec.Enabled { return ErrMFANotEnrolled }
+    if rc := normalizeRecoveryCode(recoveryCode); rc != "" {
+        sum := sha256.Sum256([]byte(rc))
+        want := hex.EncodeToString(sum[:])
+        for _, h := range rec.RecoveryCodes {
+            if subtle.ConstantTimeCompare([]byte(h), []byte(want)) == 1 { return s.useFactor(s.mfa.repo.UseRecoveryCode(ctx, userID, h)) }
+        }
+        return ErrMFAInvalidCode
+    }
+    step, ok := VerifyTOTP(rec.Secret, code, s.mfa.now(), s.mfa.policy.TOTP)
+    // 同一时间步（及更早）的验证码只能用一次
+    if !ok || step <= rec.LastUsedStep { return ErrMFAInvalidCode }
+    return s.useFactor(s.mfa.repo.UseStep(ctx, userID, step))
+}
+
+// useFactor 条件更新未命中说明已被并发请求使用。
+func (s *AuthService) useFactor(ok bool, err error) error {
+    if err != nil { return err }
+    if !ok { return ErrMFAInvalidCode }
+    return nil
+}
+
+// generateRecoveryCodes 返回明文（xxxxx-xxxxx）与其 SHA-256 摘要；恢复码熵足够，无需慢哈希。
+func generateRecoveryCodes(n int) ([]string, []string, error) {
+    codes := make([]string, 0, n)
+    hashes := make([]string, 0, n)
+    for i := 0; i < n; i++ {
+        buf := make([]byte, 5)
+        if _, err := rand.Read(buf); err != nil { return nil, nil, err }
+        raw := hex.EncodeToString(buf)
+        code := raw[:5] + "-" + raw[5:]
+        sum := sha256.Sum256([]byte(normalizeRecoveryCode(code)))
+        codes = append(codes, code)
+        hashes = append(hashes, hex.EncodeToString(sum[:]))
+    }
+    return codes, hashes, nil
+}
+
+func normalizeRecoveryCode(c string) string {
+    return strings.ToLower(strings.ReplaceAll(strings.TrimSpace(c), "-", ""))
+}
@@ -0,0 +1,123 @@
+package auth
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/YangYuS8/codyssey/backend/internal/repository"
+)
+
+type mfaFixture struct {
+    svc   *AuthService
+    jwt   *JWTManager
+    clock time.Time
+}
+
+func newMFAFixture(t *testing.T) *mfaFixture {
+    t.Helper()
+    users := repository.NewMemoryUserRepository()
+    f := &mfaFixture{jwt: NewJWTManager("test-secret", time.Minute, time.Hour), clock: time.Unix(1700000000, 0)}
+    f.svc = NewAuthService(users, f.jwt)
+    f.svc.EnableMFA(repository.NewMemoryUserMFARepository(), DefaultMFAPolicy())
+    f.svc.mfa.now = func() time.Time { return f.clock }
+    return f
+}
+
+func (f *mfaFixture) code(t *testing.T, secret string) string {
+    t.Helper()
+    c, err := TOTPCode(secret, f.clock, DefaultTOTPOptions)
+    if err != nil { t.Fatal(err) }
+    return c
+}
+
+func TestMFA_StudentOptionalEnrollmentAndChallenge(t *testing.T) {
+    f := newMFAFixture(t)
+    ctx := context.Background()
+    res, err := f.svc.Register(ctx, "stud", "secret123", []string{RoleStudent})
+    if err != nil || res.Tokens == nil || res.MFA != nil { t.Fatalf("student without mfa should get tokens: %+v %v", res, err) }
+
+    en, err := f.svc.BeginMFAEnrollment(ctx, res.User.ID)
+    if err != nil || en.URI == "" { t.Fatalf("enroll: %+v %v", en, err) }
+    if _, err := f.svc.ConfirmMFAEnrollment(ctx, res.User.ID, "000000"); err != ErrMFAInvalidCode { t.Fatalf("expected invalid code got %v", err) }
+    codes, err := f.svc.ConfirmMFAEnrollment(ctx, res.User.ID, f.code(t, en.Secret))
+    if err != nil || len(codes) != recoveryCodeCount { t.Fatalf("confirm: %v %d", err, len(codes)) }
+
+    login, err := f.svc.Login(ctx, "stud", "secret123")
+    if err != nil || login.Tokens != nil || login.MFA == nil { t.Fatalf("expected mfa challenge: %+v %v", login, err) }
+    if _, err := f.jwt.ParseAccess(login.MFA.Token); err == nil { t.Fatalf("pending token must not be usable as access token") }
+    if _, _, err := f.svc.Authenticate(ctx, "stud", "secret123"); err != ErrMFARequired { t.Fatalf("expected ErrMFARequired got %v", err) }
+
+    // 确认登记时使用过的验证码不可重放
+    if _, err := f.svc.CompleteMFALogin(ctx, login.MFA.Token, f.code(t, en.Secret), ""); err != ErrMFAInvalidCode { t.Fatalf("replay must be rejected, got %v", err) }
+    f.clock = f.clock.Add(30 * time.Second)
+    done, err := f.svc.CompleteMFALogin(ctx, login.MFA.Token, f.code(t, en.Secret), "")
+    if err != nil || done.Tokens == nil { t.Fatalf("challenge: %v", err) }
+    // mfa_token 只能完成一次登录
+    if _, err := f.svc.CompleteMFALogin(ctx, login.MFA.Token, "", codes[1]); err != ErrInvalidToken { t.Fatalf("mfa token reuse must fail, got %v", err) }
+
+    // 恢复码仅能使用一次
+    login, _ = f.svc.Login(ctx, "stud", "secret123")
+    if _, err := f.svc.CompleteMFALogin(ctx, login.MFA.Token, "", codes[0]); err != nil { t.Fatalf("recovery code: %v", err) }
+    login, _ = f.svc.Login(ctx, "stud", "secret123")
+    if _, err := f.svc.CompleteMFALogin(ctx, login.MFA.Token, "", codes[0]); err != ErrMFAInvalidCode { t.Fatalf("recovery code reuse must fail, got %v", err) }
+    st, _ := f.svc.GetMFAStatus(ctx, res.User.ID)
+    if !st.Enabled || st.Required || st.RecoveryCodesRemaining != recoveryCodeCount-2 { t.Fatalf("unexpected status %+v", st) }
+
+    f.clock = f.clock.Add(30 * time.Second)
+    if err := f.svc.DisableMFA(ctx, res.User.ID, f.code(t, en.Secret), ""); err != nil { t.Fatalf("disable: %v", err) }
+    again, _ := f.svc.Login(ctx, "stud", "secret123")
+    if again.Tokens == nil { t.Fatalf("tokens expected after disabling mfa") }
+}
+
+func TestMFA_PolicyForcesTeacherEnrollment(t *testing.T) {
+    f := newMFAFixture(t)
+    ctx := context.Background()
+    res, err := f.svc.Register(ctx, "teach", "secret123", []string{RoleTeacher})
+    if err != nil { t.Fatal(err) }
+    if res.Tokens != nil || res.MFA == nil || !res.MFA.EnrollmentRequired { t.Fatalf("teacher must enroll before receiving tokens: %+v", res) }
+
+    uid, err := f.svc.ResolveMFAToken(res.MFA.Token)
+    if err != nil || uid != res.User.ID { t.Fatalf("resolve: %v", err) }
+    en, err := f.svc.BeginMFAEnrollment(ctx, uid)
+    if err != nil { t.Fatal(err) }
+    done, codes, err := f.svc.CompleteEnrollmentLogin(ctx, res.MFA.Token, f.code(t, en.Secret))
+    if err != nil || done.Tokens == nil || len(codes) == 0 { t.Fatalf("enrollment login: %v", err) }
+    if err := f.svc.DisableMFA(ctx, uid, "", codes[0]); err != ErrMFARequiredByPolicy { t.Fatalf("expected policy error got %v", err) }
+    if _, err := f.svc.ResolveMFAToken(done.Tokens.AccessToken); err == nil { t.Fatalf("access token must not be accepted as mfa token") }
+}
+
+// 并发提交同一验证码 / 恢复码 / mfa_token，只有一个请求通过。
+func TestMFA_ConcurrentSecondFactorIsOneTime(t *testing.T) {
+    f := newMFAFixture(t)
+    ctx := context.Background()
+    res, err := f.svc.Register(ctx, "race", "secret123", []string{RoleStudent})
+    if err != nil { t.Fatal(err) }
+    en, _ := f.svc.BeginMFAEnrollment(ctx, res.User.ID)
+    codes, err := f.svc.ConfirmMFAEnrollment(ctx, res.User.ID, f.code(t, en.Secret))
+    if err != nil { t.Fatal(err) }
+    f.clock = f.clock.Add(30 * time.Second)
+
+    race := func(n int, attempt func(i int) error) int64 {
+        var ok int64
+        var wg sync.WaitGroup
+        for i := 0; i < n; i++ {
+            wg.Add(1)
+            go func(i int) { defer wg.Done(); if attempt(i) == nil { atomic.AddInt64(&ok, 1) } }(i)
+        }
+        wg.Wait()
+        return ok
+    }
+    tokens := make([]string, 8)
+    for i := range tokens { l, _ := f.svc.Login(ctx, "race", "secret123"); tokens[i] = l.MFA.Token }
+    totp := f.code(t, en.Secret)
+    if n := race(8, func(i int) error { _, err := f.svc.CompleteMFALogin(ctx, tokens[i], totp, ""); return err }); n != 1 { t.Fatalf("same totp accepted %d times", n) }
+
+    for i := range tokens { l, _ := f.svc.Login(ctx, "race", "secret123"); tokens[i] = l.MFA.Token }
+    if n := race(8, func(i int) error { _, err := f.svc.CompleteMFALogin(ctx, tokens[i], "", codes[0]); return err }); n != 1 { t.Fatalf("same recovery code accepted %d times", n) }
+
+    l, _ := f.svc.Login(ctx, "race", "secret123")
+    if n := race(8, func(i int) error { _, err := f.svc.CompleteMFALogin(ctx, l.MFA.Token, "", codes[1+i]); return err }); n != 1 { t.Fatalf("same mfa token completed %d logins", n) }
+}
@@ -1,13 +1,16 @@
 package auth
 
 import (
+	"context"
 	"net/http"
-	"os"
 	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	jwt "github.com/golang-jwt/jwt/v5"
+	"go.uber.org/zap"
+
+	"github.com/YangYuS8/codyssey/backend/internal/logging"
 )
 
 const ctxKeyIdentity = "__identity"
@@ -22,23 +25,47 @@ type jwtCustomClaims struct {
     jwt.RegisteredClaims
 }
 
+// APITokenResolver 将 API Token 明文解析为身份（*APITokenService 实现）。
+type APITokenResolver interface {
+    Resolve(ctx context.Context, raw string) (*Identity, error)
+}
+
+// apiTokenFromHeader 解析 "Authorization: Token <令牌>"。
+func apiTokenFromHeader(authz string) (string, bool) {
+    if len(authz) < 6 || !strings.EqualFold(authz[:6], "token ") { return "", false }
+    return strings.TrimSpace(authz[6:]), true
+}
+
+// attachAPIToken 处理 Token 认证；返回 true 表示已处理（成功放行或已 401）。
+func attachAPIToken(c *gin.Context, tokens []APITokenResolver) bool {
+    raw, ok := apiTokenFromHeader(c.GetHeader("Authorization"))
+    if !ok { return false }
+    if len(tokens) == 0 || tokens[0] == nil { unauthorized(c, "api tokens not supported"); return true }
+    id, err := tokens[0].Resolve(c.Request.Context(), raw)
+    if err != nil { unauthorized(c, "invalid, expired or revoked api token"); return true }
+    setIdentity(c, id)
+    c.Next()
+    return true
+}
+
 // AttachDebugIdentity 同时支持：
 // 1) Authorization: Bearer <token>
 // 2) X-Debug-Roles / X-Debug-Perms (用于本地调试叠加)
 // 优先 JWT，再叠加 debug 头。
-func AttachDebugIdentity() gin.HandlerFunc {
+// 传入 tokens 时额外支持 Authorization: Token <api token>，此时身份仅含令牌作用域，不叠加 debug 头。
+// secret 为空时使用开发默认密钥（与配置默认值一致）。
+func AttachDebugIdentity(secret string, tokens ...APITokenResolver) gin.HandlerFunc {
+    if strings.TrimSpace(secret) == "" { secret = devJWTSecret }
+    key := []byte(secret)
     return func(c *gin.Context) {
+        if attachAPIToken(c, tokens) { return }
         var id = &Identity{UserID: "guest", Roles: []string{RoleGuest}, Permissions: map[Permission]struct{}{}}
 
         // 解析 JWT（可选）
-        authz := c.GetHeader("Authorization")
-        if strings.HasPrefix(strings.ToLower(authz), "bearer ") {
-            tokenStr := strings.TrimSpace(authz[7:])
+        if tokenStr, ok := bearerToken(c); ok {
             if tokenStr != "" {
                 claims := &jwtCustomClaims{}
-                // 暂时从环境变量里拿密钥（之后可注入）
-                secret := []byte(getJWTSecret())
-                t, err := jwt.ParseWithClaims(tokenStr, claims, func(token *jwt.Token) (interface{}, error) { return secret, nil })
+                t, err := jwt.ParseWithClaims(tokenStr, claims, func(token *jwt.Token) (interface{}, error) { return key, nil })
                 if err == nil && t.Valid {
                     if claims.UserID != "" { id.UserID = claims.UserID }
                     if len(claims.Roles) > 0 { id.Roles = append(id.Roles, claims.Roles...) }
@@ -65,21 +92,21 @@ func AttachDebugIdentity() gin.HandlerFunc {
         mergeRolePermissions(id)
         // read 系列兜底
         id.Permissions[PermProblemRead] = struct{}{}
-        c.Set(ctxKeyIdentity, id)
+        setIdentity(c, id)
         c.Next()
     }
 }
 
 // StrictJWTAuth 仅解析并要求有效 JWT，不支持 debug 头；失败直接 401。
-// 适用于非 development 环境。
-func StrictJWTAuth(secret string) gin.HandlerFunc {
+// 适用于非 development 环境。传入 tokens 时同样接受 Authorization: Token <api token>。
+func StrictJWTAuth(secret string, tokens ...APITokenResolver) gin.HandlerFunc {
     return func(c *gin.Context) {
-        authz := c.GetHeader("Authorization")
-        if !strings.HasPrefix(strings.ToLower(authz), "bearer ") {
+        if attachAPIToken(c, tokens) { return }
+        tokenStr, ok := bearerToken(c)
+        if !ok {
             unauthorized(c, "missing bearer token")
             return
         }
-        tokenStr := strings.TrimSpace(authz[7:])
         if tokenStr == "" { unauthorized(c, "empty token"); return }
         claims := &jwtCustomClaims{}
         t, err := jwt.ParseWithClaims(tokenStr, claims, func(token *jwt.Token) (interface{}, error) { return []byte(secret), nil })
@@ -89,20 +116,37 @@ func StrictJWTAuth(secret string) gin.HandlerFunc {
         }
         id := &Identity{UserID: claims.UserID, Roles: claims.Roles, Permissions: map[Permission]struct{}{}}
         mergeRolePermissions(id)
-        c.Set(ctxKeyIdentity, id)
+        setIdentity(c, id)
         c.Next()
     }
 }
 
+// bearerToken 读取 Authorization: Bearer。浏览器 EventSource / WebSocket 无法设置请求头，
+// 因此 GET 事件流请求（Accept: text/event-stream）与 WebSocket 升级请求额外接受 ?access_token=。
+func bearerToken(c *gin.Context) (string, bool) {
+    authz := c.GetHeader("Authorization")
+    if strings.HasPrefix(strings.ToLower(authz), "bearer ") { return strings.TrimSpace(authz[7:]), true }
+    stream := strings.Contains(c.GetHeader("Accept"), "text/event-stream") || strings.EqualFold(c.GetHeader("Upgrade"), "websocket")
+    if authz == "" && c.Request.Method == http.MethodGet && stream {
+        if t := strings.TrimSpace(c.Query("access_token")); t != "" { return t, true }
+    }
+    return "", false
+}
+
 func unauthorized(c *gin.Context, msg string) {
     c.JSON(http.StatusUnauthorized, gin.H{"data": nil, "error": gin.H{"code": "UNAUTHORIZED", "message": msg}})
     c.Abort()
 }
 
-// getJWTSecret 暂时简单实现：从环境变量读取（避免循环依赖 config 包）
-func getJWTSecret() string {
-    if v := strings.TrimSpace(os.Getenv("JWT_SECRET")); v != "" { return v }
-    return "dev-secret-change-me"
+// devJWTSecret 开发环境默认密钥（config 默认 JWT_SECRET；非 development 环境启动校验会拒绝）
+const devJWTSecret = "dev-secret-change-me"
+
+// setIdentity 保存身份，并为请求级 logger 追加 user_id（guest 除外）。
+func setIdentity(c *gin.Context, id *Identity) {
+    c.Set(ctxKeyIdentity, id)
+    if id.UserID != "" && id.UserID != "guest" {
+        c.Request = c.Request.WithContext(logging.With(c.Request.Context(), zap.String("user_id", id.UserID)))
+    }
 }
 
 // 从 context 取出身份
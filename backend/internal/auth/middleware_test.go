@@ -35,7 +35,7 @@ func protectedHandler() gin.HandlerFunc {
 func setupTestRouter(perms ...Permission) *gin.Engine {
     gin.SetMode(gin.TestMode)
     r := gin.New()
-    r.Use(AttachDebugIdentity())
+    r.Use(AttachDebugIdentity(""))
     r.GET("/protected", Require(perms...), protectedHandler())
     return r
 }
@@ -101,3 +101,24 @@ func TestInvalidTokenIgnored(t *testing.T) {
     r.ServeHTTP(w, req)
     if w.Code != http.StatusForbidden { t.Fatalf("expected 403 got %d", w.Code) }
 }
+
+func TestStrictJWTAccessTokenQueryOnlyForEventStream(t *testing.T) {
+    secret := "strict-secret"
+    tok := makeToken(t, secret, "u1", []string{RoleStudent}, nil)
+    gin.SetMode(gin.TestMode)
+    r := gin.New()
+    r.Use(StrictJWTAuth(secret))
+    r.GET("/events", func(c *gin.Context) { c.String(http.StatusOK, GetIdentity(c).UserID) })
+
+    cases := []struct{ accept string; want int }{
+        {"text/event-stream", http.StatusOK},
+        {"application/json", http.StatusUnauthorized},
+    }
+    for _, tc := range cases {
+        w := httptest.NewRecorder()
+        req, _ := http.NewRequest(http.MethodGet, "/events?access_token="+tok, nil)
+        req.Header.Set("Accept", tc.accept)
+        r.ServeHTTP(w, req)
+        if w.Code != tc.want { t.Fatalf("accept=%s: expected %d got %d", tc.accept, tc.want, w.Code) }
+    }
+}
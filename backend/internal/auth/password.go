@@ -0,0 +1,25 @@
+package auth
+
+import "golang.org/x/crypto/bcrypt"
+
+const MinPasswordLength = 6
+
+// ValidatePassword 基础强度校验（与注册一致）。
+func ValidatePassword(password string) error {
+    if len(password) < MinPasswordLength { return ErrWeakPassword }
+    return nil
+}
+
+// HashPassword 校验强度后返回 bcrypt 摘要。
+func HashPassword(password string) (string, error) {
+    if err := ValidatePassword(password); err != nil { return "", err }
+    b, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+    if err != nil { return "", err }
+    return string(b), nil
+}
+
+// CheckPassword 比对明文与摘要；摘要为空（外部目录用户）总是失败。
+func CheckPassword(hash, password string) bool {
+    if hash == "" { return false }
+    return bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)) == nil
+}
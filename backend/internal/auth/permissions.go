@@ -18,6 +18,9 @@ const (
     PermProblemRead   Permission = "problem.read"
     PermProblemUpdate Permission = "problem.update"
     PermProblemDelete Permission = "problem.delete"
+    // 发布审核：review 通过 / 驳回待审题目；archive 下架 / 恢复题目
+    PermProblemReview  Permission = "problem.review"
+    PermProblemArchive Permission = "problem.archive"
     // 更细粒度（后续可替换掉 problem.read）
     PermProblemList Permission = "problem.list"
     PermProblemGet  Permission = "problem.get"
@@ -28,6 +31,8 @@ const (
     PermUserGet    Permission = "user.get"
     PermUserUpdateRoles Permission = "user.update_roles"
     PermUserDelete Permission = "user.delete"
+    PermUserUnlock Permission = "user.unlock" // 查看/解除登录锁定
+    PermUserResetPassword Permission = "user.reset_password"
     // 提交相关权限（初版占位）
     PermSubmissionCreate Permission = "submission.create"
     PermSubmissionGet    Permission = "submission.get"
@@ -39,13 +44,37 @@ const (
     PermJudgeRunList    Permission = "judge_run.list"
     // 内部管理（start/finish 调度权限）
     PermJudgeRunManage  Permission = "judge_run.manage"
+    // API Token：create 签发个人令牌；manage 签发服务令牌、查看/吊销任意令牌
+    PermAPITokenCreate Permission = "api_token.create"
+    PermAPITokenManage Permission = "api_token.manage"
+    // 实时通道：向比赛榜单 / 答疑 / 公告主题发布消息
+    PermRealtimePublish Permission = "realtime.publish"
+    // AI 服务：按提示词生成题目；查看提交的 AI 代码检测结果并管理检测开关
+    PermAIGenerate Permission = "ai.generate"
+    PermAIDetect   Permission = "ai.detect"
+    // 系统管理：运行时参数查看与修改
+    PermSystemManage Permission = "system.manage"
 )
 
+// AllPermissions 全部已定义权限（API Token 作用域校验用）
+var AllPermissions = []Permission{
+    PermProblemCreate, PermProblemRead, PermProblemUpdate, PermProblemDelete, PermProblemList, PermProblemGet,
+    PermProblemReview, PermProblemArchive,
+    PermUserCreate, PermUserRead, PermUserList, PermUserGet, PermUserUpdateRoles, PermUserDelete, PermUserUnlock, PermUserResetPassword,
+    PermSubmissionCreate, PermSubmissionGet, PermSubmissionList, PermSubmissionUpdateStatus,
+    PermJudgeRunEnqueue, PermJudgeRunGet, PermJudgeRunList, PermJudgeRunManage,
+    PermAPITokenCreate, PermAPITokenManage,
+    PermRealtimePublish,
+    PermAIGenerate, PermAIDetect,
+    PermSystemManage,
+}
+
 // 简单用户身份模型（后续替换为 JWT 解析结果）
 type Identity struct {
     UserID      string
     Roles       []string
     Permissions map[Permission]struct{}
+    TokenID     string // 非空表示通过 API Token 认证（权限仅限令牌作用域）
 }
 
 func (i *Identity) Has(p Permission) bool {
@@ -63,13 +92,17 @@ func NewDebugIdentity(perms []Permission) *Identity {
 // 角色到权限的静态初版映射（后续可迁移 DB / 缓存）
 var rolePermissionMap = map[string][]Permission{
     RoleSystemAdmin: {PermProblemCreate, PermProblemUpdate, PermProblemDelete, PermProblemRead, PermProblemList, PermProblemGet,
-        PermUserCreate, PermUserRead, PermUserList, PermUserGet, PermUserUpdateRoles, PermUserDelete,
+        PermProblemReview, PermProblemArchive,
+        PermUserCreate, PermUserRead, PermUserList, PermUserGet, PermUserUpdateRoles, PermUserDelete, PermUserUnlock, PermUserResetPassword,
         PermSubmissionCreate, PermSubmissionGet, PermSubmissionList, PermSubmissionUpdateStatus,
-        PermJudgeRunEnqueue, PermJudgeRunGet, PermJudgeRunList, PermJudgeRunManage},
+        PermJudgeRunEnqueue, PermJudgeRunGet, PermJudgeRunList, PermJudgeRunManage,
+        PermAPITokenCreate, PermAPITokenManage, PermRealtimePublish, PermAIGenerate, PermAIDetect, PermSystemManage},
     RoleTeacher:     {PermProblemCreate, PermProblemUpdate, PermProblemDelete, PermProblemRead, PermProblemList, PermProblemGet,
+        PermProblemArchive,
         PermUserRead, PermUserList, PermUserGet,
         PermSubmissionCreate, PermSubmissionGet, PermSubmissionList, PermSubmissionUpdateStatus,
-        PermJudgeRunEnqueue, PermJudgeRunGet, PermJudgeRunList},
+        PermJudgeRunEnqueue, PermJudgeRunGet, PermJudgeRunList,
+        PermAPITokenCreate, PermRealtimePublish, PermAIGenerate, PermAIDetect},
     RoleStudent:     {PermProblemRead, PermProblemList, PermProblemGet, PermUserGet, PermSubmissionCreate, PermSubmissionGet, PermSubmissionList},
     RoleContestant:  {PermProblemRead, PermProblemList, PermProblemGet, PermUserGet, PermSubmissionCreate, PermSubmissionGet, PermSubmissionList},
     RoleGuest:       {PermProblemRead, PermProblemList, PermProblemGet},
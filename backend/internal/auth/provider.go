@@ -0,0 +1,57 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/YangYuS8/codyssey/backend/internal/domain"
+	"github.com/YangYuS8/codyssey/backend/internal/repository"
+)
+
+// CredentialProvider 凭据校验提供者：校验用户名/密码并返回对应的本地用户。
+// 约定：凭据不匹配（或该提供者不认识此用户）返回 ErrInvalidLogin，链会继续尝试下一个提供者；
+// 其他错误视为提供者故障（例如目录服务不可达），同样继续尝试。
+type CredentialProvider interface {
+    Name() string
+    Authenticate(ctx context.Context, username, password string) (domain.User, error)
+}
+
+// LocalProvider 基于 users.password_hash 的本地 bcrypt 校验。
+type LocalProvider struct { users repository.UserRepository }
+
+func NewLocalProvider(users repository.UserRepository) *LocalProvider { return &LocalProvider{users: users} }
+
+func (p *LocalProvider) Name() string { return "local" }
+
+func (p *LocalProvider) Authenticate(ctx context.Context, username, password string) (domain.User, error) {
+    u, err := p.users.GetByUsername(ctx, username)
+    if err != nil {
+        if errors.Is(err, repository.ErrUserNotFound) { return domain.User{}, errUnknownUser }
+        return domain.User{}, err
+    }
+    // 外部目录自动开通的用户没有本地密码，交给后续提供者
+    if u.PasswordHash == "" { return domain.User{}, errUnknownUser }
+    if !CheckPassword(u.PasswordHash, password) { return domain.User{}, ErrInvalidLogin }
+    return u, nil
+}
+
+// errUnknownUser 本地没有该用户或其没有本地密码：不算对凭据的否定，由后续提供者决定。
+var errUnknownUser = fmt.Errorf("%w: no local password", ErrInvalidLogin)
+
+// authenticateChain 依次调用提供者，首个成功者胜出。
+// 任一提供者明确拒绝了凭据即返回 ErrInvalidLogin（计入失败次数），即使其他提供者故障；
+// 只有故障、没有任何否定结论时返回包装 ErrLoginUnavailable 的错误。
+func authenticateChain(ctx context.Context, providers []CredentialProvider, username, password string) (domain.User, error) {
+    var failure error
+    rejected := false
+    for _, p := range providers {
+        u, err := p.Authenticate(ctx, username, password)
+        if err == nil { return u, nil }
+        if errors.Is(err, errUnknownUser) { continue }
+        if errors.Is(err, ErrInvalidLogin) { rejected = true; continue }
+        if failure == nil { failure = fmt.Errorf("%w: %s provider: %v", ErrLoginUnavailable, p.Name(), err) }
+    }
+    if failure != nil && !rejected { return domain.User{}, failure }
+    return domain.User{}, ErrInvalidLogin
+}
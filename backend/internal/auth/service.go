@@ -7,59 +7,109 @@ import (
 	"time"
 
 	"github.com/YangYuS8/codyssey/backend/internal/domain"
+	"github.com/YangYuS8/codyssey/backend/internal/logging"
 	"github.com/YangYuS8/codyssey/backend/internal/repository"
-	"golang.org/x/crypto/bcrypt"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
 )
 
 var (
     ErrUsernameTaken   = errors.New("username already taken")
     ErrInvalidLogin    = errors.New("invalid username or password")
     ErrWeakPassword    = errors.New("password too weak (min 6 chars)")
+    // ErrLoginUnavailable 没有任何提供者给出结论（目录不可达等）；具体原因只写日志，不返回给客户端
+    ErrLoginUnavailable = errors.New("login temporarily unavailable")
 )
 
 type AuthService struct {
-    users repository.UserRepository
-    jwt   *JWTManager
+    users     repository.UserRepository
+    jwt       *JWTManager
+    providers []CredentialProvider
+    mfa       *mfaConfig  // nil 表示未启用 MFA
+    guard     *loginGuard // nil 表示不做失败计数
 }
 
-func NewAuthService(users repository.UserRepository, jwt *JWTManager) *AuthService {
-    return &AuthService{users: users, jwt: jwt}
+// NewAuthService 未显式传入 providers 时仅使用本地 bcrypt 校验。
+func NewAuthService(users repository.UserRepository, jwt *JWTManager, providers ...CredentialProvider) *AuthService {
+    if len(providers) == 0 { providers = []CredentialProvider{NewLocalProvider(users)} }
+    return &AuthService{users: users, jwt: jwt, providers: providers}
 }
 
-func (s *AuthService) Register(ctx context.Context, username, password string, roles []string) (domain.User, TokenPair, error) {
-    username = strings.TrimSpace(username)
-    if len(username) < 3 { return domain.User{}, TokenPair{}, errors.New("username too short") }
-    if len(password) < 6 { return domain.User{}, TokenPair{}, ErrWeakPassword }
+// LoginResult 登录/注册结果：要么直接带令牌，要么带 MFA 待验证挑战（二者互斥）。
+type LoginResult struct {
+    User   domain.User   `json:"user"`
+    Tokens *TokenPair    `json:"tokens,omitempty"`
+    MFA    *MFAChallenge `json:"mfa,omitempty"`
+}
 
-    // hash
-    hashBytes, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
-    if err != nil { return domain.User{}, TokenPair{}, err }
+func (s *AuthService) Register(ctx context.Context, username, password string, roles []string) (LoginResult, error) {
+    username = strings.TrimSpace(username)
+    if len(username) < 3 { return LoginResult{}, errors.New("username too short") }
+    hash, err := HashPassword(password)
+    if err != nil { return LoginResult{}, err }
 
-    u := domain.User{Username: username, Roles: roles, PasswordHash: string(hashBytes), CreatedAt: time.Now().UTC()}
+    u := domain.User{ID: uuid.New().String(), Username: username, Roles: roles, PasswordHash: hash, CreatedAt: time.Now().UTC()}
     if err := s.users.Create(ctx, u); err != nil {
-        if errors.Is(err, repository.ErrUserDuplicate) { return domain.User{}, TokenPair{}, ErrUsernameTaken }
-        return domain.User{}, TokenPair{}, err
+        if errors.Is(err, repository.ErrUserDuplicate) { return LoginResult{}, ErrUsernameTaken }
+        return LoginResult{}, err
     }
-    // issue tokens
-    access, expA, err := s.jwt.GenerateAccess(u.ID, u.Roles)
-    if err != nil { return domain.User{}, TokenPair{}, err }
-    refresh, expR, err := s.jwt.GenerateRefresh(u.ID)
-    if err != nil { return domain.User{}, TokenPair{}, err }
-    pair := TokenPair{AccessToken: access, RefreshToken: refresh, ExpiresIn: int64(expA.Sub(time.Now().UTC()).Seconds())}
-    _ = expR // 可在后续返回 refresh 过期信息
-    return u, pair, nil
+    return s.completeLogin(ctx, u)
 }
 
+// Login 不区分来源 IP 的登录，仅按用户名计数，见 LoginFrom。
+func (s *AuthService) Login(ctx context.Context, username, password string) (LoginResult, error) {
+    return s.LoginFrom(ctx, username, password, "")
+}
+
+// LoginFrom 依次委托 CredentialProvider 链（本地 bcrypt -> LDAP ...）；
+// 启用失败限制时先检查用户名 / 来源 IP 是否被锁定或处于延迟期，并在校验凭据前预占本次失败计数。
+// 通过后若需二次验证则返回 MFA 挑战，否则直接签发令牌。
+func (s *AuthService) LoginFrom(ctx context.Context, username, password, clientIP string) (LoginResult, error) {
+    var held []domain.LoginAttempt
+    if s.guard != nil {
+        var err error
+        if held, err = s.guard.reserve(ctx, attemptKeys(username, clientIP)); err != nil { return LoginResult{}, err }
+    }
+    u, err := authenticateChain(ctx, s.providers, username, password)
+    if err != nil {
+        // 仅凭据被明确拒绝时保留计数；目录不可达等故障撤回预占，不应让用户被锁
+        if s.guard != nil {
+            settle := s.guard.release
+            if errors.Is(err, ErrInvalidLogin) { settle = s.guard.fail }
+            if gerr := settle(ctx, held); gerr != nil { return LoginResult{}, gerr }
+        }
+        if errors.Is(err, ErrLoginUnavailable) { logging.FromContext(ctx).Warn("credential providers unavailable", zap.Error(err)) }
+        return LoginResult{}, err
+    }
+    if s.guard != nil {
+        if err := s.guard.succeed(ctx, held); err != nil { return LoginResult{}, err }
+    }
+    return s.completeLogin(ctx, u)
+}
+
+// Authenticate 仅在无需二次验证时签发令牌；需要 MFA 时返回 ErrMFARequired。
 func (s *AuthService) Authenticate(ctx context.Context, username, password string) (domain.User, TokenPair, error) {
-    u, err := s.users.GetByUsername(ctx, username)
-    if err != nil { return domain.User{}, TokenPair{}, ErrInvalidLogin }
-    if bcrypt.CompareHashAndPassword([]byte(u.PasswordHash), []byte(password)) != nil { return domain.User{}, TokenPair{}, ErrInvalidLogin }
-    access, expA, err := s.jwt.GenerateAccess(u.ID, u.Roles)
+    res, err := s.Login(ctx, username, password)
     if err != nil { return domain.User{}, TokenPair{}, err }
+    if res.Tokens == nil { return res.User, TokenPair{}, ErrMFARequired }
+    return res.User, *res.Tokens, nil
+}
+
+func (s *AuthService) completeLogin(ctx context.Context, u domain.User) (LoginResult, error) {
+    challenge, err := s.mfaChallengeFor(ctx, u)
+    if err != nil { return LoginResult{}, err }
+    if challenge != nil { return LoginResult{User: u, MFA: challenge}, nil }
+    pair, err := s.issueTokens(u)
+    if err != nil { return LoginResult{}, err }
+    return LoginResult{User: u, Tokens: &pair}, nil
+}
+
+func (s *AuthService) issueTokens(u domain.User) (TokenPair, error) {
+    access, expA, err := s.jwt.GenerateAccess(u.ID, u.Roles)
+    if err != nil { return TokenPair{}, err }
     refresh, _, err := s.jwt.GenerateRefresh(u.ID)
-    if err != nil { return domain.User{}, TokenPair{}, err }
-    pair := TokenPair{AccessToken: access, RefreshToken: refresh, ExpiresIn: int64(expA.Sub(time.Now().UTC()).Seconds())}
-    return u, pair, nil
+    if err != nil { return TokenPair{}, err }
+    return TokenPair{AccessToken: access, RefreshToken: refresh, ExpiresIn: int64(expA.Sub(time.Now().UTC()).Seconds())}, nil
 }
 
 func (s *AuthService) Refresh(ctx context.Context, refreshToken string) (TokenPair, error) {
@@ -67,9 +117,7 @@ func (s *AuthService) Refresh(ctx context.Context, refreshToken string) (TokenPa
     if err != nil { return TokenPair{}, ErrInvalidToken }
     u, err := s.users.GetByID(ctx, claims.UserID)
     if err != nil { return TokenPair{}, ErrInvalidToken }
-    access, expA, err := s.jwt.GenerateAccess(u.ID, u.Roles)
-    if err != nil { return TokenPair{}, err }
-    refresh, _, err := s.jwt.GenerateRefresh(u.ID)
-    if err != nil { return TokenPair{}, err }
-    return TokenPair{AccessToken: access, RefreshToken: refresh, ExpiresIn: int64(expA.Sub(time.Now().UTC()).Seconds())}, nil
+    // 修改 / 重置密码后，之前签发的 refresh token 作废；iat 只精确到秒，与改密同一秒签发的也一并作废
+    if u.PasswordChangedAt != nil && claims.IssuedAt != nil && !claims.IssuedAt.Time.After(u.PasswordChangedAt.Truncate(time.Second)) { return TokenPair{}, ErrInvalidToken }
+    return s.issueTokens(u)
 }
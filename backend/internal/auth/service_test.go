@@ -0,0 +1,28 @@
+package auth
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/YangYuS8/codyssey/backend/internal/repository"
+)
+
+// iat 只精确到秒：与改密同一秒签发的 refresh token 也必须作废，改密之后的秒签发的仍可用。
+func TestRefresh_RejectsTokenIssuedInPasswordChangeSecond(t *testing.T) {
+    ctx := context.Background()
+    users := repository.NewMemoryUserRepository()
+    svc := NewAuthService(users, NewJWTManager("test-secret", time.Minute, time.Hour))
+    res, err := svc.Register(ctx, "alice", "secret123", []string{RoleStudent})
+    if err != nil { t.Fatal(err) }
+    refresh := res.Tokens.RefreshToken
+    claims, err := svc.jwt.ParseRefresh(refresh)
+    if err != nil { t.Fatal(err) }
+    iat := claims.IssuedAt.Time
+
+    if err := users.UpdatePassword(ctx, res.User.ID, "hash", iat.Add(500*time.Millisecond)); err != nil { t.Fatal(err) }
+    if _, err := svc.Refresh(ctx, refresh); err != ErrInvalidToken { t.Fatalf("token issued in the change second: expected ErrInvalidToken got %v", err) }
+
+    if err := users.UpdatePassword(ctx, res.User.ID, "hash", iat.Add(-time.Second)); err != nil { t.Fatal(err) }
+    if _, err := svc.Refresh(ctx, refresh); err != nil { t.Fatalf("token issued after the change: %v", err) }
+}
@@ -0,0 +1,114 @@
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/subtle"
+	"encoding/base32"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// TOTPOptions RFC 6238 参数。默认值与主流验证器（Google Authenticator 等）兼容。
+type TOTPOptions struct {
+    Digits    int
+    Period    time.Duration
+    Algorithm string // SHA1 | SHA256 | SHA512
+    Skew      int    // 允许前后偏移的步数（时钟漂移）
+}
+
+var DefaultTOTPOptions = TOTPOptions{Digits: 6, Period: 30 * time.Second, Algorithm: "SHA1", Skew: 1}
+
+var b32 = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+var ErrInvalidTOTPSecret = errors.New("invalid totp secret")
+
+// GenerateTOTPSecret 生成 160 bit 随机密钥（base32 无填充，RFC 4226 推荐长度）。
+func GenerateTOTPSecret() (string, error) {
+    buf := make([]byte, 20)
+    if _, err := rand.Read(buf); err != nil { return "", err }
+    return b32.EncodeToString(buf), nil
+}
+
+// TOTPURI 生成 otpauth:// URI，供客户端扫码（Key Uri Format）。
+func TOTPURI(issuer, account, secret string, o TOTPOptions) string {
+    o = o.withDefaults()
+    label := url.PathEscape(issuer + ":" + account)
+    q := url.Values{}
+    q.Set("secret", secret)
+    q.Set("issuer", issuer)
+    q.Set("algorithm", o.Algorithm)
+    q.Set("digits", fmt.Sprint(o.Digits))
+    q.Set("period", fmt.Sprint(int(o.Period/time.Second)))
+    return "otpauth://totp/" + label + "?" + q.Encode()
+}
+
+// TOTPCode 计算 secret 在时刻 t 的验证码。
+func TOTPCode(secret string, t time.Time, o TOTPOptions) (string, error) {
+    key, err := decodeTOTPSecret(secret)
+    if err != nil { return "", err }
+    o = o.withDefaults()
+    return hotp(key, totpStep(t, o.Period), o), nil
+}
+
+// VerifyTOTP 在 ±Skew 窗口内校验验证码，返回命中的时间步（调用方据此防重放）。
+func VerifyTOTP(secret, code string, t time.Time, o TOTPOptions) (int64, bool) {
+    key, err := decodeTOTPSecret(secret)
+    if err != nil { return 0, false }
+    o = o.withDefaults()
+    code = strings.TrimSpace(code)
+    if len(code) != o.Digits { return 0, false }
+    step := totpStep(t, o.Period)
+    for d := -o.Skew; d <= o.Skew; d++ {
+        s := step + int64(d)
+        if s < 0 { continue }
+        if subtle.ConstantTimeCompare([]byte(hotp(key, s, o)), []byte(code)) == 1 { return s, true }
+    }
+    return 0, false
+}
+
+func (o TOTPOptions) withDefaults() TOTPOptions {
+    if o.Digits <= 0 || o.Digits > 9 { o.Digits = DefaultTOTPOptions.Digits }
+    if o.Period <= 0 { o.Period = DefaultTOTPOptions.Period }
+    if o.Algorithm == "" { o.Algorithm = DefaultTOTPOptions.Algorithm }
+    if o.Skew < 0 { o.Skew = 0 }
+    return o
+}
+
+func totpStep(t time.Time, period time.Duration) int64 { return t.Unix() / int64(period/time.Second) }
+
+// hotp RFC 4226 动态截断。
+func hotp(key []byte, counter int64, o TOTPOptions) string {
+    var newHash func() hash.Hash
+    switch strings.ToUpper(o.Algorithm) {
+    case "SHA256": newHash = sha256.New
+    case "SHA512": newHash = sha512.New
+    default: newHash = sha1.New
+    }
+    var msg [8]byte
+    binary.BigEndian.PutUint64(msg[:], uint64(counter))
+    mac := hmac.New(newHash, key)
+    mac.Write(msg[:])
+    sum := mac.Sum(nil)
+    off := sum[len(sum)-1] & 0x0f
+    bin := binary.BigEndian.Uint32(sum[off:off+4]) & 0x7fffffff
+    mod := uint32(1)
+    for i := 0; i < o.Digits; i++ { mod *= 10 }
+    return fmt.Sprintf("%0*d", o.Digits, bin%mod)
+}
+
+func decodeTOTPSecret(secret string) ([]byte, error) {
+    s := strings.ToUpper(strings.ReplaceAll(strings.TrimSpace(secret), " ", ""))
+    s = strings.TrimRight(s, "=")
+    key, err := b32.DecodeString(s)
+    if err != nil || len(key) == 0 { return nil, ErrInvalidTOTPSecret }
+    return key, nil
+}
@@ -0,0 +1,46 @@
+package auth
+
+import (
+	"testing"
+	"time"
+)
+
+// RFC 6238 Appendix B 测试向量（8 位）。
+func TestTOTP_RFC6238Vectors(t *testing.T) {
+    keys := map[string][]byte{
+        "SHA1":   []byte("12345678901234567890"),
+        "SHA256": []byte("12345678901234567890123456789012"),
+        "SHA512": []byte("1234567890123456789012345678901234567890123456789012345678901234"),
+    }
+    cases := []struct {
+        unix int64
+        want map[string]string
+    }{
+        {59, map[string]string{"SHA1": "94287082", "SHA256": "46119246", "SHA512": "90693936"}},
+        {1111111109, map[string]string{"SHA1": "07081804", "SHA256": "68084774", "SHA512": "25091201"}},
+        {1111111111, map[string]string{"SHA1": "14050471", "SHA256": "67062674", "SHA512": "99943326"}},
+        {1234567890, map[string]string{"SHA1": "89005924", "SHA256": "91819424", "SHA512": "93441116"}},
+        {2000000000, map[string]string{"SHA1": "69279037", "SHA256": "90698825", "SHA512": "38618901"}},
+        {20000000000, map[string]string{"SHA1": "65353130", "SHA256": "77737706", "SHA512": "47863826"}},
+    }
+    for _, tc := range cases {
+        for alg, want := range tc.want {
+            o := TOTPOptions{Digits: 8, Period: 30 * time.Second, Algorithm: alg}
+            secret := b32.EncodeToString(keys[alg])
+            got, err := TOTPCode(secret, time.Unix(tc.unix, 0), o)
+            if err != nil { t.Fatalf("%s@%d: %v", alg, tc.unix, err) }
+            if got != want { t.Fatalf("%s@%d: want %s got %s", alg, tc.unix, want, got) }
+        }
+    }
+}
+
+func TestVerifyTOTP_SkewWindow(t *testing.T) {
+    secret, err := GenerateTOTPSecret()
+    if err != nil { t.Fatal(err) }
+    now := time.Unix(1700000000, 0)
+    prev, _ := TOTPCode(secret, now.Add(-30*time.Second), DefaultTOTPOptions)
+    if _, ok := VerifyTOTP(secret, prev, now, DefaultTOTPOptions); !ok { t.Fatalf("previous step should be accepted within skew") }
+    old, _ := TOTPCode(secret, now.Add(-90*time.Second), DefaultTOTPOptions)
+    if _, ok := VerifyTOTP(secret, old, now, DefaultTOTPOptions); ok { t.Fatalf("code outside window must be rejected") }
+    if _, ok := VerifyTOTP(secret, "12345", now, DefaultTOTPOptions); ok { t.Fatalf("wrong length must be rejected") }
+}
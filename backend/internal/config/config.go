@@ -1,80 +1,247 @@
 package config
 
 import (
+	"errors"
 	"fmt"
-	"os"
+	"strings"
+	"time"
+
+	"github.com/YangYuS8/codyssey/backend/internal/ratelimit"
 )
 
 // Config holds basic runtime configuration.
+// 字段标签：yaml 为配置文件 / 命令行中的键名（嵌套以 "." 连接，如 db.host），env 为覆盖用环境变量，default 为默认值；
+// 加载顺序见 Load。
 type Config struct {
-	Port        string
-	Env         string
-	DB          DBConfig
-	Version     string
-	JWTSecret   string
-	AutoMigrate bool
-	LogLevel    string
-	MaxSubmissionCodeBytes int // 代码长度上限
-	MaxRequestBodyBytes    int // 全局请求体限制
+	Port        string `yaml:"port" env:"GO_BACKEND_PORT" default:"8080"`
+	Env         string `yaml:"env" env:"ENV" default:"development"`
+	DB          DBConfig `yaml:"db"`
+	Version     string `yaml:"-"` // 构建时注入，不可配置
+	JWTSecret   string `yaml:"jwt_secret" env:"JWT_SECRET" default:"dev-secret-change-me"`
+	AutoMigrate bool   `yaml:"auto_migrate" env:"AUTO_MIGRATE" default:"false"`
+	LogLevel    string `yaml:"log_level" env:"LOG_LEVEL" default:"info"`
+	MaxSubmissionCodeBytes int `yaml:"max_submission_code_bytes" env:"MAX_SUBMISSION_CODE_BYTES" default:"131072"` // 代码长度上限
+	MaxRequestBodyBytes    int `yaml:"max_request_body_bytes" env:"MAX_REQUEST_BODY_BYTES" default:"524288"`       // 全局请求体限制
+	LDAP        LDAPConfig `yaml:"ldap"`
+	MFARequiredRoles []string `yaml:"mfa_required_roles" env:"MFA_REQUIRED_ROLES,allowempty" default:"system_admin,teacher"` // 必须启用 TOTP 的角色；显式置空表示不强制
+	Lockout     LockoutConfig     `yaml:"lockout"`
+	Mail        MailConfig        `yaml:"mail"`
+	RateLimit   RateLimitConfig   `yaml:"rate_limit"`
+	Idempotency IdempotencyConfig `yaml:"idempotency"`
+	Tracing     TracingConfig     `yaml:"tracing"`
+	AccessLog   AccessLogConfig   `yaml:"access_log"`
+	Judge       JudgeConfig       `yaml:"judge"`
+	Health      HealthConfig      `yaml:"health"`
+	Settings    SettingsConfig    `yaml:"settings"`
+	FeatureFlags FeatureFlagConfig `yaml:"feature_flags"`
+	AI          AIConfig          `yaml:"ai"`
 }
 
-type DBConfig struct {
-	Host     string
-	Port     string
-	User     string
-	Password string
-	Name     string
-	SSLMode  string
-}
-
-// Load returns configuration read from environment variables.
-func Load() Config {
-	port := os.Getenv("GO_BACKEND_PORT")
-	if port == "" { port = "8080" }
-	env := os.Getenv("ENV")
-	if env == "" { env = "development" }
-	logLevel := os.Getenv("LOG_LEVEL")
-	if logLevel == "" { logLevel = "info" }
-	db := DBConfig{
-		Host:     firstNonEmpty(os.Getenv("POSTGRES_HOST"), "localhost"),
-		Port:     firstNonEmpty(os.Getenv("POSTGRES_PORT"), "5432"),
-		User:     firstNonEmpty(os.Getenv("POSTGRES_USER"), "codyssey"),
-		Password: firstNonEmpty(os.Getenv("POSTGRES_PASSWORD"), "codyssey"),
-		Name:     firstNonEmpty(os.Getenv("POSTGRES_DB"), "codyssey"),
-		SSLMode:  firstNonEmpty(os.Getenv("POSTGRES_SSLMODE"), "disable"),
+// AIConfig Python AI 服务客户端；URL 为空表示不启用 AI 相关接口。
+type AIConfig struct {
+	URL              string        `yaml:"url" env:"AI_SERVICE_URL"`                                  // 如 http://py-backend:8000
+	Timeout          time.Duration `yaml:"timeout" env:"AI_TIMEOUT" default:"30s"`                   // 单次请求超时
+	MaxRetries       int           `yaml:"max_retries" env:"AI_MAX_RETRIES" default:"2"`             // 网络错误 / 5xx / 429 的重试次数
+	RetryBackoff     time.Duration `yaml:"retry_backoff" env:"AI_RETRY_BACKOFF" default:"200ms"`     // 首次重试等待，逐次翻倍
+	BreakerThreshold int           `yaml:"breaker_threshold" env:"AI_BREAKER_THRESHOLD" default:"5"` // 连续失败次数达到后熔断；0 表示不熔断
+	BreakerCooldown  time.Duration `yaml:"breaker_cooldown" env:"AI_BREAKER_COOLDOWN" default:"30s"`
+	DetectWorkers    int           `yaml:"detect_workers" env:"AI_DETECT_WORKERS" default:"2"`        // 提交 AI 代码检测并发数
+	DetectQueueSize  int           `yaml:"detect_queue_size" env:"AI_DETECT_QUEUE_SIZE" default:"256"` // 检测队列容量；队列满时该次检测记为 failed
+}
+
+func (a AIConfig) Enabled() bool { return a.URL != "" }
+
+// FeatureFlagConfig 功能开关求值缓存；其他实例的修改最迟 CacheTTL 后生效。
+type FeatureFlagConfig struct {
+	CacheTTL time.Duration `yaml:"cache_ttl" env:"FEATURE_FLAG_CACHE_TTL" default:"5s"`
+}
+
+// SettingsConfig 运行时参数（见 internal/settings）：启动配置作为默认值，覆盖值存于数据库。
+type SettingsConfig struct {
+	PollInterval time.Duration `yaml:"poll_interval" env:"SETTINGS_POLL_INTERVAL" default:"10s"` // 检查其他实例写入的变更；off 表示只在本实例修改时生效
+	File         string        `yaml:"file" env:"SETTINGS_FILE"`                                 // 可选：监视的 YAML 文件（键: 值），修改后写入数据库
+}
+
+// HealthConfig 就绪检查与停机排空。
+type HealthConfig struct {
+	CheckTimeout   time.Duration     `yaml:"check_timeout" env:"HEALTH_CHECK_TIMEOUT" default:"2s"`            // 单项依赖检查超时
+	DrainDelay     time.Duration     `yaml:"drain_delay" env:"SHUTDOWN_DRAIN_DELAY" default:"5s"`              // 收到停机信号后 /readyz 先返回 503 并等待该时长，再关闭 HTTP Server；development 未显式配置时为 0
+	HTTPChecks     map[string]string `yaml:"http_checks" env:"HEALTH_HTTP_CHECKS"`                             // 名称 -> 外部依赖健康端点（对象存储、AI 服务等），环境变量格式 "name=url,name=url"
+	OptionalChecks []string          `yaml:"optional_checks" env:"HEALTH_OPTIONAL_CHECKS"`                     // 失败时仅报告 degraded、不影响就绪的检查名
+}
+
+// JudgeConfig 判题队列指标；WorkerCapacity 为外部判题 worker 的并发槽位总数，0 表示不导出利用率。
+type JudgeConfig struct {
+	WorkerCapacity  int           `yaml:"worker_capacity" env:"JUDGE_WORKER_CAPACITY" default:"0"`
+	QueueMetricsTTL time.Duration `yaml:"queue_metrics_ttl" env:"JUDGE_QUEUE_METRICS_TTL" default:"15s"`
+}
+
+// AccessLogConfig 访问日志；成功请求按 SampleRatio 采样，错误与慢请求总是记录。
+type AccessLogConfig struct {
+	SampleRatio   float64       `yaml:"sample_ratio" env:"ACCESS_LOG_SAMPLE_RATIO" default:"1.0"`
+	SlowThreshold time.Duration `yaml:"slow_threshold" env:"ACCESS_LOG_SLOW_THRESHOLD" default:"1s"`
+	Details       bool          `yaml:"details" env:"ACCESS_LOG_DETAILS" default:"false"` // 记录脱敏后的请求头与 JSON 请求体（排错用）
+}
+
+// TracingConfig OpenTelemetry 链路追踪；Exporter 为 none 时不导出（仍传播 traceparent）。
+type TracingConfig struct {
+	Exporter    string  `yaml:"exporter" env:"TRACING_EXPORTER" default:"none"`         // none | stdout | otlp
+	Endpoint    string  `yaml:"endpoint" env:"OTEL_EXPORTER_OTLP_ENDPOINT"`             // OTLP/HTTP 地址，如 localhost:4318 或 https://collector:4318
+	Insecure    bool    `yaml:"insecure" env:"OTEL_EXPORTER_OTLP_INSECURE" default:"false"`
+	SampleRatio float64 `yaml:"sample_ratio" env:"TRACING_SAMPLE_RATIO" default:"1.0"` // 根 span 采样比例 [0,1]；有上游 traceparent 时跟随上游决定
+	ServiceName string  `yaml:"service_name" env:"OTEL_SERVICE_NAME" default:"codyssey-backend"`
+}
+
+// IdempotencyConfig Idempotency-Key 存储与保留时长。
+type IdempotencyConfig struct {
+	Store string        `yaml:"store" env:"IDEMPOTENCY_STORE" default:"memory"` // memory | postgres
+	TTL   time.Duration `yaml:"ttl" env:"IDEMPOTENCY_TTL" default:"24h"`
+}
+
+// RateLimitConfig 限流；策略格式 "N/duration"（如 "10/1m"），"off" 禁用该分组。
+type RateLimitConfig struct {
+	Store        string `yaml:"store" env:"RATE_LIMIT_STORE" default:"memory"` // memory | postgres
+	Login        string `yaml:"login" env:"RATE_LIMIT_LOGIN" default:"10/1m"`
+	Submission   string `yaml:"submission" env:"RATE_LIMIT_SUBMISSION" default:"30/1m"`
+	JudgeEnqueue string `yaml:"judge_enqueue" env:"RATE_LIMIT_JUDGE_ENQUEUE" default:"20/1m"`
+}
+
+// Policies 分组 -> 策略，见 ratelimit.Group*。
+func (r RateLimitConfig) Policies() map[string]string {
+	return map[string]string{
+		ratelimit.GroupLogin:        r.Login,
+		ratelimit.GroupSubmission:   r.Submission,
+		ratelimit.GroupJudgeEnqueue: r.JudgeEnqueue,
 	}
-	jwtSecret := os.Getenv("JWT_SECRET")
-	if jwtSecret == "" { jwtSecret = "dev-secret-change-me" }
-	autoMig := os.Getenv("AUTO_MIGRATE") == "true"
-	maxCode := 128 * 1024 // 128KB 默认
-	if v := os.Getenv("MAX_SUBMISSION_CODE_BYTES"); v != "" { if n, err := atoiSafe(v); err == nil && n > 0 { maxCode = n } }
-	maxBody := 512 * 1024 // 512KB 默认
-	if v := os.Getenv("MAX_REQUEST_BODY_BYTES"); v != "" { if n, err := atoiSafe(v); err == nil && n > 0 { maxBody = n } }
-	return Config{Port: port, Env: env, DB: db, JWTSecret: jwtSecret, AutoMigrate: autoMig, LogLevel: logLevel, MaxSubmissionCodeBytes: maxCode, MaxRequestBodyBytes: maxBody}
 }
 
-// Validate performs basic sanity checks; panic early if critical settings missing in non-dev.
+// MailConfig 发信配置；Sender 为 log | file | smtp，空表示不启用邮件相关接口。
+type MailConfig struct {
+	Sender        string `yaml:"sender" env:"MAIL_SENDER"`
+	FileDir       string `yaml:"file_dir" env:"MAIL_FILE_DIR" default:"./tmp/mail"`
+	SMTPAddr      string `yaml:"smtp_addr" env:"SMTP_ADDR"`
+	SMTPUsername  string `yaml:"smtp_username" env:"SMTP_USERNAME"`
+	SMTPPassword  string `yaml:"smtp_password" env:"SMTP_PASSWORD"`
+	From          string `yaml:"from" env:"MAIL_FROM"`
+	PublicBaseURL string `yaml:"public_base_url" env:"PUBLIC_BASE_URL" default:"http://localhost:3000"`
+}
+
+func (m MailConfig) Enabled() bool { return m.Sender != "" }
+
+// LockoutConfig 登录失败限制；MaxFailures 为 0 表示不按用户名锁定。
+type LockoutConfig struct {
+	MaxFailures   int           `yaml:"max_failures" env:"LOGIN_MAX_FAILURES" default:"5"`
+	IPMaxFailures int           `yaml:"ip_max_failures" env:"LOGIN_IP_MAX_FAILURES" default:"50"`
+	Window        time.Duration `yaml:"window" env:"LOGIN_FAILURE_WINDOW" default:"15m"`
+	Duration      time.Duration `yaml:"duration" env:"LOGIN_LOCKOUT_DURATION" default:"15m"`
+}
+
+// LDAPConfig 可选的 LDAP 认证来源；URL 为空表示禁用。
+type LDAPConfig struct {
+	URL          string   `yaml:"url" env:"LDAP_URL"`
+	StartTLS     bool     `yaml:"start_tls" env:"LDAP_START_TLS" default:"false"`
+	BindDN       string   `yaml:"bind_dn" env:"LDAP_BIND_DN"`
+	BindPassword string   `yaml:"bind_password" env:"LDAP_BIND_PASSWORD"`
+	BaseDN       string   `yaml:"base_dn" env:"LDAP_BASE_DN"`
+	UserFilter   string   `yaml:"user_filter" env:"LDAP_USER_FILTER" default:"(uid=%s)"`
+	GroupAttr    string   `yaml:"group_attr" env:"LDAP_GROUP_ATTR" default:"memberOf"`
+	GroupRoles   string   `yaml:"group_roles" env:"LDAP_GROUP_ROLES"` // "组DN:角色;组DN:角色"
+	DefaultRoles []string `yaml:"default_roles" env:"LDAP_DEFAULT_ROLES" default:"student"`
+}
+
+func (l LDAPConfig) Enabled() bool { return l.URL != "" }
+
+type DBConfig struct {
+	Host     string `yaml:"host" env:"POSTGRES_HOST" default:"localhost"`
+	Port     string `yaml:"port" env:"POSTGRES_PORT" default:"5432"`
+	User     string `yaml:"user" env:"POSTGRES_USER" default:"codyssey"`
+	Password string `yaml:"password" env:"POSTGRES_PASSWORD" default:"codyssey"`
+	Name     string `yaml:"name" env:"POSTGRES_DB" default:"codyssey"`
+	SSLMode  string `yaml:"sslmode" env:"POSTGRES_SSLMODE" default:"disable"`
+	SlowQueryThreshold time.Duration `yaml:"slow_query_threshold" env:"DB_SLOW_QUERY_THRESHOLD" default:"200ms"` // 慢查询日志阈值；0 / off 表示关闭
+}
+
+// normalize 统一大小写等不影响语义的差异（在校验之前执行）。
+func (c *Config) normalize() {
+	c.LogLevel = strings.ToLower(c.LogLevel)
+	c.Mail.Sender = strings.ToLower(c.Mail.Sender)
+	c.RateLimit.Store = strings.ToLower(c.RateLimit.Store)
+	c.Idempotency.Store = strings.ToLower(c.Idempotency.Store)
+	c.Tracing.Exporter = strings.ToLower(c.Tracing.Exporter)
+}
+
+// Validate 校验全部配置并汇总问题（errors.Join，每条一行），便于一次修正。
 func (c Config) Validate() error {
+    var errs []error
+    add := func(format string, args ...any) { errs = append(errs, fmt.Errorf(format, args...)) }
     if c.Env != "development" && c.JWTSecret == "dev-secret-change-me" {
-        return fmt.Errorf("JWT_SECRET must be set in %s env", c.Env)
+        add("JWT_SECRET must be set in %s env", c.Env)
+    }
+    if c.LDAP.Enabled() {
+        if c.LDAP.BaseDN == "" { add("LDAP_BASE_DN required when LDAP_URL is set") }
+        if strings.Count(c.LDAP.UserFilter, "%s") != 1 { add("LDAP_USER_FILTER must contain exactly one %%s") }
     }
-    return nil
+    if c.MaxSubmissionCodeBytes <= 0 { add("MAX_SUBMISSION_CODE_BYTES must be positive") }
+    if c.MaxRequestBodyBytes <= 0 { add("MAX_REQUEST_BODY_BYTES must be positive") }
+    if c.RateLimit.Store != "memory" && c.RateLimit.Store != "postgres" {
+        add("unknown RATE_LIMIT_STORE %q (memory|postgres)", c.RateLimit.Store)
+    }
+    if c.Idempotency.Store != "memory" && c.Idempotency.Store != "postgres" {
+        add("unknown IDEMPOTENCY_STORE %q (memory|postgres)", c.Idempotency.Store)
+    }
+    if c.Idempotency.TTL <= 0 { add("IDEMPOTENCY_TTL must be positive") }
+    for _, group := range []string{ratelimit.GroupLogin, ratelimit.GroupSubmission, ratelimit.GroupJudgeEnqueue} {
+        if _, err := ratelimit.ParsePolicy(group, c.RateLimit.Policies()[group]); err != nil { errs = append(errs, err) }
+    }
+    switch c.Tracing.Exporter {
+    case "none", "stdout", "otlp":
+    default:
+        add("unknown TRACING_EXPORTER %q (none|stdout|otlp)", c.Tracing.Exporter)
+    }
+    if c.Tracing.SampleRatio < 0 || c.Tracing.SampleRatio > 1 { add("TRACING_SAMPLE_RATIO must be within [0,1]") }
+    if c.AccessLog.SampleRatio < 0 || c.AccessLog.SampleRatio > 1 { add("ACCESS_LOG_SAMPLE_RATIO must be within [0,1]") }
+    durations := []struct {
+        name string
+        d    time.Duration
+    }{
+        {"DB_SLOW_QUERY_THRESHOLD", c.DB.SlowQueryThreshold}, {"SHUTDOWN_DRAIN_DELAY", c.Health.DrainDelay},
+        {"ACCESS_LOG_SLOW_THRESHOLD", c.AccessLog.SlowThreshold}, {"JUDGE_QUEUE_METRICS_TTL", c.Judge.QueueMetricsTTL},
+        {"HEALTH_CHECK_TIMEOUT", c.Health.CheckTimeout}, {"LOGIN_FAILURE_WINDOW", c.Lockout.Window}, {"LOGIN_LOCKOUT_DURATION", c.Lockout.Duration},
+        {"SETTINGS_POLL_INTERVAL", c.Settings.PollInterval}, {"FEATURE_FLAG_CACHE_TTL", c.FeatureFlags.CacheTTL},
+        {"AI_RETRY_BACKOFF", c.AI.RetryBackoff}, {"AI_BREAKER_COOLDOWN", c.AI.BreakerCooldown},
+    }
+    for _, v := range durations {
+        if v.d < 0 { add("%s must not be negative", v.name) }
+    }
+    for name, u := range c.Health.HTTPChecks {
+        if name == "" || (!strings.HasPrefix(u, "http://") && !strings.HasPrefix(u, "https://")) {
+            add("invalid HEALTH_HTTP_CHECKS entry %q (expected name=http(s)://...)", name+"="+u)
+        }
+    }
+    if c.AI.Enabled() {
+        if !strings.HasPrefix(c.AI.URL, "http://") && !strings.HasPrefix(c.AI.URL, "https://") { add("AI_SERVICE_URL must start with http:// or https://") }
+        if c.AI.Timeout <= 0 { add("AI_TIMEOUT must be positive") }
+        if c.AI.MaxRetries < 0 || c.AI.BreakerThreshold < 0 { add("AI_MAX_RETRIES and AI_BREAKER_THRESHOLD must not be negative") }
+        if c.AI.DetectWorkers <= 0 || c.AI.DetectQueueSize <= 0 { add("AI_DETECT_WORKERS and AI_DETECT_QUEUE_SIZE must be positive") }
+    }
+    switch c.Mail.Sender {
+    case "", "log", "file":
+    case "smtp":
+        if c.Mail.SMTPAddr == "" || c.Mail.From == "" { add("SMTP_ADDR and MAIL_FROM required when MAIL_SENDER=smtp") }
+    default:
+        add("unknown MAIL_SENDER %q (log|file|smtp)", c.Mail.Sender)
+    }
+    return errors.Join(errs...)
 }
 
 func (d DBConfig) ConnString() string {
 	return fmt.Sprintf("postgres://%s:%s@%s:%s/%s?sslmode=%s", d.User, d.Password, d.Host, d.Port, d.Name, d.SSLMode)
 }
 
-func firstNonEmpty(values ...string) string {
-	for _, v := range values { if v != "" { return v } }
-	return ""
-}
-
-func atoiSafe(s string) (int, error) {
-	var n int
-	for _, ch := range s {
-		if ch < '0' || ch > '9' { return 0, fmt.Errorf("invalid int") }
-		n = n*10 + int(ch-'0')
+func splitList(s string) []string {
+	out := []string{}
+	for _, part := range strings.Split(s, ",") {
+		if v := strings.TrimSpace(part); v != "" { out = append(out, v) }
 	}
-	return n, nil
+	return out
 }
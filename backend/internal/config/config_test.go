@@ -0,0 +1,107 @@
+package config
+
+import (
+	"errors"
+	"flag"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func envOf(kv map[string]string) EnvLookup {
+    return func(k string) (string, bool) { v, ok := kv[k]; return v, ok }
+}
+
+func writeFile(t *testing.T, name, content string) string {
+    p := filepath.Join(t.TempDir(), name)
+    require.NoError(t, os.WriteFile(p, []byte(content), 0o600))
+    return p
+}
+
+func TestLoadDefaults(t *testing.T) {
+    cfg, err := load(nil, envOf(nil), io.Discard)
+    require.NoError(t, err)
+    require.Equal(t, "8080", cfg.Port)
+    require.Equal(t, "development", cfg.Env)
+    require.Equal(t, 128*1024, cfg.MaxSubmissionCodeBytes)
+    require.Equal(t, 200*time.Millisecond, cfg.DB.SlowQueryThreshold)
+    require.Equal(t, []string{"system_admin", "teacher"}, cfg.MFARequiredRoles)
+    require.Equal(t, "10/1m", cfg.RateLimit.Policies()["login"])
+    require.Zero(t, cfg.Health.DrainDelay, "development defaults to no drain delay")
+}
+
+func TestLoadPrecedence(t *testing.T) {
+    path := writeFile(t, "codyssey.yaml", `
+port: "7070"
+env: production
+jwt_secret: from-file
+db:
+  host: file-host
+  slow_query_threshold: off
+tracing:
+  sample_ratio: 0.25
+health:
+  http_checks:
+    ai: http://ai:8000/healthz
+  optional_checks: [ai]
+`)
+    env := envOf(map[string]string{"CONFIG_FILE": path, "POSTGRES_HOST": "env-host", "GO_BACKEND_PORT": "", "MFA_REQUIRED_ROLES": ""})
+    cfg, err := load([]string{"-db.host=flag-host", "-max_request_body_bytes", "1024"}, env, io.Discard)
+    require.NoError(t, err)
+    require.Equal(t, "7070", cfg.Port, "empty env values are ignored")
+    require.Equal(t, "flag-host", cfg.DB.Host, "flag > env > file")
+    require.Zero(t, cfg.DB.SlowQueryThreshold)
+    require.Equal(t, 0.25, cfg.Tracing.SampleRatio)
+    require.Equal(t, 1024, cfg.MaxRequestBodyBytes)
+    require.Equal(t, map[string]string{"ai": "http://ai:8000/healthz"}, cfg.Health.HTTPChecks)
+    require.Equal(t, []string{"ai"}, cfg.Health.OptionalChecks)
+    require.Empty(t, cfg.MFARequiredRoles, "allowempty env clears the list")
+    require.Equal(t, 5*time.Second, cfg.Health.DrainDelay, "non-development keeps the drain default")
+}
+
+func TestLoadTOML(t *testing.T) {
+    path := writeFile(t, "codyssey.toml", `
+env = "test"
+jwt_secret = "s"
+[rate_limit]
+store = "POSTGRES"
+login = "5/1m"
+[health]
+drain_delay = "1s"
+`)
+    cfg, err := load([]string{"-config", path}, envOf(map[string]string{"HEALTH_HTTP_CHECKS": "objectstore=http://minio:9000/minio/health/live"}), io.Discard)
+    require.NoError(t, err)
+    require.Equal(t, "postgres", cfg.RateLimit.Store)
+    require.Equal(t, "5/1m", cfg.RateLimit.Login)
+    require.Equal(t, time.Second, cfg.Health.DrainDelay)
+    require.Equal(t, "http://minio:9000/minio/health/live", cfg.Health.HTTPChecks["objectstore"])
+}
+
+func TestLoadAggregatesErrors(t *testing.T) {
+    path := writeFile(t, "bad.yaml", "prot: 1\nlockout:\n  window: soon\n")
+    env := envOf(map[string]string{"CONFIG_FILE": path, "MAX_SUBMISSION_CODE_BYTES": "lots", "ENV": "production", "TRACING_EXPORTER": "jaeger"})
+    _, err := load([]string{"-tracing.sample_ratio=2"}, env, io.Discard)
+    require.Error(t, err)
+    msg := err.Error()
+    for _, want := range []string{
+        "prot (" + path + "): unknown key",
+        "lockout.window (" + path + "): invalid duration",
+        "max_submission_code_bytes (env MAX_SUBMISSION_CODE_BYTES): invalid integer",
+        "JWT_SECRET must be set in production env",
+        `unknown TRACING_EXPORTER "jaeger"`,
+        "TRACING_SAMPLE_RATIO must be within [0,1]",
+    } {
+        require.Contains(t, msg, want)
+    }
+    require.GreaterOrEqual(t, strings.Count(msg, "\n"), 5, "one problem per line")
+}
+
+func TestLoadHelp(t *testing.T) {
+    _, err := load([]string{"-h"}, envOf(nil), io.Discard)
+    require.True(t, errors.Is(err, flag.ErrHelp))
+}
@@ -0,0 +1,234 @@
+package config
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pelletier/go-toml/v2"
+	"gopkg.in/yaml.v3"
+)
+
+// Load 按优先级合并配置：默认值 < 配置文件 < 环境变量 < 命令行参数，最后统一校验。
+// args 为命令行参数（不含程序名）：-config <文件> 指定配置文件（也可用 CONFIG_FILE），按扩展名识别 YAML（.yaml/.yml）或 TOML（.toml）；
+// 其余每个配置项对应一个同名参数，如 -port=9090、-db.host=pg、-health.drain_delay=10s。
+// 所有来源的解析错误与校验错误汇总后一次返回（errors.Join）；-h 时返回 flag.ErrHelp。
+func Load(args []string) (Config, error) { return load(args, os.LookupEnv, os.Stderr) }
+
+// EnvLookup 与 os.LookupEnv 同签名，便于测试注入。
+type EnvLookup func(key string) (string, bool)
+
+// field 一个可配置的叶子字段
+type field struct {
+	key        string // 点分键名，如 db.host
+	env        string
+	def        string
+	allowEmpty bool // 环境变量显式为空时也生效（默认忽略空值）
+	index      []int
+	typ        reflect.Type
+}
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+// fields 由 Config 的结构体标签生成字段表（只需计算一次）。
+var fields = collectFields(reflect.TypeOf(Config{}), "", nil)
+
+var fieldsByKey = func() map[string]field {
+	m := make(map[string]field, len(fields))
+	for _, f := range fields { m[f.key] = f }
+	return m
+}()
+
+func collectFields(t reflect.Type, prefix string, index []int) []field {
+	var out []field
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		name := sf.Tag.Get("yaml")
+		if name == "-" || !sf.IsExported() { continue }
+		if name == "" { name = strings.ToLower(sf.Name) }
+		key := prefix + name
+		idx := append(append([]int(nil), index...), i)
+		if sf.Type.Kind() == reflect.Struct && sf.Type != durationType {
+			out = append(out, collectFields(sf.Type, key+".", idx)...)
+			continue
+		}
+		env, opt, _ := strings.Cut(sf.Tag.Get("env"), ",")
+		out = append(out, field{key: key, env: env, def: sf.Tag.Get("default"), allowEmpty: opt == "allowempty", index: idx, typ: sf.Type})
+	}
+	return out
+}
+
+func load(args []string, lookup EnvLookup, usageOut io.Writer) (Config, error) {
+	var cfg Config
+	v := reflect.ValueOf(&cfg).Elem()
+	var errs []error
+	set := map[string]bool{} // 非默认值来源设置过的键
+	apply := func(f field, raw any, source string) {
+		if err := assign(v.FieldByIndex(f.index), raw); err != nil {
+			errs = append(errs, fmt.Errorf("%s (%s): %w", f.key, source, err))
+			return
+		}
+		set[f.key] = true
+	}
+
+	// 1. 默认值
+	for _, f := range fields {
+		if f.def == "" { continue }
+		if err := assign(v.FieldByIndex(f.index), f.def); err != nil { panic(fmt.Sprintf("config: bad default for %s: %v", f.key, err)) }
+	}
+
+	// 2. 命令行（先解析以取得 -config，最后再应用）
+	fs := flag.NewFlagSet("codyssey", flag.ContinueOnError)
+	fs.SetOutput(usageOut)
+	configFile := fs.String("config", "", "path to a YAML or TOML config file (env CONFIG_FILE)")
+	flagValues := map[string]*string{}
+	for _, f := range fields {
+		usage := "default " + strconv.Quote(f.def)
+		if f.env != "" { usage = "env " + f.env + ", " + usage }
+		flagValues[f.key] = fs.String(f.key, "", usage)
+	}
+	if err := fs.Parse(args); err != nil { return cfg, err }
+
+	// 3. 配置文件
+	path := *configFile
+	if path == "" { path, _ = lookup("CONFIG_FILE") }
+	if path != "" {
+		values, err := readFile(path)
+		if err != nil {
+			errs = append(errs, err)
+		} else {
+			keys := make([]string, 0, len(values))
+			for k := range values { keys = append(keys, k) }
+			sort.Strings(keys)
+			for _, k := range keys {
+				f, ok := fieldsByKey[k]
+				if !ok { errs = append(errs, fmt.Errorf("%s (%s): unknown key", k, path)); continue }
+				apply(f, values[k], path)
+			}
+		}
+	}
+
+	// 4. 环境变量
+	for _, f := range fields {
+		if f.env == "" { continue }
+		if s, ok := lookup(f.env); ok && (s != "" || f.allowEmpty) { apply(f, s, "env "+f.env) }
+	}
+
+	// 5. 命令行参数
+	fs.Visit(func(fl *flag.Flag) {
+		if f, ok := fieldsByKey[fl.Name]; ok { apply(f, *flagValues[f.key], "flag -"+f.key) }
+	})
+
+	// 本地开发默认不等待，Ctrl+C 立即退出
+	if !set["health.drain_delay"] && cfg.Env == "development" { cfg.Health.DrainDelay = 0 }
+	cfg.normalize()
+	if err := cfg.Validate(); err != nil { errs = append(errs, err) }
+	return cfg, errors.Join(errs...)
+}
+
+// readFile 读取配置文件并展开为点分键 -> 值；映射类型字段（如 health.http_checks）整体作为一个值。
+func readFile(path string) (map[string]any, error) {
+	data, err := os.ReadFile(path)
+	if err != nil { return nil, fmt.Errorf("read config file: %w", err) }
+	raw := map[string]any{}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, &raw)
+	case ".toml":
+		err = toml.Unmarshal(data, &raw)
+	default:
+		return nil, fmt.Errorf("config file %s: unsupported extension (want .yaml, .yml or .toml)", path)
+	}
+	if err != nil { return nil, fmt.Errorf("parse config file %s: %w", path, err) }
+	mapKeys := map[string]bool{}
+	for _, f := range fields {
+		if f.typ.Kind() == reflect.Map { mapKeys[f.key] = true }
+	}
+	out := map[string]any{}
+	flatten("", raw, mapKeys, out)
+	return out, nil
+}
+
+func flatten(prefix string, m map[string]any, mapKeys map[string]bool, out map[string]any) {
+	for k, val := range m {
+		key := prefix + k
+		if sub, ok := val.(map[string]any); ok && !mapKeys[key] {
+			flatten(key+".", sub, mapKeys, out)
+			continue
+		}
+		out[key] = val
+	}
+}
+
+// assign 把字符串（默认值 / 环境变量 / 命令行）或配置文件解码出的值写入字段。
+func assign(dst reflect.Value, raw any) error {
+	switch dst.Kind() {
+	case reflect.Slice:
+		var list []string
+		switch x := raw.(type) {
+		case string:
+			list = splitList(x)
+		case []any:
+			for _, item := range x { list = append(list, fmt.Sprint(item)) }
+		default:
+			return fmt.Errorf("expected a list, got %T", raw)
+		}
+		if list == nil { list = []string{} }
+		dst.Set(reflect.ValueOf(list))
+		return nil
+	case reflect.Map:
+		m := map[string]string{}
+		switch x := raw.(type) {
+		case string:
+			for _, kv := range splitList(x) {
+				k, val, ok := strings.Cut(kv, "=")
+				if !ok { return fmt.Errorf("expected name=value pairs, got %q", kv) }
+				m[strings.TrimSpace(k)] = strings.TrimSpace(val)
+			}
+		case map[string]any:
+			for k, val := range x { m[k] = fmt.Sprint(val) }
+		default:
+			return fmt.Errorf("expected a mapping, got %T", raw)
+		}
+		dst.Set(reflect.ValueOf(m))
+		return nil
+	}
+	s, ok := raw.(string)
+	if !ok {
+		if _, nested := raw.(map[string]any); nested { return fmt.Errorf("expected a scalar, got a mapping") }
+		s = fmt.Sprint(raw)
+	}
+	s = strings.TrimSpace(s)
+	switch {
+	case dst.Type() == durationType:
+		if s == "off" { s = "0" }
+		d, err := time.ParseDuration(s)
+		if err != nil { return fmt.Errorf("invalid duration %q", s) }
+		dst.SetInt(int64(d))
+	case dst.Kind() == reflect.String:
+		dst.SetString(s)
+	case dst.Kind() == reflect.Bool:
+		b, err := strconv.ParseBool(s)
+		if err != nil { return fmt.Errorf("invalid bool %q", s) }
+		dst.SetBool(b)
+	case dst.Kind() == reflect.Int:
+		n, err := strconv.Atoi(s)
+		if err != nil { return fmt.Errorf("invalid integer %q", s) }
+		dst.SetInt(int64(n))
+	case dst.Kind() == reflect.Float64:
+		f, err := strconv.ParseFloat(s, 64)
+		if err != nil { return fmt.Errorf("invalid number %q", s) }
+		dst.SetFloat(f)
+	default:
+		return fmt.Errorf("unsupported field type %s", dst.Type())
+	}
+	return nil
+}
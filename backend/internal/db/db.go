@@ -4,6 +4,9 @@ import (
 	"context"
 	"time"
 
+	"github.com/YangYuS8/codyssey/backend/internal/metrics"
+	"github.com/YangYuS8/codyssey/backend/internal/tracing"
+	"github.com/jackc/pgx/v5/multitracer"
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
@@ -11,11 +14,20 @@ type Database struct {
 	Pool *pgxpool.Pool
 }
 
-func Connect(ctx context.Context, connString string) (*Database, error) {
+// Options 连接池可选项。
+type Options struct {
+	SlowQueryThreshold time.Duration // 超过即输出慢查询日志；0 表示关闭
+}
+
+// Connect 建立连接池并挂载查询钩子：OTel span（tracing.PgxTracer）与 Prometheus 指标（QueryMetrics），
+// 连接池统计通过 metrics.RegisterDBPool 暴露。
+func Connect(ctx context.Context, connString string, o Options) (*Database, error) {
 	cfg, err := pgxpool.ParseConfig(connString)
 	if err != nil {
 		return nil, err
 	}
+	// pgx 每个连接只支持一个 tracer，用 multitracer 组合
+	cfg.ConnConfig.Tracer = multitracer.New(tracing.PgxTracer{}, QueryMetrics{SlowThreshold: o.SlowQueryThreshold})
 	pool, err := pgxpool.NewWithConfig(ctx, cfg)
 	if err != nil {
 		return nil, err
@@ -26,6 +38,7 @@ func Connect(ctx context.Context, connString string) (*Database, error) {
 		pool.Close()
 		return nil, err
 	}
+	metrics.RegisterDBPool(pool)
 	return &Database{Pool: pool}, nil
 }
 
@@ -0,0 +1,80 @@
+package db
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"go.uber.org/zap"
+
+	"github.com/YangYuS8/codyssey/backend/internal/logging"
+	"github.com/YangYuS8/codyssey/backend/internal/metrics"
+)
+
+type opKey struct{}
+
+// WithOperation 为随后的查询指定稳定的操作名（如 "submission.list"），用作指标标签与慢查询日志字段。
+// 仓库方法入口调用；未指定时由 SQL 推导为 "表名.动词"（如 "submissions.select"）。
+func WithOperation(ctx context.Context, op string) context.Context { return context.WithValue(ctx, opKey{}, op) }
+
+// Operation 返回 ctx 中的操作名，未指定时由 sql 推导。
+func Operation(ctx context.Context, sql string) string {
+    if op, ok := ctx.Value(opKey{}).(string); ok && op != "" { return op }
+    return operationFromSQL(sql)
+}
+
+// maxLoggedSQL 慢查询日志中 SQL 的截断长度（语句均为参数化，不含参数值）
+const maxLoggedSQL = 1024
+
+// QueryMetrics 实现 pgx.QueryTracer：按操作名记录查询耗时直方图与错误数，超过 SlowThreshold 时输出慢查询日志。
+type QueryMetrics struct {
+    SlowThreshold time.Duration // 0 表示不记录慢查询日志
+}
+
+var _ pgx.QueryTracer = QueryMetrics{}
+
+type queryStart struct {
+    op    string
+    sql   string
+    start time.Time
+}
+
+type queryStartKey struct{}
+
+func (QueryMetrics) TraceQueryStart(ctx context.Context, conn *pgx.Conn, data pgx.TraceQueryStartData) context.Context {
+    return context.WithValue(ctx, queryStartKey{}, queryStart{op: Operation(ctx, data.SQL), sql: data.SQL, start: time.Now()})
+}
+
+func (m QueryMetrics) TraceQueryEnd(ctx context.Context, conn *pgx.Conn, data pgx.TraceQueryEndData) {
+    q, ok := ctx.Value(queryStartKey{}).(queryStart)
+    if !ok { return }
+    d := time.Since(q.start)
+    metrics.ObserveDBQuery(q.op, d, data.Err)
+    if m.SlowThreshold <= 0 || d < m.SlowThreshold { return }
+    metrics.IncDBSlowQuery(q.op)
+    sql := strings.Join(strings.Fields(q.sql), " ")
+    if len(sql) > maxLoggedSQL { sql = sql[:maxLoggedSQL] }
+    fields := []zap.Field{zap.String("operation", q.op), zap.Float64("duration_ms", float64(d.Microseconds())/1000),
+        zap.String("sql", sql), zap.Int64("rows_affected", data.CommandTag.RowsAffected())}
+    if data.Err != nil { fields = append(fields, zap.Error(data.Err)) }
+    logging.FromContext(ctx).Warn("slow query", fields...)
+}
+
+// operationFromSQL 取首个关键字与其后第一个表名：SELECT ... FROM t -> "t.select"，
+// INSERT INTO t -> "t.insert"，UPDATE t -> "t.update"；无表名（如 SELECT pg_notify(...)）时仅为动词。
+func operationFromSQL(sql string) string {
+    f := strings.Fields(sql)
+    if len(f) == 0 { return "unknown" }
+    verb := strings.ToLower(f[0])
+    for i := 0; i < len(f)-1; i++ {
+        switch strings.ToLower(f[i]) {
+        case "from", "into", "update":
+            if strings.HasPrefix(f[i+1], "(") { continue } // 子查询 / 表达式，如 EXTRACT(EPOCH FROM (now() - ...))
+            t := strings.ToLower(strings.Trim(f[i+1], `"),;`))
+            if t == "" || strings.HasPrefix(t, "$") { continue }
+            return t + "." + verb
+        }
+    }
+    return verb
+}
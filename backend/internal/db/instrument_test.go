@@ -0,0 +1,89 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+
+	"github.com/YangYuS8/codyssey/backend/internal/logging"
+	"github.com/YangYuS8/codyssey/backend/internal/metrics"
+)
+
+func TestOperationFromSQL(t *testing.T) {
+    cases := map[string]string{
+        "SELECT id FROM submissions WHERE id=$1":                         "submissions.select",
+        "\n  INSERT INTO judge_runs (id) VALUES ($1)":                     "judge_runs.insert",
+        "UPDATE users SET roles=$1 WHERE id=$2":                          "users.update",
+        "DELETE FROM api_tokens WHERE id=$1":                             "api_tokens.delete",
+        `SELECT COUNT(*) FROM "problems"`:                                "problems.select",
+        "SELECT pg_notify($1, $2)":                                       "select",
+        "SELECT EXTRACT(EPOCH FROM (now() - b.updated_at)) FROM buckets b": "buckets.select",
+        "":                                                               "unknown",
+    }
+    for sql, want := range cases { require.Equal(t, want, operationFromSQL(sql), sql) }
+    ctx := WithOperation(context.Background(), "submission.list")
+    require.Equal(t, "submission.list", Operation(ctx, "SELECT 1 FROM submissions"))
+}
+
+func scrape(t *testing.T) string {
+    gin.SetMode(gin.TestMode)
+    r := gin.New()
+    r.GET("/metrics", metrics.Handler())
+    w := httptest.NewRecorder()
+    r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+    return w.Body.String()
+}
+
+func TestQueryMetricsRecordsAndLogsSlowQueries(t *testing.T) {
+    metrics.Init()
+    core, logs := observer.New(zapcore.DebugLevel)
+    ctx := logging.WithLogger(context.Background(), zap.New(core).With(zap.String("request_id", "rid-1")))
+    m := QueryMetrics{SlowThreshold: 5 * time.Millisecond}
+
+    fast := m.TraceQueryStart(WithOperation(ctx, "submission.get_by_id"), nil, pgx.TraceQueryStartData{SQL: "SELECT 1"})
+    m.TraceQueryEnd(fast, nil, pgx.TraceQueryEndData{})
+    require.Zero(t, logs.Len())
+
+    slow := m.TraceQueryStart(WithOperation(ctx, "submission.list"), nil, pgx.TraceQueryStartData{SQL: "SELECT *\n   FROM submissions"})
+    time.Sleep(6 * time.Millisecond)
+    m.TraceQueryEnd(slow, nil, pgx.TraceQueryEndData{CommandTag: pgconn.NewCommandTag("SELECT 3"), Err: errors.New("boom")})
+
+    require.Equal(t, 1, logs.Len())
+    f := logs.All()[0].ContextMap()
+    require.Equal(t, "slow query", logs.All()[0].Message)
+    require.Equal(t, "rid-1", f["request_id"])
+    require.Equal(t, "submission.list", f["operation"])
+    require.Equal(t, "SELECT * FROM submissions", f["sql"])
+    require.Equal(t, "boom", f["error"])
+
+    body := scrape(t)
+    require.Contains(t, body, `codyssey_db_query_duration_seconds_count{operation="submission.get_by_id"} 1`)
+    require.Contains(t, body, `codyssey_db_query_errors_total{operation="submission.list"} 1`)
+    require.Contains(t, body, `codyssey_db_slow_queries_total{operation="submission.list"} 1`)
+}
+
+func TestPoolStatsExposed(t *testing.T) {
+    require.NotContains(t, scrape(t), "codyssey_db_pool_max_conns")
+    cfg, err := pgxpool.ParseConfig("postgres://u:p@127.0.0.1:1/db?pool_max_conns=7")
+    require.NoError(t, err)
+    pool, err := pgxpool.NewWithConfig(context.Background(), cfg) // 不主动建连
+    require.NoError(t, err)
+    defer pool.Close()
+    metrics.RegisterDBPool(pool)
+    body := scrape(t)
+    require.Contains(t, body, "codyssey_db_pool_max_conns 7")
+    require.Contains(t, body, "codyssey_db_pool_acquired_conns 0")
+    require.Contains(t, body, "codyssey_db_pool_acquire_wait_seconds_total 0")
+}
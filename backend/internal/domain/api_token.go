@@ -0,0 +1,24 @@
+package domain
+
+import "time"
+
+const (
+    APITokenKindPersonal = "personal"
+    APITokenKindService  = "service"
+)
+
+// APIToken 长期 API 令牌；明文只在签发时返回一次，库中仅存 SHA-256 摘要。
+// personal 令牌以 UserID 身份访问；service 令牌不代表具体用户（UserID 为签发人，仅作审计）。
+type APIToken struct {
+    ID          string     `json:"id"`
+    UserID      string     `json:"user_id"`
+    Name        string     `json:"name"`
+    Kind        string     `json:"kind"`
+    Prefix      string     `json:"prefix"` // 明文前缀，便于用户辨认
+    Hash        string     `json:"-"`
+    Permissions []string   `json:"permissions"`
+    ExpiresAt   time.Time  `json:"expires_at"`
+    LastUsedAt  *time.Time `json:"last_used_at,omitempty"`
+    RevokedAt   *time.Time `json:"revoked_at,omitempty"`
+    CreatedAt   time.Time  `json:"created_at"`
+}
@@ -0,0 +1,17 @@
+package domain
+
+import "time"
+
+// IdempotencyRecord Idempotency-Key 对应的请求指纹与首次响应。
+// Key 已按 用户 + 路由 限定作用域；Status 为 0 表示首个请求仍在处理中。
+type IdempotencyRecord struct {
+    Key         string
+    Fingerprint string
+    Status      int
+    ContentType string
+    Body        []byte
+    CreatedAt   time.Time
+    ExpiresAt   time.Time
+}
+
+func (r IdempotencyRecord) Completed() bool { return r.Status != 0 }
@@ -0,0 +1,11 @@
+package domain
+
+import "time"
+
+// LoginAttempt 登录失败计数，按 Key 聚合（user:<用户名> / ip:<客户端地址>）。
+type LoginAttempt struct {
+    Key          string     `json:"key"`
+    Failures     int        `json:"failures"`
+    LastFailedAt time.Time  `json:"last_failed_at"`
+    LockedUntil  *time.Time `json:"locked_until,omitempty"`
+}
@@ -6,11 +6,110 @@ import (
 	"github.com/google/uuid"
 )
 
+// 难度取值；空串表示未标注。
+const (
+	DifficultyEasy   = "easy"
+	DifficultyMedium = "medium"
+	DifficultyHard   = "hard"
+)
+
+// 可见性：private 题目只对具备 problem.update 权限的用户（教师 / 管理员）可见。
+const (
+	VisibilityPublic  = "public"
+	VisibilityPrivate = "private"
+)
+
+// 发布状态：draft -> pending_review -> published -> archived，只有 published 题目对学生 / 访客可见。
+// 合法的流转与所需权限见 service.ProblemActions。
+const (
+	ProblemStatusDraft         = "draft"
+	ProblemStatusPendingReview = "pending_review"
+	ProblemStatusPublished     = "published"
+	ProblemStatusArchived      = "archived"
+)
+
+// 新题目的默认评测限制。
+const (
+	DefaultTimeLimitMS   = 1000
+	DefaultMemoryLimitMB = 256
+)
+
 type Problem struct {
 	ID          uuid.UUID `json:"id"`
 	Title       string    `json:"title"`
 	Description string    `json:"description"`
+	Tags        []string  `json:"tags"`       // 引用 ProblemTag.Name，已排序去重
+	Difficulty  string    `json:"difficulty"`
+	Source      string    `json:"source"`     // 出处，如 "NOIP 2019"
+	Visibility  string    `json:"visibility"`
+	Status      string    `json:"status"` // 发布状态，只经审核流程修改
+	// 以下为随修订记录的题面内容（连同 Title / Description）
+	Statement     ProblemStatement `json:"statement"`
+	TimeLimitMS   int    `json:"time_limit_ms"`
+	MemoryLimitMB int    `json:"memory_limit_mb"`
+	TestDataHash  string `json:"test_data_hash"` // 测试数据摘要，如 "sha256:<hex>"；空表示未上传
+	Revision      int    `json:"revision"`       // 当前修订号，从 1 开始；0 表示未启用修订记录
 	CreatedAt   time.Time `json:"created_at"`
+	// Provenance 非人工录入的题目来源（如 AI 生成）；手工创建的题目为 nil
+	Provenance *ProblemProvenance `json:"provenance,omitempty"`
+	// Rendered 由服务层按需渲染，不落库；列表接口不返回
+	Rendered *RenderedStatement `json:"rendered,omitempty"`
+}
+
+// 题目来源类型
+const ProblemOriginAI = "ai_generated"
+
+// ProblemProvenance 题目来源元数据，创建后不再修改。
+type ProblemProvenance struct {
+	Origin      string    `json:"origin"` // ai_generated
+	Model       string    `json:"model"`
+	Prompt      string    `json:"prompt"`
+	RequestedBy string    `json:"requested_by"`
+	GeneratedAt time.Time `json:"generated_at"`
+}
+
+// ProblemSample 样例：输入输出按原文展示，Explanation 为 Markdown。
+type ProblemSample struct {
+	Input       string `json:"input"`
+	Output      string `json:"output"`
+	Explanation string `json:"explanation"`
+}
+
+// ProblemStatement 结构化题面分节（Markdown，公式使用 KaTeX 兼容定界符）；Description 为题目描述正文。
+type ProblemStatement struct {
+	Background string          `json:"background"`
+	Input      string          `json:"input"`  // 输入格式
+	Output     string          `json:"output"` // 输出格式
+	Samples    []ProblemSample `json:"samples"`
+	Notes      string          `json:"notes"` // 提示 / 数据范围
+}
+
+// Equal 逐节比较（nil 与空样例列表视为相同）。
+func (s ProblemStatement) Equal(o ProblemStatement) bool {
+	if s.Background != o.Background || s.Input != o.Input || s.Output != o.Output || s.Notes != o.Notes || len(s.Samples) != len(o.Samples) {
+		return false
+	}
+	for i := range s.Samples {
+		if s.Samples[i] != o.Samples[i] { return false }
+	}
+	return true
+}
+
+// RenderedSample 渲染后的样例：Input / Output 为 <pre> 代码块，Explanation 为 HTML。
+type RenderedSample struct {
+	Input       string `json:"input"`
+	Output      string `json:"output"`
+	Explanation string `json:"explanation"`
+}
+
+// RenderedStatement 题面各节渲染并清洗后的 HTML，公式保留为 .math 元素交由前端 KaTeX 排版。
+type RenderedStatement struct {
+	Description string           `json:"description"`
+	Background  string           `json:"background"`
+	Input       string           `json:"input"`
+	Output      string           `json:"output"`
+	Samples     []RenderedSample `json:"samples"`
+	Notes       string           `json:"notes"`
 }
 
 func NewProblem(title, description string) Problem {
@@ -18,6 +117,71 @@ func NewProblem(title, description string) Problem {
 		ID:          uuid.New(),
 		Title:       title,
 		Description: description,
+		Tags:        []string{},
+		Visibility:  VisibilityPublic,
+		Status:      ProblemStatusPublished,
+		Statement:   ProblemStatement{Samples: []ProblemSample{}},
+		TimeLimitMS:   DefaultTimeLimitMS,
+		MemoryLimitMB: DefaultMemoryLimitMB,
 		CreatedAt:   time.Now().UTC(),
 	}
 }
+
+// ProblemRevision 题面的不可变快照：每次修改题面（标题、描述、分节、限制、测试数据）追加一条，回滚也以新修订的形式记录。
+type ProblemRevision struct {
+	ID            uuid.UUID `json:"id"`
+	ProblemID     uuid.UUID `json:"problem_id"`
+	Number        int       `json:"number"`
+	Title         string    `json:"title"`
+	Description   string    `json:"description"`
+	Statement     ProblemStatement `json:"statement"`
+	TimeLimitMS   int       `json:"time_limit_ms"`
+	MemoryLimitMB int       `json:"memory_limit_mb"`
+	TestDataHash  string    `json:"test_data_hash"`
+	AuthorID      string    `json:"author_id"`
+	Message       string    `json:"message"` // 修改说明
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+// NewProblemRevision 以题目当前题面生成第 number 个修订。
+func NewProblemRevision(p Problem, number int, authorID, message string) ProblemRevision {
+	return ProblemRevision{
+		ID:            uuid.New(),
+		ProblemID:     p.ID,
+		Number:        number,
+		Title:         p.Title,
+		Description:   p.Description,
+		Statement:     p.Statement,
+		TimeLimitMS:   p.TimeLimitMS,
+		MemoryLimitMB: p.MemoryLimitMB,
+		TestDataHash:  p.TestDataHash,
+		AuthorID:      authorID,
+		Message:       message,
+		CreatedAt:     time.Now().UTC(),
+	}
+}
+
+// ApplyTo 把修订的题面写回题目（用于回滚）。
+func (r ProblemRevision) ApplyTo(p *Problem) {
+	p.Title, p.Description, p.Statement = r.Title, r.Description, r.Statement
+	p.TimeLimitMS, p.MemoryLimitMB, p.TestDataHash = r.TimeLimitMS, r.MemoryLimitMB, r.TestDataHash
+}
+
+// Hidden private 或未发布的题目对学生 / 访客不可见，也不接受其提交。
+func (p Problem) Hidden() bool {
+	return p.Visibility == VisibilityPrivate || p.Status != ProblemStatusPublished
+}
+
+// SameStatement 两者题面（标题、描述、分节、限制、测试数据）是否一致，不比较标签等元数据。
+func (p Problem) SameStatement(o Problem) bool {
+	return p.Title == o.Title && p.Description == o.Description && p.Statement.Equal(o.Statement) && p.TimeLimitMS == o.TimeLimitMS &&
+		p.MemoryLimitMB == o.MemoryLimitMB && p.TestDataHash == o.TestDataHash
+}
+
+// ProblemTag 题目标签（受控词表：题目只能引用已创建的标签）；ProblemCount 为引用该标签的题目数。
+type ProblemTag struct {
+	Name         string    `json:"name"`
+	Description  string    `json:"description"`
+	ProblemCount int       `json:"problem_count"`
+	CreatedAt    time.Time `json:"created_at"`
+}
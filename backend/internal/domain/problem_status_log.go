@@ -0,0 +1,19 @@
+package domain
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ProblemStatusLog 题目发布状态的一次流转（审计记录，只追加）；Comment 为审核意见或操作说明。
+type ProblemStatusLog struct {
+    ID         uuid.UUID `json:"id"`
+    ProblemID  uuid.UUID `json:"problem_id"`
+    Action     string    `json:"action"` // submit / withdraw / approve / reject / archive / restore
+    FromStatus string    `json:"from_status"`
+    ToStatus   string    `json:"to_status"`
+    ActorID    string    `json:"actor_id"`
+    Comment    string    `json:"comment"`
+    CreatedAt  time.Time `json:"created_at"`
+}
@@ -0,0 +1,25 @@
+package domain
+
+import "time"
+
+// RuntimeSetting 运行时参数的覆盖值；未覆盖的键使用启动配置。Version 为写入时的全局版本号。
+type RuntimeSetting struct {
+    Key       string    `json:"key"`
+    Value     string    `json:"value"`
+    Version   int64     `json:"version"`
+    UpdatedBy string    `json:"updated_by"`
+    UpdatedAt time.Time `json:"updated_at"`
+}
+
+// RuntimeSettingChange 一次变更中单个键的记录；OldValue / NewValue 为 nil 表示变更前 / 后未覆盖（使用启动配置）。
+// Source 为 api 或 file。
+type RuntimeSettingChange struct {
+    ID        int64     `json:"id"`
+    Version   int64     `json:"version"`
+    Key       string    `json:"key"`
+    OldValue  *string   `json:"old_value"`
+    NewValue  *string   `json:"new_value"`
+    ChangedBy string    `json:"changed_by"`
+    Source    string    `json:"source"`
+    ChangedAt time.Time `json:"changed_at"`
+}
@@ -7,6 +7,7 @@ type Submission struct {
     ID        string    `json:"id"`
     UserID    string    `json:"user_id"`
     ProblemID string    `json:"problem_id"`
+    ProblemRevisionID string `json:"problem_revision_id,omitempty"` // 提交时题目的当前修订；题目未启用修订记录时为空
     Language  string    `json:"language"`
     Code      string    `json:"code"`
     Status    string    `json:"status"`
@@ -16,4 +17,6 @@ type Submission struct {
     Version   int       `json:"version"`
     CreatedAt time.Time `json:"created_at"`
     UpdatedAt time.Time `json:"updated_at"`
+    // AICheck AI 生成代码检测结果，仅对具备 ai.detect 权限的教师 / 管理员返回，不落库于 submissions
+    AICheck *SubmissionAICheck `json:"ai_check,omitempty"`
 }
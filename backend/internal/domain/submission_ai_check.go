@@ -0,0 +1,50 @@
+package domain
+
+import "time"
+
+// AI 检测结果状态
+const (
+    AICheckStatusPending   = "pending"
+    AICheckStatusRunning   = "running" // 已被某个实例认领（租约内），到期未完成可被重新认领
+    AICheckStatusCompleted = "completed"
+    AICheckStatusFailed    = "failed" // AI 服务不可用或队列已满，不影响判题
+)
+
+// SubmissionAICheck 一次提交的 AI 生成代码检测结果（每个提交至多一条）。
+type SubmissionAICheck struct {
+    SubmissionID string     `json:"submission_id"`
+    ProblemID    string     `json:"problem_id"`
+    UserID       string     `json:"user_id"`
+    Status       string     `json:"status"`
+    Score        float64    `json:"score"`      // [0,1]，越高越可能为 AI 生成
+    Suspicious   bool       `json:"suspicious"` // AI 服务给出的可疑判定
+    Model        string     `json:"model"`
+    Error        string     `json:"error,omitempty"`
+    CreatedAt    time.Time  `json:"created_at"`
+    CheckedAt    *time.Time `json:"checked_at,omitempty"`
+    LeaseUntil   *time.Time `json:"-"` // running 记录的租约到期时间
+}
+
+// AI 检测开关作用范围。比赛范围待比赛实体落地、可由服务端确定提交所属比赛后再提供。
+const AIDetectionScopeProblem = "problem"
+
+// AIDetectionSetting 按题目开启 AI 检测。
+type AIDetectionSetting struct {
+    Scope     string    `json:"scope"` // problem
+    ScopeID   string    `json:"scope_id"`
+    Enabled   bool      `json:"enabled"`
+    UpdatedBy string    `json:"updated_by"`
+    UpdatedAt time.Time `json:"updated_at"`
+}
+
+// AICheckSummary 单题检测结果汇总；AvgScore 只统计已完成的检测。
+type AICheckSummary struct {
+    ProblemID  string  `json:"problem_id"`
+    Total      int     `json:"total"`
+    Pending    int     `json:"pending"`
+    Running    int     `json:"running"`
+    Completed  int     `json:"completed"`
+    Failed     int     `json:"failed"`
+    Suspicious int     `json:"suspicious"`
+    AvgScore   float64 `json:"avg_score"`
+}
@@ -2,11 +2,36 @@ package domain
 
 import "time"
 
-// User 领域模型（简化版，后续可扩展 email, password_hash 等）
+// User 领域模型；Email 为空表示未绑定邮箱。
 type User struct {
     ID        string    `json:"id"`
     Username  string    `json:"username"`
     Roles     []string  `json:"roles"`
     CreatedAt time.Time `json:"created_at"`
     PasswordHash string `json:"-"`
+    // 资料
+    Email         string `json:"email,omitempty"`
+    EmailVerified bool   `json:"email_verified"`
+    DisplayName   string `json:"display_name,omitempty"`
+    School        string `json:"school,omitempty"`
+    StudentNumber string `json:"student_number,omitempty"`
+    // 密码最近修改时间；此前签发的 refresh token 失效
+    PasswordChangedAt *time.Time `json:"-"`
+}
+
+const (
+    UserTokenEmailVerify   = "email_verify"
+    UserTokenPasswordReset = "password_reset"
+)
+
+// UserToken 一次性令牌（邮箱验证 / 找回密码），库中仅存摘要。
+type UserToken struct {
+    ID        string
+    UserID    string
+    Purpose   string
+    Hash      string
+    Email     string // 发送目标；邮箱验证时须与用户当前邮箱一致才生效
+    ExpiresAt time.Time
+    UsedAt    *time.Time
+    CreatedAt time.Time
 }
@@ -0,0 +1,16 @@
+package domain
+
+import "time"
+
+// UserMFA 用户的 TOTP 二次验证状态（与 users 1:1，未登记则无记录）。
+// Enabled=false 表示已生成密钥但尚未用验证码确认。
+type UserMFA struct {
+    UserID        string     `json:"user_id"`
+    Secret        string     `json:"-"` // base32 TOTP 密钥
+    Enabled       bool       `json:"enabled"`
+    RecoveryCodes []string   `json:"-"` // 恢复码 SHA-256 摘要，使用后移除
+    LastUsedStep  int64      `json:"-"` // 最近一次通过校验的时间步，防止同一验证码重放
+    ConfirmedAt   *time.Time `json:"confirmed_at,omitempty"`
+    CreatedAt     time.Time  `json:"created_at"`
+    UpdatedAt     time.Time  `json:"updated_at"`
+}
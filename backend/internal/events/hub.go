@@ -0,0 +1,130 @@
+// Package events 提交 / 判题状态变更的进程内发布订阅，可经 Postgres LISTEN/NOTIFY 在多实例间扇出。
+package events
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/YangYuS8/codyssey/backend/internal/metrics"
+)
+
+// 事件类型（与前端 useSubmissionEvents 约定一致）
+const (
+    TypeStatusUpdate   = "status_update"
+    TypeJudgeRunUpdate = "judge_run_update"
+    TypeCompleted      = "completed"
+    // TypeTopicMessage WebSocket 主题消息（由 realtime.Broker 发布与消费，不进入 SSE 流）
+    TypeTopicMessage   = "topic_message"
+)
+
+// Event 推送给客户端的单条事件。ID 为事件时间的 Unix 微秒数，用于 Last-Event-ID 续传。
+type Event struct {
+    ID           string `json:"-"`
+    Type         string `json:"type"`
+    SubmissionID string `json:"submissionId"`
+    UserID       string `json:"-"` // 提交者，用于按用户过滤
+    Topic        string `json:"-"` // TypeTopicMessage 的目标主题
+    Payload      any    `json:"payload,omitempty"`
+}
+
+// IDAt 以时间生成事件 ID（微秒精度与 Postgres timestamptz 一致）。
+func IDAt(t time.Time) string { return strconv.FormatInt(t.UTC().UnixMicro(), 10) }
+
+// ParseID 解析 Last-Event-ID；非法时返回 false。
+func ParseID(id string) (time.Time, bool) {
+    n, err := strconv.ParseInt(id, 10, 64)
+    if err != nil || n <= 0 { return time.Time{}, false }
+    return time.UnixMicro(n).UTC(), true
+}
+
+// Publisher 业务侧只依赖发布能力。
+type Publisher interface {
+    Publish(ctx context.Context, ev Event)
+}
+
+// Subscription 订阅句柄；消费过慢（缓冲写满）时被 Hub 丢弃并关闭 Done，客户端应断开后续传。
+type Subscription struct {
+    hub   *Hub
+    match func(Event) bool
+    ch    chan Event
+    done  chan struct{}
+    once  sync.Once
+}
+
+func (s *Subscription) C() <-chan Event        { return s.ch }
+func (s *Subscription) Done() <-chan struct{}  { return s.done }
+func (s *Subscription) Close()                 { s.hub.remove(s) }
+
+const subscriptionBuffer = 64
+
+// Hub 进程内扇出。配置 remote 后 Publish 经远端（NOTIFY）广播，由监听协程回送 Broadcast，
+// 保证多实例下每个订阅者恰好收到一次。
+type Hub struct {
+    mu     sync.RWMutex
+    subs   map[*Subscription]struct{}
+    remote func(ctx context.Context, ev Event) error
+    listening atomic.Bool // 监听连接已 LISTEN（UsePostgres 后有效）
+}
+
+func NewHub() *Hub { return &Hub{subs: map[*Subscription]struct{}{}} }
+
+// Subscribe match 为 nil 表示接收全部事件。
+func (h *Hub) Subscribe(match func(Event) bool) *Subscription {
+    s := &Subscription{hub: h, match: match, ch: make(chan Event, subscriptionBuffer), done: make(chan struct{})}
+    h.mu.Lock()
+    h.subs[s] = struct{}{}
+    h.mu.Unlock()
+    return s
+}
+
+func (h *Hub) remove(s *Subscription) {
+    h.mu.Lock()
+    delete(h.subs, s)
+    h.mu.Unlock()
+    s.once.Do(func() { close(s.done) })
+}
+
+// Close 关闭全部订阅（服务停机时调用，使长连接及时结束）。
+func (h *Hub) Close() {
+    h.mu.Lock()
+    subs := h.subs
+    h.subs = map[*Subscription]struct{}{}
+    h.mu.Unlock()
+    for s := range subs { s.once.Do(func() { close(s.done) }) }
+}
+
+// Subscribers 当前订阅数。
+func (h *Hub) Subscribers() int {
+    h.mu.RLock(); defer h.mu.RUnlock()
+    return len(h.subs)
+}
+
+// Publish 远端不可用时退化为仅本实例投递。
+func (h *Hub) Publish(ctx context.Context, ev Event) {
+    if h.remote != nil {
+        if err := h.remote(ctx, ev); err == nil { return }
+    }
+    h.Broadcast(ev)
+}
+
+// Broadcast 投递给本实例的订阅者（不阻塞发布方）。
+func (h *Hub) Broadcast(ev Event) {
+    var slow []*Subscription
+    h.mu.RLock()
+    for s := range h.subs {
+        if s.match != nil && !s.match(ev) { continue }
+        select {
+        case s.ch <- ev:
+        default:
+            slow = append(slow, s)
+        }
+    }
+    h.mu.RUnlock()
+    for _, s := range slow {
+        s.Close()
+        metrics.IncSSEDropped()
+    }
+}
@@ -0,0 +1,55 @@
+package events
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestHubDeliversMatchingEvents(t *testing.T) {
+    h := NewHub()
+    mine := h.Subscribe(func(ev Event) bool { return ev.UserID == "u1" })
+    all := h.Subscribe(nil)
+    defer mine.Close()
+    defer all.Close()
+
+    h.Publish(context.Background(), Event{ID: "1", Type: TypeStatusUpdate, SubmissionID: "s1", UserID: "u1"})
+    h.Publish(context.Background(), Event{ID: "2", Type: TypeStatusUpdate, SubmissionID: "s2", UserID: "u2"})
+
+    require.Equal(t, "1", (<-mine.C()).ID)
+    require.Len(t, mine.C(), 0)
+    require.Equal(t, "1", (<-all.C()).ID)
+    require.Equal(t, "2", (<-all.C()).ID)
+}
+
+func TestHubDropsSlowSubscriber(t *testing.T) {
+    h := NewHub()
+    slow := h.Subscribe(nil)
+    for i := 0; i <= subscriptionBuffer; i++ { h.Broadcast(Event{ID: "x"}) }
+    select {
+    case <-slow.Done():
+    default:
+        t.Fatal("slow subscriber should be dropped")
+    }
+    require.Equal(t, 0, h.Subscribers())
+    slow.Close() // 重复关闭安全
+}
+
+func TestHubClose(t *testing.T) {
+    h := NewHub()
+    s := h.Subscribe(nil)
+    h.Close()
+    <-s.Done()
+    require.Equal(t, 0, h.Subscribers())
+}
+
+func TestEventID(t *testing.T) {
+    now := time.Date(2025, 3, 1, 8, 0, 0, 123456789, time.UTC)
+    got, ok := ParseID(IDAt(now))
+    require.True(t, ok)
+    require.Equal(t, now.Truncate(time.Microsecond), got)
+    _, ok = ParseID("abc")
+    require.False(t, ok)
+}
@@ -0,0 +1,93 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/YangYuS8/codyssey/backend/internal/tracing"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"go.uber.org/zap"
+)
+
+// Channel LISTEN/NOTIFY 通道名。
+const Channel = "codyssey_events"
+
+// wireEvent NOTIFY 载荷（需携带不向客户端输出的字段）。
+type wireEvent struct {
+    ID           string          `json:"id"`
+    Type         string          `json:"type"`
+    SubmissionID string          `json:"submission_id"`
+    UserID       string          `json:"user_id"`
+    Topic        string          `json:"topic,omitempty"`
+    Payload      json.RawMessage `json:"payload,omitempty"`
+}
+
+// MaxNotifyBytes Postgres NOTIFY 载荷上限（默认编译配置）。
+const MaxNotifyBytes = 7999
+
+// ErrPayloadTooLarge 事件超过 NOTIFY 载荷上限，只能投递到本实例。
+var ErrPayloadTooLarge = errors.New("event payload exceeds NOTIFY limit")
+
+// UsePostgres 让 Publish 走 pg_notify，并启动监听协程把各实例发布的事件投递到本地订阅者。
+// 监听连接断开后按指数退避重连；ctx 取消时退出。
+func (h *Hub) UsePostgres(ctx context.Context, pool *pgxpool.Pool, logger *zap.Logger) {
+    if logger == nil { logger = zap.NewNop() }
+    h.remote = func(ctx context.Context, ev Event) error {
+        payload, err := json.Marshal(ev.Payload)
+        if err != nil { return err }
+        b, err := json.Marshal(wireEvent{ID: ev.ID, Type: ev.Type, SubmissionID: ev.SubmissionID, UserID: ev.UserID, Topic: ev.Topic, Payload: payload})
+        if err != nil { return err }
+        if len(b) > MaxNotifyBytes {
+            err = ErrPayloadTooLarge
+        } else {
+            _, err = pool.Exec(ctx, `SELECT pg_notify($1, $2)`, Channel, string(b))
+        }
+        if err != nil { tracing.Logger(ctx, logger).Warn("event notify failed; delivering locally", zap.Error(err)) }
+        return err
+    }
+    go h.listen(ctx, pool, logger)
+}
+
+// ErrNotListening 监听连接断开、正在重连。
+var ErrNotListening = errors.New("event listener not connected")
+
+// Check 就绪检查：未启用 Postgres 扇出时总是可用；否则要求监听连接在线（断开期间其他实例发布的事件收不到）。
+func (h *Hub) Check(ctx context.Context) error {
+    if h.remote == nil || h.listening.Load() { return nil }
+    return ErrNotListening
+}
+
+func (h *Hub) listen(ctx context.Context, pool *pgxpool.Pool, logger *zap.Logger) {
+    backoff := time.Second
+    for ctx.Err() == nil {
+        err := h.listenOnce(ctx, pool)
+        if ctx.Err() != nil { return }
+        logger.Warn("event listener disconnected", zap.Error(err), zap.Duration("retry_in", backoff))
+        select {
+        case <-ctx.Done():
+            return
+        case <-time.After(backoff):
+        }
+        if backoff < 30*time.Second { backoff *= 2 }
+    }
+}
+
+func (h *Hub) listenOnce(ctx context.Context, pool *pgxpool.Pool) error {
+    conn, err := pool.Acquire(ctx)
+    if err != nil { return err }
+    defer conn.Release()
+    if _, err := conn.Exec(ctx, "LISTEN "+Channel); err != nil { return err }
+    h.listening.Store(true)
+    defer h.listening.Store(false)
+    for {
+        n, err := conn.Conn().WaitForNotification(ctx)
+        if err != nil { return err }
+        var w wireEvent
+        if err := json.Unmarshal([]byte(n.Payload), &w); err != nil { continue }
+        ev := Event{ID: w.ID, Type: w.Type, SubmissionID: w.SubmissionID, UserID: w.UserID, Topic: w.Topic}
+        if len(w.Payload) > 0 && string(w.Payload) != "null" { ev.Payload = w.Payload }
+        h.Broadcast(ev)
+    }
+}
@@ -0,0 +1,152 @@
+// Package featureflag 按身份求值的功能开关（灰度发布）：角色、用户白名单与按用户稳定哈希的百分比放量。
+// 存储抽象见 Store（内存实现在本包，Postgres 实现见 repository.PGFeatureFlagStore）；HTTP 门控见 internal/http/middleware。
+package featureflag
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 代码中门控使用的开关键；未经 /admin/feature-flags 创建前视为关闭。
+const (
+    KeyAIProblemGeneration = "ai_problem_generation" // POST /problems/generate
+    KeyAIDetection         = "ai_detection"          // /ai-detection/*、/problems/:id/ai-report
+)
+
+var (
+    ErrNotFound = errors.New("feature flag not found")
+    ErrExists   = errors.New("feature flag already exists")
+    ErrInvalid  = errors.New("invalid feature flag")
+)
+
+// Flag 一个功能开关。Enabled 为总开关：关闭时对所有人关闭；打开后满足任一条件即生效：
+// 用户 ID 在 Users 中、拥有 Roles 中任一角色、或用户落在 Percentage 百分比桶内（Percentage=100 时含匿名访客）。
+type Flag struct {
+    Key         string    `json:"key"`
+    Description string    `json:"description"`
+    Enabled     bool      `json:"enabled"`
+    Roles       []string  `json:"roles"`
+    Users       []string  `json:"users"`
+    Percentage  int       `json:"percentage"` // 0-100
+    UpdatedBy   string    `json:"updated_by"`
+    CreatedAt   time.Time `json:"created_at"`
+    UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// Subject 求值主体；UserID 为空或 "guest" 视为匿名。
+type Subject struct {
+    UserID string
+    Roles  []string
+}
+
+func (s Subject) anonymous() bool { return s.UserID == "" || s.UserID == "guest" }
+
+// EnabledFor 对主体求值。
+func (f Flag) EnabledFor(s Subject) bool {
+    if !f.Enabled { return false }
+    if f.Percentage >= 100 { return true }
+    if s.anonymous() { return false }
+    for _, u := range f.Users { if u == s.UserID { return true } }
+    for _, want := range f.Roles {
+        for _, r := range s.Roles { if r == want { return true } }
+    }
+    return f.Percentage > 0 && Bucket(f.Key, s.UserID) < f.Percentage
+}
+
+// Bucket 用户在某开关上的稳定分桶 [0,100)：同一用户结果固定，放量百分比调大时已命中的用户保持命中；
+// 以开关键参与哈希，不同开关的命中人群相互独立。
+func Bucket(key, userID string) int {
+    h := fnv.New32a()
+    _, _ = h.Write([]byte(key))
+    _, _ = h.Write([]byte{':'})
+    _, _ = h.Write([]byte(userID))
+    return int(h.Sum32() % 100)
+}
+
+var keyRe = regexp.MustCompile(`^[a-z0-9][a-z0-9_.-]{0,63}$`)
+
+// Normalize 校验并规范化（去空白、去重排序角色与用户）。
+func (f *Flag) Normalize() error {
+    f.Key = strings.TrimSpace(f.Key)
+    f.Description = strings.TrimSpace(f.Description)
+    if !keyRe.MatchString(f.Key) { return fmt.Errorf("%w: key must match %s", ErrInvalid, keyRe) }
+    if f.Percentage < 0 || f.Percentage > 100 { return fmt.Errorf("%w: percentage must be within [0,100]", ErrInvalid) }
+    f.Roles = normalizeList(f.Roles)
+    f.Users = normalizeList(f.Users)
+    return nil
+}
+
+func normalizeList(in []string) []string {
+    seen := map[string]bool{}
+    out := []string{}
+    for _, v := range in {
+        v = strings.TrimSpace(v)
+        if v == "" || seen[v] { continue }
+        seen[v] = true
+        out = append(out, v)
+    }
+    sort.Strings(out)
+    return out
+}
+
+// Store 开关存储。
+type Store interface {
+    List(ctx context.Context) ([]Flag, error)
+    Get(ctx context.Context, key string) (Flag, error)
+    Create(ctx context.Context, f Flag) error
+    Update(ctx context.Context, f Flag) error
+    Delete(ctx context.Context, key string) error
+}
+
+// MemoryStore 内存实现（测试 / 单实例开发用）。
+type MemoryStore struct {
+    mu    sync.RWMutex
+    flags map[string]Flag
+}
+
+func NewMemoryStore() *MemoryStore { return &MemoryStore{flags: map[string]Flag{}} }
+
+func (m *MemoryStore) List(ctx context.Context) ([]Flag, error) {
+    m.mu.RLock(); defer m.mu.RUnlock()
+    out := make([]Flag, 0, len(m.flags))
+    for _, f := range m.flags { out = append(out, f) }
+    sort.Slice(out, func(i, j int) bool { return out[i].Key < out[j].Key })
+    return out, nil
+}
+
+func (m *MemoryStore) Get(ctx context.Context, key string) (Flag, error) {
+    m.mu.RLock(); defer m.mu.RUnlock()
+    f, ok := m.flags[key]
+    if !ok { return Flag{}, ErrNotFound }
+    return f, nil
+}
+
+func (m *MemoryStore) Create(ctx context.Context, f Flag) error {
+    m.mu.Lock(); defer m.mu.Unlock()
+    if _, ok := m.flags[f.Key]; ok { return ErrExists }
+    m.flags[f.Key] = f
+    return nil
+}
+
+func (m *MemoryStore) Update(ctx context.Context, f Flag) error {
+    m.mu.Lock(); defer m.mu.Unlock()
+    old, ok := m.flags[f.Key]
+    if !ok { return ErrNotFound }
+    f.CreatedAt = old.CreatedAt
+    m.flags[f.Key] = f
+    return nil
+}
+
+func (m *MemoryStore) Delete(ctx context.Context, key string) error {
+    m.mu.Lock(); defer m.mu.Unlock()
+    if _, ok := m.flags[key]; !ok { return ErrNotFound }
+    delete(m.flags, key)
+    return nil
+}
@@ -0,0 +1,104 @@
+package featureflag
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestEnabledFor(t *testing.T) {
+    f := Flag{Key: "ai_detection", Enabled: true, Roles: []string{"teacher"}, Users: []string{"u-allow"}}
+    require.True(t, f.EnabledFor(Subject{UserID: "u-allow", Roles: []string{"student"}}))
+    require.True(t, f.EnabledFor(Subject{UserID: "u2", Roles: []string{"teacher"}}))
+    require.False(t, f.EnabledFor(Subject{UserID: "u3", Roles: []string{"student"}}))
+    require.False(t, f.EnabledFor(Subject{}))
+
+    f.Enabled = false
+    require.False(t, f.EnabledFor(Subject{UserID: "u-allow"}), "master switch off overrides allowlist")
+
+    all := Flag{Key: "new_verdicts", Enabled: true, Percentage: 100}
+    require.True(t, all.EnabledFor(Subject{}), "100% includes guests")
+    require.True(t, all.EnabledFor(Subject{UserID: "guest"}))
+}
+
+func TestPercentageRolloutStable(t *testing.T) {
+    f := Flag{Key: "contest_v2", Enabled: true, Percentage: 30}
+    hits := 0
+    for i := 0; i < 2000; i++ {
+        sub := Subject{UserID: fmt.Sprintf("user-%d", i)}
+        got := f.EnabledFor(sub)
+        require.Equal(t, got, f.EnabledFor(sub), "same user, same result")
+        if got { hits++ }
+    }
+    require.InDelta(t, 600, hits, 120)
+    require.False(t, f.EnabledFor(Subject{}), "partial rollout excludes guests")
+
+    // 放量调大时已命中的用户保持命中
+    wider := f
+    wider.Percentage = 60
+    for i := 0; i < 500; i++ {
+        sub := Subject{UserID: fmt.Sprintf("user-%d", i)}
+        if f.EnabledFor(sub) { require.True(t, wider.EnabledFor(sub)) }
+    }
+}
+
+func TestNormalize(t *testing.T) {
+    f := Flag{Key: " ai_detection ", Roles: []string{"teacher", " teacher", ""}, Users: []string{"b", "a"}}
+    require.NoError(t, f.Normalize())
+    require.Equal(t, "ai_detection", f.Key)
+    require.Equal(t, []string{"teacher"}, f.Roles)
+    require.Equal(t, []string{"a", "b"}, f.Users)
+
+    require.ErrorIs(t, (&Flag{Key: "Bad Key"}).Normalize(), ErrInvalid)
+    require.ErrorIs(t, (&Flag{Key: "ok", Percentage: 101}).Normalize(), ErrInvalid)
+}
+
+type failingStore struct {
+    *MemoryStore
+    fail bool
+}
+
+func (s *failingStore) List(ctx context.Context) ([]Flag, error) {
+    if s.fail { return nil, errors.New("db down") }
+    return s.MemoryStore.List(ctx)
+}
+
+func TestServiceCache(t *testing.T) {
+    ctx := context.Background()
+    store := &failingStore{MemoryStore: NewMemoryStore()}
+    svc := NewService(store, Options{CacheTTL: time.Minute})
+    now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+    svc.now = func() time.Time { return now }
+    teacher := Subject{UserID: "t1", Roles: []string{"teacher"}}
+
+    require.False(t, svc.Enabled(ctx, "ai_detection", teacher), "undefined flag is off")
+    _, err := svc.Create(ctx, Flag{Key: "ai_detection", Enabled: true, Roles: []string{"teacher"}}, "admin")
+    require.NoError(t, err)
+    require.True(t, svc.Enabled(ctx, "ai_detection", teacher), "local write invalidates cache")
+    _, err = svc.Create(ctx, Flag{Key: "ai_detection"}, "admin")
+    require.ErrorIs(t, err, ErrExists)
+
+    // 其他实例直接写入存储：TTL 内仍为旧值
+    require.NoError(t, store.Update(ctx, Flag{Key: "ai_detection", Enabled: false}))
+    require.True(t, svc.Enabled(ctx, "ai_detection", teacher))
+    now = now.Add(2 * time.Minute)
+    require.False(t, svc.Enabled(ctx, "ai_detection", teacher))
+
+    // 加载失败沿用旧缓存
+    _, err = svc.Update(ctx, "ai_detection", Flag{Enabled: true, Percentage: 100}, "admin")
+    require.NoError(t, err)
+    require.True(t, svc.Enabled(ctx, "ai_detection", Subject{}))
+    store.fail = true
+    now = now.Add(2 * time.Minute)
+    require.True(t, svc.Enabled(ctx, "ai_detection", Subject{}))
+    require.Equal(t, map[string]bool{"ai_detection": true}, svc.Evaluate(ctx, Subject{}))
+
+    store.fail = false
+    require.NoError(t, svc.Delete(ctx, "ai_detection", "admin"))
+    require.False(t, svc.Enabled(ctx, "ai_detection", Subject{}))
+    require.ErrorIs(t, svc.Delete(ctx, "ai_detection", "admin"), ErrNotFound)
+}
@@ -0,0 +1,109 @@
+package featureflag
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// Options CacheTTL 为求值缓存的有效期（<=0 时为 5s）：求值在请求路径上，不逐次查库；
+// 本实例的写入立即生效，其他实例的写入最迟 CacheTTL 后生效。
+type Options struct {
+    CacheTTL time.Duration
+    Logger   *zap.Logger
+}
+
+type flagCache struct {
+    flags    map[string]Flag
+    loadedAt time.Time
+}
+
+// Service 开关的管理与求值。
+type Service struct {
+    store  Store
+    ttl    time.Duration
+    logger *zap.Logger
+    now    func() time.Time
+
+    cache atomic.Pointer[flagCache]
+    mu    sync.Mutex // 串行化缓存加载
+}
+
+func NewService(store Store, o Options) *Service {
+    if o.CacheTTL <= 0 { o.CacheTTL = 5 * time.Second }
+    if o.Logger == nil { o.Logger = zap.NewNop() }
+    return &Service{store: store, ttl: o.CacheTTL, logger: o.Logger, now: time.Now}
+}
+
+// snapshot 返回未过期的缓存，必要时重新加载；加载失败时沿用旧缓存（无缓存时全部视为关闭）并在下个周期重试。
+func (s *Service) snapshot(ctx context.Context) map[string]Flag {
+    if c := s.cache.Load(); c != nil && s.now().Sub(c.loadedAt) < s.ttl { return c.flags }
+    s.mu.Lock()
+    defer s.mu.Unlock()
+    old := s.cache.Load()
+    if old != nil && s.now().Sub(old.loadedAt) < s.ttl { return old.flags }
+    list, err := s.store.List(ctx)
+    if err != nil {
+        s.logger.Warn("feature flags load failed; using cached values", zap.Error(err))
+        stale := map[string]Flag{}
+        if old != nil { stale = old.flags }
+        s.cache.Store(&flagCache{flags: stale, loadedAt: s.now()})
+        return stale
+    }
+    flags := make(map[string]Flag, len(list))
+    for _, f := range list { flags[f.Key] = f }
+    s.cache.Store(&flagCache{flags: flags, loadedAt: s.now()})
+    return flags
+}
+
+func (s *Service) invalidate() { s.cache.Store(nil) }
+
+// Enabled 对主体求值；未定义的开关为关闭。
+func (s *Service) Enabled(ctx context.Context, key string, sub Subject) bool {
+    f, ok := s.snapshot(ctx)[key]
+    return ok && f.EnabledFor(sub)
+}
+
+// Evaluate 主体在全部开关上的求值结果（前端按此决定展示）。
+func (s *Service) Evaluate(ctx context.Context, sub Subject) map[string]bool {
+    flags := s.snapshot(ctx)
+    out := make(map[string]bool, len(flags))
+    for k, f := range flags { out[k] = f.EnabledFor(sub) }
+    return out
+}
+
+func (s *Service) List(ctx context.Context) ([]Flag, error) { return s.store.List(ctx) }
+
+func (s *Service) Get(ctx context.Context, key string) (Flag, error) { return s.store.Get(ctx, key) }
+
+// Create 新建开关；actor 记录为最后修改人。
+func (s *Service) Create(ctx context.Context, f Flag, actor string) (Flag, error) {
+    if err := f.Normalize(); err != nil { return Flag{}, err }
+    now := s.now().UTC()
+    f.UpdatedBy, f.CreatedAt, f.UpdatedAt = actor, now, now
+    if err := s.store.Create(ctx, f); err != nil { return Flag{}, err }
+    s.invalidate()
+    s.logger.Info("feature flag created", zap.String("key", f.Key), zap.Bool("enabled", f.Enabled), zap.Int("percentage", f.Percentage), zap.String("actor", actor))
+    return f, nil
+}
+
+// Update 整体替换 key 对应开关的规则。
+func (s *Service) Update(ctx context.Context, key string, f Flag, actor string) (Flag, error) {
+    f.Key = key
+    if err := f.Normalize(); err != nil { return Flag{}, err }
+    f.UpdatedBy, f.UpdatedAt = actor, s.now().UTC()
+    if err := s.store.Update(ctx, f); err != nil { return Flag{}, err }
+    s.invalidate()
+    s.logger.Info("feature flag updated", zap.String("key", f.Key), zap.Bool("enabled", f.Enabled), zap.Int("percentage", f.Percentage), zap.String("actor", actor))
+    return s.store.Get(ctx, key)
+}
+
+func (s *Service) Delete(ctx context.Context, key, actor string) error {
+    if err := s.store.Delete(ctx, key); err != nil { return err }
+    s.invalidate()
+    s.logger.Info("feature flag deleted", zap.String("key", key), zap.String("actor", actor))
+    return nil
+}
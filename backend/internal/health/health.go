@@ -0,0 +1,188 @@
+package health
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// 就绪检查注册表：各依赖以 CheckFunc 注册，/readyz 并发执行并汇总；/livez 仅表示进程存活，不访问依赖。
+
+// CheckFunc 返回 nil 表示依赖可用；ctx 带有单项超时。
+type CheckFunc func(ctx context.Context) error
+
+const (
+    StatusOK       = "ok"
+    StatusFail     = "fail"
+    StatusDegraded = "degraded" // 仅非关键检查失败，仍视为就绪
+    StatusDraining = "draining" // 停机排空中，不再接收新流量
+)
+
+// Result 单项检查结果。
+type Result struct {
+    Name      string  `json:"name"`
+    Status    string  `json:"status"`
+    Critical  bool    `json:"critical"`
+    LatencyMs float64 `json:"latency_ms"`
+    Error     string  `json:"error,omitempty"`
+}
+
+// Report /readyz 响应体。
+type Report struct {
+    Status string   `json:"status"`
+    Checks []Result `json:"checks"`
+}
+
+// Ready 关键检查均通过且未在排空。
+func (r Report) Ready() bool { return r.Status == StatusOK || r.Status == StatusDegraded }
+
+type check struct {
+    name     string
+    fn       CheckFunc
+    critical bool
+}
+
+// Registry 就绪检查注册表，可并发使用。
+type Registry struct {
+    mu       sync.RWMutex
+    checks   []check
+    optional map[string]bool
+    timeout  time.Duration
+    draining atomic.Bool
+}
+
+// NewRegistry timeout 为单项检查超时，<=0 时为 2s。
+func NewRegistry(timeout time.Duration) *Registry {
+    if timeout <= 0 { timeout = 2 * time.Second }
+    return &Registry{timeout: timeout, optional: map[string]bool{}}
+}
+
+// Register 注册关键检查；同名检查会被替换。
+func (r *Registry) Register(name string, fn CheckFunc) { r.register(name, fn, true) }
+
+// RegisterOptional 注册非关键检查：失败时报告为 degraded，不影响就绪。
+func (r *Registry) RegisterOptional(name string, fn CheckFunc) { r.register(name, fn, false) }
+
+func (r *Registry) register(name string, fn CheckFunc, critical bool) {
+    r.mu.Lock()
+    defer r.mu.Unlock()
+    c := check{name: name, fn: fn, critical: critical}
+    for i := range r.checks {
+        if r.checks[i].name == name { r.checks[i] = c; return }
+    }
+    r.checks = append(r.checks, c)
+}
+
+// SetOptional 将指定名称的检查降级为非关键（运维配置覆盖，先于或后于注册均可）。
+func (r *Registry) SetOptional(names ...string) {
+    r.mu.Lock()
+    defer r.mu.Unlock()
+    for _, n := range names { r.optional[n] = true }
+}
+
+// Drain 标记进入停机排空：此后 Check 总是报告 draining，负载均衡器据此摘除实例。
+func (r *Registry) Drain() { r.draining.Store(true) }
+
+// Draining 是否处于排空状态。
+func (r *Registry) Draining() bool { return r.draining.Load() }
+
+// Check 并发执行全部检查（各自超时），结果按名称排序。
+func (r *Registry) Check(ctx context.Context) Report {
+    r.mu.RLock()
+    checks := append([]check(nil), r.checks...)
+    optional := make(map[string]bool, len(r.optional))
+    for k, v := range r.optional { optional[k] = v }
+    r.mu.RUnlock()
+
+    results := make([]Result, len(checks))
+    var wg sync.WaitGroup
+    for i, c := range checks {
+        wg.Add(1)
+        go func(i int, c check) {
+            defer wg.Done()
+            results[i] = r.run(ctx, c, c.critical && !optional[c.name])
+        }(i, c)
+    }
+    wg.Wait()
+    sort.Slice(results, func(i, j int) bool { return results[i].Name < results[j].Name })
+
+    rep := Report{Status: StatusOK, Checks: results}
+    for _, res := range results {
+        if res.Status == StatusOK { continue }
+        if res.Critical { rep.Status = StatusFail; break }
+        rep.Status = StatusDegraded
+    }
+    if r.Draining() { rep.Status = StatusDraining }
+    return rep
+}
+
+func (r *Registry) run(ctx context.Context, c check, critical bool) (res Result) {
+    res = Result{Name: c.name, Status: StatusOK, Critical: critical}
+    ctx, cancel := context.WithTimeout(ctx, r.timeout)
+    defer cancel()
+    start := time.Now()
+    defer func() { res.LatencyMs = float64(time.Since(start).Microseconds()) / 1000 }()
+    // 检查函数未遵守 ctx 时也按超时返回
+    done := make(chan error, 1)
+    go func() {
+        defer func() {
+            if p := recover(); p != nil { done <- fmt.Errorf("panic: %v", p) }
+        }()
+        done <- c.fn(ctx)
+    }()
+    var err error
+    select {
+    case err = <-done:
+    case <-ctx.Done():
+        err = ctx.Err()
+    }
+    if err != nil { res.Status, res.Error = StatusFail, err.Error() }
+    return res
+}
+
+// Pinger 由 *pgxpool.Pool 等实现。
+type Pinger interface {
+    Ping(ctx context.Context) error
+}
+
+// PingCheck 检查连接可用（Postgres 等）。
+func PingCheck(p Pinger) CheckFunc {
+    return func(ctx context.Context) error { return p.Ping(ctx) }
+}
+
+// RowQuerier 由 *pgxpool.Pool 实现。
+type RowQuerier interface {
+    QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
+}
+
+// MigrationCheck 比较 goose 已应用的最高版本与代码携带的最新迁移版本：库落后说明迁移未执行（新代码可能访问不存在的列）；
+// 库超前是滚动发布中旧实例的正常情况（迁移需向后兼容），不视为失败。
+func MigrationCheck(q RowQuerier, want int64) CheckFunc {
+    return func(ctx context.Context) error {
+        var got int64
+        err := q.QueryRow(ctx, `SELECT COALESCE(MAX(version_id), 0) FROM goose_db_version WHERE is_applied`).Scan(&got)
+        if err != nil { return err }
+        if got < want { return fmt.Errorf("pending migrations: database=%d code=%d", got, want) }
+        return nil
+    }
+}
+
+// HTTPCheck GET url，2xx/3xx 视为可用（对象存储、AI 服务等外部依赖的健康端点）。client 为 nil 时使用 http.DefaultClient。
+func HTTPCheck(client *http.Client, url string) CheckFunc {
+    if client == nil { client = http.DefaultClient }
+    return func(ctx context.Context) error {
+        req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+        if err != nil { return err }
+        resp, err := client.Do(req)
+        if err != nil { return err }
+        defer resp.Body.Close()
+        if resp.StatusCode >= 400 { return fmt.Errorf("unexpected status %d", resp.StatusCode) }
+        return nil
+    }
+}
@@ -0,0 +1,86 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/stretchr/testify/require"
+)
+
+func ok(ctx context.Context) error   { return nil }
+func fail(ctx context.Context) error { return errors.New("down") }
+
+func TestRegistryStatus(t *testing.T) {
+    r := NewRegistry(time.Second)
+    r.Register("postgres", ok)
+    rep := r.Check(context.Background())
+    require.Equal(t, StatusOK, rep.Status)
+    require.True(t, rep.Ready())
+
+    r.RegisterOptional("ai", fail)
+    rep = r.Check(context.Background())
+    require.Equal(t, StatusDegraded, rep.Status)
+    require.True(t, rep.Ready(), "optional failure keeps instance ready")
+    require.Equal(t, "ai", rep.Checks[0].Name)
+    require.Equal(t, "down", rep.Checks[0].Error)
+    require.False(t, rep.Checks[0].Critical)
+
+    r.Register("objectstore", fail)
+    rep = r.Check(context.Background())
+    require.Equal(t, StatusFail, rep.Status)
+    require.False(t, rep.Ready())
+
+    r.SetOptional("objectstore")
+    require.Equal(t, StatusDegraded, r.Check(context.Background()).Status)
+
+    r.Drain()
+    rep = r.Check(context.Background())
+    require.Equal(t, StatusDraining, rep.Status)
+    require.False(t, rep.Ready())
+}
+
+func TestRegistryTimeoutAndPanic(t *testing.T) {
+    r := NewRegistry(20 * time.Millisecond)
+    r.Register("stuck", func(ctx context.Context) error { time.Sleep(time.Second); return nil }) // 不遵守 ctx
+    r.Register("broken", func(ctx context.Context) error { panic("boom") })
+    start := time.Now()
+    rep := r.Check(context.Background())
+    require.Less(t, time.Since(start), 500*time.Millisecond)
+    require.Equal(t, StatusFail, rep.Status)
+    require.Equal(t, "panic: boom", rep.Checks[0].Error)
+    require.Equal(t, context.DeadlineExceeded.Error(), rep.Checks[1].Error)
+    require.GreaterOrEqual(t, rep.Checks[1].LatencyMs, 20.0)
+}
+
+type fakeRow struct{ v int64; err error }
+
+func (f fakeRow) Scan(dest ...any) error {
+    if f.err != nil { return f.err }
+    *dest[0].(*int64) = f.v
+    return nil
+}
+
+type fakeQuerier struct{ row fakeRow }
+
+func (f fakeQuerier) QueryRow(ctx context.Context, sql string, args ...any) pgx.Row { return f.row }
+
+func TestMigrationCheck(t *testing.T) {
+    require.NoError(t, MigrationCheck(fakeQuerier{fakeRow{v: 15}}, 15)(context.Background()))
+    require.NoError(t, MigrationCheck(fakeQuerier{fakeRow{v: 16}}, 15)(context.Background()), "newer schema during rolling deploy")
+    require.ErrorContains(t, MigrationCheck(fakeQuerier{fakeRow{v: 14}}, 15)(context.Background()), "pending migrations")
+    require.Error(t, MigrationCheck(fakeQuerier{fakeRow{err: errors.New("no table")}}, 15)(context.Background()))
+}
+
+func TestHTTPCheck(t *testing.T) {
+    srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        if r.URL.Path == "/bad" { w.WriteHeader(http.StatusServiceUnavailable) }
+    }))
+    defer srv.Close()
+    require.NoError(t, HTTPCheck(srv.Client(), srv.URL+"/ok")(context.Background()))
+    require.ErrorContains(t, HTTPCheck(srv.Client(), srv.URL+"/bad")(context.Background()), "503")
+}
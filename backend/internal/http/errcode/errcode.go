@@ -14,6 +14,66 @@ const (
     CodeInvalidStatus      = "INVALID_STATUS"
     CodeInvalidTransition  = "INVALID_TRANSITION"
     CodeConflict           = "CONFLICT"
+    // MFA
+    CodeMFAInvalidCode      = "INVALID_MFA_CODE"
+    CodeMFAInvalidToken     = "INVALID_MFA_TOKEN"
+    CodeMFANotEnrolled      = "MFA_NOT_ENROLLED"
+    CodeMFAAlreadyEnabled   = "MFA_ALREADY_ENABLED"
+    CodeMFARequiredByPolicy = "MFA_REQUIRED_BY_POLICY"
+    // 登录失败限制
+    CodeLoginLocked         = "LOGIN_LOCKED"
+    CodeLoginThrottled      = "LOGIN_THROTTLED"
+    CodeLockoutNotFound     = "LOCKOUT_NOT_FOUND"
+    // API Token
+    CodeAPITokenNotFound    = "API_TOKEN_NOT_FOUND"
+    CodeAPITokenScope       = "INVALID_TOKEN_SCOPE"
+    CodeAPITokenExpiry      = "INVALID_TOKEN_EXPIRY"
+    // 用户资料 / 密码
+    CodeEmailTaken           = "EMAIL_TAKEN"
+    CodeInvalidEmail         = "INVALID_EMAIL"
+    CodeWrongPassword        = "WRONG_PASSWORD"
+    CodeNoLocalPassword      = "NO_LOCAL_PASSWORD"
+    CodeInvalidUserToken     = "INVALID_OR_EXPIRED_TOKEN"
+    CodeMailDisabled         = "MAIL_DISABLED"
+    CodeNoEmail              = "NO_EMAIL"
+    CodeEmailAlreadyVerified = "EMAIL_ALREADY_VERIFIED"
+    // 批量导入
+    CodeImportInvalid = "IMPORT_VALIDATION_FAILED"
+    CodeInvalidCSV    = "INVALID_CSV"
+    // 限流
+    CodeRateLimited = "RATE_LIMITED"
+    // Idempotency-Key
+    CodeInvalidIdempotencyKey = "INVALID_IDEMPOTENCY_KEY"
+    CodeIdempotencyKeyReused  = "IDEMPOTENCY_KEY_REUSED"
+    CodeIdempotencyInProgress = "IDEMPOTENCY_IN_PROGRESS"
+    // 实时通道
+    CodeInvalidTopic    = "INVALID_TOPIC"
+    CodePayloadTooLarge = "PAYLOAD_TOO_LARGE"
+    // 运行时参数
+    CodeInvalidSetting   = "INVALID_SETTING"
+    CodeSettingsConflict = "SETTINGS_VERSION_CONFLICT"
+    // 功能开关
+    CodeFeatureDisabled     = "FEATURE_DISABLED"
+    CodeFeatureFlagNotFound = "FEATURE_FLAG_NOT_FOUND"
+    CodeFeatureFlagExists   = "FEATURE_FLAG_EXISTS"
+    CodeInvalidFeatureFlag  = "INVALID_FEATURE_FLAG"
+    // 列表查询参数（分页 / 过滤 / 排序）
+    CodeInvalidQuery = "INVALID_QUERY"
+    // 题目元数据与标签
+    CodeInvalidProblem = "INVALID_PROBLEM"
+    CodeUnknownTag     = "UNKNOWN_TAG"
+    CodeInvalidTag     = "INVALID_TAG"
+    CodeTagNotFound    = "TAG_NOT_FOUND"
+    CodeTagExists      = "TAG_EXISTS"
+    CodeRevisionNotFound = "REVISION_NOT_FOUND"
+    CodeReviewCommentRequired = "REVIEW_COMMENT_REQUIRED"
+    CodePublishedProblemLocked = "PUBLISHED_PROBLEM_LOCKED"
+    // AI 服务
+    CodeAIUnavailable = "AI_UNAVAILABLE"
+    CodeAIBadResponse = "AI_BAD_RESPONSE"
+    CodeInvalidDetectionScope = "INVALID_DETECTION_SCOPE"
+    // 未捕获 panic
+    CodeInternal = "INTERNAL_ERROR"
 )
 
 // Msg 提供默认错误消息，可在 handler 中覆盖
@@ -29,6 +89,52 @@ var Msg = map[string]string{
     CodeInvalidStatus:      "invalid status value",
     CodeInvalidTransition:  "invalid status transition",
     CodeConflict:           "conflict",
+    CodeMFAInvalidCode:      "invalid or reused verification code",
+    CodeMFAInvalidToken:     "invalid or expired mfa token",
+    CodeMFANotEnrolled:      "mfa not enrolled",
+    CodeMFAAlreadyEnabled:   "mfa already enabled",
+    CodeMFARequiredByPolicy: "mfa is required for this account",
+    CodeLoginLocked:         "too many failed attempts, temporarily locked",
+    CodeLoginThrottled:      "too many failed attempts, retry later",
+    CodeLockoutNotFound:     "no lockout record",
+    CodeAPITokenNotFound:    "api token not found",
+    CodeAPITokenScope:       "permissions must be a non-empty subset of your own",
+    CodeAPITokenExpiry:      "expires_in_days must be between 1 and 366",
+    CodeEmailTaken:           "email already in use",
+    CodeInvalidEmail:         "invalid email address",
+    CodeWrongPassword:        "current password is incorrect",
+    CodeNoLocalPassword:      "account has no local password (directory login)",
+    CodeInvalidUserToken:     "token invalid, expired or already used",
+    CodeMailDisabled:         "mail delivery not configured",
+    CodeNoEmail:              "no email address on account",
+    CodeEmailAlreadyVerified: "email already verified",
+    CodeImportInvalid:        "import has invalid rows; nothing was created",
+    CodeInvalidCSV:           "invalid csv",
+    CodeRateLimited:          "too many requests, retry later",
+    CodeInvalidIdempotencyKey: "Idempotency-Key must be at most 255 characters",
+    CodeIdempotencyKeyReused:  "Idempotency-Key already used with a different request",
+    CodeIdempotencyInProgress: "a request with this Idempotency-Key is still in progress",
+    CodeInvalidTopic:          "invalid or unpublishable topic",
+    CodePayloadTooLarge:       "request body too large",
+    CodeInvalidSetting:        "unknown runtime setting or invalid value",
+    CodeSettingsConflict:      "runtime settings changed since the given version; reload and retry",
+    CodeFeatureDisabled:       "feature not available",
+    CodeFeatureFlagNotFound:   "feature flag not found",
+    CodeFeatureFlagExists:     "feature flag already exists",
+    CodeInvalidFeatureFlag:    "invalid feature flag",
+    CodeInvalidQuery:          "invalid pagination, filter or sort parameters",
+    CodeInvalidProblem:        "invalid problem fields",
+    CodeUnknownTag:            "problem references unknown tags",
+    CodeInvalidTag:            "invalid tag name",
+    CodeTagNotFound:           "problem tag not found",
+    CodeTagExists:             "problem tag already exists",
+    CodeRevisionNotFound:      "problem revision not found",
+    CodeReviewCommentRequired: "a comment is required when rejecting a problem",
+    CodePublishedProblemLocked: "changing the statement of a published problem requires problem.review",
+    CodeAIUnavailable:         "ai service unavailable, try again later",
+    CodeAIBadResponse:         "ai service returned an unusable response",
+    CodeInvalidDetectionScope: "scope must be problem with a non-empty id",
+    CodeInternal:              "internal server error",
 }
 
 func Text(code string) string {
@@ -0,0 +1,51 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/YangYuS8/codyssey/backend/internal/http/errcode"
+	"github.com/YangYuS8/codyssey/backend/internal/repository"
+	"github.com/YangYuS8/codyssey/backend/internal/service"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+type AIDetectionSettingRequest struct {
+    Enabled *bool `json:"enabled" binding:"required"`
+}
+
+// GetProblemAIReport 单题 AI 代码检测报告：data.summary 为汇总，data.items 为分页明细（过滤排序见 repository.SubmissionAICheckListSchema）。
+func GetProblemAIReport(s *service.AIDetectionService) gin.HandlerFunc {
+    return func(c *gin.Context) {
+        id, err := uuid.Parse(c.Param("id"))
+        if err != nil { respondError(c, http.StatusBadRequest, errcode.CodeInvalidID, "invalid uuid"); return }
+        spec, ok := parseListQuery(c, repository.SubmissionAICheckListSchema)
+        if !ok { return }
+        sum, items, next, err := s.ProblemReport(c.Request.Context(), id.String(), spec)
+        if err != nil { respondError(c, http.StatusInternalServerError, errcode.CodeListFailed, err.Error()); return }
+        respondOK(c, gin.H{"summary": sum, "items": items}, listMeta(spec, len(items), next))
+    }
+}
+
+// ListAIDetectionSettings 全部题目 / 比赛检测开关。
+func ListAIDetectionSettings(s *service.AIDetectionService) gin.HandlerFunc {
+    return func(c *gin.Context) {
+        list, err := s.ListSettings(c.Request.Context())
+        if err != nil { respondError(c, http.StatusInternalServerError, errcode.CodeListFailed, err.Error()); return }
+        respondOK(c, list, nil)
+    }
+}
+
+// SetAIDetectionSetting 开启或关闭 /ai-detection/settings/:scope/:id 的检测，只影响之后的提交。
+func SetAIDetectionSetting(s *service.AIDetectionService) gin.HandlerFunc {
+    return func(c *gin.Context) {
+        var req AIDetectionSettingRequest
+        if err := c.ShouldBindJSON(&req); err != nil { respondError(c, http.StatusBadRequest, "INVALID_BODY", err.Error()); return }
+        st, err := s.SetSetting(c.Request.Context(), c.Param("scope"), c.Param("id"), *req.Enabled, identityUserID(c))
+        if err != nil {
+            if err == service.ErrInvalidDetectionScope { respondError(c, http.StatusBadRequest, errcode.CodeInvalidDetectionScope, errcode.Text(errcode.CodeInvalidDetectionScope)); return }
+            respondError(c, http.StatusInternalServerError, "UPDATE_FAILED", err.Error()); return
+        }
+        respondOK(c, st, nil)
+    }
+}
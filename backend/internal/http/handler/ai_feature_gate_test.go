@@ -0,0 +1,61 @@
+package handler_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+
+	"github.com/YangYuS8/codyssey/backend/internal/aiclient"
+	"github.com/YangYuS8/codyssey/backend/internal/auth"
+	"github.com/YangYuS8/codyssey/backend/internal/featureflag"
+	"github.com/YangYuS8/codyssey/backend/internal/http/router"
+	"github.com/YangYuS8/codyssey/backend/internal/repository"
+	"github.com/YangYuS8/codyssey/backend/internal/service"
+)
+
+// 未放量时 AI 路由对有权限的教师也返回 404 FEATURE_DISABLED；开关打开后按权限正常处理。
+func TestAIRoutesRequireFeatureFlag(t *testing.T) {
+    client, err := aiclient.New(aiclient.Options{BaseURL: "http://127.0.0.1:1"})
+    require.NoError(t, err)
+    problems := repository.NewMemoryProblemRepository()
+    det := service.NewAIDetectionService(repository.NewMemorySubmissionAICheckRepository(), client, service.AIDetectionOptions{})
+    flags := featureflag.NewService(featureflag.NewMemoryStore(), featureflag.Options{})
+    srv := httptest.NewServer(router.Setup(router.Dependencies{
+        JWTSecret: "test-secret", ProblemRepo: problems, ProblemReviewRepo: problems, AIClient: client,
+        SubmissionRepo: repository.NewMemorySubmissionRepository(), AIDetection: det, FeatureFlags: flags,
+    }))
+    t.Cleanup(srv.Close)
+    teacher := makeTokenList(t, "test-secret", "teacher1", []string{auth.RoleTeacher}, nil)
+    student := makeTokenList(t, "test-secret", "stu1", []string{auth.RoleStudent}, nil)
+
+    routes := []struct {
+        method, path string
+        body         any
+    }{
+        {http.MethodPost, "/problems/generate", gin.H{"prompt": "x"}},
+        {http.MethodGet, "/ai-detection/settings", nil},
+        {http.MethodPut, "/ai-detection/settings/problem/" + uuid.NewString(), gin.H{"enabled": true}},
+        {http.MethodGet, "/problems/" + uuid.NewString() + "/ai-report", nil},
+    }
+    for _, rt := range routes {
+        code, body := aiCheckReq(t, srv, rt.method, rt.path, teacher, rt.body)
+        require.Equal(t, http.StatusNotFound, code, rt.path)
+        require.Contains(t, string(body), "FEATURE_DISABLED", rt.path)
+    }
+
+    // 只对教师放量检测：学生仍看不到功能，教师按权限访问
+    _, err = flags.Create(context.Background(), featureflag.Flag{Key: featureflag.KeyAIDetection, Enabled: true, Roles: []string{auth.RoleTeacher}}, "admin")
+    require.NoError(t, err)
+    code, body := aiCheckReq(t, srv, http.MethodGet, "/ai-detection/settings", teacher, nil)
+    require.Equal(t, http.StatusOK, code, string(body))
+    code, body = aiCheckReq(t, srv, http.MethodGet, "/ai-detection/settings", student, nil)
+    require.Equal(t, http.StatusNotFound, code)
+    require.Contains(t, string(body), "FEATURE_DISABLED")
+    code, _ = aiCheckReq(t, srv, http.MethodPost, "/problems/generate", teacher, gin.H{"prompt": "x"})
+    require.Equal(t, http.StatusNotFound, code)
+}
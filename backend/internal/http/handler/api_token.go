@@ -0,0 +1,79 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/YangYuS8/codyssey/backend/internal/auth"
+	"github.com/YangYuS8/codyssey/backend/internal/http/errcode"
+	"github.com/gin-gonic/gin"
+)
+
+type APITokenHandlers struct { Service *auth.APITokenService }
+
+func NewAPITokenHandlers(s *auth.APITokenService) *APITokenHandlers { return &APITokenHandlers{Service: s} }
+
+type issueAPITokenRequest struct {
+    Name          string   `json:"name"`
+    Kind          string   `json:"kind"`        // personal（默认）| service
+    Permissions   []string `json:"permissions"`
+    ExpiresInDays int      `json:"expires_in_days"` // 0 表示默认 90 天
+}
+
+func respondAPITokenError(c *gin.Context, err error) {
+    switch {
+    case errors.Is(err, auth.ErrAPITokenForbidden):
+        respondError(c, http.StatusForbidden, errcode.CodeForbidden, err.Error())
+    case errors.Is(err, auth.ErrAPITokenNotFound):
+        respondError(c, http.StatusNotFound, errcode.CodeAPITokenNotFound, errcode.Text(errcode.CodeAPITokenNotFound))
+    case errors.Is(err, auth.ErrAPITokenScope):
+        respondError(c, http.StatusBadRequest, errcode.CodeAPITokenScope, errcode.Text(errcode.CodeAPITokenScope))
+    case errors.Is(err, auth.ErrAPITokenExpiry):
+        respondError(c, http.StatusBadRequest, errcode.CodeAPITokenExpiry, errcode.Text(errcode.CodeAPITokenExpiry))
+    default:
+        respondError(c, http.StatusBadRequest, "API_TOKEN_FAILED", err.Error())
+    }
+}
+
+func tokenCaller(c *gin.Context) (*auth.Identity, bool) {
+    id := auth.GetIdentity(c)
+    if id == nil || id.UserID == "" || id.UserID == "guest" {
+        respondError(c, http.StatusUnauthorized, errcode.CodeUnauthorized, errcode.Text(errcode.CodeUnauthorized))
+        return nil, false
+    }
+    return id, true
+}
+
+// Issue POST /auth/tokens：明文令牌仅在响应中出现一次。
+func (h *APITokenHandlers) Issue(c *gin.Context) {
+    id, ok := tokenCaller(c)
+    if !ok { return }
+    var req issueAPITokenRequest
+    if err := c.ShouldBindJSON(&req); err != nil { respondError(c, http.StatusBadRequest, "INVALID_BODY", err.Error()); return }
+    if req.ExpiresInDays < 0 { respondAPITokenError(c, auth.ErrAPITokenExpiry); return }
+    perms := make([]auth.Permission, 0, len(req.Permissions))
+    for _, p := range req.Permissions { perms = append(perms, auth.Permission(p)) }
+    out, err := h.Service.Issue(c, id, auth.IssueAPITokenInput{
+        Name: req.Name, Kind: req.Kind, Permissions: perms, TTL: time.Duration(req.ExpiresInDays) * 24 * time.Hour,
+    })
+    if err != nil { respondAPITokenError(c, err); return }
+    respondCreated(c, out)
+}
+
+// List GET /auth/tokens[?all=true]
+func (h *APITokenHandlers) List(c *gin.Context) {
+    id, ok := tokenCaller(c)
+    if !ok { return }
+    list, err := h.Service.List(c, id, c.Query("all") == "true")
+    if err != nil { respondAPITokenError(c, err); return }
+    respondOK(c, list, gin.H{"count": len(list)})
+}
+
+// Revoke DELETE /auth/tokens/:id
+func (h *APITokenHandlers) Revoke(c *gin.Context) {
+    id, ok := tokenCaller(c)
+    if !ok { return }
+    if err := h.Service.Revoke(c, id, c.Param("id")); err != nil { respondAPITokenError(c, err); return }
+    c.Status(http.StatusNoContent)
+}
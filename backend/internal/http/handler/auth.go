@@ -1,10 +1,14 @@
 package handler
 
 import (
+	"errors"
+	"math"
 	"net/http"
+	"strconv"
 	"strings"
 
 	"github.com/YangYuS8/codyssey/backend/internal/auth"
+	"github.com/YangYuS8/codyssey/backend/internal/http/errcode"
 	"github.com/gin-gonic/gin"
 )
 
@@ -30,7 +34,7 @@ type refreshRequest struct {
 func (h *AuthHandlers) Register(c *gin.Context) {
     var req registerRequest
     if err := c.ShouldBindJSON(&req); err != nil { respondError(c, http.StatusBadRequest, "INVALID_BODY", err.Error()); return }
-    user, tokens, err := h.Service.Register(c, req.Username, req.Password, req.Roles)
+    res, err := h.Service.Register(c, req.Username, req.Password, req.Roles)
     if err != nil {
         switch err {
         case auth.ErrUsernameTaken:
@@ -42,19 +46,24 @@ func (h *AuthHandlers) Register(c *gin.Context) {
         }
         return
     }
-    respondCreated(c, gin.H{"user": user, "tokens": tokens})
+    respondCreated(c, res)
 }
 
 func (h *AuthHandlers) Login(c *gin.Context) {
     var req loginRequest
     if err := c.ShouldBindJSON(&req); err != nil { respondError(c, http.StatusBadRequest, "INVALID_BODY", err.Error()); return }
-    user, tokens, err := h.Service.Authenticate(c, req.Username, req.Password)
+    res, err := h.Service.LoginFrom(c, req.Username, req.Password, c.ClientIP())
     if err != nil {
         if err == auth.ErrInvalidLogin { respondError(c, http.StatusUnauthorized, "INVALID_CREDENTIALS", err.Error()); return }
+        var blocked *auth.LoginBlockedError
+        if errors.As(err, &blocked) { respondLoginBlocked(c, blocked); return }
+        // 目录不可达等内部原因已由 service 记录日志，不返回给客户端
+        if errors.Is(err, auth.ErrLoginUnavailable) { respondError(c, http.StatusServiceUnavailable, "LOGIN_UNAVAILABLE", auth.ErrLoginUnavailable.Error()); return }
         respondError(c, http.StatusBadRequest, "LOGIN_FAILED", err.Error())
         return
     }
-    respondOK(c, gin.H{"user": user, "tokens": tokens}, nil)
+    // 需要二次验证时 data.tokens 为空、data.mfa 携带待验证令牌
+    respondOK(c, res, nil)
 }
 
 func (h *AuthHandlers) Refresh(c *gin.Context) {
@@ -66,3 +75,37 @@ func (h *AuthHandlers) Refresh(c *gin.Context) {
     if err != nil { respondError(c, http.StatusUnauthorized, "INVALID_REFRESH", err.Error()); return }
     respondOK(c, gin.H{"tokens": pair}, nil)
 }
+
+// respondLoginBlocked 429 + Retry-After（秒，向上取整）。
+func respondLoginBlocked(c *gin.Context, e *auth.LoginBlockedError) {
+    c.Header("Retry-After", strconv.Itoa(int(math.Ceil(e.RetryAfter.Seconds()))))
+    code := errcode.CodeLoginThrottled
+    if e.Locked { code = errcode.CodeLoginLocked }
+    respondError(c, http.StatusTooManyRequests, code, errcode.Text(code))
+}
+
+// ListLockouts GET /auth/lockouts
+func (h *AuthHandlers) ListLockouts(c *gin.Context) {
+    list, err := h.Service.ListLockouts(c)
+    if err != nil { respondError(c, http.StatusInternalServerError, errcode.CodeListFailed, err.Error()); return }
+    respondOK(c, list, gin.H{"count": len(list)})
+}
+
+// UnlockUsername DELETE /auth/lockouts/users/:username
+func (h *AuthHandlers) UnlockUsername(c *gin.Context) {
+    h.respondUnlock(c, h.Service.UnlockUsername(c, c.Param("username")))
+}
+
+// UnlockIP DELETE /auth/lockouts/ips/:ip
+func (h *AuthHandlers) UnlockIP(c *gin.Context) {
+    h.respondUnlock(c, h.Service.UnlockIP(c, c.Param("ip")))
+}
+
+func (h *AuthHandlers) respondUnlock(c *gin.Context, err error) {
+    if err != nil {
+        if errors.Is(err, auth.ErrLockoutNotFound) { respondError(c, http.StatusNotFound, errcode.CodeLockoutNotFound, errcode.Text(errcode.CodeLockoutNotFound)); return }
+        respondError(c, http.StatusInternalServerError, "UNLOCK_FAILED", err.Error())
+        return
+    }
+    c.Status(http.StatusNoContent)
+}
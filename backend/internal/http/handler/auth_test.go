@@ -2,6 +2,7 @@ package handler
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
@@ -73,3 +74,46 @@ func TestAuth_Register_Login_Refresh(t *testing.T) {
     r.ServeHTTP(w5, req5)
     require.Equal(t, 200, w5.Code, w5.Body.String())
 }
+
+func TestAuth_LoginLockoutAndUnlock(t *testing.T) {
+    gin.SetMode(gin.TestMode)
+    mem := repository.NewMemoryUserRepository()
+    svc := auth.NewAuthService(mem, auth.NewJWTManager("test-secret", 2*time.Minute, time.Hour))
+    policy := auth.DefaultLockoutPolicy()
+    policy.UsernameMaxFailures, policy.FreeAttempts = 2, 5
+    svc.EnableLockout(repository.NewMemoryLoginAttemptRepository(), policy)
+    _, err := svc.Register(context.Background(), "bob", "secret123", []string{"student"})
+    require.NoError(t, err)
+    h := NewAuthHandlers(svc)
+    r := gin.New()
+    r.POST("/auth/login", h.Login)
+    r.GET("/auth/lockouts", h.ListLockouts)
+    r.DELETE("/auth/lockouts/users/:username", h.UnlockUsername)
+
+    login := func(password string) *httptest.ResponseRecorder {
+        b, _ := json.Marshal(map[string]any{"username": "bob", "password": password})
+        req, _ := http.NewRequest(http.MethodPost, "/auth/login", bytes.NewReader(b))
+        req.Header.Set("Content-Type", "application/json")
+        w := httptest.NewRecorder(); r.ServeHTTP(w, req)
+        return w
+    }
+    require.Equal(t, 401, login("bad").Code)
+    require.Equal(t, 401, login("bad").Code)
+    w := login("secret123")
+    require.Equal(t, 429, w.Code, w.Body.String())
+    require.Contains(t, w.Body.String(), "LOGIN_LOCKED")
+    require.NotEmpty(t, w.Header().Get("Retry-After"))
+
+    wl := httptest.NewRecorder()
+    r.ServeHTTP(wl, httptest.NewRequest(http.MethodGet, "/auth/lockouts", nil))
+    require.Equal(t, 200, wl.Code)
+    require.Contains(t, wl.Body.String(), "user:bob")
+
+    wu := httptest.NewRecorder()
+    r.ServeHTTP(wu, httptest.NewRequest(http.MethodDelete, "/auth/lockouts/users/bob", nil))
+    require.Equal(t, 204, wu.Code)
+    wu2 := httptest.NewRecorder()
+    r.ServeHTTP(wu2, httptest.NewRequest(http.MethodDelete, "/auth/lockouts/users/bob", nil))
+    require.Equal(t, 404, wu2.Code)
+    require.Equal(t, 200, login("secret123").Code)
+}
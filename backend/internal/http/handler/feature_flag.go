@@ -0,0 +1,90 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/YangYuS8/codyssey/backend/internal/auth"
+	"github.com/YangYuS8/codyssey/backend/internal/featureflag"
+	"github.com/YangYuS8/codyssey/backend/internal/http/errcode"
+	"github.com/YangYuS8/codyssey/backend/internal/http/middleware"
+	"github.com/gin-gonic/gin"
+)
+
+type FeatureFlagHandlers struct { Service *featureflag.Service }
+
+func NewFeatureFlagHandlers(s *featureflag.Service) *FeatureFlagHandlers { return &FeatureFlagHandlers{Service: s} }
+
+type featureFlagRequest struct {
+    Key         string   `json:"key"` // 仅创建时使用；更新以路径为准
+    Description string   `json:"description"`
+    Enabled     bool     `json:"enabled"`
+    Roles       []string `json:"roles"`
+    Users       []string `json:"users"`
+    Percentage  int      `json:"percentage"`
+}
+
+func (r featureFlagRequest) flag() featureflag.Flag {
+    return featureflag.Flag{Key: r.Key, Description: r.Description, Enabled: r.Enabled, Roles: r.Roles, Users: r.Users, Percentage: r.Percentage}
+}
+
+func respondFeatureFlagError(c *gin.Context, err error) {
+    switch {
+    case errors.Is(err, featureflag.ErrNotFound):
+        respondError(c, http.StatusNotFound, errcode.CodeFeatureFlagNotFound, errcode.Text(errcode.CodeFeatureFlagNotFound))
+    case errors.Is(err, featureflag.ErrExists):
+        respondError(c, http.StatusConflict, errcode.CodeFeatureFlagExists, errcode.Text(errcode.CodeFeatureFlagExists))
+    case errors.Is(err, featureflag.ErrInvalid):
+        respondError(c, http.StatusBadRequest, errcode.CodeInvalidFeatureFlag, err.Error())
+    default:
+        respondError(c, http.StatusInternalServerError, errcode.CodeInternal, err.Error())
+    }
+}
+
+func flagActor(c *gin.Context) string {
+    if id := auth.GetIdentity(c); id != nil { return id.UserID }
+    return ""
+}
+
+// Evaluate GET /features：当前身份在全部开关上的求值结果（前端据此展示入口）。
+func (h *FeatureFlagHandlers) Evaluate(c *gin.Context) {
+    respondOK(c, h.Service.Evaluate(c.Request.Context(), middleware.FlagSubject(c)), nil)
+}
+
+// List GET /admin/feature-flags
+func (h *FeatureFlagHandlers) List(c *gin.Context) {
+    list, err := h.Service.List(c.Request.Context())
+    if err != nil { respondFeatureFlagError(c, err); return }
+    respondOK(c, list, gin.H{"count": len(list)})
+}
+
+// Get GET /admin/feature-flags/:key
+func (h *FeatureFlagHandlers) Get(c *gin.Context) {
+    f, err := h.Service.Get(c.Request.Context(), c.Param("key"))
+    if err != nil { respondFeatureFlagError(c, err); return }
+    respondOK(c, f, nil)
+}
+
+// Create POST /admin/feature-flags
+func (h *FeatureFlagHandlers) Create(c *gin.Context) {
+    var req featureFlagRequest
+    if err := c.ShouldBindJSON(&req); err != nil { respondError(c, http.StatusBadRequest, "INVALID_BODY", err.Error()); return }
+    f, err := h.Service.Create(c.Request.Context(), req.flag(), flagActor(c))
+    if err != nil { respondFeatureFlagError(c, err); return }
+    respondCreated(c, f)
+}
+
+// Update PUT /admin/feature-flags/:key：整体替换规则。
+func (h *FeatureFlagHandlers) Update(c *gin.Context) {
+    var req featureFlagRequest
+    if err := c.ShouldBindJSON(&req); err != nil { respondError(c, http.StatusBadRequest, "INVALID_BODY", err.Error()); return }
+    f, err := h.Service.Update(c.Request.Context(), c.Param("key"), req.flag(), flagActor(c))
+    if err != nil { respondFeatureFlagError(c, err); return }
+    respondOK(c, f, nil)
+}
+
+// Delete DELETE /admin/feature-flags/:key
+func (h *FeatureFlagHandlers) Delete(c *gin.Context) {
+    if err := h.Service.Delete(c.Request.Context(), c.Param("key"), flagActor(c)); err != nil { respondFeatureFlagError(c, err); return }
+    c.Status(http.StatusNoContent)
+}
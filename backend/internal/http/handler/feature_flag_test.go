@@ -0,0 +1,103 @@
+package handler
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/require"
+
+	"github.com/YangYuS8/codyssey/backend/internal/auth"
+	"github.com/YangYuS8/codyssey/backend/internal/featureflag"
+	"github.com/YangYuS8/codyssey/backend/internal/http/middleware"
+)
+
+func buildFeatureFlagRouter() *gin.Engine {
+    gin.SetMode(gin.TestMode)
+    svc := featureflag.NewService(featureflag.NewMemoryStore(), featureflag.Options{})
+    r := gin.New()
+    r.Use(func(c *gin.Context) {
+        id := &auth.Identity{UserID: c.GetHeader("X-Test-User"), Permissions: map[auth.Permission]struct{}{}}
+        if id.UserID == "" { id.UserID = "guest" }
+        if roles := c.GetHeader("X-Test-Roles"); roles != "" { id.Roles = strings.Split(roles, ",") }
+        if c.GetHeader("X-Test-Admin") == "1" { id.Permissions[auth.PermSystemManage] = struct{}{} }
+        c.Set("__identity", id)
+        c.Next()
+    })
+    fh := NewFeatureFlagHandlers(svc)
+    r.GET("/features", fh.Evaluate)
+    admin := r.Group("/admin/feature-flags", auth.Require(auth.PermSystemManage))
+    admin.GET("", fh.List)
+    admin.POST("", fh.Create)
+    admin.GET("/:key", fh.Get)
+    admin.PUT("/:key", fh.Update)
+    admin.DELETE("/:key", fh.Delete)
+    // 整组门控
+    beta := r.Group("/beta", middleware.RequireFeature(svc, "beta_api"))
+    beta.GET("/ping", func(c *gin.Context) { c.String(http.StatusOK, "pong") })
+    return r
+}
+
+func doFlag(r *gin.Engine, method, path, body string, headers map[string]string) *httptest.ResponseRecorder {
+    req := httptest.NewRequest(method, path, bytes.NewBufferString(body))
+    req.Header.Set("Content-Type", "application/json")
+    for k, v := range headers { req.Header.Set(k, v) }
+    w := httptest.NewRecorder()
+    r.ServeHTTP(w, req)
+    return w
+}
+
+func TestFeatureFlagCRUDAndGating(t *testing.T) {
+    r := buildFeatureFlagRouter()
+    admin := map[string]string{"X-Test-User": "admin1", "X-Test-Admin": "1"}
+    teacher := map[string]string{"X-Test-User": "t1", "X-Test-Roles": "teacher"}
+    student := map[string]string{"X-Test-User": "s1", "X-Test-Roles": "student"}
+
+    require.Equal(t, http.StatusForbidden, doFlag(r, http.MethodGet, "/admin/feature-flags", "", teacher).Code)
+    // 未定义的开关：门控路由不可见
+    w := doFlag(r, http.MethodGet, "/beta/ping", "", teacher)
+    require.Equal(t, http.StatusNotFound, w.Code)
+    require.Contains(t, w.Body.String(), "FEATURE_DISABLED")
+
+    w = doFlag(r, http.MethodPost, "/admin/feature-flags", `{"key":"beta_api","enabled":true,"roles":["teacher"],"users":["s-allow"]}`, admin)
+    require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+    var created struct{ Data featureflag.Flag `json:"data"` }
+    require.NoError(t, json.Unmarshal(w.Body.Bytes(), &created))
+    require.Equal(t, "admin1", created.Data.UpdatedBy)
+
+    require.Equal(t, http.StatusConflict, doFlag(r, http.MethodPost, "/admin/feature-flags", `{"key":"beta_api"}`, admin).Code)
+    require.Equal(t, http.StatusBadRequest, doFlag(r, http.MethodPost, "/admin/feature-flags", `{"key":"x","percentage":150}`, admin).Code)
+
+    require.Equal(t, http.StatusOK, doFlag(r, http.MethodGet, "/beta/ping", "", teacher).Code)
+    require.Equal(t, http.StatusNotFound, doFlag(r, http.MethodGet, "/beta/ping", "", student).Code)
+    require.Equal(t, http.StatusOK, doFlag(r, http.MethodGet, "/beta/ping", "", map[string]string{"X-Test-User": "s-allow"}).Code)
+
+    w = doFlag(r, http.MethodGet, "/features", "", teacher)
+    require.Equal(t, http.StatusOK, w.Code)
+    require.JSONEq(t, `{"beta_api":true}`, string(mustData(t, w)))
+
+    w = doFlag(r, http.MethodPut, "/admin/feature-flags/beta_api", `{"enabled":false}`, admin)
+    require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+    require.Equal(t, http.StatusNotFound, doFlag(r, http.MethodGet, "/beta/ping", "", teacher).Code)
+    require.Equal(t, http.StatusNotFound, doFlag(r, http.MethodPut, "/admin/feature-flags/missing", `{}`, admin).Code)
+
+    w = doFlag(r, http.MethodGet, "/admin/feature-flags", "", admin)
+    require.Equal(t, http.StatusOK, w.Code)
+    require.Contains(t, w.Body.String(), `"count":1`)
+
+    require.Equal(t, http.StatusNoContent, doFlag(r, http.MethodDelete, "/admin/feature-flags/beta_api", "", admin).Code)
+    w = doFlag(r, http.MethodGet, "/admin/feature-flags/beta_api", "", admin)
+    require.Equal(t, http.StatusNotFound, w.Code)
+    require.Contains(t, w.Body.String(), "FEATURE_FLAG_NOT_FOUND")
+}
+
+func mustData(t *testing.T, w *httptest.ResponseRecorder) json.RawMessage {
+    t.Helper()
+    var env struct{ Data json.RawMessage `json:"data"` }
+    require.NoError(t, json.Unmarshal(w.Body.Bytes(), &env))
+    return env.Data
+}
@@ -3,6 +3,7 @@ package handler
 import (
 	"net/http"
 
+	"github.com/YangYuS8/codyssey/backend/internal/health"
 	"github.com/gin-gonic/gin"
 )
 
@@ -28,3 +29,21 @@ func Health(version, env string, hc HealthChecker) gin.HandlerFunc {
         c.JSON(http.StatusOK, resp)
     }
 }
+
+// Livez 存活探针：进程能处理请求即 200，不检查依赖（依赖故障不应触发重启）。
+func Livez() gin.HandlerFunc {
+    return func(c *gin.Context) { c.JSON(http.StatusOK, gin.H{"status": health.StatusOK}) }
+}
+
+// Readyz 就绪探针：执行注册的依赖检查，关键检查失败或停机排空时返回 503。
+// reg 为 nil 时总是就绪。
+func Readyz(reg *health.Registry) gin.HandlerFunc {
+    return func(c *gin.Context) {
+        rep := health.Report{Status: health.StatusOK, Checks: []health.Result{}}
+        if reg != nil { rep = reg.Check(c.Request.Context()) }
+        code := http.StatusOK
+        if !rep.Ready() { code = http.StatusServiceUnavailable }
+        c.Header("Cache-Control", "no-store")
+        c.JSON(code, rep)
+    }
+}
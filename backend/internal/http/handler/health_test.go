@@ -1,10 +1,15 @@
 package handler_test
 
 import (
+	"context"
+	"errors"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/YangYuS8/codyssey/backend/internal/health"
 	"github.com/YangYuS8/codyssey/backend/internal/http/handler"
 	"github.com/gin-gonic/gin"
 )
@@ -33,3 +38,32 @@ func TestHealth_Down(t *testing.T) {
 	r.ServeHTTP(w, req)
 	if w.Code != http.StatusOK { t.Fatalf("expected 200 got %d", w.Code) }
 }
+
+func TestLivez(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.GET("/livez", handler.Livez())
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/livez", nil))
+	if w.Code != http.StatusOK { t.Fatalf("expected 200 got %d", w.Code) }
+}
+
+func TestReadyz(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	reg := health.NewRegistry(time.Second)
+	up := true
+	reg.Register("postgres", func(ctx context.Context) error { if up { return nil }; return errors.New("down") })
+	r := gin.New()
+	r.GET("/readyz", handler.Readyz(reg))
+	get := func() *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+		return w
+	}
+	if w := get(); w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `"name":"postgres"`) { t.Fatalf("expected ready: %d %s", w.Code, w.Body.String()) }
+	up = false
+	if w := get(); w.Code != http.StatusServiceUnavailable || !strings.Contains(w.Body.String(), `"error":"down"`) { t.Fatalf("expected 503: %d %s", w.Code, w.Body.String()) }
+	up = true
+	reg.Drain()
+	if w := get(); w.Code != http.StatusServiceUnavailable || !strings.Contains(w.Body.String(), `"status":"draining"`) { t.Fatalf("expected draining: %d %s", w.Code, w.Body.String()) }
+}
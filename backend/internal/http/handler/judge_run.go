@@ -2,7 +2,6 @@ package handler
 
 import (
 	"net/http"
-	"strconv"
 	"strings"
 	"time"
 
@@ -95,14 +94,13 @@ func ListJudgeRuns(judgeSvc *service.JudgeRunHTTPAdapter, subSvc *service.Submis
             respondError(c, http.StatusForbidden, "FORBIDDEN", "not owner")
             return
         }
-        limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
-        offset, _ := strconv.Atoi(c.DefaultQuery("offset", "0"))
-        runs, err := judgeSvc.ListBySubmission(c.Request.Context(), submissionID, limit, offset)
+        spec, ok := parseListQuery(c, repository.JudgeRunListSchema)
+        if !ok { return }
+        runs, next, err := judgeSvc.ListBySubmission(c.Request.Context(), submissionID, spec)
         if err != nil { respondError(c, http.StatusInternalServerError, errcode.CodeListFailed, err.Error()); return }
         out := make([]JudgeRunResponse, 0, len(runs))
         for _, r := range runs { out = append(out, toJudgeRunResponse(r)) }
-        meta := map[string]int{"limit":limit, "offset":offset, "count":len(out)}
-        respondOK(c, out, meta)
+        respondOK(c, out, listMeta(spec, len(out), next))
     }
 }
 
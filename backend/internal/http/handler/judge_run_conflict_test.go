@@ -5,7 +5,6 @@ import (
 	"context"
 	"net/http"
 	"net/http/httptest"
-	"os"
 	"sync"
 	"sync/atomic"
 	"testing"
@@ -15,6 +14,7 @@ import (
 
 	"github.com/YangYuS8/codyssey/backend/internal/auth"
 	"github.com/YangYuS8/codyssey/backend/internal/domain"
+	"github.com/YangYuS8/codyssey/backend/internal/listquery"
 	"github.com/YangYuS8/codyssey/backend/internal/http/router"
 	"github.com/YangYuS8/codyssey/backend/internal/repository"
 )
@@ -34,7 +34,7 @@ func (r *conflictStartRepo) GetByID(_ context.Context, id string) (domain.JudgeR
     if n == 2 { close(r.barrier) }
     return r.run, nil
 }
-func (r *conflictStartRepo) ListBySubmission(_ context.Context, subID string, _, _ int) ([]domain.JudgeRun, error) { if r.run.SubmissionID == subID { return []domain.JudgeRun{r.run}, nil }; return []domain.JudgeRun{}, nil }
+func (r *conflictStartRepo) ListBySubmission(_ context.Context, subID string, _ listquery.Spec) ([]domain.JudgeRun, string, error) { if r.run.SubmissionID == subID { return []domain.JudgeRun{r.run}, "", nil }; return []domain.JudgeRun{}, "", nil }
 func (r *conflictStartRepo) UpdateRunning(_ context.Context, id string) error {
     if r.run.ID != id { return repository.ErrJudgeRunNotFound }
     <-r.barrier // 等待两个并发读取都完成
@@ -59,7 +59,7 @@ func (r *conflictFinishRepo) GetByID(_ context.Context, id string) (domain.Judge
     if n == 2 { close(r.barrier) }
     return r.run, nil
 }
-func (r *conflictFinishRepo) ListBySubmission(_ context.Context, subID string, _, _ int) ([]domain.JudgeRun, error) { if r.run.SubmissionID == subID { return []domain.JudgeRun{r.run}, nil }; return []domain.JudgeRun{}, nil }
+func (r *conflictFinishRepo) ListBySubmission(_ context.Context, subID string, _ listquery.Spec) ([]domain.JudgeRun, string, error) { if r.run.SubmissionID == subID { return []domain.JudgeRun{r.run}, "", nil }; return []domain.JudgeRun{}, "", nil }
 func (r *conflictFinishRepo) UpdateRunning(_ context.Context, id string) error { return repository.ErrJudgeRunNotFound }
 func (r *conflictFinishRepo) UpdateFinished(_ context.Context, id string, status string, runtimeMS, memoryKB, exitCode int, errMsg string) error {
     if r.run.ID != id { return repository.ErrJudgeRunNotFound }
@@ -71,10 +71,9 @@ func (r *conflictFinishRepo) UpdateFinished(_ context.Context, id string, status
 
 // --- 测试：并发 Start 冲突 ---
 func TestJudgeRun_Start_Conflict(t *testing.T) {
-    os.Setenv("JWT_SECRET", "test-secret")
     jr := domain.JudgeRun{ID: "jr-start-1", SubmissionID: "sub-jr-1", Status: domain.JudgeRunStatusQueued, CreatedAt: time.Now().UTC(), UpdatedAt: time.Now().UTC()}
     repo := newConflictStartRepo(jr)
-    deps := router.Dependencies{JudgeRunRepo: repo, SubmissionRepo: repository.NewMemorySubmissionRepository(), Env: "test"}
+    deps := router.Dependencies{JWTSecret: "test-secret", JudgeRunRepo: repo, SubmissionRepo: repository.NewMemorySubmissionRepository(), Env: "test"}
     // 放一条 submission 以通过 Enqueue 前置校验（直接创建）
     _ = deps.SubmissionRepo.Create(context.Background(), domain.Submission{ID: "sub-jr-1", UserID: "u1", ProblemID: "p", Language: "go", Code: "print", Status: "pending", CreatedAt: time.Now().UTC(), UpdatedAt: time.Now().UTC(), Version:1})
     r := router.Setup(deps)
@@ -103,11 +102,10 @@ func TestJudgeRun_Start_Conflict(t *testing.T) {
 
 // --- 测试：并发 Finish 冲突 ---
 func TestJudgeRun_Finish_Conflict(t *testing.T) {
-    os.Setenv("JWT_SECRET", "test-secret")
     started := time.Now().Add(-2 * time.Second).UTC()
     jr := domain.JudgeRun{ID: "jr-finish-1", SubmissionID: "sub-jr-2", Status: domain.JudgeRunStatusRunning, StartedAt: &started, CreatedAt: started, UpdatedAt: started}
     repo := newConflictFinishRepo(jr)
-    deps := router.Dependencies{JudgeRunRepo: repo, SubmissionRepo: repository.NewMemorySubmissionRepository(), Env: "test"}
+    deps := router.Dependencies{JWTSecret: "test-secret", JudgeRunRepo: repo, SubmissionRepo: repository.NewMemorySubmissionRepository(), Env: "test"}
     _ = deps.SubmissionRepo.Create(context.Background(), domain.Submission{ID: "sub-jr-2", UserID: "u1", ProblemID: "p", Language: "go", Code: "print", Status: "pending", CreatedAt: time.Now().UTC(), UpdatedAt: time.Now().UTC(), Version:1})
     r := router.Setup(deps)
     srv := httptest.NewServer(r); defer srv.Close()
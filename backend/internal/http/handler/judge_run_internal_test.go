@@ -35,7 +35,7 @@ func buildInternalJudgeRunRouter() (*gin.Engine, *memoryJudgeRunRepo, *service.S
     sub := domain.Submission{ID: "subx", UserID: "u1", ProblemID: "p1", Language: "go", Code: "print", Status: service.SubmissionStatusPending, CreatedAt: time.Now().UTC(), UpdatedAt: time.Now().UTC(), Version:1}
     _ = subRepo.Create(context.Background(), sub)
     logRepo := &memoryStatusLogRepo{}
-    subSvc := service.NewSubmissionService(subRepo, logRepo)
+    subSvc := service.NewSubmissionService(subRepo, logRepo, service.SubmissionOptions{})
 
     jrRepo := newMemoryJudgeRunRepo()
     jrSvc := service.NewJudgeRunService(jrRepo)
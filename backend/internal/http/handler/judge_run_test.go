@@ -14,7 +14,7 @@ import (
 
 	"github.com/YangYuS8/codyssey/backend/internal/auth"
 	"github.com/YangYuS8/codyssey/backend/internal/domain"
-	"github.com/YangYuS8/codyssey/backend/internal/repository"
+	"github.com/YangYuS8/codyssey/backend/internal/listquery"
 	"github.com/YangYuS8/codyssey/backend/internal/service"
 )
 
@@ -33,28 +33,24 @@ func (m *memorySubmissionRepo) UpdateStatus(ctx context.Context, id string, stat
     m.items[id] = v
     return nil
 }
-func (m *memorySubmissionRepo) List(ctx context.Context, f repository.SubmissionFilter, limit, offset int) ([]domain.Submission, error) {
+func (m *memorySubmissionRepo) List(ctx context.Context, spec listquery.Spec) ([]domain.Submission, string, error) {
     res := make([]domain.Submission,0)
-    for _, v := range m.items {
-        if f.UserID != "" && v.UserID != f.UserID { continue }
-        if f.ProblemID != "" && v.ProblemID != f.ProblemID { continue }
-        if f.Status != "" && v.Status != f.Status { continue }
-        res = append(res, v)
-    }
-    return res, nil
+    for _, v := range m.items { res = append(res, v) }
+    return res, "", nil
 }
-func (m *memorySubmissionRepo) Count(ctx context.Context, f repository.SubmissionFilter) (int, error) { list, _ := m.List(ctx, f, 0, 0); return len(list), nil }
+func (m *memorySubmissionRepo) Count(ctx context.Context, spec listquery.Spec) (int, error) { return len(m.items), nil }
 
 type memoryStatusLogRepo struct{ logs []domain.SubmissionStatusLog }
 func (m *memoryStatusLogRepo) Add(ctx context.Context, l domain.SubmissionStatusLog) error { m.logs = append(m.logs, l); return nil }
-func (m *memoryStatusLogRepo) ListBySubmission(ctx context.Context, submissionID string, limit, offset int) ([]domain.SubmissionStatusLog, error) { out := []domain.SubmissionStatusLog{}; for _, l := range m.logs { if l.SubmissionID == submissionID { out = append(out, l) } }; return out, nil }
+func (m *memoryStatusLogRepo) ListBySubmission(ctx context.Context, submissionID string, spec listquery.Spec) ([]domain.SubmissionStatusLog, string, error) { out := []domain.SubmissionStatusLog{}; for _, l := range m.logs { if l.SubmissionID == submissionID { out = append(out, l) } }; return out, "", nil }
+func (m *memoryStatusLogRepo) ListSince(ctx context.Context, since time.Time, submissionID string, limit int) ([]domain.SubmissionStatusLog, error) { return nil, nil }
 
 // memoryJudgeRunRepo 直接复用 service.JudgeRunRepo 接口需要的方法
 type memoryJudgeRunRepo struct { items map[string]domain.JudgeRun }
 func newMemoryJudgeRunRepo() *memoryJudgeRunRepo { return &memoryJudgeRunRepo{items: map[string]domain.JudgeRun{}} }
 func (m *memoryJudgeRunRepo) Create(ctx context.Context, jr domain.JudgeRun) error { m.items[jr.ID] = jr; return nil }
 func (m *memoryJudgeRunRepo) GetByID(ctx context.Context, id string) (domain.JudgeRun, error) { v, ok := m.items[id]; if !ok { return domain.JudgeRun{}, service.ErrJudgeRunNotFound }; return v, nil }
-func (m *memoryJudgeRunRepo) ListBySubmission(ctx context.Context, submissionID string, limit, offset int) ([]domain.JudgeRun, error) { out := []domain.JudgeRun{}; for _, v := range m.items { if v.SubmissionID == submissionID { out = append(out, v) } }; return out, nil }
+func (m *memoryJudgeRunRepo) ListBySubmission(ctx context.Context, submissionID string, spec listquery.Spec) ([]domain.JudgeRun, string, error) { out := []domain.JudgeRun{}; for _, v := range m.items { if v.SubmissionID == submissionID { out = append(out, v) } }; return out, "", nil }
 func (m *memoryJudgeRunRepo) UpdateRunning(ctx context.Context, id string) error { v, ok := m.items[id]; if !ok { return service.ErrJudgeRunNotFound }; if v.Status != domain.JudgeRunStatusQueued { return service.ErrJudgeRunInvalidStatus }; now := time.Now().UTC(); v.Status = domain.JudgeRunStatusRunning; v.StartedAt = &now; v.UpdatedAt = now; m.items[id] = v; return nil }
 func (m *memoryJudgeRunRepo) UpdateFinished(ctx context.Context, id string, status string, runtimeMS, memoryKB, exitCode int, errMsg string) error { v, ok := m.items[id]; if !ok { return service.ErrJudgeRunNotFound }; if v.Status != domain.JudgeRunStatusRunning { return service.ErrJudgeRunInvalidStatus }; now := time.Now().UTC(); v.Status = status; v.RuntimeMS = runtimeMS; v.MemoryKB = memoryKB; v.ExitCode = exitCode; v.ErrorMessage = errMsg; v.FinishedAt = &now; v.UpdatedAt = now; m.items[id] = v; return nil }
 
@@ -79,7 +75,7 @@ func buildJudgeRunTestRouter(userID string, subOwnedUser string) *gin.Engine {
     s := domain.Submission{ID: "sub1", UserID: subOwnedUser, ProblemID: "p1", Language: "go", Code: "print", Status: "pending", CreatedAt: time.Now().UTC(), UpdatedAt: time.Now().UTC(), Version:1}
     _ = subRepo.Create(context.Background(), s)
     logRepo := &memoryStatusLogRepo{}
-    subSvc := service.NewSubmissionService(subRepo, logRepo)
+    subSvc := service.NewSubmissionService(subRepo, logRepo, service.SubmissionOptions{})
 
     jrRepo := newMemoryJudgeRunRepo()
     jrSvc := service.NewJudgeRunService(jrRepo)
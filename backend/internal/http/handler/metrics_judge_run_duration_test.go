@@ -6,7 +6,6 @@ import (
 	"io"
 	"net/http"
 	"net/http/httptest"
-	"os"
 	"strings"
 	"testing"
 	"time"
@@ -21,10 +20,9 @@ import (
 
 // TestMetrics_JudgeRunDuration 验证 finish 后 /metrics 暴露 judge_run_duration_seconds
 func TestMetrics_JudgeRunDuration(t *testing.T) {
-    os.Setenv("JWT_SECRET", "test-secret")
     subRepo := repository.NewMemorySubmissionRepository()
     jrRepo := repository.NewMemoryJudgeRunRepository()
-    deps := router.Dependencies{SubmissionRepo: subRepo, JudgeRunRepo: jrRepo, Env: "test"}
+    deps := router.Dependencies{JWTSecret: "test-secret", SubmissionRepo: subRepo, JudgeRunRepo: jrRepo, Env: "test"}
     r := router.Setup(deps)
     srv := httptest.NewServer(r); defer srv.Close()
 
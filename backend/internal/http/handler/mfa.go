@@ -0,0 +1,114 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/YangYuS8/codyssey/backend/internal/auth"
+	"github.com/YangYuS8/codyssey/backend/internal/http/errcode"
+	"github.com/gin-gonic/gin"
+)
+
+// mfaRequest 各 MFA 端点共用：mfa_token 仅在登录中途（尚无访问令牌）时携带。
+type mfaRequest struct {
+    MFAToken     string `json:"mfa_token"`
+    Code         string `json:"code"`
+    RecoveryCode string `json:"recovery_code"`
+}
+
+// mfaSubject 优先使用 mfa_token（强制登记阶段），否则要求已登录身份。
+func (h *AuthHandlers) mfaSubject(c *gin.Context, req mfaRequest) (string, bool) {
+    if strings.TrimSpace(req.MFAToken) != "" {
+        uid, err := h.Service.ResolveMFAToken(req.MFAToken)
+        if err != nil { respondError(c, http.StatusUnauthorized, errcode.CodeMFAInvalidToken, errcode.Text(errcode.CodeMFAInvalidToken)); return "", false }
+        return uid, true
+    }
+    id := auth.GetIdentity(c)
+    if id == nil || id.UserID == "" || id.UserID == "guest" {
+        respondError(c, http.StatusUnauthorized, errcode.CodeUnauthorized, errcode.Text(errcode.CodeUnauthorized))
+        return "", false
+    }
+    return id.UserID, true
+}
+
+func respondMFAError(c *gin.Context, err error) {
+    switch {
+    case errors.Is(err, auth.ErrMFAInvalidCode):
+        respondError(c, http.StatusUnauthorized, errcode.CodeMFAInvalidCode, errcode.Text(errcode.CodeMFAInvalidCode))
+    case errors.Is(err, auth.ErrInvalidToken):
+        respondError(c, http.StatusUnauthorized, errcode.CodeMFAInvalidToken, errcode.Text(errcode.CodeMFAInvalidToken))
+    case errors.Is(err, auth.ErrMFANotEnrolled):
+        respondError(c, http.StatusBadRequest, errcode.CodeMFANotEnrolled, errcode.Text(errcode.CodeMFANotEnrolled))
+    case errors.Is(err, auth.ErrMFAAlreadyEnabled):
+        respondError(c, http.StatusConflict, errcode.CodeMFAAlreadyEnabled, errcode.Text(errcode.CodeMFAAlreadyEnabled))
+    case errors.Is(err, auth.ErrMFARequiredByPolicy):
+        respondError(c, http.StatusForbidden, errcode.CodeMFARequiredByPolicy, errcode.Text(errcode.CodeMFARequiredByPolicy))
+    default:
+        respondError(c, http.StatusInternalServerError, "MFA_FAILED", err.Error())
+    }
+}
+
+// MFAStatus GET /auth/mfa
+func (h *AuthHandlers) MFAStatus(c *gin.Context) {
+    uid, ok := h.mfaSubject(c, mfaRequest{})
+    if !ok { return }
+    st, err := h.Service.GetMFAStatus(c, uid)
+    if err != nil { respondMFAError(c, err); return }
+    respondOK(c, st, nil)
+}
+
+// MFAEnroll POST /auth/mfa/enroll：生成密钥与 otpauth URI。
+func (h *AuthHandlers) MFAEnroll(c *gin.Context) {
+    var req mfaRequest
+    _ = c.ShouldBindJSON(&req) // 已登录时可无 body
+    uid, ok := h.mfaSubject(c, req)
+    if !ok { return }
+    en, err := h.Service.BeginMFAEnrollment(c, uid)
+    if err != nil { respondMFAError(c, err); return }
+    respondOK(c, en, nil)
+}
+
+// MFAVerify POST /auth/mfa/verify：用首个验证码确认登记，返回恢复码；
+// 若携带 mfa_token（强制登记阶段）则同时签发令牌。
+func (h *AuthHandlers) MFAVerify(c *gin.Context) {
+    var req mfaRequest
+    if err := c.ShouldBindJSON(&req); err != nil { respondError(c, http.StatusBadRequest, "INVALID_BODY", err.Error()); return }
+    if strings.TrimSpace(req.MFAToken) != "" {
+        res, codes, err := h.Service.CompleteEnrollmentLogin(c, req.MFAToken, req.Code)
+        if err != nil { respondMFAError(c, err); return }
+        respondOK(c, gin.H{"user": res.User, "tokens": res.Tokens, "recovery_codes": codes}, nil)
+        return
+    }
+    uid, ok := h.mfaSubject(c, req)
+    if !ok { return }
+    codes, err := h.Service.ConfirmMFAEnrollment(c, uid, req.Code)
+    if err != nil { respondMFAError(c, err); return }
+    respondOK(c, gin.H{"recovery_codes": codes}, nil)
+}
+
+// MFAChallenge POST /auth/mfa/challenge：登录第二步。
+func (h *AuthHandlers) MFAChallenge(c *gin.Context) {
+    var req mfaRequest
+    if err := c.ShouldBindJSON(&req); err != nil { respondError(c, http.StatusBadRequest, "INVALID_BODY", err.Error()); return }
+    if strings.TrimSpace(req.MFAToken) == "" { respondError(c, http.StatusBadRequest, "MISSING_MFA_TOKEN", "mfa_token required"); return }
+    res, err := h.Service.CompleteMFALogin(c, req.MFAToken, req.Code, req.RecoveryCode)
+    if err != nil {
+        var blocked *auth.LoginBlockedError
+        if errors.As(err, &blocked) { respondLoginBlocked(c, blocked); return }
+        respondMFAError(c, err)
+        return
+    }
+    respondOK(c, res, nil)
+}
+
+// MFADisable POST /auth/mfa/disable
+func (h *AuthHandlers) MFADisable(c *gin.Context) {
+    var req mfaRequest
+    if err := c.ShouldBindJSON(&req); err != nil { respondError(c, http.StatusBadRequest, "INVALID_BODY", err.Error()); return }
+    req.MFAToken = "" // 关闭必须基于完整登录身份
+    uid, ok := h.mfaSubject(c, req)
+    if !ok { return }
+    if err := h.Service.DisableMFA(c, uid, req.Code, req.RecoveryCode); err != nil { respondMFAError(c, err); return }
+    respondOK(c, gin.H{"disabled": true}, nil)
+}
@@ -39,7 +39,7 @@ func buildMatrixRouter() (*gin.Engine, *memorySubmissionRepo, *memoryJudgeRunRep
     // memory repos
     subRepo := newMemorySubmissionRepo()
     logRepo := &memoryStatusLogRepo{}
-    subSvc := service.NewSubmissionService(subRepo, logRepo)
+    subSvc := service.NewSubmissionService(subRepo, logRepo, service.SubmissionOptions{})
 
     jrRepo := newMemoryJudgeRunRepo()
     jrSvc := service.NewJudgeRunService(jrRepo)
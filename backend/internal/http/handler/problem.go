@@ -1,9 +1,17 @@
 package handler
 
 import (
+	"errors"
+	"io"
 	"net/http"
 	"strconv"
+	"strings"
 
+	"github.com/YangYuS8/codyssey/backend/internal/aiclient"
+	"github.com/YangYuS8/codyssey/backend/internal/auth"
+	"github.com/YangYuS8/codyssey/backend/internal/domain"
+	"github.com/YangYuS8/codyssey/backend/internal/http/errcode"
+	"github.com/YangYuS8/codyssey/backend/internal/listquery"
 	"github.com/YangYuS8/codyssey/backend/internal/repository"
 	"github.com/YangYuS8/codyssey/backend/internal/service"
 	"github.com/gin-gonic/gin"
@@ -12,16 +20,24 @@ import (
 
 // 使用 service 层抽象，避免 handler 直接操作仓储
 type ProblemService interface {
-    Create(ctx any, title, desc string) (any, error)
+    Create(ctx any, in service.ProblemInput) (any, error)
     Get(ctx any, id uuid.UUID) (any, error)
-    Update(ctx any, id uuid.UUID, title *string, desc *string) (any, error)
+    Update(ctx any, id uuid.UUID, patch service.ProblemPatch) (any, error)
     Delete(ctx any, id uuid.UUID) error
-    List(ctx any, limit, offset int) ([]any, error)
+    List(ctx any, f repository.ProblemFilter, spec listquery.Spec) ([]any, string, error)
 }
 
 type ProblemCreateRequest struct {
-	Title       string `json:"title" binding:"required,min=3,max=100"`
-	Description string `json:"description" binding:"required,min=5"`
+	Title       string   `json:"title" binding:"required,min=3,max=100"`
+	Description string   `json:"description" binding:"required,min=5"`
+	Tags        []string `json:"tags"`
+	Difficulty  string   `json:"difficulty"`
+	Source      string   `json:"source"`
+	Visibility  string   `json:"visibility"`
+	TimeLimitMS   int    `json:"time_limit_ms"`   // 0 表示默认 1000
+	MemoryLimitMB int    `json:"memory_limit_mb"` // 0 表示默认 256
+	TestDataHash  string `json:"test_data_hash"`
+	Statement     domain.ProblemStatement `json:"statement"` // 背景、输入输出格式、样例、提示（Markdown）
 }
 
 func CreateProblem(s *service.ProblemService) gin.HandlerFunc {
@@ -31,8 +47,10 @@ func CreateProblem(s *service.ProblemService) gin.HandlerFunc {
 			respondError(c, http.StatusBadRequest, "INVALID_REQUEST", err.Error())
 			return
 		}
-		p, err := s.Create(c.Request.Context(), req.Title, req.Description)
+		p, err := s.Create(c.Request.Context(), service.ProblemInput{Title: req.Title, Description: req.Description, Tags: req.Tags, Difficulty: req.Difficulty, Source: req.Source, Visibility: req.Visibility,
+			TimeLimitMS: req.TimeLimitMS, MemoryLimitMB: req.MemoryLimitMB, TestDataHash: req.TestDataHash, Statement: req.Statement, AuthorID: identityUserID(c)})
 		if err != nil {
+			if respondProblemError(c, err) { return }
 			respondError(c, http.StatusInternalServerError, "CREATE_FAILED", err.Error())
 			return
 		}
@@ -40,17 +58,41 @@ func CreateProblem(s *service.ProblemService) gin.HandlerFunc {
 	}
 }
 
+// identityUserID 当前身份的用户 ID（记为修订作者），无身份时为空串。
+func identityUserID(c *gin.Context) string {
+	if id := auth.GetIdentity(c); id != nil { return id.UserID }
+	return ""
+}
+
+// canSeeHidden 具备 problem.update 或 problem.review 权限（教师 / 管理员）才能看到 private 或未发布的题目，
+// 学生与访客只能看到 public 且已发布的题目。
+func canSeeHidden(c *gin.Context) bool {
+	id := auth.GetIdentity(c)
+	return id != nil && (id.Has(auth.PermProblemUpdate) || id.Has(auth.PermProblemReview))
+}
+
+// canReview 调用者可审核题目（可直接修改已发布题目的题面）。
+func canReview(c *gin.Context) bool {
+	id := auth.GetIdentity(c)
+	return id != nil && id.Has(auth.PermProblemReview)
+}
+
+// ListProblems 列表：q 全文检索（标题 / 题面 / 出处，中文按字切分匹配），tags 逗号分隔且需全部具备，
+// 其余过滤与排序见 repository.ProblemListSchema（如 difficulty[in]=easy,medium）。
 func ListProblems(s *service.ProblemService) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
-		offset, _ := strconv.Atoi(c.DefaultQuery("offset", "0"))
-		items, err := s.List(c.Request.Context(), limit, offset)
+		spec, ok := parseListQuery(c, repository.ProblemListSchema)
+		if !ok { return }
+		f := repository.ProblemFilter{Query: c.Query("q")}
+		if raw := c.Query("tags"); strings.TrimSpace(raw) != "" { f.Tags = strings.Split(raw, ",") }
+		if !canSeeHidden(c) { f.Visibility, f.Status = domain.VisibilityPublic, domain.ProblemStatusPublished }
+		items, next, err := s.List(c.Request.Context(), f, spec)
 		if err != nil {
+			if errors.Is(err, service.ErrInvalidTag) { respondError(c, http.StatusBadRequest, errcode.CodeInvalidQuery, err.Error()); return }
 			respondError(c, http.StatusInternalServerError, "LIST_FAILED", err.Error())
 			return
 		}
-		meta := map[string]int{"limit": limit, "offset": offset, "count": len(items)}
-		respondOK(c, items, meta)
+		respondOK(c, items, listMeta(spec, len(items), next))
 	}
 }
 
@@ -64,13 +106,24 @@ func GetProblem(s *service.ProblemService) gin.HandlerFunc {
 			if err == repository.ErrNotFound { respondError(c, http.StatusNotFound, "NOT_FOUND", "problem not found"); return }
 			respondError(c, http.StatusInternalServerError, "GET_FAILED", err.Error()); return
 		}
+		// private 或未发布的题目对无权查看者表现为不存在
+		if p.Hidden() && !canSeeHidden(c) { respondError(c, http.StatusNotFound, "NOT_FOUND", "problem not found"); return }
 		respondOK(c, p, nil)
 	}
 }
 
 type ProblemUpdateRequest struct {
-	Title       *string `json:"title" binding:"omitempty,min=3,max=100"`
-	Description *string `json:"description" binding:"omitempty,min=5"`
+	Title       *string  `json:"title" binding:"omitempty,min=3,max=100"`
+	Description *string  `json:"description" binding:"omitempty,min=5"`
+	Tags        []string `json:"tags"` // 非 null 时整体替换（[] 清空）
+	Difficulty  *string  `json:"difficulty"`
+	Source      *string  `json:"source"`
+	Visibility  *string  `json:"visibility"`
+	TimeLimitMS   *int    `json:"time_limit_ms"`
+	MemoryLimitMB *int    `json:"memory_limit_mb"`
+	TestDataHash  *string `json:"test_data_hash"`
+	Statement     *domain.ProblemStatement `json:"statement"` // 非 null 时整体替换各分节
+	Message       string  `json:"message"` // 修改说明，题面有变化时记入新修订
 }
 
 func UpdateProblem(s *service.ProblemService) gin.HandlerFunc {
@@ -79,9 +132,11 @@ func UpdateProblem(s *service.ProblemService) gin.HandlerFunc {
 		if err != nil { respondError(c, http.StatusBadRequest, "INVALID_ID", "invalid uuid"); return }
 		var req ProblemUpdateRequest
 		if err := c.ShouldBindJSON(&req); err != nil { respondError(c, http.StatusBadRequest, "INVALID_REQUEST", err.Error()); return }
-		updated, err := s.Update(c.Request.Context(), id, req.Title, req.Description)
+		updated, err := s.Update(c.Request.Context(), id, service.ProblemPatch{Title: req.Title, Description: req.Description, Tags: req.Tags, Difficulty: req.Difficulty, Source: req.Source, Visibility: req.Visibility,
+			TimeLimitMS: req.TimeLimitMS, MemoryLimitMB: req.MemoryLimitMB, TestDataHash: req.TestDataHash, Statement: req.Statement, AuthorID: identityUserID(c), Message: req.Message, CanReview: canReview(c)})
 		if err != nil {
 			if err == repository.ErrNotFound { respondError(c, http.StatusNotFound, "NOT_FOUND", "problem not found"); return }
+			if respondProblemError(c, err) { return }
 			respondError(c, http.StatusInternalServerError, "UPDATE_FAILED", err.Error()); return
 		}
 		respondOK(c, updated, nil)
@@ -99,3 +154,231 @@ func DeleteProblem(s *service.ProblemService) gin.HandlerFunc {
 		respondOK(c, gin.H{"deleted": id.String()}, nil)
 	}
 }
+
+// respondProblemError 映射题目字段与标签校验错误，已处理时返回 true。
+func respondProblemError(c *gin.Context, err error) bool {
+	switch {
+	case errors.Is(err, service.ErrInvalidProblem):
+		respondError(c, http.StatusBadRequest, errcode.CodeInvalidProblem, err.Error())
+	case errors.Is(err, service.ErrInvalidTag):
+		respondError(c, http.StatusBadRequest, errcode.CodeInvalidTag, err.Error())
+	case errors.Is(err, service.ErrUnknownTag):
+		respondError(c, http.StatusBadRequest, errcode.CodeUnknownTag, err.Error())
+	case errors.Is(err, repository.ErrTagNotFound):
+		respondError(c, http.StatusNotFound, errcode.CodeTagNotFound, errcode.Text(errcode.CodeTagNotFound))
+	case errors.Is(err, repository.ErrTagExists):
+		respondError(c, http.StatusConflict, errcode.CodeTagExists, errcode.Text(errcode.CodeTagExists))
+	case errors.Is(err, repository.ErrProblemConflict):
+		respondError(c, http.StatusConflict, errcode.CodeConflict, err.Error())
+	case errors.Is(err, repository.ErrRevisionNotFound):
+		respondError(c, http.StatusNotFound, errcode.CodeRevisionNotFound, errcode.Text(errcode.CodeRevisionNotFound))
+	case errors.Is(err, service.ErrInvalidProblemTransition):
+		respondError(c, http.StatusBadRequest, errcode.CodeInvalidTransition, err.Error())
+	case errors.Is(err, service.ErrPublishedStatementLocked):
+		respondError(c, http.StatusForbidden, errcode.CodePublishedProblemLocked, errcode.Text(errcode.CodePublishedProblemLocked))
+	case errors.Is(err, service.ErrReviewCommentRequired):
+		respondError(c, http.StatusBadRequest, errcode.CodeReviewCommentRequired, errcode.Text(errcode.CodeReviewCommentRequired))
+	case errors.Is(err, aiclient.ErrUnavailable):
+		respondError(c, http.StatusServiceUnavailable, errcode.CodeAIUnavailable, errcode.Text(errcode.CodeAIUnavailable))
+	case errors.Is(err, aiclient.ErrBadResponse), errors.Is(err, service.ErrGeneratedProblemInvalid):
+		respondError(c, http.StatusBadGateway, errcode.CodeAIBadResponse, err.Error())
+	default:
+		return false
+	}
+	return true
+}
+
+type ProblemTagRequest struct {
+	Name        string  `json:"name"`
+	Description *string `json:"description"`
+}
+
+// ListProblemTags 全部标签（按名称排序），附引用该标签的题目数。
+func ListProblemTags(s *service.ProblemService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		tags, err := s.ListTags(c.Request.Context())
+		if err != nil { respondError(c, http.StatusInternalServerError, "LIST_FAILED", err.Error()); return }
+		respondOK(c, tags, gin.H{"count": len(tags)})
+	}
+}
+
+func CreateProblemTag(s *service.ProblemService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req ProblemTagRequest
+		if err := c.ShouldBindJSON(&req); err != nil { respondError(c, http.StatusBadRequest, "INVALID_REQUEST", err.Error()); return }
+		desc := ""
+		if req.Description != nil { desc = *req.Description }
+		t, err := s.CreateTag(c.Request.Context(), req.Name, desc)
+		if err != nil {
+			if respondProblemError(c, err) { return }
+			respondError(c, http.StatusInternalServerError, "CREATE_FAILED", err.Error())
+			return
+		}
+		respondCreated(c, t)
+	}
+}
+
+// UpdateProblemTag 修改描述或重命名（name 非空时），重命名同步到所有引用该标签的题目。
+func UpdateProblemTag(s *service.ProblemService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req ProblemTagRequest
+		if err := c.ShouldBindJSON(&req); err != nil { respondError(c, http.StatusBadRequest, "INVALID_REQUEST", err.Error()); return }
+		t, err := s.UpdateTag(c.Request.Context(), c.Param("name"), req.Name, req.Description)
+		if err != nil {
+			if respondProblemError(c, err) { return }
+			respondError(c, http.StatusInternalServerError, "UPDATE_FAILED", err.Error())
+			return
+		}
+		respondOK(c, t, nil)
+	}
+}
+
+// DeleteProblemTag 删除标签并从所有题目上移除。
+func DeleteProblemTag(s *service.ProblemService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		name := c.Param("name")
+		if err := s.DeleteTag(c.Request.Context(), name); err != nil {
+			if respondProblemError(c, err) { return }
+			respondError(c, http.StatusInternalServerError, "DELETE_FAILED", err.Error())
+			return
+		}
+		respondOK(c, gin.H{"deleted": name}, nil)
+	}
+}
+
+// parseRevisionNumber 解析路径或查询参数中的修订号（正整数）。
+func parseRevisionNumber(c *gin.Context, raw string) (int, bool) {
+	n, err := strconv.Atoi(raw)
+	if err != nil || n < 1 { respondError(c, http.StatusBadRequest, errcode.CodeInvalidID, "revision must be a positive integer"); return 0, false }
+	return n, true
+}
+
+// ListProblemRevisions 题目的修订历史，默认按修订号倒序，分页参数见 repository.ProblemRevisionListSchema。
+func ListProblemRevisions(s *service.ProblemService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, err := uuid.Parse(c.Param("id"))
+		if err != nil { respondError(c, http.StatusBadRequest, "INVALID_ID", "invalid uuid"); return }
+		spec, ok := parseListQuery(c, repository.ProblemRevisionListSchema)
+		if !ok { return }
+		revs, next, err := s.ListRevisions(c.Request.Context(), id, spec)
+		if err != nil {
+			if err == repository.ErrNotFound { respondError(c, http.StatusNotFound, "NOT_FOUND", "problem not found"); return }
+			respondError(c, http.StatusInternalServerError, "LIST_FAILED", err.Error()); return
+		}
+		respondOK(c, revs, listMeta(spec, len(revs), next))
+	}
+}
+
+func GetProblemRevision(s *service.ProblemService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, err := uuid.Parse(c.Param("id"))
+		if err != nil { respondError(c, http.StatusBadRequest, "INVALID_ID", "invalid uuid"); return }
+		n, ok := parseRevisionNumber(c, c.Param("number"))
+		if !ok { return }
+		rev, err := s.GetRevision(c.Request.Context(), id, n)
+		if err != nil {
+			if respondProblemError(c, err) { return }
+			respondError(c, http.StatusInternalServerError, "GET_FAILED", err.Error()); return
+		}
+		respondOK(c, rev, nil)
+	}
+}
+
+// DiffProblemRevisions 对比 ?from=&to= 两个修订；to 缺省时为题目当前修订。
+func DiffProblemRevisions(s *service.ProblemService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, err := uuid.Parse(c.Param("id"))
+		if err != nil { respondError(c, http.StatusBadRequest, "INVALID_ID", "invalid uuid"); return }
+		from, ok := parseRevisionNumber(c, c.Query("from"))
+		if !ok { return }
+		var to int
+		if raw := c.Query("to"); raw != "" {
+			if to, ok = parseRevisionNumber(c, raw); !ok { return }
+		} else {
+			p, err := s.Get(c.Request.Context(), id)
+			if err != nil {
+				if err == repository.ErrNotFound { respondError(c, http.StatusNotFound, "NOT_FOUND", "problem not found"); return }
+				respondError(c, http.StatusInternalServerError, "GET_FAILED", err.Error()); return
+			}
+			to = p.Revision
+		}
+		d, err := s.DiffRevisions(c.Request.Context(), id, from, to)
+		if err != nil {
+			if respondProblemError(c, err) { return }
+			respondError(c, http.StatusInternalServerError, "DIFF_FAILED", err.Error()); return
+		}
+		respondOK(c, d, nil)
+	}
+}
+
+// RollbackProblem 把题面恢复为指定修订，以新修订记录（历史不被改写），返回更新后的题目。
+func RollbackProblem(s *service.ProblemService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, err := uuid.Parse(c.Param("id"))
+		if err != nil { respondError(c, http.StatusBadRequest, "INVALID_ID", "invalid uuid"); return }
+		n, ok := parseRevisionNumber(c, c.Param("number"))
+		if !ok { return }
+		p, err := s.Rollback(c.Request.Context(), id, n, identityUserID(c), canReview(c))
+		if err != nil {
+			if err == repository.ErrNotFound { respondError(c, http.StatusNotFound, "NOT_FOUND", "problem not found"); return }
+			if respondProblemError(c, err) { return }
+			respondError(c, http.StatusInternalServerError, "ROLLBACK_FAILED", err.Error()); return
+		}
+		respondOK(c, p, nil)
+	}
+}
+
+type ProblemTransitionRequest struct {
+	Comment string `json:"comment"` // 审核意见 / 操作说明；驳回时必填
+}
+
+// TransitionProblem 执行发布审核流程动作 action（所需权限由路由按动作配置），请求体可省略。
+func TransitionProblem(s *service.ProblemService, action string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, err := uuid.Parse(c.Param("id"))
+		if err != nil { respondError(c, http.StatusBadRequest, "INVALID_ID", "invalid uuid"); return }
+		var req ProblemTransitionRequest
+		if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) { respondError(c, http.StatusBadRequest, "INVALID_REQUEST", err.Error()); return }
+		p, err := s.Transition(c.Request.Context(), id, action, identityUserID(c), req.Comment)
+		if err != nil {
+			if err == repository.ErrNotFound { respondError(c, http.StatusNotFound, "NOT_FOUND", "problem not found"); return }
+			if respondProblemError(c, err) { return }
+			respondError(c, http.StatusInternalServerError, "TRANSITION_FAILED", err.Error()); return
+		}
+		respondOK(c, p, nil)
+	}
+}
+
+// ListProblemStatusLogs 题目的状态流转审计日志（含审核意见），默认按时间正序。
+func ListProblemStatusLogs(s *service.ProblemService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, err := uuid.Parse(c.Param("id"))
+		if err != nil { respondError(c, http.StatusBadRequest, "INVALID_ID", "invalid uuid"); return }
+		spec, ok := parseListQuery(c, repository.ProblemStatusLogListSchema)
+		if !ok { return }
+		logs, next, err := s.ListStatusLogs(c.Request.Context(), id, spec)
+		if err != nil {
+			if err == repository.ErrNotFound { respondError(c, http.StatusNotFound, "NOT_FOUND", "problem not found"); return }
+			respondError(c, http.StatusInternalServerError, "LIST_FAILED", err.Error()); return
+		}
+		respondOK(c, logs, listMeta(spec, len(logs), next))
+	}
+}
+
+type ProblemGenerateRequest struct {
+	Prompt string `json:"prompt" binding:"required"` // 题目要求，如知识点、难度、背景设定
+}
+
+// GenerateProblem 调用 AI 服务按提示词生成题目，保存为草稿并记录来源（模型、提示词）。
+func GenerateProblem(s *service.ProblemService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req ProblemGenerateRequest
+		if err := c.ShouldBindJSON(&req); err != nil { respondError(c, http.StatusBadRequest, "INVALID_REQUEST", err.Error()); return }
+		p, err := s.Generate(c.Request.Context(), req.Prompt, identityUserID(c))
+		if err != nil {
+			if respondProblemError(c, err) { return }
+			respondError(c, http.StatusInternalServerError, "CREATE_FAILED", err.Error()); return
+		}
+		respondCreated(c, p)
+	}
+}
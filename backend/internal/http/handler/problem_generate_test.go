@@ -0,0 +1,79 @@
+package handler_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/YangYuS8/codyssey/backend/internal/aiclient"
+	"github.com/YangYuS8/codyssey/backend/internal/auth"
+	"github.com/YangYuS8/codyssey/backend/internal/domain"
+	"github.com/YangYuS8/codyssey/backend/internal/http/handler"
+	"github.com/YangYuS8/codyssey/backend/internal/repository"
+	"github.com/YangYuS8/codyssey/backend/internal/service"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/require"
+)
+
+const generatorPerms = teacherPerms + ",ai.generate"
+
+// setupProblemGenerateRouter AI 服务由 httptest 模拟：提示词 "down" 返回 503，"empty" 返回空标题。
+func setupProblemGenerateRouter(t *testing.T) *gin.Engine {
+	t.Helper()
+	ai := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var in struct{ Prompt string `json:"prompt"` }
+		_ = json.NewDecoder(r.Body).Decode(&in)
+		switch in.Prompt {
+		case "down":
+			http.Error(w, "overloaded", http.StatusServiceUnavailable)
+		case "empty":
+			_ = json.NewEncoder(w).Encode(gin.H{"problem_id": "stub", "title": "", "description": ""})
+		default:
+			_ = json.NewEncoder(w).Encode(gin.H{"problem_id": "stub", "title": "  Two Sum Variant  ", "description": "Given n numbers ...", "model": "stub-model"})
+		}
+	}))
+	t.Cleanup(ai.Close)
+	client, err := aiclient.New(aiclient.Options{BaseURL: ai.URL, MaxRetries: 1, RetryBackoff: 1})
+	require.NoError(t, err)
+
+	gin.SetMode(gin.TestMode)
+	repo := repository.NewMemoryProblemRepository()
+	ps := service.NewProblemService(repo)
+	ps.EnableReview(repo)
+	ps.EnableGenerator(client)
+	r := gin.New()
+	r.Use(auth.AttachDebugIdentity(""))
+	r.GET("/problems/:id", handler.GetProblem(ps))
+	r.POST("/problems/generate", auth.Require(auth.PermProblemCreate, auth.PermAIGenerate), handler.GenerateProblem(ps))
+	return r
+}
+
+func TestProblemGenerate(t *testing.T) {
+	r := setupProblemGenerateRouter(t)
+	w := doProblemReq(t, r, http.MethodPost, "/problems/generate", generatorPerms, gin.H{"prompt": " 两数之和的变体，难度简单 "})
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	p := decodeData[domain.Problem](t, w.Body.Bytes())
+	require.Equal(t, "Two Sum Variant", p.Title)
+	require.Equal(t, domain.ProblemStatusDraft, p.Status)
+	require.NotNil(t, p.Provenance)
+	require.Equal(t, domain.ProblemOriginAI, p.Provenance.Origin)
+	require.Equal(t, "stub-model", p.Provenance.Model)
+	require.Equal(t, "两数之和的变体，难度简单", p.Provenance.Prompt)
+	require.Equal(t, "guest", p.Provenance.RequestedBy)
+
+	// 草稿对学生不可见，教师读取时带来源信息
+	require.Equal(t, http.StatusNotFound, doProblemReq(t, r, http.MethodGet, "/problems/"+p.ID.String(), "", nil).Code)
+	w = doProblemReq(t, r, http.MethodGet, "/problems/"+p.ID.String(), teacherPerms, nil)
+	require.Equal(t, "stub-model", decodeData[domain.Problem](t, w.Body.Bytes()).Provenance.Model)
+
+	require.Equal(t, http.StatusForbidden, doProblemReq(t, r, http.MethodPost, "/problems/generate", teacherPerms, gin.H{"prompt": "x"}).Code)
+	require.Contains(t, doProblemReq(t, r, http.MethodPost, "/problems/generate", generatorPerms, gin.H{"prompt": "  "}).Body.String(), "INVALID_PROBLEM")
+
+	w = doProblemReq(t, r, http.MethodPost, "/problems/generate", generatorPerms, gin.H{"prompt": "down"})
+	require.Equal(t, http.StatusServiceUnavailable, w.Code)
+	require.Contains(t, w.Body.String(), "AI_UNAVAILABLE")
+	w = doProblemReq(t, r, http.MethodPost, "/problems/generate", generatorPerms, gin.H{"prompt": "empty"})
+	require.Equal(t, http.StatusBadGateway, w.Code)
+	require.Contains(t, w.Body.String(), "AI_BAD_RESPONSE")
+}
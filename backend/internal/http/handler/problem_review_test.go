@@ -0,0 +1,132 @@
+package handler_test
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/YangYuS8/codyssey/backend/internal/auth"
+	"github.com/YangYuS8/codyssey/backend/internal/domain"
+	"github.com/YangYuS8/codyssey/backend/internal/http/handler"
+	"github.com/YangYuS8/codyssey/backend/internal/repository"
+	"github.com/YangYuS8/codyssey/backend/internal/service"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/require"
+)
+
+const reviewerPerms = "problem.review,problem.archive"
+
+func setupProblemReviewRouter() *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	repo := repository.NewMemoryProblemRepository()
+	ps := service.NewProblemService(repo)
+	ps.EnableReview(repo)
+	ps.EnableRevisions(repo)
+	r := gin.New()
+	r.Use(auth.AttachDebugIdentity(""))
+	r.GET("/problems", handler.ListProblems(ps))
+	r.POST("/problems", auth.Require(auth.PermProblemCreate), handler.CreateProblem(ps))
+	r.GET("/problems/:id", handler.GetProblem(ps))
+	r.PUT("/problems/:id", auth.Require(auth.PermProblemUpdate), handler.UpdateProblem(ps))
+	r.POST("/problems/:id/submit", auth.Require(auth.PermProblemUpdate), handler.TransitionProblem(ps, service.ProblemActionSubmit))
+	r.POST("/problems/:id/withdraw", auth.Require(auth.PermProblemUpdate), handler.TransitionProblem(ps, service.ProblemActionWithdraw))
+	r.POST("/problems/:id/approve", auth.Require(auth.PermProblemReview), handler.TransitionProblem(ps, service.ProblemActionApprove))
+	r.POST("/problems/:id/reject", auth.Require(auth.PermProblemReview), handler.TransitionProblem(ps, service.ProblemActionReject))
+	r.POST("/problems/:id/archive", auth.Require(auth.PermProblemArchive), handler.TransitionProblem(ps, service.ProblemActionArchive))
+	r.POST("/problems/:id/restore", auth.Require(auth.PermProblemArchive), handler.TransitionProblem(ps, service.ProblemActionRestore))
+	r.GET("/problems/:id/status-logs", auth.Require(auth.PermProblemUpdate), handler.ListProblemStatusLogs(ps))
+	r.POST("/problems/:id/revisions/:number/rollback", auth.Require(auth.PermProblemUpdate), handler.RollbackProblem(ps))
+	return r
+}
+
+func TestProblemReview_Workflow(t *testing.T) {
+	r := setupProblemReviewRouter()
+	w := doProblemReq(t, r, http.MethodPost, "/problems", teacherPerms, gin.H{"title": "Draft One", "description": "statement"})
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	p := decodeData[domain.Problem](t, w.Body.Bytes())
+	require.Equal(t, domain.ProblemStatusDraft, p.Status)
+	base := "/problems/" + p.ID.String()
+
+	// 未发布题目对学生 / 访客不可见，教师可见
+	require.Equal(t, http.StatusNotFound, doProblemReq(t, r, http.MethodGet, base, "", nil).Code)
+	require.Empty(t, listProblemTitles(t, r, "", ""))
+	require.Equal(t, []string{"Draft One"}, listProblemTitles(t, r, "status=draft", teacherPerms))
+
+	// 非法流转与权限
+	w = doProblemReq(t, r, http.MethodPost, base+"/approve", reviewerPerms, nil)
+	require.Equal(t, http.StatusBadRequest, w.Code)
+	require.Contains(t, w.Body.String(), "INVALID_TRANSITION")
+	require.Equal(t, http.StatusOK, doProblemReq(t, r, http.MethodPost, base+"/submit", teacherPerms, nil).Code)
+	require.Equal(t, http.StatusForbidden, doProblemReq(t, r, http.MethodPost, base+"/approve", teacherPerms, nil).Code)
+	require.Equal(t, []string{"Draft One"}, listProblemTitles(t, r, "status=pending_review", reviewerPerms)) // 审核者可查看待审队列
+
+	// 驳回必须附意见
+	w = doProblemReq(t, r, http.MethodPost, base+"/reject", reviewerPerms, gin.H{"comment": "  "})
+	require.Contains(t, w.Body.String(), "REVIEW_COMMENT_REQUIRED")
+	w = doProblemReq(t, r, http.MethodPost, base+"/reject", reviewerPerms, gin.H{"comment": "样例缺少说明"})
+	require.Equal(t, domain.ProblemStatusDraft, decodeData[domain.Problem](t, w.Body.Bytes()).Status)
+
+	// 修改题面不影响状态，重新提交后通过
+	require.Equal(t, http.StatusOK, doProblemReq(t, r, http.MethodPut, base, teacherPerms, gin.H{"description": "statement with notes"}).Code)
+	require.Equal(t, http.StatusOK, doProblemReq(t, r, http.MethodPost, base+"/submit", teacherPerms, gin.H{"comment": "已补充"}).Code)
+	w = doProblemReq(t, r, http.MethodPost, base+"/approve", reviewerPerms, nil)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	require.Equal(t, domain.ProblemStatusPublished, decodeData[domain.Problem](t, w.Body.Bytes()).Status)
+	require.Equal(t, http.StatusOK, doProblemReq(t, r, http.MethodGet, base, "", nil).Code)
+	require.Equal(t, []string{"Draft One"}, listProblemTitles(t, r, "", ""))
+
+	// 下架后再次隐藏，恢复为草稿需重新审核
+	require.Equal(t, http.StatusOK, doProblemReq(t, r, http.MethodPost, base+"/archive", reviewerPerms, nil).Code)
+	require.Equal(t, http.StatusNotFound, doProblemReq(t, r, http.MethodGet, base, "", nil).Code)
+	require.Equal(t, http.StatusBadRequest, doProblemReq(t, r, http.MethodPost, base+"/submit", teacherPerms, nil).Code)
+	w = doProblemReq(t, r, http.MethodPost, base+"/restore", reviewerPerms, nil)
+	require.Equal(t, domain.ProblemStatusDraft, decodeData[domain.Problem](t, w.Body.Bytes()).Status)
+
+	w = doProblemReq(t, r, http.MethodGet, base+"/status-logs", teacherPerms, nil)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	logs := decodeData[[]domain.ProblemStatusLog](t, w.Body.Bytes())
+	actions := make([]string, 0, len(logs))
+	for _, l := range logs { actions = append(actions, l.Action) }
+	require.Equal(t, []string{"submit", "reject", "submit", "approve", "archive", "restore"}, actions)
+	require.Equal(t, "样例缺少说明", logs[1].Comment)
+	require.Equal(t, domain.ProblemStatusPendingReview, logs[1].FromStatus)
+	require.Equal(t, "guest", logs[1].ActorID)
+	require.Equal(t, http.StatusForbidden, doProblemReq(t, r, http.MethodGet, base+"/status-logs", "", nil).Code)
+	require.Equal(t, http.StatusNotFound, doProblemReq(t, r, http.MethodPost, "/problems/00000000-0000-0000-0000-000000000000/submit", teacherPerms, nil).Code)
+}
+
+// 已发布题目的题面修改立即对学生生效：出题人不能绕过审核直接改题面或回滚，审核者可以；元数据不受限。
+func TestProblemReview_PublishedStatementLocked(t *testing.T) {
+	r := setupProblemReviewRouter()
+	w := doProblemReq(t, r, http.MethodPost, "/problems", teacherPerms, gin.H{"title": "Live", "description": "statement v1"})
+	base := "/problems/" + decodeData[domain.Problem](t, w.Body.Bytes()).ID.String()
+	require.Equal(t, http.StatusOK, doProblemReq(t, r, http.MethodPut, base, teacherPerms, gin.H{"description": "statement v2"}).Code)
+	require.Equal(t, http.StatusOK, doProblemReq(t, r, http.MethodPost, base+"/submit", teacherPerms, nil).Code)
+	require.Equal(t, http.StatusOK, doProblemReq(t, r, http.MethodPost, base+"/approve", reviewerPerms, nil).Code)
+
+	w = doProblemReq(t, r, http.MethodPut, base, teacherPerms, gin.H{"description": "sneaky statement"})
+	require.Equal(t, http.StatusForbidden, w.Code)
+	require.Contains(t, w.Body.String(), "PUBLISHED_PROBLEM_LOCKED")
+	w = doProblemReq(t, r, http.MethodPut, base, teacherPerms, gin.H{"time_limit_ms": 50})
+	require.Equal(t, http.StatusForbidden, w.Code)
+	w = doProblemReq(t, r, http.MethodPost, base+"/revisions/1/rollback", teacherPerms, nil)
+	require.Equal(t, http.StatusForbidden, w.Code)
+	require.Equal(t, "statement v2", decodeData[domain.Problem](t, doProblemReq(t, r, http.MethodGet, base, "", nil).Body.Bytes()).Description)
+	require.Equal(t, http.StatusOK, doProblemReq(t, r, http.MethodPut, base, teacherPerms, gin.H{"difficulty": "hard"}).Code)
+
+	editor := teacherPerms + "," + reviewerPerms
+	w = doProblemReq(t, r, http.MethodPut, base, editor, gin.H{"description": "statement v3"})
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	p := decodeData[domain.Problem](t, w.Body.Bytes())
+	require.Equal(t, domain.ProblemStatusPublished, p.Status)
+	require.Equal(t, "statement v3", p.Description)
+	w = doProblemReq(t, r, http.MethodPost, base+"/revisions/1/rollback", editor, nil)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	require.Equal(t, "statement v1", decodeData[domain.Problem](t, w.Body.Bytes()).Description)
+}
+
+func TestProblemReview_DisabledPublishesImmediately(t *testing.T) {
+	r := setupProblemSearchRouter()
+	w := doProblemReq(t, r, http.MethodPost, "/problems", teacherPerms, gin.H{"title": "Open", "description": "statement"})
+	require.Equal(t, domain.ProblemStatusPublished, decodeData[domain.Problem](t, w.Body.Bytes()).Status)
+	require.Equal(t, []string{"Open"}, listProblemTitles(t, r, "", ""))
+}
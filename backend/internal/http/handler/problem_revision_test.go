@@ -0,0 +1,126 @@
+package handler_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/YangYuS8/codyssey/backend/internal/auth"
+	"github.com/YangYuS8/codyssey/backend/internal/domain"
+	"github.com/YangYuS8/codyssey/backend/internal/http/handler"
+	"github.com/YangYuS8/codyssey/backend/internal/repository"
+	"github.com/YangYuS8/codyssey/backend/internal/service"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/require"
+)
+
+func setupProblemRevisionRouter() *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	repo := repository.NewMemoryProblemRepository()
+	ps := service.NewProblemService(repo)
+	ps.EnableRevisions(repo)
+	r := gin.New()
+	r.Use(auth.AttachDebugIdentity(""))
+	r.POST("/problems", auth.Require(auth.PermProblemCreate), handler.CreateProblem(ps))
+	r.GET("/problems/:id", handler.GetProblem(ps))
+	r.PUT("/problems/:id", auth.Require(auth.PermProblemUpdate), handler.UpdateProblem(ps))
+	r.GET("/problems/:id/revisions", auth.Require(auth.PermProblemUpdate), handler.ListProblemRevisions(ps))
+	r.GET("/problems/:id/revisions/diff", auth.Require(auth.PermProblemUpdate), handler.DiffProblemRevisions(ps))
+	r.GET("/problems/:id/revisions/:number", auth.Require(auth.PermProblemUpdate), handler.GetProblemRevision(ps))
+	r.POST("/problems/:id/revisions/:number/rollback", auth.Require(auth.PermProblemUpdate), handler.RollbackProblem(ps))
+	return r
+}
+
+func decodeData[T any](t *testing.T, body []byte) T {
+	t.Helper()
+	var resp struct{ Data T }
+	require.NoError(t, json.Unmarshal(body, &resp))
+	return resp.Data
+}
+
+func TestProblemRevisions_HistoryDiffRollback(t *testing.T) {
+	r := setupProblemRevisionRouter()
+	w := doProblemReq(t, r, http.MethodPost, "/problems", teacherPerms, gin.H{"title": "A+B", "description": "line1\nline2\nline3", "time_limit_ms": 2000})
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	p := decodeData[domain.Problem](t, w.Body.Bytes())
+	require.Equal(t, 1, p.Revision)
+	require.Equal(t, 256, p.MemoryLimitMB)
+	base := "/problems/" + p.ID.String()
+
+	// 题面变化追加修订
+	w = doProblemReq(t, r, http.MethodPut, base, teacherPerms, gin.H{"description": "line1\nline two\nline3", "memory_limit_mb": 512, "message": "clarify input"})
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	require.Equal(t, 2, decodeData[domain.Problem](t, w.Body.Bytes()).Revision)
+	// 仅修改元数据不产生修订
+	w = doProblemReq(t, r, http.MethodPut, base, teacherPerms, gin.H{"difficulty": "easy"})
+	require.Equal(t, 2, decodeData[domain.Problem](t, w.Body.Bytes()).Revision)
+	w = doProblemReq(t, r, http.MethodPut, base, teacherPerms, gin.H{"test_data_hash": "not-a-hash"})
+	require.Contains(t, w.Body.String(), "INVALID_PROBLEM")
+
+	w = doProblemReq(t, r, http.MethodGet, base+"/revisions", teacherPerms, nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	revs := decodeData[[]domain.ProblemRevision](t, w.Body.Bytes())
+	require.Len(t, revs, 2)
+	require.Equal(t, 2, revs[0].Number)
+	require.Equal(t, "clarify input", revs[0].Message)
+	require.Equal(t, "guest", revs[0].AuthorID) // debug 身份未携带 JWT 时用户为 guest
+	require.Equal(t, http.StatusForbidden, doProblemReq(t, r, http.MethodGet, base+"/revisions", "", nil).Code)
+
+	w = doProblemReq(t, r, http.MethodGet, base+"/revisions/diff?from=1", teacherPerms, nil)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	d := decodeData[service.RevisionDiff](t, w.Body.Bytes())
+	require.Equal(t, 2, d.To)
+	require.Equal(t, "@@ -1,3 +1,3 @@\n line1\n-line2\n+line two\n line3\n", d.DescriptionDiff)
+	require.Equal(t, []service.FieldChange{{Field: "memory_limit_mb", From: float64(256), To: float64(512)}, {Field: "description"}}, d.Changes)
+	require.Equal(t, http.StatusNotFound, doProblemReq(t, r, http.MethodGet, base+"/revisions/diff?from=1&to=9", teacherPerms, nil).Code)
+	require.Equal(t, http.StatusBadRequest, doProblemReq(t, r, http.MethodGet, base+"/revisions/diff?from=x", teacherPerms, nil).Code)
+
+	// 回滚以新修订记录，旧修订保持不变
+	w = doProblemReq(t, r, http.MethodPost, base+"/revisions/1/rollback", teacherPerms, nil)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	rolled := decodeData[domain.Problem](t, w.Body.Bytes())
+	require.Equal(t, 3, rolled.Revision)
+	require.Equal(t, "line1\nline2\nline3", rolled.Description)
+	require.Equal(t, 256, rolled.MemoryLimitMB)
+	require.Equal(t, domain.DifficultyEasy, rolled.Difficulty) // 元数据不随回滚改变
+	w = doProblemReq(t, r, http.MethodGet, base+"/revisions/3", teacherPerms, nil)
+	require.Equal(t, "rollback to revision 1", decodeData[domain.ProblemRevision](t, w.Body.Bytes()).Message)
+	w = doProblemReq(t, r, http.MethodGet, base+"/revisions/2", teacherPerms, nil)
+	require.Equal(t, 512, decodeData[domain.ProblemRevision](t, w.Body.Bytes()).MemoryLimitMB)
+	// 已与目标修订一致时不再追加
+	w = doProblemReq(t, r, http.MethodPost, base+"/revisions/3/rollback", teacherPerms, nil)
+	require.Equal(t, 3, decodeData[domain.Problem](t, w.Body.Bytes()).Revision)
+	require.Equal(t, http.StatusNotFound, doProblemReq(t, r, http.MethodPost, base+"/revisions/7/rollback", teacherPerms, nil).Code)
+}
+
+func TestProblemRevisions_ConflictAndSubmissionRecord(t *testing.T) {
+	ctx := context.Background()
+	repo := repository.NewMemoryProblemRepository()
+	ps := service.NewProblemService(repo)
+	ps.EnableRevisions(repo)
+	p, err := ps.Create(ctx, service.ProblemInput{Title: "Sum", Description: "add numbers", AuthorID: "t1"})
+	require.NoError(t, err)
+
+	// 基于过期修订号的写入被拒绝
+	stale := p
+	title := "Sum v2"
+	_, err = ps.Update(ctx, p.ID, service.ProblemPatch{Title: &title})
+	require.NoError(t, err)
+	stale.Title = "stale"
+	stale.Revision = 1
+	require.ErrorIs(t, repo.UpdateWithRevision(ctx, stale, domain.NewProblemRevision(stale, 2, "t2", "")), repository.ErrProblemConflict)
+	require.ErrorIs(t, repo.Update(ctx, stale), repository.ErrProblemConflict)
+
+	subs := service.NewSubmissionService(repository.NewMemorySubmissionRepository(), repository.NewMemorySubmissionStatusLogRepository(), service.SubmissionOptions{})
+	subs.UseProblems(ps.SubmissionTarget)
+	sub, err := subs.Create(ctx, "u1", p.ID.String(), "go", "package main")
+	require.NoError(t, err)
+	rev2, err := ps.GetRevision(ctx, p.ID, 2)
+	require.NoError(t, err)
+	require.Equal(t, rev2.ID.String(), sub.ProblemRevisionID)
+	// 题目不存在时不记录修订，提交仍然创建
+	sub, err = subs.Create(ctx, "u1", "unknown-problem", "go", "package main")
+	require.NoError(t, err)
+	require.Empty(t, sub.ProblemRevisionID)
+}
@@ -0,0 +1,140 @@
+package handler_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/YangYuS8/codyssey/backend/internal/auth"
+	"github.com/YangYuS8/codyssey/backend/internal/domain"
+	"github.com/YangYuS8/codyssey/backend/internal/http/handler"
+	"github.com/YangYuS8/codyssey/backend/internal/repository"
+	"github.com/YangYuS8/codyssey/backend/internal/service"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/require"
+)
+
+const teacherPerms = "problem.create,problem.update,problem.delete"
+
+func setupProblemSearchRouter() *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	repo := repository.NewMemoryProblemRepository()
+	ps := service.NewProblemService(repo)
+	ps.EnableTags(repo)
+	r := gin.New()
+	r.Use(auth.AttachDebugIdentity(""))
+	r.GET("/problems", handler.ListProblems(ps))
+	r.POST("/problems", auth.Require(auth.PermProblemCreate), handler.CreateProblem(ps))
+	r.GET("/problems/:id", handler.GetProblem(ps))
+	r.PUT("/problems/:id", auth.Require(auth.PermProblemUpdate), handler.UpdateProblem(ps))
+	r.GET("/problem-tags", handler.ListProblemTags(ps))
+	r.POST("/problem-tags", auth.Require(auth.PermProblemUpdate), handler.CreateProblemTag(ps))
+	r.PUT("/problem-tags/:name", auth.Require(auth.PermProblemUpdate), handler.UpdateProblemTag(ps))
+	r.DELETE("/problem-tags/:name", auth.Require(auth.PermProblemUpdate), handler.DeleteProblemTag(ps))
+	return r
+}
+
+func doProblemReq(t *testing.T, r *gin.Engine, method, path, perms string, body any) *httptest.ResponseRecorder {
+	t.Helper()
+	var buf bytes.Buffer
+	if body != nil { require.NoError(t, json.NewEncoder(&buf).Encode(body)) }
+	req := httptest.NewRequest(method, path, &buf)
+	req.Header.Set("Content-Type", "application/json")
+	if perms != "" { req.Header.Set("X-Debug-Perms", perms) }
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func listProblemTitles(t *testing.T, r *gin.Engine, query, perms string) []string {
+	t.Helper()
+	w := doProblemReq(t, r, http.MethodGet, "/problems?"+query, perms, nil)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var resp struct{ Data []domain.Problem }
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	titles := make([]string, 0, len(resp.Data))
+	for _, p := range resp.Data { titles = append(titles, p.Title) }
+	return titles
+}
+
+func TestProblemSearch_QueryTagsDifficultyVisibility(t *testing.T) {
+	r := setupProblemSearchRouter()
+	for _, name := range []string{"dp", "图论"} {
+		require.Equal(t, http.StatusCreated, doProblemReq(t, r, http.MethodPost, "/problem-tags", teacherPerms, gin.H{"name": name}).Code)
+	}
+	// 引用未创建的标签被拒绝
+	w := doProblemReq(t, r, http.MethodPost, "/problems", teacherPerms, gin.H{"title": "Bad", "description": "xxxxx", "tags": []string{"greedy"}})
+	require.Equal(t, http.StatusBadRequest, w.Code)
+	require.Contains(t, w.Body.String(), "UNKNOWN_TAG")
+	w = doProblemReq(t, r, http.MethodPost, "/problems", teacherPerms, gin.H{"title": "Bad", "description": "xxxxx", "difficulty": "insane"})
+	require.Contains(t, w.Body.String(), "INVALID_PROBLEM")
+
+	seed := []gin.H{
+		{"title": "两数之和", "description": "给定整数数组，求和为目标值的两个数", "difficulty": "easy"},
+		{"title": "最长上升子序列", "description": "Dynamic programming classic", "tags": []string{"dp"}, "difficulty": "medium", "source": "NOIP 2019"},
+		{"title": "单源最短路", "description": "Dijkstra on a weighted graph", "tags": []string{"图论", " dp "}, "difficulty": "hard"},
+		{"title": "内部题目", "description": "Dynamic only for teachers", "tags": []string{"dp"}, "visibility": "private"},
+	}
+	var privateID string
+	for _, b := range seed {
+		w := doProblemReq(t, r, http.MethodPost, "/problems", teacherPerms, b)
+		require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+		var resp struct{ Data domain.Problem }
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+		if resp.Data.Visibility == domain.VisibilityPrivate { privateID = resp.Data.ID.String() }
+	}
+
+	require.Equal(t, []string{"两数之和"}, listProblemTitles(t, r, "q="+url.QueryEscape("两数"), ""))
+	require.Equal(t, []string{"最长上升子序列"}, listProblemTitles(t, r, "q="+url.QueryEscape("上升 dyn"), ""))
+	require.Equal(t, []string{"最长上升子序列"}, listProblemTitles(t, r, "q=noip", ""))
+	require.Empty(t, listProblemTitles(t, r, "q="+url.QueryEscape("最短 dynamic"), ""))
+	require.ElementsMatch(t, []string{"最长上升子序列", "单源最短路"}, listProblemTitles(t, r, "tags=dp", ""))
+	require.Equal(t, []string{"单源最短路"}, listProblemTitles(t, r, "tags="+url.QueryEscape("dp,图论"), ""))
+	require.ElementsMatch(t, []string{"两数之和", "单源最短路"}, listProblemTitles(t, r, "difficulty[in]=easy,hard", ""))
+	require.Equal(t, []string{"最长上升子序列"}, listProblemTitles(t, r, "q=dynamic&difficulty=medium", ""))
+
+	// private 题目仅对具备 problem.update 的用户可见
+	require.ElementsMatch(t, []string{"最长上升子序列", "内部题目"}, listProblemTitles(t, r, "q=dynamic", teacherPerms))
+	require.Equal(t, http.StatusNotFound, doProblemReq(t, r, http.MethodGet, "/problems/"+privateID, "", nil).Code)
+	require.Equal(t, http.StatusOK, doProblemReq(t, r, http.MethodGet, "/problems/"+privateID, teacherPerms, nil).Code)
+
+	// 改为 public 后学生可检索到
+	require.Equal(t, http.StatusOK, doProblemReq(t, r, http.MethodPut, "/problems/"+privateID, teacherPerms, gin.H{"visibility": "public"}).Code)
+	require.ElementsMatch(t, []string{"最长上升子序列", "内部题目"}, listProblemTitles(t, r, "q=dynamic", ""))
+}
+
+func TestProblemTags_CRUD(t *testing.T) {
+	r := setupProblemSearchRouter()
+	require.Equal(t, http.StatusForbidden, doProblemReq(t, r, http.MethodPost, "/problem-tags", "", gin.H{"name": "dp"}).Code)
+	require.Equal(t, http.StatusCreated, doProblemReq(t, r, http.MethodPost, "/problem-tags", teacherPerms, gin.H{"name": "dp", "description": "动态规划"}).Code)
+	require.Equal(t, http.StatusCreated, doProblemReq(t, r, http.MethodPost, "/problem-tags", teacherPerms, gin.H{"name": "graph"}).Code)
+	w := doProblemReq(t, r, http.MethodPost, "/problem-tags", teacherPerms, gin.H{"name": "dp"})
+	require.Equal(t, http.StatusConflict, w.Code)
+	require.Contains(t, w.Body.String(), "TAG_EXISTS")
+	require.Contains(t, doProblemReq(t, r, http.MethodPost, "/problem-tags", teacherPerms, gin.H{"name": "a,b"}).Body.String(), "INVALID_TAG")
+	require.Equal(t, http.StatusCreated, doProblemReq(t, r, http.MethodPost, "/problems", teacherPerms, gin.H{"title": "Knapsack", "description": "0/1 knapsack", "tags": []string{"dp", "graph"}}).Code)
+
+	// 重命名同步到题目
+	w = doProblemReq(t, r, http.MethodPut, "/problem-tags/dp", teacherPerms, gin.H{"name": "dynamic-programming"})
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	require.Equal(t, http.StatusConflict, doProblemReq(t, r, http.MethodPut, "/problem-tags/graph", teacherPerms, gin.H{"name": "dynamic-programming"}).Code)
+	require.Equal(t, []string{"Knapsack"}, listProblemTitles(t, r, "tags=dynamic-programming", ""))
+	require.Empty(t, listProblemTitles(t, r, "tags=dp", ""))
+
+	var tags struct{ Data []domain.ProblemTag }
+	w = doProblemReq(t, r, http.MethodGet, "/problem-tags", "", nil)
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &tags))
+	require.Len(t, tags.Data, 2)
+	require.Equal(t, "dynamic-programming", tags.Data[0].Name)
+	require.Equal(t, "动态规划", tags.Data[0].Description)
+	require.Equal(t, 1, tags.Data[0].ProblemCount)
+
+	// 删除后从题目上移除
+	require.Equal(t, http.StatusOK, doProblemReq(t, r, http.MethodDelete, "/problem-tags/graph", teacherPerms, nil).Code)
+	require.Equal(t, http.StatusNotFound, doProblemReq(t, r, http.MethodDelete, "/problem-tags/graph", teacherPerms, nil).Code)
+	w = doProblemReq(t, r, http.MethodGet, "/problems?tags=dynamic-programming", "", nil)
+	require.Contains(t, w.Body.String(), `"tags":["dynamic-programming"]`)
+}
@@ -0,0 +1,61 @@
+package handler_test
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/YangYuS8/codyssey/backend/internal/domain"
+	"github.com/YangYuS8/codyssey/backend/internal/service"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProblemStatement_RenderedSections(t *testing.T) {
+	r := setupProblemRevisionRouter()
+	statement := gin.H{
+		"background": "Alice 喜欢 **数学**。<script>alert(1)</script>",
+		"input":      "第一行一个整数 $n$（$1 \\le n \\le 10^5$）。",
+		"output":     "输出 $\\sum a_i$。",
+		"samples":    []gin.H{{"input": "3\n1 2 3", "output": "6", "explanation": "[说明](javascript:alert(1))"}},
+		"notes":      "<img src=x onerror=alert(1)>",
+	}
+	w := doProblemReq(t, r, http.MethodPost, "/problems", teacherPerms, gin.H{"title": "Sum", "description": "求和 <b>a</b>", "statement": statement})
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	p := decodeData[domain.Problem](t, w.Body.Bytes())
+	// 响应同时包含 Markdown 源与渲染结果
+	require.Equal(t, "输出 $\\sum a_i$。", p.Statement.Output)
+	require.NotNil(t, p.Rendered)
+	require.Equal(t, "<p>求和 &lt;b&gt;a&lt;/b&gt;</p>\n", p.Rendered.Description)
+	require.Equal(t, "<p>Alice 喜欢 <strong>数学</strong>。&lt;script&gt;alert(1)&lt;/script&gt;</p>\n", p.Rendered.Background)
+	require.Equal(t, "<p>第一行一个整数 <span class=\"math math-inline\">n</span>（<span class=\"math math-inline\">1 \\le n \\le 10^5</span>）。</p>\n", p.Rendered.Input)
+	require.Equal(t, []domain.RenderedSample{{Input: "<pre><code>3\n1 2 3\n</code></pre>", Output: "<pre><code>6\n</code></pre>", Explanation: "<p>说明</p>\n"}}, p.Rendered.Samples)
+	require.NotContains(t, p.Rendered.Notes, "<img")
+	w = doProblemReq(t, r, http.MethodGet, "/problems/"+p.ID.String(), "", nil)
+	require.Equal(t, p.Rendered, decodeData[domain.Problem](t, w.Body.Bytes()).Rendered)
+
+	// 修改分节追加修订，diff 列出变化的分节
+	base := "/problems/" + p.ID.String()
+	w = doProblemReq(t, r, http.MethodPut, base, teacherPerms, gin.H{"statement": gin.H{"background": "Alice 喜欢数学。", "input": statement["input"], "output": statement["output"], "notes": "无"}})
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	updated := decodeData[domain.Problem](t, w.Body.Bytes())
+	require.Equal(t, 2, updated.Revision)
+	require.Equal(t, []domain.ProblemSample{}, updated.Statement.Samples)
+	w = doProblemReq(t, r, http.MethodGet, base+"/revisions/diff?from=1&to=2", teacherPerms, nil)
+	d := decodeData[service.RevisionDiff](t, w.Body.Bytes())
+	fields := make([]string, 0, len(d.Changes))
+	for _, c := range d.Changes { fields = append(fields, c.Field) }
+	require.Equal(t, []string{"statement.background", "statement.samples", "statement.notes"}, fields)
+
+	// 回滚恢复分节
+	w = doProblemReq(t, r, http.MethodPost, base+"/revisions/1/rollback", teacherPerms, nil)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	rolled := decodeData[domain.Problem](t, w.Body.Bytes())
+	require.Len(t, rolled.Statement.Samples, 1)
+	require.Contains(t, rolled.Rendered.Background, "<strong>数学</strong>")
+
+	bad := gin.H{"title": "Bad", "description": "some text", "statement": gin.H{"samples": []gin.H{{"input": " ", "output": ""}}}}
+	require.Contains(t, doProblemReq(t, r, http.MethodPost, "/problems", teacherPerms, bad).Body.String(), "INVALID_PROBLEM")
+	huge := gin.H{"title": "Big", "description": "some text", "statement": gin.H{"notes": strings.Repeat("x", 65<<10)}}
+	require.Contains(t, doProblemReq(t, r, http.MethodPost, "/problems", teacherPerms, huge).Body.String(), "statement.notes")
+}
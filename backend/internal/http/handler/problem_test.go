@@ -11,6 +11,7 @@ import (
 	"github.com/YangYuS8/codyssey/backend/internal/auth"
 	"github.com/YangYuS8/codyssey/backend/internal/domain"
 	"github.com/YangYuS8/codyssey/backend/internal/http/handler"
+	"github.com/YangYuS8/codyssey/backend/internal/listquery"
 	"github.com/YangYuS8/codyssey/backend/internal/repository"
 	"github.com/YangYuS8/codyssey/backend/internal/service"
 	"github.com/gin-gonic/gin"
@@ -20,7 +21,7 @@ import (
 type memoryRepo struct { items []domain.Problem }
 
 func (m *memoryRepo) Create(ctx context.Context, p domain.Problem) error { m.items = append([]domain.Problem{p}, m.items...); return nil }
-func (m *memoryRepo) List(ctx context.Context, limit, offset int) ([]domain.Problem, error) { return m.items, nil }
+func (m *memoryRepo) List(ctx context.Context, f repository.ProblemFilter, spec listquery.Spec) ([]domain.Problem, string, error) { return m.items, "", nil }
 func (m *memoryRepo) GetByID(ctx context.Context, id uuid.UUID) (domain.Problem, error) {
 	for _, it := range m.items { if it.ID == id { return it, nil } }
 	return domain.Problem{}, repository.ErrNotFound
@@ -39,7 +40,7 @@ func setupProblemRouter(repo service.ProblemRepo) *gin.Engine {
 	gin.SetMode(gin.TestMode)
 	r := gin.New()
 	// 附加调试身份中间件
-	r.Use(auth.AttachDebugIdentity())
+	r.Use(auth.AttachDebugIdentity(""))
 	ps := service.NewProblemService(repo)
 	r.POST("/problems", auth.Require(auth.PermProblemCreate), handler.CreateProblem(ps))
 	r.GET("/problems", handler.ListProblems(ps))
@@ -0,0 +1,67 @@
+package handler
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"golang.org/x/net/websocket"
+
+	"github.com/YangYuS8/codyssey/backend/internal/auth"
+	"github.com/YangYuS8/codyssey/backend/internal/http/errcode"
+	"github.com/YangYuS8/codyssey/backend/internal/realtime"
+)
+
+// RealtimeSocket GET /realtime/ws：升级为 WebSocket，身份沿用全局认证中间件（JWT 可放在 ?access_token=）。
+// 个人判题结果主题仅本人或 teacher/system_admin 可订阅；比赛与公告主题对登录用户开放。
+func RealtimeSocket(b *realtime.Broker) gin.HandlerFunc {
+    return func(c *gin.Context) {
+        id := auth.GetIdentity(c)
+        if id == nil || id.UserID == "guest" {
+            respondError(c, http.StatusUnauthorized, "UNAUTHORIZED", "login required")
+            return
+        }
+        privileged := hasAnyRole(id, auth.RoleSystemAdmin, auth.RoleTeacher)
+        authorize := func(t realtime.Topic) error {
+            if t.Kind == realtime.KindVerdicts && t.ID != id.UserID && !privileged { return realtime.ErrTopicForbidden }
+            return nil
+        }
+        srv := websocket.Server{
+            // 认证基于令牌而非 Cookie，不存在跨站劫持风险，放开 Origin 校验
+            Handshake: func(*websocket.Config, *http.Request) error { return nil },
+            Handler:   func(ws *websocket.Conn) { b.Serve(c.Request.Context(), ws, authorize) },
+        }
+        srv.ServeHTTP(c.Writer, c.Request)
+    }
+}
+
+type RealtimePublishRequest struct {
+    Topic string `json:"topic" binding:"required"`
+    Event string `json:"event" binding:"required"`
+    Data  any    `json:"data"`
+}
+
+// PublishRealtime POST /realtime/publish：向比赛榜单 / 答疑 / 公告主题发布消息（realtime.publish）。
+// 个人判题结果由系统在提交进入终态时自动推送，不接受手动发布；消息超过 realtime.MaxMessageBytes 返回 413。
+func PublishRealtime(b *realtime.Broker) gin.HandlerFunc {
+    return func(c *gin.Context) {
+        var req RealtimePublishRequest
+        if err := c.ShouldBindJSON(&req); err != nil {
+            respondError(c, http.StatusBadRequest, "INVALID_BODY", err.Error())
+            return
+        }
+        t, err := realtime.ParseTopic(strings.TrimSpace(req.Topic))
+        if err != nil || t.Kind == realtime.KindVerdicts {
+            respondError(c, http.StatusBadRequest, errcode.CodeInvalidTopic, errcode.Text(errcode.CodeInvalidTopic))
+            return
+        }
+        event := strings.TrimSpace(req.Event)
+        if err := realtime.CheckMessage(event, req.Data); err != nil {
+            respondError(c, http.StatusRequestEntityTooLarge, errcode.CodePayloadTooLarge, errcode.Text(errcode.CodePayloadTooLarge))
+            return
+        }
+        b.Publish(c.Request.Context(), t, event, req.Data)
+        // subscribers 为本实例的订阅数，多实例部署时仅供参考
+        c.JSON(http.StatusAccepted, SuccessResponse{Data: gin.H{"topic": t.Name, "subscribers": b.Subscribers(t.Name)}})
+    }
+}
@@ -0,0 +1,77 @@
+package handler
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/net/websocket"
+
+	"github.com/YangYuS8/codyssey/backend/internal/auth"
+	"github.com/YangYuS8/codyssey/backend/internal/realtime"
+)
+
+func TestRealtimeSocketAndPublish(t *testing.T) {
+    gin.SetMode(gin.TestMode)
+    b := realtime.NewBroker()
+    r := gin.New()
+    r.Use(func(c *gin.Context) {
+        if c.GetHeader("X-Test-Guest") != "" { c.Next(); return }
+        c.Set("__identity", &auth.Identity{UserID: "u1", Roles: []string{auth.RoleStudent}, Permissions: map[auth.Permission]struct{}{}})
+        c.Next()
+    })
+    r.GET("/realtime/ws", RealtimeSocket(b))
+    r.POST("/realtime/publish", PublishRealtime(b))
+    srv := httptest.NewServer(r)
+    defer srv.Close()
+
+    // 未登录不升级
+    req, _ := http.NewRequest(http.MethodGet, srv.URL+"/realtime/ws", nil)
+    req.Header.Set("X-Test-Guest", "1")
+    resp, err := http.DefaultClient.Do(req)
+    require.NoError(t, err)
+    resp.Body.Close()
+    require.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+
+    ws, err := websocket.Dial("ws"+strings.TrimPrefix(srv.URL, "http")+"/realtime/ws", "", srv.URL)
+    require.NoError(t, err)
+    defer ws.Close()
+    require.NoError(t, websocket.JSON.Send(ws, map[string]any{"op": "subscribe", "topics": []string{"announcements", "user:u2:verdicts"}}))
+    got := map[string]realtime.Frame{}
+    for len(got) < 2 {
+        _ = ws.SetReadDeadline(time.Now().Add(2 * time.Second))
+        var f realtime.Frame
+        require.NoError(t, websocket.JSON.Receive(ws, &f))
+        got[f.Type] = f
+    }
+    require.Equal(t, "FORBIDDEN", got["error"].Code)
+    require.Equal(t, []string{"announcements"}, got["subscribed"].Topics)
+
+    w := httptest.NewRecorder()
+    r.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/realtime/publish", bytes.NewBufferString(`{"topic":"user:u1:verdicts","event":"verdict"}`)))
+    require.Equal(t, http.StatusBadRequest, w.Code)
+    // 超过 NOTIFY 载荷上限的消息直接拒绝，而不是只投递到本实例
+    w = httptest.NewRecorder()
+    big := `{"topic":"announcements","event":"announcement","data":{"text":"` + strings.Repeat("x", realtime.MaxMessageBytes) + `"}}`
+    r.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/realtime/publish", bytes.NewBufferString(big)))
+    require.Equal(t, http.StatusRequestEntityTooLarge, w.Code)
+    require.Contains(t, w.Body.String(), "PAYLOAD_TOO_LARGE")
+    w = httptest.NewRecorder()
+    r.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/realtime/publish", bytes.NewBufferString(`{"topic":"announcements","event":"announcement","data":{"text":"比赛延长 10 分钟"}}`)))
+    require.Equal(t, http.StatusAccepted, w.Code, w.Body.String())
+    require.Contains(t, w.Body.String(), `"subscribers":1`)
+
+    _ = ws.SetReadDeadline(time.Now().Add(2 * time.Second))
+    var f realtime.Frame
+    require.NoError(t, websocket.JSON.Receive(ws, &f))
+    require.Equal(t, "announcement", f.Event)
+
+    // 等服务端会话退订完成再返回，避免其协程与后续测试初始化指标并发
+    require.NoError(t, ws.Close())
+    require.Eventually(t, func() bool { return b.Subscribers("announcements") == 0 }, 2*time.Second, 10*time.Millisecond)
+}
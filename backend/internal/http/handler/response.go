@@ -1,6 +1,12 @@
 package handler
 
-import "github.com/gin-gonic/gin"
+import (
+	"net/http"
+
+	"github.com/YangYuS8/codyssey/backend/internal/http/errcode"
+	"github.com/YangYuS8/codyssey/backend/internal/listquery"
+	"github.com/gin-gonic/gin"
+)
 
 // Standard API response formats
 // Success: { "data": <payload>, "meta": {..optional..}, "error": null }
@@ -33,3 +39,17 @@ func respondCreated(c *gin.Context, data any) {
 func respondError(c *gin.Context, status int, code, message string) {
     c.JSON(status, ErrorResponse{Data: nil, Err: &APIError{Code: code, Message: message}})
 }
+
+// parseListQuery 按资源的 schema 解析 limit / offset / cursor / sort / 过滤参数；不合法时写 400 INVALID_QUERY 并返回 false。
+func parseListQuery(c *gin.Context, schema *listquery.Schema) (listquery.Spec, bool) {
+    spec, err := schema.Parse(c.Request.URL.Query())
+    if err != nil { respondError(c, http.StatusBadRequest, errcode.CodeInvalidQuery, err.Error()); return listquery.Spec{}, false }
+    return spec, true
+}
+
+// listMeta 列表响应的 meta：next_cursor 仅在还有下一页时出现，原样作为 cursor 参数请求下一页。
+func listMeta(spec listquery.Spec, count int, next string) gin.H {
+    meta := gin.H{"limit": spec.Limit, "offset": spec.Offset, "count": count}
+    if next != "" { meta["next_cursor"] = next }
+    return meta
+}
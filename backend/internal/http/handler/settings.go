@@ -0,0 +1,82 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/YangYuS8/codyssey/backend/internal/auth"
+	"github.com/YangYuS8/codyssey/backend/internal/http/errcode"
+	"github.com/YangYuS8/codyssey/backend/internal/settings"
+	"github.com/gin-gonic/gin"
+)
+
+type settingsView struct {
+    Version     int64                 `json:"version"`
+    Settings    []settings.Entry      `json:"settings"`
+    Definitions []settings.Definition `json:"definitions"`
+}
+
+func viewSettings(st *settings.Store, snap *settings.Snapshot) settingsView {
+    return settingsView{Version: snap.Version, Settings: snap.Entries(st.Defaults()), Definitions: settings.Definitions()}
+}
+
+// updateSettingsRequest values 中字符串、数字、布尔均按字面量处理，null 表示恢复默认值；version 缺省时基于服务端当前版本。
+type updateSettingsRequest struct {
+    Version *int64                     `json:"version"`
+    Values  map[string]json.RawMessage `json:"values" binding:"required"`
+}
+
+func (r updateSettingsRequest) changes() map[string]*string {
+    out := make(map[string]*string, len(r.Values))
+    for k, raw := range r.Values {
+        if string(raw) == "null" { out[k] = nil; continue }
+        var s string
+        if json.Unmarshal(raw, &s) != nil { s = string(raw) }
+        out[k] = &s
+    }
+    return out
+}
+
+// GetSettings GET /admin/settings：当前版本与全部生效值（含默认值与是否被覆盖）。
+func GetSettings(st *settings.Store) gin.HandlerFunc {
+    return func(c *gin.Context) { respondOK(c, viewSettings(st, st.Current()), nil) }
+}
+
+// UpdateSettings PATCH /admin/settings：原子写入一组变更，版本冲突返回 409。
+func UpdateSettings(st *settings.Store) gin.HandlerFunc {
+    return func(c *gin.Context) {
+        id := auth.GetIdentity(c)
+        if id == nil || id.UserID == "" || id.UserID == "guest" {
+            respondError(c, http.StatusUnauthorized, errcode.CodeUnauthorized, errcode.Text(errcode.CodeUnauthorized))
+            return
+        }
+        var req updateSettingsRequest
+        if err := c.ShouldBindJSON(&req); err != nil { respondError(c, http.StatusBadRequest, "INVALID_BODY", err.Error()); return }
+        snap, err := st.Update(c, req.Version, req.changes(), id.UserID, settings.SourceAPI)
+        switch {
+        case err == nil:
+            respondOK(c, viewSettings(st, snap), nil)
+        case errors.Is(err, settings.ErrInvalidSetting):
+            respondError(c, http.StatusBadRequest, errcode.CodeInvalidSetting, err.Error())
+        case errors.Is(err, settings.ErrVersionConflict):
+            respondError(c, http.StatusConflict, errcode.CodeSettingsConflict, errcode.Text(errcode.CodeSettingsConflict))
+        default:
+            respondError(c, http.StatusInternalServerError, errcode.CodeInternal, err.Error())
+        }
+    }
+}
+
+// ListSettingsHistory GET /admin/settings/history?limit=&offset=：变更记录，新版本在前。
+func ListSettingsHistory(st *settings.Store) gin.HandlerFunc {
+    return func(c *gin.Context) {
+        limit, _ := strconv.Atoi(c.DefaultQuery("limit", "50"))
+        offset, _ := strconv.Atoi(c.DefaultQuery("offset", "0"))
+        if limit <= 0 || limit > 200 { limit = 50 }
+        if offset < 0 { offset = 0 }
+        list, err := st.History(c, limit, offset)
+        if err != nil { respondError(c, http.StatusInternalServerError, errcode.CodeListFailed, err.Error()); return }
+        respondOK(c, list, map[string]int{"limit": limit, "offset": offset, "count": len(list)})
+    }
+}
@@ -0,0 +1,106 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/require"
+
+	"github.com/YangYuS8/codyssey/backend/internal/auth"
+	"github.com/YangYuS8/codyssey/backend/internal/repository"
+	"github.com/YangYuS8/codyssey/backend/internal/settings"
+)
+
+func buildSettingsRouter(t *testing.T) (*gin.Engine, *settings.Store) {
+    t.Helper()
+    gin.SetMode(gin.TestMode)
+    st, err := settings.NewStore(context.Background(), repository.NewMemoryRuntimeSettingRepository(), settings.Defaults{
+        MaxSubmissionCodeBytes: 1024, LogLevel: "info", RateLimits: map[string]string{"login": "10/1m", "submission": "off", "judge_enqueue": "off"},
+    }, nil)
+    require.NoError(t, err)
+    r := gin.New()
+    r.Use(func(c *gin.Context) {
+        id := &auth.Identity{UserID: c.GetHeader("X-Test-User"), Permissions: map[auth.Permission]struct{}{}}
+        if id.UserID == "" { id.UserID = "guest" }
+        if c.GetHeader("X-Test-Admin") == "1" { id.Permissions[auth.PermSystemManage] = struct{}{} }
+        c.Set("__identity", id)
+        c.Next()
+    })
+    r.GET("/admin/settings", auth.Require(auth.PermSystemManage), GetSettings(st))
+    r.PATCH("/admin/settings", auth.Require(auth.PermSystemManage), UpdateSettings(st))
+    r.GET("/admin/settings/history", auth.Require(auth.PermSystemManage), ListSettingsHistory(st))
+    return r, st
+}
+
+func doSettings(r *gin.Engine, method, path, body string, admin bool) *httptest.ResponseRecorder {
+    req := httptest.NewRequest(method, path, bytes.NewBufferString(body))
+    req.Header.Set("Content-Type", "application/json")
+    req.Header.Set("X-Test-User", "admin1")
+    if admin { req.Header.Set("X-Test-Admin", "1") }
+    w := httptest.NewRecorder()
+    r.ServeHTTP(w, req)
+    return w
+}
+
+func TestSettingsAdminAPI(t *testing.T) {
+    r, st := buildSettingsRouter(t)
+
+    require.Equal(t, http.StatusForbidden, doSettings(r, http.MethodGet, "/admin/settings", "", false).Code)
+
+    w := doSettings(r, http.MethodGet, "/admin/settings", "", true)
+    require.Equal(t, http.StatusOK, w.Code)
+    var got struct {
+        Data struct {
+            Version  int64            `json:"version"`
+            Settings []settings.Entry `json:"settings"`
+        } `json:"data"`
+    }
+    require.NoError(t, json.Unmarshal(w.Body.Bytes(), &got))
+    require.EqualValues(t, 0, got.Data.Version)
+    require.NotEmpty(t, got.Data.Settings)
+
+    // 功能开关不属于运行时参数（由 /admin/feature-flags 管理）
+    w = doSettings(r, http.MethodPatch, "/admin/settings", `{"version":0,"values":{"feature.ai_detection":true}}`, true)
+    require.Equal(t, http.StatusBadRequest, w.Code)
+    require.Contains(t, w.Body.String(), "INVALID_SETTING")
+
+    // 数字按字面量接受
+    w = doSettings(r, http.MethodPatch, "/admin/settings", `{"version":0,"values":{"max_submission_code_bytes":4096}}`, true)
+    require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+    require.Equal(t, 4096, st.Current().MaxSubmissionCodeBytes())
+
+    // 过期版本
+    w = doSettings(r, http.MethodPatch, "/admin/settings", `{"version":0,"values":{"log_level":"warn"}}`, true)
+    require.Equal(t, http.StatusConflict, w.Code)
+    require.Contains(t, w.Body.String(), "SETTINGS_VERSION_CONFLICT")
+
+    w = doSettings(r, http.MethodPatch, "/admin/settings", `{"values":{"log_level":"loud"}}`, true)
+    require.Equal(t, http.StatusBadRequest, w.Code)
+    require.Contains(t, w.Body.String(), "INVALID_SETTING")
+
+    w = doSettings(r, http.MethodPatch, "/admin/settings", `{"version":1,"values":{"max_submission_code_bytes":null}}`, true)
+    require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+    require.Equal(t, 1024, st.Current().MaxSubmissionCodeBytes())
+
+    w = doSettings(r, http.MethodGet, "/admin/settings/history?limit=10", "", true)
+    require.Equal(t, http.StatusOK, w.Code)
+    var hist struct {
+        Data []struct {
+            Key       string  `json:"key"`
+            NewValue  *string `json:"new_value"`
+            ChangedBy string  `json:"changed_by"`
+            Source    string  `json:"source"`
+        } `json:"data"`
+    }
+    require.NoError(t, json.Unmarshal(w.Body.Bytes(), &hist))
+    require.Len(t, hist.Data, 2)
+    require.Equal(t, "max_submission_code_bytes", hist.Data[0].Key)
+    require.Nil(t, hist.Data[0].NewValue)
+    require.Equal(t, "admin1", hist.Data[0].ChangedBy)
+    require.Equal(t, settings.SourceAPI, hist.Data[0].Source)
+}
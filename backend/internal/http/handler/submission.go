@@ -2,12 +2,12 @@ package handler
 
 import (
 	"net/http"
-	"strconv"
 	"strings"
 	"time"
 
 	"github.com/YangYuS8/codyssey/backend/internal/auth"
 	"github.com/YangYuS8/codyssey/backend/internal/http/errcode"
+	"github.com/YangYuS8/codyssey/backend/internal/repository"
 	"github.com/YangYuS8/codyssey/backend/internal/service"
 	"github.com/gin-gonic/gin"
 )
@@ -39,6 +39,11 @@ func CreateSubmission(s *service.SubmissionService) gin.HandlerFunc {
             respondError(c, http.StatusUnauthorized, "UNAUTHORIZED", "login required")
             return
         }
+        // service 令牌不代表具体用户，提交必须归属于真实用户
+        if id.IsService() {
+            respondError(c, http.StatusForbidden, errcode.CodeForbidden, "service token cannot create submissions")
+            return
+        }
         var req SubmissionCreateRequest
         if err := c.ShouldBindJSON(&req); err != nil {
             respondError(c, http.StatusBadRequest, "INVALID_BODY", err.Error())
@@ -47,9 +52,13 @@ func CreateSubmission(s *service.SubmissionService) gin.HandlerFunc {
         // 简单清洗
         lang := strings.TrimSpace(req.Language)
         code := req.Code
-        sub, err := s.Create(c.Request.Context(), id.UserID, req.ProblemID, lang, code)
+        sub, err := s.Submit(c.Request.Context(), service.CreateSubmissionInput{
+            UserID: id.UserID, ProblemID: req.ProblemID, Language: lang, Code: code, CanSeeHidden: canSeeHidden(c),
+        })
         if err != nil {
             switch err {
+            case service.ErrProblemUnavailable:
+                respondError(c, http.StatusNotFound, "NOT_FOUND", "problem not found")
             case service.ErrEmptyCode:
                 respondError(c, http.StatusBadRequest, "EMPTY_CODE", err.Error())
             case service.ErrLanguageRequired:
@@ -89,11 +98,15 @@ func GetSubmission(s *service.SubmissionService) gin.HandlerFunc {
         if id.UserID != sub.UserID && !hasAnyRole(id, auth.RoleSystemAdmin, auth.RoleTeacher) {
             sub.Code = ""
         }
+        // AI 检测结果仅对 ai.detect 权限（教师 / 管理员）可见，提交者本人不可见
+        if id.Has(auth.PermAIDetect) {
+            if chk, err := s.AICheck(c.Request.Context(), sub.ID); err == nil { sub.AICheck = &chk }
+        }
         respondOK(c, sub, nil)
     }
 }
 
-// ListSubmissions 列表：支持按 user_id / problem_id / status 过滤，分页 limit/offset。
+// ListSubmissions 列表：过滤、排序与分页参数见 repository.SubmissionListSchema，支持 cursor 与 limit/offset。
 // 代码可见性：仅 owner 或 teacher/system_admin 角色保留 code，其余清空。
 func ListSubmissions(s *service.SubmissionService) gin.HandlerFunc {
     return func(c *gin.Context) {
@@ -102,16 +115,9 @@ func ListSubmissions(s *service.SubmissionService) gin.HandlerFunc {
             respondError(c, http.StatusUnauthorized, "UNAUTHORIZED", "login required")
             return
         }
-        limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
-        offset, _ := strconv.Atoi(c.DefaultQuery("offset", "0"))
-        filter := service.SubmissionListFilter{
-            UserID:    strings.TrimSpace(c.Query("user_id")),
-            ProblemID: strings.TrimSpace(c.Query("problem_id")),
-            Status:    strings.TrimSpace(c.Query("status")),
-            Limit:     limit,
-            Offset:    offset,
-        }
-        subs, total, err := s.ListWithTotal(c.Request.Context(), filter)
+        spec, ok := parseListQuery(c, repository.SubmissionListSchema)
+        if !ok { return }
+        subs, next, total, err := s.ListWithTotal(c.Request.Context(), spec)
         if err != nil { respondError(c, http.StatusInternalServerError, "LIST_FAILED", err.Error()); return }
         // redaction
         if !hasAnyRole(id, auth.RoleSystemAdmin, auth.RoleTeacher) {
@@ -119,7 +125,8 @@ func ListSubmissions(s *service.SubmissionService) gin.HandlerFunc {
                 if subs[i].UserID != id.UserID { subs[i].Code = "" }
             }
         }
-        meta := map[string]int{"limit": limit, "offset": offset, "count": len(subs), "total": total}
+        meta := listMeta(spec, len(subs), next)
+        meta["total"] = total
         respondOK(c, subs, meta)
     }
 }
@@ -171,16 +178,15 @@ func ListSubmissionStatusLogs(s *service.SubmissionService) gin.HandlerFunc {
     return func(c *gin.Context) {
         subID := c.Param("id")
         if strings.TrimSpace(subID) == "" { respondError(c, http.StatusBadRequest, "INVALID_ID", "empty id"); return }
-        limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
-        offset, _ := strconv.Atoi(c.DefaultQuery("offset", "0"))
-        logs, err := s.ListStatusLogs(c.Request.Context(), subID, limit, offset)
+        spec, ok := parseListQuery(c, repository.StatusLogListSchema)
+        if !ok { return }
+        logs, next, err := s.ListStatusLogs(c.Request.Context(), subID, spec)
         if err != nil { respondError(c, http.StatusInternalServerError, "LIST_LOGS_FAILED", err.Error()); return }
         // 映射输出
         out := make([]SubmissionStatusLogResponse, 0, len(logs))
         for _, l := range logs {
             out = append(out, SubmissionStatusLogResponse{ID: l.ID, SubmissionID: l.SubmissionID, FromStatus: l.FromStatus, ToStatus: l.ToStatus, CreatedAt: l.CreatedAt.Format(time.RFC3339)})
         }
-        meta := map[string]int{"limit":limit, "offset":offset, "count": len(out)}
-        respondOK(c, out, meta)
+        respondOK(c, out, listMeta(spec, len(out), next))
     }
 }
@@ -0,0 +1,163 @@
+package handler_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+
+	"github.com/YangYuS8/codyssey/backend/internal/aiclient"
+	"github.com/YangYuS8/codyssey/backend/internal/auth"
+	"github.com/YangYuS8/codyssey/backend/internal/domain"
+	"github.com/YangYuS8/codyssey/backend/internal/featureflag"
+	"github.com/YangYuS8/codyssey/backend/internal/http/router"
+	"github.com/YangYuS8/codyssey/backend/internal/repository"
+	"github.com/YangYuS8/codyssey/backend/internal/service"
+)
+
+// setupAICheckServer AI 服务由 httptest 模拟：代码含 "down" 返回 503，含 "gpt" 判为可疑。
+func setupAICheckServer(t *testing.T) *httptest.Server {
+    t.Helper()
+    ai := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        var in struct{ Code string `json:"code"` }
+        _ = json.NewDecoder(r.Body).Decode(&in)
+        switch {
+        case strings.Contains(in.Code, "down"):
+            http.Error(w, "overloaded", http.StatusServiceUnavailable)
+        case strings.Contains(in.Code, "gpt"):
+            _ = json.NewEncoder(w).Encode(gin.H{"suspicious": true, "score": 0.9, "model": "stub-detector"})
+        default:
+            _ = json.NewEncoder(w).Encode(gin.H{"suspicious": false, "score": 0.1, "model": "stub-detector"})
+        }
+    }))
+    t.Cleanup(ai.Close)
+    client, err := aiclient.New(aiclient.Options{BaseURL: ai.URL, RetryBackoff: 1})
+    require.NoError(t, err)
+
+    det := service.NewAIDetectionService(repository.NewMemorySubmissionAICheckRepository(), client, service.AIDetectionOptions{})
+    ctx, cancel := context.WithCancel(context.Background())
+    det.Start(ctx)
+    t.Cleanup(func() { cancel(); det.Wait() })
+
+    flags := featureflag.NewService(featureflag.NewMemoryStore(), featureflag.Options{})
+    _, err = flags.Create(ctx, featureflag.Flag{Key: featureflag.KeyAIDetection, Enabled: true, Percentage: 100}, "admin")
+    require.NoError(t, err)
+
+    srv := httptest.NewServer(router.Setup(router.Dependencies{JWTSecret: "test-secret", SubmissionRepo: repository.NewMemorySubmissionRepository(), AIDetection: det, FeatureFlags: flags}))
+    t.Cleanup(srv.Close)
+    return srv
+}
+
+func aiCheckReq(t *testing.T, srv *httptest.Server, method, path, token string, body any) (int, []byte) {
+    t.Helper()
+    var rd *bytes.Reader
+    if body != nil { b, _ := json.Marshal(body); rd = bytes.NewReader(b) } else { rd = bytes.NewReader(nil) }
+    req, _ := http.NewRequest(method, srv.URL+path, rd)
+    req.Header.Set("Authorization", "Bearer "+token)
+    req.Header.Set("Content-Type", "application/json")
+    resp, err := http.DefaultClient.Do(req)
+    require.NoError(t, err)
+    defer resp.Body.Close()
+    var buf bytes.Buffer
+    _, _ = buf.ReadFrom(resp.Body)
+    return resp.StatusCode, buf.Bytes()
+}
+
+func TestSubmissionAICheck(t *testing.T) {
+    srv := setupAICheckServer(t)
+    student := makeTokenList(t, "test-secret", "stu1", []string{auth.RoleStudent}, nil)
+    teacher := makeTokenList(t, "test-secret", "teacher1", []string{auth.RoleTeacher}, nil)
+    problemID, otherProblem := uuid.NewString(), uuid.NewString()
+
+    submit := func(problemID, code string) domain.Submission {
+        st, body := aiCheckReq(t, srv, http.MethodPost, "/submissions", student, gin.H{"problem_id": problemID, "language": "go", "code": code})
+        require.Equal(t, http.StatusCreated, st, string(body))
+        return decodeData[domain.Submission](t, body)
+    }
+    getAs := func(token, id string) domain.Submission {
+        code, body := aiCheckReq(t, srv, http.MethodGet, "/submissions/"+id, token, nil)
+        require.Equal(t, http.StatusOK, code, string(body))
+        return decodeData[domain.Submission](t, body)
+    }
+    waitCheck := func(id, status string) *domain.SubmissionAICheck {
+        var chk *domain.SubmissionAICheck
+        require.Eventually(t, func() bool {
+            chk = getAs(teacher, id).AICheck
+            return chk != nil && chk.Status == status
+        }, 5*time.Second, 10*time.Millisecond)
+        return chk
+    }
+
+    // 未开启检测：不产生检测记录（开关在创建提交时同步判断）
+    plain := submit(problemID, "package main // gpt")
+    require.Nil(t, getAs(teacher, plain.ID).AICheck)
+
+    // 学生不能管理开关；范围非法
+    code, _ := aiCheckReq(t, srv, http.MethodPut, "/ai-detection/settings/problem/"+problemID, student, gin.H{"enabled": true})
+    require.Equal(t, http.StatusForbidden, code)
+    code, body := aiCheckReq(t, srv, http.MethodPut, "/ai-detection/settings/course/x", teacher, gin.H{"enabled": true})
+    require.Equal(t, http.StatusBadRequest, code)
+    require.Contains(t, string(body), "INVALID_DETECTION_SCOPE")
+
+    // 按题目开启
+    code, body = aiCheckReq(t, srv, http.MethodPut, "/ai-detection/settings/problem/"+problemID, teacher, gin.H{"enabled": true})
+    require.Equal(t, http.StatusOK, code, string(body))
+    require.Equal(t, "teacher1", decodeData[domain.AIDetectionSetting](t, body).UpdatedBy)
+
+    sus := submit(problemID, "package main // gpt")
+    chk := waitCheck(sus.ID, domain.AICheckStatusCompleted)
+    require.True(t, chk.Suspicious)
+    require.InDelta(t, 0.9, chk.Score, 1e-9)
+    require.Equal(t, "stub-detector", chk.Model)
+    // 提交者本人可见代码但看不到检测结果
+    own := getAs(student, sus.ID)
+    require.NotEmpty(t, own.Code)
+    require.Nil(t, own.AICheck)
+
+    clean := submit(problemID, "package main")
+    waitCheck(clean.ID, domain.AICheckStatusCompleted)
+
+    // AI 服务失败只记录在检测结果中，提交照常判题
+    down := submit(problemID, "package main // down")
+    chk = waitCheck(down.ID, domain.AICheckStatusFailed)
+    require.NotEmpty(t, chk.Error)
+    require.Equal(t, "pending", getAs(teacher, down.ID).Status)
+    code, body = aiCheckReq(t, srv, http.MethodPatch, "/submissions/"+down.ID+"/status", teacher, gin.H{"status": "accepted"})
+    require.Equal(t, http.StatusOK, code, string(body))
+
+    // 比赛范围不再支持：提交所属比赛由客户端填写，无法作为检测依据
+    code, body = aiCheckReq(t, srv, http.MethodPut, "/ai-detection/settings/contest/spring-cup", teacher, gin.H{"enabled": true})
+    require.Equal(t, http.StatusBadRequest, code)
+    require.Contains(t, string(body), "INVALID_DETECTION_SCOPE")
+    outside := submit(otherProblem, "package main // gpt")
+    require.Nil(t, getAs(teacher, outside.ID).AICheck)
+
+    code, body = aiCheckReq(t, srv, http.MethodGet, "/ai-detection/settings", teacher, nil)
+    require.Equal(t, http.StatusOK, code)
+    require.Len(t, decodeData[[]domain.AIDetectionSetting](t, body), 1)
+
+    // 单题报告
+    code, _ = aiCheckReq(t, srv, http.MethodGet, "/problems/"+problemID+"/ai-report", student, nil)
+    require.Equal(t, http.StatusForbidden, code)
+    code, body = aiCheckReq(t, srv, http.MethodGet, "/problems/"+problemID+"/ai-report?limit=2", teacher, nil)
+    require.Equal(t, http.StatusOK, code, string(body))
+    report := decodeData[struct {
+        Summary domain.AICheckSummary      `json:"summary"`
+        Items   []domain.SubmissionAICheck `json:"items"`
+    }](t, body)
+    require.Equal(t, domain.AICheckSummary{ProblemID: problemID, Total: 3, Completed: 2, Failed: 1, Suspicious: 1, AvgScore: 0.5}, report.Summary)
+    require.Len(t, report.Items, 2)
+    require.Equal(t, down.ID, report.Items[0].SubmissionID)
+    code, body = aiCheckReq(t, srv, http.MethodGet, "/problems/"+problemID+"/ai-report?status=failed", teacher, nil)
+    require.Equal(t, http.StatusOK, code, string(body))
+    require.Contains(t, string(body), down.ID)
+    require.NotContains(t, string(body), sus.ID)
+}
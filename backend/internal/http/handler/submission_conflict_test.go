@@ -7,7 +7,6 @@ import (
 	"io"
 	"net/http"
 	"net/http/httptest"
-	"os"
 	"sync"
 	"sync/atomic"
 	"testing"
@@ -17,6 +16,7 @@ import (
 
 	"github.com/YangYuS8/codyssey/backend/internal/auth"
 	"github.com/YangYuS8/codyssey/backend/internal/domain"
+	"github.com/YangYuS8/codyssey/backend/internal/listquery"
 	"github.com/YangYuS8/codyssey/backend/internal/http/router"
 	"github.com/YangYuS8/codyssey/backend/internal/repository"
 )
@@ -56,16 +56,15 @@ func (m *conflictMemorySubmissionRepo) UpdateStatus(_ context.Context, id string
     m.updated.Add(1)
     return nil
 }
-func (m *conflictMemorySubmissionRepo) List(_ context.Context, _ repository.SubmissionFilter, _, _ int) ([]domain.Submission, error) { return []domain.Submission{m.sub}, nil }
-func (m *conflictMemorySubmissionRepo) Count(_ context.Context, _ repository.SubmissionFilter) (int, error) { return 1, nil }
+func (m *conflictMemorySubmissionRepo) List(_ context.Context, _ listquery.Spec) ([]domain.Submission, string, error) { return []domain.Submission{m.sub}, "", nil }
+func (m *conflictMemorySubmissionRepo) Count(_ context.Context, _ listquery.Spec) (int, error) { return 1, nil }
 
 // TestSubmission_StatusUpdate_Conflict 验证并发状态更新产生 409 CONFLICT。
 func TestSubmission_StatusUpdate_Conflict(t *testing.T) {
-    os.Setenv("JWT_SECRET", "test-secret")
     // 初始 submission：pending
     sub := domain.Submission{ID: "sub-conflict-1", UserID: "u1", ProblemID: "p1", Language: "go", Code: "print", Status: "pending", CreatedAt: time.Now().UTC(), UpdatedAt: time.Now().UTC(), Version: 1}
     repo := newConflictRepo(sub)
-    deps := router.Dependencies{SubmissionRepo: repo, Env: "test"}
+    deps := router.Dependencies{JWTSecret: "test-secret", SubmissionRepo: repo, Env: "test"}
     r := router.Setup(deps)
     srv := httptest.NewServer(r); defer srv.Close()
 
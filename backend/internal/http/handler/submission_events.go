@@ -0,0 +1,138 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/YangYuS8/codyssey/backend/internal/auth"
+	"github.com/YangYuS8/codyssey/backend/internal/events"
+	"github.com/YangYuS8/codyssey/backend/internal/http/errcode"
+	"github.com/YangYuS8/codyssey/backend/internal/metrics"
+	"github.com/YangYuS8/codyssey/backend/internal/service"
+	"github.com/gin-gonic/gin"
+)
+
+// EventStreamHeartbeat 注释行心跳间隔，防止代理因空闲断开连接（测试可调小）。
+var EventStreamHeartbeat = 25 * time.Second
+
+// eventStreamRetryMS 建议客户端重连间隔
+const eventStreamRetryMS = 3000
+
+// SubmissionEvents GET /submissions/:id/events：单个提交的实时事件（SSE）。
+// 仅提交者或 teacher/system_admin 可订阅。新连接先推送当前状态快照；携带 Last-Event-ID 时改为从状态日志补发。
+func SubmissionEvents(s *service.SubmissionService, hub *events.Hub) gin.HandlerFunc {
+    return func(c *gin.Context) {
+        id := auth.GetIdentity(c)
+        if id == nil || id.UserID == "guest" {
+            respondError(c, http.StatusUnauthorized, "UNAUTHORIZED", "login required")
+            return
+        }
+        subID := strings.TrimSpace(c.Param("id"))
+        sub, err := s.Get(c.Request.Context(), subID)
+        if err != nil {
+            if errors.Is(err, service.ErrSubmissionNotFound) {
+                respondError(c, http.StatusNotFound, "NOT_FOUND", "submission not found")
+                return
+            }
+            respondError(c, http.StatusInternalServerError, "GET_FAILED", err.Error())
+            return
+        }
+        if sub.UserID != id.UserID && !hasAnyRole(id, auth.RoleSystemAdmin, auth.RoleTeacher) {
+            respondError(c, http.StatusForbidden, errcode.CodeForbidden, errcode.Text(errcode.CodeForbidden))
+            return
+        }
+        initial := func(ctx context.Context, since time.Time, resume bool) ([]events.Event, error) {
+            if resume { return s.EventsSince(ctx, since, sub.ID, "") }
+            return []events.Event{{ID: events.IDAt(sub.UpdatedAt), Type: events.TypeStatusUpdate, SubmissionID: sub.ID, UserID: sub.UserID,
+                Payload: map[string]any{"status": sub.Status}}}, nil
+        }
+        streamEvents(c, hub, func(ev events.Event) bool { return ev.SubmissionID == sub.ID }, initial)
+    }
+}
+
+// AllSubmissionEvents GET /submissions/events：teacher/system_admin 接收全部提交事件，其余用户仅接收自己的。
+func AllSubmissionEvents(s *service.SubmissionService, hub *events.Hub) gin.HandlerFunc {
+    return func(c *gin.Context) {
+        id := auth.GetIdentity(c)
+        if id == nil || id.UserID == "guest" {
+            respondError(c, http.StatusUnauthorized, "UNAUTHORIZED", "login required")
+            return
+        }
+        owner := id.UserID
+        if hasAnyRole(id, auth.RoleSystemAdmin, auth.RoleTeacher) { owner = "" }
+        match := func(ev events.Event) bool { return ev.SubmissionID != "" && (owner == "" || ev.UserID == owner) }
+        initial := func(ctx context.Context, since time.Time, resume bool) ([]events.Event, error) {
+            if !resume { return nil, nil }
+            return s.EventsSince(ctx, since, "", owner)
+        }
+        streamEvents(c, hub, match, initial)
+    }
+}
+
+// lastEventID 优先取 Last-Event-ID 头（浏览器自动重连时携带），其次 ?last_event_id=。
+func lastEventID(c *gin.Context) (time.Time, bool) {
+    raw := strings.TrimSpace(c.GetHeader("Last-Event-ID"))
+    if raw == "" { raw = strings.TrimSpace(c.Query("last_event_id")) }
+    if raw == "" { return time.Time{}, false }
+    return events.ParseID(raw)
+}
+
+// streamEvents 先订阅再补发，避免补发期间产生的事件丢失；补发过的事件在实时流中去重。
+func streamEvents(c *gin.Context, hub *events.Hub, match func(events.Event) bool,
+    initial func(ctx context.Context, since time.Time, resume bool) ([]events.Event, error)) {
+    ctx := c.Request.Context()
+    sub := hub.Subscribe(match)
+    defer sub.Close()
+    since, resume := lastEventID(c)
+    backlog, err := initial(ctx, since, resume)
+    if err != nil { respondError(c, http.StatusInternalServerError, "GET_FAILED", err.Error()); return }
+    defer metrics.SSEConnected()()
+
+    h := c.Writer.Header()
+    h.Set("Content-Type", "text/event-stream")
+    h.Set("Cache-Control", "no-cache")
+    h.Set("Connection", "keep-alive")
+    h.Set("X-Accel-Buffering", "no") // 关闭 nginx 缓冲
+    c.Status(http.StatusOK)
+    fmt.Fprintf(c.Writer, "retry: %d\n\n", eventStreamRetryMS)
+
+    sent := make(map[string]struct{}, len(backlog))
+    for _, ev := range backlog {
+        if writeEvent(c, ev) != nil { return }
+        sent[eventKey(ev)] = struct{}{}
+    }
+    c.Writer.Flush()
+
+    ticker := time.NewTicker(EventStreamHeartbeat)
+    defer ticker.Stop()
+    for {
+        select {
+        case <-ctx.Done():
+            return
+        case <-sub.Done():
+            return // 消费过慢被丢弃，客户端重连后按 Last-Event-ID 补发
+        case ev := <-sub.C():
+            if _, dup := sent[eventKey(ev)]; dup { continue }
+            if writeEvent(c, ev) != nil { return }
+            c.Writer.Flush()
+        case <-ticker.C:
+            if _, err := fmt.Fprint(c.Writer, ": ping\n\n"); err != nil { return }
+            c.Writer.Flush()
+        }
+    }
+}
+
+func eventKey(ev events.Event) string { return ev.ID + "|" + ev.Type + "|" + ev.SubmissionID }
+
+// writeEvent 不写 event: 字段，前端统一由 onmessage 按 data.type 分发。
+func writeEvent(c *gin.Context, ev events.Event) error {
+    b, err := json.Marshal(ev)
+    if err != nil { return err }
+    _, err = fmt.Fprintf(c.Writer, "id: %s\ndata: %s\n\n", ev.ID, b)
+    return err
+}
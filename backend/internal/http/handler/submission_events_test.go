@@ -0,0 +1,137 @@
+package handler
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/require"
+
+	"github.com/YangYuS8/codyssey/backend/internal/auth"
+	"github.com/YangYuS8/codyssey/backend/internal/domain"
+	"github.com/YangYuS8/codyssey/backend/internal/events"
+	"github.com/YangYuS8/codyssey/backend/internal/repository"
+	"github.com/YangYuS8/codyssey/backend/internal/service"
+)
+
+type sseMessage struct {
+    ID   string
+    Data map[string]any
+}
+
+// readSSE 读取 data 事件（跳过 retry 与注释行）。
+func readSSE(t *testing.T, sc *bufio.Scanner, n int) []sseMessage {
+    t.Helper()
+    out := make([]sseMessage, 0, n)
+    cur := sseMessage{}
+    for len(out) < n && sc.Scan() {
+        line := sc.Text()
+        switch {
+        case strings.HasPrefix(line, "id: "):
+            cur.ID = line[4:]
+        case strings.HasPrefix(line, "data: "):
+            require.NoError(t, json.Unmarshal([]byte(line[6:]), &cur.Data))
+        case line == "" && cur.Data != nil:
+            out = append(out, cur)
+            cur = sseMessage{}
+        }
+    }
+    require.Len(t, out, n, "stream ended early")
+    return out
+}
+
+func newEventsTestServer(t *testing.T, userID string, roles ...string) (*httptest.Server, *service.SubmissionService, *repository.MemorySubmissionRepository, *events.Hub) {
+    gin.SetMode(gin.TestMode)
+    subRepo := repository.NewMemorySubmissionRepository()
+    ss := service.NewSubmissionService(subRepo, repository.NewMemorySubmissionStatusLogRepository(), service.SubmissionOptions{})
+    hub := events.NewHub()
+    ss.EnableEvents(hub)
+    r := gin.New()
+    r.Use(func(c *gin.Context) {
+        c.Set("__identity", &auth.Identity{UserID: userID, Roles: roles, Permissions: map[auth.Permission]struct{}{}})
+        c.Next()
+    })
+    r.GET("/submissions/events", AllSubmissionEvents(ss, hub))
+    r.GET("/submissions/:id/events", SubmissionEvents(ss, hub))
+    srv := httptest.NewServer(r)
+    t.Cleanup(func() { hub.Close(); srv.Close() })
+    return srv, ss, subRepo, hub
+}
+
+func openStream(t *testing.T, url, lastEventID string) *bufio.Scanner {
+    t.Helper()
+    req, _ := http.NewRequest(http.MethodGet, url, nil)
+    req.Header.Set("Accept", "text/event-stream")
+    if lastEventID != "" { req.Header.Set("Last-Event-ID", lastEventID) }
+    resp, err := http.DefaultClient.Do(req)
+    require.NoError(t, err)
+    t.Cleanup(func() { resp.Body.Close() })
+    require.Equal(t, http.StatusOK, resp.StatusCode)
+    require.Equal(t, "text/event-stream", resp.Header.Get("Content-Type"))
+    return bufio.NewScanner(resp.Body)
+}
+
+func waitSubscribers(t *testing.T, hub *events.Hub, n int) {
+    require.Eventually(t, func() bool { return hub.Subscribers() == n }, time.Second, 5*time.Millisecond)
+}
+
+func TestSubmissionEventsStreamAndResume(t *testing.T) {
+    srv, ss, subRepo, hub := newEventsTestServer(t, "u1", auth.RoleStudent)
+    ctx := context.Background()
+    sub, err := ss.Create(ctx, "u1", "p1", "go", "package main")
+    require.NoError(t, err)
+
+    sc := openStream(t, srv.URL+"/submissions/"+sub.ID+"/events", "")
+    snapshot := readSSE(t, sc, 1)[0]
+    require.Equal(t, "status_update", snapshot.Data["type"])
+    require.Equal(t, map[string]any{"status": "pending"}, snapshot.Data["payload"])
+    waitSubscribers(t, hub, 1)
+
+    _, err = ss.UpdateStatus(ctx, sub.ID, service.SubmissionStatusJudging)
+    require.NoError(t, err)
+    judging := readSSE(t, sc, 1)[0]
+    require.Equal(t, sub.ID, judging.Data["submissionId"])
+    require.Equal(t, "judging", judging.Data["payload"].(map[string]any)["status"])
+    _, err = ss.UpdateStatus(ctx, sub.ID, service.SubmissionStatusAccepted)
+    require.NoError(t, err)
+    msgs := readSSE(t, sc, 2)
+    require.Equal(t, "status_update", msgs[0].Data["type"])
+    require.Equal(t, "completed", msgs[1].Data["type"])
+
+    // 断线续传：从 judging 之后补发 accepted + completed
+    sc2 := openStream(t, srv.URL+"/submissions/"+sub.ID+"/events", judging.ID)
+    replay := readSSE(t, sc2, 2)
+    require.Equal(t, "accepted", replay[0].Data["payload"].(map[string]any)["status"])
+    require.Equal(t, "completed", replay[1].Data["type"])
+
+    // 非提交者不可订阅
+    other := domain.Submission{ID: "other", UserID: "u2", ProblemID: "p1", Language: "go", Code: "x", Status: "pending", Version: 1}
+    require.NoError(t, subRepo.Create(ctx, other))
+    resp, err := http.Get(srv.URL + "/submissions/other/events")
+    require.NoError(t, err)
+    resp.Body.Close()
+    require.Equal(t, http.StatusForbidden, resp.StatusCode)
+}
+
+func TestAllSubmissionEventsScopedToCaller(t *testing.T) {
+    srv, ss, _, hub := newEventsTestServer(t, "u1", auth.RoleStudent)
+    ctx := context.Background()
+    mine, _ := ss.Create(ctx, "u1", "p1", "go", "a")
+    theirs, _ := ss.Create(ctx, "u2", "p1", "go", "b")
+
+    sc := openStream(t, srv.URL+"/submissions/events", "")
+    waitSubscribers(t, hub, 1)
+    _, err := ss.UpdateStatus(ctx, theirs.ID, service.SubmissionStatusJudging)
+    require.NoError(t, err)
+    _, err = ss.UpdateStatus(ctx, mine.ID, service.SubmissionStatusJudging)
+    require.NoError(t, err)
+    got := readSSE(t, sc, 1)[0]
+    require.Equal(t, mine.ID, got.Data["submissionId"])
+    require.NotContains(t, got.Data, "UserID")
+}
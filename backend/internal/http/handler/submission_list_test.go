@@ -2,10 +2,11 @@ package handler_test
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
-	"os"
+	"net/url"
 	"testing"
 	"time"
 
@@ -13,6 +14,7 @@ import (
 	"github.com/stretchr/testify/require"
 
 	"github.com/YangYuS8/codyssey/backend/internal/auth"
+	"github.com/YangYuS8/codyssey/backend/internal/domain"
 	"github.com/YangYuS8/codyssey/backend/internal/http/router"
 	"github.com/YangYuS8/codyssey/backend/internal/repository"
 )
@@ -32,9 +34,8 @@ func makeTokenList(t *testing.T, secret, userID string, roles []string, perms []
 }
 
 func TestSubmission_List_Visibility_And_Filter(t *testing.T) {
-    os.Setenv("JWT_SECRET", "test-secret")
     repo := repository.NewMemorySubmissionRepository()
-    deps := router.Dependencies{SubmissionRepo: repo}
+    deps := router.Dependencies{JWTSecret: "test-secret", SubmissionRepo: repo}
     r := router.Setup(deps)
     srv := httptest.NewServer(r); defer srv.Close()
 
@@ -102,3 +103,56 @@ func TestSubmission_List_Visibility_And_Filter(t *testing.T) {
     require.Equal(t, "userB", listFilter.Data[0].UserID)
     require.Equal(t, 1, listFilter.Meta.Total)
 }
+
+func TestSubmission_List_CursorPaging(t *testing.T) {
+    repo := repository.NewMemorySubmissionRepository()
+    deps := router.Dependencies{JWTSecret: "test-secret", SubmissionRepo: repo}
+    r := router.Setup(deps)
+    srv := httptest.NewServer(r); defer srv.Close()
+    teacherToken := makeTokenList(t, "test-secret", "teacher1", []string{auth.RoleTeacher}, nil)
+
+    base := time.Now().UTC().Add(-time.Hour)
+    for i := 0; i < 5; i++ {
+        require.NoError(t, repo.Create(context.Background(), domain.Submission{UserID: "u1", ProblemID: "p1", Language: []string{"go", "python"}[i%2], Code: "x", Status: "pending", CreatedAt: base.Add(time.Duration(i) * time.Minute)}))
+    }
+
+    type page struct {
+        Data []struct { ID string `json:"id"`; Language string `json:"language"` } `json:"data"`
+        Meta struct { Count int `json:"count"`; Total int `json:"total"`; NextCursor string `json:"next_cursor"` } `json:"meta"`
+    }
+    get := func(query string) (int, page) {
+        req,_ := http.NewRequest(http.MethodGet, srv.URL+"/submissions?"+query, nil)
+        req.Header.Set("Authorization", "Bearer "+teacherToken)
+        resp, err := http.DefaultClient.Do(req)
+        require.NoError(t, err)
+        defer resp.Body.Close()
+        var p page
+        _ = json.NewDecoder(resp.Body).Decode(&p)
+        return resp.StatusCode, p
+    }
+
+    seen := map[string]bool{}
+    status, p := get("limit=2")
+    require.Equal(t, http.StatusOK, status)
+    require.Len(t, p.Data, 2)
+    require.NotEmpty(t, p.Meta.NextCursor)
+    for pages := 1; ; pages++ {
+        require.Less(t, pages, 5)
+        for _, it := range p.Data { require.False(t, seen[it.ID]); seen[it.ID] = true }
+        if p.Meta.NextCursor == "" { break }
+        status, p = get("limit=2&cursor=" + url.QueryEscape(p.Meta.NextCursor))
+        require.Equal(t, http.StatusOK, status)
+    }
+    require.Len(t, seen, 5)
+
+    status, p = get("language[in]=go&sort=created_at")
+    require.Equal(t, http.StatusOK, status)
+    require.Len(t, p.Data, 3)
+    require.Equal(t, 3, p.Meta.Total)
+    require.Empty(t, p.Meta.NextCursor)
+
+    for _, q := range []string{"limit=1000", "sort=code", "status[gt]=x", "created_at[gte]=bad", "cursor=zzz"} {
+        status, _ = get(q)
+        require.Equal(t, http.StatusBadRequest, status, q)
+    }
+}
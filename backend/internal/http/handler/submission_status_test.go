@@ -5,7 +5,6 @@ import (
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
-	"os"
 	"testing"
 	"time"
 
@@ -58,10 +57,9 @@ func patchStatus(t *testing.T, baseURL, token, id, status string) (*http.Respons
 }
 
 func TestSubmission_Status_Transitions(t *testing.T) {
-    os.Setenv("JWT_SECRET", "test-secret")
     repo := repository.NewMemorySubmissionRepository()
     logRepo := repository.NewMemorySubmissionStatusLogRepository()
-    deps := router.Dependencies{SubmissionRepo: repo, SubmissionStatusLogRepo: logRepo}
+    deps := router.Dependencies{JWTSecret: "test-secret", SubmissionRepo: repo, SubmissionStatusLogRepo: logRepo}
     r := router.Setup(deps)
     srv := httptest.NewServer(r); defer srv.Close()
 
@@ -97,10 +95,9 @@ func TestSubmission_Status_Transitions(t *testing.T) {
 }
 
 func TestSubmission_StatusLogs(t *testing.T) {
-    os.Setenv("JWT_SECRET", "test-secret")
     repo := repository.NewMemorySubmissionRepository()
     logRepo := repository.NewMemorySubmissionStatusLogRepository()
-    deps := router.Dependencies{SubmissionRepo: repo, SubmissionStatusLogRepo: logRepo}
+    deps := router.Dependencies{JWTSecret: "test-secret", SubmissionRepo: repo, SubmissionStatusLogRepo: logRepo}
     r := router.Setup(deps)
     srv := httptest.NewServer(r); defer srv.Close()
 
@@ -2,11 +2,11 @@ package handler_test
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"io"
 	"net/http"
 	"net/http/httptest"
-	"os"
 	"testing"
 	"time"
 
@@ -16,6 +16,7 @@ import (
 	"github.com/YangYuS8/codyssey/backend/internal/auth"
 	"github.com/YangYuS8/codyssey/backend/internal/domain"
 	"github.com/YangYuS8/codyssey/backend/internal/http/router"
+	"github.com/YangYuS8/codyssey/backend/internal/listquery"
 	"github.com/YangYuS8/codyssey/backend/internal/repository"
 )
 
@@ -34,9 +35,8 @@ func makeToken(t *testing.T, secret, userID string, roles []string) string {
 }
 
 func TestSubmission_Create_Unauthorized(t *testing.T) {
-    os.Setenv("JWT_SECRET", "test-secret")
     memSubRepo := repository.NewMemorySubmissionRepository()
-    deps := router.Dependencies{SubmissionRepo: memSubRepo}
+    deps := router.Dependencies{JWTSecret: "test-secret", SubmissionRepo: memSubRepo}
     r := router.Setup(deps)
     ts := httptest.NewServer(r); defer ts.Close()
     body := map[string]string{"problem_id":"p1","language":"go","code":"print(1)"}
@@ -47,9 +47,8 @@ func TestSubmission_Create_Unauthorized(t *testing.T) {
 }
 
 func TestSubmission_Create_And_Get_Visibility(t *testing.T) {
-    os.Setenv("JWT_SECRET", "test-secret")
     memSubRepo := repository.NewMemorySubmissionRepository()
-    deps := router.Dependencies{SubmissionRepo: memSubRepo}
+    deps := router.Dependencies{JWTSecret: "test-secret", SubmissionRepo: memSubRepo}
     r := router.Setup(deps)
     server := httptest.NewServer(r); defer server.Close()
 
@@ -99,3 +98,58 @@ func TestSubmission_Create_And_Get_Visibility(t *testing.T) {
     require.Equal(t, "print('hi')", gotTeacher.Data.Code)
 }
 
+
+// service 令牌不代表具体用户：创建提交返回 403，而不是把 "service:<id>" 写入 user_id。
+func TestSubmission_Create_ServiceTokenForbidden(t *testing.T) {
+    tokens := auth.NewAPITokenService(repository.NewMemoryAPITokenRepository(), repository.NewMemoryUserRepository())
+    admin := &auth.Identity{UserID: "admin1", Roles: []string{auth.RoleSystemAdmin}, Permissions: map[auth.Permission]struct{}{auth.PermAPITokenManage: {}, auth.PermJudgeRunManage: {}}}
+    out, err := tokens.Issue(context.Background(), admin, auth.IssueAPITokenInput{Name: "worker", Kind: domain.APITokenKindService, Permissions: []auth.Permission{auth.PermJudgeRunManage}})
+    require.NoError(t, err)
+    memSubRepo := repository.NewMemorySubmissionRepository()
+    ts := httptest.NewServer(router.Setup(router.Dependencies{JWTSecret: "test-secret", SubmissionRepo: memSubRepo, APITokens: tokens})); defer ts.Close()
+    b, _ := json.Marshal(map[string]string{"problem_id": "p1", "language": "go", "code": "print(1)"})
+    req, _ := http.NewRequest(http.MethodPost, ts.URL+"/submissions", bytes.NewReader(b))
+    req.Header.Set("Authorization", "Token "+out.Token)
+    req.Header.Set("Content-Type", "application/json")
+    resp, err := http.DefaultClient.Do(req)
+    require.NoError(t, err)
+    defer resp.Body.Close()
+    require.Equal(t, http.StatusForbidden, resp.StatusCode)
+    subs, _, err := memSubRepo.List(context.Background(), listquery.Spec{Limit: 10})
+    require.NoError(t, err)
+    require.Empty(t, subs)
+}
+
+// 学生只能向 public 且已发布的题目提交；教师（problem.update）可向未发布或 private 题目提交用于验题。
+func TestSubmission_Create_HiddenProblem(t *testing.T) {
+    ctx := context.Background()
+    problems := repository.NewMemoryProblemRepository()
+    memSubRepo := repository.NewMemorySubmissionRepository()
+    ts := httptest.NewServer(router.Setup(router.Dependencies{JWTSecret: "test-secret", ProblemRepo: problems, ProblemReviewRepo: problems, SubmissionRepo: memSubRepo})); defer ts.Close()
+    student := makeToken(t, "test-secret", "stu1", []string{auth.RoleStudent})
+    teacher := makeToken(t, "test-secret", "teacher1", []string{auth.RoleTeacher})
+    problem := func(status, visibility string) string {
+        p := domain.NewProblem("Sum "+status+" "+visibility, "add two numbers")
+        p.Status, p.Visibility = status, visibility
+        require.NoError(t, problems.Create(ctx, p))
+        return p.ID.String()
+    }
+    submit := func(token, problemID string) int {
+        code, _ := aiCheckReq(t, ts, http.MethodPost, "/submissions", token, map[string]string{"problem_id": problemID, "language": "go", "code": "package main"})
+        return code
+    }
+
+    for _, id := range []string{
+        problem(domain.ProblemStatusDraft, domain.VisibilityPublic),
+        problem(domain.ProblemStatusPendingReview, domain.VisibilityPublic),
+        problem(domain.ProblemStatusArchived, domain.VisibilityPublic),
+        problem(domain.ProblemStatusPublished, domain.VisibilityPrivate),
+    } {
+        require.Equal(t, http.StatusNotFound, submit(student, id), id)
+        require.Equal(t, http.StatusCreated, submit(teacher, id), id)
+    }
+    require.Equal(t, http.StatusCreated, submit(student, problem(domain.ProblemStatusPublished, domain.VisibilityPublic)))
+    subs, _, err := memSubRepo.List(ctx, listquery.Spec{Limit: 20})
+    require.NoError(t, err)
+    require.Len(t, subs, 5)
+}
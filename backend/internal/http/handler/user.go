@@ -1,30 +1,41 @@
 package handler
 
 import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
 	"net/http"
 	"strconv"
 	"strings"
+	"time"
 
+	"github.com/YangYuS8/codyssey/backend/internal/auth"
+	"github.com/YangYuS8/codyssey/backend/internal/http/errcode"
 	"github.com/YangYuS8/codyssey/backend/internal/repository"
 	"github.com/YangYuS8/codyssey/backend/internal/service"
 	"github.com/gin-gonic/gin"
 )
 
 type createUserReq struct {
-    Username string   `json:"username" binding:"required,min=3"`
-    Roles    []string `json:"roles"`
+    Username      string   `json:"username" binding:"required,min=3"`
+    Roles         []string `json:"roles"`
+    Password      string   `json:"password"`
+    Email         string   `json:"email"`
+    DisplayName   string   `json:"display_name"`
+    School        string   `json:"school"`
+    StudentNumber string   `json:"student_number"`
 }
 
 type updateUserRolesReq struct { Roles []string `json:"roles" binding:"required"` }
 
 func ListUsers(us *service.UserService) gin.HandlerFunc {
     return func(c *gin.Context) {
-        limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
-        offset, _ := strconv.Atoi(c.DefaultQuery("offset", "0"))
-        users, err := us.List(c, limit, offset)
+        spec, ok := parseListQuery(c, repository.UserListSchema)
+        if !ok { return }
+        users, next, err := us.List(c, spec)
         if err != nil { respondError(c, http.StatusInternalServerError, "LIST_FAILED", err.Error()); return }
-        meta := map[string]int{"limit": limit, "offset": offset, "count": len(users)}
-        respondOK(c, users, meta)
+        respondOK(c, users, listMeta(spec, len(users), next))
     }
 }
 
@@ -34,9 +45,13 @@ func CreateUser(us *service.UserService) gin.HandlerFunc {
         if err := c.ShouldBindJSON(&req); err != nil { respondError(c, http.StatusBadRequest, "INVALID_REQUEST", err.Error()); return }
         filtered := make([]string, 0, len(req.Roles))
         for _, r := range req.Roles { if s := strings.TrimSpace(r); s != "" { filtered = append(filtered, s) } }
-        u, err := us.Create(c, req.Username, filtered)
+        u, err := us.Create(c, service.CreateUserInput{
+            Username: req.Username, Roles: filtered, Password: req.Password,
+            Email: req.Email, DisplayName: req.DisplayName, School: req.School, StudentNumber: req.StudentNumber,
+        })
         if err != nil {
             if err == service.ErrUserDuplicate { respondError(c, http.StatusConflict, "USER_EXISTS", err.Error()); return }
+            if respondUserError(c, err) { return }
             respondError(c, http.StatusInternalServerError, "CREATE_FAILED", err.Error()); return }
         respondCreated(c, u)
     }
@@ -75,5 +90,226 @@ func DeleteUser(us *service.UserService) gin.HandlerFunc {
     }
 }
 
+// respondUserError 资料 / 密码相关的业务错误；返回 false 表示未识别，由调用方兜底。
+func respondUserError(c *gin.Context, err error) bool {
+    switch {
+    case errors.Is(err, service.ErrUserNotFound):
+        respondError(c, http.StatusNotFound, "NOT_FOUND", "user not found")
+    case errors.Is(err, service.ErrEmailDuplicate):
+        respondError(c, http.StatusConflict, errcode.CodeEmailTaken, errcode.Text(errcode.CodeEmailTaken))
+    case errors.Is(err, service.ErrInvalidEmail):
+        respondError(c, http.StatusBadRequest, errcode.CodeInvalidEmail, errcode.Text(errcode.CodeInvalidEmail))
+    case errors.Is(err, service.ErrProfileFieldTooLong):
+        respondError(c, http.StatusBadRequest, "INVALID_REQUEST", err.Error())
+    case errors.Is(err, service.ErrWeakPassword):
+        respondError(c, http.StatusBadRequest, "WEAK_PASSWORD", err.Error())
+    case errors.Is(err, service.ErrWrongPassword):
+        respondError(c, http.StatusBadRequest, errcode.CodeWrongPassword, errcode.Text(errcode.CodeWrongPassword))
+    case errors.Is(err, service.ErrNoLocalPassword):
+        respondError(c, http.StatusConflict, errcode.CodeNoLocalPassword, errcode.Text(errcode.CodeNoLocalPassword))
+    case errors.Is(err, service.ErrTokenInvalid):
+        respondError(c, http.StatusBadRequest, errcode.CodeInvalidUserToken, errcode.Text(errcode.CodeInvalidUserToken))
+    case errors.Is(err, service.ErrMailDisabled):
+        respondError(c, http.StatusServiceUnavailable, errcode.CodeMailDisabled, errcode.Text(errcode.CodeMailDisabled))
+    case errors.Is(err, service.ErrNoEmail):
+        respondError(c, http.StatusBadRequest, errcode.CodeNoEmail, errcode.Text(errcode.CodeNoEmail))
+    case errors.Is(err, service.ErrEmailAlreadyVerified):
+        respondError(c, http.StatusConflict, errcode.CodeEmailAlreadyVerified, errcode.Text(errcode.CodeEmailAlreadyVerified))
+    default:
+        return false
+    }
+    return true
+}
+
+// selfUserID 当前登录用户；API Token 身份不允许修改资料与密码。
+func selfUserID(c *gin.Context) (string, bool) {
+    id := auth.GetIdentity(c)
+    if id == nil || id.UserID == "" || id.UserID == "guest" {
+        respondError(c, http.StatusUnauthorized, errcode.CodeUnauthorized, errcode.Text(errcode.CodeUnauthorized))
+        return "", false
+    }
+    if id.TokenID != "" {
+        respondError(c, http.StatusForbidden, errcode.CodeForbidden, "not allowed with api token")
+        return "", false
+    }
+    return id.UserID, true
+}
+
+// GetMe GET /users/me
+func GetMe(us *service.UserService) gin.HandlerFunc {
+    return func(c *gin.Context) {
+        uid, ok := selfUserID(c)
+        if !ok { return }
+        u, err := us.Get(c, uid)
+        if err != nil {
+            if respondUserError(c, err) { return }
+            respondError(c, http.StatusInternalServerError, "GET_FAILED", err.Error()); return }
+        respondOK(c, u, nil)
+    }
+}
+
+type updateMeReq struct {
+    DisplayName   *string `json:"display_name"`
+    Email         *string `json:"email"`
+    School        *string `json:"school"`
+    StudentNumber *string `json:"student_number"`
+}
+
+// UpdateMe PATCH /users/me：仅修改提供的字段；修改邮箱后需重新验证。
+func UpdateMe(us *service.UserService) gin.HandlerFunc {
+    return func(c *gin.Context) {
+        uid, ok := selfUserID(c)
+        if !ok { return }
+        var req updateMeReq
+        if err := c.ShouldBindJSON(&req); err != nil { respondError(c, http.StatusBadRequest, "INVALID_REQUEST", err.Error()); return }
+        u, err := us.UpdateProfile(c, uid, service.ProfilePatch{DisplayName: req.DisplayName, Email: req.Email, School: req.School, StudentNumber: req.StudentNumber})
+        if err != nil {
+            if respondUserError(c, err) { return }
+            respondError(c, http.StatusInternalServerError, "UPDATE_FAILED", err.Error()); return }
+        respondOK(c, u, nil)
+    }
+}
+
+type changePasswordReq struct {
+    OldPassword string `json:"old_password"`
+    NewPassword string `json:"new_password" binding:"required"`
+}
+
+// ChangeMyPassword POST /users/me/password
+func ChangeMyPassword(us *service.UserService) gin.HandlerFunc {
+    return func(c *gin.Context) {
+        uid, ok := selfUserID(c)
+        if !ok { return }
+        var req changePasswordReq
+        if err := c.ShouldBindJSON(&req); err != nil { respondError(c, http.StatusBadRequest, "INVALID_REQUEST", err.Error()); return }
+        if err := us.ChangePassword(c, uid, req.OldPassword, req.NewPassword); err != nil {
+            if respondUserError(c, err) { return }
+            respondError(c, http.StatusInternalServerError, "UPDATE_FAILED", err.Error()); return }
+        c.Status(http.StatusNoContent)
+    }
+}
+
+type resetPasswordReq struct { NewPassword string `json:"new_password"` }
+
+// AdminResetPassword POST /users/:id/password：未提供 new_password 时生成随机密码并在响应中返回一次。
+func AdminResetPassword(us *service.UserService) gin.HandlerFunc {
+    return func(c *gin.Context) {
+        var req resetPasswordReq
+        if c.Request.ContentLength != 0 {
+            if err := c.ShouldBindJSON(&req); err != nil { respondError(c, http.StatusBadRequest, "INVALID_REQUEST", err.Error()); return }
+        }
+        generated, err := us.ResetPassword(c, c.Param("id"), req.NewPassword)
+        if err != nil {
+            if respondUserError(c, err) { return }
+            respondError(c, http.StatusInternalServerError, "UPDATE_FAILED", err.Error()); return }
+        data := gin.H{"id": c.Param("id")}
+        if generated != "" { data["generated_password"] = generated }
+        respondOK(c, data, nil)
+    }
+}
+
+// RequestEmailVerification POST /users/me/email/verification
+func RequestEmailVerification(us *service.UserService) gin.HandlerFunc {
+    return func(c *gin.Context) {
+        uid, ok := selfUserID(c)
+        if !ok { return }
+        if err := us.RequestEmailVerification(c, uid); err != nil {
+            if respondUserError(c, err) { return }
+            respondError(c, http.StatusInternalServerError, "MAIL_FAILED", err.Error()); return }
+        c.Status(http.StatusAccepted)
+    }
+}
+
+type userTokenReq struct {
+    Token       string `json:"token" binding:"required"`
+    NewPassword string `json:"new_password"`
+}
+
+// VerifyEmail POST /auth/email/verify
+func VerifyEmail(us *service.UserService) gin.HandlerFunc {
+    return func(c *gin.Context) {
+        var req userTokenReq
+        if err := c.ShouldBindJSON(&req); err != nil { respondError(c, http.StatusBadRequest, "INVALID_REQUEST", err.Error()); return }
+        u, err := us.VerifyEmail(c, req.Token)
+        if err != nil {
+            if respondUserError(c, err) { return }
+            respondError(c, http.StatusInternalServerError, "VERIFY_FAILED", err.Error()); return }
+        respondOK(c, gin.H{"id": u.ID, "email": u.Email, "email_verified": u.EmailVerified}, nil)
+    }
+}
+
+type forgotPasswordReq struct { Login string `json:"login" binding:"required"` }
+
+// ForgotPassword POST /auth/password/forgot：无论账号是否存在都返回 202。
+func ForgotPassword(us *service.UserService) gin.HandlerFunc {
+    return func(c *gin.Context) {
+        var req forgotPasswordReq
+        if err := c.ShouldBindJSON(&req); err != nil { respondError(c, http.StatusBadRequest, "INVALID_REQUEST", err.Error()); return }
+        if err := us.RequestPasswordReset(c, req.Login); err != nil {
+            if respondUserError(c, err) { return }
+            respondError(c, http.StatusInternalServerError, "MAIL_FAILED", err.Error()); return }
+        c.Status(http.StatusAccepted)
+    }
+}
+
+// ResetPassword POST /auth/password/reset
+func ResetPassword(us *service.UserService) gin.HandlerFunc {
+    return func(c *gin.Context) {
+        var req userTokenReq
+        if err := c.ShouldBindJSON(&req); err != nil { respondError(c, http.StatusBadRequest, "INVALID_REQUEST", err.Error()); return }
+        if err := us.ResetPasswordWithToken(c, req.Token, req.NewPassword); err != nil {
+            if respondUserError(c, err) { return }
+            respondError(c, http.StatusInternalServerError, "UPDATE_FAILED", err.Error()); return }
+        c.Status(http.StatusNoContent)
+    }
+}
+
 // 确保引用 repository 错误以保持编译期校验
 var _ = repository.ErrUserNotFound
+
+// ImportUsers POST /users/import?dry_run=true&format=csv
+// 请求体为 CSV（text/csv 原文或 multipart 字段 file）。format=csv 时以附件形式返回凭据清单。
+func ImportUsers(us *service.UserService) gin.HandlerFunc {
+    return func(c *gin.Context) {
+        body := io.Reader(c.Request.Body)
+        if strings.HasPrefix(c.ContentType(), "multipart/") {
+            fh, err := c.FormFile("file")
+            if err != nil { respondError(c, http.StatusBadRequest, "INVALID_REQUEST", "multipart field 'file' required"); return }
+            f, err := fh.Open()
+            if err != nil { respondError(c, http.StatusBadRequest, "INVALID_REQUEST", err.Error()); return }
+            defer f.Close()
+            body = f
+        }
+        dryRun, _ := strconv.ParseBool(c.Query("dry_run"))
+        res, err := us.ImportUsers(c, body, service.ImportOptions{DryRun: dryRun})
+        var headerErr *service.ImportHeaderError
+        switch {
+        case err == nil:
+        case errors.Is(err, service.ErrImportInvalid):
+            c.JSON(http.StatusUnprocessableEntity, ErrorResponse{Data: res, Err: &APIError{Code: errcode.CodeImportInvalid, Message: errcode.Text(errcode.CodeImportInvalid)}})
+            return
+        case errors.As(err, &headerErr):
+            respondError(c, http.StatusBadRequest, errcode.CodeInvalidCSV, err.Error()); return
+        case errors.Is(err, service.ErrImportEmpty), errors.Is(err, service.ErrImportTooLarge):
+            respondError(c, http.StatusBadRequest, errcode.CodeInvalidCSV, err.Error()); return
+        case errors.Is(err, service.ErrUserDuplicate), errors.Is(err, service.ErrEmailDuplicate):
+            // 校验后到写库前被并发创建，事务已回滚
+            respondError(c, http.StatusConflict, "USER_EXISTS", err.Error()); return
+        default:
+            respondError(c, http.StatusInternalServerError, "IMPORT_FAILED", err.Error()); return
+        }
+        c.Header("Cache-Control", "no-store") // 响应含初始密码
+        if c.Query("format") == "csv" && !res.DryRun {
+            c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="credentials-%s.csv"`, time.Now().UTC().Format("20060102-150405")))
+            c.Header("Content-Type", "text/csv; charset=utf-8")
+            c.Status(http.StatusCreated)
+            w := csv.NewWriter(c.Writer)
+            _ = w.Write([]string{"username", "password", "email", "display_name", "roles"})
+            for _, u := range res.Users { _ = w.Write([]string{u.Username, u.Password, u.Email, u.DisplayName, strings.Join(u.Roles, ";")}) }
+            w.Flush()
+            return
+        }
+        if res.DryRun { respondOK(c, res, nil); return }
+        respondCreated(c, res)
+    }
+}
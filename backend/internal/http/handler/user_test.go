@@ -6,11 +6,13 @@ import (
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/YangYuS8/codyssey/backend/internal/auth"
 	"github.com/YangYuS8/codyssey/backend/internal/domain"
+	"github.com/YangYuS8/codyssey/backend/internal/listquery"
 	"github.com/YangYuS8/codyssey/backend/internal/http/handler"
 	"github.com/YangYuS8/codyssey/backend/internal/service"
 	"github.com/gin-gonic/gin"
@@ -19,6 +21,7 @@ import (
 type memUserRepo struct { items []domain.User }
 
 func (m *memUserRepo) Create(_ context.Context, u domain.User) error { m.items = append([]domain.User{u}, m.items...); return nil }
+func (m *memUserRepo) CreateBatch(_ context.Context, users []domain.User) error { m.items = append(users, m.items...); return nil }
 func (m *memUserRepo) GetByID(_ context.Context, id string) (domain.User, error) {
     for _, it := range m.items { if it.ID == id { return it, nil } }
     return domain.User{}, service.ErrUserNotFound
@@ -35,7 +38,22 @@ func (m *memUserRepo) Delete(_ context.Context, id string) error {
     for i, it := range m.items { if it.ID == id { m.items = append(m.items[:i], m.items[i+1:]...); return nil } }
     return service.ErrUserNotFound
 }
-func (m *memUserRepo) List(_ context.Context, limit, offset int) ([]domain.User, error) { return m.items, nil }
+func (m *memUserRepo) List(_ context.Context, spec listquery.Spec) ([]domain.User, string, error) { return m.items, "", nil }
+func (m *memUserRepo) GetByEmail(_ context.Context, email string) (domain.User, error) {
+    for _, it := range m.items { if it.Email != "" && strings.EqualFold(it.Email, email) { return it, nil } }
+    return domain.User{}, service.ErrUserNotFound
+}
+func (m *memUserRepo) UpdateProfile(_ context.Context, u domain.User) error {
+    for i, it := range m.items { if it.ID == u.ID {
+        m.items[i].Email, m.items[i].EmailVerified, m.items[i].DisplayName = u.Email, u.EmailVerified, u.DisplayName
+        m.items[i].School, m.items[i].StudentNumber = u.School, u.StudentNumber
+        return nil } }
+    return service.ErrUserNotFound
+}
+func (m *memUserRepo) UpdatePassword(_ context.Context, id, hash string, at time.Time) error {
+    for i, it := range m.items { if it.ID == id { m.items[i].PasswordHash = hash; m.items[i].PasswordChangedAt = &at; return nil } }
+    return service.ErrUserNotFound
+}
 
 // adapt interface expected by service (context.Context used but tests ignore)
 // Provide wrappers with context.Context signature matching service.UserRepo
@@ -44,13 +62,14 @@ func (m *memUserRepo) List(_ context.Context, limit, offset int) ([]domain.User,
 func setupUserRouter(repo service.UserRepo) *gin.Engine {
     gin.SetMode(gin.TestMode)
     r := gin.New()
-    r.Use(auth.AttachDebugIdentity())
+    r.Use(auth.AttachDebugIdentity(""))
     us := service.NewUserService(repo)
     r.POST("/users", auth.Require(auth.PermUserCreate), handler.CreateUser(us))
     r.GET("/users", auth.Require(auth.PermUserList), handler.ListUsers(us))
     r.GET("/users/:id", auth.Require(auth.PermUserGet), handler.GetUser(us))
     r.PUT("/users/:id/roles", auth.Require(auth.PermUserUpdateRoles), handler.UpdateUserRoles(us))
     r.DELETE("/users/:id", auth.Require(auth.PermUserDelete), handler.DeleteUser(us))
+    r.POST("/users/import", auth.Require(auth.PermUserCreate), handler.ImportUsers(us))
     return r
 }
 
@@ -118,3 +137,63 @@ func TestUserCRUDLifecycle(t *testing.T) {
 
     _ = time.Now() // silence imported time if unused later
 }
+
+func TestUserSelfProfile(t *testing.T) {
+    gin.SetMode(gin.TestMode)
+    repo := &memUserRepo{}
+    _ = repo.Create(context.Background(), domain.User{ID: "u1", Username: "dave", Roles: []string{"student"}})
+    us := service.NewUserService(repo)
+    r := gin.New()
+    r.Use(func(c *gin.Context) {
+        id := &auth.Identity{UserID: c.GetHeader("X-Test-User"), TokenID: c.GetHeader("X-Test-Token"), Permissions: map[auth.Permission]struct{}{}}
+        c.Set("__identity", id)
+        c.Next()
+    })
+    r.PATCH("/users/me", handler.UpdateMe(us))
+
+    patch := func(user, token, body string) *httptest.ResponseRecorder {
+        w := httptest.NewRecorder()
+        req, _ := http.NewRequest(http.MethodPatch, "/users/me", bytes.NewReader([]byte(body)))
+        req.Header.Set("Content-Type", "application/json")
+        req.Header.Set("X-Test-User", user)
+        if token != "" { req.Header.Set("X-Test-Token", token) }
+        r.ServeHTTP(w, req)
+        return w
+    }
+
+    if w := patch("", "", `{"display_name":"D"}`); w.Code != http.StatusUnauthorized { t.Fatalf("expected 401 got %d", w.Code) }
+    if w := patch("u1", "tok-1", `{"display_name":"D"}`); w.Code != http.StatusForbidden { t.Fatalf("api token expected 403 got %d", w.Code) }
+    if w := patch("u1", "", `{"email":"nope"}`); w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), "INVALID_EMAIL") {
+        t.Fatalf("expected INVALID_EMAIL got %d %s", w.Code, w.Body.String())
+    }
+    w := patch("u1", "", `{"display_name":"Dave","email":"dave@example.edu"}`)
+    if w.Code != http.StatusOK { t.Fatalf("expected 200 got %d %s", w.Code, w.Body.String()) }
+    var resp struct { Data domain.User }
+    _ = json.Unmarshal(w.Body.Bytes(), &resp)
+    if resp.Data.DisplayName != "Dave" || resp.Data.Email != "dave@example.edu" || resp.Data.EmailVerified { t.Fatalf("unexpected profile %+v", resp.Data) }
+}
+
+func TestUserImportCredentialsSheet(t *testing.T) {
+    r := setupUserRouter(&memUserRepo{})
+    post := func(url, body string) *httptest.ResponseRecorder {
+        w := httptest.NewRecorder()
+        req, _ := http.NewRequest(http.MethodPost, url, strings.NewReader(body))
+        req.Header.Set("Content-Type", "text/csv")
+        req.Header.Set("X-Debug-Perms", "user.create")
+        r.ServeHTTP(w, req)
+        return w
+    }
+
+    w := post("/users/import", "username,email\nab,not-an-email\n")
+    if w.Code != http.StatusUnprocessableEntity { t.Fatalf("expected 422 got %d %s", w.Code, w.Body.String()) }
+    var invalid struct { Data struct { Errors []map[string]any } }
+    _ = json.Unmarshal(w.Body.Bytes(), &invalid)
+    if len(invalid.Data.Errors) != 2 { t.Fatalf("expected 2 row errors got %+v", invalid.Data.Errors) }
+
+    w = post("/users/import?format=csv", "username,display_name\nstu01,甲\nstu02,乙\n")
+    if w.Code != http.StatusCreated { t.Fatalf("expected 201 got %d %s", w.Code, w.Body.String()) }
+    if !strings.HasPrefix(w.Header().Get("Content-Type"), "text/csv") { t.Fatalf("unexpected content type %s", w.Header().Get("Content-Type")) }
+    if !strings.Contains(w.Header().Get("Content-Disposition"), "attachment") { t.Fatalf("expected attachment") }
+    lines := strings.Split(strings.TrimSpace(w.Body.String()), "\n")
+    if len(lines) != 3 || !strings.HasPrefix(lines[1], "stu01,") { t.Fatalf("unexpected sheet %q", w.Body.String()) }
+}
@@ -0,0 +1,165 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+
+	"github.com/YangYuS8/codyssey/backend/internal/auth"
+	"github.com/YangYuS8/codyssey/backend/internal/http/errcode"
+	"github.com/YangYuS8/codyssey/backend/internal/logging"
+	"github.com/YangYuS8/codyssey/backend/internal/tracing"
+)
+
+// Redacted 脱敏后的占位值
+const Redacted = "[REDACTED]"
+
+// maxLoggedBody 详细模式下记录的请求体上限；超出时只记录长度（截断的 JSON 无法可靠脱敏）
+const maxLoggedBody = 4 << 10
+
+// redactedHeaders 详细模式下需要脱敏的请求头（小写）
+var redactedHeaders = map[string]bool{"authorization": true, "cookie": true, "x-api-key": true}
+
+// redactedQuery 查询参数中需要脱敏的键（事件流 / WebSocket 允许 ?access_token=）
+var redactedQuery = map[string]bool{"access_token": true, "token": true}
+
+// redactedFields JSON 请求体中需要脱敏的字段：提交代码、密码、各类令牌与验证码
+var redactedFields = map[string]bool{
+    "code": true, "password": true, "old_password": true, "new_password": true,
+    "token": true, "access_token": true, "refresh_token": true, "mfa_token": true,
+    "secret": true, "recovery_code": true,
+}
+
+// AccessLogOptions 访问日志配置。
+type AccessLogOptions struct {
+    // SampleRatio 成功请求（状态码 < 400 且未超过 SlowThreshold）的记录比例 [0,1]；
+    // 4xx / 5xx 与慢请求总是记录。
+    SampleRatio float64
+    // SlowThreshold 超过该耗时的请求总是记录；0 表示不按耗时强制记录。
+    SlowThreshold time.Duration
+    // Details 额外记录请求头与 JSON 请求体（已脱敏），仅用于排错。
+    Details bool
+}
+
+// AccessLog 以 zap 输出结构化访问日志（替代 gin.Logger），并把请求级 logger
+// （含 request_id、trace_id）放入 c.Request 的 context，供 handler / service 通过 logging.FromContext 使用。
+// 需放在 TraceID、Tracing 之后。
+func AccessLog(logger *zap.Logger, o AccessLogOptions) gin.HandlerFunc {
+    if logger == nil { logger = zap.NewNop() }
+    return func(c *gin.Context) {
+        start := time.Now()
+        ctx := c.Request.Context()
+        fields := append([]zap.Field{zap.String("request_id", c.GetString("request_id"))}, tracing.LogFields(ctx)...)
+        c.Request = c.Request.WithContext(logging.WithLogger(ctx, logger.With(fields...)))
+        var details []zap.Field
+        if o.Details { details = requestDetails(c) }
+
+        c.Next()
+
+        status := c.Writer.Status()
+        latency := time.Since(start)
+        slow := o.SlowThreshold > 0 && latency >= o.SlowThreshold
+        if status < http.StatusBadRequest && !slow && o.SampleRatio < 1 && rand.Float64() >= o.SampleRatio { return }
+
+        route := c.FullPath()
+        out := c.Writer.Size()
+        if out < 0 { out = 0 }
+        fields = append(fields,
+            zap.String("method", c.Request.Method),
+            zap.String("route", route),
+            zap.String("path", c.Request.URL.Path),
+            zap.Int("status", status),
+            zap.Float64("latency_ms", float64(latency.Microseconds())/1000),
+            zap.Int64("bytes_in", max(c.Request.ContentLength, 0)),
+            zap.Int("bytes_out", out),
+            zap.String("client_ip", c.ClientIP()),
+            zap.String("user_agent", c.Request.UserAgent()),
+        )
+        if q := redactQuery(c.Request.URL.RawQuery); q != "" { fields = append(fields, zap.String("query", q)) }
+        if id := auth.GetIdentity(c); id != nil && id.UserID != "" && id.UserID != "guest" {
+            fields = append(fields, zap.String("user_id", id.UserID))
+        }
+        if slow { fields = append(fields, zap.Bool("slow", true)) }
+        if len(c.Errors) > 0 { fields = append(fields, zap.String("errors", c.Errors.String())) }
+        fields = append(fields, details...)
+
+        switch {
+        case status >= http.StatusInternalServerError:
+            logger.Error("request completed", fields...)
+        case status >= http.StatusBadRequest || slow:
+            logger.Warn("request completed", fields...)
+        default:
+            logger.Info("request completed", fields...)
+        }
+    }
+}
+
+// Recovery 捕获 panic 并通过请求级 logger 记录堆栈（替代 gin.Recovery 的纯文本输出），返回 500。
+func Recovery() gin.HandlerFunc {
+    return gin.CustomRecoveryWithWriter(io.Discard, func(c *gin.Context, err any) {
+        logging.FromContext(c.Request.Context()).Error("panic recovered", zap.Any("panic", err), zap.Stack("stack"))
+        c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"data": nil, "error": gin.H{"code": errcode.CodeInternal, "message": errcode.Text(errcode.CodeInternal)}})
+    })
+}
+
+// requestDetails 记录脱敏后的请求头与 JSON 请求体；读取的请求体原样放回供后续 handler 使用。
+func requestDetails(c *gin.Context) []zap.Field {
+    headers := make(map[string]string, len(c.Request.Header))
+    for k, v := range c.Request.Header {
+        if redactedHeaders[strings.ToLower(k)] { headers[k] = Redacted; continue }
+        headers[k] = strings.Join(v, ", ")
+    }
+    fields := []zap.Field{zap.Any("headers", headers)}
+    if c.Request.Body == nil || !strings.Contains(c.ContentType(), "json") { return fields }
+    buf, err := io.ReadAll(io.LimitReader(c.Request.Body, maxLoggedBody+1))
+    c.Request.Body = readCloser{io.MultiReader(bytes.NewReader(buf), c.Request.Body), c.Request.Body}
+    if err != nil || len(buf) == 0 { return fields }
+    if len(buf) > maxLoggedBody { return append(fields, zap.String("body", "[TRUNCATED]")) }
+    if body, ok := RedactJSON(buf); ok { fields = append(fields, zap.String("body", body)) }
+    return fields
+}
+
+type readCloser struct {
+    io.Reader
+    io.Closer
+}
+
+// RedactJSON 将 JSON 中敏感字段（任意层级）替换为 [REDACTED]；无法解析时返回 false。
+func RedactJSON(b []byte) (string, bool) {
+    var v any
+    if err := json.Unmarshal(b, &v); err != nil { return "", false }
+    out, err := json.Marshal(redactValue(v))
+    if err != nil { return "", false }
+    return string(out), true
+}
+
+func redactValue(v any) any {
+    switch t := v.(type) {
+    case map[string]any:
+        for k, child := range t {
+            if redactedFields[strings.ToLower(k)] { t[k] = Redacted; continue }
+            t[k] = redactValue(child)
+        }
+    case []any:
+        for i, child := range t { t[i] = redactValue(child) }
+    }
+    return v
+}
+
+func redactQuery(raw string) string {
+    if raw == "" { return "" }
+    q, err := url.ParseQuery(raw)
+    if err != nil { return "" }
+    for k := range q {
+        if redactedQuery[strings.ToLower(k)] { q[k] = []string{Redacted} }
+    }
+    return q.Encode()
+}
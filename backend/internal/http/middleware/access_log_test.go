@@ -0,0 +1,102 @@
+package middleware_test
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+
+	"github.com/YangYuS8/codyssey/backend/internal/auth"
+	"github.com/YangYuS8/codyssey/backend/internal/http/middleware"
+	"github.com/YangYuS8/codyssey/backend/internal/logging"
+)
+
+func accessLogRouter(o middleware.AccessLogOptions) (*gin.Engine, *observer.ObservedLogs) {
+    gin.SetMode(gin.TestMode)
+    core, logs := observer.New(zapcore.DebugLevel)
+    r := gin.New()
+    r.Use(middleware.TraceID(), middleware.AccessLog(zap.New(core), o), middleware.Recovery(), auth.AttachDebugIdentity(""))
+    r.POST("/submissions/:id", func(c *gin.Context) {
+        body, _ := io.ReadAll(c.Request.Body)
+        logging.FromContext(c.Request.Context()).Info("handler", zap.Int("body_len", len(body)))
+        c.String(http.StatusCreated, "ok")
+    })
+    r.GET("/missing", func(c *gin.Context) { c.Status(http.StatusNotFound) })
+    r.GET("/panic", func(c *gin.Context) { panic("boom") })
+    return r, logs
+}
+
+func TestAccessLogFieldsAndRequestLogger(t *testing.T) {
+    r, logs := accessLogRouter(middleware.AccessLogOptions{SampleRatio: 1, Details: true})
+    body := `{"language":"go","code":"package main","nested":{"password":"p"}}`
+    req := httptest.NewRequest(http.MethodPost, "/submissions/42?access_token=abc&x=1", strings.NewReader(body))
+    req.Header.Set("Content-Type", "application/json")
+    req.Header.Set("Authorization", "Bearer not-a-valid-jwt")
+    req.Header.Set("X-Request-ID", "rid-1")
+    w := httptest.NewRecorder()
+    r.ServeHTTP(w, req)
+    require.Equal(t, http.StatusCreated, w.Code)
+
+    entries := logs.All()
+    require.Len(t, entries, 2)
+    h := entries[0].ContextMap()
+    require.Equal(t, "handler", entries[0].Message)
+    require.Equal(t, "rid-1", h["request_id"])
+    require.EqualValues(t, len(body), h["body_len"], "request body must be restored after logging")
+
+    f := entries[1].ContextMap()
+    require.Equal(t, "request completed", entries[1].Message)
+    require.Equal(t, zapcore.InfoLevel, entries[1].Level)
+    require.Equal(t, "rid-1", f["request_id"])
+    require.Equal(t, "/submissions/:id", f["route"])
+    require.EqualValues(t, 201, f["status"])
+    require.EqualValues(t, 2, f["bytes_out"])
+    require.Contains(t, f["query"], "access_token=%5BREDACTED%5D")
+    require.NotContains(t, f["body"], "package main")
+    require.Contains(t, f["body"], `"code":"[REDACTED]"`)
+    require.Contains(t, f["body"], `"password":"[REDACTED]"`)
+    require.Contains(t, f["body"], `"language":"go"`)
+    require.Equal(t, middleware.Redacted, f["headers"].(map[string]string)["Authorization"])
+}
+
+func TestAccessLogUserID(t *testing.T) {
+    gin.SetMode(gin.TestMode)
+    core, logs := observer.New(zapcore.DebugLevel)
+    r := gin.New()
+    r.Use(middleware.TraceID(), middleware.AccessLog(zap.New(core), middleware.AccessLogOptions{SampleRatio: 1}))
+    r.Use(func(c *gin.Context) { c.Set("__identity", &auth.Identity{UserID: "u1"}); c.Next() })
+    r.GET("/me", func(c *gin.Context) { c.Status(http.StatusOK) })
+    r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/me", nil))
+    require.Equal(t, "u1", logs.All()[0].ContextMap()["user_id"])
+}
+
+func TestAccessLogSampling(t *testing.T) {
+    r, logs := accessLogRouter(middleware.AccessLogOptions{SampleRatio: 0})
+    req := httptest.NewRequest(http.MethodPost, "/submissions/1", strings.NewReader("{}"))
+    r.ServeHTTP(httptest.NewRecorder(), req)
+    require.Len(t, logs.FilterMessage("request completed").All(), 0, "successful requests sampled out")
+
+    r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/missing", nil))
+    got := logs.FilterMessage("request completed").All()
+    require.Len(t, got, 1, "errors always logged")
+    require.Equal(t, zapcore.WarnLevel, got[0].Level)
+}
+
+func TestRecoveryLogsPanic(t *testing.T) {
+    r, logs := accessLogRouter(middleware.AccessLogOptions{SampleRatio: 1})
+    w := httptest.NewRecorder()
+    r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/panic", nil))
+    require.Equal(t, http.StatusInternalServerError, w.Code)
+    require.Contains(t, w.Body.String(), "INTERNAL_ERROR")
+    require.Len(t, logs.FilterMessage("panic recovered").All(), 1)
+    done := logs.FilterMessage("request completed").All()
+    require.Len(t, done, 1)
+    require.Equal(t, zapcore.ErrorLevel, done[0].Level)
+}
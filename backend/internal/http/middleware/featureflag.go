@@ -0,0 +1,33 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/YangYuS8/codyssey/backend/internal/auth"
+	"github.com/YangYuS8/codyssey/backend/internal/featureflag"
+	"github.com/YangYuS8/codyssey/backend/internal/http/errcode"
+	"github.com/gin-gonic/gin"
+)
+
+// FlagSubject 当前请求身份对应的开关求值主体；未登录为匿名。
+func FlagSubject(c *gin.Context) featureflag.Subject {
+    id := auth.GetIdentity(c)
+    if id == nil { return featureflag.Subject{} }
+    return featureflag.Subject{UserID: id.UserID, Roles: id.Roles}
+}
+
+// FeatureEnabled handler 内按当前身份判断开关；flags 为 nil 时视为关闭。
+func FeatureEnabled(c *gin.Context, flags *featureflag.Service, key string) bool {
+    return flags != nil && flags.Enabled(c.Request.Context(), key, FlagSubject(c))
+}
+
+// RequireFeature 门控整组路由：开关对当前身份关闭时返回 404 FEATURE_DISABLED（对未放量用户隐藏功能），需挂在身份中间件之后。
+func RequireFeature(flags *featureflag.Service, key string) gin.HandlerFunc {
+    return func(c *gin.Context) {
+        if !FeatureEnabled(c, flags, key) {
+            c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"data": nil, "error": gin.H{"code": errcode.CodeFeatureDisabled, "message": errcode.Text(errcode.CodeFeatureDisabled)}})
+            return
+        }
+        c.Next()
+    }
+}
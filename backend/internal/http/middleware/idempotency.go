@@ -0,0 +1,123 @@
+package middleware
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"github.com/YangYuS8/codyssey/backend/internal/auth"
+	"github.com/YangYuS8/codyssey/backend/internal/domain"
+	"github.com/YangYuS8/codyssey/backend/internal/http/errcode"
+	"github.com/YangYuS8/codyssey/backend/internal/repository"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+    HeaderIdempotencyKey      = "Idempotency-Key"
+    HeaderIdempotentReplayed  = "Idempotent-Replayed"
+    maxIdempotencyKeyLen      = 255
+    maxIdempotentBodyBytes    = 256 * 1024 // 超过则不缓存响应（释放 key）
+    idempotencyStaleAfter     = time.Minute // 处理中记录超过该时长视为原请求已中断
+    idempotencyPurgeInterval  = 10 * time.Minute
+)
+
+// IdempotencyOptions TTL 为 key 保留时长。
+type IdempotencyOptions struct {
+    TTL time.Duration
+}
+
+// recordingWriter 同时写出并缓存响应体
+type recordingWriter struct {
+    gin.ResponseWriter
+    buf      bytes.Buffer
+    overflow bool
+}
+
+func (w *recordingWriter) Write(b []byte) (int, error) {
+    if !w.overflow {
+        if w.buf.Len()+len(b) > maxIdempotentBodyBytes { w.overflow = true; w.buf.Reset() } else { w.buf.Write(b) }
+    }
+    return w.ResponseWriter.Write(b)
+}
+
+func (w *recordingWriter) WriteString(s string) (int, error) { return w.Write([]byte(s)) }
+
+func abortIdempotency(c *gin.Context, status int, code string) {
+    c.AbortWithStatusJSON(status, gin.H{"data": nil, "error": gin.H{"code": code, "message": errcode.Text(code)}})
+}
+
+// Idempotency 处理 Idempotency-Key 请求头（未携带时直通）：
+//   - 同一身份、同一路由、同一 key 的重试直接重放首次响应（附 Idempotent-Replayed: true）；
+//   - key 相同但请求体不同返回 422 IDEMPOTENCY_KEY_REUSED；
+//   - 首个请求仍在处理中返回 409 IDEMPOTENCY_IN_PROGRESS。
+// 5xx 与 429 不缓存，客户端可用同一 key 重试。需挂在身份中间件与权限校验之后。
+func Idempotency(repo repository.IdempotencyRepository, opts IdempotencyOptions) gin.HandlerFunc {
+    if repo == nil { return func(c *gin.Context) { c.Next() } }
+    if opts.TTL <= 0 { opts.TTL = 24 * time.Hour }
+    var lastPurge atomic.Int64
+    return func(c *gin.Context) {
+        key := c.GetHeader(HeaderIdempotencyKey)
+        if key == "" { c.Next(); return }
+        if len(key) > maxIdempotencyKeyLen { abortIdempotency(c, http.StatusBadRequest, errcode.CodeInvalidIdempotencyKey); return }
+
+        var body []byte
+        if c.Request.Body != nil {
+            b, err := io.ReadAll(c.Request.Body)
+            if err != nil { abortIdempotency(c, http.StatusRequestEntityTooLarge, "PAYLOAD_TOO_LARGE"); return }
+            body = b
+            c.Request.Body = io.NopCloser(bytes.NewReader(body))
+        }
+
+        now := time.Now().UTC()
+        if last := lastPurge.Load(); now.Unix()-last > int64(idempotencyPurgeInterval.Seconds()) && lastPurge.CompareAndSwap(last, now.Unix()) {
+            _, _ = repo.PurgeExpired(c.Request.Context(), now)
+        }
+
+        owner := "guest:" + c.ClientIP()
+        if id := auth.GetIdentity(c); id != nil && id.UserID != "" && id.UserID != "guest" { owner = "user:" + id.UserID }
+        scoped := owner + "|" + c.Request.Method + " " + c.FullPath() + "|" + key
+        sum := sha256.Sum256(append([]byte(c.Request.Method+" "+c.Request.URL.Path+"\n"), body...))
+        fp := hex.EncodeToString(sum[:])
+
+        rec := domain.IdempotencyRecord{Key: scoped, Fingerprint: fp, CreatedAt: now, ExpiresAt: now.Add(opts.TTL)}
+        existing, reserved, err := repo.Reserve(c.Request.Context(), rec, now.Add(-idempotencyStaleAfter))
+        if err != nil {
+            if err == repository.ErrIdempotencyConflict { c.Header("Retry-After", "1"); abortIdempotency(c, http.StatusConflict, errcode.CodeIdempotencyInProgress); return }
+            abortIdempotency(c, http.StatusInternalServerError, "INTERNAL_ERROR"); return
+        }
+        if !reserved {
+            switch {
+            case existing.Fingerprint != fp:
+                abortIdempotency(c, http.StatusUnprocessableEntity, errcode.CodeIdempotencyKeyReused)
+            case !existing.Completed():
+                c.Header("Retry-After", "1")
+                abortIdempotency(c, http.StatusConflict, errcode.CodeIdempotencyInProgress)
+            default:
+                c.Header(HeaderIdempotentReplayed, "true")
+                c.Data(existing.Status, existing.ContentType, existing.Body)
+                c.Abort()
+            }
+            return
+        }
+
+        w := &recordingWriter{ResponseWriter: c.Writer}
+        c.Writer = w
+        defer func() {
+            // panic 由外层 Recovery 转成 500，这里先释放 key 再继续向上抛
+            if p := recover(); p != nil { _ = repo.Release(c.Request.Context(), scoped); panic(p) }
+        }()
+        c.Next()
+        c.Writer = w.ResponseWriter
+
+        status := w.Status()
+        if status >= 500 || status == http.StatusTooManyRequests || w.overflow {
+            _ = repo.Release(c.Request.Context(), scoped)
+            return
+        }
+        _ = repo.Complete(c.Request.Context(), scoped, status, w.Header().Get("Content-Type"), w.buf.Bytes())
+    }
+}
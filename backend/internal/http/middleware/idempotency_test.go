@@ -0,0 +1,113 @@
+package middleware_test
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/require"
+
+	"github.com/YangYuS8/codyssey/backend/internal/auth"
+	"github.com/YangYuS8/codyssey/backend/internal/domain"
+	"github.com/YangYuS8/codyssey/backend/internal/http/middleware"
+	"github.com/YangYuS8/codyssey/backend/internal/repository"
+)
+
+func TestIdempotency(t *testing.T) {
+    gin.SetMode(gin.TestMode)
+    repo := repository.NewMemoryIdempotencyRepository()
+    calls := 0
+    failNext := false
+    r := gin.New()
+    r.Use(func(c *gin.Context) {
+        if uid := c.GetHeader("X-Test-User"); uid != "" { c.Set("__identity", &auth.Identity{UserID: uid}) }
+        c.Next()
+    })
+    r.POST("/submissions", middleware.Idempotency(repo, middleware.IdempotencyOptions{TTL: time.Hour}), func(c *gin.Context) {
+        calls++
+        if failNext { failNext = false; c.JSON(http.StatusInternalServerError, gin.H{"error": "boom"}); return }
+        c.JSON(http.StatusCreated, gin.H{"data": gin.H{"n": calls}})
+    })
+    do := func(user, key, body string) *httptest.ResponseRecorder {
+        w := httptest.NewRecorder()
+        req, _ := http.NewRequest(http.MethodPost, "/submissions", strings.NewReader(body))
+        req.Header.Set("X-Test-User", user)
+        if key != "" { req.Header.Set(middleware.HeaderIdempotencyKey, key) }
+        r.ServeHTTP(w, req)
+        return w
+    }
+
+    first := do("u1", "k1", `{"code":"a"}`)
+    require.Equal(t, http.StatusCreated, first.Code)
+    again := do("u1", "k1", `{"code":"a"}`)
+    require.Equal(t, http.StatusCreated, again.Code)
+    require.Equal(t, first.Body.String(), again.Body.String())
+    require.Equal(t, "true", again.Header().Get(middleware.HeaderIdempotentReplayed))
+    require.Equal(t, 1, calls, "重放不应再次执行处理器")
+
+    w := do("u1", "k1", `{"code":"b"}`)
+    require.Equal(t, http.StatusUnprocessableEntity, w.Code)
+    require.Contains(t, w.Body.String(), "IDEMPOTENCY_KEY_REUSED")
+
+    // 不同用户的相同 key 互不影响；无 key 直通
+    require.Equal(t, http.StatusCreated, do("u2", "k1", `{"code":"b"}`).Code)
+    require.Equal(t, http.StatusCreated, do("u1", "", `{"code":"a"}`).Code)
+    require.Equal(t, 3, calls)
+
+    // 5xx 不缓存，同 key 可重试
+    failNext = true
+    require.Equal(t, http.StatusInternalServerError, do("u1", "k2", `{}`).Code)
+    require.Equal(t, http.StatusCreated, do("u1", "k2", `{}`).Code)
+    require.Equal(t, 5, calls)
+
+    require.Equal(t, http.StatusBadRequest, do("u1", strings.Repeat("x", 256), `{}`).Code)
+}
+
+func TestIdempotency_InProgressAndExpiry(t *testing.T) {
+    gin.SetMode(gin.TestMode)
+    repo := repository.NewMemoryIdempotencyRepository()
+    ctx := context.Background()
+    now := time.Now().UTC()
+    r := gin.New()
+    r.POST("/x", middleware.Idempotency(repo, middleware.IdempotencyOptions{TTL: time.Hour}), func(c *gin.Context) { c.Status(http.StatusNoContent) })
+
+    // 先占用（模拟另一个副本正在处理同一请求）
+    sum := sha256.Sum256([]byte("POST /x\n"))
+    fp := hex.EncodeToString(sum[:])
+    _, reserved, err := repo.Reserve(ctx, domain.IdempotencyRecord{Key: "guest:192.0.2.1|POST /x|busy", Fingerprint: fp, CreatedAt: now, ExpiresAt: now.Add(time.Hour)}, now.Add(-time.Minute))
+    require.NoError(t, err)
+    require.True(t, reserved)
+    w := httptest.NewRecorder()
+    req, _ := http.NewRequest(http.MethodPost, "/x", nil)
+    req.RemoteAddr = "192.0.2.1:5000"
+    req.Header.Set(middleware.HeaderIdempotencyKey, "busy")
+    r.ServeHTTP(w, req)
+    require.Equal(t, http.StatusConflict, w.Code)
+    require.Equal(t, "1", w.Header().Get("Retry-After"))
+
+    // 过期记录可被重新占用；处理中记录超过 staleBefore 也可接管
+    rec := domain.IdempotencyRecord{Key: "k", Fingerprint: "f", CreatedAt: now, ExpiresAt: now.Add(time.Minute)}
+    _, reserved, _ = repo.Reserve(ctx, rec, now.Add(-time.Minute))
+    require.True(t, reserved)
+    require.NoError(t, repo.Complete(ctx, "k", 201, "application/json", []byte(`{}`)))
+    existing, reserved, _ := repo.Reserve(ctx, rec, now.Add(-time.Minute))
+    require.False(t, reserved)
+    require.Equal(t, 201, existing.Status)
+    later := rec
+    later.CreatedAt, later.ExpiresAt = now.Add(2*time.Minute), now.Add(time.Hour)
+    _, reserved, _ = repo.Reserve(ctx, later, later.CreatedAt.Add(-time.Minute))
+    require.True(t, reserved)
+    existing, reserved, _ = repo.Reserve(ctx, later, later.CreatedAt.Add(time.Second))
+    require.True(t, reserved, "处理中记录早于 staleBefore 时可接管")
+    require.False(t, existing.Completed())
+
+    n, err := repo.PurgeExpired(ctx, now.Add(2*time.Hour))
+    require.NoError(t, err)
+    require.Equal(t, int64(2), n)
+}
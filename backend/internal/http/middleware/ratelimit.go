@@ -0,0 +1,62 @@
+package middleware
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/YangYuS8/codyssey/backend/internal/auth"
+	"github.com/YangYuS8/codyssey/backend/internal/http/errcode"
+	"github.com/YangYuS8/codyssey/backend/internal/metrics"
+	"github.com/YangYuS8/codyssey/backend/internal/ratelimit"
+	"github.com/gin-gonic/gin"
+)
+
+// rateLimitKey 已登录身份按用户计数，匿名（或策略要求）按来源 IP 计数。
+func rateLimitKey(c *gin.Context, p ratelimit.Policy) string {
+    if !p.ByIP {
+        if id := auth.GetIdentity(c); id != nil && id.UserID != "" && id.UserID != "guest" {
+            return p.Group + ":user:" + id.UserID
+        }
+    }
+    return p.Group + ":ip:" + c.ClientIP()
+}
+
+func ceilSeconds(d time.Duration) int { return int(math.Ceil(d.Seconds())) }
+
+// RateLimit 令牌桶限流中间件，需挂在身份中间件之后。
+// 响应头遵循 IETF RateLimit 头草案：RateLimit-Limit / RateLimit-Remaining / RateLimit-Reset（秒）。
+// 存储故障时放行（fail-open），仅计数，避免限流组件拖垮登录与提交。
+func RateLimit(store ratelimit.Store, p ratelimit.Policy) gin.HandlerFunc {
+    if store == nil || !p.Enabled() { return func(c *gin.Context) { c.Next() } }
+    return func(c *gin.Context) { takeToken(c, store, p) }
+}
+
+// RateLimitDynamic 每个请求读取一次当前策略（运行时参数热更新后立即生效）；策略禁用时直通。
+func RateLimitDynamic(store ratelimit.Store, policy func() ratelimit.Policy) gin.HandlerFunc {
+    if store == nil { return func(c *gin.Context) { c.Next() } }
+    return func(c *gin.Context) {
+        p := policy()
+        if !p.Enabled() { c.Next(); return }
+        takeToken(c, store, p)
+    }
+}
+
+func takeToken(c *gin.Context, store ratelimit.Store, p ratelimit.Policy) {
+    d, err := store.Take(c.Request.Context(), rateLimitKey(c, p), p, time.Now())
+    if err != nil { metrics.IncRateLimitStoreError(); c.Next(); return }
+    h := c.Writer.Header()
+    h.Set("RateLimit-Limit", strconv.Itoa(p.Burst))
+    h.Set("RateLimit-Remaining", strconv.Itoa(d.Remaining))
+    h.Set("RateLimit-Reset", strconv.Itoa(ceilSeconds(d.Reset)))
+    if !d.Allowed {
+        retry := ceilSeconds(d.RetryAfter)
+        if retry < 1 { retry = 1 }
+        h.Set("Retry-After", strconv.Itoa(retry))
+        metrics.IncRateLimited(p.Group)
+        c.AbortWithStatusJSON(http.StatusTooManyRequests, gin.H{"data": nil, "error": gin.H{"code": errcode.CodeRateLimited, "message": errcode.Text(errcode.CodeRateLimited)}})
+        return
+    }
+    c.Next()
+}
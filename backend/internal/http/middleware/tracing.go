@@ -0,0 +1,48 @@
+package middleware
+
+import (
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/YangYuS8/codyssey/backend/internal/auth"
+	"github.com/YangYuS8/codyssey/backend/internal/tracing"
+)
+
+// Tracing 为每个请求创建服务端 span：从 W3C traceparent 继续上游链路，span 名为 "METHOD 路由模板"，
+// 并把 span 放入 c.Request 的 context，供 service / 仓库层创建子 span。需放在 TraceID 之后。
+func Tracing() gin.HandlerFunc {
+    return func(c *gin.Context) {
+        ctx := otel.GetTextMapPropagator().Extract(c.Request.Context(), propagation.HeaderCarrier(c.Request.Header))
+        route := c.FullPath()
+        name := c.Request.Method + " " + route
+        if route == "" { name = c.Request.Method } // 未匹配路由，避免以原始路径作 span 名导致基数膨胀
+        ctx, span := tracing.Tracer().Start(ctx, name, trace.WithSpanKind(trace.SpanKindServer), trace.WithAttributes(
+            attribute.String("http.request.method", c.Request.Method),
+            attribute.String("http.route", route),
+            attribute.String("url.path", c.Request.URL.Path),
+            attribute.String("client.address", c.ClientIP()),
+            attribute.String("user_agent.original", c.Request.UserAgent()),
+            attribute.String("http.request_id", c.GetString("request_id")),
+        ))
+        defer span.End()
+        c.Request = c.Request.WithContext(ctx)
+
+        c.Next()
+
+        status := c.Writer.Status()
+        span.SetAttributes(attribute.Int("http.response.status_code", status))
+        if id := auth.GetIdentity(c); id != nil && id.UserID != "" && id.UserID != "guest" {
+            span.SetAttributes(attribute.String("enduser.id", id.UserID))
+        }
+        if status >= 500 {
+            span.SetStatus(codes.Error, strconv.Itoa(status))
+        }
+        if len(c.Errors) > 0 { span.SetAttributes(attribute.String("error.message", c.Errors.String())) }
+    }
+}
@@ -0,0 +1,61 @@
+package middleware_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/YangYuS8/codyssey/backend/internal/http/middleware"
+	"github.com/YangYuS8/codyssey/backend/internal/tracing"
+)
+
+func TestTracingContinuesTraceparent(t *testing.T) {
+    gin.SetMode(gin.TestMode)
+    rec := tracetest.NewSpanRecorder()
+    tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(rec))
+    prevTP, prevProp := otel.GetTracerProvider(), otel.GetTextMapPropagator()
+    otel.SetTracerProvider(tp)
+    otel.SetTextMapPropagator(propagation.TraceContext{})
+    t.Cleanup(func() { otel.SetTracerProvider(prevTP); otel.SetTextMapPropagator(prevProp) })
+
+    r := gin.New()
+    r.Use(middleware.TraceID(), middleware.Tracing())
+    var inner trace.SpanContext
+    r.GET("/items/:id", func(c *gin.Context) {
+        ctx, end := tracing.Start(c.Request.Context(), "ItemService.Get")
+        inner = trace.SpanContextFromContext(ctx)
+        end(nil)
+        c.Status(http.StatusInternalServerError)
+    })
+
+    req := httptest.NewRequest(http.MethodGet, "/items/42", nil)
+    req.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+    w := httptest.NewRecorder()
+    r.ServeHTTP(w, req)
+
+    spans := rec.Ended()
+    require.Len(t, spans, 2)
+    server := spans[1]
+    require.Equal(t, "GET /items/:id", server.Name())
+    require.Equal(t, trace.SpanKindServer, server.SpanKind())
+    require.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", server.SpanContext().TraceID().String())
+    require.Equal(t, "00f067aa0ba902b7", server.Parent().SpanID().String())
+    require.Equal(t, codes.Error, server.Status().Code)
+    require.Equal(t, server.SpanContext().TraceID(), inner.TraceID())
+    require.Equal(t, server.SpanContext().SpanID(), spans[0].Parent().SpanID())
+
+    // 未匹配路由只用方法名
+    r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/nope/123", nil))
+    require.Equal(t, "GET", rec.Ended()[2].Name())
+    _ = tp.Shutdown(context.Background())
+}
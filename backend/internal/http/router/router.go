@@ -2,17 +2,27 @@ package router
 
 import (
 	"context"
-	"os"
-	"strconv"
+	"time"
 
+	"github.com/YangYuS8/codyssey/backend/internal/aiclient"
 	"github.com/YangYuS8/codyssey/backend/internal/auth"
 	"github.com/YangYuS8/codyssey/backend/internal/domain"
+	"github.com/YangYuS8/codyssey/backend/internal/events"
+	"github.com/YangYuS8/codyssey/backend/internal/featureflag"
+	"github.com/YangYuS8/codyssey/backend/internal/health"
 	"github.com/YangYuS8/codyssey/backend/internal/http/handler"
 	"github.com/YangYuS8/codyssey/backend/internal/http/middleware"
+	"github.com/YangYuS8/codyssey/backend/internal/listquery"
+	"github.com/YangYuS8/codyssey/backend/internal/mail"
 	"github.com/YangYuS8/codyssey/backend/internal/metrics"
+	"github.com/YangYuS8/codyssey/backend/internal/ratelimit"
+	"github.com/YangYuS8/codyssey/backend/internal/realtime"
+	"github.com/YangYuS8/codyssey/backend/internal/repository"
 	"github.com/YangYuS8/codyssey/backend/internal/service"
+	"github.com/YangYuS8/codyssey/backend/internal/settings"
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
+	"go.uber.org/zap"
 )
 
 type ProblemRepo interface {
@@ -20,56 +30,146 @@ type ProblemRepo interface {
     GetByID(ctx context.Context, id uuid.UUID) (domain.Problem, error)
     Update(ctx context.Context, p domain.Problem) error
     Delete(ctx context.Context, id uuid.UUID) error
-    List(ctx context.Context, limit, offset int) ([]domain.Problem, error)
+    List(ctx context.Context, f repository.ProblemFilter, spec listquery.Spec) ([]domain.Problem, string, error)
 }
 
 type Dependencies struct {
     ProblemRepo ProblemRepo
+    ProblemTagRepo repository.ProblemTagRepository // nil 时题目标签不做词表校验，也不提供 /problem-tags
+    ProblemRevisionRepo repository.ProblemRevisionRepository // nil 时题目原地更新，不提供修订历史，提交不记录修订
+    ProblemReviewRepo repository.ProblemReviewRepository // nil 时不启用发布审核，题目创建即发布
+    AIClient    *aiclient.Client // nil 表示未配置 AI 服务；AI 生成题目还需启用发布审核及功能开关 ai_problem_generation
+    AIDetection *service.AIDetectionService // nil 表示不做提交的 AI 代码检测（协程由调用方 Start）；管理与报告路由受功能开关 ai_detection 门控
     UserRepo    service.UserRepo
+    UserTokenRepo service.UserTokenRepo // 与 Mailer 同时提供时启用邮箱验证 / 找回密码
+    Mailer      mail.Sender
+    PublicBaseURL string
     AuthService *auth.AuthService
+    APITokens   *auth.APITokenService
     SubmissionRepo service.SubmissionRepo
     SubmissionStatusLogRepo service.SubmissionStatusLogRepo
     JudgeRunRepo service.JudgeRunRepo
+    RateLimitStore ratelimit.Store            // nil 表示不限流
+    RateLimits     map[string]ratelimit.Policy // 分组 -> 策略，见 ratelimit.Group*
+    IdempotencyRepo repository.IdempotencyRepository // nil 表示忽略 Idempotency-Key
+    IdempotencyTTL  time.Duration
+    Events          *events.Hub // nil 表示不提供 SSE 事件流
+    Realtime        *realtime.Broker // nil 表示不提供 WebSocket 实时通道
+    Logger      *zap.Logger // 访问日志；nil 表示不输出
+    AccessLog   middleware.AccessLogOptions
     HealthCheck handler.HealthChecker
+    Readiness   *health.Registry // /readyz 依赖检查；nil 表示总是就绪
+    JWTSecret   string // HS256 密钥；development / test 下为空时使用开发默认值
+    MaxRequestBodyBytes    int // 全局请求体限制；0 表示不限制
+    MaxSubmissionCodeBytes int // 提交代码长度上限；0 表示默认 128KB
+    Settings    *settings.Store // 运行时参数；非 nil 时限流策略与代码长度上限按当前快照生效，并提供 /admin/settings
+    FeatureFlags *featureflag.Service // 功能开关；nil 时 middleware.RequireFeature 门控的路由一律关闭
     Version     string
     Env         string
 }
 
 func Setup(dep Dependencies) *gin.Engine {
     r := gin.New()
-    r.Use(gin.Logger(), gin.Recovery(), middleware.TraceID(), metrics.Middleware())
-    // 全局请求体限制（若配置提供）
-    if dep.Env != "" { /* placeholder to emphasize env already captured */ }
-    // 这里无法直接访问 config.Config；采用依赖注入策略可在 future 版本增强。
-    // 临时：如果通过环境变量提供 MAX_REQUEST_BODY_BYTES，则解析后加中间件（避免修改 Dependencies 结构过大）。
-    if v := os.Getenv("MAX_REQUEST_BODY_BYTES"); v != "" {
-        if n, err := strconv.Atoi(v); err == nil && n > 0 { r.Use(middleware.BodyLimit(n)) }
-    }
-    // 依据 ENV 使用不同身份中间件（默认 development 下允许 debug 头）
-    env := dep.Env
-    if env == "" { env = os.Getenv("ENV") }
-    if env == "development" || env == "test" {
-        r.Use(auth.AttachDebugIdentity())
+    // 访问日志与 Recovery 位于 TraceID / Tracing 之后，日志可携带 request_id 与 trace_id，panic 产生的 500 也计入 span
+    r.Use(middleware.TraceID(), middleware.Tracing(), middleware.AccessLog(dep.Logger, dep.AccessLog), middleware.Recovery(), metrics.Middleware())
+    if dep.MaxRequestBodyBytes > 0 { r.Use(middleware.BodyLimit(dep.MaxRequestBodyBytes)) }
+    // 依据 ENV 使用不同身份中间件（development / test 下允许 debug 头）
+    var tokens []auth.APITokenResolver
+    if dep.APITokens != nil { tokens = append(tokens, dep.APITokens) }
+    if dep.Env == "development" || dep.Env == "test" {
+        r.Use(auth.AttachDebugIdentity(dep.JWTSecret, tokens...))
     } else {
-        // JWT_SECRET 由 server 侧注入 env；这里直接读取
-        r.Use(auth.StrictJWTAuth(os.Getenv("JWT_SECRET")))
+        r.Use(auth.StrictJWTAuth(dep.JWTSecret, tokens...))
+    }
+
+    // limit 返回分组对应的限流中间件；未配置时为直通
+    limit := func(group string) gin.HandlerFunc { return middleware.RateLimit(dep.RateLimitStore, dep.RateLimits[group]) }
+    if dep.Settings != nil {
+        limit = func(group string) gin.HandlerFunc {
+            return middleware.RateLimitDynamic(dep.RateLimitStore, func() ratelimit.Policy { return dep.Settings.Current().RateLimit(group) })
+        }
     }
+    loginLimit := limit(ratelimit.GroupLogin)
+    // 幂等放在限流之前：重放不消耗令牌，429 也不会被缓存
+    idem := middleware.Idempotency(dep.IdempotencyRepo, middleware.IdempotencyOptions{TTL: dep.IdempotencyTTL})
 
     r.GET("/health", handler.Health(dep.Version, dep.Env, dep.HealthCheck))
+    r.GET("/livez", handler.Livez())
+    r.GET("/readyz", handler.Readyz(dep.Readiness))
     r.GET("/metrics", metrics.Handler())
 	r.GET("/version", func(c *gin.Context) { c.JSON(200, gin.H{"version": dep.Version}) })
 
+    if dep.Settings != nil {
+        r.GET("/admin/settings", auth.Require(auth.PermSystemManage), handler.GetSettings(dep.Settings))
+        r.PATCH("/admin/settings", auth.Require(auth.PermSystemManage), handler.UpdateSettings(dep.Settings))
+        r.GET("/admin/settings/history", auth.Require(auth.PermSystemManage), handler.ListSettingsHistory(dep.Settings))
+    }
+
+    if dep.FeatureFlags != nil {
+        fh := handler.NewFeatureFlagHandlers(dep.FeatureFlags)
+        r.GET("/features", fh.Evaluate)
+        r.GET("/admin/feature-flags", auth.Require(auth.PermSystemManage), fh.List)
+        r.POST("/admin/feature-flags", auth.Require(auth.PermSystemManage), fh.Create)
+        r.GET("/admin/feature-flags/:key", auth.Require(auth.PermSystemManage), fh.Get)
+        r.PUT("/admin/feature-flags/:key", auth.Require(auth.PermSystemManage), fh.Update)
+        r.DELETE("/admin/feature-flags/:key", auth.Require(auth.PermSystemManage), fh.Delete)
+    }
+
+    var ps *service.ProblemService
     if dep.ProblemRepo != nil {
-        ps := service.NewProblemService(dep.ProblemRepo)
+        ps = service.NewProblemService(dep.ProblemRepo)
         r.GET("/problems", handler.ListProblems(ps))
         r.POST("/problems", auth.Require(auth.PermProblemCreate), handler.CreateProblem(ps))
         r.GET("/problems/:id", handler.GetProblem(ps))
         r.PUT("/problems/:id", auth.Require(auth.PermProblemUpdate), handler.UpdateProblem(ps))
         r.DELETE("/problems/:id", auth.Require(auth.PermProblemDelete), handler.DeleteProblem(ps))
+        if dep.ProblemRevisionRepo != nil {
+            ps.EnableRevisions(dep.ProblemRevisionRepo)
+            r.GET("/problems/:id/revisions", auth.Require(auth.PermProblemUpdate), handler.ListProblemRevisions(ps))
+            r.GET("/problems/:id/revisions/diff", auth.Require(auth.PermProblemUpdate), handler.DiffProblemRevisions(ps))
+            r.GET("/problems/:id/revisions/:number", auth.Require(auth.PermProblemUpdate), handler.GetProblemRevision(ps))
+            r.POST("/problems/:id/revisions/:number/rollback", auth.Require(auth.PermProblemUpdate), handler.RollbackProblem(ps))
+        }
+        if dep.ProblemTagRepo != nil {
+            ps.EnableTags(dep.ProblemTagRepo)
+            r.GET("/problem-tags", handler.ListProblemTags(ps))
+            r.POST("/problem-tags", auth.Require(auth.PermProblemUpdate), handler.CreateProblemTag(ps))
+            r.PUT("/problem-tags/:name", auth.Require(auth.PermProblemUpdate), handler.UpdateProblemTag(ps))
+            r.DELETE("/problem-tags/:name", auth.Require(auth.PermProblemUpdate), handler.DeleteProblemTag(ps))
+        }
+        if dep.ProblemReviewRepo != nil {
+            ps.EnableReview(dep.ProblemReviewRepo)
+            r.POST("/problems/:id/submit", auth.Require(auth.PermProblemUpdate), handler.TransitionProblem(ps, service.ProblemActionSubmit))
+            r.POST("/problems/:id/withdraw", auth.Require(auth.PermProblemUpdate), handler.TransitionProblem(ps, service.ProblemActionWithdraw))
+            r.POST("/problems/:id/approve", auth.Require(auth.PermProblemReview), handler.TransitionProblem(ps, service.ProblemActionApprove))
+            r.POST("/problems/:id/reject", auth.Require(auth.PermProblemReview), handler.TransitionProblem(ps, service.ProblemActionReject))
+            r.POST("/problems/:id/archive", auth.Require(auth.PermProblemArchive), handler.TransitionProblem(ps, service.ProblemActionArchive))
+            r.POST("/problems/:id/restore", auth.Require(auth.PermProblemArchive), handler.TransitionProblem(ps, service.ProblemActionRestore))
+            r.GET("/problems/:id/status-logs", auth.Require(auth.PermProblemUpdate), handler.ListProblemStatusLogs(ps))
+            if dep.AIClient != nil {
+                ps.EnableGenerator(dep.AIClient)
+                r.POST("/problems/generate", middleware.RequireFeature(dep.FeatureFlags, featureflag.KeyAIProblemGeneration), auth.Require(auth.PermProblemCreate, auth.PermAIGenerate), handler.GenerateProblem(ps))
+            }
+        }
     }
 
     if dep.UserRepo != nil {
         us := service.NewUserService(dep.UserRepo)
+        if dep.UserTokenRepo != nil && dep.Mailer != nil {
+            us.EnableMail(dep.UserTokenRepo, dep.Mailer, service.MailOptions{PublicBaseURL: dep.PublicBaseURL})
+        }
+        // /users/me 需先于 /users/:id 注册
+        r.GET("/users/me", handler.GetMe(us))
+        r.PATCH("/users/me", handler.UpdateMe(us))
+        r.POST("/users/me/password", handler.ChangeMyPassword(us))
+        if us.MailEnabled() {
+            r.POST("/users/me/email/verification", handler.RequestEmailVerification(us))
+            r.POST("/auth/email/verify", loginLimit, handler.VerifyEmail(us))
+            r.POST("/auth/password/forgot", loginLimit, handler.ForgotPassword(us))
+            r.POST("/auth/password/reset", loginLimit, handler.ResetPassword(us))
+        }
+        r.POST("/users/import", auth.Require(auth.PermUserCreate), handler.ImportUsers(us))
+        r.POST("/users/:id/password", auth.Require(auth.PermUserResetPassword), handler.AdminResetPassword(us))
         r.GET("/users", auth.Require(auth.PermUserList), handler.ListUsers(us))
         r.POST("/users", auth.Require(auth.PermUserCreate), handler.CreateUser(us))
         r.GET("/users/:id", auth.Require(auth.PermUserGet), handler.GetUser(us))
@@ -79,23 +179,69 @@ func Setup(dep Dependencies) *gin.Engine {
 
     if dep.AuthService != nil {
         ah := handler.NewAuthHandlers(dep.AuthService)
-        r.POST("/auth/register", ah.Register)
-        r.POST("/auth/login", ah.Login)
+        r.POST("/auth/register", loginLimit, ah.Register)
+        r.POST("/auth/login", loginLimit, ah.Login)
         r.POST("/auth/refresh", ah.Refresh)
+        if dep.AuthService.MFAEnabled() {
+            r.GET("/auth/mfa", ah.MFAStatus)
+            r.POST("/auth/mfa/enroll", ah.MFAEnroll)
+            r.POST("/auth/mfa/verify", ah.MFAVerify)
+            r.POST("/auth/mfa/challenge", loginLimit, ah.MFAChallenge)
+            r.POST("/auth/mfa/disable", ah.MFADisable)
+        }
+        if dep.AuthService.LockoutEnabled() {
+            r.GET("/auth/lockouts", auth.Require(auth.PermUserUnlock), ah.ListLockouts)
+            r.DELETE("/auth/lockouts/users/:username", auth.Require(auth.PermUserUnlock), ah.UnlockUsername)
+            r.DELETE("/auth/lockouts/ips/:ip", auth.Require(auth.PermUserUnlock), ah.UnlockIP)
+        }
+    }
+
+    if dep.APITokens != nil {
+        th := handler.NewAPITokenHandlers(dep.APITokens)
+        r.POST("/auth/tokens", th.Issue)
+        r.GET("/auth/tokens", th.List)
+        r.DELETE("/auth/tokens/:id", th.Revoke)
+    }
+
+    if dep.Realtime != nil {
+        r.GET("/realtime/ws", handler.RealtimeSocket(dep.Realtime))
+        r.POST("/realtime/publish", auth.Require(auth.PermRealtimePublish), handler.PublishRealtime(dep.Realtime))
     }
 
     if dep.SubmissionRepo != nil {
-        ss := service.NewSubmissionService(dep.SubmissionRepo, dep.SubmissionStatusLogRepo)
+        ss := service.NewSubmissionService(dep.SubmissionRepo, dep.SubmissionStatusLogRepo, service.SubmissionOptions{MaxCodeBytes: dep.MaxSubmissionCodeBytes})
+        if dep.Settings != nil { ss.UseMaxCodeBytes(func() int { return dep.Settings.Current().MaxSubmissionCodeBytes() }) }
+        if ps != nil { ss.UseProblems(ps.SubmissionTarget) }
+        if dep.AIDetection != nil {
+            ss.EnableAIDetection(dep.AIDetection)
+            // 灰度开关先于权限检查：未放量时对所有人返回 404
+            aiDetect := middleware.RequireFeature(dep.FeatureFlags, featureflag.KeyAIDetection)
+            r.GET("/problems/:id/ai-report", aiDetect, auth.Require(auth.PermAIDetect), handler.GetProblemAIReport(dep.AIDetection))
+            r.GET("/ai-detection/settings", aiDetect, auth.Require(auth.PermAIDetect), handler.ListAIDetectionSettings(dep.AIDetection))
+            r.PUT("/ai-detection/settings/:scope/:id", aiDetect, auth.Require(auth.PermAIDetect), handler.SetAIDetectionSetting(dep.AIDetection))
+        }
         var jrAdapter *service.JudgeRunHTTPAdapter
-        if dep.JudgeRunRepo != nil { jrAdapter = service.NewJudgeRunHTTPAdapter(service.NewJudgeRunService(dep.JudgeRunRepo)) }
+        var jrSvc *service.JudgeRunService
+        if dep.JudgeRunRepo != nil {
+            jrSvc = service.NewJudgeRunService(dep.JudgeRunRepo)
+            jrSvc.UseSubmissions(dep.SubmissionRepo)
+            jrAdapter = service.NewJudgeRunHTTPAdapter(jrSvc)
+        }
+        if dep.Events != nil {
+            ss.EnableEvents(dep.Events)
+            if jrSvc != nil { jrSvc.EnableEvents(dep.Events, dep.SubmissionRepo) }
+            // 静态路径须先于 /submissions/:id 注册
+            r.GET("/submissions/events", auth.Require(auth.PermSubmissionList), handler.AllSubmissionEvents(ss, dep.Events))
+            r.GET("/submissions/:id/events", auth.Require(auth.PermSubmissionGet), handler.SubmissionEvents(ss, dep.Events))
+        }
         // 创建沿用 handler 内部校验登录，列表与单个获取加精细权限（list / get）
-        r.POST("/submissions", handler.CreateSubmission(ss))
+        r.POST("/submissions", idem, limit(ratelimit.GroupSubmission), handler.CreateSubmission(ss))
         r.GET("/submissions", auth.Require(auth.PermSubmissionList), handler.ListSubmissions(ss))
         r.GET("/submissions/:id", auth.Require(auth.PermSubmissionGet), handler.GetSubmission(ss))
         r.PATCH("/submissions/:id/status", auth.Require(auth.PermSubmissionUpdateStatus), handler.UpdateSubmissionStatus(ss))
         r.GET("/submissions/:id/logs", auth.Require(auth.PermSubmissionGet), handler.ListSubmissionStatusLogs(ss))
         if jrAdapter != nil {
-            r.POST("/submissions/:id/runs", auth.Require(auth.PermJudgeRunEnqueue), handler.EnqueueJudgeRun(jrAdapter, ss))
+            r.POST("/submissions/:id/runs", auth.Require(auth.PermJudgeRunEnqueue), idem, limit(ratelimit.GroupJudgeEnqueue), handler.EnqueueJudgeRun(jrAdapter, ss))
             r.GET("/submissions/:id/runs", auth.Require(auth.PermJudgeRunList), handler.ListJudgeRuns(jrAdapter, ss))
             r.GET("/judge-runs/:id", auth.Require(auth.PermJudgeRunGet), handler.GetJudgeRun(jrAdapter, ss))
             // 内部判题执行控制（仅 system_admin: judge_run.manage）
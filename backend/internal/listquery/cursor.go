@@ -0,0 +1,69 @@
+package listquery
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"hash/fnv"
+	"strconv"
+	"strings"
+)
+
+// 游标为 base64url(JSON)：k 为上一页末行在各排序键上的值，f 为排序与过滤条件的指纹。
+// 游标只能搭配生成它时的 sort / 过滤参数使用，改变条件后携带旧游标返回 400，而不是静默返回错位的数据。
+type cursorPayload struct {
+    K []string `json:"k"`
+    F string   `json:"f"`
+}
+
+// fingerprint 排序与过滤条件的规范化摘要（过滤已按参数名排序）。
+func (spec Spec) fingerprint() string {
+    var b strings.Builder
+    for _, s := range spec.Sort {
+        if s.Desc { b.WriteByte('-') }
+        b.WriteString(s.Field.Name)
+        b.WriteByte(',')
+    }
+    for _, f := range spec.Filters {
+        b.WriteString(";" + f.Field.Name + "[" + string(f.Op) + "]=")
+        for i, v := range f.Values {
+            if i > 0 { b.WriteByte(',') }
+            b.WriteString(formatValue(f.Field.Type, v))
+        }
+    }
+    h := fnv.New64a()
+    _, _ = h.Write([]byte(b.String()))
+    return strconv.FormatUint(h.Sum64(), 36)
+}
+
+func (spec Spec) encodeCursor(values []any) string {
+    p := cursorPayload{F: spec.fingerprint()}
+    for i, s := range spec.Sort { p.K = append(p.K, formatValue(s.Field.Type, values[i])) }
+    raw, _ := json.Marshal(p)
+    return base64.RawURLEncoding.EncodeToString(raw)
+}
+
+func (spec Spec) decodeCursor(c string) ([]any, error) {
+    raw, err := base64.RawURLEncoding.DecodeString(c)
+    if err != nil { return nil, invalid("malformed cursor") }
+    var p cursorPayload
+    if err := json.Unmarshal(raw, &p); err != nil || len(p.K) != len(spec.Sort) { return nil, invalid("malformed cursor") }
+    if p.F != spec.fingerprint() { return nil, invalid("cursor does not match current sort/filter parameters") }
+    after := make([]any, len(p.K))
+    for i, s := range spec.Sort {
+        v, err := parseValue(s.Field.Type, p.K[i])
+        if err != nil { return nil, invalid("malformed cursor") }
+        after[i] = v
+    }
+    return after, nil
+}
+
+// Page 截断 SelectSQL / Apply 多取的一行，并据本页末行生成下一页游标（无下一页时为空串）。
+// value 返回条目在给定字段（Field.Name）上的值，类型与字段 Type 一致（uuid 等以字符串返回）。
+func Page[T any](spec Spec, rows []T, value func(T, string) any) ([]T, string) {
+    if len(rows) <= spec.Limit { return rows, "" }
+    rows = rows[:spec.Limit]
+    last := rows[len(rows)-1]
+    values := make([]any, len(spec.Sort))
+    for i, s := range spec.Sort { values[i] = value(last, s.Field.Name) }
+    return rows, spec.encodeCursor(values)
+}
@@ -0,0 +1,260 @@
+// Package listquery 列表接口通用的分页 / 过滤 / 排序：按资源声明 Schema（可过滤与可排序字段的白名单），
+// 从查询参数解析出校验过的 Spec，再由 SQL 构造器（PG 仓储）或 Apply（内存仓储）执行。
+// 分页使用不透明的 keyset 游标（按排序键 + 唯一键定位），翻页期间有新数据写入也不会重复或遗漏；limit/offset 仍兼容。
+package listquery
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrInvalid 查询参数不合法（handler 返回 400 INVALID_QUERY）。
+var ErrInvalid = errors.New("invalid list query")
+
+func invalid(format string, args ...any) error { return fmt.Errorf("%w: %s", ErrInvalid, fmt.Sprintf(format, args...)) }
+
+// Type 字段值类型，决定查询参数的解析与比较方式。
+type Type int
+
+const (
+    String Type = iota
+    Int
+    Time // RFC 3339，或 2006-01-02（UTC 零点）
+)
+
+// Op 过滤操作符，查询参数写作 field[op]=value；省略 [op] 即 eq。
+type Op string
+
+const (
+    OpEq  Op = "eq"
+    OpNe  Op = "ne"
+    OpGt  Op = "gt"
+    OpGte Op = "gte"
+    OpLt  Op = "lt"
+    OpLte Op = "lte"
+    OpIn  Op = "in" // 逗号分隔，最多 MaxInValues 个
+)
+
+// MaxInValues in 操作符的取值个数上限。
+const MaxInValues = 50
+
+// MaxSortFields sort 参数的字段个数上限（不含自动追加的唯一键）。
+const MaxSortFields = 3
+
+// Field 一个可过滤或可排序的字段。Name 为 API 中的名称，Column 为 SQL 列（可带表别名）。
+// 可排序字段不能为 NULL（keyset 比较不处理 NULL）。
+type Field struct {
+    Name     string
+    Column   string
+    Type     Type
+    Ops      []Op
+    Sortable bool
+}
+
+func (f Field) allows(op Op) bool {
+    for _, o := range f.Ops { if o == op { return true } }
+    return false
+}
+
+// Schema 资源的列表查询声明。Key 为唯一键字段名（必须可排序），总是作为最后一个排序键以保证顺序稳定。
+type Schema struct {
+    Fields       []Field
+    Key          string
+    DefaultSort  string // 如 "-created_at"
+    DefaultLimit int    // 0 时为 20
+    MaxLimit     int    // 0 时为 100
+}
+
+func (s *Schema) field(name string) (Field, bool) {
+    for _, f := range s.Fields { if f.Name == name { return f, true } }
+    return Field{}, false
+}
+
+func (s *Schema) limits() (def, max int) {
+    def, max = s.DefaultLimit, s.MaxLimit
+    if def <= 0 { def = 20 }
+    if max <= 0 { max = 100 }
+    return def, max
+}
+
+// Filter 一个已解析的过滤条件；Values 已按字段类型转换（string / int64 / time.Time）。
+type Filter struct {
+    Field  Field
+    Op     Op
+    Values []any
+}
+
+// Sort 一个排序键。
+type Sort struct {
+    Field Field
+    Desc  bool
+}
+
+// Spec 校验后的列表查询。零值不可用，应由 Schema.Parse 或 Schema.Default 构造。
+type Spec struct {
+    Limit   int
+    Offset  int
+    Filters []Filter
+    Sort    []Sort // 末位总是唯一键
+    After   []any  // 游标位置（与 Sort 一一对应）；nil 表示第一页
+    schema  *Schema
+}
+
+// Default 无过滤、默认排序的第一页；limit<=0 时取默认值（内部调用与测试用）。
+func (s *Schema) Default(limit int) Spec {
+    spec, err := s.Parse(url.Values{})
+    if err != nil { panic(fmt.Sprintf("listquery: bad schema defaults: %v", err)) }
+    _, max := s.limits()
+    if limit > 0 { spec.Limit = min(limit, max) }
+    return spec
+}
+
+// Parse 解析查询参数：limit、offset、cursor、sort（逗号分隔，- 前缀表示降序）与 field / field[op] 过滤。
+// 未声明的普通参数被忽略（如 format、access_token）；field[op] 形式的未知字段或不允许的操作符为错误。
+func (s *Schema) Parse(q url.Values) (Spec, error) {
+    spec := Spec{schema: s}
+    def, max := s.limits()
+    spec.Limit = def
+    if v := q.Get("limit"); v != "" {
+        n, err := strconv.Atoi(v)
+        if err != nil || n < 1 || n > max { return Spec{}, invalid("limit must be an integer within [1,%d]", max) }
+        spec.Limit = n
+    }
+    if v := q.Get("offset"); v != "" {
+        n, err := strconv.Atoi(v)
+        if err != nil || n < 0 { return Spec{}, invalid("offset must be a non-negative integer") }
+        spec.Offset = n
+    }
+
+    sortParam := q.Get("sort")
+    if sortParam == "" { sortParam = s.DefaultSort }
+    if err := spec.parseSort(sortParam); err != nil { return Spec{}, err }
+
+    keys := make([]string, 0, len(q))
+    for k := range q { keys = append(keys, k) }
+    sort.Strings(keys)
+    for _, k := range keys {
+        name, op := k, OpEq
+        if i := strings.IndexByte(k, '['); i > 0 && strings.HasSuffix(k, "]") {
+            name, op = k[:i], Op(k[i+1:len(k)-1])
+            f, ok := s.field(name)
+            if !ok || !f.allows(op) { return Spec{}, invalid("unsupported filter %s", k) }
+        }
+        f, ok := s.field(name)
+        if !ok || len(f.Ops) == 0 { continue }
+        for _, raw := range q[k] {
+            if op == OpEq && strings.TrimSpace(raw) == "" { continue } // ?status= 等同于不过滤
+            if !f.allows(op) { return Spec{}, invalid("unsupported filter %s", k) }
+            flt, err := parseFilter(f, op, raw)
+            if err != nil { return Spec{}, err }
+            spec.Filters = append(spec.Filters, flt)
+        }
+    }
+
+    if c := q.Get("cursor"); c != "" {
+        if spec.Offset > 0 { return Spec{}, invalid("cursor and offset are mutually exclusive") }
+        after, err := spec.decodeCursor(c)
+        if err != nil { return Spec{}, err }
+        spec.After = after
+    }
+    return spec, nil
+}
+
+func (spec *Spec) parseSort(param string) error {
+    s := spec.schema
+    seen := map[string]bool{}
+    for _, part := range strings.Split(param, ",") {
+        part = strings.TrimSpace(part)
+        if part == "" { continue }
+        desc := strings.HasPrefix(part, "-")
+        name := strings.TrimLeft(part, "+-")
+        f, ok := s.field(name)
+        if !ok || !f.Sortable { return invalid("unsupported sort field %q", name) }
+        if seen[name] { return invalid("duplicate sort field %q", name) }
+        seen[name] = true
+        spec.Sort = append(spec.Sort, Sort{Field: f, Desc: desc})
+    }
+    if len(spec.Sort) > MaxSortFields { return invalid("at most %d sort fields", MaxSortFields) }
+    if !seen[s.Key] {
+        key, ok := s.field(s.Key)
+        if !ok || !key.Sortable { panic("listquery: schema key must be a sortable field") }
+        desc := len(spec.Sort) > 0 && spec.Sort[len(spec.Sort)-1].Desc
+        spec.Sort = append(spec.Sort, Sort{Field: key, Desc: desc})
+    }
+    return nil
+}
+
+func parseFilter(f Field, op Op, raw string) (Filter, error) {
+    parts := []string{raw}
+    if op == OpIn {
+        parts = parts[:0]
+        for _, p := range strings.Split(raw, ",") {
+            if p = strings.TrimSpace(p); p != "" { parts = append(parts, p) }
+        }
+        if len(parts) == 0 || len(parts) > MaxInValues { return Filter{}, invalid("%s[in] expects 1-%d comma separated values", f.Name, MaxInValues) }
+    }
+    flt := Filter{Field: f, Op: op}
+    for _, p := range parts {
+        v, err := parseValue(f.Type, strings.TrimSpace(p))
+        if err != nil { return Filter{}, invalid("%s: %v", f.Name, err) }
+        flt.Values = append(flt.Values, v)
+    }
+    return flt, nil
+}
+
+func parseValue(t Type, s string) (any, error) {
+    switch t {
+    case Int:
+        n, err := strconv.ParseInt(s, 10, 64)
+        if err != nil { return nil, fmt.Errorf("invalid integer %q", s) }
+        return n, nil
+    case Time:
+        if ts, err := time.Parse(time.RFC3339Nano, s); err == nil { return ts.UTC(), nil }
+        if d, err := time.Parse("2006-01-02", s); err == nil { return d, nil }
+        return nil, fmt.Errorf("invalid time %q (RFC 3339 or YYYY-MM-DD)", s)
+    }
+    return s, nil
+}
+
+func formatValue(t Type, v any) string {
+    switch t {
+    case Int:
+        return strconv.FormatInt(toInt64(v), 10)
+    case Time:
+        return v.(time.Time).UTC().Format(time.RFC3339Nano)
+    }
+    return fmt.Sprint(v)
+}
+
+func toInt64(v any) int64 {
+    switch n := v.(type) {
+    case int:
+        return int64(n)
+    case int32:
+        return int64(n)
+    case int64:
+        return n
+    }
+    panic(fmt.Sprintf("listquery: %T is not an integer", v))
+}
+
+// compare 同类型值比较（内存实现与测试用）。
+func compare(t Type, a, b any) int {
+    switch t {
+    case Int:
+        x, y := toInt64(a), toInt64(b)
+        switch {
+        case x < y: return -1
+        case x > y: return 1
+        }
+        return 0
+    case Time:
+        return a.(time.Time).Compare(b.(time.Time))
+    }
+    return strings.Compare(fmt.Sprint(a), fmt.Sprint(b))
+}
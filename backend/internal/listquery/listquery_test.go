@@ -0,0 +1,155 @@
+package listquery
+
+import (
+	"fmt"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+type row struct {
+    ID        string
+    Status    string
+    Score     int
+    CreatedAt time.Time
+}
+
+var testSchema = &Schema{
+    Fields: []Field{
+        {Name: "id", Column: "id", Type: String, Sortable: true},
+        {Name: "status", Column: "status", Type: String, Ops: []Op{OpEq, OpNe, OpIn}},
+        {Name: "score", Column: "score", Type: Int, Ops: []Op{OpEq, OpGte, OpLte}, Sortable: true},
+        {Name: "created_at", Column: "s.created_at", Type: Time, Ops: []Op{OpGte, OpLt}, Sortable: true},
+    },
+    Key:          "id",
+    DefaultSort:  "-created_at",
+    DefaultLimit: 2,
+    MaxLimit:     10,
+}
+
+func rowValue(r row, field string) any {
+    switch field {
+    case "id":
+        return r.ID
+    case "status":
+        return r.Status
+    case "score":
+        return r.Score
+    }
+    return r.CreatedAt
+}
+
+func mustParse(t *testing.T, q string) Spec {
+    t.Helper()
+    v, err := url.ParseQuery(q)
+    require.NoError(t, err)
+    spec, err := testSchema.Parse(v)
+    require.NoError(t, err)
+    return spec
+}
+
+func TestParseValidation(t *testing.T) {
+    spec := mustParse(t, "")
+    require.Equal(t, 2, spec.Limit)
+    require.Len(t, spec.Sort, 2)
+    require.Equal(t, "created_at", spec.Sort[0].Field.Name)
+    require.True(t, spec.Sort[1].Desc, "tie-breaker follows the last direction")
+
+    spec = mustParse(t, "status=&score[gte]=3&status[in]=a,b&format=json")
+    require.Len(t, spec.Filters, 2)
+    require.Equal(t, []any{int64(3)}, spec.Filters[0].Values)
+    require.Equal(t, []any{"a", "b"}, spec.Filters[1].Values)
+
+    for _, q := range []string{
+        "limit=0", "limit=11", "limit=x", "offset=-1",
+        "sort=status", "sort=nope", "sort=score,score",
+        "status[gt]=a", "nope[eq]=1", "score=abc", "created_at[gte]=yesterday",
+        "cursor=!!!", "offset=1&cursor=abc",
+    } {
+        v, _ := url.ParseQuery(q)
+        _, err := testSchema.Parse(v)
+        require.ErrorIs(t, err, ErrInvalid, q)
+    }
+}
+
+func TestSelectSQL(t *testing.T) {
+    spec := mustParse(t, "status[in]=a,b&score[gte]=3")
+    sql, args, err := spec.SelectSQL("SELECT id FROM t s", Eq("user_id", "u1"))
+    require.NoError(t, err)
+    require.Equal(t, "SELECT id FROM t s WHERE (user_id = $1) AND score >= $2 AND status IN ($3, $4) ORDER BY s.created_at DESC, id DESC LIMIT $5", sql)
+    require.Equal(t, []any{"u1", int64(3), "a", "b", 3}, args)
+
+    sql, args, err = spec.CountSQL("SELECT COUNT(*) FROM t s")
+    require.NoError(t, err)
+    require.Equal(t, "SELECT COUNT(*) FROM t s WHERE score >= $1 AND status IN ($2, $3)", sql)
+    require.Len(t, args, 3)
+
+    ts := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+    spec.After = []any{ts, "x"}
+    sql, _, err = spec.SelectSQL("SELECT id FROM t s")
+    require.NoError(t, err)
+    require.Contains(t, sql, "(s.created_at, id) < ($4, $5)")
+
+    mixed := mustParse(t, "sort=score,-created_at&offset=4")
+    mixed.After = []any{int64(1), ts, "x"}
+    sql, args, err = mixed.SelectSQL("SELECT id FROM t s")
+    require.NoError(t, err)
+    require.Equal(t, "SELECT id FROM t s WHERE ((score > $1) OR (score = $2 AND s.created_at < $3) OR (score = $4 AND s.created_at = $5 AND id < $6)) ORDER BY score, s.created_at DESC, id DESC LIMIT $7 OFFSET $8", sql)
+    require.Equal(t, []any{int64(1), int64(1), ts, int64(1), ts, "x", 3, 4}, args)
+}
+
+func TestSelectSQLLiteralQuestionMarks(t *testing.T) {
+    spec := mustParse(t, "status=a")
+    // base 与无参数条件中的 ? 原样保留（jsonb 运算符、字面量）
+    sql, args, err := spec.SelectSQL("SELECT id, data ? 'k' FROM t s", Cond{SQL: "tags ?| array['x']"}, Cond{SQL: "note <> '?'"}, Eq("user_id", "u1"))
+    require.NoError(t, err)
+    require.Equal(t, "SELECT id, data ? 'k' FROM t s WHERE (tags ?| array['x']) AND (note <> '?') AND (user_id = $1) AND status = $2 ORDER BY s.created_at DESC, id DESC LIMIT $3", sql)
+    require.Equal(t, []any{"u1", "a", 3}, args)
+
+    // 占位符与参数个数不符返回错误而不是 panic
+    _, _, err = spec.SelectSQL("SELECT id FROM t s", Cond{SQL: "a = ? AND b = ?", Args: []any{1}})
+    require.ErrorIs(t, err, ErrPlaceholderMismatch)
+    _, _, err = spec.CountSQL("SELECT COUNT(*) FROM t s", Cond{SQL: "data ? 'k' AND a = ?", Args: []any{1}})
+    require.ErrorIs(t, err, ErrPlaceholderMismatch)
+}
+
+func TestCursorPagingInMemory(t *testing.T) {
+    base := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+    var items []row
+    for i := 0; i < 7; i++ {
+        // 两两相同的 created_at，验证唯一键兜底
+        items = append(items, row{ID: fmt.Sprintf("r%d", i), Status: []string{"ok", "bad"}[i%2], Score: i, CreatedAt: base.Add(time.Duration(i/2) * time.Minute)})
+    }
+
+    var got []string
+    q := url.Values{}
+    for pages := 0; ; pages++ {
+        require.Less(t, pages, 10)
+        spec, err := testSchema.Parse(q)
+        require.NoError(t, err)
+        page, next := Apply(spec, items, rowValue)
+        for _, r := range page { got = append(got, r.ID) }
+        if next == "" { break }
+        q.Set("cursor", next)
+        // 翻页期间插入更新的数据不影响后续页
+        items = append(items, row{ID: fmt.Sprintf("new%d", pages), CreatedAt: base.Add(time.Hour)})
+    }
+    require.Equal(t, []string{"r6", "r5", "r4", "r3", "r2", "r1", "r0"}, got)
+
+    spec := mustParse(t, "status=ok&sort=score&limit=3")
+    page, next := Apply(spec, items[:7], rowValue)
+    require.Equal(t, []row{items[0], items[2], items[4]}, page)
+    require.NotEmpty(t, next)
+    require.Equal(t, 4, Count(spec, items[:7], rowValue))
+
+    // 游标与条件绑定：换了过滤条件后旧游标无效
+    _, err := testSchema.Parse(url.Values{"status": {"bad"}, "sort": {"score"}, "limit": {"3"}, "cursor": {next}})
+    require.ErrorIs(t, err, ErrInvalid)
+    spec, err = testSchema.Parse(url.Values{"status": {"ok"}, "sort": {"score"}, "limit": {"3"}, "cursor": {next}})
+    require.NoError(t, err)
+    page, next = Apply(spec, items[:7], rowValue)
+    require.Equal(t, []row{items[6]}, page)
+    require.Empty(t, next)
+}
@@ -0,0 +1,65 @@
+package listquery
+
+import "sort"
+
+// Matches 条目是否满足全部过滤条件（内存仓储用）。
+func Matches[T any](spec Spec, item T, value func(T, string) any) bool {
+    for _, f := range spec.Filters {
+        v := value(item, f.Field.Name)
+        ok := false
+        switch f.Op {
+        case OpIn:
+            for _, want := range f.Values { ok = ok || compare(f.Field.Type, v, want) == 0 }
+        default:
+            c := compare(f.Field.Type, v, f.Values[0])
+            switch f.Op {
+            case OpEq: ok = c == 0
+            case OpNe: ok = c != 0
+            case OpGt: ok = c > 0
+            case OpGte: ok = c >= 0
+            case OpLt: ok = c < 0
+            case OpLte: ok = c <= 0
+            }
+        }
+        if !ok { return false }
+    }
+    return true
+}
+
+// Count 满足过滤条件的条目数（内存仓储用）。
+func Count[T any](spec Spec, items []T, value func(T, string) any) int {
+    n := 0
+    for _, it := range items { if Matches(spec, it, value) { n++ } }
+    return n
+}
+
+// Apply 在内存中执行与 SelectSQL + Page 等价的查询：过滤、排序、游标定位、offset 与 limit。
+// items 不会被修改；仓储自身的固定条件应在调用前筛好。
+func Apply[T any](spec Spec, items []T, value func(T, string) any) ([]T, string) {
+    out := make([]T, 0, len(items))
+    for _, it := range items { if Matches(spec, it, value) { out = append(out, it) } }
+    cmp := func(a, b T) int {
+        for _, s := range spec.Sort {
+            c := compare(s.Field.Type, value(a, s.Field.Name), value(b, s.Field.Name))
+            if s.Desc { c = -c }
+            if c != 0 { return c }
+        }
+        return 0
+    }
+    sort.SliceStable(out, func(i, j int) bool { return cmp(out[i], out[j]) < 0 })
+    if spec.After != nil {
+        // 已排好序，首个严格位于游标之后的条目即起点
+        i := sort.Search(len(out), func(i int) bool {
+            for k, s := range spec.Sort {
+                c := compare(s.Field.Type, value(out[i], s.Field.Name), spec.After[k])
+                if s.Desc { c = -c }
+                if c != 0 { return c > 0 }
+            }
+            return false
+        })
+        out = out[i:]
+    }
+    if spec.Offset >= len(out) { out = out[:0] } else { out = out[spec.Offset:] }
+    if len(out) > spec.Limit+1 { out = out[:spec.Limit+1] }
+    return Page(spec, out, value)
+}
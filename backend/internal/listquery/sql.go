@@ -0,0 +1,125 @@
+package listquery
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ErrPlaceholderMismatch 带参数的 SQL 片段中 ? 的个数与参数个数不一致。
+var ErrPlaceholderMismatch = errors.New("listquery: placeholder count does not match args")
+
+// Cond 仓储附加的固定条件（如按 submission_id 限定），SQL 中以 ? 作为参数占位符，构造时统一改写为 $n。
+// 无参数的 Cond 原样写入（可含 jsonb 的 ? / ?| 运算符或 '?' 字面量）；有参数时 ? 只能是占位符，
+// 需要 jsonb 运算符时改用 jsonb_exists 等函数形式。
+type Cond struct {
+    SQL  string
+    Args []any
+}
+
+// Eq column = v 的便捷构造。
+func Eq(column string, v any) Cond { return Cond{SQL: column + " = ?", Args: []any{v}} }
+
+type sqlBuilder struct {
+    b    strings.Builder
+    args []any
+    err  error
+}
+
+// write 写入一段 SQL：无参数时原样写入；有参数时把 ? 依次改写为 $n，个数不符记录 ErrPlaceholderMismatch。
+func (sb *sqlBuilder) write(sql string, args ...any) {
+    if len(args) == 0 { sb.b.WriteString(sql); return }
+    if n := strings.Count(sql, "?"); n != len(args) {
+        if sb.err == nil { sb.err = fmt.Errorf("%w: %d placeholders, %d args in %q", ErrPlaceholderMismatch, n, len(args), sql) }
+        return
+    }
+    for _, r := range sql {
+        if r == '?' {
+            sb.args = append(sb.args, args[0])
+            args = args[1:]
+            sb.b.WriteString("$" + strconv.Itoa(len(sb.args)))
+            continue
+        }
+        sb.b.WriteRune(r)
+    }
+}
+
+var sqlOps = map[Op]string{OpEq: "=", OpNe: "<>", OpGt: ">", OpGte: ">=", OpLt: "<", OpLte: "<="}
+
+// where 写入 WHERE 子句：固定条件、过滤条件，以及 keyset 条件（withCursor 时）。
+func (spec Spec) where(sb *sqlBuilder, extra []Cond, withCursor bool) {
+    n := 0
+    next := func() {
+        if n == 0 { sb.write(" WHERE ") } else { sb.write(" AND ") }
+        n++
+    }
+    for _, c := range extra {
+        next()
+        sb.write("("+c.SQL+")", c.Args...)
+    }
+    for _, f := range spec.Filters {
+        next()
+        if f.Op == OpIn {
+            sb.write(f.Field.Column + " IN (" + strings.TrimSuffix(strings.Repeat("?, ", len(f.Values)), ", ") + ")", f.Values...)
+            continue
+        }
+        sb.write(f.Field.Column+" "+sqlOps[f.Op]+" ?", f.Values[0])
+    }
+    if !withCursor || spec.After == nil { return }
+    next()
+    spec.keyset(sb)
+}
+
+// keyset 写入“位于游标之后”的条件。各键方向一致时用行比较 (a, b) < ($1, $2)，可直接利用联合索引；
+// 方向混合时展开为 a < $1 OR (a = $1 AND b > $2)。
+func (spec Spec) keyset(sb *sqlBuilder) {
+    same := true
+    for _, s := range spec.Sort { same = same && s.Desc == spec.Sort[0].Desc }
+    if same {
+        cols := make([]string, len(spec.Sort))
+        for i, s := range spec.Sort { cols[i] = s.Field.Column }
+        op := ">"
+        if spec.Sort[0].Desc { op = "<" }
+        sb.write("("+strings.Join(cols, ", ")+") "+op+" ("+strings.TrimSuffix(strings.Repeat("?, ", len(cols)), ", ")+")", spec.After...)
+        return
+    }
+    sb.write("(")
+    for i, s := range spec.Sort {
+        if i > 0 { sb.write(" OR ") }
+        sb.write("(")
+        for j := 0; j < i; j++ { sb.write(spec.Sort[j].Field.Column+" = ? AND ", spec.After[j]) }
+        op := ">"
+        if s.Desc { op = "<" }
+        sb.write(s.Field.Column+" "+op+" ?", spec.After[i])
+        sb.write(")")
+    }
+    sb.write(")")
+}
+
+// SelectSQL 在 base（SELECT ... FROM ...，不含 WHERE）之后拼接条件、ORDER BY 与 LIMIT/OFFSET。
+// LIMIT 为 spec.Limit+1，多取的一行供 Page 判断是否还有下一页。base 原样写入，不改写其中的 ?。
+func (spec Spec) SelectSQL(base string, extra ...Cond) (string, []any, error) {
+    sb := &sqlBuilder{}
+    sb.write(base)
+    spec.where(sb, extra, true)
+    sb.write(" ORDER BY ")
+    for i, s := range spec.Sort {
+        if i > 0 { sb.write(", ") }
+        sb.write(s.Field.Column)
+        if s.Desc { sb.write(" DESC") }
+    }
+    sb.write(" LIMIT ?", spec.Limit+1)
+    if spec.Offset > 0 { sb.write(" OFFSET ?", spec.Offset) }
+    if sb.err != nil { return "", nil, sb.err }
+    return sb.b.String(), sb.args, nil
+}
+
+// CountSQL 在 base（SELECT COUNT(*) FROM ...）之后拼接固定条件与过滤条件（不含游标）。
+func (spec Spec) CountSQL(base string, extra ...Cond) (string, []any, error) {
+    sb := &sqlBuilder{}
+    sb.write(base)
+    spec.where(sb, extra, false)
+    if sb.err != nil { return "", nil, sb.err }
+    return sb.b.String(), sb.args, nil
+}
@@ -0,0 +1,31 @@
+// Package logging 请求级 zap logger 在 context.Context 中的传递。
+// 访问日志中间件为每个请求创建带 request_id / trace_id 的 logger，认证后追加 user_id；
+// handler / service 通过 FromContext 取出，使业务日志与访问日志可按 request_id 关联。
+package logging
+
+import (
+	"context"
+
+	"go.uber.org/zap"
+)
+
+type ctxKey struct{}
+
+// WithLogger 把 logger 放入 ctx。
+func WithLogger(ctx context.Context, l *zap.Logger) context.Context {
+    if l == nil { return ctx }
+    return context.WithValue(ctx, ctxKey{}, l)
+}
+
+// FromContext 取出请求级 logger；未设置时返回全局 logger（server 启动时通过 zap.ReplaceGlobals 安装，否则为 Nop）。
+func FromContext(ctx context.Context) *zap.Logger {
+    if ctx != nil {
+        if l, ok := ctx.Value(ctxKey{}).(*zap.Logger); ok { return l }
+    }
+    return zap.L()
+}
+
+// With 在 ctx 中的 logger 上追加字段并返回新 ctx。
+func With(ctx context.Context, fields ...zap.Field) context.Context {
+    return WithLogger(ctx, FromContext(ctx).With(fields...))
+}
@@ -0,0 +1,88 @@
+// Package mail 发信抽象：业务只依赖 Sender，部署时按配置选择 SMTP / 文件 / 日志实现。
+package mail
+
+import (
+	"context"
+	"fmt"
+	"mime"
+	"net/smtp"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/YangYuS8/codyssey/backend/internal/tracing"
+)
+
+// Message 纯文本邮件。
+type Message struct {
+    To      string
+    Subject string
+    Body    string
+}
+
+type Sender interface {
+    Send(ctx context.Context, m Message) error
+}
+
+// LogSender 仅记录日志（开发环境默认）。注意：正文含一次性链接，生产环境勿用。
+type LogSender struct { logger *zap.Logger }
+
+func NewLogSender(logger *zap.Logger) *LogSender {
+    if logger == nil { logger = zap.NewNop() }
+    return &LogSender{logger: logger}
+}
+
+func (s *LogSender) Send(ctx context.Context, m Message) error {
+    tracing.Logger(ctx, s.logger).Info("mail (log sender)", zap.String("to", m.To), zap.String("subject", m.Subject), zap.String("body", m.Body))
+    return nil
+}
+
+// FileSender 每封邮件写入目录下一个 .eml 文件，便于测试与本地查看。
+type FileSender struct {
+    dir string
+    mu  sync.Mutex
+    seq int
+}
+
+func NewFileSender(dir string) *FileSender { return &FileSender{dir: dir} }
+
+func (s *FileSender) Send(ctx context.Context, m Message) error {
+    s.mu.Lock()
+    s.seq++
+    name := fmt.Sprintf("%s-%04d.eml", time.Now().UTC().Format("20060102T150405"), s.seq)
+    s.mu.Unlock()
+    if err := os.MkdirAll(s.dir, 0o755); err != nil { return err }
+    return os.WriteFile(filepath.Join(s.dir, name), []byte(render("", m)), 0o600)
+}
+
+// SMTPSender 经 net/smtp 发信（STARTTLS 由 net/smtp 在服务器支持时自动协商）。
+type SMTPSender struct {
+    Addr     string // host:port
+    From     string
+    Username string
+    Password string
+}
+
+func (s *SMTPSender) Send(ctx context.Context, m Message) error {
+    var a smtp.Auth
+    if s.Username != "" {
+        host := s.Addr
+        if i := strings.LastIndex(host, ":"); i > 0 { host = host[:i] }
+        a = smtp.PlainAuth("", s.Username, s.Password, host)
+    }
+    return smtp.SendMail(s.Addr, a, s.From, []string{m.To}, []byte(render(s.From, m)))
+}
+
+func render(from string, m Message) string {
+    var b strings.Builder
+    if from != "" { b.WriteString("From: " + from + "\r\n") }
+    b.WriteString("To: " + m.To + "\r\n")
+    b.WriteString("Subject: " + mime.QEncoding.Encode("utf-8", m.Subject) + "\r\n")
+    b.WriteString("MIME-Version: 1.0\r\nContent-Type: text/plain; charset=UTF-8\r\n\r\n")
+    b.WriteString(m.Body)
+    return b.String()
+}
@@ -0,0 +1,336 @@
+package markdown
+
+import (
+	"html"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// maxInlineDepth 行内元素（强调、链接文本）的最大嵌套层数，超过后按纯文本输出。
+const maxInlineDepth = 16
+
+var autolinkRe = regexp.MustCompile(`^<([A-Za-z][A-Za-z0-9+.-]{1,31}:[^\s<>]*|[A-Za-z0-9.!#$%&'*+/=?^_{|}~-]+@[A-Za-z0-9](?:[A-Za-z0-9.-]{0,253}[A-Za-z0-9])?)>`)
+
+// inlineParser 单次行内解析的状态。各类结束定界符的位置在首次需要时一次性计算，
+// 查找时二分，避免在病态输入（大量未闭合的 * 或 `）上退化为平方复杂度。
+type inlineParser struct {
+    s        string
+    depth    int
+    out      strings.Builder
+    text     strings.Builder // 待转义输出的普通文本
+    brackets map[int]int     // '[' 位置 -> 匹配的 ']' 位置
+    ticks    map[int][]int   // 反引号串长度 -> 起始位置
+    dollars  []int           // 可作为行内公式结束符的 '$' 位置
+    closers  map[string][]int
+}
+
+func renderInline(s string, depth int) string {
+    if depth > maxInlineDepth { return html.EscapeString(s) }
+    p := &inlineParser{s: s, depth: depth}
+    p.parse()
+    return p.out.String()
+}
+
+func isSpace(c byte) bool { return c == ' ' || c == '\t' || c == '\n' }
+
+func isAlnum(c byte) bool {
+    return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c >= 0x80
+}
+
+func isPunct(c byte) bool { return c < 0x80 && strings.IndexByte("!\"#$%&'()*+,-./:;<=>?@[\\]^_`{|}~", c) >= 0 }
+
+// nextAfter 返回有序列表中第一个 > pos 的元素，不存在时返回 -1。
+func nextAfter(list []int, pos int) int {
+    k := sort.SearchInts(list, pos+1)
+    if k == len(list) { return -1 }
+    return list[k]
+}
+
+func (p *inlineParser) flush() {
+    if p.text.Len() == 0 { return }
+    p.out.WriteString(html.EscapeString(p.text.String()))
+    p.text.Reset()
+}
+
+func (p *inlineParser) emit(h string) { p.flush(); p.out.WriteString(h) }
+
+func (p *inlineParser) parse() {
+    s := p.s
+    for i := 0; i < len(s); {
+        c := s[i]
+        n := 0
+        switch c {
+        case '\\':
+            n = p.backslash(i)
+        case '`':
+            n = p.codeSpan(i)
+        case '$':
+            n = p.dollarMath(i)
+        case '!':
+            if i+1 < len(s) && s[i+1] == '[' { n = p.link(i+1, true) }
+        case '[':
+            n = p.link(i, false)
+        case '<':
+            n = p.autolink(i)
+        case '*', '_', '~':
+            n = p.emphasis(i)
+        case '\n':
+            p.lineBreak()
+            i++
+            continue
+        }
+        if n > 0 {
+            i += n
+            continue
+        }
+        p.text.WriteByte(c)
+        i++
+    }
+    p.flush()
+}
+
+// lineBreak 行尾两个以上空格为硬换行，否则保留为普通换行。
+func (p *inlineParser) lineBreak() {
+    t := p.text.String()
+    trimmed := strings.TrimRight(t, " ")
+    p.text.Reset()
+    p.text.WriteString(trimmed)
+    if len(t)-len(trimmed) >= 2 {
+        p.emit("<br>\n")
+        return
+    }
+    p.text.WriteByte('\n')
+}
+
+func (p *inlineParser) backslash(i int) int {
+    s := p.s
+    if i+1 >= len(s) { return 0 }
+    switch s[i+1] {
+    case '\n':
+        p.emit("<br>\n")
+        return 2
+    case '(', '[':
+        closer := `\)`
+        class := "math math-inline"
+        if s[i+1] == '[' { closer, class = `\]`, "math math-display" }
+        if j := strings.Index(s[i+2:], closer); j >= 0 {
+            p.emit(`<span class="` + class + `">` + html.EscapeString(strings.TrimSpace(s[i+2:i+2+j])) + "</span>")
+            return j + 4
+        }
+    }
+    if isPunct(s[i+1]) {
+        p.text.WriteByte(s[i+1])
+        return 2
+    }
+    return 0
+}
+
+func runLen(s string, i int, c byte) int {
+    k := i
+    for k < len(s) && s[k] == c { k++ }
+    return k - i
+}
+
+func (p *inlineParser) codeSpan(i int) int {
+    s := p.s
+    k := runLen(s, i, '`')
+    if p.ticks == nil {
+        p.ticks = map[int][]int{}
+        for j := 0; j < len(s); {
+            if s[j] != '`' { j++; continue }
+            l := runLen(s, j, '`')
+            p.ticks[l] = append(p.ticks[l], j)
+            j += l
+        }
+    }
+    j := nextAfter(p.ticks[k], i)
+    if j < 0 {
+        p.text.WriteString(s[i : i+k])
+        return k
+    }
+    code := strings.ReplaceAll(s[i+k:j], "\n", " ")
+    if len(code) > 2 && code[0] == ' ' && code[len(code)-1] == ' ' && strings.Trim(code, " ") != "" { code = code[1 : len(code)-1] }
+    p.emit("<code>" + html.EscapeString(code) + "</code>")
+    return j + k - i
+}
+
+// dollarMath 行内公式。单个 $ 沿用 pandoc 规则：开头 $ 后不能是空白，结尾 $ 前不能是空白、
+// 后面不能紧跟数字，从而 "花费 $5 和 $6" 这类文本不会被误识别为公式。
+func (p *inlineParser) dollarMath(i int) int {
+    s := p.s
+    if strings.HasPrefix(s[i:], "$$") {
+        if j := strings.Index(s[i+2:], "$$"); j > 0 {
+            p.emit(`<span class="math math-display">` + html.EscapeString(strings.TrimSpace(s[i+2:i+2+j])) + "</span>")
+            return j + 4
+        }
+        p.text.WriteString("$$")
+        return 2
+    }
+    if i+1 >= len(s) || isSpace(s[i+1]) { return 0 }
+    if p.dollars == nil {
+        p.dollars = []int{}
+        for j := 1; j < len(s); j++ {
+            if s[j] != '$' || isSpace(s[j-1]) || s[j-1] == '\\' || s[j-1] == '$' { continue }
+            if j+1 < len(s) && (s[j+1] >= '0' && s[j+1] <= '9' || s[j+1] == '$') { continue }
+            p.dollars = append(p.dollars, j)
+        }
+    }
+    j := nextAfter(p.dollars, i+1)
+    if j < 0 { return 0 }
+    p.emit(`<span class="math math-inline">` + html.EscapeString(s[i+1:j]) + "</span>")
+    return j + 1 - i
+}
+
+func (p *inlineParser) matchBracket(i int) int {
+    if p.brackets == nil {
+        p.brackets = map[int]int{}
+        var stack []int
+        for j := 0; j < len(p.s); j++ {
+            switch p.s[j] {
+            case '\\':
+                j++
+            case '[':
+                stack = append(stack, j)
+            case ']':
+                if len(stack) > 0 {
+                    p.brackets[stack[len(stack)-1]] = j
+                    stack = stack[:len(stack)-1]
+                }
+            }
+        }
+    }
+    if j, ok := p.brackets[i]; ok { return j }
+    return -1
+}
+
+// linkTarget 解析 "(url "title")"，返回地址、标题与消耗的字节数。
+func linkTarget(s string) (url, title string, n int, ok bool) {
+    if len(s) == 0 || s[0] != '(' { return "", "", 0, false }
+    i := 1
+    for i < len(s) && isSpace(s[i]) { i++ }
+    if i < len(s) && s[i] == '<' {
+        j := strings.IndexAny(s[i+1:], ">\n")
+        if j < 0 || s[i+1+j] != '>' { return "", "", 0, false }
+        url = s[i+1 : i+1+j]
+        i += j + 2
+    } else {
+        start, depth := i, 0
+        for ; i < len(s) && !isSpace(s[i]); i++ {
+            if s[i] == '\\' && i+1 < len(s) { i++; continue }
+            if s[i] == '(' { depth++ }
+            if s[i] == ')' {
+                if depth == 0 { break }
+                depth--
+            }
+        }
+        url = s[start:i]
+    }
+    for i < len(s) && isSpace(s[i]) { i++ }
+    if i < len(s) && (s[i] == '"' || s[i] == '\'') {
+        q := s[i]
+        j := strings.IndexByte(s[i+1:], q)
+        if j < 0 { return "", "", 0, false }
+        title = s[i+1 : i+1+j]
+        i += j + 2
+        for i < len(s) && isSpace(s[i]) { i++ }
+    }
+    if i >= len(s) || s[i] != ')' { return "", "", 0, false }
+    return url, title, i + 1, true
+}
+
+// link 处理 [text](url) 与 ![alt](url)。不安全的地址只输出文字，不生成链接。
+func (p *inlineParser) link(i int, image bool) int {
+    j := p.matchBracket(i)
+    if j < 0 { return 0 }
+    url, title, n, ok := linkTarget(p.s[j+1:])
+    if !ok { return 0 }
+    label := p.s[i+1 : j]
+    consumed := j + 1 + n - i
+    if image { consumed++ }
+    titleAttr := ""
+    if title != "" { titleAttr = ` title="` + html.EscapeString(title) + `"` }
+    if image {
+        if !safeImageURL(url) {
+            p.text.WriteString(label)
+            return consumed
+        }
+        p.emit(`<img src="` + html.EscapeString(url) + `" alt="` + html.EscapeString(label) + `"` + titleAttr + ">")
+        return consumed
+    }
+    inner := renderInline(label, p.depth+1)
+    if !SafeURL(url) {
+        p.emit(inner)
+        return consumed
+    }
+    p.emit(`<a href="` + html.EscapeString(url) + `"` + titleAttr + ">" + inner + "</a>")
+    return consumed
+}
+
+// autolink 处理 <https://...> 与 <user@example.com>；其他以 < 开头的内容（包括原始 HTML）按文本转义。
+func (p *inlineParser) autolink(i int) int {
+    m := autolinkRe.FindStringSubmatch(p.s[i:])
+    if m == nil { return 0 }
+    href := m[1]
+    if !strings.Contains(href, ":") { href = "mailto:" + href }
+    if !SafeURL(href) {
+        p.text.WriteString(m[0])
+        return len(m[0])
+    }
+    p.emit(`<a href="` + html.EscapeString(href) + `">` + html.EscapeString(m[1]) + "</a>")
+    return len(m[0])
+}
+
+// validCloser 位置 j 处的 delim 能否作为结束定界符：前面不是空白或转义，且单字符定界符不属于更长的串；
+// '_' 还要求后面不是字母数字（词内下划线不视为强调）。
+func (p *inlineParser) validCloser(j int, delim string) bool {
+    s := p.s
+    c := delim[0]
+    end := j + len(delim)
+    if j == 0 || isSpace(s[j-1]) || s[j-1] == '\\' || s[j-1] == c { return false }
+    if end < len(s) && s[end] == c { return false }
+    if c == '_' && end < len(s) && isAlnum(s[end]) { return false }
+    return true
+}
+
+func (p *inlineParser) closerAfter(delim string, pos int) int {
+    if p.closers == nil { p.closers = map[string][]int{} }
+    list, ok := p.closers[delim]
+    if !ok {
+        list = []int{}
+        for j := strings.Index(p.s, delim); j >= 0; {
+            if p.validCloser(j, delim) { list = append(list, j) }
+            k := strings.Index(p.s[j+1:], delim)
+            if k < 0 { break }
+            j += k + 1
+        }
+        p.closers[delim] = list
+    }
+    return nextAfter(list, pos)
+}
+
+func (p *inlineParser) emphasis(i int) int {
+    s := p.s
+    c := s[i]
+    delim, tag := string(c), "em"
+    if i+1 < len(s) && s[i+1] == c {
+        delim, tag = string([]byte{c, c}), "strong"
+        if c == '~' { tag = "del" }
+    } else if c == '~' {
+        return 0
+    }
+    end := i + len(delim)
+    if end >= len(s) || isSpace(s[end]) { return 0 }
+    if c == '_' && i > 0 && isAlnum(s[i-1]) { return 0 }
+    j := p.closerAfter(delim, end-1)
+    if j < 0 {
+        if len(delim) == 2 {
+            // 没有匹配的双定界符时，先输出一个字符，剩下的再尝试作为单定界符
+            p.text.WriteByte(c)
+            return 1
+        }
+        return 0
+    }
+    p.emit("<" + tag + ">" + renderInline(s[end:j], p.depth+1) + "</" + tag + ">")
+    return j + len(delim) - i
+}
@@ -0,0 +1,308 @@
+// Package markdown 题面 Markdown 的服务端渲染：支持常用的 CommonMark / GFM 子集（标题、段落、列表、引用、
+// 代码块、表格、强调、链接、图片）与 KaTeX 兼容的数学公式定界符（$...$、$$...$$、\(...\)、\[...\]）。
+// 公式不在服务端排版，而是原样（转义后）放入 <span class="math math-inline"> / <div class="math math-display">，
+// 由前端调用 KaTeX 渲染。Markdown 中的原始 HTML 一律按文本转义；输出再经 Sanitize 白名单清洗。
+package markdown
+
+import (
+	"html"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// maxDepth 引用 / 列表的最大嵌套层数，超过后按段落处理，防止恶意输入导致深递归。
+const maxDepth = 8
+
+// Render 把 Markdown 渲染为经过白名单清洗的 HTML；空输入返回空串。
+func Render(src string) string {
+    if strings.TrimSpace(src) == "" { return "" }
+    src = strings.ReplaceAll(strings.ReplaceAll(src, "\r\n", "\n"), "\r", "\n")
+    src = strings.ReplaceAll(src, "\x00", "�")
+    var b strings.Builder
+    renderBlocks(&b, strings.Split(src, "\n"), 0)
+    return Sanitize(b.String())
+}
+
+// Preformatted 把纯文本（如样例输入输出）原样放入 <pre><code>，不做 Markdown 解析。
+func Preformatted(text string) string {
+    text = strings.ReplaceAll(text, "\r\n", "\n")
+    if text != "" && !strings.HasSuffix(text, "\n") { text += "\n" }
+    return "<pre><code>" + html.EscapeString(text) + "</code></pre>"
+}
+
+var (
+    headingRe  = regexp.MustCompile(`^ {0,3}(#{1,6})(?:[ \t]+(.*?))?(?:[ \t]+#+)?[ \t]*$`)
+    hrRe       = regexp.MustCompile(`^ {0,3}(?:(?:-[ \t]*){3,}|(?:\*[ \t]*){3,}|(?:_[ \t]*){3,})$`)
+    fenceRe    = regexp.MustCompile("^( {0,3})(`{3,}|~{3,})[ \t]*([^`]*)$")
+    bulletRe   = regexp.MustCompile(`^( {0,3})([-*+])(?:[ \t]+|$)`)
+    orderedRe  = regexp.MustCompile(`^( {0,3})([0-9]{1,9})([.)])(?:[ \t]+|$)`)
+    quoteRe    = regexp.MustCompile(`^ {0,3}> ?`)
+    tableSepRe = regexp.MustCompile(`^ {0,3}\|?[ \t]*:?-+:?[ \t]*(\|[ \t]*:?-+:?[ \t]*)*\|?[ \t]*$`)
+    langRe     = regexp.MustCompile(`^[A-Za-z0-9_+-]{1,32}$`)
+)
+
+func isBlank(s string) bool { return strings.TrimSpace(s) == "" }
+
+// startsBlock 该行是否开始一个会打断段落的块。
+func startsBlock(line string) bool {
+    t := strings.TrimSpace(line)
+    return headingRe.MatchString(line) || hrRe.MatchString(line) || fenceRe.MatchString(line) ||
+        quoteRe.MatchString(line) || bulletRe.MatchString(line) || orderedRe.MatchString(line) ||
+        strings.HasPrefix(t, "$$") || strings.HasPrefix(t, `\[`)
+}
+
+func renderBlocks(b *strings.Builder, lines []string, depth int) {
+    for i := 0; i < len(lines); {
+        line := lines[i]
+        t := strings.TrimSpace(line)
+        switch {
+        case isBlank(line):
+            i++
+        case fenceRe.MatchString(line):
+            i = renderFence(b, lines, i)
+        case strings.HasPrefix(t, "$$") || strings.HasPrefix(t, `\[`):
+            i = renderDisplayMath(b, lines, i)
+        case headingRe.MatchString(line):
+            m := headingRe.FindStringSubmatch(line)
+            n := strconv.Itoa(len(m[1]))
+            b.WriteString("<h" + n + ">" + renderInline(m[2], 0) + "</h" + n + ">\n")
+            i++
+        case hrRe.MatchString(line):
+            b.WriteString("<hr>\n")
+            i++
+        case depth < maxDepth && quoteRe.MatchString(line):
+            var inner []string
+            for ; i < len(lines) && !isBlank(lines[i]); i++ {
+                if loc := quoteRe.FindStringIndex(lines[i]); loc != nil {
+                    inner = append(inner, lines[i][loc[1]:])
+                } else if len(inner) > 0 && !startsBlock(lines[i]) {
+                    inner = append(inner, lines[i]) // 惰性续行
+                } else {
+                    break
+                }
+            }
+            b.WriteString("<blockquote>\n")
+            renderBlocks(b, inner, depth+1)
+            b.WriteString("</blockquote>\n")
+        case depth < maxDepth && (bulletRe.MatchString(line) || orderedRe.MatchString(line)):
+            i = renderList(b, lines, i, depth)
+        case i+1 < len(lines) && strings.Contains(line, "|") && tableSepRe.MatchString(lines[i+1]):
+            i = renderTable(b, lines, i)
+        default:
+            var para []string
+            for ; i < len(lines) && !isBlank(lines[i]); i++ {
+                if len(para) > 0 && startsBlock(lines[i]) { break }
+                para = append(para, lines[i])
+            }
+            b.WriteString("<p>" + renderInline(joinParagraph(para), 0) + "</p>\n")
+        }
+    }
+}
+
+// joinParagraph 去掉行首缩进并合并为段落文本（保留换行，供硬换行判断）。
+func joinParagraph(lines []string) string {
+    out := make([]string, len(lines))
+    for i, l := range lines { out[i] = strings.TrimLeft(l, " \t") }
+    return strings.TrimRight(strings.Join(out, "\n"), " \t")
+}
+
+func renderFence(b *strings.Builder, lines []string, i int) int {
+    m := fenceRe.FindStringSubmatch(lines[i])
+    indent, fence := len(m[1]), m[2]
+    lang := ""
+    if f := strings.Fields(m[3]); len(f) > 0 && langRe.MatchString(f[0]) { lang = strings.ToLower(f[0]) }
+    var code []string
+    i++
+    for ; i < len(lines); i++ {
+        t := strings.TrimSpace(lines[i])
+        if strings.HasPrefix(t, fence[:1]) && strings.Trim(t, fence[:1]) == "" && len(t) >= len(fence) { i++; break }
+        l := lines[i]
+        for k := 0; k < indent && strings.HasPrefix(l, " "); k++ { l = l[1:] }
+        code = append(code, l)
+    }
+    b.WriteString("<pre><code")
+    if lang != "" { b.WriteString(` class="language-` + lang + `"`) }
+    b.WriteString(">")
+    if len(code) > 0 { b.WriteString(html.EscapeString(strings.Join(code, "\n")) + "\n") }
+    b.WriteString("</code></pre>\n")
+    return i
+}
+
+// renderDisplayMath 处理 $$...$$ 与 \[...\] 块（可跨行）；找不到结束定界符时按段落处理整行。
+func renderDisplayMath(b *strings.Builder, lines []string, i int) int {
+    t := strings.TrimSpace(lines[i])
+    open, close := "$$", "$$"
+    if strings.HasPrefix(t, `\[`) { open, close = `\[`, `\]` }
+    rest := t[len(open):]
+    if j := strings.Index(rest, close); j >= 0 {
+        if strings.TrimSpace(rest[j+len(close):]) == "" {
+            writeDisplayMath(b, rest[:j])
+            return i + 1
+        }
+        // 同一行还有其他内容：作为段落交给行内解析
+        b.WriteString("<p>" + renderInline(t, 0) + "</p>\n")
+        return i + 1
+    }
+    tex := []string{rest}
+    for k := i + 1; k < len(lines); k++ {
+        lt := strings.TrimSpace(lines[k])
+        if j := strings.Index(lt, close); j >= 0 && strings.TrimSpace(lt[j+len(close):]) == "" {
+            tex = append(tex, lt[:j])
+            writeDisplayMath(b, strings.Join(tex, "\n"))
+            return k + 1
+        }
+        tex = append(tex, lines[k])
+    }
+    b.WriteString("<p>" + renderInline(t, 0) + "</p>\n")
+    return i + 1
+}
+
+func writeDisplayMath(b *strings.Builder, tex string) {
+    b.WriteString(`<div class="math math-display">` + html.EscapeString(strings.TrimSpace(tex)) + "</div>\n")
+}
+
+// listMarker 返回列表项标记信息：是否有序、起始数字、内容起始列。
+func listMarker(line string) (ordered bool, start int, marker string, width int, ok bool) {
+    if m := bulletRe.FindStringSubmatchIndex(line); m != nil {
+        return false, 0, line[m[4]:m[5]], m[1], true
+    }
+    if m := orderedRe.FindStringSubmatchIndex(line); m != nil {
+        n, _ := strconv.Atoi(line[m[4]:m[5]])
+        return true, n, line[m[6]:m[7]], m[1], true
+    }
+    return false, 0, "", 0, false
+}
+
+func renderList(b *strings.Builder, lines []string, i int, depth int) int {
+    ordered, start, marker, _, _ := listMarker(lines[i])
+    var items [][]string
+    loose := false
+    for i < len(lines) {
+        o, _, mk, width, ok := listMarker(lines[i])
+        if !ok || o != ordered || mk != marker { break }
+        item := []string{lines[i][width:]}
+        i++
+        for i < len(lines) {
+            l := lines[i]
+            if isBlank(l) {
+                // 空行后若仍有缩进内容则属于同一项，否则结束该项
+                k := i
+                for k < len(lines) && isBlank(lines[k]) { k++ }
+                if k < len(lines) && strings.HasPrefix(lines[k], "  ") {
+                    loose = true
+                    for ; i < k; i++ { item = append(item, "") }
+                    continue
+                }
+                if k < len(lines) {
+                    if _, _, mk2, _, ok2 := listMarker(lines[k]); ok2 && mk2 == marker { loose = true }
+                }
+                break
+            }
+            if strings.HasPrefix(l, "  ") || strings.HasPrefix(l, "\t") {
+                item = append(item, strings.TrimPrefix(strings.TrimPrefix(l, "  "), "  "))
+            } else if _, _, _, _, isItem := listMarker(l); isItem || startsBlock(l) {
+                break
+            } else {
+                item = append(item, l) // 惰性续行
+            }
+            i++
+        }
+        items = append(items, item)
+        for i < len(lines) && isBlank(lines[i]) {
+            k := i
+            for k < len(lines) && isBlank(lines[k]) { k++ }
+            if k < len(lines) {
+                if o2, _, mk2, _, ok2 := listMarker(lines[k]); ok2 && o2 == ordered && mk2 == marker { i = k; break }
+            }
+            break
+        }
+    }
+    tag := "ul"
+    if ordered { tag = "ol" }
+    b.WriteString("<" + tag)
+    if ordered && start != 1 { b.WriteString(` start="` + strconv.Itoa(start) + `"`) }
+    b.WriteString(">\n")
+    for _, item := range items {
+        var ib strings.Builder
+        renderBlocks(&ib, item, depth+1)
+        s := ib.String()
+        // 紧凑列表：单段落去掉 <p>
+        if !loose && strings.HasPrefix(s, "<p>") {
+            if end := strings.Index(s, "</p>\n"); end >= 0 { s = s[3:end] + s[end+4:] }
+        }
+        b.WriteString("<li>" + strings.TrimSuffix(s, "\n") + "</li>\n")
+    }
+    b.WriteString("</" + tag + ">\n")
+    return i
+}
+
+// splitRow 按未转义、不在代码片段内的 | 切分表格行。
+func splitRow(line string) []string {
+    line = strings.TrimSpace(line)
+    line = strings.TrimPrefix(line, "|")
+    if strings.HasSuffix(line, "|") && !strings.HasSuffix(line, `\|`) { line = line[:len(line)-1] }
+    var cells []string
+    var cur strings.Builder
+    inCode := false
+    for k := 0; k < len(line); k++ {
+        c := line[k]
+        switch {
+        case c == '\\' && k+1 < len(line) && line[k+1] == '|':
+            cur.WriteByte('|')
+            k++
+        case c == '`':
+            inCode = !inCode
+            cur.WriteByte(c)
+        case c == '|' && !inCode:
+            cells = append(cells, strings.TrimSpace(cur.String()))
+            cur.Reset()
+        default:
+            cur.WriteByte(c)
+        }
+    }
+    return append(cells, strings.TrimSpace(cur.String()))
+}
+
+func renderTable(b *strings.Builder, lines []string, i int) int {
+    head := splitRow(lines[i])
+    seps := splitRow(lines[i+1])
+    aligns := make([]string, len(head))
+    for k := range aligns {
+        if k >= len(seps) { break }
+        s := seps[k]
+        switch {
+        case strings.HasPrefix(s, ":") && strings.HasSuffix(s, ":"):
+            aligns[k] = "center"
+        case strings.HasSuffix(s, ":"):
+            aligns[k] = "right"
+        case strings.HasPrefix(s, ":"):
+            aligns[k] = "left"
+        }
+    }
+    cell := func(tag string, k int, text string) string {
+        attr := ""
+        if aligns[k] != "" { attr = ` align="` + aligns[k] + `"` }
+        return "<" + tag + attr + ">" + renderInline(text, 0) + "</" + tag + ">"
+    }
+    b.WriteString("<table>\n<thead>\n<tr>")
+    for k, h := range head { b.WriteString(cell("th", k, h)) }
+    b.WriteString("</tr>\n</thead>\n")
+    i += 2
+    if i < len(lines) && !isBlank(lines[i]) && strings.Contains(lines[i], "|") {
+        b.WriteString("<tbody>\n")
+        for ; i < len(lines) && !isBlank(lines[i]) && strings.Contains(lines[i], "|") && !startsBlock(lines[i]); i++ {
+            row := splitRow(lines[i])
+            b.WriteString("<tr>")
+            for k := range head {
+                text := ""
+                if k < len(row) { text = row[k] }
+                b.WriteString(cell("td", k, text))
+            }
+            b.WriteString("</tr>\n")
+        }
+        b.WriteString("</tbody>\n")
+    }
+    b.WriteString("</table>\n")
+    return i
+}
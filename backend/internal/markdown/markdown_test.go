@@ -0,0 +1,149 @@
+package markdown
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"golang.org/x/net/html"
+)
+
+func TestRenderBlocks(t *testing.T) {
+    require.Empty(t, Render("  \n"))
+    require.Equal(t, "<h2>输入格式</h2>\n<p>第一行 <em>n</em>，第二行 <strong>m</strong>。</p>\n", Render("## 输入格式\n\n第一行 *n*，第二行 **m**。"))
+    require.Equal(t, "<ul>\n<li>a</li>\n<li>b\n<ul>\n<li>c</li>\n</ul></li>\n</ul>\n", Render("- a\n- b\n  - c"))
+    require.Equal(t, "<ol start=\"3\">\n<li>x</li>\n<li>y</li>\n</ol>\n", Render("3. x\n4. y"))
+    require.Equal(t, "<pre><code class=\"language-cpp\">if (a &lt; b) {}\n</code></pre>\n", Render("```cpp\nif (a < b) {}\n```"))
+    require.Equal(t, "<blockquote>\n<p>注意\n溢出</p>\n</blockquote>\n", Render("> 注意\n> 溢出"))
+    require.Equal(t, "<hr>\n", Render("---"))
+    require.Equal(t, "<table>\n<thead>\n<tr><th align=\"left\">n</th><th align=\"right\">答案</th></tr>\n</thead>\n<tbody>\n<tr><td align=\"left\">1</td><td align=\"right\"><code>a|b</code></td></tr>\n</tbody>\n</table>\n",
+        Render("| n | 答案 |\n|:--|--:|\n| 1 | `a\\|b` |"))
+}
+
+func TestRenderInline(t *testing.T) {
+    require.Equal(t, "<p>见 <a href=\"https://oi-wiki.org\" title=\"wiki\" rel=\"nofollow noopener noreferrer\">OI Wiki</a> 与 <img src=\"fig/1.png\" alt=\"图 1\"></p>\n",
+        Render("见 [OI Wiki](https://oi-wiki.org \"wiki\") 与 ![图 1](fig/1.png)"))
+    require.Equal(t, "<p><code>a_b*c</code> snake_case_name <del>旧</del> \\*x*</p>\n", Render("`a_b*c` snake_case_name ~~旧~~ \\\\\\*x*"))
+    require.Equal(t, "<p>a<br>\nb\nc</p>\n", Render("a  \nb\nc"))
+    require.Equal(t, "<p><a href=\"mailto:oj@example.com\" rel=\"nofollow noopener noreferrer\">oj@example.com</a></p>\n", Render("<oj@example.com>"))
+}
+
+func TestRenderMath(t *testing.T) {
+    require.Equal(t, "<p>求 <span class=\"math math-inline\">\\sum_{i=1}^{n} a_i</span> 的值</p>\n", Render("求 $\\sum_{i=1}^{n} a_i$ 的值"))
+    // 金额等普通 $ 不被识别为公式
+    require.Equal(t, "<p>花费 $5 和 $6，或 $ x $</p>\n", Render("花费 $5 和 $6，或 $ x $"))
+    require.Equal(t, "<p><span class=\"math math-inline\">a&lt;b</span> 与 <span class=\"math math-display\">x^2</span></p>\n", Render("\\(a<b\\) 与 $$x^2$$"))
+    require.Equal(t, "<div class=\"math math-display\">1 \\le n \\le 10^5\n\\frac{a}{b}</div>\n", Render("$$\n1 \\le n \\le 10^5\n\\frac{a}{b}\n$$"))
+    require.Equal(t, "<div class=\"math math-display\">x</div>\n", Render("\\[ x \\]"))
+    // 公式与代码内的 Markdown 不再解析
+    require.Equal(t, "<p><span class=\"math math-inline\">a*b*c</span> <code>$x$</code></p>\n", Render("$a*b*c$ `$x$`"))
+}
+
+func TestRenderPathologicalInput(t *testing.T) {
+    // 大量未闭合定界符与深嵌套不应退化或栈溢出
+    for _, s := range []string{strings.Repeat("*a ", 20000), strings.Repeat("`", 5000) + strings.Repeat("[", 20000), strings.Repeat("> ", 5000) + "x", strings.Repeat("- ", 5000) + "x", strings.Repeat("**", 5000)} {
+        out := Render(s)
+        require.NotEmpty(t, out)
+    }
+}
+
+func TestSanitize(t *testing.T) {
+    require.Equal(t, "<p>ok</p>", Sanitize("<p>ok</p>"))
+    require.Equal(t, "<strong>x</strong>", Sanitize("<strong><u>x</u>"))
+    require.Equal(t, "<span class=\"math\">m</span>", Sanitize("<span class=\"math evil\" style=\"color:red\">m</span>"))
+    require.Equal(t, "<td align=\"center\">1</td><td>2</td>", Sanitize("<td align=\"center\">1</td><td align=\"javascript\">2</td>"))
+    require.Equal(t, "<ol>x</ol>", Sanitize("<ol start=\"1; x\">x</ol>"))
+    require.Equal(t, "a &lt; b", Sanitize("a &lt; b"))
+}
+
+// xssPayloads 常见 XSS 载荷；无论直接清洗还是作为 Markdown 渲染，输出中都不能出现可执行的内容。
+var xssPayloads = []string{
+    `<script>alert(1)</script>`,
+    `<SCRIPT SRC=//evil.example/x.js></SCRIPT>`,
+    `<scr<script>ipt>alert(1)</script>`,
+    `<img src=x onerror=alert(1)>`,
+    `<img src="javascript:alert(1)">`,
+    `<IMG SRC=JaVaScRiPt:alert(1)>`,
+    `<a href="java&#x09;script:alert(1)">x</a>`,
+    `<a href="javascript&#58;alert(1)">x</a>`,
+    `<a href=" javascript:alert(1)">x</a>`,
+    `<a href="data:text/html;base64,PHNjcmlwdD5hbGVydCgxKTwvc2NyaXB0Pg==">x</a>`,
+    `<a href="vbscript:msgbox(1)">x</a>`,
+    `<svg onload=alert(1)><script>alert(1)</script></svg>`,
+    `<math><mtext><table><mglyph><style><img src=x onerror=alert(1)>`,
+    `<iframe src="https://evil.example"></iframe>`,
+    `<style>body{background:url(javascript:alert(1))}</style>`,
+    `<div style="background:url(javascript:alert(1))" onclick="alert(1)">x</div>`,
+    `<!--<script>alert(1)</script>-->`,
+    `<body onload=alert(1)>`,
+    `<object data="javascript:alert(1)"></object>`,
+    `<form action="javascript:alert(1)"><button>x</button></form>`,
+    `<a href="#" onmouseover="alert(1)">x</a>`,
+    `<p title="&quot;><script>alert(1)</script>">x</p>`,
+    `<noscript><p title="</noscript><img src=x onerror=alert(1)>"></noscript>`,
+    `<textarea><script>alert(1)</script></textarea>`,
+    `<base href="javascript:alert(1)//">`,
+    `<meta http-equiv="refresh" content="0;url=javascript:alert(1)">`,
+}
+
+// requireNoXSS 按 HTML 解析输出，确认只有白名单标签、没有事件或样式属性、链接不含危险协议。
+// 载荷被转义成文本后仍会包含 "onerror" 等字样，因此不能简单地做字符串匹配。
+func requireNoXSS(t *testing.T, in, out string) {
+    t.Helper()
+    z := html.NewTokenizer(strings.NewReader(out))
+    for tt := z.Next(); tt != html.ErrorToken; tt = z.Next() {
+        tok := z.Token()
+        require.NotEqual(t, html.CommentToken, tt, "input: %s\noutput: %s", in, out)
+        if tt != html.StartTagToken && tt != html.SelfClosingTagToken { continue }
+        _, ok := allowedAttrs[tok.Data]
+        require.True(t, ok, "tag %q; input: %s\noutput: %s", tok.Data, in, out)
+        for _, a := range tok.Attr {
+            require.False(t, strings.HasPrefix(a.Key, "on") || a.Key == "style", "attr %q; input: %s", a.Key, in)
+            if a.Key == "href" || a.Key == "src" {
+                v := strings.ToLower(a.Val)
+                for _, scheme := range []string{"javascript:", "vbscript:", "data:"} {
+                    require.NotContains(t, v, scheme, "input: %s\noutput: %s", in, out)
+                }
+            }
+        }
+    }
+}
+
+func TestSanitizeXSS(t *testing.T) {
+    for _, p := range xssPayloads {
+        requireNoXSS(t, p, Sanitize(p))
+    }
+    require.Equal(t, `<a rel="nofollow noopener noreferrer">x</a>`, Sanitize(`<a href="javascript&#58;alert(1)">x</a>`))
+    // 拼接式标签被整体去掉，残余部分只是转义后的文本
+    require.Equal(t, "ipt&gt;alert(1)", Sanitize(`<scr<script>ipt>alert(1)</script>`))
+}
+
+func TestRenderXSS(t *testing.T) {
+    for _, p := range xssPayloads {
+        out := Render(p)
+        requireNoXSS(t, p, out)
+        require.NotContains(t, out, "<img", p) // 原始 HTML 始终按文本输出
+    }
+    cases := []string{
+        "[x](javascript:alert(1))",
+        "[x](JavaScript:alert(1))",
+        "[x](<java\tscript:alert(1)>)",
+        "[x](data:text/html,<script>alert(1)</script>)",
+        "![x](javascript:alert(1))",
+        "![x](mailto:a@b.c)",
+        "<javascript:alert(1)>",
+        "[x](https://ok.example \"\\\" onmouseover=\\\"alert(1)\")",
+        "$<img src=x onerror=alert(1)>$",
+        "$$</span><script>alert(1)</script>$$",
+        "```\"><script>alert(1)</script>\nx\n```",
+        "`<script>alert(1)</script>`",
+    }
+    for _, c := range cases {
+        out := Render(c)
+        requireNoXSS(t, c, out)
+        require.NotContains(t, out, "<img", c)
+        require.NotContains(t, out, "mailto", c)
+    }
+    require.Equal(t, "<p>x</p>\n", Render("[x](javascript:alert(1))"))
+    require.Equal(t, "<pre><code>x\n</code></pre>\n", Render("```\"><script>alert(1)</script>\nx\n```"))
+}
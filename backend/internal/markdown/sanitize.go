@@ -0,0 +1,132 @@
+package markdown
+
+import (
+	"bytes"
+	"regexp"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// allowedAttrs 白名单标签及其允许的属性；不在表中的标签被去掉（保留文本）。
+var allowedAttrs = map[string]map[string]bool{
+    "p": {}, "br": {}, "hr": {}, "h1": {}, "h2": {}, "h3": {}, "h4": {}, "h5": {}, "h6": {},
+    "strong": {}, "em": {}, "del": {}, "sub": {}, "sup": {}, "blockquote": {},
+    "ul": {}, "ol": {"start": true}, "li": {},
+    "pre": {}, "code": {"class": true},
+    "span": {"class": true}, "div": {"class": true},
+    "a":   {"href": true, "title": true},
+    "img": {"src": true, "alt": true, "title": true},
+    "table": {}, "thead": {}, "tbody": {}, "tr": {}, "th": {"align": true}, "td": {"align": true},
+}
+
+// droppedWithContent 连同内容一起丢弃的标签（脚本、样式、嵌入内容及 RCDATA 元素）。
+var droppedWithContent = map[string]bool{
+    "script": true, "style": true, "iframe": true, "object": true, "embed": true, "template": true,
+    "noscript": true, "noembed": true, "noframes": true, "textarea": true, "title": true, "xmp": true,
+    "svg": true, "math": true, "frameset": true, "plaintext": true,
+}
+
+var voidTags = map[string]bool{"br": true, "hr": true, "img": true}
+
+var (
+    classPattern = regexp.MustCompile(`^(math|math-inline|math-display|language-[a-z0-9_+-]{1,32})$`)
+    alignValues  = map[string]bool{"left": true, "center": true, "right": true}
+    digits       = regexp.MustCompile(`^[0-9]{1,9}$`)
+)
+
+// SafeURL 链接与图片地址是否安全：相对地址，或 http / https / mailto（img 不允许 mailto，由调用方区分）。
+// 含控制字符或空白（浏览器会忽略它们，可用于拼出 "java\tscript:"）的地址一律拒绝。
+func SafeURL(u string) bool {
+    u = strings.TrimSpace(u)
+    if u == "" { return false }
+    for _, r := range u {
+        if r < 0x20 || r == 0x7f || r == ' ' { return false }
+    }
+    i := strings.IndexAny(u, ":/?#")
+    if i < 0 || u[i] != ':' { return true } // 无 scheme 的相对地址
+    switch strings.ToLower(u[:i]) {
+    case "http", "https", "mailto":
+        return true
+    }
+    return false
+}
+
+func safeImageURL(u string) bool { return SafeURL(u) && !strings.HasPrefix(strings.ToLower(strings.TrimSpace(u)), "mailto:") }
+
+// filterAttr 返回清洗后的属性值，ok 为 false 表示丢弃该属性。
+func filterAttr(tag, key, val string) (string, bool) {
+    if !allowedAttrs[tag][key] { return "", false }
+    switch key {
+    case "href":
+        return val, SafeURL(val)
+    case "src":
+        return val, safeImageURL(val)
+    case "class":
+        var keep []string
+        for _, c := range strings.Fields(val) { if classPattern.MatchString(c) { keep = append(keep, c) } }
+        return strings.Join(keep, " "), len(keep) > 0
+    case "align":
+        return val, alignValues[val]
+    case "start":
+        return val, digits.MatchString(val)
+    }
+    return val, true
+}
+
+// Sanitize 按白名单清洗 HTML：去掉不允许的标签（脚本类标签连同内容）、事件处理器等属性、
+// 危险协议的链接与注释，文本重新转义，并补齐未闭合的标签。渲染结果在输出前总会经过这里。
+func Sanitize(src string) string {
+    z := html.NewTokenizer(strings.NewReader(src))
+    var out bytes.Buffer
+    var open []string // 已输出的未闭合标签
+    skip := 0         // >0 时处于 droppedWithContent 标签内部
+    var skipTag string
+    for {
+        tt := z.Next()
+        if tt == html.ErrorToken { break }
+        tok := z.Token()
+        if skip > 0 {
+            // 仅跟踪同名标签的嵌套，其余一律丢弃
+            if tok.Data == skipTag {
+                switch tt {
+                case html.StartTagToken: skip++
+                case html.EndTagToken: skip--
+                }
+            }
+            continue
+        }
+        switch tt {
+        case html.TextToken:
+            out.WriteString(html.EscapeString(tok.Data))
+        case html.StartTagToken, html.SelfClosingTagToken:
+            if droppedWithContent[tok.Data] {
+                if tt == html.StartTagToken { skip, skipTag = 1, tok.Data }
+                continue
+            }
+            if _, ok := allowedAttrs[tok.Data]; !ok { continue }
+            out.WriteByte('<')
+            out.WriteString(tok.Data)
+            for _, a := range tok.Attr {
+                if a.Namespace != "" { continue }
+                v, ok := filterAttr(tok.Data, a.Key, a.Val)
+                if !ok { continue }
+                out.WriteString(" " + a.Key + `="` + html.EscapeString(v) + `"`)
+            }
+            if tok.Data == "a" { out.WriteString(` rel="nofollow noopener noreferrer"`) }
+            out.WriteByte('>')
+            if !voidTags[tok.Data] { open = append(open, tok.Data) }
+        case html.EndTagToken:
+            // 只闭合已打开的标签，并顺带闭合其内部未闭合的标签
+            for i := len(open) - 1; i >= 0; i-- {
+                if open[i] != tok.Data { continue }
+                for j := len(open) - 1; j >= i; j-- { out.WriteString("</" + open[j] + ">") }
+                open = open[:i]
+                break
+            }
+        }
+        // 注释、DOCTYPE 直接丢弃
+    }
+    for j := len(open) - 1; j >= 0; j-- { out.WriteString("</" + open[j] + ">") }
+    return out.String()
+}
@@ -0,0 +1,162 @@
+package metrics
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// 判题队列与 worker 相关的指标：队列深度在抓取时经回调查询（带缓存），其余在 service 状态变更时记录。
+
+// JudgeQueueSnapshot 队列快照：Depth 为非终态各状态的运行数，OldestQueued 为零值表示队列为空。
+type JudgeQueueSnapshot struct {
+    Depth        map[string]int
+    OldestQueued time.Time
+}
+
+// JudgeQueueOptions 队列采集配置。
+type JudgeQueueOptions struct {
+    TTL            time.Duration // 快照缓存时长，避免多个 Prometheus 副本频繁抓取时重复查询；默认 15s
+    Timeout        time.Duration // 单次查询超时；默认 2s
+    WorkerCapacity int           // 判题 worker 并发槽位总数；>0 时导出利用率
+}
+
+// judgeQueueStatuses 总是导出的状态（无运行时为 0，避免序列消失）
+var judgeQueueStatuses = []string{"queued", "running"}
+
+// RegisterJudgeQueue 注册队列深度采集：fetch 在抓取时调用，结果缓存 TTL；查询失败时沿用上次快照并计数。
+func RegisterJudgeQueue(fetch func(ctx context.Context) (JudgeQueueSnapshot, error), o JudgeQueueOptions) {
+    Init()
+    if fetch == nil { return }
+    if o.TTL <= 0 { o.TTL = 15 * time.Second }
+    if o.Timeout <= 0 { o.Timeout = 2 * time.Second }
+    judgeQueue.set(fetch, o)
+}
+
+type judgeQueueCollector struct {
+    mu      sync.Mutex
+    fetch   func(ctx context.Context) (JudgeQueueSnapshot, error)
+    opts    JudgeQueueOptions
+    cached  JudgeQueueSnapshot
+    fetched time.Time
+    now     func() time.Time
+
+    depth, oldestAge, capacity, utilization *prometheus.Desc
+    errors prometheus.Counter
+}
+
+func newJudgeQueueCollector() *judgeQueueCollector {
+    return &judgeQueueCollector{
+        now:         time.Now,
+        depth:       prometheus.NewDesc("codyssey_judge_queue_depth", "Number of judge runs in a non-terminal status.", []string{"status"}, nil),
+        oldestAge:   prometheus.NewDesc("codyssey_judge_queue_oldest_age_seconds", "Age of the oldest queued judge run (0 when the queue is empty).", nil, nil),
+        capacity:    prometheus.NewDesc("codyssey_judge_worker_capacity", "Configured number of concurrent judge worker slots.", nil, nil),
+        utilization: prometheus.NewDesc("codyssey_judge_worker_utilization", "Running judge runs divided by worker capacity (may exceed 1 when capacity is understated).", nil, nil),
+        errors: prometheus.NewCounter(prometheus.CounterOpts{
+            Namespace: "codyssey",
+            Name:      "judge_queue_scrape_errors_total",
+            Help:      "Count of failed judge queue snapshot queries (the previous snapshot is served).",
+        }),
+    }
+}
+
+func (c *judgeQueueCollector) set(fetch func(ctx context.Context) (JudgeQueueSnapshot, error), o JudgeQueueOptions) {
+    c.mu.Lock()
+    defer c.mu.Unlock()
+    c.fetch, c.opts, c.fetched = fetch, o, time.Time{}
+}
+
+func (c *judgeQueueCollector) Describe(ch chan<- *prometheus.Desc) {
+    ch <- c.depth
+    ch <- c.oldestAge
+    ch <- c.capacity
+    ch <- c.utilization
+    c.errors.Describe(ch)
+}
+
+// snapshot 返回缓存或新查询的快照；锁内查询，同一时刻只有一个抓取会访问数据库。
+func (c *judgeQueueCollector) snapshot() (JudgeQueueSnapshot, JudgeQueueOptions, bool) {
+    c.mu.Lock()
+    defer c.mu.Unlock()
+    if c.fetch == nil { return JudgeQueueSnapshot{}, c.opts, false }
+    now := c.now()
+    if c.fetched.IsZero() || now.Sub(c.fetched) >= c.opts.TTL {
+        ctx, cancel := context.WithTimeout(context.Background(), c.opts.Timeout)
+        snap, err := c.fetch(ctx)
+        cancel()
+        if err != nil {
+            c.errors.Inc()
+            if c.fetched.IsZero() { return JudgeQueueSnapshot{}, c.opts, false }
+        } else {
+            c.cached, c.fetched = snap, now
+        }
+    }
+    return c.cached, c.opts, true
+}
+
+func (c *judgeQueueCollector) Collect(ch chan<- prometheus.Metric) {
+    c.errors.Collect(ch)
+    snap, o, ok := c.snapshot()
+    if !ok { return }
+    for _, st := range judgeQueueStatuses {
+        ch <- prometheus.MustNewConstMetric(c.depth, prometheus.GaugeValue, float64(snap.Depth[st]), st)
+    }
+    age := 0.0
+    if !snap.OldestQueued.IsZero() { age = max(c.now().Sub(snap.OldestQueued).Seconds(), 0) }
+    ch <- prometheus.MustNewConstMetric(c.oldestAge, prometheus.GaugeValue, age)
+    if o.WorkerCapacity > 0 {
+        ch <- prometheus.MustNewConstMetric(c.capacity, prometheus.GaugeValue, float64(o.WorkerCapacity))
+        ch <- prometheus.MustNewConstMetric(c.utilization, prometheus.GaugeValue, float64(snap.Depth["running"])/float64(o.WorkerCapacity))
+    }
+}
+
+// ObserveJudgeQueueWait 记录一次运行从入队到开始执行的等待时间。
+func ObserveJudgeQueueWait(d time.Duration) {
+    if judgeQueueWait == nil || d < 0 { return }
+    judgeQueueWait.Observe(d.Seconds())
+}
+
+// ObserveJudgeRunExecution 按语言与判题内核版本记录 start->finish 耗时。
+func ObserveJudgeRunExecution(language, judgeVersion string, startedAt, finishedAt *time.Time) {
+    if judgeRunExecution == nil || startedAt == nil || finishedAt == nil || finishedAt.Before(*startedAt) { return }
+    judgeRunExecution.WithLabelValues(languageLabels.value(language), judgeVersionLabels.value(judgeVersion)).Observe(finishedAt.Sub(*startedAt).Seconds())
+}
+
+// IncJudgeVerdict 统计题目的最终判定结果（提交进入终态时调用）。
+func IncJudgeVerdict(problemID, verdict string) {
+    if judgeVerdicts == nil { return }
+    judgeVerdicts.WithLabelValues(problemLabels.value(problemID), verdict).Inc()
+}
+
+// labelLimiter 限制来自客户端输入的标签取值个数：超出上限的新值归入 "other"，空值为 "unknown"。
+type labelLimiter struct {
+    mu   sync.Mutex
+    max  int
+    seen map[string]struct{}
+}
+
+func newLabelLimiter(max int) *labelLimiter { return &labelLimiter{max: max, seen: map[string]struct{}{}} }
+
+// maxLabelLen 单个标签值的最大长度
+const maxLabelLen = 64
+
+func (l *labelLimiter) value(v string) string {
+    v = strings.ToLower(strings.TrimSpace(v))
+    if v == "" { return "unknown" }
+    if len(v) > maxLabelLen { v = v[:maxLabelLen] }
+    l.mu.Lock()
+    defer l.mu.Unlock()
+    if _, ok := l.seen[v]; ok { return v }
+    if len(l.seen) >= l.max { return "other" }
+    l.seen[v] = struct{}{}
+    return v
+}
+
+var (
+    languageLabels     = newLabelLimiter(32)
+    judgeVersionLabels = newLabelLimiter(16)
+    problemLabels      = newLabelLimiter(2000)
+)
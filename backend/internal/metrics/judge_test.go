@@ -0,0 +1,88 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func scrapeBody(t *testing.T) string {
+    gin.SetMode(gin.TestMode)
+    r := gin.New()
+    r.GET("/metrics", Handler())
+    w := httptest.NewRecorder()
+    r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+    return w.Body.String()
+}
+
+func requireLines(t *testing.T, body string, lines ...string) {
+    t.Helper()
+    for _, l := range lines {
+        if !strings.Contains(body, l) { t.Fatalf("expected %q in metrics output", l) }
+    }
+}
+
+func TestJudgeQueueCollectorCachesSnapshot(t *testing.T) {
+    Init()
+    now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+    judgeQueue.now = func() time.Time { return now }
+    defer func() { judgeQueue.now = time.Now; judgeQueue.set(nil, JudgeQueueOptions{}) }()
+
+    calls := 0
+    fail := false
+    RegisterJudgeQueue(func(ctx context.Context) (JudgeQueueSnapshot, error) {
+        calls++
+        if fail { return JudgeQueueSnapshot{}, errors.New("db down") }
+        return JudgeQueueSnapshot{Depth: map[string]int{"queued": 3, "running": calls}, OldestQueued: now.Add(-90 * time.Second)}, nil
+    }, JudgeQueueOptions{TTL: 10 * time.Second, WorkerCapacity: 4})
+
+    body := scrapeBody(t)
+    requireLines(t, body,
+        `codyssey_judge_queue_depth{status="queued"} 3`,
+        `codyssey_judge_queue_depth{status="running"} 1`,
+        "codyssey_judge_queue_oldest_age_seconds 90",
+        "codyssey_judge_worker_capacity 4",
+        "codyssey_judge_worker_utilization 0.25",
+    )
+    scrapeBody(t)
+    if calls != 1 { t.Fatalf("expected cached snapshot, fetch called %d times", calls) }
+
+    // 过期后重新查询；查询失败时沿用上次快照
+    now = now.Add(11 * time.Second)
+    fail = true
+    body = scrapeBody(t)
+    if calls != 2 { t.Fatalf("expected refetch after ttl, got %d calls", calls) }
+    requireLines(t, body, `codyssey_judge_queue_depth{status="running"} 1`, "codyssey_judge_queue_scrape_errors_total 1")
+}
+
+func TestJudgeRunObservations(t *testing.T) {
+    Init()
+    ObserveJudgeQueueWait(1500 * time.Millisecond)
+    start := time.Now()
+    end := start.Add(300 * time.Millisecond)
+    ObserveJudgeRunExecution("Go", "", &start, &end)
+    ObserveJudgeRunExecution("go", "v2", &start, nil) // 未结束不记录
+    IncJudgeVerdict("p1", "accepted")
+    IncJudgeVerdict("p1", "accepted")
+
+    requireLines(t, scrapeBody(t),
+        "codyssey_judge_queue_wait_seconds_count 1",
+        `codyssey_judge_run_execution_seconds_count{judge_version="unknown",language="go"} 1`,
+        `codyssey_judge_verdicts_total{problem_id="p1",verdict="accepted"} 2`,
+    )
+}
+
+func TestLabelLimiter(t *testing.T) {
+    l := newLabelLimiter(2)
+    if got := l.value(" Python3 "); got != "python3" { t.Fatalf("got %q", got) }
+    l.value("go")
+    if got := l.value("rust"); got != "other" { t.Fatalf("expected overflow to other, got %q", got) }
+    if got := l.value("GO"); got != "go" { t.Fatalf("known value must pass, got %q", got) }
+    if got := l.value(""); got != "unknown" { t.Fatalf("got %q", got) }
+}
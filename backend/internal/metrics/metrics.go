@@ -1,9 +1,11 @@
 package metrics
 
 import (
+	"sync"
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/prometheus/client_golang/prometheus"
 	promhttp "github.com/prometheus/client_golang/prometheus/promhttp"
 )
@@ -21,6 +23,33 @@ var (
     judgeRunDuration *prometheus.HistogramVec
     submissionConflicts prometheus.Counter
     judgeRunConflicts prometheus.Counter
+
+    loginLockouts *prometheus.CounterVec
+    loginBlocked *prometheus.CounterVec
+    loginUnlocks *prometheus.CounterVec
+
+    rateLimited *prometheus.CounterVec
+    rateLimitStoreErrors prometheus.Counter
+
+    sseConnections prometheus.Gauge
+    sseDropped prometheus.Counter
+
+    wsConnections prometheus.Gauge
+    wsTopicSubscribers *prometheus.GaugeVec
+    wsDropped *prometheus.CounterVec
+
+    dbQueryDuration *prometheus.HistogramVec
+    dbQueryErrors *prometheus.CounterVec
+    dbSlowQueries *prometheus.CounterVec
+    dbPool *dbPoolCollector
+
+    judgeQueueWait prometheus.Histogram
+    judgeRunExecution *prometheus.HistogramVec
+    judgeVerdicts *prometheus.CounterVec
+    judgeQueue *judgeQueueCollector
+
+    aiRequests *prometheus.CounterVec
+    aiRequestDuration *prometheus.HistogramVec
 )
 
 // Init initializes the metrics registry and registers collectors. Safe to call once.
@@ -79,6 +108,109 @@ func Init() {
         Help:      "Total number of judge run status update conflicts (optimistic lock).",
     })
 
+    loginLockouts = prometheus.NewCounterVec(prometheus.CounterOpts{
+        Namespace: "codyssey",
+        Name:      "auth_login_lockouts_total",
+        Help:      "Count of temporary login lockouts by scope (username|ip).",
+    }, []string{"scope"})
+    loginBlocked = prometheus.NewCounterVec(prometheus.CounterOpts{
+        Namespace: "codyssey",
+        Name:      "auth_login_blocked_total",
+        Help:      "Count of login attempts rejected before credential check by scope and reason (locked|throttled).",
+    }, []string{"scope", "reason"})
+    loginUnlocks = prometheus.NewCounterVec(prometheus.CounterOpts{
+        Namespace: "codyssey",
+        Name:      "auth_login_unlocks_total",
+        Help:      "Count of manual lockout removals by administrators.",
+    }, []string{"scope"})
+
+    rateLimited = prometheus.NewCounterVec(prometheus.CounterOpts{
+        Namespace: "codyssey",
+        Name:      "http_rate_limited_total",
+        Help:      "Count of requests rejected by the rate limiter, by policy group.",
+    }, []string{"group"})
+    rateLimitStoreErrors = prometheus.NewCounter(prometheus.CounterOpts{
+        Namespace: "codyssey",
+        Name:      "http_rate_limit_store_errors_total",
+        Help:      "Count of rate limit store failures (requests are allowed through).",
+    })
+
+    sseConnections = prometheus.NewGauge(prometheus.GaugeOpts{
+        Namespace: "codyssey",
+        Name:      "sse_connections",
+        Help:      "Current number of open server-sent event streams.",
+    })
+    sseDropped = prometheus.NewCounter(prometheus.CounterOpts{
+        Namespace: "codyssey",
+        Name:      "sse_subscribers_dropped_total",
+        Help:      "Count of event stream subscribers disconnected for falling behind.",
+    })
+
+    wsConnections = prometheus.NewGauge(prometheus.GaugeOpts{
+        Namespace: "codyssey",
+        Name:      "ws_connections",
+        Help:      "Current number of open realtime WebSocket connections.",
+    })
+    wsTopicSubscribers = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+        Namespace: "codyssey",
+        Name:      "ws_topic_subscribers",
+        Help:      "Current realtime topic subscriptions by topic kind (announcements|scoreboard|clarifications|verdicts).",
+    }, []string{"kind"})
+    wsDropped = prometheus.NewCounterVec(prometheus.CounterOpts{
+        Namespace: "codyssey",
+        Name:      "ws_messages_dropped_total",
+        Help:      "Count of realtime frames not delivered to slow clients, by reason (coalesced|overflow).",
+    }, []string{"reason"})
+
+    dbQueryDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+        Namespace: "codyssey",
+        Name:      "db_query_duration_seconds",
+        Help:      "Histogram of database query durations in seconds, by operation.",
+        Buckets:   []float64{0.0005, 0.001, 0.0025, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5},
+    }, []string{"operation"})
+    dbQueryErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+        Namespace: "codyssey",
+        Name:      "db_query_errors_total",
+        Help:      "Count of database queries that returned an error, by operation.",
+    }, []string{"operation"})
+    dbSlowQueries = prometheus.NewCounterVec(prometheus.CounterOpts{
+        Namespace: "codyssey",
+        Name:      "db_slow_queries_total",
+        Help:      "Count of database queries exceeding the slow query threshold, by operation.",
+    }, []string{"operation"})
+    dbPool = newDBPoolCollector()
+
+    judgeQueueWait = prometheus.NewHistogram(prometheus.HistogramOpts{
+        Namespace: "codyssey",
+        Name:      "judge_queue_wait_seconds",
+        Help:      "Histogram of time judge runs spend queued before starting (queued->running).",
+        Buckets:   []float64{0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60, 120, 300, 600},
+    })
+    judgeRunExecution = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+        Namespace: "codyssey",
+        Name:      "judge_run_execution_seconds",
+        Help:      "Histogram of judge run execution (start->finish) durations by submission language and judge version.",
+        Buckets:   []float64{0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 20},
+    }, []string{"language", "judge_version"})
+    judgeVerdicts = prometheus.NewCounterVec(prometheus.CounterOpts{
+        Namespace: "codyssey",
+        Name:      "judge_verdicts_total",
+        Help:      "Count of final submission verdicts by problem and verdict.",
+    }, []string{"problem_id", "verdict"})
+    judgeQueue = newJudgeQueueCollector()
+
+    aiRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+        Namespace: "codyssey",
+        Name:      "ai_requests_total",
+        Help:      "Count of AI service request attempts by endpoint and outcome (ok|error|bad_response|circuit_open).",
+    }, []string{"endpoint", "outcome"})
+    aiRequestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+        Namespace: "codyssey",
+        Name:      "ai_request_duration_seconds",
+        Help:      "Histogram of AI service request attempt durations in seconds, by endpoint.",
+        Buckets:   []float64{0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 20, 30, 60},
+    }, []string{"endpoint"})
+
     _ = reg.Register(httpRequestsTotal)
     _ = reg.Register(httpRequestDuration)
     _ = reg.Register(httpInFlight)
@@ -87,6 +219,26 @@ func Init() {
     _ = reg.Register(judgeRunDuration)
     _ = reg.Register(submissionConflicts)
     _ = reg.Register(judgeRunConflicts)
+    _ = reg.Register(loginLockouts)
+    _ = reg.Register(loginBlocked)
+    _ = reg.Register(loginUnlocks)
+    _ = reg.Register(rateLimited)
+    _ = reg.Register(rateLimitStoreErrors)
+    _ = reg.Register(sseConnections)
+    _ = reg.Register(sseDropped)
+    _ = reg.Register(wsConnections)
+    _ = reg.Register(wsTopicSubscribers)
+    _ = reg.Register(wsDropped)
+    _ = reg.Register(dbQueryDuration)
+    _ = reg.Register(dbQueryErrors)
+    _ = reg.Register(dbSlowQueries)
+    _ = reg.Register(dbPool)
+    _ = reg.Register(judgeQueueWait)
+    _ = reg.Register(judgeRunExecution)
+    _ = reg.Register(judgeVerdicts)
+    _ = reg.Register(judgeQueue)
+    _ = reg.Register(aiRequests)
+    _ = reg.Register(aiRequestDuration)
 }
 
 // Middleware instruments HTTP requests. Should be added high in the chain after recovery & trace.
@@ -142,6 +294,111 @@ func IncSubmissionConflict() { if submissionConflicts != nil { submissionConflic
 // IncJudgeRunConflict increments judge run conflict counter.
 func IncJudgeRunConflict() { if judgeRunConflicts != nil { judgeRunConflicts.Inc() } }
 
+// IncLoginLockout increments the lockout counter for the given scope.
+func IncLoginLockout(scope string) { if loginLockouts != nil { loginLockouts.WithLabelValues(scope).Inc() } }
+
+// IncLoginBlocked counts a login rejected due to lockout or progressive delay.
+func IncLoginBlocked(scope, reason string) { if loginBlocked != nil { loginBlocked.WithLabelValues(scope, reason).Inc() } }
+
+// IncLoginUnlock counts an administrator unlock.
+func IncLoginUnlock(scope string) { if loginUnlocks != nil { loginUnlocks.WithLabelValues(scope).Inc() } }
+
+// IncRateLimited counts a request rejected with 429 by the given policy group.
+func IncRateLimited(group string) { if rateLimited != nil { rateLimited.WithLabelValues(group).Inc() } }
+
+// IncRateLimitStoreError counts a limiter store failure (fail-open).
+func IncRateLimitStoreError() { if rateLimitStoreErrors != nil { rateLimitStoreErrors.Inc() } }
+
+// SSEConnected tracks an open event stream; call the returned func when it closes.
+func SSEConnected() func() {
+    if sseConnections == nil { return func() {} }
+    sseConnections.Inc()
+    return sseConnections.Dec
+}
+
+// IncSSEDropped counts a slow subscriber removed by the event hub.
+func IncSSEDropped() { if sseDropped != nil { sseDropped.Inc() } }
+
+// WSConnected tracks an open WebSocket connection; call the returned func when it closes.
+func WSConnected() func() {
+    if wsConnections == nil { return func() {} }
+    wsConnections.Inc()
+    return wsConnections.Dec
+}
+
+// WSSubscribed / WSUnsubscribed track topic subscriptions by topic kind.
+func WSSubscribed(kind string) { if wsTopicSubscribers != nil { wsTopicSubscribers.WithLabelValues(kind).Inc() } }
+func WSUnsubscribed(kind string) { if wsTopicSubscribers != nil { wsTopicSubscribers.WithLabelValues(kind).Dec() } }
+
+// IncWSDropped counts a frame replaced by a newer snapshot (coalesced) or lost when a slow client was disconnected (overflow).
+func IncWSDropped(reason string) { if wsDropped != nil { wsDropped.WithLabelValues(reason).Inc() } }
+
+// ObserveDBQuery records a query duration and counts it as an error when err is non-nil.
+func ObserveDBQuery(operation string, d time.Duration, err error) {
+    if dbQueryDuration == nil { return }
+    dbQueryDuration.WithLabelValues(operation).Observe(d.Seconds())
+    if err != nil { dbQueryErrors.WithLabelValues(operation).Inc() }
+}
+
+// IncDBSlowQuery counts a query above the slow query threshold.
+func IncDBSlowQuery(operation string) { if dbSlowQueries != nil { dbSlowQueries.WithLabelValues(operation).Inc() } }
+
+// ObserveAIRequest records one AI service request attempt; attempts rejected by the circuit breaker are counted without duration.
+func ObserveAIRequest(endpoint, outcome string, d time.Duration) {
+    if aiRequests == nil { return }
+    aiRequests.WithLabelValues(endpoint, outcome).Inc()
+    if outcome != "circuit_open" { aiRequestDuration.WithLabelValues(endpoint).Observe(d.Seconds()) }
+}
+
+// RegisterDBPool exposes connection pool statistics, read on each scrape.
+func RegisterDBPool(pool *pgxpool.Pool) {
+    Init()
+    if pool == nil { return }
+    dbPool.set(pool.Stat)
+}
+
+// dbPoolCollector reads pgxpool.Stat at collection time; emits nothing until a pool is registered.
+type dbPoolCollector struct {
+    mu   sync.Mutex
+    stat func() *pgxpool.Stat
+
+    acquired, idle, total, max, acquires, emptyAcquires, waitSeconds *prometheus.Desc
+}
+
+func newDBPoolCollector() *dbPoolCollector {
+    d := func(name, help string) *prometheus.Desc { return prometheus.NewDesc("codyssey_db_pool_"+name, help, nil, nil) }
+    return &dbPoolCollector{
+        acquired:      d("acquired_conns", "Number of connections currently checked out of the pool."),
+        idle:          d("idle_conns", "Number of idle connections in the pool."),
+        total:         d("total_conns", "Total number of connections in the pool (acquired, idle and constructing)."),
+        max:           d("max_conns", "Maximum size of the pool."),
+        acquires:      d("acquires_total", "Cumulative count of successful connection acquires."),
+        emptyAcquires: d("empty_acquires_total", "Cumulative count of acquires that had to wait because the pool was empty."),
+        waitSeconds:   d("acquire_wait_seconds_total", "Cumulative time spent waiting for a connection from an empty pool."),
+    }
+}
+
+func (c *dbPoolCollector) set(stat func() *pgxpool.Stat) { c.mu.Lock(); c.stat = stat; c.mu.Unlock() }
+
+func (c *dbPoolCollector) Describe(ch chan<- *prometheus.Desc) {
+    for _, d := range []*prometheus.Desc{c.acquired, c.idle, c.total, c.max, c.acquires, c.emptyAcquires, c.waitSeconds} { ch <- d }
+}
+
+func (c *dbPoolCollector) Collect(ch chan<- prometheus.Metric) {
+    c.mu.Lock()
+    stat := c.stat
+    c.mu.Unlock()
+    if stat == nil { return }
+    s := stat()
+    ch <- prometheus.MustNewConstMetric(c.acquired, prometheus.GaugeValue, float64(s.AcquiredConns()))
+    ch <- prometheus.MustNewConstMetric(c.idle, prometheus.GaugeValue, float64(s.IdleConns()))
+    ch <- prometheus.MustNewConstMetric(c.total, prometheus.GaugeValue, float64(s.TotalConns()))
+    ch <- prometheus.MustNewConstMetric(c.max, prometheus.GaugeValue, float64(s.MaxConns()))
+    ch <- prometheus.MustNewConstMetric(c.acquires, prometheus.CounterValue, float64(s.AcquireCount()))
+    ch <- prometheus.MustNewConstMetric(c.emptyAcquires, prometheus.CounterValue, float64(s.EmptyAcquireCount()))
+    ch <- prometheus.MustNewConstMetric(c.waitSeconds, prometheus.CounterValue, s.EmptyAcquireWaitTime().Seconds())
+}
+
 // intToStr – small helper without importing strconv repeatedly.
 func intToStr(i int) string {
     // hand-written fast path for common statuses; fallback minimal alloc.
@@ -0,0 +1,113 @@
+// Package ratelimit 令牌桶限流核心：策略、决策与存储抽象；HTTP 中间件见 internal/http/middleware。
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 限流策略分组（按路由组配置）
+const (
+    GroupLogin         = "login"          // 登录 / 注册 / MFA / 找回密码等未认证入口
+    GroupSubmission    = "submission"     // 创建提交
+    GroupJudgeEnqueue  = "judge_enqueue"  // 手动触发判题
+)
+
+// Policy 令牌桶：容量 Burst，每 Per 时间补充 Burst 个令牌（匀速）。
+type Policy struct {
+    Group string
+    Burst int
+    Per   time.Duration
+    ByIP  bool // true 时总是按来源 IP 计数（忽略登录身份）
+}
+
+func (p Policy) Enabled() bool { return p.Burst > 0 && p.Per > 0 }
+
+// refillRate 每秒补充的令牌数
+func (p Policy) refillRate() float64 { return float64(p.Burst) / p.Per.Seconds() }
+
+// ParsePolicy 解析 "N/duration"（如 "10/1m"）；"off" 或 "0" 表示禁用。
+func ParsePolicy(group, spec string) (Policy, error) {
+    p := Policy{Group: group}
+    spec = strings.TrimSpace(strings.ToLower(spec))
+    if spec == "" || spec == "off" || spec == "0" { return p, nil }
+    n, per, ok := strings.Cut(spec, "/")
+    if !ok { return p, fmt.Errorf("rate limit %q: expected N/duration", spec) }
+    burst, err := strconv.Atoi(strings.TrimSpace(n))
+    if err != nil || burst < 0 { return p, fmt.Errorf("rate limit %q: invalid count", spec) }
+    d, err := time.ParseDuration(strings.TrimSpace(per))
+    if err != nil || d <= 0 { return p, fmt.Errorf("rate limit %q: invalid duration", spec) }
+    p.Burst, p.Per = burst, d
+    return p, nil
+}
+
+// Decision Remaining 为本次扣减后的剩余令牌；RetryAfter 为下一个令牌到达前的等待时间（仅拒绝时有意义）；
+// Reset 为令牌桶恢复满额所需时间。
+type Decision struct {
+    Allowed    bool
+    Remaining  int
+    RetryAfter time.Duration
+    Reset      time.Duration
+}
+
+// Store 令牌桶存储；Take 必须原子地完成“补充 + 扣减”。
+type Store interface {
+    Take(ctx context.Context, key string, p Policy, now time.Time) (Decision, error)
+}
+
+// Decide 依据补充后的令牌数给出决策（内存与 Postgres 实现共用）。
+// tokens 为补充后、扣减前的令牌数。
+func Decide(tokens float64, allowed bool, p Policy) Decision {
+    rate := p.refillRate()
+    d := Decision{Allowed: allowed}
+    left := tokens
+    if allowed { left = tokens - 1 }
+    d.Remaining = int(math.Floor(left))
+    if d.Remaining < 0 { d.Remaining = 0 }
+    d.Reset = time.Duration((float64(p.Burst) - left) / rate * float64(time.Second))
+    if !allowed { d.RetryAfter = time.Duration((1 - tokens) / rate * float64(time.Second)) }
+    return d
+}
+
+type memoryBucket struct {
+    tokens float64
+    last   time.Time
+    full   time.Time // 预计恢复满额的时间，用于清理
+}
+
+// MemoryStore 单实例内存实现；多副本部署请使用 Postgres 实现。
+type MemoryStore struct {
+    mu        sync.Mutex
+    buckets   map[string]*memoryBucket
+    lastSweep time.Time
+}
+
+func NewMemoryStore() *MemoryStore {
+    return &MemoryStore{buckets: map[string]*memoryBucket{}}
+}
+
+func (s *MemoryStore) Take(_ context.Context, key string, p Policy, now time.Time) (Decision, error) {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+    // 已恢复满额的桶与新桶等价，定期清理避免 key 无限增长
+    if now.Sub(s.lastSweep) > time.Minute {
+        for k, b := range s.buckets { if !now.Before(b.full) { delete(s.buckets, k) } }
+        s.lastSweep = now
+    }
+    b, ok := s.buckets[key]
+    if !ok {
+        b = &memoryBucket{tokens: float64(p.Burst), last: now}
+        s.buckets[key] = b
+    }
+    tokens := math.Min(float64(p.Burst), b.tokens+now.Sub(b.last).Seconds()*p.refillRate())
+    allowed := tokens >= 1
+    d := Decide(tokens, allowed, p)
+    if allowed { tokens-- }
+    b.tokens, b.last, b.full = tokens, now, now.Add(d.Reset)
+    return d, nil
+}
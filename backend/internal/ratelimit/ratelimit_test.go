@@ -0,0 +1,103 @@
+package ratelimit_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/require"
+
+	"github.com/YangYuS8/codyssey/backend/internal/auth"
+	"github.com/YangYuS8/codyssey/backend/internal/http/middleware"
+	"github.com/YangYuS8/codyssey/backend/internal/ratelimit"
+)
+
+func TestParsePolicy(t *testing.T) {
+    p, err := ratelimit.ParsePolicy("login", "10/1m")
+    require.NoError(t, err)
+    require.Equal(t, 10, p.Burst)
+    require.Equal(t, time.Minute, p.Per)
+    require.True(t, p.Enabled())
+
+    p, err = ratelimit.ParsePolicy("login", "off")
+    require.NoError(t, err)
+    require.False(t, p.Enabled())
+
+    for _, bad := range []string{"10", "x/1m", "10/soon", "10/-1s"} {
+        _, err := ratelimit.ParsePolicy("login", bad)
+        require.Error(t, err, bad)
+    }
+}
+
+func TestMemoryStore_TokenBucket(t *testing.T) {
+    ctx := context.Background()
+    s := ratelimit.NewMemoryStore()
+    p := ratelimit.Policy{Group: "g", Burst: 3, Per: 3 * time.Second} // 每秒补充 1 个
+    now := time.Unix(1_700_000_000, 0)
+
+    for i := 2; i >= 0; i-- {
+        d, err := s.Take(ctx, "k", p, now)
+        require.NoError(t, err)
+        require.True(t, d.Allowed)
+        require.Equal(t, i, d.Remaining)
+    }
+    d, _ := s.Take(ctx, "k", p, now)
+    require.False(t, d.Allowed)
+    require.Equal(t, time.Second, d.RetryAfter)
+    require.Equal(t, 3*time.Second, d.Reset)
+
+    // 其他 key 互不影响
+    d, _ = s.Take(ctx, "other", p, now)
+    require.True(t, d.Allowed)
+
+    d, _ = s.Take(ctx, "k", p, now.Add(1500*time.Millisecond))
+    require.True(t, d.Allowed, "1.5s 后补充 1.5 个令牌")
+    require.Equal(t, 0, d.Remaining)
+    d, _ = s.Take(ctx, "k", p, now.Add(1500*time.Millisecond))
+    require.False(t, d.Allowed)
+    require.Equal(t, 500*time.Millisecond, d.RetryAfter)
+
+    d, _ = s.Take(ctx, "k", p, now.Add(time.Hour))
+    require.True(t, d.Allowed)
+    require.Equal(t, 2, d.Remaining, "补充不超过容量")
+}
+
+func TestRateLimitMiddleware(t *testing.T) {
+    gin.SetMode(gin.TestMode)
+    store := ratelimit.NewMemoryStore()
+    r := gin.New()
+    r.Use(func(c *gin.Context) {
+        if uid := c.GetHeader("X-Test-User"); uid != "" { c.Set("__identity", &auth.Identity{UserID: uid}) }
+        c.Next()
+    })
+    r.POST("/submissions", middleware.RateLimit(store, ratelimit.Policy{Group: ratelimit.GroupSubmission, Burst: 2, Per: time.Minute}), func(c *gin.Context) { c.Status(http.StatusCreated) })
+    r.POST("/off", middleware.RateLimit(store, ratelimit.Policy{Group: "off"}), func(c *gin.Context) { c.Status(http.StatusOK) })
+
+    do := func(path, user string) *httptest.ResponseRecorder {
+        w := httptest.NewRecorder()
+        req, _ := http.NewRequest(http.MethodPost, path, nil)
+        req.RemoteAddr = "10.0.0.1:1234"
+        if user != "" { req.Header.Set("X-Test-User", user) }
+        r.ServeHTTP(w, req)
+        return w
+    }
+
+    require.Equal(t, http.StatusCreated, do("/submissions", "u1").Code)
+    w := do("/submissions", "u1")
+    require.Equal(t, http.StatusCreated, w.Code)
+    require.Equal(t, "2", w.Header().Get("RateLimit-Limit"))
+    require.Equal(t, "0", w.Header().Get("RateLimit-Remaining"))
+    w = do("/submissions", "u1")
+    require.Equal(t, http.StatusTooManyRequests, w.Code)
+    require.Equal(t, "30", w.Header().Get("Retry-After"))
+    require.Contains(t, w.Body.String(), "RATE_LIMITED")
+
+    // 同一 IP 下的另一用户与匿名请求各自计数
+    require.Equal(t, http.StatusCreated, do("/submissions", "u2").Code)
+    require.Equal(t, http.StatusCreated, do("/submissions", "").Code)
+
+    for i := 0; i < 5; i++ { require.Equal(t, http.StatusOK, do("/off", "").Code) }
+}
@@ -0,0 +1,235 @@
+package realtime
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/YangYuS8/codyssey/backend/internal/events"
+	"github.com/YangYuS8/codyssey/backend/internal/metrics"
+)
+
+// Frame 服务端下发的单个 JSON 文本帧。
+type Frame struct {
+    Type    string   `json:"type"`            // message / subscribed / unsubscribed / error / ping / pong
+    Topic   string   `json:"topic,omitempty"`
+    Event   string   `json:"event,omitempty"` // 业务事件名，如 scoreboard / clarification / verdict / announcement
+    Data    any      `json:"data,omitempty"`
+    Topics  []string `json:"topics,omitempty"`
+    Code    string   `json:"code,omitempty"`
+    Message string   `json:"message,omitempty"`
+    TS      int64    `json:"ts,omitempty"` // Unix 毫秒
+}
+
+// clientQueueLimit 单连接待发送帧上限；超过即视为慢客户端并断开
+const clientQueueLimit = 128
+
+// Client 一个 WebSocket 连接的发送队列与订阅集合。
+type Client struct {
+    mu       sync.Mutex
+    queue    []Frame
+    topics   map[string]Topic
+    notify   chan struct{}
+    closed   chan struct{}
+    once     sync.Once
+}
+
+func newClient() *Client {
+    return &Client{topics: map[string]Topic{}, notify: make(chan struct{}, 1), closed: make(chan struct{})}
+}
+
+func (c *Client) Close() { c.once.Do(func() { close(c.closed) }) }
+
+// enqueue 榜单消息替换队列中同主题未发送的旧快照；队列满则断开。
+func (c *Client) enqueue(f Frame, coalesce bool) {
+    c.mu.Lock()
+    if coalesce {
+        for i := range c.queue {
+            if c.queue[i].Type == "message" && c.queue[i].Topic == f.Topic {
+                c.queue[i] = f
+                c.mu.Unlock()
+                metrics.IncWSDropped("coalesced")
+                return
+            }
+        }
+    }
+    if len(c.queue) >= clientQueueLimit {
+        c.mu.Unlock()
+        metrics.IncWSDropped("overflow")
+        c.Close()
+        return
+    }
+    c.queue = append(c.queue, f)
+    c.mu.Unlock()
+    select {
+    case c.notify <- struct{}{}:
+    default:
+    }
+}
+
+func (c *Client) drain() []Frame {
+    c.mu.Lock()
+    out := c.queue
+    c.queue = nil
+    c.mu.Unlock()
+    return out
+}
+
+// Broker 主题 -> 订阅连接。配置 events.Hub 后发布经 Hub（LISTEN/NOTIFY）扇出到所有实例。
+type Broker struct {
+    mu      sync.RWMutex
+    topics  map[string]map[*Client]struct{}
+    clients map[*Client]struct{}
+    hub     *events.Hub
+}
+
+func NewBroker() *Broker {
+    return &Broker{topics: map[string]map[*Client]struct{}{}, clients: map[*Client]struct{}{}}
+}
+
+// MaxMessageBytes 手动发布消息（event 与 data 序列化后）的上限：多实例经 Postgres NOTIFY 扇出，
+// 其载荷不得超过 8000 字节，余量留给事件 ID、主题等字段。
+const MaxMessageBytes = 7000
+
+// CheckMessage 消息超过 MaxMessageBytes 时返回 ErrMessageTooLarge（NOTIFY 会拒绝，其他实例收不到）。
+func CheckMessage(event string, data any) error {
+    raw, err := json.Marshal(map[string]any{"event": event, "data": data})
+    if err != nil { return err }
+    if len(raw) > MaxMessageBytes { return ErrMessageTooLarge }
+    return nil
+}
+
+// Publish 向主题发布一条业务事件。
+func (b *Broker) Publish(ctx context.Context, t Topic, event string, data any) {
+    if b.hub != nil {
+        b.hub.Publish(ctx, events.Event{ID: events.IDAt(time.Now()), Type: events.TypeTopicMessage, Topic: t.Name,
+            Payload: map[string]any{"event": event, "data": data}})
+        return
+    }
+    b.deliver(t, event, data)
+}
+
+func (b *Broker) deliver(t Topic, event string, data any) {
+    f := Frame{Type: "message", Topic: t.Name, Event: event, Data: data, TS: time.Now().UnixMilli()}
+    b.mu.RLock()
+    subs := make([]*Client, 0, len(b.topics[t.Name]))
+    for c := range b.topics[t.Name] { subs = append(subs, c) }
+    b.mu.RUnlock()
+    for _, c := range subs { c.enqueue(f, t.coalesces()) }
+}
+
+// UseHub 发布改走 Hub，并把 Hub 上的事件转为主题消息：
+// topic_message 投递到对应主题；提交进入终态（completed）投递到提交者的 verdicts 主题。
+func (b *Broker) UseHub(ctx context.Context, hub *events.Hub) {
+    b.hub = hub
+    go func() {
+        for ctx.Err() == nil {
+            sub := hub.Subscribe(func(ev events.Event) bool { return ev.Type == events.TypeTopicMessage || ev.Type == events.TypeCompleted })
+            b.bridge(ctx, sub)
+            sub.Close()
+        }
+    }()
+}
+
+func (b *Broker) bridge(ctx context.Context, sub *events.Subscription) {
+    for {
+        select {
+        case <-ctx.Done():
+            return
+        case <-sub.Done():
+            return // 被 Hub 丢弃或 Hub 关闭，由调用方重新订阅
+        case ev := <-sub.C():
+            b.fromEvent(ev)
+        }
+    }
+}
+
+func (b *Broker) fromEvent(ev events.Event) {
+    switch ev.Type {
+    case events.TypeCompleted:
+        if ev.UserID == "" { return }
+        t, err := ParseTopic(VerdictTopic(ev.UserID))
+        if err != nil { return }
+        var p struct{ Status string `json:"status"` }
+        if raw, err := json.Marshal(ev.Payload); err == nil { _ = json.Unmarshal(raw, &p) }
+        b.deliver(t, "verdict", map[string]any{"submissionId": ev.SubmissionID, "status": p.Status})
+    case events.TypeTopicMessage:
+        t, err := ParseTopic(ev.Topic)
+        if err != nil { return }
+        // 本地发布为 map，经 NOTIFY 回送为 json.RawMessage：统一序列化后解析
+        var p struct {
+            Event string          `json:"event"`
+            Data  json.RawMessage `json:"data"`
+        }
+        raw, err := json.Marshal(ev.Payload)
+        if err != nil || json.Unmarshal(raw, &p) != nil { return }
+        var data any
+        if len(p.Data) > 0 && string(p.Data) != "null" { data = p.Data }
+        b.deliver(t, p.Event, data)
+    }
+}
+
+func (b *Broker) register(c *Client) {
+    b.mu.Lock()
+    b.clients[c] = struct{}{}
+    b.mu.Unlock()
+}
+
+func (b *Broker) subscribe(c *Client, t Topic) bool {
+    b.mu.Lock()
+    defer b.mu.Unlock()
+    c.mu.Lock()
+    defer c.mu.Unlock()
+    if _, ok := c.topics[t.Name]; ok { return false }
+    c.topics[t.Name] = t
+    set := b.topics[t.Name]
+    if set == nil { set = map[*Client]struct{}{}; b.topics[t.Name] = set }
+    set[c] = struct{}{}
+    metrics.WSSubscribed(t.Kind)
+    return true
+}
+
+func (b *Broker) unsubscribe(c *Client, name string) {
+    b.mu.Lock()
+    defer b.mu.Unlock()
+    c.mu.Lock()
+    defer c.mu.Unlock()
+    b.unsubscribeLocked(c, name)
+}
+
+func (b *Broker) unsubscribeLocked(c *Client, name string) {
+    t, ok := c.topics[name]
+    if !ok { return }
+    delete(c.topics, name)
+    if set := b.topics[name]; set != nil {
+        delete(set, c)
+        if len(set) == 0 { delete(b.topics, name) }
+    }
+    metrics.WSUnsubscribed(t.Kind)
+}
+
+func (b *Broker) remove(c *Client) {
+    b.mu.Lock()
+    c.mu.Lock()
+    for name := range c.topics { b.unsubscribeLocked(c, name) }
+    c.mu.Unlock()
+    delete(b.clients, c)
+    b.mu.Unlock()
+    c.Close()
+}
+
+// Subscribers 主题当前订阅连接数。
+func (b *Broker) Subscribers(topic string) int {
+    b.mu.RLock(); defer b.mu.RUnlock()
+    return len(b.topics[topic])
+}
+
+// Close 断开全部连接（服务停机时调用）。
+func (b *Broker) Close() {
+    b.mu.RLock()
+    all := make([]*Client, 0, len(b.clients))
+    for c := range b.clients { all = append(all, c) }
+    b.mu.RUnlock()
+    for _, c := range all { c.Close() }
+}
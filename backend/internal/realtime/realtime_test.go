@@ -0,0 +1,104 @@
+package realtime
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"golang.org/x/net/websocket"
+
+	"github.com/YangYuS8/codyssey/backend/internal/events"
+)
+
+func TestParseTopic(t *testing.T) {
+    for _, ok := range []string{"announcements", "contest:c1:scoreboard", "contest:spring-2025:clarifications", "user:u_1:verdicts"} {
+        _, err := ParseTopic(ok)
+        require.NoError(t, err, ok)
+    }
+    for _, bad := range []string{"", "contest:c1", "contest:c1:verdicts", "user:u1:scoreboard", "contest:a b:scoreboard", "x:c1:scoreboard"} {
+        _, err := ParseTopic(bad)
+        require.ErrorIs(t, err, ErrInvalidTopic, bad)
+    }
+}
+
+func TestClientCoalescesScoreboardAndDropsOnOverflow(t *testing.T) {
+    c := newClient()
+    c.enqueue(Frame{Type: "message", Topic: "contest:c1:scoreboard", Data: 1}, true)
+    c.enqueue(Frame{Type: "message", Topic: "contest:c1:clarifications", Data: "q"}, false)
+    c.enqueue(Frame{Type: "message", Topic: "contest:c1:scoreboard", Data: 2}, true)
+    got := c.drain()
+    require.Len(t, got, 2)
+    require.Equal(t, 2, got[0].Data)
+
+    for i := 0; i <= clientQueueLimit; i++ { c.enqueue(Frame{Type: "message", Topic: "announcements"}, false) }
+    select {
+    case <-c.closed:
+    default:
+        t.Fatal("overflowing client should be closed")
+    }
+}
+
+func dial(t *testing.T, b *Broker, authorize func(Topic) error) *websocket.Conn {
+    t.Helper()
+    srv := httptest.NewServer(websocket.Server{
+        Handshake: func(*websocket.Config, *http.Request) error { return nil },
+        Handler:   func(ws *websocket.Conn) { b.Serve(context.Background(), ws, authorize) },
+    })
+    t.Cleanup(srv.Close)
+    ws, err := websocket.Dial("ws"+strings.TrimPrefix(srv.URL, "http"), "", srv.URL)
+    require.NoError(t, err)
+    t.Cleanup(func() { ws.Close() })
+    return ws
+}
+
+func recv(t *testing.T, ws *websocket.Conn) Frame {
+    t.Helper()
+    _ = ws.SetReadDeadline(time.Now().Add(2 * time.Second))
+    var f Frame
+    require.NoError(t, websocket.JSON.Receive(ws, &f))
+    return f
+}
+
+func TestServeSubscribePublishAndVerdictBridge(t *testing.T) {
+    hub := events.NewHub()
+    b := NewBroker()
+    ctx, cancel := context.WithCancel(context.Background())
+    t.Cleanup(cancel)
+    b.UseHub(ctx, hub)
+    ws := dial(t, b, func(tp Topic) error {
+        if tp.Kind == KindVerdicts && tp.ID != "u1" { return ErrTopicForbidden }
+        return nil
+    })
+
+    require.NoError(t, websocket.JSON.Send(ws, map[string]any{"op": "subscribe", "topics": []string{"contest:c1:scoreboard", "user:u1:verdicts", "user:u2:verdicts", "bogus"}}))
+    var errs []string
+    var subscribed Frame
+    for subscribed.Type != "subscribed" {
+        f := recv(t, ws)
+        if f.Type == "error" { errs = append(errs, f.Code) } else { subscribed = f }
+    }
+    require.ElementsMatch(t, []string{"FORBIDDEN", "INVALID_TOPIC"}, errs)
+    require.Equal(t, []string{"contest:c1:scoreboard", "user:u1:verdicts"}, subscribed.Topics)
+
+    tp, _ := ParseTopic("contest:c1:scoreboard")
+    b.Publish(ctx, tp, "scoreboard", map[string]any{"rows": []int{1}})
+    f := recv(t, ws)
+    require.Equal(t, "message", f.Type)
+    require.Equal(t, "scoreboard", f.Event)
+    require.Equal(t, "contest:c1:scoreboard", f.Topic)
+
+    hub.Publish(ctx, events.Event{ID: "1", Type: events.TypeCompleted, SubmissionID: "s1", UserID: "u1", Payload: map[string]any{"status": "accepted"}})
+    f = recv(t, ws)
+    require.Equal(t, "verdict", f.Event)
+    require.Equal(t, map[string]any{"submissionId": "s1", "status": "accepted"}, f.Data)
+
+    require.NoError(t, websocket.JSON.Send(ws, map[string]any{"op": "ping"}))
+    require.Equal(t, "pong", recv(t, ws).Type)
+
+    ws.Close()
+    require.Eventually(t, func() bool { return b.Subscribers("contest:c1:scoreboard") == 0 }, time.Second, 10*time.Millisecond)
+}
@@ -0,0 +1,113 @@
+package realtime
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"golang.org/x/net/websocket"
+
+	"github.com/YangYuS8/codyssey/backend/internal/metrics"
+)
+
+// 连接参数（测试可调小 Heartbeat）
+var Heartbeat = 25 * time.Second
+
+const (
+    maxTopicsPerConn = 32
+    maxClientFrame   = 4 << 10
+    writeTimeout     = 10 * time.Second
+)
+
+// request 客户端上行帧：{"op":"subscribe","topics":[...]} / unsubscribe / ping / pong。
+type request struct {
+    Op     string   `json:"op"`
+    Topics []string `json:"topics"`
+}
+
+// Serve 处理一个已握手的连接直至断开。authorize 决定调用方能否订阅某主题。
+// 服务端每个 Heartbeat 发送 ping；超过两个周期未收到任何上行帧即断开。
+func (b *Broker) Serve(ctx context.Context, ws *websocket.Conn, authorize func(Topic) error) {
+    ws.MaxPayloadBytes = maxClientFrame
+    c := newClient()
+    b.register(c)
+    defer b.remove(c)
+    defer metrics.WSConnected()()
+
+    go b.readLoop(ws, c, authorize)
+
+    ticker := time.NewTicker(Heartbeat)
+    defer ticker.Stop()
+    for {
+        select {
+        case <-ctx.Done():
+            return
+        case <-c.closed:
+            return
+        case <-ticker.C:
+            c.enqueue(Frame{Type: "ping", TS: time.Now().UnixMilli()}, false)
+        case <-c.notify:
+            for _, f := range c.drain() {
+                _ = ws.SetWriteDeadline(time.Now().Add(writeTimeout))
+                if err := websocket.JSON.Send(ws, f); err != nil { c.Close(); return }
+            }
+        }
+    }
+}
+
+func (b *Broker) readLoop(ws *websocket.Conn, c *Client, authorize func(Topic) error) {
+    defer c.Close()
+    for {
+        _ = ws.SetReadDeadline(time.Now().Add(2 * Heartbeat))
+        var req request
+        if err := websocket.JSON.Receive(ws, &req); err != nil {
+            var syntaxErr *json.SyntaxError
+            var typeErr *json.UnmarshalTypeError
+            if errors.As(err, &syntaxErr) || errors.As(err, &typeErr) {
+                c.enqueue(Frame{Type: "error", Code: "BAD_REQUEST", Message: "frame must be a JSON object"}, false)
+                continue
+            }
+            return
+        }
+        switch req.Op {
+        case "subscribe":
+            b.handleSubscribe(c, req.Topics, authorize)
+        case "unsubscribe":
+            for _, name := range req.Topics { b.unsubscribe(c, name) }
+            c.enqueue(Frame{Type: "unsubscribed", Topics: req.Topics}, false)
+        case "ping":
+            c.enqueue(Frame{Type: "pong", TS: time.Now().UnixMilli()}, false)
+        case "pong":
+        default:
+            c.enqueue(Frame{Type: "error", Code: "BAD_REQUEST", Message: "unknown op"}, false)
+        }
+    }
+}
+
+func (b *Broker) handleSubscribe(c *Client, names []string, authorize func(Topic) error) {
+    ok := make([]string, 0, len(names))
+    for _, name := range names {
+        t, err := ParseTopic(name)
+        if err != nil {
+            c.enqueue(Frame{Type: "error", Topic: name, Code: "INVALID_TOPIC", Message: err.Error()}, false)
+            continue
+        }
+        if authorize != nil {
+            if err := authorize(t); err != nil {
+                c.enqueue(Frame{Type: "error", Topic: name, Code: "FORBIDDEN", Message: err.Error()}, false)
+                continue
+            }
+        }
+        c.mu.Lock()
+        full := len(c.topics) >= maxTopicsPerConn
+        c.mu.Unlock()
+        if full {
+            c.enqueue(Frame{Type: "error", Topic: name, Code: "TOO_MANY_TOPICS", Message: "topic limit per connection reached"}, false)
+            continue
+        }
+        b.subscribe(c, t)
+        ok = append(ok, name)
+    }
+    if len(ok) > 0 { c.enqueue(Frame{Type: "subscribed", Topics: ok}, false) }
+}
@@ -0,0 +1,56 @@
+// Package realtime 双向 WebSocket 通道：客户端订阅主题（比赛榜单、答疑、个人判题结果、公告），
+// 服务端按主题推送。慢客户端的榜单快照合并为最新一条，其余主题队列溢出时断开连接。
+package realtime
+
+import (
+	"errors"
+	"regexp"
+	"strings"
+)
+
+// 主题类别（亦作为指标标签）
+const (
+    KindAnnouncements  = "announcements"
+    KindScoreboard     = "scoreboard"
+    KindClarifications = "clarifications"
+    KindVerdicts       = "verdicts"
+)
+
+var (
+    ErrInvalidTopic    = errors.New("invalid topic")
+    ErrTopicForbidden  = errors.New("topic forbidden")
+    ErrMessageTooLarge = errors.New("realtime message too large")
+)
+
+// Topic 解析后的主题。格式：
+//   announcements
+//   contest:<id>:scoreboard
+//   contest:<id>:clarifications
+//   user:<id>:verdicts
+type Topic struct {
+    Name string
+    Kind string
+    ID   string // contest id 或 user id；announcements 为空
+}
+
+var topicIDPattern = regexp.MustCompile(`^[A-Za-z0-9_-]{1,64}$`)
+
+func ParseTopic(s string) (Topic, error) {
+    if s == KindAnnouncements { return Topic{Name: s, Kind: KindAnnouncements}, nil }
+    parts := strings.Split(s, ":")
+    if len(parts) != 3 || !topicIDPattern.MatchString(parts[1]) { return Topic{}, ErrInvalidTopic }
+    t := Topic{Name: s, ID: parts[1], Kind: parts[2]}
+    switch {
+    case parts[0] == "contest" && (t.Kind == KindScoreboard || t.Kind == KindClarifications):
+    case parts[0] == "user" && t.Kind == KindVerdicts:
+    default:
+        return Topic{}, ErrInvalidTopic
+    }
+    return t, nil
+}
+
+// VerdictTopic 用户个人判题结果主题。
+func VerdictTopic(userID string) string { return "user:" + userID + ":" + KindVerdicts }
+
+// coalesces 榜单为全量快照，未发送的旧快照可直接被新快照替换。
+func (t Topic) coalesces() bool { return t.Kind == KindScoreboard }
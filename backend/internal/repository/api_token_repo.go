@@ -0,0 +1,143 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/YangYuS8/codyssey/backend/internal/domain"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+var ErrAPITokenNotFound = errors.New("api token not found")
+
+// APITokenRepository API 令牌存储；按摘要查找用于认证，ListByUser 传空 userID 表示全部。
+type APITokenRepository interface {
+    Create(ctx context.Context, t domain.APIToken) error
+    GetByID(ctx context.Context, id string) (domain.APIToken, error)
+    GetByHash(ctx context.Context, hash string) (domain.APIToken, error)
+    ListByUser(ctx context.Context, userID string) ([]domain.APIToken, error)
+    Revoke(ctx context.Context, id string, at time.Time) error
+    TouchLastUsed(ctx context.Context, id string, at time.Time) error
+}
+
+// PG 实现
+type PGAPITokenRepository struct { pool *pgxpool.Pool }
+
+func NewPGAPITokenRepository(pool *pgxpool.Pool) *PGAPITokenRepository { return &PGAPITokenRepository{pool: pool} }
+
+const apiTokenColumns = `id, user_id, name, kind, prefix, token_hash, permissions, expires_at, last_used_at, revoked_at, created_at`
+
+type rowScanner interface{ Scan(dest ...any) error }
+
+func scanAPIToken(row rowScanner) (domain.APIToken, error) {
+    var t domain.APIToken
+    err := row.Scan(&t.ID, &t.UserID, &t.Name, &t.Kind, &t.Prefix, &t.Hash, &t.Permissions, &t.ExpiresAt, &t.LastUsedAt, &t.RevokedAt, &t.CreatedAt)
+    return t, err
+}
+
+func (r *PGAPITokenRepository) Create(ctx context.Context, t domain.APIToken) error {
+    if t.CreatedAt.IsZero() { t.CreatedAt = time.Now().UTC() }
+    _, err := r.pool.Exec(ctx, `INSERT INTO api_tokens (`+apiTokenColumns+`) VALUES ($1,$2,$3,$4,$5,$6,$7,$8,$9,$10,$11)`,
+        t.ID, t.UserID, t.Name, t.Kind, t.Prefix, t.Hash, t.Permissions, t.ExpiresAt, t.LastUsedAt, t.RevokedAt, t.CreatedAt)
+    return err
+}
+
+func (r *PGAPITokenRepository) get(ctx context.Context, where string, arg any) (domain.APIToken, error) {
+    t, err := scanAPIToken(r.pool.QueryRow(ctx, `SELECT `+apiTokenColumns+` FROM api_tokens WHERE `+where, arg))
+    if err != nil {
+        if strings.Contains(err.Error(), "no rows") { return domain.APIToken{}, ErrAPITokenNotFound }
+        return domain.APIToken{}, err
+    }
+    return t, nil
+}
+
+func (r *PGAPITokenRepository) GetByID(ctx context.Context, id string) (domain.APIToken, error) { return r.get(ctx, `id=$1`, id) }
+
+func (r *PGAPITokenRepository) GetByHash(ctx context.Context, hash string) (domain.APIToken, error) { return r.get(ctx, `token_hash=$1`, hash) }
+
+func (r *PGAPITokenRepository) ListByUser(ctx context.Context, userID string) ([]domain.APIToken, error) {
+    q := `SELECT ` + apiTokenColumns + ` FROM api_tokens`
+    args := []any{}
+    if userID != "" { q += ` WHERE user_id=$1`; args = append(args, userID) }
+    rows, err := r.pool.Query(ctx, q+` ORDER BY created_at DESC`, args...)
+    if err != nil { return nil, err }
+    defer rows.Close()
+    var out []domain.APIToken
+    for rows.Next() {
+        t, err := scanAPIToken(rows)
+        if err != nil { return nil, err }
+        out = append(out, t)
+    }
+    return out, rows.Err()
+}
+
+func (r *PGAPITokenRepository) Revoke(ctx context.Context, id string, at time.Time) error {
+    cmd, err := r.pool.Exec(ctx, `UPDATE api_tokens SET revoked_at=$2 WHERE id=$1 AND revoked_at IS NULL`, id, at)
+    if err != nil { return err }
+    if cmd.RowsAffected() == 0 { return ErrAPITokenNotFound }
+    return nil
+}
+
+func (r *PGAPITokenRepository) TouchLastUsed(ctx context.Context, id string, at time.Time) error {
+    _, err := r.pool.Exec(ctx, `UPDATE api_tokens SET last_used_at=$2 WHERE id=$1`, id, at)
+    return err
+}
+
+// 内存实现（测试用）
+type MemoryAPITokenRepository struct {
+    mu    sync.RWMutex
+    items map[string]domain.APIToken
+}
+
+func NewMemoryAPITokenRepository() *MemoryAPITokenRepository { return &MemoryAPITokenRepository{items: map[string]domain.APIToken{}} }
+
+func (m *MemoryAPITokenRepository) Create(ctx context.Context, t domain.APIToken) error {
+    m.mu.Lock(); defer m.mu.Unlock()
+    if t.CreatedAt.IsZero() { t.CreatedAt = time.Now().UTC() }
+    t.Permissions = append([]string(nil), t.Permissions...)
+    m.items[t.ID] = t
+    return nil
+}
+
+func (m *MemoryAPITokenRepository) GetByID(ctx context.Context, id string) (domain.APIToken, error) {
+    m.mu.RLock(); defer m.mu.RUnlock()
+    t, ok := m.items[id]
+    if !ok { return domain.APIToken{}, ErrAPITokenNotFound }
+    return t, nil
+}
+
+func (m *MemoryAPITokenRepository) GetByHash(ctx context.Context, hash string) (domain.APIToken, error) {
+    m.mu.RLock(); defer m.mu.RUnlock()
+    for _, t := range m.items { if t.Hash == hash { return t, nil } }
+    return domain.APIToken{}, ErrAPITokenNotFound
+}
+
+func (m *MemoryAPITokenRepository) ListByUser(ctx context.Context, userID string) ([]domain.APIToken, error) {
+    m.mu.RLock(); defer m.mu.RUnlock()
+    var out []domain.APIToken
+    for _, t := range m.items { if userID == "" || t.UserID == userID { out = append(out, t) } }
+    sort.Slice(out, func(i, j int) bool { return out[i].CreatedAt.After(out[j].CreatedAt) })
+    return out, nil
+}
+
+func (m *MemoryAPITokenRepository) Revoke(ctx context.Context, id string, at time.Time) error {
+    m.mu.Lock(); defer m.mu.Unlock()
+    t, ok := m.items[id]
+    if !ok || t.RevokedAt != nil { return ErrAPITokenNotFound }
+    t.RevokedAt = &at
+    m.items[id] = t
+    return nil
+}
+
+func (m *MemoryAPITokenRepository) TouchLastUsed(ctx context.Context, id string, at time.Time) error {
+    m.mu.Lock(); defer m.mu.Unlock()
+    t, ok := m.items[id]
+    if !ok { return ErrAPITokenNotFound }
+    t.LastUsedAt = &at
+    m.items[id] = t
+    return nil
+}
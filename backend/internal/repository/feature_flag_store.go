@@ -0,0 +1,71 @@
+package repository
+
+import (
+	"context"
+	"errors"
+
+	"github.com/YangYuS8/codyssey/backend/internal/db"
+	"github.com/YangYuS8/codyssey/backend/internal/featureflag"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// PGFeatureFlagStore 功能开关的 Postgres 存储（表 feature_flags）。
+type PGFeatureFlagStore struct { pool *pgxpool.Pool }
+
+func NewPGFeatureFlagStore(pool *pgxpool.Pool) *PGFeatureFlagStore { return &PGFeatureFlagStore{pool: pool} }
+
+const featureFlagColumns = `key, description, enabled, roles, user_ids, percentage, updated_by, created_at, updated_at`
+
+func scanFeatureFlag(row pgx.Row) (featureflag.Flag, error) {
+    var f featureflag.Flag
+    err := row.Scan(&f.Key, &f.Description, &f.Enabled, &f.Roles, &f.Users, &f.Percentage, &f.UpdatedBy, &f.CreatedAt, &f.UpdatedAt)
+    return f, err
+}
+
+func (s *PGFeatureFlagStore) List(ctx context.Context) ([]featureflag.Flag, error) {
+    ctx = db.WithOperation(ctx, "feature_flag.list")
+    rows, err := s.pool.Query(ctx, `SELECT `+featureFlagColumns+` FROM feature_flags ORDER BY key`)
+    if err != nil { return nil, err }
+    defer rows.Close()
+    out := []featureflag.Flag{}
+    for rows.Next() {
+        f, err := scanFeatureFlag(rows)
+        if err != nil { return nil, err }
+        out = append(out, f)
+    }
+    return out, rows.Err()
+}
+
+func (s *PGFeatureFlagStore) Get(ctx context.Context, key string) (featureflag.Flag, error) {
+    ctx = db.WithOperation(ctx, "feature_flag.get")
+    f, err := scanFeatureFlag(s.pool.QueryRow(ctx, `SELECT `+featureFlagColumns+` FROM feature_flags WHERE key=$1`, key))
+    if errors.Is(err, pgx.ErrNoRows) { return featureflag.Flag{}, featureflag.ErrNotFound }
+    return f, err
+}
+
+func (s *PGFeatureFlagStore) Create(ctx context.Context, f featureflag.Flag) error {
+    ctx = db.WithOperation(ctx, "feature_flag.create")
+    cmd, err := s.pool.Exec(ctx, `INSERT INTO feature_flags (`+featureFlagColumns+`) VALUES ($1,$2,$3,$4,$5,$6,$7,$8,$9) ON CONFLICT (key) DO NOTHING`,
+        f.Key, f.Description, f.Enabled, f.Roles, f.Users, f.Percentage, f.UpdatedBy, f.CreatedAt, f.UpdatedAt)
+    if err != nil { return err }
+    if cmd.RowsAffected() == 0 { return featureflag.ErrExists }
+    return nil
+}
+
+func (s *PGFeatureFlagStore) Update(ctx context.Context, f featureflag.Flag) error {
+    ctx = db.WithOperation(ctx, "feature_flag.update")
+    cmd, err := s.pool.Exec(ctx, `UPDATE feature_flags SET description=$2, enabled=$3, roles=$4, user_ids=$5, percentage=$6, updated_by=$7, updated_at=$8 WHERE key=$1`,
+        f.Key, f.Description, f.Enabled, f.Roles, f.Users, f.Percentage, f.UpdatedBy, f.UpdatedAt)
+    if err != nil { return err }
+    if cmd.RowsAffected() == 0 { return featureflag.ErrNotFound }
+    return nil
+}
+
+func (s *PGFeatureFlagStore) Delete(ctx context.Context, key string) error {
+    ctx = db.WithOperation(ctx, "feature_flag.delete")
+    cmd, err := s.pool.Exec(ctx, `DELETE FROM feature_flags WHERE key=$1`, key)
+    if err != nil { return err }
+    if cmd.RowsAffected() == 0 { return featureflag.ErrNotFound }
+    return nil
+}
@@ -0,0 +1,114 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/YangYuS8/codyssey/backend/internal/domain"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// ErrIdempotencyConflict 占用与读取之间记录反复变化（极少见），调用方按处理中对待。
+var ErrIdempotencyConflict = errors.New("idempotency key changed concurrently")
+
+// IdempotencyRepository Idempotency-Key 存储。
+// Reserve 原子地占用 key：key 不存在、已过期或处理中记录早于 staleBefore（原请求进程可能已崩溃）时写入 rec 并返回 reserved=true；
+// 否则返回已有记录。
+type IdempotencyRepository interface {
+    Reserve(ctx context.Context, rec domain.IdempotencyRecord, staleBefore time.Time) (existing domain.IdempotencyRecord, reserved bool, err error)
+    Complete(ctx context.Context, key string, status int, contentType string, body []byte) error
+    Release(ctx context.Context, key string) error
+    PurgeExpired(ctx context.Context, now time.Time) (int64, error)
+}
+
+// PG 实现
+type PGIdempotencyRepository struct { pool *pgxpool.Pool }
+
+func NewPGIdempotencyRepository(pool *pgxpool.Pool) *PGIdempotencyRepository { return &PGIdempotencyRepository{pool: pool} }
+
+func (r *PGIdempotencyRepository) Reserve(ctx context.Context, rec domain.IdempotencyRecord, staleBefore time.Time) (domain.IdempotencyRecord, bool, error) {
+    for attempt := 0; attempt < 2; attempt++ {
+        var k string
+        err := r.pool.QueryRow(ctx, `INSERT INTO idempotency_keys (key, fingerprint, status, content_type, body, created_at, expires_at)
+            VALUES ($1,$2,0,'',NULL,$3,$4)
+            ON CONFLICT (key) DO UPDATE SET fingerprint=EXCLUDED.fingerprint, status=0, content_type='', body=NULL,
+                created_at=EXCLUDED.created_at, expires_at=EXCLUDED.expires_at
+            WHERE idempotency_keys.expires_at <= EXCLUDED.created_at
+               OR (idempotency_keys.status = 0 AND idempotency_keys.created_at < $5)
+            RETURNING key`, rec.Key, rec.Fingerprint, rec.CreatedAt, rec.ExpiresAt, staleBefore).Scan(&k)
+        if err == nil { return domain.IdempotencyRecord{}, true, nil }
+        if !strings.Contains(err.Error(), "no rows") { return domain.IdempotencyRecord{}, false, err }
+        var existing domain.IdempotencyRecord
+        err = r.pool.QueryRow(ctx, `SELECT key, fingerprint, status, content_type, COALESCE(body, ''::bytea), created_at, expires_at
+            FROM idempotency_keys WHERE key=$1`, rec.Key).
+            Scan(&existing.Key, &existing.Fingerprint, &existing.Status, &existing.ContentType, &existing.Body, &existing.CreatedAt, &existing.ExpiresAt)
+        if err == nil { return existing, false, nil }
+        // 两条语句之间记录被释放，重试一次
+        if !strings.Contains(err.Error(), "no rows") { return domain.IdempotencyRecord{}, false, err }
+    }
+    return domain.IdempotencyRecord{}, false, ErrIdempotencyConflict
+}
+
+func (r *PGIdempotencyRepository) Complete(ctx context.Context, key string, status int, contentType string, body []byte) error {
+    _, err := r.pool.Exec(ctx, `UPDATE idempotency_keys SET status=$2, content_type=$3, body=$4 WHERE key=$1`, key, status, contentType, body)
+    return err
+}
+
+func (r *PGIdempotencyRepository) Release(ctx context.Context, key string) error {
+    _, err := r.pool.Exec(ctx, `DELETE FROM idempotency_keys WHERE key=$1 AND status=0`, key)
+    return err
+}
+
+func (r *PGIdempotencyRepository) PurgeExpired(ctx context.Context, now time.Time) (int64, error) {
+    cmd, err := r.pool.Exec(ctx, `DELETE FROM idempotency_keys WHERE expires_at <= $1`, now)
+    if err != nil { return 0, err }
+    return cmd.RowsAffected(), nil
+}
+
+// Memory 实现（单实例 / 测试）
+type MemoryIdempotencyRepository struct {
+    mu    sync.Mutex
+    items map[string]domain.IdempotencyRecord
+}
+
+func NewMemoryIdempotencyRepository() *MemoryIdempotencyRepository { return &MemoryIdempotencyRepository{items: map[string]domain.IdempotencyRecord{}} }
+
+func (m *MemoryIdempotencyRepository) Reserve(_ context.Context, rec domain.IdempotencyRecord, staleBefore time.Time) (domain.IdempotencyRecord, bool, error) {
+    m.mu.Lock(); defer m.mu.Unlock()
+    if cur, ok := m.items[rec.Key]; ok {
+        expired := !cur.ExpiresAt.After(rec.CreatedAt)
+        stale := !cur.Completed() && cur.CreatedAt.Before(staleBefore)
+        if !expired && !stale {
+            cur.Body = append([]byte(nil), cur.Body...)
+            return cur, false, nil
+        }
+    }
+    rec.Status, rec.ContentType, rec.Body = 0, "", nil
+    m.items[rec.Key] = rec
+    return domain.IdempotencyRecord{}, true, nil
+}
+
+func (m *MemoryIdempotencyRepository) Complete(_ context.Context, key string, status int, contentType string, body []byte) error {
+    m.mu.Lock(); defer m.mu.Unlock()
+    rec, ok := m.items[key]
+    if !ok { return nil }
+    rec.Status, rec.ContentType, rec.Body = status, contentType, append([]byte(nil), body...)
+    m.items[key] = rec
+    return nil
+}
+
+func (m *MemoryIdempotencyRepository) Release(_ context.Context, key string) error {
+    m.mu.Lock(); defer m.mu.Unlock()
+    if rec, ok := m.items[key]; ok && !rec.Completed() { delete(m.items, key) }
+    return nil
+}
+
+func (m *MemoryIdempotencyRepository) PurgeExpired(_ context.Context, now time.Time) (int64, error) {
+    m.mu.Lock(); defer m.mu.Unlock()
+    var n int64
+    for k, rec := range m.items { if !rec.ExpiresAt.After(now) { delete(m.items, k); n++ } }
+    return n, nil
+}
@@ -5,7 +5,9 @@ import (
 	"errors"
 	"time"
 
+	"github.com/YangYuS8/codyssey/backend/internal/db"
 	"github.com/YangYuS8/codyssey/backend/internal/domain"
+	"github.com/YangYuS8/codyssey/backend/internal/listquery"
 	"github.com/google/uuid"
 	"github.com/jackc/pgx/v5/pgxpool"
 )
@@ -23,9 +25,39 @@ var (
 type JudgeRunRepository interface {
     Create(ctx context.Context, jr domain.JudgeRun) error
     GetByID(ctx context.Context, id string) (domain.JudgeRun, error)
-    ListBySubmission(ctx context.Context, submissionID string, limit, offset int) ([]domain.JudgeRun, error)
+    ListBySubmission(ctx context.Context, submissionID string, spec listquery.Spec) ([]domain.JudgeRun, string, error)
     UpdateRunning(ctx context.Context, id string) error
     UpdateFinished(ctx context.Context, id string, status string, runtimeMS, memoryKB, exitCode int, errMsg string) error
+    // QueueStats 非终态运行的数量与最早排队时间（队列深度指标采集用）
+    QueueStats(ctx context.Context) (JudgeQueueStats, error)
+}
+
+// JudgeQueueStats 判题队列快照；OldestQueuedAt 为 nil 表示队列为空。
+type JudgeQueueStats struct {
+    Queued         int
+    Running        int
+    OldestQueuedAt *time.Time
+}
+
+// JudgeRunListSchema 单个提交下运行记录的过滤与排序字段，默认按创建时间正序。
+var JudgeRunListSchema = &listquery.Schema{
+    Fields: []listquery.Field{
+        {Name: "id", Column: "id", Type: listquery.String, Sortable: true},
+        {Name: "status", Column: "status", Type: listquery.String, Ops: []listquery.Op{listquery.OpEq, listquery.OpNe, listquery.OpIn}},
+        {Name: "created_at", Column: "created_at", Type: listquery.Time, Ops: []listquery.Op{listquery.OpGt, listquery.OpGte, listquery.OpLt, listquery.OpLte}, Sortable: true},
+    },
+    Key:         "id",
+    DefaultSort: "created_at",
+}
+
+func judgeRunValue(jr domain.JudgeRun, field string) any {
+    switch field {
+    case "id":
+        return jr.ID
+    case "status":
+        return jr.Status
+    }
+    return jr.CreatedAt
 }
 
 // PG 实现
@@ -35,6 +67,7 @@ type PGJudgeRunRepository struct { pool *pgxpool.Pool }
 func NewPGJudgeRunRepository(pool *pgxpool.Pool) *PGJudgeRunRepository { return &PGJudgeRunRepository{pool: pool} }
 
 func (r *PGJudgeRunRepository) Create(ctx context.Context, jr domain.JudgeRun) error {
+    ctx = db.WithOperation(ctx, "judge_run.create")
     if jr.ID == "" { jr.ID = uuid.New().String() }
     now := time.Now().UTC()
     if jr.CreatedAt.IsZero() { jr.CreatedAt = now }
@@ -46,6 +79,7 @@ func (r *PGJudgeRunRepository) Create(ctx context.Context, jr domain.JudgeRun) e
 }
 
 func (r *PGJudgeRunRepository) GetByID(ctx context.Context, id string) (domain.JudgeRun, error) {
+    ctx = db.WithOperation(ctx, "judge_run.get_by_id")
     row := r.pool.QueryRow(ctx, `SELECT id, submission_id, status, judge_version, runtime_ms, memory_kb, exit_code, error_message, started_at, finished_at, created_at, updated_at FROM judge_runs WHERE id=$1`, id)
     var jr domain.JudgeRun
     if err := row.Scan(&jr.ID,&jr.SubmissionID,&jr.Status,&jr.JudgeVersion,&jr.RuntimeMS,&jr.MemoryKB,&jr.ExitCode,&jr.ErrorMessage,&jr.StartedAt,&jr.FinishedAt,&jr.CreatedAt,&jr.UpdatedAt); err != nil {
@@ -55,22 +89,26 @@ func (r *PGJudgeRunRepository) GetByID(ctx context.Context, id string) (domain.J
     return jr, nil
 }
 
-func (r *PGJudgeRunRepository) ListBySubmission(ctx context.Context, submissionID string, limit, offset int) ([]domain.JudgeRun, error) {
-    if limit <= 0 { limit = 20 }
-    if offset < 0 { offset = 0 }
-    rows, err := r.pool.Query(ctx, `SELECT id, submission_id, status, judge_version, runtime_ms, memory_kb, exit_code, error_message, started_at, finished_at, created_at, updated_at FROM judge_runs WHERE submission_id=$1 ORDER BY created_at ASC LIMIT $2 OFFSET $3`, submissionID, limit, offset)
-    if err != nil { return nil, err }
+func (r *PGJudgeRunRepository) ListBySubmission(ctx context.Context, submissionID string, spec listquery.Spec) ([]domain.JudgeRun, string, error) {
+    ctx = db.WithOperation(ctx, "judge_run.list_by_submission")
+    q, args, err := spec.SelectSQL(`SELECT id, submission_id, status, judge_version, runtime_ms, memory_kb, exit_code, error_message, started_at, finished_at, created_at, updated_at FROM judge_runs`, listquery.Eq("submission_id", submissionID))
+    if err != nil { return nil, "", err }
+    rows, err := r.pool.Query(ctx, q, args...)
+    if err != nil { return nil, "", err }
     defer rows.Close()
-    res := make([]domain.JudgeRun,0,limit)
+    res := make([]domain.JudgeRun,0,spec.Limit+1)
     for rows.Next() {
         var jr domain.JudgeRun
-        if err := rows.Scan(&jr.ID,&jr.SubmissionID,&jr.Status,&jr.JudgeVersion,&jr.RuntimeMS,&jr.MemoryKB,&jr.ExitCode,&jr.ErrorMessage,&jr.StartedAt,&jr.FinishedAt,&jr.CreatedAt,&jr.UpdatedAt); err != nil { return nil, err }
+        if err := rows.Scan(&jr.ID,&jr.SubmissionID,&jr.Status,&jr.JudgeVersion,&jr.RuntimeMS,&jr.MemoryKB,&jr.ExitCode,&jr.ErrorMessage,&jr.StartedAt,&jr.FinishedAt,&jr.CreatedAt,&jr.UpdatedAt); err != nil { return nil, "", err }
         res = append(res, jr)
     }
-    return res, nil
+    if err := rows.Err(); err != nil { return nil, "", err }
+    res, next := listquery.Page(spec, res, judgeRunValue)
+    return res, next, nil
 }
 
 func (r *PGJudgeRunRepository) UpdateRunning(ctx context.Context, id string) error {
+    ctx = db.WithOperation(ctx, "judge_run.update_running")
     // 仅允许 queued -> running；利用 WHERE status='queued' 保证并发安全。区分 not found 与 conflict：
     // 如果记录存在但状态不是 queued，则视为冲突。
     cmd, err := r.pool.Exec(ctx, `UPDATE judge_runs SET status='running', started_at=NOW(), updated_at=NOW() WHERE id=$1 AND status='queued'`, id)
@@ -87,6 +125,7 @@ func (r *PGJudgeRunRepository) UpdateRunning(ctx context.Context, id string) err
 }
 
 func (r *PGJudgeRunRepository) UpdateFinished(ctx context.Context, id string, status string, runtimeMS, memoryKB, exitCode int, errMsg string) error {
+    ctx = db.WithOperation(ctx, "judge_run.update_finished")
     // 仅允许 running -> 终态
     switch status {
     case domain.JudgeRunStatusSucceeded, domain.JudgeRunStatusFailed, domain.JudgeRunStatusCanceled:
@@ -105,6 +144,14 @@ func (r *PGJudgeRunRepository) UpdateFinished(ctx context.Context, id string, st
     return nil
 }
 
+func (r *PGJudgeRunRepository) QueueStats(ctx context.Context) (JudgeQueueStats, error) {
+    ctx = db.WithOperation(ctx, "judge_run.queue_stats")
+    var st JudgeQueueStats
+    err := r.pool.QueryRow(ctx, `SELECT COUNT(*) FILTER (WHERE status='queued'), COUNT(*) FILTER (WHERE status='running'),
+        MIN(created_at) FILTER (WHERE status='queued') FROM judge_runs WHERE status IN ('queued','running')`).Scan(&st.Queued, &st.Running, &st.OldestQueuedAt)
+    return st, err
+}
+
 // 内存实现（测试）
 
 type MemoryJudgeRunRepository struct {
@@ -127,16 +174,11 @@ func (m *MemoryJudgeRunRepository) GetByID(ctx context.Context, id string) (doma
     return domain.JudgeRun{}, ErrJudgeRunNotFound
 }
 
-func (m *MemoryJudgeRunRepository) ListBySubmission(ctx context.Context, submissionID string, limit, offset int) ([]domain.JudgeRun, error) {
-    if limit <= 0 { limit = 20 }
-    if offset < 0 { offset = 0 }
+func (m *MemoryJudgeRunRepository) ListBySubmission(ctx context.Context, submissionID string, spec listquery.Spec) ([]domain.JudgeRun, string, error) {
     filtered := make([]domain.JudgeRun,0)
     for _, jr := range m.list { if jr.SubmissionID == submissionID { filtered = append(filtered, jr) } }
-    if offset >= len(filtered) { return []domain.JudgeRun{}, nil }
-    end := offset + limit; if end > len(filtered) { end = len(filtered) }
-    out := make([]domain.JudgeRun, end-offset)
-    copy(out, filtered[offset:end])
-    return out, nil
+    res, next := listquery.Apply(spec, filtered, judgeRunValue)
+    return res, next, nil
 }
 
 func (m *MemoryJudgeRunRepository) UpdateRunning(ctx context.Context, id string) error {
@@ -171,3 +213,17 @@ func (m *MemoryJudgeRunRepository) UpdateFinished(ctx context.Context, id string
     }
     return ErrJudgeRunNotFound
 }
+
+func (m *MemoryJudgeRunRepository) QueueStats(ctx context.Context) (JudgeQueueStats, error) {
+    var st JudgeQueueStats
+    for _, jr := range m.list {
+        switch jr.Status {
+        case domain.JudgeRunStatusQueued:
+            st.Queued++
+            if st.OldestQueuedAt == nil || jr.CreatedAt.Before(*st.OldestQueuedAt) { t := jr.CreatedAt; st.OldestQueuedAt = &t }
+        case domain.JudgeRunStatusRunning:
+            st.Running++
+        }
+    }
+    return st, nil
+}
@@ -0,0 +1,149 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/YangYuS8/codyssey/backend/internal/domain"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+var ErrLoginAttemptNotFound = errors.New("login attempt record not found")
+
+// LoginAttemptRepository 登录失败计数存储。
+// RecordFailure 需原子完成“窗口外清零 + 计数加一”，以便多实例并发时计数准确；
+// 登录在校验凭据前即调用它预占本次尝试，ReleaseFailure 撤回一次预占（计数减一，不低于 0）。
+type LoginAttemptRepository interface {
+    Get(ctx context.Context, key string) (domain.LoginAttempt, error)
+    RecordFailure(ctx context.Context, key string, at, windowStart time.Time) (domain.LoginAttempt, error)
+    ReleaseFailure(ctx context.Context, key string) error
+    Lock(ctx context.Context, key string, until time.Time) error
+    Reset(ctx context.Context, key string) error
+    ListLocked(ctx context.Context, now time.Time) ([]domain.LoginAttempt, error)
+}
+
+// PG 实现
+type PGLoginAttemptRepository struct { pool *pgxpool.Pool }
+
+func NewPGLoginAttemptRepository(pool *pgxpool.Pool) *PGLoginAttemptRepository { return &PGLoginAttemptRepository{pool: pool} }
+
+func (r *PGLoginAttemptRepository) Get(ctx context.Context, key string) (domain.LoginAttempt, error) {
+    row := r.pool.QueryRow(ctx, `SELECT key, failures, last_failed_at, locked_until FROM login_attempts WHERE key=$1`, key)
+    var a domain.LoginAttempt
+    if err := row.Scan(&a.Key, &a.Failures, &a.LastFailedAt, &a.LockedUntil); err != nil {
+        if strings.Contains(err.Error(), "no rows") { return domain.LoginAttempt{}, ErrLoginAttemptNotFound }
+        return domain.LoginAttempt{}, err
+    }
+    return a, nil
+}
+
+func (r *PGLoginAttemptRepository) RecordFailure(ctx context.Context, key string, at, windowStart time.Time) (domain.LoginAttempt, error) {
+    row := r.pool.QueryRow(ctx, `INSERT INTO login_attempts (key, failures, last_failed_at) VALUES ($1, 1, $2)
+        ON CONFLICT (key) DO UPDATE SET
+            failures = CASE WHEN login_attempts.last_failed_at < $3 THEN 1 ELSE login_attempts.failures + 1 END,
+            last_failed_at = EXCLUDED.last_failed_at
+        RETURNING key, failures, last_failed_at, locked_until`, key, at, windowStart)
+    var a domain.LoginAttempt
+    if err := row.Scan(&a.Key, &a.Failures, &a.LastFailedAt, &a.LockedUntil); err != nil { return domain.LoginAttempt{}, err }
+    return a, nil
+}
+
+func (r *PGLoginAttemptRepository) ReleaseFailure(ctx context.Context, key string) error {
+    cmd, err := r.pool.Exec(ctx, `UPDATE login_attempts SET failures = GREATEST(failures - 1, 0) WHERE key=$1`, key)
+    if err != nil { return err }
+    if cmd.RowsAffected() == 0 { return ErrLoginAttemptNotFound }
+    return nil
+}
+
+func (r *PGLoginAttemptRepository) Lock(ctx context.Context, key string, until time.Time) error {
+    cmd, err := r.pool.Exec(ctx, `UPDATE login_attempts SET locked_until=$2 WHERE key=$1`, key, until)
+    if err != nil { return err }
+    if cmd.RowsAffected() == 0 { return ErrLoginAttemptNotFound }
+    return nil
+}
+
+func (r *PGLoginAttemptRepository) Reset(ctx context.Context, key string) error {
+    cmd, err := r.pool.Exec(ctx, `DELETE FROM login_attempts WHERE key=$1`, key)
+    if err != nil { return err }
+    if cmd.RowsAffected() == 0 { return ErrLoginAttemptNotFound }
+    return nil
+}
+
+func (r *PGLoginAttemptRepository) ListLocked(ctx context.Context, now time.Time) ([]domain.LoginAttempt, error) {
+    rows, err := r.pool.Query(ctx, `SELECT key, failures, last_failed_at, locked_until FROM login_attempts WHERE locked_until > $1 ORDER BY locked_until DESC`, now)
+    if err != nil { return nil, err }
+    defer rows.Close()
+    var out []domain.LoginAttempt
+    for rows.Next() {
+        var a domain.LoginAttempt
+        if err := rows.Scan(&a.Key, &a.Failures, &a.LastFailedAt, &a.LockedUntil); err != nil { return nil, err }
+        out = append(out, a)
+    }
+    return out, rows.Err()
+}
+
+// 内存实现（单实例 / 测试）
+type MemoryLoginAttemptRepository struct {
+    mu    sync.Mutex
+    items map[string]domain.LoginAttempt
+}
+
+func NewMemoryLoginAttemptRepository() *MemoryLoginAttemptRepository { return &MemoryLoginAttemptRepository{items: map[string]domain.LoginAttempt{}} }
+
+func (m *MemoryLoginAttemptRepository) Get(ctx context.Context, key string) (domain.LoginAttempt, error) {
+    m.mu.Lock(); defer m.mu.Unlock()
+    a, ok := m.items[key]
+    if !ok { return domain.LoginAttempt{}, ErrLoginAttemptNotFound }
+    return a, nil
+}
+
+func (m *MemoryLoginAttemptRepository) RecordFailure(ctx context.Context, key string, at, windowStart time.Time) (domain.LoginAttempt, error) {
+    m.mu.Lock(); defer m.mu.Unlock()
+    a, ok := m.items[key]
+    if !ok || a.LastFailedAt.Before(windowStart) {
+        a.Key, a.Failures = key, 0
+    }
+    a.Failures++
+    a.LastFailedAt = at
+    m.items[key] = a
+    return a, nil
+}
+
+func (m *MemoryLoginAttemptRepository) ReleaseFailure(ctx context.Context, key string) error {
+    m.mu.Lock(); defer m.mu.Unlock()
+    a, ok := m.items[key]
+    if !ok { return ErrLoginAttemptNotFound }
+    if a.Failures > 0 { a.Failures-- }
+    m.items[key] = a
+    return nil
+}
+
+func (m *MemoryLoginAttemptRepository) Lock(ctx context.Context, key string, until time.Time) error {
+    m.mu.Lock(); defer m.mu.Unlock()
+    a, ok := m.items[key]
+    if !ok { return ErrLoginAttemptNotFound }
+    a.LockedUntil = &until
+    m.items[key] = a
+    return nil
+}
+
+func (m *MemoryLoginAttemptRepository) Reset(ctx context.Context, key string) error {
+    m.mu.Lock(); defer m.mu.Unlock()
+    if _, ok := m.items[key]; !ok { return ErrLoginAttemptNotFound }
+    delete(m.items, key)
+    return nil
+}
+
+func (m *MemoryLoginAttemptRepository) ListLocked(ctx context.Context, now time.Time) ([]domain.LoginAttempt, error) {
+    m.mu.Lock(); defer m.mu.Unlock()
+    var out []domain.LoginAttempt
+    for _, a := range m.items {
+        if a.LockedUntil != nil && a.LockedUntil.After(now) { out = append(out, a) }
+    }
+    sort.Slice(out, func(i, j int) bool { return out[i].LockedUntil.After(*out[j].LockedUntil) })
+    return out, nil
+}
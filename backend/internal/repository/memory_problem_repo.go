@@ -2,20 +2,28 @@ package repository
 
 import (
 	"context"
+	"sort"
 	"sync"
+	"time"
 
 	"github.com/google/uuid"
 
 	"github.com/YangYuS8/codyssey/backend/internal/domain"
+	"github.com/YangYuS8/codyssey/backend/internal/listquery"
+	"github.com/YangYuS8/codyssey/backend/internal/textsearch"
 )
 
 type MemoryProblemRepository struct {
 	mu   sync.RWMutex
 	list []domain.Problem
+	tags map[string]domain.ProblemTag
+	revs map[uuid.UUID][]domain.ProblemRevision // 按修订号升序
+	statusLogs map[uuid.UUID][]domain.ProblemStatusLog
 }
 
 func NewMemoryProblemRepository() *MemoryProblemRepository {
-	return &MemoryProblemRepository{list: make([]domain.Problem, 0, 16)}
+	return &MemoryProblemRepository{list: make([]domain.Problem, 0, 16), tags: map[string]domain.ProblemTag{}, revs: map[uuid.UUID][]domain.ProblemRevision{},
+		statusLogs: map[uuid.UUID][]domain.ProblemStatusLog{}}
 }
 
 func (m *MemoryProblemRepository) Create(ctx context.Context, p domain.Problem) error {
@@ -25,18 +33,30 @@ func (m *MemoryProblemRepository) Create(ctx context.Context, p domain.Problem)
 	return nil
 }
 
-func (m *MemoryProblemRepository) List(ctx context.Context, limit, offset int) ([]domain.Problem, error) {
+// List 与 PG 实现语义一致：检索词经同一分词器匹配标题、题面与出处，标签需全部具备。
+func (m *MemoryProblemRepository) List(ctx context.Context, f ProblemFilter, spec listquery.Spec) ([]domain.Problem, string, error) {
 	m.mu.RLock()
 	defer m.mu.RUnlock()
-	if offset >= len(m.list) {
-		return []domain.Problem{}, nil
+	query := textsearch.QueryTokens(f.Query)
+	filtered := make([]domain.Problem, 0, len(m.list))
+	for _, p := range m.list {
+		if f.Visibility != "" && p.Visibility != f.Visibility { continue }
+		if f.Status != "" && p.Status != f.Status { continue }
+		if !hasAllTags(p.Tags, f.Tags) { continue }
+		if len(query) > 0 && !textsearch.Match(problemSearchTokens(p), query) { continue }
+		filtered = append(filtered, p)
 	}
-	end := offset + limit
-	if limit <= 0 { limit = 20; end = offset + limit }
-	if end > len(m.list) { end = len(m.list) }
-	res := make([]domain.Problem, end-offset)
-	copy(res, m.list[offset:end])
-	return res, nil
+	res, next := listquery.Apply(spec, filtered, problemValue)
+	return res, next, nil
+}
+
+func hasAllTags(have, want []string) bool {
+	for _, w := range want {
+		found := false
+		for _, h := range have { if h == w { found = true; break } }
+		if !found { return false }
+	}
+	return true
 }
 
 func (m *MemoryProblemRepository) GetByID(ctx context.Context, id uuid.UUID) (domain.Problem, error) {
@@ -47,12 +67,108 @@ func (m *MemoryProblemRepository) GetByID(ctx context.Context, id uuid.UUID) (do
 
 func (m *MemoryProblemRepository) Update(ctx context.Context, p domain.Problem) error {
 	m.mu.Lock(); defer m.mu.Unlock()
-	for i, item := range m.list { if item.ID == p.ID { m.list[i] = p; return nil } }
+	return m.update(p, p.Revision)
+}
+
+// update 修订号为 expectedRevision 时替换题目（保留发布状态与来源），调用方持有写锁。
+func (m *MemoryProblemRepository) update(p domain.Problem, expectedRevision int) error {
+	for i, item := range m.list {
+		if item.ID != p.ID { continue }
+		if item.Revision != expectedRevision { return ErrProblemConflict }
+		p.Status, p.Provenance = item.Status, item.Provenance
+		m.list[i] = p
+		return nil
+	}
 	return ErrNotFound
 }
 
 func (m *MemoryProblemRepository) Delete(ctx context.Context, id uuid.UUID) error {
 	m.mu.Lock(); defer m.mu.Unlock()
-	for i, item := range m.list { if item.ID == id { m.list = append(m.list[:i], m.list[i+1:]...); return nil } }
+	for i, item := range m.list { if item.ID == id { m.list = append(m.list[:i], m.list[i+1:]...); delete(m.revs, id); delete(m.statusLogs, id); return nil } }
 	return ErrNotFound
 }
+
+func (m *MemoryProblemRepository) ListTags(ctx context.Context) ([]domain.ProblemTag, error) {
+	m.mu.RLock(); defer m.mu.RUnlock()
+	res := make([]domain.ProblemTag, 0, len(m.tags))
+	for _, t := range m.tags {
+		t.ProblemCount = 0
+		for _, p := range m.list { if hasAllTags(p.Tags, []string{t.Name}) { t.ProblemCount++ } }
+		res = append(res, t)
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i].Name < res[j].Name })
+	return res, nil
+}
+
+func (m *MemoryProblemRepository) CreateTag(ctx context.Context, t domain.ProblemTag) error {
+	m.mu.Lock(); defer m.mu.Unlock()
+	if _, ok := m.tags[t.Name]; ok { return ErrTagExists }
+	if t.CreatedAt.IsZero() { t.CreatedAt = time.Now().UTC() }
+	m.tags[t.Name] = t
+	return nil
+}
+
+func (m *MemoryProblemRepository) UpdateTag(ctx context.Context, name string, t domain.ProblemTag) error {
+	m.mu.Lock(); defer m.mu.Unlock()
+	old, ok := m.tags[name]
+	if !ok { return ErrTagNotFound }
+	if _, taken := m.tags[t.Name]; taken && t.Name != name { return ErrTagExists }
+	delete(m.tags, name)
+	old.Name, old.Description = t.Name, t.Description
+	m.tags[t.Name] = old
+	if t.Name != name { m.rewriteTags(func(tag string) string { if tag == name { return t.Name }; return tag }) }
+	return nil
+}
+
+func (m *MemoryProblemRepository) DeleteTag(ctx context.Context, name string) error {
+	m.mu.Lock(); defer m.mu.Unlock()
+	if _, ok := m.tags[name]; !ok { return ErrTagNotFound }
+	delete(m.tags, name)
+	m.rewriteTags(func(tag string) string { if tag == name { return "" }; return tag })
+	return nil
+}
+
+// rewriteTags 按 fn 改写全部题目的标签（返回空串表示移除），调用方持有写锁。
+func (m *MemoryProblemRepository) rewriteTags(fn func(string) string) {
+	for i, p := range m.list {
+		tags := make([]string, 0, len(p.Tags))
+		for _, t := range p.Tags { if nt := fn(t); nt != "" { tags = append(tags, nt) } }
+		sort.Strings(tags)
+		m.list[i].Tags = tags
+	}
+}
+
+func (m *MemoryProblemRepository) MissingTags(ctx context.Context, names []string) ([]string, error) {
+	m.mu.RLock(); defer m.mu.RUnlock()
+	var missing []string
+	for _, n := range names { if _, ok := m.tags[n]; !ok { missing = append(missing, n) } }
+	sort.Strings(missing)
+	return missing, nil
+}
+
+func (m *MemoryProblemRepository) CreateWithRevision(ctx context.Context, p domain.Problem, rev domain.ProblemRevision) error {
+	m.mu.Lock(); defer m.mu.Unlock()
+	m.list = append([]domain.Problem{p}, m.list...)
+	m.revs[p.ID] = []domain.ProblemRevision{rev}
+	return nil
+}
+
+func (m *MemoryProblemRepository) UpdateWithRevision(ctx context.Context, p domain.Problem, rev domain.ProblemRevision) error {
+	m.mu.Lock(); defer m.mu.Unlock()
+	if err := m.update(p, rev.Number-1); err != nil { return err }
+	m.revs[p.ID] = append(m.revs[p.ID], rev)
+	return nil
+}
+
+func (m *MemoryProblemRepository) ListRevisions(ctx context.Context, problemID uuid.UUID, spec listquery.Spec) ([]domain.ProblemRevision, string, error) {
+	m.mu.RLock(); defer m.mu.RUnlock()
+	revs := append([]domain.ProblemRevision(nil), m.revs[problemID]...)
+	res, next := listquery.Apply(spec, revs, revisionValue)
+	return res, next, nil
+}
+
+func (m *MemoryProblemRepository) GetRevision(ctx context.Context, problemID uuid.UUID, number int) (domain.ProblemRevision, error) {
+	m.mu.RLock(); defer m.mu.RUnlock()
+	for _, r := range m.revs[problemID] { if r.Number == number { return r, nil } }
+	return domain.ProblemRevision{}, ErrRevisionNotFound
+}
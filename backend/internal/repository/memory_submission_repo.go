@@ -6,6 +6,7 @@ import (
 	"time"
 
 	"github.com/YangYuS8/codyssey/backend/internal/domain"
+	"github.com/YangYuS8/codyssey/backend/internal/listquery"
 	"github.com/google/uuid"
 )
 
@@ -49,32 +50,13 @@ func (m *MemorySubmissionRepository) UpdateStatus(ctx context.Context, id string
     return ErrSubmissionNotFound
 }
 
-func (m *MemorySubmissionRepository) List(ctx context.Context, f SubmissionFilter, limit, offset int) ([]domain.Submission, error) {
+func (m *MemorySubmissionRepository) List(ctx context.Context, spec listquery.Spec) ([]domain.Submission, string, error) {
     m.mu.RLock(); defer m.mu.RUnlock()
-    if limit <= 0 { limit = 20 }
-    if offset < 0 { offset = 0 }
-    filtered := make([]domain.Submission,0,len(m.list))
-    for _, s := range m.list {
-        if f.UserID != "" && s.UserID != f.UserID { continue }
-        if f.ProblemID != "" && s.ProblemID != f.ProblemID { continue }
-        if f.Status != "" && s.Status != f.Status { continue }
-        filtered = append(filtered, s)
-    }
-    if offset >= len(filtered) { return []domain.Submission{}, nil }
-    end := offset + limit; if end > len(filtered) { end = len(filtered) }
-    res := make([]domain.Submission, end-offset)
-    copy(res, filtered[offset:end])
-    return res, nil
+    res, next := listquery.Apply(spec, m.list, submissionValue)
+    return res, next, nil
 }
 
-func (m *MemorySubmissionRepository) Count(ctx context.Context, f SubmissionFilter) (int, error) {
+func (m *MemorySubmissionRepository) Count(ctx context.Context, spec listquery.Spec) (int, error) {
     m.mu.RLock(); defer m.mu.RUnlock()
-    total := 0
-    for _, s := range m.list {
-        if f.UserID != "" && s.UserID != f.UserID { continue }
-        if f.ProblemID != "" && s.ProblemID != f.ProblemID { continue }
-        if f.Status != "" && s.Status != f.Status { continue }
-        total++
-    }
-    return total, nil
+    return listquery.Count(spec, m.list, submissionValue), nil
 }
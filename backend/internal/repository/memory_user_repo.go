@@ -2,10 +2,12 @@ package repository
 
 import (
 	"context"
+	"strings"
 	"sync"
 	"time"
 
 	"github.com/YangYuS8/codyssey/backend/internal/domain"
+	"github.com/YangYuS8/codyssey/backend/internal/listquery"
 	"github.com/google/uuid"
 )
 
@@ -18,13 +20,33 @@ func NewMemoryUserRepository() *MemoryUserRepository { return &MemoryUserReposit
 
 func (m *MemoryUserRepository) Create(ctx context.Context, u domain.User) error {
     m.mu.Lock(); defer m.mu.Unlock()
-    for _, existing := range m.list { if existing.Username == u.Username { return ErrUserDuplicate } }
+    for _, existing := range m.list {
+        if existing.Username == u.Username { return ErrUserDuplicate }
+        if u.Email != "" && strings.EqualFold(existing.Email, u.Email) { return ErrEmailDuplicate }
+    }
     if u.ID == "" { u.ID = uuid.New().String() }
     if u.CreatedAt.IsZero() { u.CreatedAt = time.Now().UTC() }
     m.list = append(m.list, u)
     return nil
 }
 
+func (m *MemoryUserRepository) CreateBatch(ctx context.Context, users []domain.User) error {
+    m.mu.Lock(); defer m.mu.Unlock()
+    all := append([]domain.User{}, m.list...)
+    now := time.Now().UTC()
+    for _, u := range users {
+        for _, existing := range all {
+            if existing.Username == u.Username { return ErrUserDuplicate }
+            if u.Email != "" && strings.EqualFold(existing.Email, u.Email) { return ErrEmailDuplicate }
+        }
+        if u.ID == "" { u.ID = uuid.New().String() }
+        if u.CreatedAt.IsZero() { u.CreatedAt = now }
+        all = append(all, u)
+    }
+    m.list = all
+    return nil
+}
+
 func (m *MemoryUserRepository) GetByID(ctx context.Context, id string) (domain.User, error) {
     m.mu.RLock(); defer m.mu.RUnlock()
     for _, u := range m.list { if u.ID == id { return u, nil } }
@@ -43,18 +65,41 @@ func (m *MemoryUserRepository) UpdateRoles(ctx context.Context, id string, roles
     return ErrUserNotFound
 }
 
+func (m *MemoryUserRepository) GetByEmail(ctx context.Context, email string) (domain.User, error) {
+    m.mu.RLock(); defer m.mu.RUnlock()
+    for _, u := range m.list { if email != "" && strings.EqualFold(u.Email, email) { return u, nil } }
+    return domain.User{}, ErrUserNotFound
+}
+
+func (m *MemoryUserRepository) UpdateProfile(ctx context.Context, p domain.User) error {
+    m.mu.Lock(); defer m.mu.Unlock()
+    idx := -1
+    for i, u := range m.list {
+        if u.ID == p.ID { idx = i; continue }
+        if p.Email != "" && strings.EqualFold(u.Email, p.Email) { return ErrEmailDuplicate }
+    }
+    if idx < 0 { return ErrUserNotFound }
+    u := &m.list[idx]
+    u.Email, u.EmailVerified, u.DisplayName, u.School, u.StudentNumber = p.Email, p.EmailVerified, p.DisplayName, p.School, p.StudentNumber
+    return nil
+}
+
+func (m *MemoryUserRepository) UpdatePassword(ctx context.Context, id, hash string, changedAt time.Time) error {
+    m.mu.Lock(); defer m.mu.Unlock()
+    for i, u := range m.list {
+        if u.ID == id { m.list[i].PasswordHash = hash; m.list[i].PasswordChangedAt = &changedAt; return nil }
+    }
+    return ErrUserNotFound
+}
+
 func (m *MemoryUserRepository) Delete(ctx context.Context, id string) error {
     m.mu.Lock(); defer m.mu.Unlock()
     for i, u := range m.list { if u.ID == id { m.list = append(m.list[:i], m.list[i+1:]...); return nil } }
     return ErrUserNotFound
 }
 
-func (m *MemoryUserRepository) List(ctx context.Context, limit, offset int) ([]domain.User, error) {
+func (m *MemoryUserRepository) List(ctx context.Context, spec listquery.Spec) ([]domain.User, string, error) {
     m.mu.RLock(); defer m.mu.RUnlock()
-    if limit <= 0 { limit = 20 }
-    if offset >= len(m.list) { return []domain.User{}, nil }
-    end := offset + limit; if end > len(m.list) { end = len(m.list) }
-    res := make([]domain.User, end-offset)
-    copy(res, m.list[offset:end])
-    return res, nil
+    res, next := listquery.Apply(spec, m.list, userValue)
+    return res, next, nil
 }
@@ -5,19 +5,88 @@ import (
 	"errors"
 	"strings"
 
+	"github.com/YangYuS8/codyssey/backend/internal/db"
 	"github.com/YangYuS8/codyssey/backend/internal/domain"
+	"github.com/YangYuS8/codyssey/backend/internal/listquery"
+	"github.com/YangYuS8/codyssey/backend/internal/textsearch"
 	"github.com/google/uuid"
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
 var ErrNotFound = errors.New("problem not found")
 
+// ErrProblemConflict 题目在读取后已被他人修改（当前修订号不匹配）。
+var ErrProblemConflict = errors.New("problem modified concurrently")
+
+var (
+	ErrTagNotFound = errors.New("problem tag not found")
+	ErrTagExists   = errors.New("problem tag already exists")
+)
+
 type ProblemRepository interface {
 	Create(ctx context.Context, p domain.Problem) error
 	GetByID(ctx context.Context, id uuid.UUID) (domain.Problem, error)
+	// Update 仅当库中题目的修订号仍为 p.Revision 时写入，否则返回 ErrProblemConflict。
 	Update(ctx context.Context, p domain.Problem) error
 	Delete(ctx context.Context, id uuid.UUID) error
-	List(ctx context.Context, limit, offset int) ([]domain.Problem, error)
+	List(ctx context.Context, f ProblemFilter, spec listquery.Spec) ([]domain.Problem, string, error)
+}
+
+// ProblemTagRepository 标签词表；重命名与删除会同步修改引用该标签的题目。
+type ProblemTagRepository interface {
+	ListTags(ctx context.Context) ([]domain.ProblemTag, error)
+	CreateTag(ctx context.Context, t domain.ProblemTag) error
+	// UpdateTag 修改 name 对应标签的名称与描述；新名称已存在时返回 ErrTagExists。
+	UpdateTag(ctx context.Context, name string, t domain.ProblemTag) error
+	DeleteTag(ctx context.Context, name string) error
+	// MissingTags 返回 names 中尚未创建的标签。
+	MissingTags(ctx context.Context, names []string) ([]string, error)
+}
+
+// ProblemFilter listquery 之外的题目过滤：全文检索、标签（需同时具备全部标签）、可见性与发布状态（非空时只返回该取值）。
+type ProblemFilter struct {
+	Query      string
+	Tags       []string
+	Visibility string
+	Status     string
+}
+
+// ProblemListSchema 题目列表可用的过滤与排序字段，默认按创建时间倒序。
+var ProblemListSchema = &listquery.Schema{
+	Fields: []listquery.Field{
+		{Name: "id", Column: "id", Type: listquery.String, Sortable: true},
+		{Name: "title", Column: "title", Type: listquery.String, Sortable: true},
+		{Name: "difficulty", Column: "difficulty", Type: listquery.String, Ops: []listquery.Op{listquery.OpEq, listquery.OpIn}},
+		{Name: "source", Column: "source", Type: listquery.String, Ops: []listquery.Op{listquery.OpEq}},
+		{Name: "visibility", Column: "visibility", Type: listquery.String, Ops: []listquery.Op{listquery.OpEq}},
+		{Name: "status", Column: "status", Type: listquery.String, Ops: []listquery.Op{listquery.OpEq, listquery.OpIn}},
+		{Name: "created_at", Column: "created_at", Type: listquery.Time, Ops: []listquery.Op{listquery.OpGt, listquery.OpGte, listquery.OpLt, listquery.OpLte}, Sortable: true},
+	},
+	Key:         "id",
+	DefaultSort: "-created_at",
+}
+
+func problemValue(p domain.Problem, field string) any {
+	switch field {
+	case "id":
+		return p.ID.String()
+	case "title":
+		return p.Title
+	case "difficulty":
+		return p.Difficulty
+	case "source":
+		return p.Source
+	case "visibility":
+		return p.Visibility
+	case "status":
+		return p.Status
+	}
+	return p.CreatedAt
+}
+
+// problemSearchTokens 参与全文检索的字段：标题、题面（含背景与提示）与出处。
+func problemSearchTokens(p domain.Problem) []string {
+	return textsearch.IndexTokens(p.Title, p.Description, p.Statement.Background, p.Statement.Notes, p.Source)
 }
 
 type PGProblemRepository struct {
@@ -28,53 +97,162 @@ func NewPGProblemRepository(pool *pgxpool.Pool) *PGProblemRepository {
 	return &PGProblemRepository{pool: pool}
 }
 
+const problemColumns = `id,title,description,tags,difficulty,source,visibility,time_limit_ms,memory_limit_mb,test_data_hash,revision,created_at,statement,status,provenance`
+
+func scanProblem(row interface{ Scan(dest ...any) error }) (domain.Problem, error) {
+	var p domain.Problem
+	err := row.Scan(&p.ID, &p.Title, &p.Description, &p.Tags, &p.Difficulty, &p.Source, &p.Visibility, &p.TimeLimitMS, &p.MemoryLimitMB, &p.TestDataHash, &p.Revision, &p.CreatedAt, &p.Statement, &p.Status, &p.Provenance)
+	if p.Statement.Samples == nil { p.Statement.Samples = []domain.ProblemSample{} }
+	return p, err
+}
+
 func (r *PGProblemRepository) Create(ctx context.Context, p domain.Problem) error {
-	_, err := r.pool.Exec(ctx, `INSERT INTO problems (id,title,description,created_at) VALUES ($1,$2,$3,$4)`, p.ID, p.Title, p.Description, p.CreatedAt)
+	ctx = db.WithOperation(ctx, "problem.create")
+	_, err := r.pool.Exec(ctx, insertProblemSQL, insertProblemArgs(p)...)
 	return err
 }
 
+const insertProblemSQL = `INSERT INTO problems (` + problemColumns + `,search_vector) VALUES ($1,$2,$3,$4,$5,$6,$7,$8,$9,$10,$11,$12,$13,$14,$15,array_to_tsvector($16::text[]))`
+
+func insertProblemArgs(p domain.Problem) []any {
+	if p.Tags == nil { p.Tags = []string{} }
+	return []any{p.ID, p.Title, p.Description, p.Tags, p.Difficulty, p.Source, p.Visibility, p.TimeLimitMS, p.MemoryLimitMB, p.TestDataHash, p.Revision, p.CreatedAt, p.Statement, p.Status, p.Provenance, problemSearchTokens(p)}
+}
+
 func (r *PGProblemRepository) GetByID(ctx context.Context, id uuid.UUID) (domain.Problem, error) {
-	row := r.pool.QueryRow(ctx, `SELECT id,title,description,created_at FROM problems WHERE id=$1`, id)
-	var p domain.Problem
-	var pid uuid.UUID
-	if err := row.Scan(&pid, &p.Title, &p.Description, &p.CreatedAt); err != nil {
+	ctx = db.WithOperation(ctx, "problem.get_by_id")
+	p, err := scanProblem(r.pool.QueryRow(ctx, `SELECT `+problemColumns+` FROM problems WHERE id=$1`, id))
+	if err != nil {
 		// 由于移除 pgx 直接引用，这里用字符串方式判断 no rows
 		if strings.Contains(err.Error(), "no rows") { return domain.Problem{}, ErrNotFound }
 		return domain.Problem{}, err
 	}
-	p.ID = pid
 	return p, nil
 }
 
 func (r *PGProblemRepository) Update(ctx context.Context, p domain.Problem) error {
-	cmd, err := r.pool.Exec(ctx, `UPDATE problems SET title=$1, description=$2 WHERE id=$3`, p.Title, p.Description, p.ID)
+	ctx = db.WithOperation(ctx, "problem.update")
+	cmd, err := r.pool.Exec(ctx, updateProblemSQL, updateProblemArgs(p, p.Revision)...)
 	if err != nil { return err }
-	if cmd.RowsAffected() == 0 { return ErrNotFound }
+	if cmd.RowsAffected() == 0 { return r.missOrConflict(ctx, p.ID) }
 	return nil
 }
 
+// updateProblemSQL 以修订号做乐观锁：$10 为新修订号，$12 为读取时的修订号。发布状态只经 TransitionStatus 修改。
+const updateProblemSQL = `UPDATE problems SET title=$1, description=$2, tags=$3, difficulty=$4, source=$5, visibility=$6,
+	time_limit_ms=$7, memory_limit_mb=$8, test_data_hash=$9, revision=$10, search_vector=array_to_tsvector($11::text[]), statement=$14 WHERE id=$13 AND revision=$12`
+
+func updateProblemArgs(p domain.Problem, expectedRevision int) []any {
+	if p.Tags == nil { p.Tags = []string{} }
+	return []any{p.Title, p.Description, p.Tags, p.Difficulty, p.Source, p.Visibility, p.TimeLimitMS, p.MemoryLimitMB, p.TestDataHash, p.Revision, problemSearchTokens(p), expectedRevision, p.ID, p.Statement}
+}
+
+// missOrConflict 条件更新 0 行时区分题目不存在与修订号冲突。
+func (r *PGProblemRepository) missOrConflict(ctx context.Context, id uuid.UUID) error {
+	var exists bool
+	if err := r.pool.QueryRow(ctx, `SELECT EXISTS(SELECT 1 FROM problems WHERE id=$1)`, id).Scan(&exists); err != nil { return err }
+	if !exists { return ErrNotFound }
+	return ErrProblemConflict
+}
+
 func (r *PGProblemRepository) Delete(ctx context.Context, id uuid.UUID) error {
+	ctx = db.WithOperation(ctx, "problem.delete")
 	cmd, err := r.pool.Exec(ctx, `DELETE FROM problems WHERE id=$1`, id)
 	if err != nil { return err }
 	if cmd.RowsAffected() == 0 { return ErrNotFound }
 	return nil
 }
 
-func (r *PGProblemRepository) List(ctx context.Context, limit, offset int) ([]domain.Problem, error) {
-	if limit <= 0 { limit = 20 }
-	rows, err := r.pool.Query(ctx, `SELECT id,title,description,created_at FROM problems ORDER BY created_at DESC LIMIT $1 OFFSET $2`, limit, offset)
-	if err != nil { return nil, err }
+func (r *PGProblemRepository) List(ctx context.Context, f ProblemFilter, spec listquery.Spec) ([]domain.Problem, string, error) {
+	ctx = db.WithOperation(ctx, "problem.list")
+	var conds []listquery.Cond
+	if q := textsearch.TSQuery(textsearch.QueryTokens(f.Query)); q != "" {
+		conds = append(conds, listquery.Cond{SQL: "search_vector @@ ?::tsquery", Args: []any{q}})
+	}
+	if len(f.Tags) > 0 { conds = append(conds, listquery.Cond{SQL: "tags @> ?::text[]", Args: []any{f.Tags}}) }
+	if f.Visibility != "" { conds = append(conds, listquery.Eq("visibility", f.Visibility)) }
+	if f.Status != "" { conds = append(conds, listquery.Eq("status", f.Status)) }
+	q, args, err := spec.SelectSQL(`SELECT `+problemColumns+` FROM problems`, conds...)
+	if err != nil { return nil, "", err }
+	rows, err := r.pool.Query(ctx, q, args...)
+	if err != nil { return nil, "", err }
 	defer rows.Close()
 	var res []domain.Problem
 	for rows.Next() {
-		var p domain.Problem
-		var id uuid.UUID
-		if err := rows.Scan(&id, &p.Title, &p.Description, &p.CreatedAt); err != nil { return nil, err }
-		p.ID = id
+		p, err := scanProblem(rows)
+		if err != nil { return nil, "", err }
 		res = append(res, p)
 	}
+	if err := rows.Err(); err != nil { return nil, "", err }
+	res, next := listquery.Page(spec, res, problemValue)
+	return res, next, nil
+}
+
+func (r *PGProblemRepository) ListTags(ctx context.Context) ([]domain.ProblemTag, error) {
+	ctx = db.WithOperation(ctx, "problem_tag.list")
+	rows, err := r.pool.Query(ctx, `SELECT t.name, t.description, t.created_at, COUNT(p.id) FROM problem_tags t
+		LEFT JOIN problems p ON t.name = ANY(p.tags) GROUP BY t.name, t.description, t.created_at ORDER BY t.name`)
+	if err != nil { return nil, err }
+	defer rows.Close()
+	res := make([]domain.ProblemTag, 0)
+	for rows.Next() {
+		var t domain.ProblemTag
+		if err := rows.Scan(&t.Name, &t.Description, &t.CreatedAt, &t.ProblemCount); err != nil { return nil, err }
+		res = append(res, t)
+	}
 	return res, rows.Err()
 }
 
+func (r *PGProblemRepository) CreateTag(ctx context.Context, t domain.ProblemTag) error {
+	ctx = db.WithOperation(ctx, "problem_tag.create")
+	cmd, err := r.pool.Exec(ctx, `INSERT INTO problem_tags (name, description, created_at) VALUES ($1,$2,$3) ON CONFLICT (name) DO NOTHING`, t.Name, t.Description, t.CreatedAt)
+	if err != nil { return err }
+	if cmd.RowsAffected() == 0 { return ErrTagExists }
+	return nil
+}
+
+func (r *PGProblemRepository) UpdateTag(ctx context.Context, name string, t domain.ProblemTag) error {
+	ctx = db.WithOperation(ctx, "problem_tag.update")
+	tx, err := r.pool.Begin(ctx)
+	if err != nil { return err }
+	defer func() { _ = tx.Rollback(ctx) }()
+	cmd, err := tx.Exec(ctx, `UPDATE problem_tags SET name=$1, description=$2 WHERE name=$3`, t.Name, t.Description, name)
+	if err != nil {
+		if strings.Contains(strings.ToLower(err.Error()), "unique") || strings.Contains(err.Error(), "23505") { return ErrTagExists }
+		return err
+	}
+	if cmd.RowsAffected() == 0 { return ErrTagNotFound }
+	if t.Name != name {
+		if _, err := tx.Exec(ctx, `UPDATE problems SET tags = array_replace(tags, $1, $2) WHERE $1 = ANY(tags)`, name, t.Name); err != nil { return err }
+	}
+	return tx.Commit(ctx)
+}
+
+func (r *PGProblemRepository) DeleteTag(ctx context.Context, name string) error {
+	ctx = db.WithOperation(ctx, "problem_tag.delete")
+	tx, err := r.pool.Begin(ctx)
+	if err != nil { return err }
+	defer func() { _ = tx.Rollback(ctx) }()
+	cmd, err := tx.Exec(ctx, `DELETE FROM problem_tags WHERE name=$1`, name)
+	if err != nil { return err }
+	if cmd.RowsAffected() == 0 { return ErrTagNotFound }
+	if _, err := tx.Exec(ctx, `UPDATE problems SET tags = array_remove(tags, $1) WHERE $1 = ANY(tags)`, name); err != nil { return err }
+	return tx.Commit(ctx)
+}
+
+func (r *PGProblemRepository) MissingTags(ctx context.Context, names []string) ([]string, error) {
+	ctx = db.WithOperation(ctx, "problem_tag.missing")
+	rows, err := r.pool.Query(ctx, `SELECT n FROM unnest($1::text[]) AS n WHERE NOT EXISTS (SELECT 1 FROM problem_tags t WHERE t.name = n) ORDER BY n`, names)
+	if err != nil { return nil, err }
+	defer rows.Close()
+	var missing []string
+	for rows.Next() {
+		var n string
+		if err := rows.Scan(&n); err != nil { return nil, err }
+		missing = append(missing, n)
+	}
+	return missing, rows.Err()
+}
+
 // Migration helper (idempotent) - 可在初始化时调用
 // (legacy EnsureSchema 已移除; 迁移由 goose 管理)
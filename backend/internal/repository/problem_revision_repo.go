@@ -0,0 +1,118 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"github.com/YangYuS8/codyssey/backend/internal/db"
+	"github.com/YangYuS8/codyssey/backend/internal/domain"
+	"github.com/YangYuS8/codyssey/backend/internal/listquery"
+	"github.com/google/uuid"
+)
+
+var ErrRevisionNotFound = errors.New("problem revision not found")
+
+// ProblemRevisionRepository 题面修订历史：修订只追加不修改，题目的当前题面与最新修订在同一事务内写入。
+type ProblemRevisionRepository interface {
+	// CreateWithRevision 写入新题目及其第 1 个修订。
+	CreateWithRevision(ctx context.Context, p domain.Problem, rev domain.ProblemRevision) error
+	// UpdateWithRevision 追加修订 rev 并把题目更新为 p（p.Revision == rev.Number）；
+	// 题目当前修订号不是 rev.Number-1 时返回 ErrProblemConflict。
+	UpdateWithRevision(ctx context.Context, p domain.Problem, rev domain.ProblemRevision) error
+	ListRevisions(ctx context.Context, problemID uuid.UUID, spec listquery.Spec) ([]domain.ProblemRevision, string, error)
+	GetRevision(ctx context.Context, problemID uuid.UUID, number int) (domain.ProblemRevision, error)
+}
+
+// ProblemRevisionListSchema 修订列表：默认按修订号倒序。
+var ProblemRevisionListSchema = &listquery.Schema{
+	Fields: []listquery.Field{
+		{Name: "id", Column: "id", Type: listquery.String, Sortable: true},
+		{Name: "number", Column: "number", Type: listquery.Int, Ops: []listquery.Op{listquery.OpGt, listquery.OpGte, listquery.OpLt, listquery.OpLte}, Sortable: true},
+		{Name: "author_id", Column: "author_id", Type: listquery.String, Ops: []listquery.Op{listquery.OpEq}},
+		{Name: "created_at", Column: "created_at", Type: listquery.Time, Ops: []listquery.Op{listquery.OpGt, listquery.OpGte, listquery.OpLt, listquery.OpLte}, Sortable: true},
+	},
+	Key:         "id",
+	DefaultSort: "-number",
+}
+
+func revisionValue(r domain.ProblemRevision, field string) any {
+	switch field {
+	case "id":
+		return r.ID.String()
+	case "number":
+		return r.Number
+	case "author_id":
+		return r.AuthorID
+	}
+	return r.CreatedAt
+}
+
+const revisionColumns = `id,problem_id,number,title,description,time_limit_ms,memory_limit_mb,test_data_hash,author_id,message,created_at,statement`
+
+func scanRevision(row interface{ Scan(dest ...any) error }) (domain.ProblemRevision, error) {
+	var r domain.ProblemRevision
+	err := row.Scan(&r.ID, &r.ProblemID, &r.Number, &r.Title, &r.Description, &r.TimeLimitMS, &r.MemoryLimitMB, &r.TestDataHash, &r.AuthorID, &r.Message, &r.CreatedAt, &r.Statement)
+	if r.Statement.Samples == nil { r.Statement.Samples = []domain.ProblemSample{} }
+	return r, err
+}
+
+func insertRevisionArgs(r domain.ProblemRevision) []any {
+	return []any{r.ID, r.ProblemID, r.Number, r.Title, r.Description, r.TimeLimitMS, r.MemoryLimitMB, r.TestDataHash, r.AuthorID, r.Message, r.CreatedAt, r.Statement}
+}
+
+const insertRevisionSQL = `INSERT INTO problem_revisions (` + revisionColumns + `) VALUES ($1,$2,$3,$4,$5,$6,$7,$8,$9,$10,$11,$12)`
+
+func (r *PGProblemRepository) CreateWithRevision(ctx context.Context, p domain.Problem, rev domain.ProblemRevision) error {
+	ctx = db.WithOperation(ctx, "problem.create_with_revision")
+	tx, err := r.pool.Begin(ctx)
+	if err != nil { return err }
+	defer func() { _ = tx.Rollback(ctx) }()
+	if _, err := tx.Exec(ctx, insertProblemSQL, insertProblemArgs(p)...); err != nil { return err }
+	if _, err := tx.Exec(ctx, insertRevisionSQL, insertRevisionArgs(rev)...); err != nil { return err }
+	return tx.Commit(ctx)
+}
+
+func (r *PGProblemRepository) UpdateWithRevision(ctx context.Context, p domain.Problem, rev domain.ProblemRevision) error {
+	ctx = db.WithOperation(ctx, "problem.update_with_revision")
+	tx, err := r.pool.Begin(ctx)
+	if err != nil { return err }
+	defer func() { _ = tx.Rollback(ctx) }()
+	cmd, err := tx.Exec(ctx, updateProblemSQL, updateProblemArgs(p, rev.Number-1)...)
+	if err != nil { return err }
+	if cmd.RowsAffected() == 0 { return r.missOrConflict(ctx, p.ID) }
+	if _, err := tx.Exec(ctx, insertRevisionSQL, insertRevisionArgs(rev)...); err != nil {
+		// (problem_id, number) 唯一约束兜底并发追加
+		if strings.Contains(strings.ToLower(err.Error()), "unique") || strings.Contains(err.Error(), "23505") { return ErrProblemConflict }
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+func (r *PGProblemRepository) ListRevisions(ctx context.Context, problemID uuid.UUID, spec listquery.Spec) ([]domain.ProblemRevision, string, error) {
+	ctx = db.WithOperation(ctx, "problem_revision.list")
+	q, args, err := spec.SelectSQL(`SELECT `+revisionColumns+` FROM problem_revisions`, listquery.Eq("problem_id", problemID))
+	if err != nil { return nil, "", err }
+	rows, err := r.pool.Query(ctx, q, args...)
+	if err != nil { return nil, "", err }
+	defer rows.Close()
+	var res []domain.ProblemRevision
+	for rows.Next() {
+		rev, err := scanRevision(rows)
+		if err != nil { return nil, "", err }
+		res = append(res, rev)
+	}
+	if err := rows.Err(); err != nil { return nil, "", err }
+	res, next := listquery.Page(spec, res, revisionValue)
+	return res, next, nil
+}
+
+func (r *PGProblemRepository) GetRevision(ctx context.Context, problemID uuid.UUID, number int) (domain.ProblemRevision, error) {
+	ctx = db.WithOperation(ctx, "problem_revision.get")
+	rev, err := scanRevision(r.pool.QueryRow(ctx, `SELECT `+revisionColumns+` FROM problem_revisions WHERE problem_id=$1 AND number=$2`, problemID, number))
+	if err != nil {
+		if strings.Contains(err.Error(), "no rows") { return domain.ProblemRevision{}, ErrRevisionNotFound }
+		return domain.ProblemRevision{}, err
+	}
+	return rev, nil
+}
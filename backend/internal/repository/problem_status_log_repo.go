@@ -0,0 +1,97 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/YangYuS8/codyssey/backend/internal/db"
+	"github.com/YangYuS8/codyssey/backend/internal/domain"
+	"github.com/YangYuS8/codyssey/backend/internal/listquery"
+	"github.com/google/uuid"
+)
+
+// ProblemReviewRepository 题目发布状态流转与审计日志。
+type ProblemReviewRepository interface {
+	// TransitionStatus 题目当前状态为 l.FromStatus 时改为 l.ToStatus，并在同一事务内追加审计日志 l；
+	// 状态已被他人改变返回 ErrProblemConflict，题目不存在返回 ErrNotFound。
+	TransitionStatus(ctx context.Context, l domain.ProblemStatusLog) error
+	ListStatusLogs(ctx context.Context, problemID uuid.UUID, spec listquery.Spec) ([]domain.ProblemStatusLog, string, error)
+}
+
+// ProblemStatusLogListSchema 单个题目的状态流转日志，默认按时间正序。
+var ProblemStatusLogListSchema = &listquery.Schema{
+	Fields: []listquery.Field{
+		{Name: "id", Column: "id", Type: listquery.String, Sortable: true},
+		{Name: "action", Column: "action", Type: listquery.String, Ops: []listquery.Op{listquery.OpEq, listquery.OpIn}},
+		{Name: "to_status", Column: "to_status", Type: listquery.String, Ops: []listquery.Op{listquery.OpEq, listquery.OpIn}},
+		{Name: "actor_id", Column: "actor_id", Type: listquery.String, Ops: []listquery.Op{listquery.OpEq}},
+		{Name: "created_at", Column: "created_at", Type: listquery.Time, Ops: []listquery.Op{listquery.OpGt, listquery.OpGte, listquery.OpLt, listquery.OpLte}, Sortable: true},
+	},
+	Key:         "id",
+	DefaultSort: "created_at",
+}
+
+func problemStatusLogValue(l domain.ProblemStatusLog, field string) any {
+	switch field {
+	case "id":
+		return l.ID.String()
+	case "action":
+		return l.Action
+	case "to_status":
+		return l.ToStatus
+	case "actor_id":
+		return l.ActorID
+	}
+	return l.CreatedAt
+}
+
+const problemStatusLogColumns = `id,problem_id,action,from_status,to_status,actor_id,comment,created_at`
+
+func (r *PGProblemRepository) TransitionStatus(ctx context.Context, l domain.ProblemStatusLog) error {
+	ctx = db.WithOperation(ctx, "problem.transition_status")
+	tx, err := r.pool.Begin(ctx)
+	if err != nil { return err }
+	defer func() { _ = tx.Rollback(ctx) }()
+	cmd, err := tx.Exec(ctx, `UPDATE problems SET status=$1 WHERE id=$2 AND status=$3`, l.ToStatus, l.ProblemID, l.FromStatus)
+	if err != nil { return err }
+	if cmd.RowsAffected() == 0 { return r.missOrConflict(ctx, l.ProblemID) }
+	if _, err := tx.Exec(ctx, `INSERT INTO problem_status_logs (`+problemStatusLogColumns+`) VALUES ($1,$2,$3,$4,$5,$6,$7,$8)`,
+		l.ID, l.ProblemID, l.Action, l.FromStatus, l.ToStatus, l.ActorID, l.Comment, l.CreatedAt); err != nil { return err }
+	return tx.Commit(ctx)
+}
+
+func (r *PGProblemRepository) ListStatusLogs(ctx context.Context, problemID uuid.UUID, spec listquery.Spec) ([]domain.ProblemStatusLog, string, error) {
+	ctx = db.WithOperation(ctx, "problem_status_log.list")
+	q, args, err := spec.SelectSQL(`SELECT `+problemStatusLogColumns+` FROM problem_status_logs`, listquery.Eq("problem_id", problemID))
+	if err != nil { return nil, "", err }
+	rows, err := r.pool.Query(ctx, q, args...)
+	if err != nil { return nil, "", err }
+	defer rows.Close()
+	var res []domain.ProblemStatusLog
+	for rows.Next() {
+		var l domain.ProblemStatusLog
+		if err := rows.Scan(&l.ID, &l.ProblemID, &l.Action, &l.FromStatus, &l.ToStatus, &l.ActorID, &l.Comment, &l.CreatedAt); err != nil { return nil, "", err }
+		res = append(res, l)
+	}
+	if err := rows.Err(); err != nil { return nil, "", err }
+	res, next := listquery.Page(spec, res, problemStatusLogValue)
+	return res, next, nil
+}
+
+func (m *MemoryProblemRepository) TransitionStatus(ctx context.Context, l domain.ProblemStatusLog) error {
+	m.mu.Lock(); defer m.mu.Unlock()
+	for i, p := range m.list {
+		if p.ID != l.ProblemID { continue }
+		if p.Status != l.FromStatus { return ErrProblemConflict }
+		m.list[i].Status = l.ToStatus
+		m.statusLogs[l.ProblemID] = append(m.statusLogs[l.ProblemID], l)
+		return nil
+	}
+	return ErrNotFound
+}
+
+func (m *MemoryProblemRepository) ListStatusLogs(ctx context.Context, problemID uuid.UUID, spec listquery.Spec) ([]domain.ProblemStatusLog, string, error) {
+	m.mu.RLock(); defer m.mu.RUnlock()
+	logs := append([]domain.ProblemStatusLog(nil), m.statusLogs[problemID]...)
+	res, next := listquery.Apply(spec, logs, problemStatusLogValue)
+	return res, next, nil
+}
@@ -0,0 +1,48 @@
+package repository
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/YangYuS8/codyssey/backend/internal/ratelimit"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// PGRateLimitStore 多副本共享的令牌桶。补充与扣减在单条 upsert 内完成；
+// 时间以数据库时钟为准，避免各副本时钟漂移影响补充量。
+type PGRateLimitStore struct {
+    pool      *pgxpool.Pool
+    lastPurge sync.Map // group -> time.Time
+}
+
+func NewPGRateLimitStore(pool *pgxpool.Pool) *PGRateLimitStore { return &PGRateLimitStore{pool: pool} }
+
+// refill 为补充后的令牌数：min(burst, tokens + elapsed * rate)
+const rateLimitRefill = `LEAST($2::float8, b.tokens + GREATEST(0, EXTRACT(EPOCH FROM (now() - b.updated_at))) * $3::float8)`
+
+func (s *PGRateLimitStore) Take(ctx context.Context, key string, p ratelimit.Policy, now time.Time) (ratelimit.Decision, error) {
+    s.maybePurge(ctx, p, now)
+    var (
+        tokens  float64
+        allowed bool
+    )
+    err := s.pool.QueryRow(ctx, `INSERT INTO rate_limit_buckets AS b (key, tokens, allowed, updated_at)
+        VALUES ($1, $2::float8 - 1, $2 >= 1, now())
+        ON CONFLICT (key) DO UPDATE SET
+            tokens = CASE WHEN `+rateLimitRefill+` >= 1 THEN `+rateLimitRefill+` - 1 ELSE `+rateLimitRefill+` END,
+            allowed = `+rateLimitRefill+` >= 1,
+            updated_at = now()
+        RETURNING tokens, allowed`, key, p.Burst, float64(p.Burst)/p.Per.Seconds()).Scan(&tokens, &allowed)
+    if err != nil { return ratelimit.Decision{}, err }
+    if allowed { tokens++ } // 还原为扣减前的令牌数
+    return ratelimit.Decide(tokens, allowed, p), nil
+}
+
+// maybePurge 每个分组最多每 10 分钟清理一次已恢复满额的桶（与新桶等价）。
+func (s *PGRateLimitStore) maybePurge(ctx context.Context, p ratelimit.Policy, now time.Time) {
+    if v, ok := s.lastPurge.Load(p.Group); ok && now.Sub(v.(time.Time)) < 10*time.Minute { return }
+    s.lastPurge.Store(p.Group, now)
+    _, _ = s.pool.Exec(ctx, `DELETE FROM rate_limit_buckets WHERE key LIKE $1 AND updated_at < now() - make_interval(secs => $2)`,
+        p.Group+":%", p.Per.Seconds())
+}
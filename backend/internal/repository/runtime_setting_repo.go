@@ -0,0 +1,166 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/YangYuS8/codyssey/backend/internal/db"
+	"github.com/YangYuS8/codyssey/backend/internal/domain"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// ErrSettingsVersionConflict 写入时全局版本号已被其他变更推进。
+var ErrSettingsVersionConflict = errors.New("runtime settings version conflict")
+
+// RuntimeSettingRepository 运行时参数覆盖值存储。全局版本号在每次 Apply 后加一，用于乐观锁与多实例变更检测。
+type RuntimeSettingRepository interface {
+    // Load 返回全部覆盖值与当前全局版本号（同一快照）。
+    Load(ctx context.Context) ([]domain.RuntimeSetting, int64, error)
+    Version(ctx context.Context) (int64, error)
+    // Apply 在 expectedVersion 上原子地写入一组变更（值为 nil 表示删除覆盖）并逐键记录变更，返回新版本号。
+    Apply(ctx context.Context, expectedVersion int64, values map[string]*string, actor, source string) (int64, error)
+    // ListChanges 按版本倒序列出变更记录。
+    ListChanges(ctx context.Context, limit, offset int) ([]domain.RuntimeSettingChange, error)
+}
+
+// PG 实现
+type PGRuntimeSettingRepository struct { pool *pgxpool.Pool }
+
+func NewPGRuntimeSettingRepository(pool *pgxpool.Pool) *PGRuntimeSettingRepository { return &PGRuntimeSettingRepository{pool: pool} }
+
+func (r *PGRuntimeSettingRepository) Load(ctx context.Context) ([]domain.RuntimeSetting, int64, error) {
+    ctx = db.WithOperation(ctx, "runtime_setting.load")
+    // 可重复读：版本号与覆盖值来自同一快照
+    tx, err := r.pool.BeginTx(ctx, pgx.TxOptions{IsoLevel: pgx.RepeatableRead, AccessMode: pgx.ReadOnly})
+    if err != nil { return nil, 0, err }
+    defer func() { _ = tx.Rollback(ctx) }()
+    var version int64
+    if err := tx.QueryRow(ctx, `SELECT version FROM runtime_settings_version`).Scan(&version); err != nil { return nil, 0, err }
+    rows, err := tx.Query(ctx, `SELECT key, value, version, updated_by, updated_at FROM runtime_settings ORDER BY key`)
+    if err != nil { return nil, 0, err }
+    defer rows.Close()
+    var out []domain.RuntimeSetting
+    for rows.Next() {
+        var s domain.RuntimeSetting
+        if err := rows.Scan(&s.Key, &s.Value, &s.Version, &s.UpdatedBy, &s.UpdatedAt); err != nil { return nil, 0, err }
+        out = append(out, s)
+    }
+    return out, version, rows.Err()
+}
+
+func (r *PGRuntimeSettingRepository) Version(ctx context.Context) (int64, error) {
+    ctx = db.WithOperation(ctx, "runtime_setting.version")
+    var version int64
+    err := r.pool.QueryRow(ctx, `SELECT version FROM runtime_settings_version`).Scan(&version)
+    return version, err
+}
+
+func (r *PGRuntimeSettingRepository) Apply(ctx context.Context, expectedVersion int64, values map[string]*string, actor, source string) (int64, error) {
+    ctx = db.WithOperation(ctx, "runtime_setting.apply")
+    tx, err := r.pool.Begin(ctx)
+    if err != nil { return 0, err }
+    defer func() { _ = tx.Rollback(ctx) }()
+    // 推进版本号同时锁住版本行，串行化并发写入
+    var version int64
+    err = tx.QueryRow(ctx, `UPDATE runtime_settings_version SET version=version+1 WHERE version=$1 RETURNING version`, expectedVersion).Scan(&version)
+    if err != nil {
+        if errors.Is(err, pgx.ErrNoRows) { return 0, ErrSettingsVersionConflict }
+        return 0, err
+    }
+    now := time.Now().UTC()
+    for _, key := range sortedKeys(values) {
+        var old *string
+        if err := tx.QueryRow(ctx, `SELECT value FROM runtime_settings WHERE key=$1`, key).Scan(&old); err != nil && !errors.Is(err, pgx.ErrNoRows) { return 0, err }
+        v := values[key]
+        if v == nil {
+            _, err = tx.Exec(ctx, `DELETE FROM runtime_settings WHERE key=$1`, key)
+        } else {
+            _, err = tx.Exec(ctx, `INSERT INTO runtime_settings (key, value, version, updated_by, updated_at) VALUES ($1,$2,$3,$4,$5)
+                ON CONFLICT (key) DO UPDATE SET value=EXCLUDED.value, version=EXCLUDED.version, updated_by=EXCLUDED.updated_by, updated_at=EXCLUDED.updated_at`,
+                key, *v, version, actor, now)
+        }
+        if err != nil { return 0, err }
+        _, err = tx.Exec(ctx, `INSERT INTO runtime_setting_changes (version, key, old_value, new_value, changed_by, source, changed_at) VALUES ($1,$2,$3,$4,$5,$6,$7)`,
+            version, key, old, v, actor, source, now)
+        if err != nil { return 0, err }
+    }
+    return version, tx.Commit(ctx)
+}
+
+func (r *PGRuntimeSettingRepository) ListChanges(ctx context.Context, limit, offset int) ([]domain.RuntimeSettingChange, error) {
+    ctx = db.WithOperation(ctx, "runtime_setting.list_changes")
+    rows, err := r.pool.Query(ctx, `SELECT id, version, key, old_value, new_value, changed_by, source, changed_at
+        FROM runtime_setting_changes ORDER BY version DESC, id DESC LIMIT $1 OFFSET $2`, limit, offset)
+    if err != nil { return nil, err }
+    defer rows.Close()
+    var out []domain.RuntimeSettingChange
+    for rows.Next() {
+        var c domain.RuntimeSettingChange
+        if err := rows.Scan(&c.ID, &c.Version, &c.Key, &c.OldValue, &c.NewValue, &c.ChangedBy, &c.Source, &c.ChangedAt); err != nil { return nil, err }
+        out = append(out, c)
+    }
+    return out, rows.Err()
+}
+
+func sortedKeys(m map[string]*string) []string {
+    keys := make([]string, 0, len(m))
+    for k := range m { keys = append(keys, k) }
+    sort.Strings(keys)
+    return keys
+}
+
+// 内存实现（测试 / 单实例开发用）
+type MemoryRuntimeSettingRepository struct {
+    mu       sync.Mutex
+    version  int64
+    settings map[string]domain.RuntimeSetting
+    changes  []domain.RuntimeSettingChange
+}
+
+func NewMemoryRuntimeSettingRepository() *MemoryRuntimeSettingRepository {
+    return &MemoryRuntimeSettingRepository{settings: map[string]domain.RuntimeSetting{}}
+}
+
+func (m *MemoryRuntimeSettingRepository) Load(ctx context.Context) ([]domain.RuntimeSetting, int64, error) {
+    m.mu.Lock(); defer m.mu.Unlock()
+    out := make([]domain.RuntimeSetting, 0, len(m.settings))
+    for _, s := range m.settings { out = append(out, s) }
+    sort.Slice(out, func(i, j int) bool { return out[i].Key < out[j].Key })
+    return out, m.version, nil
+}
+
+func (m *MemoryRuntimeSettingRepository) Version(ctx context.Context) (int64, error) {
+    m.mu.Lock(); defer m.mu.Unlock()
+    return m.version, nil
+}
+
+func (m *MemoryRuntimeSettingRepository) Apply(ctx context.Context, expectedVersion int64, values map[string]*string, actor, source string) (int64, error) {
+    m.mu.Lock(); defer m.mu.Unlock()
+    if m.version != expectedVersion { return 0, ErrSettingsVersionConflict }
+    m.version++
+    now := time.Now().UTC()
+    for _, key := range sortedKeys(values) {
+        var old *string
+        if s, ok := m.settings[key]; ok { v := s.Value; old = &v }
+        v := values[key]
+        if v != nil { cp := *v; v = &cp }
+        if v == nil {
+            delete(m.settings, key)
+        } else {
+            m.settings[key] = domain.RuntimeSetting{Key: key, Value: *v, Version: m.version, UpdatedBy: actor, UpdatedAt: now}
+        }
+        m.changes = append(m.changes, domain.RuntimeSettingChange{ID: int64(len(m.changes) + 1), Version: m.version, Key: key, OldValue: old, NewValue: v, ChangedBy: actor, Source: source, ChangedAt: now})
+    }
+    return m.version, nil
+}
+
+func (m *MemoryRuntimeSettingRepository) ListChanges(ctx context.Context, limit, offset int) ([]domain.RuntimeSettingChange, error) {
+    m.mu.Lock(); defer m.mu.Unlock()
+    out := []domain.RuntimeSettingChange{}
+    for i := len(m.changes) - 1 - offset; i >= 0 && len(out) < limit; i-- { out = append(out, m.changes[i]) }
+    return out, nil
+}
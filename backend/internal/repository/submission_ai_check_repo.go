@@ -0,0 +1,293 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/YangYuS8/codyssey/backend/internal/db"
+	"github.com/YangYuS8/codyssey/backend/internal/domain"
+	"github.com/YangYuS8/codyssey/backend/internal/listquery"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// ErrAICheckLeaseLost 写入结果时记录已不由调用方持有（租约过期后被重新认领或已有结果）。
+var ErrAICheckLeaseLost = errors.New("ai check lease lost")
+
+// SubmissionAICheckRepository 提交的 AI 生成代码检测结果与按题目的检测开关。
+type SubmissionAICheckRepository interface {
+    // DetectionEnabled 题目是否开启了检测。
+    DetectionEnabled(ctx context.Context, problemID string) (bool, error)
+    // SetDetection 新增或覆盖一个范围的开关。
+    SetDetection(ctx context.Context, s domain.AIDetectionSetting) error
+    ListDetectionSettings(ctx context.Context) ([]domain.AIDetectionSetting, error)
+    // CreateCheck 登记检测记录（通常为带租约的 running，由登记的实例直接处理）；同一提交已登记时忽略。
+    CreateCheck(ctx context.Context, c domain.SubmissionAICheck) error
+    // FinishCheck 写入检测结果（状态、分数、可疑标记、模型、错误、检测时间）并清除租约。
+    // c.LeaseUntil 为调用方认领时持有的租约：记录已不是 running 或租约已被其他实例重新认领时返回 ErrAICheckLeaseLost，
+    // 记录不存在返回 ErrNotFound。
+    FinishCheck(ctx context.Context, c domain.SubmissionAICheck) error
+    GetCheck(ctx context.Context, submissionID string) (domain.SubmissionAICheck, error)
+    ListChecks(ctx context.Context, problemID string, spec listquery.Spec) ([]domain.SubmissionAICheck, string, error)
+    SummarizeChecks(ctx context.Context, problemID string) (domain.AICheckSummary, error)
+    // ClaimChecks 认领最早登记的至多 limit 条未完成记录（pending，或租约已过期的 running）：
+    // 置为 running 并设置租约到期时间 now+lease，并发认领的实例之间不会重复。
+    ClaimChecks(ctx context.Context, limit int, lease time.Duration) ([]domain.SubmissionAICheck, error)
+}
+
+// SubmissionAICheckListSchema 单题检测结果列表，默认按登记时间倒序。
+var SubmissionAICheckListSchema = &listquery.Schema{
+    Fields: []listquery.Field{
+        {Name: "submission_id", Column: "submission_id", Type: listquery.String, Sortable: true},
+        {Name: "user_id", Column: "user_id", Type: listquery.String, Ops: []listquery.Op{listquery.OpEq, listquery.OpIn}},
+        {Name: "status", Column: "status", Type: listquery.String, Ops: []listquery.Op{listquery.OpEq, listquery.OpIn}},
+        {Name: "created_at", Column: "created_at", Type: listquery.Time, Ops: []listquery.Op{listquery.OpGt, listquery.OpGte, listquery.OpLt, listquery.OpLte}, Sortable: true},
+    },
+    Key:         "submission_id",
+    DefaultSort: "-created_at",
+}
+
+func aiCheckValue(c domain.SubmissionAICheck, field string) any {
+    switch field {
+    case "submission_id":
+        return c.SubmissionID
+    case "user_id":
+        return c.UserID
+    case "status":
+        return c.Status
+    }
+    return c.CreatedAt
+}
+
+type PGSubmissionAICheckRepository struct { pool *pgxpool.Pool }
+
+func NewPGSubmissionAICheckRepository(pool *pgxpool.Pool) *PGSubmissionAICheckRepository { return &PGSubmissionAICheckRepository{pool: pool} }
+
+func (r *PGSubmissionAICheckRepository) DetectionEnabled(ctx context.Context, problemID string) (bool, error) {
+    ctx = db.WithOperation(ctx, "ai_detection_setting.enabled")
+    var on bool
+    err := r.pool.QueryRow(ctx, `SELECT EXISTS (SELECT 1 FROM ai_detection_settings WHERE enabled AND scope='problem' AND scope_id=$1)`, problemID).Scan(&on)
+    return on, err
+}
+
+func (r *PGSubmissionAICheckRepository) SetDetection(ctx context.Context, s domain.AIDetectionSetting) error {
+    ctx = db.WithOperation(ctx, "ai_detection_setting.set")
+    _, err := r.pool.Exec(ctx, `INSERT INTO ai_detection_settings (scope, scope_id, enabled, updated_by, updated_at) VALUES ($1,$2,$3,$4,$5)
+        ON CONFLICT (scope, scope_id) DO UPDATE SET enabled=EXCLUDED.enabled, updated_by=EXCLUDED.updated_by, updated_at=EXCLUDED.updated_at`,
+        s.Scope, s.ScopeID, s.Enabled, s.UpdatedBy, s.UpdatedAt)
+    return err
+}
+
+func (r *PGSubmissionAICheckRepository) ListDetectionSettings(ctx context.Context) ([]domain.AIDetectionSetting, error) {
+    ctx = db.WithOperation(ctx, "ai_detection_setting.list")
+    rows, err := r.pool.Query(ctx, `SELECT scope, scope_id, enabled, updated_by, updated_at FROM ai_detection_settings ORDER BY scope, scope_id`)
+    if err != nil { return nil, err }
+    defer rows.Close()
+    res := []domain.AIDetectionSetting{}
+    for rows.Next() {
+        var s domain.AIDetectionSetting
+        if err := rows.Scan(&s.Scope, &s.ScopeID, &s.Enabled, &s.UpdatedBy, &s.UpdatedAt); err != nil { return nil, err }
+        res = append(res, s)
+    }
+    return res, rows.Err()
+}
+
+const aiCheckColumns = `submission_id, problem_id, user_id, status, score, suspicious, model, error, created_at, checked_at, lease_until`
+
+func scanAICheck(row interface{ Scan(dest ...any) error }) (domain.SubmissionAICheck, error) {
+    var c domain.SubmissionAICheck
+    err := row.Scan(&c.SubmissionID, &c.ProblemID, &c.UserID, &c.Status, &c.Score, &c.Suspicious, &c.Model, &c.Error, &c.CreatedAt, &c.CheckedAt, &c.LeaseUntil)
+    return c, err
+}
+
+func (r *PGSubmissionAICheckRepository) CreateCheck(ctx context.Context, c domain.SubmissionAICheck) error {
+    ctx = db.WithOperation(ctx, "submission_ai_check.create")
+    _, err := r.pool.Exec(ctx, `INSERT INTO submission_ai_checks (`+aiCheckColumns+`) VALUES ($1,$2,$3,$4,$5,$6,$7,$8,$9,$10,$11) ON CONFLICT (submission_id) DO NOTHING`,
+        c.SubmissionID, c.ProblemID, c.UserID, c.Status, c.Score, c.Suspicious, c.Model, c.Error, c.CreatedAt, c.CheckedAt, c.LeaseUntil)
+    return err
+}
+
+// FinishCheck 以 status='running' 且 lease_until 等于持有的租约为条件更新，租约过期后被重新认领的记录不会被旧实例覆盖。
+func (r *PGSubmissionAICheckRepository) FinishCheck(ctx context.Context, c domain.SubmissionAICheck) error {
+    ctx = db.WithOperation(ctx, "submission_ai_check.finish")
+    cmd, err := r.pool.Exec(ctx, `UPDATE submission_ai_checks SET status=$1, score=$2, suspicious=$3, model=$4, error=$5, checked_at=$6, lease_until=NULL
+        WHERE submission_id=$7 AND status='running' AND lease_until=$8`,
+        c.Status, c.Score, c.Suspicious, c.Model, c.Error, c.CheckedAt, c.SubmissionID, c.LeaseUntil)
+    if err != nil { return err }
+    if cmd.RowsAffected() == 0 {
+        if _, err := r.GetCheck(ctx, c.SubmissionID); err != nil { return err }
+        return ErrAICheckLeaseLost
+    }
+    return nil
+}
+
+func (r *PGSubmissionAICheckRepository) GetCheck(ctx context.Context, submissionID string) (domain.SubmissionAICheck, error) {
+    ctx = db.WithOperation(ctx, "submission_ai_check.get")
+    c, err := scanAICheck(r.pool.QueryRow(ctx, `SELECT `+aiCheckColumns+` FROM submission_ai_checks WHERE submission_id=$1`, submissionID))
+    if err != nil {
+        if strings.Contains(err.Error(), "no rows") { return domain.SubmissionAICheck{}, ErrNotFound }
+        return domain.SubmissionAICheck{}, err
+    }
+    return c, nil
+}
+
+func (r *PGSubmissionAICheckRepository) ListChecks(ctx context.Context, problemID string, spec listquery.Spec) ([]domain.SubmissionAICheck, string, error) {
+    ctx = db.WithOperation(ctx, "submission_ai_check.list")
+    q, args, err := spec.SelectSQL(`SELECT `+aiCheckColumns+` FROM submission_ai_checks`, listquery.Eq("problem_id", problemID))
+    if err != nil { return nil, "", err }
+    rows, err := r.pool.Query(ctx, q, args...)
+    if err != nil { return nil, "", err }
+    defer rows.Close()
+    res := make([]domain.SubmissionAICheck, 0, spec.Limit+1)
+    for rows.Next() {
+        c, err := scanAICheck(rows)
+        if err != nil { return nil, "", err }
+        res = append(res, c)
+    }
+    if err := rows.Err(); err != nil { return nil, "", err }
+    res, next := listquery.Page(spec, res, aiCheckValue)
+    return res, next, nil
+}
+
+func (r *PGSubmissionAICheckRepository) SummarizeChecks(ctx context.Context, problemID string) (domain.AICheckSummary, error) {
+    ctx = db.WithOperation(ctx, "submission_ai_check.summarize")
+    s := domain.AICheckSummary{ProblemID: problemID}
+    err := r.pool.QueryRow(ctx, `SELECT COUNT(*),
+        COUNT(*) FILTER (WHERE status='pending'), COUNT(*) FILTER (WHERE status='running'), COUNT(*) FILTER (WHERE status='completed'),
+        COUNT(*) FILTER (WHERE status='failed'), COUNT(*) FILTER (WHERE status='completed' AND suspicious), COALESCE(AVG(score) FILTER (WHERE status='completed'), 0)
+        FROM submission_ai_checks WHERE problem_id=$1`, problemID).Scan(&s.Total, &s.Pending, &s.Running, &s.Completed, &s.Failed, &s.Suspicious, &s.AvgScore)
+    return s, err
+}
+
+// ClaimChecks 以 FOR UPDATE SKIP LOCKED 选取候选行并在同一语句内置为 running，多实例并发认领互不重复。
+func (r *PGSubmissionAICheckRepository) ClaimChecks(ctx context.Context, limit int, lease time.Duration) ([]domain.SubmissionAICheck, error) {
+    ctx = db.WithOperation(ctx, "submission_ai_check.claim")
+    rows, err := r.pool.Query(ctx, `UPDATE submission_ai_checks SET status='running', lease_until=NOW() + $2 * INTERVAL '1 millisecond'
+        WHERE submission_id IN (SELECT submission_id FROM submission_ai_checks
+            WHERE status='pending' OR (status='running' AND lease_until < NOW())
+            ORDER BY created_at LIMIT $1 FOR UPDATE SKIP LOCKED)
+        RETURNING `+aiCheckColumns, limit, lease.Milliseconds())
+    if err != nil { return nil, err }
+    defer rows.Close()
+    var res []domain.SubmissionAICheck
+    for rows.Next() {
+        c, err := scanAICheck(rows)
+        if err != nil { return nil, err }
+        res = append(res, c)
+    }
+    return res, rows.Err()
+}
+
+// MemorySubmissionAICheckRepository 内存实现（测试 / 无数据库运行）。
+type MemorySubmissionAICheckRepository struct {
+    mu       sync.RWMutex
+    settings map[string]domain.AIDetectionSetting // scope + ":" + scope_id
+    checks   map[string]domain.SubmissionAICheck
+}
+
+func NewMemorySubmissionAICheckRepository() *MemorySubmissionAICheckRepository {
+    return &MemorySubmissionAICheckRepository{settings: map[string]domain.AIDetectionSetting{}, checks: map[string]domain.SubmissionAICheck{}}
+}
+
+func (m *MemorySubmissionAICheckRepository) DetectionEnabled(ctx context.Context, problemID string) (bool, error) {
+    m.mu.RLock(); defer m.mu.RUnlock()
+    return m.settings[domain.AIDetectionScopeProblem+":"+problemID].Enabled, nil
+}
+
+func (m *MemorySubmissionAICheckRepository) SetDetection(ctx context.Context, s domain.AIDetectionSetting) error {
+    m.mu.Lock(); defer m.mu.Unlock()
+    m.settings[s.Scope+":"+s.ScopeID] = s
+    return nil
+}
+
+func (m *MemorySubmissionAICheckRepository) ListDetectionSettings(ctx context.Context) ([]domain.AIDetectionSetting, error) {
+    m.mu.RLock(); defer m.mu.RUnlock()
+    res := make([]domain.AIDetectionSetting, 0, len(m.settings))
+    for _, s := range m.settings { res = append(res, s) }
+    sort.Slice(res, func(i, j int) bool {
+        if res[i].Scope != res[j].Scope { return res[i].Scope < res[j].Scope }
+        return res[i].ScopeID < res[j].ScopeID
+    })
+    return res, nil
+}
+
+func (m *MemorySubmissionAICheckRepository) CreateCheck(ctx context.Context, c domain.SubmissionAICheck) error {
+    m.mu.Lock(); defer m.mu.Unlock()
+    if _, ok := m.checks[c.SubmissionID]; !ok { m.checks[c.SubmissionID] = c }
+    return nil
+}
+
+func (m *MemorySubmissionAICheckRepository) FinishCheck(ctx context.Context, c domain.SubmissionAICheck) error {
+    m.mu.Lock(); defer m.mu.Unlock()
+    cur, ok := m.checks[c.SubmissionID]
+    if !ok { return ErrNotFound }
+    if cur.Status != domain.AICheckStatusRunning || cur.LeaseUntil == nil || c.LeaseUntil == nil || !cur.LeaseUntil.Equal(*c.LeaseUntil) { return ErrAICheckLeaseLost }
+    cur.Status, cur.Score, cur.Suspicious, cur.Model, cur.Error, cur.CheckedAt = c.Status, c.Score, c.Suspicious, c.Model, c.Error, c.CheckedAt
+    cur.LeaseUntil = nil
+    m.checks[c.SubmissionID] = cur
+    return nil
+}
+
+func (m *MemorySubmissionAICheckRepository) GetCheck(ctx context.Context, submissionID string) (domain.SubmissionAICheck, error) {
+    m.mu.RLock(); defer m.mu.RUnlock()
+    c, ok := m.checks[submissionID]
+    if !ok { return domain.SubmissionAICheck{}, ErrNotFound }
+    return c, nil
+}
+
+// byProblem 调用方持有读锁。
+func (m *MemorySubmissionAICheckRepository) byProblem(problemID string) []domain.SubmissionAICheck {
+    var res []domain.SubmissionAICheck
+    for _, c := range m.checks { if c.ProblemID == problemID { res = append(res, c) } }
+    return res
+}
+
+func (m *MemorySubmissionAICheckRepository) ListChecks(ctx context.Context, problemID string, spec listquery.Spec) ([]domain.SubmissionAICheck, string, error) {
+    m.mu.RLock(); defer m.mu.RUnlock()
+    res, next := listquery.Apply(spec, m.byProblem(problemID), aiCheckValue)
+    return res, next, nil
+}
+
+func (m *MemorySubmissionAICheckRepository) SummarizeChecks(ctx context.Context, problemID string) (domain.AICheckSummary, error) {
+    m.mu.RLock(); defer m.mu.RUnlock()
+    s := domain.AICheckSummary{ProblemID: problemID}
+    var sum float64
+    for _, c := range m.byProblem(problemID) {
+        s.Total++
+        switch c.Status {
+        case domain.AICheckStatusPending:
+            s.Pending++
+        case domain.AICheckStatusRunning:
+            s.Running++
+        case domain.AICheckStatusFailed:
+            s.Failed++
+        case domain.AICheckStatusCompleted:
+            s.Completed++
+            sum += c.Score
+            if c.Suspicious { s.Suspicious++ }
+        }
+    }
+    if s.Completed > 0 { s.AvgScore = sum / float64(s.Completed) }
+    return s, nil
+}
+
+func (m *MemorySubmissionAICheckRepository) ClaimChecks(ctx context.Context, limit int, lease time.Duration) ([]domain.SubmissionAICheck, error) {
+    m.mu.Lock(); defer m.mu.Unlock()
+    now := time.Now().UTC()
+    var res []domain.SubmissionAICheck
+    for _, c := range m.checks {
+        expired := c.Status == domain.AICheckStatusRunning && c.LeaseUntil != nil && c.LeaseUntil.Before(now)
+        if c.Status == domain.AICheckStatusPending || expired { res = append(res, c) }
+    }
+    sort.Slice(res, func(i, j int) bool { return res[i].CreatedAt.Before(res[j].CreatedAt) })
+    if len(res) > limit { res = res[:limit] }
+    until := now.Add(lease)
+    for i := range res {
+        res[i].Status, res[i].LeaseUntil = domain.AICheckStatusRunning, &until
+        m.checks[res[i].SubmissionID] = res[i]
+    }
+    return res, nil
+}
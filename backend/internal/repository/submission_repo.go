@@ -3,11 +3,12 @@ package repository
 import (
 	"context"
 	"errors"
-	"strconv"
 	"strings"
 	"time"
 
+	"github.com/YangYuS8/codyssey/backend/internal/db"
 	"github.com/YangYuS8/codyssey/backend/internal/domain"
+	"github.com/YangYuS8/codyssey/backend/internal/listquery"
 	"github.com/google/uuid"
 	"github.com/jackc/pgx/v5/pgxpool"
 )
@@ -22,15 +23,40 @@ type SubmissionRepository interface {
     GetByID(ctx context.Context, id string) (domain.Submission, error)
     // UpdateStatus 基于版本号乐观锁；expectedVersion 为调用方读取到的当前 version。
     UpdateStatus(ctx context.Context, id string, status string, expectedVersion int) error
-    List(ctx context.Context, filter SubmissionFilter, limit, offset int) ([]domain.Submission, error)
-    Count(ctx context.Context, filter SubmissionFilter) (int, error)
+    // List 按 SubmissionListSchema 描述的条件分页查询，返回本页与下一页游标（无下一页时为空）。
+    List(ctx context.Context, spec listquery.Spec) ([]domain.Submission, string, error)
+    // Count 满足过滤条件的总数（不受分页影响）。
+    Count(ctx context.Context, spec listquery.Spec) (int, error)
 }
 
-// SubmissionFilter 用于列表过滤
-type SubmissionFilter struct {
-    UserID    string
-    ProblemID string
-    Status    string
+// SubmissionListSchema 提交列表可用的过滤与排序字段，默认按创建时间倒序。
+var SubmissionListSchema = &listquery.Schema{
+    Fields: []listquery.Field{
+        {Name: "id", Column: "id", Type: listquery.String, Sortable: true},
+        {Name: "user_id", Column: "user_id", Type: listquery.String, Ops: []listquery.Op{listquery.OpEq, listquery.OpIn}},
+        {Name: "problem_id", Column: "problem_id", Type: listquery.String, Ops: []listquery.Op{listquery.OpEq, listquery.OpIn}},
+        {Name: "status", Column: "status", Type: listquery.String, Ops: []listquery.Op{listquery.OpEq, listquery.OpNe, listquery.OpIn}},
+        {Name: "language", Column: "language", Type: listquery.String, Ops: []listquery.Op{listquery.OpEq, listquery.OpIn}},
+        {Name: "created_at", Column: "created_at", Type: listquery.Time, Ops: []listquery.Op{listquery.OpGt, listquery.OpGte, listquery.OpLt, listquery.OpLte}, Sortable: true},
+    },
+    Key:         "id",
+    DefaultSort: "-created_at",
+}
+
+func submissionValue(s domain.Submission, field string) any {
+    switch field {
+    case "id":
+        return s.ID
+    case "user_id":
+        return s.UserID
+    case "problem_id":
+        return s.ProblemID
+    case "status":
+        return s.Status
+    case "language":
+        return s.Language
+    }
+    return s.CreatedAt
 }
 
 type PGSubmissionRepository struct { pool *pgxpool.Pool }
@@ -38,22 +64,24 @@ type PGSubmissionRepository struct { pool *pgxpool.Pool }
 func NewPGSubmissionRepository(pool *pgxpool.Pool) *PGSubmissionRepository { return &PGSubmissionRepository{pool: pool} }
 
 func (r *PGSubmissionRepository) Create(ctx context.Context, s domain.Submission) error {
+    ctx = db.WithOperation(ctx, "submission.create")
     if s.ID == "" { s.ID = uuid.New().String() }
     now := time.Now().UTC()
     if s.CreatedAt.IsZero() { s.CreatedAt = now }
     s.UpdatedAt = now
     // version 初始为 1
     if s.Version == 0 { s.Version = 1 }
-    _, err := r.pool.Exec(ctx, `INSERT INTO submissions (id, user_id, problem_id, language, code, status, runtime_ms, memory_kb, error_message, version, created_at, updated_at)
-        VALUES ($1,$2,$3,$4,$5,$6,$7,$8,$9,$10,$11,$12)`,
-        s.ID, s.UserID, s.ProblemID, s.Language, s.Code, s.Status, s.RuntimeMS, s.MemoryKB, s.ErrorMessage, s.Version, s.CreatedAt, s.UpdatedAt)
+    _, err := r.pool.Exec(ctx, `INSERT INTO submissions (id, user_id, problem_id, problem_revision_id, language, code, status, runtime_ms, memory_kb, error_message, version, created_at, updated_at)
+        VALUES ($1,$2,$3,NULLIF($4, '')::uuid,$5,$6,$7,$8,$9,$10,$11,$12,$13)`,
+        s.ID, s.UserID, s.ProblemID, s.ProblemRevisionID, s.Language, s.Code, s.Status, s.RuntimeMS, s.MemoryKB, s.ErrorMessage, s.Version, s.CreatedAt, s.UpdatedAt)
     return err
 }
 
 func (r *PGSubmissionRepository) GetByID(ctx context.Context, id string) (domain.Submission, error) {
-    row := r.pool.QueryRow(ctx, `SELECT id, user_id, problem_id, language, code, status, runtime_ms, memory_kb, error_message, version, created_at, updated_at FROM submissions WHERE id=$1`, id)
+    ctx = db.WithOperation(ctx, "submission.get_by_id")
+    row := r.pool.QueryRow(ctx, `SELECT id, user_id, problem_id, COALESCE(problem_revision_id::text, ''), language, code, status, runtime_ms, memory_kb, error_message, version, created_at, updated_at FROM submissions WHERE id=$1`, id)
     var s domain.Submission
-    if err := row.Scan(&s.ID, &s.UserID, &s.ProblemID, &s.Language, &s.Code, &s.Status, &s.RuntimeMS, &s.MemoryKB, &s.ErrorMessage, &s.Version, &s.CreatedAt, &s.UpdatedAt); err != nil {
+    if err := row.Scan(&s.ID, &s.UserID, &s.ProblemID, &s.ProblemRevisionID, &s.Language, &s.Code, &s.Status, &s.RuntimeMS, &s.MemoryKB, &s.ErrorMessage, &s.Version, &s.CreatedAt, &s.UpdatedAt); err != nil {
         if strings.Contains(err.Error(), "no rows") { return domain.Submission{}, ErrSubmissionNotFound }
         return domain.Submission{}, err
     }
@@ -61,6 +89,7 @@ func (r *PGSubmissionRepository) GetByID(ctx context.Context, id string) (domain
 }
 
 func (r *PGSubmissionRepository) UpdateStatus(ctx context.Context, id string, status string, expectedVersion int) error {
+    ctx = db.WithOperation(ctx, "submission.update_status")
     // 使用版本号乐观锁：只有当 version 匹配时才更新并自增
     cmd, err := r.pool.Exec(ctx, `UPDATE submissions SET status=$1, version=version+1, updated_at=NOW() WHERE id=$2 AND version=$3`, status, id, expectedVersion)
     if err != nil { return err }
@@ -68,43 +97,29 @@ func (r *PGSubmissionRepository) UpdateStatus(ctx context.Context, id string, st
     return nil
 }
 
-func (r *PGSubmissionRepository) List(ctx context.Context, f SubmissionFilter, limit, offset int) ([]domain.Submission, error) {
-    if limit <= 0 { limit = 20 }
-    if offset < 0 { offset = 0 }
-    clauses := []string{"1=1"}
-    args := []any{}
-    // build dynamic placeholders using current len(args)+1 to avoid manual idx management
-    if f.UserID != "" { clauses = append(clauses, "user_id=$"+itoa(len(args)+1)); args = append(args, f.UserID) }
-    if f.ProblemID != "" { clauses = append(clauses, "problem_id=$"+itoa(len(args)+1)); args = append(args, f.ProblemID) }
-    if f.Status != "" { clauses = append(clauses, "status=$"+itoa(len(args)+1)); args = append(args, f.Status) }
-    limitPos := len(args) + 1
-    offsetPos := len(args) + 2
-    q := `SELECT id, user_id, problem_id, language, code, status, runtime_ms, memory_kb, error_message, version, created_at, updated_at FROM submissions WHERE ` + strings.Join(clauses, " AND ") + ` ORDER BY created_at DESC LIMIT $` + itoa(limitPos) + ` OFFSET $` + itoa(offsetPos)
-    args = append(args, limit, offset)
+func (r *PGSubmissionRepository) List(ctx context.Context, spec listquery.Spec) ([]domain.Submission, string, error) {
+    ctx = db.WithOperation(ctx, "submission.list")
+    q, args, err := spec.SelectSQL(`SELECT id, user_id, problem_id, COALESCE(problem_revision_id::text, ''), language, code, status, runtime_ms, memory_kb, error_message, version, created_at, updated_at FROM submissions`)
+    if err != nil { return nil, "", err }
     rows, err := r.pool.Query(ctx, q, args...)
-    if err != nil { return nil, err }
+    if err != nil { return nil, "", err }
     defer rows.Close()
-    res := make([]domain.Submission,0,limit)
+    res := make([]domain.Submission,0,spec.Limit+1)
     for rows.Next() {
         var s domain.Submission
-        if err := rows.Scan(&s.ID,&s.UserID,&s.ProblemID,&s.Language,&s.Code,&s.Status,&s.RuntimeMS,&s.MemoryKB,&s.ErrorMessage,&s.Version,&s.CreatedAt,&s.UpdatedAt); err != nil { return nil, err }
+        if err := rows.Scan(&s.ID,&s.UserID,&s.ProblemID,&s.ProblemRevisionID,&s.Language,&s.Code,&s.Status,&s.RuntimeMS,&s.MemoryKB,&s.ErrorMessage,&s.Version,&s.CreatedAt,&s.UpdatedAt); err != nil { return nil, "", err }
         res = append(res, s)
     }
-    return res, nil
+    if err := rows.Err(); err != nil { return nil, "", err }
+    res, next := listquery.Page(spec, res, submissionValue)
+    return res, next, nil
 }
 
-func (r *PGSubmissionRepository) Count(ctx context.Context, f SubmissionFilter) (int, error) {
-    clauses := []string{"1=1"}
-    args := []any{}
-    if f.UserID != "" { clauses = append(clauses, "user_id=$"+itoa(len(args)+1)); args = append(args, f.UserID) }
-    if f.ProblemID != "" { clauses = append(clauses, "problem_id=$"+itoa(len(args)+1)); args = append(args, f.ProblemID) }
-    if f.Status != "" { clauses = append(clauses, "status=$"+itoa(len(args)+1)); args = append(args, f.Status) }
-    q := `SELECT COUNT(*) FROM submissions WHERE ` + strings.Join(clauses, " AND ")
-    row := r.pool.QueryRow(ctx, q, args...)
+func (r *PGSubmissionRepository) Count(ctx context.Context, spec listquery.Spec) (int, error) {
+    ctx = db.WithOperation(ctx, "submission.count")
+    q, args, err := spec.CountSQL(`SELECT COUNT(*) FROM submissions`)
+    if err != nil { return 0, err }
     var total int
-    if err := row.Scan(&total); err != nil { return 0, err }
+    if err := r.pool.QueryRow(ctx, q, args...).Scan(&total); err != nil { return 0, err }
     return total, nil
 }
-
-// 简易 int->string (避免 strconv 每次导入)
-func itoa(i int) string { return strconv.FormatInt(int64(i),10) }
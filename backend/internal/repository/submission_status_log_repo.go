@@ -2,9 +2,13 @@ package repository
 
 import (
 	"context"
+	"sort"
+	"sync"
 	"time"
 
+	"github.com/YangYuS8/codyssey/backend/internal/db"
 	"github.com/YangYuS8/codyssey/backend/internal/domain"
+	"github.com/YangYuS8/codyssey/backend/internal/listquery"
 	"github.com/google/uuid"
 	"github.com/jackc/pgx/v5/pgxpool"
 )
@@ -12,7 +16,30 @@ import (
 // SubmissionStatusLogRepository 日志仓库接口
 type SubmissionStatusLogRepository interface {
     Add(ctx context.Context, log domain.SubmissionStatusLog) error
-    ListBySubmission(ctx context.Context, submissionID string, limit, offset int) ([]domain.SubmissionStatusLog, error)
+    ListBySubmission(ctx context.Context, submissionID string, spec listquery.Spec) ([]domain.SubmissionStatusLog, string, error)
+    // ListSince 返回 created_at 晚于 since 的日志（按时间升序）；submissionID 为空表示不限提交。用于事件流断线续传。
+    ListSince(ctx context.Context, since time.Time, submissionID string, limit int) ([]domain.SubmissionStatusLog, error)
+}
+
+// StatusLogListSchema 单个提交下状态日志的过滤与排序字段，默认按时间正序。
+var StatusLogListSchema = &listquery.Schema{
+    Fields: []listquery.Field{
+        {Name: "id", Column: "id", Type: listquery.String, Sortable: true},
+        {Name: "to_status", Column: "to_status", Type: listquery.String, Ops: []listquery.Op{listquery.OpEq, listquery.OpIn}},
+        {Name: "created_at", Column: "created_at", Type: listquery.Time, Ops: []listquery.Op{listquery.OpGt, listquery.OpGte, listquery.OpLt, listquery.OpLte}, Sortable: true},
+    },
+    Key:         "id",
+    DefaultSort: "created_at",
+}
+
+func statusLogValue(l domain.SubmissionStatusLog, field string) any {
+    switch field {
+    case "id":
+        return l.ID
+    case "to_status":
+        return l.ToStatus
+    }
+    return l.CreatedAt
 }
 
 // PG 实现
@@ -21,6 +48,7 @@ type PGSubmissionStatusLogRepository struct { pool *pgxpool.Pool }
 func NewPGSubmissionStatusLogRepository(pool *pgxpool.Pool) *PGSubmissionStatusLogRepository { return &PGSubmissionStatusLogRepository{pool: pool} }
 
 func (r *PGSubmissionStatusLogRepository) Add(ctx context.Context, l domain.SubmissionStatusLog) error {
+    ctx = db.WithOperation(ctx, "submission_status_log.add")
     if l.ID == "" { l.ID = uuid.New().String() }
     if l.CreatedAt.IsZero() { l.CreatedAt = time.Now().UTC() }
     _, err := r.pool.Exec(ctx, `INSERT INTO submission_status_logs (id, submission_id, from_status, to_status, created_at) VALUES ($1,$2,$3,$4,$5)`,
@@ -28,23 +56,43 @@ func (r *PGSubmissionStatusLogRepository) Add(ctx context.Context, l domain.Subm
     return err
 }
 
-func (r *PGSubmissionStatusLogRepository) ListBySubmission(ctx context.Context, submissionID string, limit, offset int) ([]domain.SubmissionStatusLog, error) {
-    if limit <= 0 { limit = 20 }
-    if offset < 0 { offset = 0 }
-    rows, err := r.pool.Query(ctx, `SELECT id, submission_id, from_status, to_status, created_at FROM submission_status_logs WHERE submission_id=$1 ORDER BY created_at ASC LIMIT $2 OFFSET $3`, submissionID, limit, offset)
+func (r *PGSubmissionStatusLogRepository) ListBySubmission(ctx context.Context, submissionID string, spec listquery.Spec) ([]domain.SubmissionStatusLog, string, error) {
+    ctx = db.WithOperation(ctx, "submission_status_log.list_by_submission")
+    q, args, err := spec.SelectSQL(`SELECT id, submission_id, from_status, to_status, created_at FROM submission_status_logs`, listquery.Eq("submission_id", submissionID))
+    if err != nil { return nil, "", err }
+    rows, err := r.pool.Query(ctx, q, args...)
+    if err != nil { return nil, "", err }
+    defer rows.Close()
+    res := make([]domain.SubmissionStatusLog,0,spec.Limit+1)
+    for rows.Next() {
+        var l domain.SubmissionStatusLog
+        if err := rows.Scan(&l.ID,&l.SubmissionID,&l.FromStatus,&l.ToStatus,&l.CreatedAt); err != nil { return nil, "", err }
+        res = append(res, l)
+    }
+    if err := rows.Err(); err != nil { return nil, "", err }
+    res, next := listquery.Page(spec, res, statusLogValue)
+    return res, next, nil
+}
+
+func (r *PGSubmissionStatusLogRepository) ListSince(ctx context.Context, since time.Time, submissionID string, limit int) ([]domain.SubmissionStatusLog, error) {
+    ctx = db.WithOperation(ctx, "submission_status_log.list_since")
+    if limit <= 0 { limit = 100 }
+    rows, err := r.pool.Query(ctx, `SELECT id, submission_id, from_status, to_status, created_at FROM submission_status_logs
+        WHERE created_at > $1 AND ($2 = '' OR submission_id::text = $2) ORDER BY created_at ASC LIMIT $3`, since, submissionID, limit)
     if err != nil { return nil, err }
     defer rows.Close()
-    res := make([]domain.SubmissionStatusLog,0,limit)
+    res := make([]domain.SubmissionStatusLog, 0)
     for rows.Next() {
         var l domain.SubmissionStatusLog
-        if err := rows.Scan(&l.ID,&l.SubmissionID,&l.FromStatus,&l.ToStatus,&l.CreatedAt); err != nil { return nil, err }
+        if err := rows.Scan(&l.ID, &l.SubmissionID, &l.FromStatus, &l.ToStatus, &l.CreatedAt); err != nil { return nil, err }
         res = append(res, l)
     }
-    return res, nil
+    return res, rows.Err()
 }
 
 // 内存实现（测试用）
 type MemorySubmissionStatusLogRepository struct {
+    mu   sync.RWMutex
     list []domain.SubmissionStatusLog
 }
 
@@ -53,18 +101,31 @@ func NewMemorySubmissionStatusLogRepository() *MemorySubmissionStatusLogReposito
 func (m *MemorySubmissionStatusLogRepository) Add(ctx context.Context, l domain.SubmissionStatusLog) error {
     if l.ID == "" { l.ID = uuid.New().String() }
     if l.CreatedAt.IsZero() { l.CreatedAt = time.Now().UTC() }
+    m.mu.Lock()
     m.list = append(m.list, l)
+    m.mu.Unlock()
     return nil
 }
 
-func (m *MemorySubmissionStatusLogRepository) ListBySubmission(ctx context.Context, submissionID string, limit, offset int) ([]domain.SubmissionStatusLog, error) {
-    if limit <= 0 { limit = 20 }
-    if offset < 0 { offset = 0 }
+func (m *MemorySubmissionStatusLogRepository) ListBySubmission(ctx context.Context, submissionID string, spec listquery.Spec) ([]domain.SubmissionStatusLog, string, error) {
     filtered := make([]domain.SubmissionStatusLog,0)
+    m.mu.RLock()
+    defer m.mu.RUnlock()
     for _, l := range m.list { if l.SubmissionID == submissionID { filtered = append(filtered, l) } }
-    if offset >= len(filtered) { return []domain.SubmissionStatusLog{}, nil }
-    end := offset + limit; if end > len(filtered) { end = len(filtered) }
-    out := make([]domain.SubmissionStatusLog, end-offset)
-    copy(out, filtered[offset:end])
+    res, next := listquery.Apply(spec, filtered, statusLogValue)
+    return res, next, nil
+}
+
+func (m *MemorySubmissionStatusLogRepository) ListSince(ctx context.Context, since time.Time, submissionID string, limit int) ([]domain.SubmissionStatusLog, error) {
+    if limit <= 0 { limit = 100 }
+    m.mu.RLock()
+    defer m.mu.RUnlock()
+    out := make([]domain.SubmissionStatusLog, 0)
+    for _, l := range m.list {
+        if !l.CreatedAt.After(since) || (submissionID != "" && l.SubmissionID != submissionID) { continue }
+        out = append(out, l)
+    }
+    sort.SliceStable(out, func(i, j int) bool { return out[i].CreatedAt.Before(out[j].CreatedAt) })
+    if len(out) > limit { out = out[:limit] }
     return out, nil
 }
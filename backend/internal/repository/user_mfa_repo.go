@@ -0,0 +1,169 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/YangYuS8/codyssey/backend/internal/domain"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+var ErrMFANotFound = errors.New("mfa not enrolled")
+
+// UserMFARepository 保存 TOTP 登记信息；Save 为 upsert 语义。
+// 一次性语义（验证码时间步、恢复码、待验证令牌）由条件更新保证，并发请求只有一个返回 true。
+type UserMFARepository interface {
+    Get(ctx context.Context, userID string) (domain.UserMFA, error)
+    Save(ctx context.Context, m domain.UserMFA) error
+    Delete(ctx context.Context, userID string) error
+    // Enable 确认登记：仅当记录未启用且密钥未被重置时写入，返回是否成功。
+    Enable(ctx context.Context, m domain.UserMFA) (bool, error)
+    // UseStep 仅当 step 大于已用时间步时记录，返回是否成功。
+    UseStep(ctx context.Context, userID string, step int64) (bool, error)
+    // UseRecoveryCode 移除一个恢复码摘要，摘要不存在（已被使用）返回 false。
+    UseRecoveryCode(ctx context.Context, userID, hash string) (bool, error)
+    // ConsumeChallenge 标记待验证令牌（jti）已使用，已使用过返回 false；顺带清理过期记录。
+    ConsumeChallenge(ctx context.Context, jti string, expiresAt time.Time) (bool, error)
+}
+
+// PG 实现
+type PGUserMFARepository struct { pool *pgxpool.Pool }
+
+func NewPGUserMFARepository(pool *pgxpool.Pool) *PGUserMFARepository { return &PGUserMFARepository{pool: pool} }
+
+func (r *PGUserMFARepository) Get(ctx context.Context, userID string) (domain.UserMFA, error) {
+    row := r.pool.QueryRow(ctx, `SELECT user_id, secret, enabled, recovery_codes, last_used_step, confirmed_at, created_at, updated_at FROM user_mfa WHERE user_id=$1`, userID)
+    var m domain.UserMFA
+    if err := row.Scan(&m.UserID, &m.Secret, &m.Enabled, &m.RecoveryCodes, &m.LastUsedStep, &m.ConfirmedAt, &m.CreatedAt, &m.UpdatedAt); err != nil {
+        if strings.Contains(err.Error(), "no rows") { return domain.UserMFA{}, ErrMFANotFound }
+        return domain.UserMFA{}, err
+    }
+    return m, nil
+}
+
+func (r *PGUserMFARepository) Save(ctx context.Context, m domain.UserMFA) error {
+    now := time.Now().UTC()
+    if m.CreatedAt.IsZero() { m.CreatedAt = now }
+    if m.RecoveryCodes == nil { m.RecoveryCodes = []string{} }
+    _, err := r.pool.Exec(ctx, `INSERT INTO user_mfa (user_id, secret, enabled, recovery_codes, last_used_step, confirmed_at, created_at, updated_at)
+        VALUES ($1,$2,$3,$4,$5,$6,$7,$8)
+        ON CONFLICT (user_id) DO UPDATE SET secret=EXCLUDED.secret, enabled=EXCLUDED.enabled, recovery_codes=EXCLUDED.recovery_codes,
+            last_used_step=EXCLUDED.last_used_step, confirmed_at=EXCLUDED.confirmed_at, updated_at=EXCLUDED.updated_at`,
+        m.UserID, m.Secret, m.Enabled, m.RecoveryCodes, m.LastUsedStep, m.ConfirmedAt, m.CreatedAt, now)
+    return err
+}
+
+func (r *PGUserMFARepository) Delete(ctx context.Context, userID string) error {
+    cmd, err := r.pool.Exec(ctx, `DELETE FROM user_mfa WHERE user_id=$1`, userID)
+    if err != nil { return err }
+    if cmd.RowsAffected() == 0 { return ErrMFANotFound }
+    return nil
+}
+
+func (r *PGUserMFARepository) Enable(ctx context.Context, m domain.UserMFA) (bool, error) {
+    cmd, err := r.pool.Exec(ctx, `UPDATE user_mfa SET enabled=TRUE, recovery_codes=$3, last_used_step=$4, confirmed_at=$5, updated_at=NOW()
+        WHERE user_id=$1 AND secret=$2 AND NOT enabled`, m.UserID, m.Secret, m.RecoveryCodes, m.LastUsedStep, m.ConfirmedAt)
+    if err != nil { return false, err }
+    return cmd.RowsAffected() == 1, nil
+}
+
+func (r *PGUserMFARepository) UseStep(ctx context.Context, userID string, step int64) (bool, error) {
+    cmd, err := r.pool.Exec(ctx, `UPDATE user_mfa SET last_used_step=$2, updated_at=NOW() WHERE user_id=$1 AND enabled AND last_used_step < $2`, userID, step)
+    if err != nil { return false, err }
+    return cmd.RowsAffected() == 1, nil
+}
+
+func (r *PGUserMFARepository) UseRecoveryCode(ctx context.Context, userID, hash string) (bool, error) {
+    cmd, err := r.pool.Exec(ctx, `UPDATE user_mfa SET recovery_codes=array_remove(recovery_codes, $2), updated_at=NOW()
+        WHERE user_id=$1 AND enabled AND $2 = ANY(recovery_codes)`, userID, hash)
+    if err != nil { return false, err }
+    return cmd.RowsAffected() == 1, nil
+}
+
+func (r *PGUserMFARepository) ConsumeChallenge(ctx context.Context, jti string, expiresAt time.Time) (bool, error) {
+    if _, err := r.pool.Exec(ctx, `DELETE FROM mfa_challenge_uses WHERE expires_at < NOW()`); err != nil { return false, err }
+    cmd, err := r.pool.Exec(ctx, `INSERT INTO mfa_challenge_uses (jti, expires_at) VALUES ($1,$2) ON CONFLICT (jti) DO NOTHING`, jti, expiresAt)
+    if err != nil { return false, err }
+    return cmd.RowsAffected() == 1, nil
+}
+
+// 内存实现（测试用）
+type MemoryUserMFARepository struct {
+    mu    sync.RWMutex
+    items map[string]domain.UserMFA
+    used  map[string]time.Time // 已使用的待验证令牌 jti -> 过期时间
+}
+
+func NewMemoryUserMFARepository() *MemoryUserMFARepository {
+    return &MemoryUserMFARepository{items: map[string]domain.UserMFA{}, used: map[string]time.Time{}}
+}
+
+func (m *MemoryUserMFARepository) Get(ctx context.Context, userID string) (domain.UserMFA, error) {
+    m.mu.RLock(); defer m.mu.RUnlock()
+    it, ok := m.items[userID]
+    if !ok { return domain.UserMFA{}, ErrMFANotFound }
+    it.RecoveryCodes = append([]string(nil), it.RecoveryCodes...)
+    return it, nil
+}
+
+func (m *MemoryUserMFARepository) Save(ctx context.Context, it domain.UserMFA) error {
+    m.mu.Lock(); defer m.mu.Unlock()
+    now := time.Now().UTC()
+    if it.CreatedAt.IsZero() { it.CreatedAt = now }
+    it.UpdatedAt = now
+    it.RecoveryCodes = append([]string(nil), it.RecoveryCodes...)
+    m.items[it.UserID] = it
+    return nil
+}
+
+func (m *MemoryUserMFARepository) Delete(ctx context.Context, userID string) error {
+    m.mu.Lock(); defer m.mu.Unlock()
+    if _, ok := m.items[userID]; !ok { return ErrMFANotFound }
+    delete(m.items, userID)
+    return nil
+}
+
+func (m *MemoryUserMFARepository) Enable(ctx context.Context, it domain.UserMFA) (bool, error) {
+    m.mu.Lock(); defer m.mu.Unlock()
+    cur, ok := m.items[it.UserID]
+    if !ok || cur.Enabled || cur.Secret != it.Secret { return false, nil }
+    cur.Enabled, cur.LastUsedStep, cur.ConfirmedAt, cur.UpdatedAt = true, it.LastUsedStep, it.ConfirmedAt, time.Now().UTC()
+    cur.RecoveryCodes = append([]string(nil), it.RecoveryCodes...)
+    m.items[it.UserID] = cur
+    return true, nil
+}
+
+func (m *MemoryUserMFARepository) UseStep(ctx context.Context, userID string, step int64) (bool, error) {
+    m.mu.Lock(); defer m.mu.Unlock()
+    cur, ok := m.items[userID]
+    if !ok || !cur.Enabled || cur.LastUsedStep >= step { return false, nil }
+    cur.LastUsedStep, cur.UpdatedAt = step, time.Now().UTC()
+    m.items[userID] = cur
+    return true, nil
+}
+
+func (m *MemoryUserMFARepository) UseRecoveryCode(ctx context.Context, userID, hash string) (bool, error) {
+    m.mu.Lock(); defer m.mu.Unlock()
+    cur, ok := m.items[userID]
+    if !ok || !cur.Enabled { return false, nil }
+    for i, h := range cur.RecoveryCodes {
+        if h != hash { continue }
+        cur.RecoveryCodes = append(append([]string(nil), cur.RecoveryCodes[:i]...), cur.RecoveryCodes[i+1:]...)
+        cur.UpdatedAt = time.Now().UTC()
+        m.items[userID] = cur
+        return true, nil
+    }
+    return false, nil
+}
+
+func (m *MemoryUserMFARepository) ConsumeChallenge(ctx context.Context, jti string, expiresAt time.Time) (bool, error) {
+    m.mu.Lock(); defer m.mu.Unlock()
+    now := time.Now().UTC()
+    for k, exp := range m.used { if exp.Before(now) { delete(m.used, k) } }
+    if _, ok := m.used[jti]; ok { return false, nil }
+    m.used[jti] = expiresAt
+    return true, nil
+}
@@ -3,86 +3,177 @@ package repository
 import (
 	"context"
 	"errors"
+	"fmt"
 	"strings"
 	"time"
 
+	"github.com/YangYuS8/codyssey/backend/internal/db"
 	"github.com/YangYuS8/codyssey/backend/internal/domain"
+	"github.com/YangYuS8/codyssey/backend/internal/listquery"
 	"github.com/google/uuid"
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
 var ErrUserNotFound = errors.New("user not found")
 var ErrUserDuplicate = errors.New("username already exists")
+var ErrEmailDuplicate = errors.New("email already in use")
 
 type UserRepository interface {
     Create(ctx context.Context, u domain.User) error
+    // CreateBatch 在单个事务内插入全部用户，任一失败则整体回滚
+    CreateBatch(ctx context.Context, users []domain.User) error
     GetByID(ctx context.Context, id string) (domain.User, error)
     GetByUsername(ctx context.Context, username string) (domain.User, error)
+    GetByEmail(ctx context.Context, email string) (domain.User, error)
     UpdateRoles(ctx context.Context, id string, roles []string) error
+    // UpdateProfile 更新 email / email_verified / display_name / school / student_number
+    UpdateProfile(ctx context.Context, u domain.User) error
+    UpdatePassword(ctx context.Context, id, hash string, changedAt time.Time) error
     Delete(ctx context.Context, id string) error
-    List(ctx context.Context, limit, offset int) ([]domain.User, error)
+    List(ctx context.Context, spec listquery.Spec) ([]domain.User, string, error)
+}
+
+// UserListSchema 用户列表可用的过滤与排序字段，默认按创建时间倒序。
+var UserListSchema = &listquery.Schema{
+    Fields: []listquery.Field{
+        {Name: "id", Column: "id", Type: listquery.String, Sortable: true},
+        {Name: "username", Column: "username", Type: listquery.String, Ops: []listquery.Op{listquery.OpEq, listquery.OpIn}, Sortable: true},
+        {Name: "created_at", Column: "created_at", Type: listquery.Time, Ops: []listquery.Op{listquery.OpGt, listquery.OpGte, listquery.OpLt, listquery.OpLte}, Sortable: true},
+    },
+    Key:         "id",
+    DefaultSort: "-created_at",
+}
+
+func userValue(u domain.User, field string) any {
+    switch field {
+    case "id":
+        return u.ID
+    case "username":
+        return u.Username
+    }
+    return u.CreatedAt
 }
 
 type PGUserRepository struct { pool *pgxpool.Pool }
 
 func NewPGUserRepository(pool *pgxpool.Pool) *PGUserRepository { return &PGUserRepository{pool: pool} }
 
+const userColumns = `id, username, roles, created_at, COALESCE(password_hash,''), COALESCE(email,''), email_verified, display_name, school, student_number, password_changed_at`
+
+func scanUser(row interface{ Scan(dest ...any) error }) (domain.User, error) {
+    var u domain.User
+    err := row.Scan(&u.ID, &u.Username, &u.Roles, &u.CreatedAt, &u.PasswordHash, &u.Email, &u.EmailVerified, &u.DisplayName, &u.School, &u.StudentNumber, &u.PasswordChangedAt)
+    return u, err
+}
+
+// mapUniqueErr 区分用户名与邮箱唯一约束冲突。
+func mapUniqueErr(err error) error {
+    msg := strings.ToLower(err.Error())
+    if !strings.Contains(msg, "unique") { return err }
+    if strings.Contains(msg, "email") { return ErrEmailDuplicate }
+    return ErrUserDuplicate
+}
+
 func (r *PGUserRepository) Create(ctx context.Context, u domain.User) error {
+    ctx = db.WithOperation(ctx, "user.create")
     if u.ID == "" { u.ID = uuid.New().String() }
     if u.CreatedAt.IsZero() { u.CreatedAt = time.Now().UTC() }
-    _, err := r.pool.Exec(ctx, `INSERT INTO users (id, username, roles, created_at, password_hash) VALUES ($1,$2,$3,$4,$5)`,
-        u.ID, u.Username, u.Roles, u.CreatedAt, u.PasswordHash)
-    if err != nil {
-        if strings.Contains(strings.ToLower(err.Error()), "unique") { return ErrUserDuplicate }
-        return err
-    }
+    _, err := r.pool.Exec(ctx, `INSERT INTO users (id, username, roles, created_at, password_hash, email, email_verified, display_name, school, student_number)
+        VALUES ($1,$2,$3,$4,$5,NULLIF($6,''),$7,$8,$9,$10)`,
+        u.ID, u.Username, u.Roles, u.CreatedAt, u.PasswordHash, u.Email, u.EmailVerified, u.DisplayName, u.School, u.StudentNumber)
+    if err != nil { return mapUniqueErr(err) }
     return nil
 }
 
-func (r *PGUserRepository) GetByID(ctx context.Context, id string) (domain.User, error) {
-    row := r.pool.QueryRow(ctx, `SELECT id, username, roles, created_at, COALESCE(password_hash,'') FROM users WHERE id=$1`, id)
-    var u domain.User
-    if err := row.Scan(&u.ID, &u.Username, &u.Roles, &u.CreatedAt, &u.PasswordHash); err != nil {
-        if strings.Contains(err.Error(), "no rows") { return domain.User{}, ErrUserNotFound }
-        return domain.User{}, err
+func (r *PGUserRepository) CreateBatch(ctx context.Context, users []domain.User) error {
+    ctx = db.WithOperation(ctx, "user.create_batch")
+    tx, err := r.pool.Begin(ctx)
+    if err != nil { return err }
+    defer func() { _ = tx.Rollback(ctx) }()
+    now := time.Now().UTC()
+    for i, u := range users {
+        if u.ID == "" { u.ID = uuid.New().String() }
+        if u.CreatedAt.IsZero() { u.CreatedAt = now }
+        _, err := tx.Exec(ctx, `INSERT INTO users (id, username, roles, created_at, password_hash, email, email_verified, display_name, school, student_number)
+            VALUES ($1,$2,$3,$4,$5,NULLIF($6,''),$7,$8,$9,$10)`,
+            u.ID, u.Username, u.Roles, u.CreatedAt, u.PasswordHash, u.Email, u.EmailVerified, u.DisplayName, u.School, u.StudentNumber)
+        if err != nil { return fmt.Errorf("user %d (%s): %w", i, u.Username, mapUniqueErr(err)) }
     }
-    return u, nil
+    return tx.Commit(ctx)
 }
 
-func (r *PGUserRepository) GetByUsername(ctx context.Context, username string) (domain.User, error) {
-    row := r.pool.QueryRow(ctx, `SELECT id, username, roles, created_at, COALESCE(password_hash,'') FROM users WHERE username=$1`, username)
-    var u domain.User
-    if err := row.Scan(&u.ID, &u.Username, &u.Roles, &u.CreatedAt, &u.PasswordHash); err != nil {
+func (r *PGUserRepository) getOne(ctx context.Context, where string, arg any) (domain.User, error) {
+    u, err := scanUser(r.pool.QueryRow(ctx, `SELECT `+userColumns+` FROM users WHERE `+where, arg))
+    if err != nil {
         if strings.Contains(err.Error(), "no rows") { return domain.User{}, ErrUserNotFound }
         return domain.User{}, err
     }
     return u, nil
 }
 
+func (r *PGUserRepository) GetByID(ctx context.Context, id string) (domain.User, error) {
+    ctx = db.WithOperation(ctx, "user.get_by_id")
+    return r.getOne(ctx, `id=$1`, id)
+}
+
+func (r *PGUserRepository) GetByUsername(ctx context.Context, username string) (domain.User, error) {
+    ctx = db.WithOperation(ctx, "user.get_by_username")
+    return r.getOne(ctx, `username=$1`, username)
+}
+
+func (r *PGUserRepository) GetByEmail(ctx context.Context, email string) (domain.User, error) {
+    ctx = db.WithOperation(ctx, "user.get_by_email")
+    return r.getOne(ctx, `LOWER(email)=LOWER($1)`, email)
+}
+
 func (r *PGUserRepository) UpdateRoles(ctx context.Context, id string, roles []string) error {
+    ctx = db.WithOperation(ctx, "user.update_roles")
     cmd, err := r.pool.Exec(ctx, `UPDATE users SET roles=$1 WHERE id=$2`, roles, id)
     if err != nil { return err }
     if cmd.RowsAffected() == 0 { return ErrUserNotFound }
     return nil
 }
 
+func (r *PGUserRepository) UpdateProfile(ctx context.Context, u domain.User) error {
+    ctx = db.WithOperation(ctx, "user.update_profile")
+    cmd, err := r.pool.Exec(ctx, `UPDATE users SET email=NULLIF($2,''), email_verified=$3, display_name=$4, school=$5, student_number=$6 WHERE id=$1`,
+        u.ID, u.Email, u.EmailVerified, u.DisplayName, u.School, u.StudentNumber)
+    if err != nil { return mapUniqueErr(err) }
+    if cmd.RowsAffected() == 0 { return ErrUserNotFound }
+    return nil
+}
+
+func (r *PGUserRepository) UpdatePassword(ctx context.Context, id, hash string, changedAt time.Time) error {
+    ctx = db.WithOperation(ctx, "user.update_password")
+    cmd, err := r.pool.Exec(ctx, `UPDATE users SET password_hash=$2, password_changed_at=$3 WHERE id=$1`, id, hash, changedAt)
+    if err != nil { return err }
+    if cmd.RowsAffected() == 0 { return ErrUserNotFound }
+    return nil
+}
+
 func (r *PGUserRepository) Delete(ctx context.Context, id string) error {
+    ctx = db.WithOperation(ctx, "user.delete")
     cmd, err := r.pool.Exec(ctx, `DELETE FROM users WHERE id=$1`, id)
     if err != nil { return err }
     if cmd.RowsAffected() == 0 { return ErrUserNotFound }
     return nil
 }
 
-func (r *PGUserRepository) List(ctx context.Context, limit, offset int) ([]domain.User, error) {
-    if limit <= 0 { limit = 20 }
-    rows, err := r.pool.Query(ctx, `SELECT id, username, roles, created_at FROM users ORDER BY created_at DESC LIMIT $1 OFFSET $2`, limit, offset)
-    if err != nil { return nil, err }
+func (r *PGUserRepository) List(ctx context.Context, spec listquery.Spec) ([]domain.User, string, error) {
+    ctx = db.WithOperation(ctx, "user.list")
+    q, args, err := spec.SelectSQL(`SELECT ` + userColumns + ` FROM users`)
+    if err != nil { return nil, "", err }
+    rows, err := r.pool.Query(ctx, q, args...)
+    if err != nil { return nil, "", err }
     defer rows.Close()
     res := make([]domain.User, 0)
     for rows.Next() {
-        var u domain.User
-        if err := rows.Scan(&u.ID, &u.Username, &u.Roles, &u.CreatedAt); err != nil { return nil, err }
+        u, err := scanUser(rows)
+        if err != nil { return nil, "", err }
+        u.PasswordHash = ""
         res = append(res, u)
     }
-    return res, rows.Err()
+    if err := rows.Err(); err != nil { return nil, "", err }
+    res, next := listquery.Page(spec, res, userValue)
+    return res, next, nil
 }
@@ -0,0 +1,84 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/YangYuS8/codyssey/backend/internal/domain"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+var ErrUserTokenInvalid = errors.New("token invalid, expired or already used")
+
+// UserTokenRepository 一次性令牌存储。Consume 原子地校验未使用、未过期并标记已用。
+type UserTokenRepository interface {
+    Create(ctx context.Context, t domain.UserToken) error
+    Consume(ctx context.Context, purpose, hash string, now time.Time) (domain.UserToken, error)
+    // InvalidateUser 作废用户某用途下所有未使用的令牌（重新申请 / 密码已修改时）
+    InvalidateUser(ctx context.Context, userID, purpose string, now time.Time) error
+}
+
+type PGUserTokenRepository struct { pool *pgxpool.Pool }
+
+func NewPGUserTokenRepository(pool *pgxpool.Pool) *PGUserTokenRepository { return &PGUserTokenRepository{pool: pool} }
+
+func (r *PGUserTokenRepository) Create(ctx context.Context, t domain.UserToken) error {
+    if t.CreatedAt.IsZero() { t.CreatedAt = time.Now().UTC() }
+    _, err := r.pool.Exec(ctx, `INSERT INTO user_tokens (id, user_id, purpose, token_hash, email, expires_at, created_at) VALUES ($1,$2,$3,$4,$5,$6,$7)`,
+        t.ID, t.UserID, t.Purpose, t.Hash, t.Email, t.ExpiresAt, t.CreatedAt)
+    return err
+}
+
+func (r *PGUserTokenRepository) Consume(ctx context.Context, purpose, hash string, now time.Time) (domain.UserToken, error) {
+    row := r.pool.QueryRow(ctx, `UPDATE user_tokens SET used_at=$3
+        WHERE token_hash=$1 AND purpose=$2 AND used_at IS NULL AND expires_at > $3
+        RETURNING id, user_id, purpose, token_hash, email, expires_at, used_at, created_at`, hash, purpose, now)
+    var t domain.UserToken
+    if err := row.Scan(&t.ID, &t.UserID, &t.Purpose, &t.Hash, &t.Email, &t.ExpiresAt, &t.UsedAt, &t.CreatedAt); err != nil {
+        if strings.Contains(err.Error(), "no rows") { return domain.UserToken{}, ErrUserTokenInvalid }
+        return domain.UserToken{}, err
+    }
+    return t, nil
+}
+
+func (r *PGUserTokenRepository) InvalidateUser(ctx context.Context, userID, purpose string, now time.Time) error {
+    _, err := r.pool.Exec(ctx, `UPDATE user_tokens SET used_at=$3 WHERE user_id=$1 AND purpose=$2 AND used_at IS NULL`, userID, purpose, now)
+    return err
+}
+
+// 内存实现（测试用）
+type MemoryUserTokenRepository struct {
+    mu    sync.Mutex
+    items []domain.UserToken
+}
+
+func NewMemoryUserTokenRepository() *MemoryUserTokenRepository { return &MemoryUserTokenRepository{} }
+
+func (m *MemoryUserTokenRepository) Create(ctx context.Context, t domain.UserToken) error {
+    m.mu.Lock(); defer m.mu.Unlock()
+    if t.CreatedAt.IsZero() { t.CreatedAt = time.Now().UTC() }
+    m.items = append(m.items, t)
+    return nil
+}
+
+func (m *MemoryUserTokenRepository) Consume(ctx context.Context, purpose, hash string, now time.Time) (domain.UserToken, error) {
+    m.mu.Lock(); defer m.mu.Unlock()
+    for i, t := range m.items {
+        if t.Hash != hash || t.Purpose != purpose { continue }
+        if t.UsedAt != nil || !now.Before(t.ExpiresAt) { return domain.UserToken{}, ErrUserTokenInvalid }
+        m.items[i].UsedAt = &now
+        return m.items[i], nil
+    }
+    return domain.UserToken{}, ErrUserTokenInvalid
+}
+
+func (m *MemoryUserTokenRepository) InvalidateUser(ctx context.Context, userID, purpose string, now time.Time) error {
+    m.mu.Lock(); defer m.mu.Unlock()
+    for i, t := range m.items {
+        if t.UserID == userID && t.Purpose == purpose && t.UsedAt == nil { m.items[i].UsedAt = &now }
+    }
+    return nil
+}
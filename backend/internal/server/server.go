@@ -14,11 +14,24 @@ import (
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
 
+	"github.com/YangYuS8/codyssey/backend/internal/aiclient"
 	"github.com/YangYuS8/codyssey/backend/internal/auth"
 	"github.com/YangYuS8/codyssey/backend/internal/config"
 	"github.com/YangYuS8/codyssey/backend/internal/db"
+	"github.com/YangYuS8/codyssey/backend/internal/events"
+	"github.com/YangYuS8/codyssey/backend/internal/featureflag"
+	"github.com/YangYuS8/codyssey/backend/internal/health"
+	"github.com/YangYuS8/codyssey/backend/internal/http/middleware"
 	"github.com/YangYuS8/codyssey/backend/internal/http/router"
+	"github.com/YangYuS8/codyssey/backend/internal/mail"
+	"github.com/YangYuS8/codyssey/backend/internal/metrics"
+	"github.com/YangYuS8/codyssey/backend/internal/ratelimit"
+	"github.com/YangYuS8/codyssey/backend/internal/realtime"
 	"github.com/YangYuS8/codyssey/backend/internal/repository"
+	"github.com/YangYuS8/codyssey/backend/internal/service"
+	"github.com/YangYuS8/codyssey/backend/internal/settings"
+	"github.com/YangYuS8/codyssey/backend/internal/tracing"
+	_ "github.com/YangYuS8/codyssey/backend/migrations" // 注册 Go 编写的 goose 迁移
 	_ "github.com/jackc/pgx/v5/stdlib" // register pgx driver for database/sql
 	"github.com/pressly/goose/v3"
 )
@@ -26,28 +39,39 @@ import (
 type Server struct {
 	cfg    config.Config
 	logger *zap.Logger
+	level  zap.AtomicLevel // 运行时参数 log_level 变更时调整
 	http   *http.Server
 	db     *db.Database
+	stopTracing func(context.Context) error
+	readiness   *health.Registry
+	stopEvents  context.CancelFunc          // 取消事件监听、运行时参数监听与 AI 检测协程
+	aiDetection *service.AIDetectionService // 停机时等待检测协程退出后再关闭数据库
 }
 
 type healthProbe struct { s *Server }
-func (h healthProbe) DBAlive() bool { return h.s != nil && h.s.db != nil }
+func (h healthProbe) DBAlive() bool {
+	if h.s == nil || h.s.db == nil { return false }
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	return h.s.db.Pool.Ping(ctx) == nil
+}
 
 func New(cfg config.Config) (*Server, error) {
 	if err := cfg.Validate(); err != nil { return nil, err }
-	logger, err := buildLogger(cfg)
+	level := zap.NewAtomicLevel()
+	logger, err := buildLogger(cfg, level)
 	if err != nil { return nil, err }
-	return &Server{cfg: cfg, logger: logger}, nil
+	zap.ReplaceGlobals(logger) // logging.FromContext 在请求上下文之外的兜底
+	return &Server{cfg: cfg, logger: logger, level: level}, nil
 }
 
-func buildLogger(cfg config.Config) (*zap.Logger, error) {
-	var level zapcore.Level
+func buildLogger(cfg config.Config, level zap.AtomicLevel) (*zap.Logger, error) {
 	switch cfg.LogLevel {
-	case "debug": level = zap.DebugLevel
-	case "info": level = zap.InfoLevel
-	case "warn": level = zap.WarnLevel
-	case "error": level = zap.ErrorLevel
-	default: level = zap.InfoLevel
+	case "debug": level.SetLevel(zap.DebugLevel)
+	case "info": level.SetLevel(zap.InfoLevel)
+	case "warn": level.SetLevel(zap.WarnLevel)
+	case "error": level.SetLevel(zap.ErrorLevel)
+	default: level.SetLevel(zap.InfoLevel)
 	}
 	encCfg := zap.NewProductionEncoderConfig()
 	encCfg.TimeKey = "ts"
@@ -67,8 +91,17 @@ func (s *Server) Start(ctx context.Context) error {
 		zap.Int("max_submission_code_bytes", s.cfg.MaxSubmissionCodeBytes),
 		zap.Int("max_request_body_bytes", s.cfg.MaxRequestBodyBytes),
 	)
+	// 0. 链路追踪（需在建立连接池之前，pgx 钩子依赖全局 TracerProvider）
+	stopTracing, err := tracing.Setup(ctx, tracing.Options{Exporter: s.cfg.Tracing.Exporter, Endpoint: s.cfg.Tracing.Endpoint,
+		Insecure: s.cfg.Tracing.Insecure, SampleRatio: s.cfg.Tracing.SampleRatio, ServiceName: s.cfg.Tracing.ServiceName,
+		Version: s.cfg.Version, Env: s.cfg.Env}, s.logger)
+	if err != nil { return fmt.Errorf("tracing setup: %w", err) }
+	s.stopTracing = stopTracing
+	if s.cfg.Tracing.Exporter != tracing.ExporterNone {
+		s.logger.Info("tracing enabled", zap.String("exporter", s.cfg.Tracing.Exporter), zap.Float64("sample_ratio", s.cfg.Tracing.SampleRatio))
+	}
 	// 1. 连接数据库
-	database, err := db.Connect(ctx, s.cfg.DB.ConnString())
+	database, err := db.Connect(ctx, s.cfg.DB.ConnString(), db.Options{SlowQueryThreshold: s.cfg.DB.SlowQueryThreshold})
 	if err != nil { return err }
 	s.db = database
 
@@ -81,29 +114,155 @@ func (s *Server) Start(ctx context.Context) error {
 		s.logger.Info("auto migrate disabled; skip applying migrations", zap.Bool("auto_migrate", s.cfg.AutoMigrate))
 	}
 
-	// 3. 初始化仓库 & 路由
+	// 3. 就绪检查：数据库连通、迁移版本、外部依赖
+	s.readiness = health.NewRegistry(s.cfg.Health.CheckTimeout)
+	s.readiness.Register("postgres", health.PingCheck(database.Pool))
+	if want, err := latestMigration(migrationsDir); err == nil {
+		s.readiness.Register("migrations", health.MigrationCheck(database.Pool, want))
+	} else {
+		s.logger.Warn("migration readiness check disabled", zap.String("dir", migrationsDir), zap.Error(err))
+	}
+	for name, url := range s.cfg.Health.HTTPChecks { s.readiness.Register(name, health.HTTPCheck(nil, url)) }
+	s.readiness.SetOptional(s.cfg.Health.OptionalChecks...)
+
+	// 4. 初始化仓库 & 路由
 	problemRepo := repository.NewPGProblemRepository(database.Pool)
 	userRepo := repository.NewPGUserRepository(database.Pool)
 	submissionRepo := repository.NewPGSubmissionRepository(database.Pool)
 	judgeRunRepo := repository.NewPGJudgeRunRepository(database.Pool)
+	metrics.RegisterJudgeQueue(func(ctx context.Context) (metrics.JudgeQueueSnapshot, error) {
+		st, err := judgeRunRepo.QueueStats(ctx)
+		if err != nil { return metrics.JudgeQueueSnapshot{}, err }
+		snap := metrics.JudgeQueueSnapshot{Depth: map[string]int{"queued": st.Queued, "running": st.Running}}
+		if st.OldestQueuedAt != nil { snap.OldestQueued = *st.OldestQueuedAt }
+		return snap, nil
+	}, metrics.JudgeQueueOptions{TTL: s.cfg.Judge.QueueMetricsTTL, WorkerCapacity: s.cfg.Judge.WorkerCapacity})
 	statusLogRepo := repository.NewPGSubmissionStatusLogRepository(database.Pool)
-	jwtMgr := auth.NewJWTManager(os.Getenv("JWT_SECRET"), 15*time.Minute, 7*24*time.Hour)
-	authService := auth.NewAuthService(userRepo, jwtMgr)
+	jwtMgr := auth.NewJWTManager(s.cfg.JWTSecret, 15*time.Minute, 7*24*time.Hour)
+	providers := []auth.CredentialProvider{auth.NewLocalProvider(userRepo)}
+	if s.cfg.LDAP.Enabled() {
+		ldapCfg := auth.LDAPConfig{
+			URL: s.cfg.LDAP.URL, StartTLS: s.cfg.LDAP.StartTLS,
+			BindDN: s.cfg.LDAP.BindDN, BindPassword: s.cfg.LDAP.BindPassword,
+			BaseDN: s.cfg.LDAP.BaseDN, UserFilter: s.cfg.LDAP.UserFilter, GroupAttr: s.cfg.LDAP.GroupAttr,
+			GroupRoles: auth.ParseGroupRoles(s.cfg.LDAP.GroupRoles), DefaultRoles: s.cfg.LDAP.DefaultRoles,
+		}
+		providers = append(providers, auth.NewLDAPProvider(ldapCfg, nil, userRepo))
+		s.logger.Info("ldap credential provider enabled", zap.String("url", s.cfg.LDAP.URL), zap.String("base_dn", s.cfg.LDAP.BaseDN))
+	}
+	authService := auth.NewAuthService(userRepo, jwtMgr, providers...)
+	mfaPolicy := auth.DefaultMFAPolicy()
+	mfaPolicy.RequiredRoles = s.cfg.MFARequiredRoles
+	authService.EnableMFA(repository.NewPGUserMFARepository(database.Pool), mfaPolicy)
+	lockoutPolicy := auth.DefaultLockoutPolicy()
+	lockoutPolicy.UsernameMaxFailures = s.cfg.Lockout.MaxFailures
+	lockoutPolicy.IPMaxFailures = s.cfg.Lockout.IPMaxFailures
+	lockoutPolicy.Window = s.cfg.Lockout.Window
+	lockoutPolicy.LockoutDuration = s.cfg.Lockout.Duration
+	authService.EnableLockout(repository.NewPGLoginAttemptRepository(database.Pool), lockoutPolicy)
+	var mailer mail.Sender
+	switch s.cfg.Mail.Sender {
+	case "log":
+		mailer = mail.NewLogSender(s.logger)
+	case "file":
+		mailer = mail.NewFileSender(s.cfg.Mail.FileDir)
+	case "smtp":
+		mailer = &mail.SMTPSender{Addr: s.cfg.Mail.SMTPAddr, From: s.cfg.Mail.From, Username: s.cfg.Mail.SMTPUsername, Password: s.cfg.Mail.SMTPPassword}
+	}
+	if mailer != nil { s.logger.Info("mail sender enabled", zap.String("sender", s.cfg.Mail.Sender)) }
+	var aiClient *aiclient.Client
+	if s.cfg.AI.Enabled() {
+		aiClient, err = aiclient.New(aiclient.Options{BaseURL: s.cfg.AI.URL, Timeout: s.cfg.AI.Timeout, MaxRetries: s.cfg.AI.MaxRetries,
+			RetryBackoff: s.cfg.AI.RetryBackoff, BreakerThreshold: s.cfg.AI.BreakerThreshold, BreakerCooldown: s.cfg.AI.BreakerCooldown})
+		if err != nil { return err }
+		s.logger.Info("ai client enabled", zap.String("url", s.cfg.AI.URL), zap.Duration("timeout", s.cfg.AI.Timeout), zap.Int("max_retries", s.cfg.AI.MaxRetries))
+	}
+	rateLimits := map[string]ratelimit.Policy{}
+	for group, spec := range s.cfg.RateLimit.Policies() {
+		p, _ := ratelimit.ParsePolicy(group, spec) // Validate 已校验
+		p.ByIP = group == ratelimit.GroupLogin
+		rateLimits[group] = p
+	}
+	var rateLimitStore ratelimit.Store = ratelimit.NewMemoryStore()
+	if s.cfg.RateLimit.Store == "postgres" { rateLimitStore = repository.NewPGRateLimitStore(database.Pool) }
+	var idemRepo repository.IdempotencyRepository = repository.NewMemoryIdempotencyRepository()
+	if s.cfg.Idempotency.Store == "postgres" { idemRepo = repository.NewPGIdempotencyRepository(database.Pool) }
+	// 事件经 LISTEN/NOTIFY 在实例间扇出；监听协程随停机取消
+	hub := events.NewHub()
+	eventsCtx, stopEvents := context.WithCancel(context.Background())
+	s.stopEvents = stopEvents
+	hub.UsePostgres(eventsCtx, database.Pool, s.logger)
+	broker := realtime.NewBroker()
+	broker.UseHub(eventsCtx, hub)
+	// 监听重连期间各实例同时失败，若为关键检查会令整个集群 /readyz 503；降级为非关键，NOTIFY 失败时本已退化为本实例投递
+	s.readiness.RegisterOptional("events", hub.Check)
+	// 运行时参数：启动配置为默认值，数据库覆盖值热更新；日志级别在此订阅，限流与代码长度上限由路由按请求读取
+	rtSettings, err := settings.NewStore(ctx, repository.NewPGRuntimeSettingRepository(database.Pool), settings.Defaults{
+		MaxSubmissionCodeBytes: s.cfg.MaxSubmissionCodeBytes, LogLevel: s.cfg.LogLevel, RateLimits: s.cfg.RateLimit.Policies(),
+	}, s.logger)
+	if err != nil { stopEvents(); return err }
+	rtSettings.Subscribe(func(snap *settings.Snapshot) {
+		if snap.LogLevel() != s.level.Level() {
+			s.logger.Info("log level changed", zap.Stringer("level", snap.LogLevel()), zap.Int64("settings_version", snap.Version))
+			s.level.SetLevel(snap.LogLevel())
+		}
+	})
+	go rtSettings.Watch(eventsCtx, s.cfg.Settings.PollInterval)
+	if s.cfg.Settings.File != "" {
+		go rtSettings.WatchFile(eventsCtx, s.cfg.Settings.File, 2*time.Second)
+		s.logger.Info("runtime settings file watch enabled", zap.String("path", s.cfg.Settings.File))
+	}
+	// 提交的 AI 代码检测：后台协程随停机取消，未完成的检测在租约到期后由任一实例重新认领
+	var aiDetection *service.AIDetectionService
+	if aiClient != nil {
+		aiDetection = service.NewAIDetectionService(repository.NewPGSubmissionAICheckRepository(database.Pool), aiClient, service.AIDetectionOptions{Workers: s.cfg.AI.DetectWorkers, QueueSize: s.cfg.AI.DetectQueueSize})
+		aiDetection.UseSubmissionCode(func(ctx context.Context, id string) (string, error) {
+			sub, err := submissionRepo.GetByID(ctx, id)
+			return sub.Code, err
+		})
+		aiDetection.Start(eventsCtx)
+		s.aiDetection = aiDetection
+	}
 	deps := router.Dependencies{
 		ProblemRepo:            problemRepo,
+		ProblemTagRepo:         problemRepo,
+		ProblemRevisionRepo:    problemRepo,
+		ProblemReviewRepo:      problemRepo,
+		AIClient:               aiClient,
+		AIDetection:            aiDetection,
 		UserRepo:               userRepo,
+		UserTokenRepo:          repository.NewPGUserTokenRepository(database.Pool),
+		Mailer:                 mailer,
+		PublicBaseURL:          s.cfg.Mail.PublicBaseURL,
 		AuthService:            authService,
+		APITokens:              auth.NewAPITokenService(repository.NewPGAPITokenRepository(database.Pool), userRepo),
 		SubmissionRepo:         submissionRepo,
 		SubmissionStatusLogRepo: statusLogRepo,
 		JudgeRunRepo:           judgeRunRepo,
+		RateLimitStore:         rateLimitStore,
+		RateLimits:             rateLimits,
+		IdempotencyRepo:        idemRepo,
+		IdempotencyTTL:         s.cfg.Idempotency.TTL,
+		Events:                 hub,
+		Realtime:               broker,
+		Logger:                 s.logger,
+		AccessLog:              middleware.AccessLogOptions{SampleRatio: s.cfg.AccessLog.SampleRatio, SlowThreshold: s.cfg.AccessLog.SlowThreshold, Details: s.cfg.AccessLog.Details},
 		HealthCheck:            healthProbe{s: s},
+		Readiness:              s.readiness,
+		JWTSecret:              s.cfg.JWTSecret,
+		MaxRequestBodyBytes:    s.cfg.MaxRequestBodyBytes,
+		MaxSubmissionCodeBytes: s.cfg.MaxSubmissionCodeBytes,
+		Settings:               rtSettings,
+		FeatureFlags:           featureflag.NewService(repository.NewPGFeatureFlagStore(database.Pool), featureflag.Options{CacheTTL: s.cfg.FeatureFlags.CacheTTL, Logger: s.logger}),
 		Version:                s.cfg.Version,
 		Env:                    s.cfg.Env,
 	}
 	r := router.Setup(deps)
 
-	// 4. 启动 HTTP Server
+	// 5. 启动 HTTP Server
 	s.http = &http.Server{Addr: ":" + s.cfg.Port, Handler: r}
+	// Shutdown 不会中断进行中的请求：主动结束 SSE 长连接
+	s.http.RegisterOnShutdown(func() { stopEvents(); hub.Close(); broker.Close() })
 	go func() {
 		s.logger.Info("http server starting", zap.String("addr", s.http.Addr))
 		if err := s.http.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
@@ -113,10 +272,21 @@ func (s *Server) Start(ctx context.Context) error {
 	return nil
 }
 
+// migrationsDir 运行时当前工作目录是在 backend (Makefile: cd backend && go run .)
+var migrationsDir = filepath.Join("migrations")
+
+// latestMigration 代码携带的最新迁移版本（就绪检查比对用）
+func latestMigration(dir string) (int64, error) {
+	ms, err := goose.CollectMigrations(dir, 0, goose.MaxVersion)
+	if err != nil { return 0, err }
+	last, err := ms.Last()
+	if err != nil { return 0, err }
+	return last.Version, nil
+}
+
 // runMigrations 使用 goose 执行 backend/migrations 下的所有 Up 迁移
 func (s *Server) runMigrations() error {
-	// 运行时当前工作目录是在 backend (Makefile: cd backend && go run .)
-	dir := filepath.Join("migrations")
+	dir := migrationsDir
 	s.logger.Info("running migrations", zap.String("driver", "pgx"), zap.String("dir", dir))
 	// 允许多次调用，goose 会记录版本
 	goose.SetLogger(goose.NopLogger()) // 静默；我们用 zap 记录
@@ -136,11 +306,23 @@ func (s *Server) WaitForShutdown() {
 	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
 	<-quit
 	s.logger.Info("shutdown signal received")
+	// 先让 /readyz 返回 503，等待负载均衡器摘除实例后再停止接收连接
+	if s.readiness != nil {
+		s.readiness.Drain()
+		if d := s.cfg.Health.DrainDelay; d > 0 {
+			s.logger.Info("draining before shutdown", zap.Duration("delay", d))
+			time.Sleep(d)
+		}
+	}
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 	if s.http != nil {
 		_ = s.http.Shutdown(ctx)
 	}
+	// 检测协程可能正在写结果：先取消并等待其退出，再关闭连接池
+	if s.stopEvents != nil { s.stopEvents() }
+	if s.aiDetection != nil { s.aiDetection.Wait() }
 	if s.db != nil { s.db.Close() }
+	if s.stopTracing != nil { _ = s.stopTracing(ctx) }
 	_ = s.logger.Sync()
 }
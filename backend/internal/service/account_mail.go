@@ -0,0 +1,146 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/YangYuS8/codyssey/backend/internal/auth"
+	"github.com/YangYuS8/codyssey/backend/internal/domain"
+	"github.com/YangYuS8/codyssey/backend/internal/mail"
+	"github.com/google/uuid"
+)
+
+// UserTokenRepo 一次性令牌存储（repository.UserTokenRepository 的子集）。
+type UserTokenRepo interface {
+    Create(ctx context.Context, t domain.UserToken) error
+    Consume(ctx context.Context, purpose, hash string, now time.Time) (domain.UserToken, error)
+    InvalidateUser(ctx context.Context, userID, purpose string, now time.Time) error
+}
+
+// MailOptions 邮件链接与令牌有效期。
+type MailOptions struct {
+    PublicBaseURL string // 前端地址，邮件链接指向 {base}/verify-email?token=... 与 {base}/reset-password?token=...
+    VerifyTTL     time.Duration
+    ResetTTL      time.Duration
+}
+
+type accountMail struct {
+    tokens UserTokenRepo
+    sender mail.Sender
+    opts   MailOptions
+}
+
+// EnableMail 启用邮箱验证与找回密码。
+func (s *UserService) EnableMail(tokens UserTokenRepo, sender mail.Sender, opts MailOptions) {
+    if opts.VerifyTTL <= 0 { opts.VerifyTTL = 24 * time.Hour }
+    if opts.ResetTTL <= 0 { opts.ResetTTL = time.Hour }
+    opts.PublicBaseURL = strings.TrimRight(opts.PublicBaseURL, "/")
+    s.account = &accountMail{tokens: tokens, sender: sender, opts: opts}
+}
+
+func (s *UserService) MailEnabled() bool { return s.account != nil }
+
+func hashUserToken(raw string) string {
+    sum := sha256.Sum256([]byte(raw))
+    return hex.EncodeToString(sum[:])
+}
+
+// issueToken 作废同用途旧令牌后签发新令牌，返回明文。
+func (a *accountMail) issueToken(ctx context.Context, u domain.User, purpose string, ttl time.Duration) (string, error) {
+    now := time.Now().UTC()
+    if err := a.tokens.InvalidateUser(ctx, u.ID, purpose, now); err != nil { return "", err }
+    buf := make([]byte, 32)
+    if _, err := rand.Read(buf); err != nil { return "", err }
+    raw := base64.RawURLEncoding.EncodeToString(buf)
+    t := domain.UserToken{ID: uuid.New().String(), UserID: u.ID, Purpose: purpose, Hash: hashUserToken(raw), Email: u.Email, ExpiresAt: now.Add(ttl), CreatedAt: now}
+    if err := a.tokens.Create(ctx, t); err != nil { return "", err }
+    return raw, nil
+}
+
+func (a *accountMail) link(path, token string) string {
+    return a.opts.PublicBaseURL + path + "?token=" + url.QueryEscape(token)
+}
+
+// RequestEmailVerification 向当前邮箱发送验证链接。
+func (s *UserService) RequestEmailVerification(ctx context.Context, userID string) error {
+    if s.account == nil { return ErrMailDisabled }
+    u, err := s.repo.GetByID(ctx, userID)
+    if err != nil { return err }
+    if u.Email == "" { return ErrNoEmail }
+    if u.EmailVerified { return ErrEmailAlreadyVerified }
+    raw, err := s.account.issueToken(ctx, u, domain.UserTokenEmailVerify, s.account.opts.VerifyTTL)
+    if err != nil { return err }
+    return s.account.sender.Send(ctx, mail.Message{
+        To: u.Email, Subject: "Codyssey 邮箱验证",
+        Body: fmt.Sprintf("%s，你好：\n\n请在 %s 内打开以下链接完成邮箱验证：\n%s\n\n如非本人操作请忽略。\n",
+            u.Username, s.account.opts.VerifyTTL, s.account.link("/verify-email", raw)),
+    })
+}
+
+// VerifyEmail 消费验证令牌；令牌签发后邮箱被修改则不生效。
+func (s *UserService) VerifyEmail(ctx context.Context, token string) (domain.User, error) {
+    if s.account == nil { return domain.User{}, ErrMailDisabled }
+    t, err := s.account.tokens.Consume(ctx, domain.UserTokenEmailVerify, hashUserToken(strings.TrimSpace(token)), time.Now().UTC())
+    if err != nil { return domain.User{}, err }
+    u, err := s.repo.GetByID(ctx, t.UserID)
+    if err != nil {
+        if errors.Is(err, ErrUserNotFound) { return domain.User{}, ErrTokenInvalid }
+        return domain.User{}, err
+    }
+    if !strings.EqualFold(u.Email, t.Email) { return domain.User{}, ErrTokenInvalid }
+    u.EmailVerified = true
+    if err := s.repo.UpdateProfile(ctx, u); err != nil { return domain.User{}, err }
+    return u, nil
+}
+
+// RequestPasswordReset 按用户名或邮箱发送重置链接。
+// 为避免枚举账号，用户不存在、邮箱未验证或无本地密码时同样返回 nil 且不发信。
+func (s *UserService) RequestPasswordReset(ctx context.Context, login string) error {
+    if s.account == nil { return ErrMailDisabled }
+    login = strings.TrimSpace(login)
+    if login == "" { return nil }
+    var (
+        u   domain.User
+        err error
+    )
+    if strings.Contains(login, "@") {
+        u, err = s.repo.GetByEmail(ctx, login)
+    } else {
+        u, err = s.repo.GetByUsername(ctx, login)
+    }
+    if err != nil {
+        if errors.Is(err, ErrUserNotFound) { return nil }
+        return err
+    }
+    // 未验证的邮箱可能填错，发过去等于把账号交给陌生人
+    if u.Email == "" || !u.EmailVerified || u.PasswordHash == "" { return nil }
+    raw, err := s.account.issueToken(ctx, u, domain.UserTokenPasswordReset, s.account.opts.ResetTTL)
+    if err != nil { return err }
+    return s.account.sender.Send(ctx, mail.Message{
+        To: u.Email, Subject: "Codyssey 重置密码",
+        Body: fmt.Sprintf("%s，你好：\n\n请在 %s 内打开以下链接设置新密码（链接仅可使用一次）：\n%s\n\n如非本人操作请忽略，原密码仍然有效。\n",
+            u.Username, s.account.opts.ResetTTL, s.account.link("/reset-password", raw)),
+    })
+}
+
+// ResetPasswordWithToken 消费找回密码令牌并设置新密码。
+func (s *UserService) ResetPasswordWithToken(ctx context.Context, token, newPassword string) error {
+    if s.account == nil { return ErrMailDisabled }
+    // 先校验强度，避免弱密码白白消耗一次性令牌
+    if err := auth.ValidatePassword(newPassword); err != nil { return err }
+    t, err := s.account.tokens.Consume(ctx, domain.UserTokenPasswordReset, hashUserToken(strings.TrimSpace(token)), time.Now().UTC())
+    if err != nil { return err }
+    if err := s.setPassword(ctx, t.UserID, newPassword); err != nil {
+        if errors.Is(err, ErrUserNotFound) { return ErrTokenInvalid }
+        return err
+    }
+    return nil
+}
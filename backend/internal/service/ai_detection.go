@@ -0,0 +1,206 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/YangYuS8/codyssey/backend/internal/aiclient"
+	"github.com/YangYuS8/codyssey/backend/internal/domain"
+	"github.com/YangYuS8/codyssey/backend/internal/listquery"
+	"github.com/YangYuS8/codyssey/backend/internal/logging"
+	"github.com/YangYuS8/codyssey/backend/internal/repository"
+	"github.com/YangYuS8/codyssey/backend/internal/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	"go.uber.org/zap"
+)
+
+// ErrInvalidDetectionScope 开关范围不是 problem，或范围 ID 为空。
+var ErrInvalidDetectionScope = errors.New("invalid ai detection scope")
+
+// SubmissionDetector 检测代码是否疑似 AI 生成（*aiclient.Client 实现）。
+type SubmissionDetector interface {
+    Detect(ctx context.Context, code string) (aiclient.Detection, error)
+}
+
+// AIDetectionOptions 后台检测队列参数；零值使用默认值。
+type AIDetectionOptions struct {
+    Workers   int           // 并发检测数，默认 2
+    QueueSize int           // 队列容量，默认 256；队列满时记录保持 running，租约到期后重新认领
+    Timeout   time.Duration // 单次检测（含重试）超时，默认 60s
+    Lease     time.Duration // 认领租约，默认 5m 且不小于 2 倍 Timeout；到期未完成的记录由任一实例重新认领
+}
+
+type detectionJob struct {
+    check domain.SubmissionAICheck
+    code  string
+}
+
+// AIDetectionService 提交后异步调用 AI 服务检测代码，结果写入 submission_ai_checks。
+// 检测与判题完全解耦：入队不阻塞、任何失败只记录在检测结果中，不影响提交与判题。
+type AIDetectionService struct {
+    repo     repository.SubmissionAICheckRepository
+    detector SubmissionDetector
+    opts     AIDetectionOptions
+    queue    chan detectionJob
+    codeOf   func(ctx context.Context, submissionID string) (string, error) // 认领未完成记录时读取代码
+    wg       sync.WaitGroup
+}
+
+func NewAIDetectionService(repo repository.SubmissionAICheckRepository, detector SubmissionDetector, o AIDetectionOptions) *AIDetectionService {
+    if o.Workers <= 0 { o.Workers = 2 }
+    if o.QueueSize <= 0 { o.QueueSize = 256 }
+    if o.Timeout <= 0 { o.Timeout = 60 * time.Second }
+    if o.Lease <= 0 { o.Lease = 5 * time.Minute }
+    if o.Lease < 2*o.Timeout { o.Lease = 2 * o.Timeout }
+    return &AIDetectionService{repo: repo, detector: detector, opts: o, queue: make(chan detectionJob, o.QueueSize)}
+}
+
+// UseSubmissionCode 认领未完成的检测需要重新读取提交代码（通常为 SubmissionService.Get）；未设置时不认领。
+func (s *AIDetectionService) UseSubmissionCode(fn func(ctx context.Context, submissionID string) (string, error)) { s.codeOf = fn }
+
+// Start 启动检测协程，并周期性认领未完成的记录（pending 或租约过期的 running，含其他实例遗留的）；
+// ctx 取消后协程退出，Wait 等待其结束。
+func (s *AIDetectionService) Start(ctx context.Context) {
+    for i := 0; i < s.opts.Workers; i++ {
+        s.wg.Add(1)
+        go func() {
+            defer s.wg.Done()
+            for {
+                select {
+                case <-ctx.Done():
+                    return
+                case job := <-s.queue:
+                    s.run(ctx, job)
+                }
+            }
+        }()
+    }
+    if s.codeOf != nil {
+        s.wg.Add(1)
+        go func() {
+            defer s.wg.Done()
+            ticker := time.NewTicker(s.opts.Lease / 2)
+            defer ticker.Stop()
+            for {
+                s.recover(ctx)
+                select {
+                case <-ctx.Done():
+                    return
+                case <-ticker.C:
+                }
+            }
+        }()
+    }
+}
+
+// Wait 等待检测协程退出（Start 的 ctx 取消后）。
+func (s *AIDetectionService) Wait() { s.wg.Wait() }
+
+func (s *AIDetectionService) recover(ctx context.Context) {
+    log := logging.FromContext(ctx)
+    // 只认领队列放得下的数量，避免认领后因队列满被记为 failed
+    free := cap(s.queue) - len(s.queue)
+    if free <= 0 || ctx.Err() != nil { return }
+    claimed, err := s.repo.ClaimChecks(ctx, free, s.opts.Lease)
+    if err != nil {
+        if ctx.Err() == nil { log.Warn("ai detection claim failed", zap.Error(err)) }
+        return
+    }
+    for _, c := range claimed {
+        code, err := s.codeOf(ctx, c.SubmissionID)
+        if err != nil { s.fail(ctx, c, "load submission: "+err.Error()); continue }
+        s.push(ctx, detectionJob{check: c, code: code})
+    }
+    if len(claimed) > 0 { log.Info("ai detection claimed unfinished checks", zap.Int("count", len(claimed))) }
+}
+
+// Enqueue 提交所属题目开启了检测时登记记录并入队；记录直接以 running 登记并持有租约，其他实例不会重复认领。
+// 不返回错误：开关查询或登记失败只记日志，队列满时留待租约到期后认领，均不影响提交。
+func (s *AIDetectionService) Enqueue(ctx context.Context, sub domain.Submission) {
+    log := logging.FromContext(ctx).With(zap.String("submission_id", sub.ID))
+    on, err := s.repo.DetectionEnabled(ctx, sub.ProblemID)
+    if err != nil { log.Warn("ai detection setting lookup failed", zap.Error(err)); return }
+    if !on { return }
+    now := time.Now().UTC()
+    until := now.Add(s.opts.Lease).Truncate(time.Microsecond) // 与数据库精度一致，FinishCheck 按租约比对
+    c := domain.SubmissionAICheck{SubmissionID: sub.ID, ProblemID: sub.ProblemID, UserID: sub.UserID, Status: domain.AICheckStatusRunning, CreatedAt: now, LeaseUntil: &until}
+    if err := s.repo.CreateCheck(ctx, c); err != nil { log.Warn("ai check create failed", zap.Error(err)); return }
+    s.push(ctx, detectionJob{check: c, code: sub.Code})
+}
+
+// push 入队；队列满时不写结果，记录保持 running，租约到期后由任一实例重新认领。
+func (s *AIDetectionService) push(ctx context.Context, job detectionJob) {
+    select {
+    case s.queue <- job:
+    default:
+        logging.FromContext(ctx).Warn("ai detection queue full; retry after lease expiry", zap.String("submission_id", job.check.SubmissionID))
+    }
+}
+
+// run 单个检测任务；使用独立超时，父 ctx 只用于停机取消。
+func (s *AIDetectionService) run(ctx context.Context, job detectionJob) {
+    ctx, end := tracing.Start(ctx, "AIDetectionService.Run", attribute.String("submission.id", job.check.SubmissionID), attribute.String("problem.id", job.check.ProblemID))
+    var err error
+    defer end(&err)
+    dctx, cancel := context.WithTimeout(ctx, s.opts.Timeout)
+    defer cancel()
+    var d aiclient.Detection
+    d, err = s.detector.Detect(dctx, job.code)
+    if err != nil {
+        if ctx.Err() != nil { return } // 停机：保持 running，租约到期后由任一实例重新认领
+        s.fail(ctx, job.check, err.Error())
+        return
+    }
+    now := time.Now().UTC()
+    c := job.check
+    c.Status, c.Score, c.Suspicious, c.Model, c.Error, c.CheckedAt = domain.AICheckStatusCompleted, d.Score, d.Suspicious, d.Model, "", &now
+    if c.Model == "" { c.Model = unknownGeneratorModel }
+    if err = s.repo.FinishCheck(ctx, c); err != nil { s.saveFailed(ctx, c, err) }
+}
+
+func (s *AIDetectionService) fail(ctx context.Context, c domain.SubmissionAICheck, reason string) {
+    now := time.Now().UTC()
+    c.Status, c.Error, c.CheckedAt = domain.AICheckStatusFailed, reason, &now
+    log := logging.FromContext(ctx).With(zap.String("submission_id", c.SubmissionID))
+    log.Warn("ai detection failed", zap.String("reason", reason))
+    if err := s.repo.FinishCheck(ctx, c); err != nil { s.saveFailed(ctx, c, err) }
+}
+
+// saveFailed 结果未写入；租约已被其他实例接手时丢弃本次结果，以接手实例的结果为准。
+func (s *AIDetectionService) saveFailed(ctx context.Context, c domain.SubmissionAICheck, err error) {
+    log := logging.FromContext(ctx).With(zap.String("submission_id", c.SubmissionID))
+    if errors.Is(err, repository.ErrAICheckLeaseLost) { log.Info("ai check lease lost; result discarded"); return }
+    log.Warn("ai check save failed", zap.Error(err))
+}
+
+// Get 提交的检测结果；未检测返回 repository.ErrNotFound。
+func (s *AIDetectionService) Get(ctx context.Context, submissionID string) (domain.SubmissionAICheck, error) {
+    return s.repo.GetCheck(ctx, submissionID)
+}
+
+// ProblemReport 单题检测汇总与分页明细。
+func (s *AIDetectionService) ProblemReport(ctx context.Context, problemID string, spec listquery.Spec) (_ domain.AICheckSummary, _ []domain.SubmissionAICheck, _ string, err error) {
+    ctx, end := tracing.Start(ctx, "AIDetectionService.ProblemReport", attribute.String("problem.id", problemID))
+    defer end(&err)
+    sum, err := s.repo.SummarizeChecks(ctx, problemID)
+    if err != nil { return domain.AICheckSummary{}, nil, "", err }
+    items, next, err := s.repo.ListChecks(ctx, problemID, spec)
+    if err != nil { return domain.AICheckSummary{}, nil, "", err }
+    return sum, items, next, nil
+}
+
+// SetSetting 开启或关闭题目的检测；只影响之后的提交。
+func (s *AIDetectionService) SetSetting(ctx context.Context, scope, scopeID string, enabled bool, updatedBy string) (domain.AIDetectionSetting, error) {
+    scopeID = strings.TrimSpace(scopeID)
+    if scope != domain.AIDetectionScopeProblem || scopeID == "" { return domain.AIDetectionSetting{}, ErrInvalidDetectionScope }
+    st := domain.AIDetectionSetting{Scope: scope, ScopeID: scopeID, Enabled: enabled, UpdatedBy: updatedBy, UpdatedAt: time.Now().UTC()}
+    if err := s.repo.SetDetection(ctx, st); err != nil { return domain.AIDetectionSetting{}, err }
+    return st, nil
+}
+
+func (s *AIDetectionService) ListSettings(ctx context.Context) ([]domain.AIDetectionSetting, error) {
+    return s.repo.ListDetectionSettings(ctx)
+}
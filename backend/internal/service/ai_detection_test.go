@@ -0,0 +1,98 @@
+package service_test
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/YangYuS8/codyssey/backend/internal/aiclient"
+	"github.com/YangYuS8/codyssey/backend/internal/domain"
+	"github.com/YangYuS8/codyssey/backend/internal/repository"
+	"github.com/YangYuS8/codyssey/backend/internal/service"
+)
+
+type countingDetector struct {
+    mu    sync.Mutex
+    calls map[string]int
+}
+
+func (d *countingDetector) Detect(ctx context.Context, code string) (aiclient.Detection, error) {
+    d.mu.Lock(); defer d.mu.Unlock()
+    d.calls[code]++
+    return aiclient.Detection{Score: 0.2, Model: "stub"}, nil
+}
+
+// 多实例共用一张表：未完成的记录只被认领一次；持有有效租约的 running 记录不被其他实例抢走，过期后可重新认领。
+func TestAIDetection_ClaimWithLease(t *testing.T) {
+    ctx := context.Background()
+    repo := repository.NewMemorySubmissionAICheckRepository()
+    past, future := time.Now().Add(-time.Minute), time.Now().Add(time.Hour)
+    seed := []domain.SubmissionAICheck{
+        {SubmissionID: "s1", Status: domain.AICheckStatusPending},
+        {SubmissionID: "s2", Status: domain.AICheckStatusPending},
+        {SubmissionID: "s3", Status: domain.AICheckStatusRunning, LeaseUntil: &past},   // 上个实例崩溃遗留
+        {SubmissionID: "s4", Status: domain.AICheckStatusRunning, LeaseUntil: &future}, // 另一实例正在处理
+    }
+    for i, c := range seed {
+        c.ProblemID, c.CreatedAt = "p1", time.Now().Add(time.Duration(i)*time.Millisecond)
+        require.NoError(t, repo.CreateCheck(ctx, c))
+    }
+
+    det := &countingDetector{calls: map[string]int{}}
+    runCtx, cancel := context.WithCancel(ctx)
+    var instances []*service.AIDetectionService
+    for i := 0; i < 3; i++ {
+        s := service.NewAIDetectionService(repo, det, service.AIDetectionOptions{})
+        s.UseSubmissionCode(func(ctx context.Context, id string) (string, error) { return id, nil })
+        s.Start(runCtx)
+        instances = append(instances, s)
+    }
+    require.Eventually(t, func() bool {
+        sum, err := repo.SummarizeChecks(ctx, "p1")
+        return err == nil && sum.Completed == 3
+    }, 5*time.Second, 10*time.Millisecond)
+    cancel()
+    for _, s := range instances { s.Wait() }
+
+    require.Equal(t, map[string]int{"s1": 1, "s2": 1, "s3": 1}, det.calls)
+    c, err := repo.GetCheck(ctx, "s4")
+    require.NoError(t, err)
+    require.Equal(t, domain.AICheckStatusRunning, c.Status)
+    c, err = repo.GetCheck(ctx, "s3")
+    require.NoError(t, err)
+    require.Nil(t, c.LeaseUntil)
+}
+
+// 队列满时记录保持 running 等待租约到期后重新认领，而不是永久记为 failed；
+// 租约被其他实例重新认领后，旧实例写入的结果被拒绝，不覆盖接手实例的处理。
+func TestAIDetection_QueueFullAndLeaseLost(t *testing.T) {
+    ctx := context.Background()
+    repo := repository.NewMemorySubmissionAICheckRepository()
+    require.NoError(t, repo.SetDetection(ctx, domain.AIDetectionSetting{Scope: domain.AIDetectionScopeProblem, ScopeID: "p1", Enabled: true}))
+    s := service.NewAIDetectionService(repo, &countingDetector{calls: map[string]int{}}, service.AIDetectionOptions{QueueSize: 1})
+    s.Enqueue(ctx, domain.Submission{ID: "s1", ProblemID: "p1", Code: "a"})
+    s.Enqueue(ctx, domain.Submission{ID: "s2", ProblemID: "p1", Code: "b"}) // 未 Start，队列已满
+    c, err := repo.GetCheck(ctx, "s2")
+    require.NoError(t, err)
+    require.Equal(t, domain.AICheckStatusRunning, c.Status)
+    require.NotNil(t, c.LeaseUntil)
+
+    past := time.Now().Add(-time.Minute)
+    require.NoError(t, repo.CreateCheck(ctx, domain.SubmissionAICheck{SubmissionID: "s3", ProblemID: "p1", Status: domain.AICheckStatusRunning, LeaseUntil: &past, CreatedAt: time.Now()}))
+    claimed, err := repo.ClaimChecks(ctx, 10, time.Hour)
+    require.NoError(t, err)
+    require.Len(t, claimed, 1)
+    now := time.Now()
+    stale := domain.SubmissionAICheck{SubmissionID: "s3", Status: domain.AICheckStatusFailed, Error: "timeout", CheckedAt: &now, LeaseUntil: &past}
+    require.ErrorIs(t, repo.FinishCheck(ctx, stale), repository.ErrAICheckLeaseLost)
+    done := claimed[0]
+    done.Status, done.Score, done.CheckedAt = domain.AICheckStatusCompleted, 0.3, &now
+    require.NoError(t, repo.FinishCheck(ctx, done))
+    c, err = repo.GetCheck(ctx, "s3")
+    require.NoError(t, err)
+    require.Equal(t, domain.AICheckStatusCompleted, c.Status)
+    require.ErrorIs(t, repo.FinishCheck(ctx, done), repository.ErrAICheckLeaseLost)
+}
@@ -6,9 +6,15 @@ import (
 	"time"
 
 	"github.com/YangYuS8/codyssey/backend/internal/domain"
+	"github.com/YangYuS8/codyssey/backend/internal/listquery"
+	"github.com/YangYuS8/codyssey/backend/internal/events"
+	"github.com/YangYuS8/codyssey/backend/internal/logging"
 	"github.com/YangYuS8/codyssey/backend/internal/metrics"
 	"github.com/YangYuS8/codyssey/backend/internal/repository"
+	"github.com/YangYuS8/codyssey/backend/internal/tracing"
 	"github.com/google/uuid"
+	"go.opentelemetry.io/otel/attribute"
+	"go.uber.org/zap"
 )
 
 var (
@@ -21,57 +27,108 @@ var (
 type JudgeRunRepo interface {
     Create(ctx context.Context, jr domain.JudgeRun) error
     GetByID(ctx context.Context, id string) (domain.JudgeRun, error)
-    ListBySubmission(ctx context.Context, submissionID string, limit, offset int) ([]domain.JudgeRun, error)
+    ListBySubmission(ctx context.Context, submissionID string, spec listquery.Spec) ([]domain.JudgeRun, string, error)
     UpdateRunning(ctx context.Context, id string) error
     UpdateFinished(ctx context.Context, id string, status string, runtimeMS, memoryKB, exitCode int, errMsg string) error
 }
 
-type JudgeRunService struct { repo JudgeRunRepo }
+type JudgeRunService struct {
+    repo    JudgeRunRepo
+    events  events.Publisher
+    subRepo SubmissionRepo // 查询所属提交：事件按用户过滤、执行耗时按语言统计
+}
 
 func NewJudgeRunService(r JudgeRunRepo) *JudgeRunService { return &JudgeRunService{repo: r} }
 
+// EnableEvents 每次状态变更发布 judge_run_update。
+func (s *JudgeRunService) EnableEvents(p events.Publisher, subRepo SubmissionRepo) { s.events, s.subRepo = p, subRepo }
+
+// UseSubmissions 设置提交仓库，用于按语言统计执行耗时（EnableEvents 已设置时无需调用）。
+func (s *JudgeRunService) UseSubmissions(subRepo SubmissionRepo) { s.subRepo = subRepo }
+
+// submission 查询运行所属提交；未配置或查询失败时返回零值（仅影响事件过滤与指标标签）。
+func (s *JudgeRunService) submission(ctx context.Context, jr domain.JudgeRun) domain.Submission {
+    if s.subRepo == nil { return domain.Submission{} }
+    sub, _ := s.subRepo.GetByID(ctx, jr.SubmissionID)
+    return sub
+}
+
+func (s *JudgeRunService) publish(ctx context.Context, jr domain.JudgeRun, userID string) {
+    if s.events == nil { return }
+    run := map[string]any{"id": jr.ID, "status": jr.Status, "createdAt": jr.CreatedAt.Format(time.RFC3339)}
+    if jr.StartedAt != nil && jr.FinishedAt != nil { run["durationMs"] = jr.FinishedAt.Sub(*jr.StartedAt).Milliseconds() }
+    s.events.Publish(ctx, events.Event{ID: events.IDAt(jr.UpdatedAt), Type: events.TypeJudgeRunUpdate, SubmissionID: jr.SubmissionID, UserID: userID,
+        Payload: map[string]any{"judgeRun": run}})
+}
+
 // Enqueue 创建一个排队的 JudgeRun
-func (s *JudgeRunService) Enqueue(ctx context.Context, submissionID string, judgeVersion string) (domain.JudgeRun, error) {
+func (s *JudgeRunService) Enqueue(ctx context.Context, submissionID string, judgeVersion string) (_ domain.JudgeRun, err error) {
+    ctx, end := tracing.Start(ctx, "JudgeRunService.Enqueue", attribute.String("submission.id", submissionID))
+    defer end(&err)
     jr := domain.JudgeRun{ID: uuid.New().String(), SubmissionID: submissionID, Status: domain.JudgeRunStatusQueued, JudgeVersion: judgeVersion, CreatedAt: time.Now().UTC(), UpdatedAt: time.Now().UTC()}
     if err := s.repo.Create(ctx, jr); err != nil { return domain.JudgeRun{}, err }
     metrics.ObserveJudgeRunTransition("", domain.JudgeRunStatusQueued)
+    if s.events != nil { s.publish(ctx, jr, s.submission(ctx, jr).UserID) }
     return jr, nil
 }
 
 // Start 将 queued 置为 running
-func (s *JudgeRunService) Start(ctx context.Context, id string) (domain.JudgeRun, error) {
+func (s *JudgeRunService) Start(ctx context.Context, id string) (_ domain.JudgeRun, err error) {
+    ctx, end := tracing.Start(ctx, "JudgeRunService.Start", attribute.String("judge_run.id", id))
+    defer end(&err)
     if err := s.repo.UpdateRunning(ctx, id); err != nil {
-        if errors.Is(err, repository.ErrJudgeRunConflict) { metrics.IncJudgeRunConflict() }
+        if errors.Is(err, repository.ErrJudgeRunConflict) {
+            metrics.IncJudgeRunConflict()
+            logging.FromContext(ctx).Info("judge run transition conflict", zap.String("judge_run_id", id))
+        }
         return domain.JudgeRun{}, err
     }
     jr, err := s.repo.GetByID(ctx, id)
-    if err == nil { metrics.ObserveJudgeRunTransition(domain.JudgeRunStatusQueued, domain.JudgeRunStatusRunning) }
+    if err == nil {
+        metrics.ObserveJudgeRunTransition(domain.JudgeRunStatusQueued, domain.JudgeRunStatusRunning)
+        if jr.StartedAt != nil { metrics.ObserveJudgeQueueWait(jr.StartedAt.Sub(jr.CreatedAt)) }
+        if s.events != nil { s.publish(ctx, jr, s.submission(ctx, jr).UserID) }
+    }
     return jr, err
 }
 
 // Finish 将 running 置为终态（succeeded/failed/canceled），并写入指标
-func (s *JudgeRunService) Finish(ctx context.Context, id string, status string, runtimeMS, memoryKB, exitCode int, errMsg string) (domain.JudgeRun, error) {
+func (s *JudgeRunService) Finish(ctx context.Context, id string, status string, runtimeMS, memoryKB, exitCode int, errMsg string) (_ domain.JudgeRun, err error) {
+    ctx, end := tracing.Start(ctx, "JudgeRunService.Finish", attribute.String("judge_run.id", id), attribute.String("judge_run.status", status))
+    defer end(&err)
     switch status {
     case domain.JudgeRunStatusSucceeded, domain.JudgeRunStatusFailed, domain.JudgeRunStatusCanceled:
     default:
         return domain.JudgeRun{}, ErrJudgeRunInvalidStatus
     }
     if err := s.repo.UpdateFinished(ctx, id, status, runtimeMS, memoryKB, exitCode, errMsg); err != nil {
-        if errors.Is(err, repository.ErrJudgeRunConflict) { metrics.IncJudgeRunConflict() }
+        if errors.Is(err, repository.ErrJudgeRunConflict) {
+            metrics.IncJudgeRunConflict()
+            logging.FromContext(ctx).Info("judge run transition conflict", zap.String("judge_run_id", id))
+        }
         return domain.JudgeRun{}, err
     }
     jr, err := s.repo.GetByID(ctx, id)
     if err == nil {
         metrics.ObserveJudgeRunTransition(domain.JudgeRunStatusRunning, status)
         metrics.ObserveJudgeRunDuration(status, jr.StartedAt, jr.FinishedAt)
+        sub := s.submission(ctx, jr)
+        metrics.ObserveJudgeRunExecution(sub.Language, jr.JudgeVersion, jr.StartedAt, jr.FinishedAt)
+        s.publish(ctx, jr, sub.UserID)
     }
     return jr, err
 }
 
-func (s *JudgeRunService) Get(ctx context.Context, id string) (domain.JudgeRun, error) { return s.repo.GetByID(ctx, id) }
+func (s *JudgeRunService) Get(ctx context.Context, id string) (_ domain.JudgeRun, err error) {
+    ctx, end := tracing.Start(ctx, "JudgeRunService.Get", attribute.String("judge_run.id", id))
+    defer end(&err)
+    return s.repo.GetByID(ctx, id)
+}
 
-func (s *JudgeRunService) ListBySubmission(ctx context.Context, submissionID string, limit, offset int) ([]domain.JudgeRun, error) {
-    return s.repo.ListBySubmission(ctx, submissionID, limit, offset)
+func (s *JudgeRunService) ListBySubmission(ctx context.Context, submissionID string, spec listquery.Spec) (_ []domain.JudgeRun, _ string, err error) {
+    ctx, end := tracing.Start(ctx, "JudgeRunService.ListBySubmission", attribute.String("submission.id", submissionID))
+    defer end(&err)
+    return s.repo.ListBySubmission(ctx, submissionID, spec)
 }
 
 // --- DTO & Adapter for HTTP layer ---
@@ -110,12 +167,12 @@ func (a *JudgeRunHTTPAdapter) Enqueue(ctx context.Context, submissionID, judgeVe
     return toDTO(jr), nil
 }
 
-func (a *JudgeRunHTTPAdapter) ListBySubmission(ctx context.Context, submissionID string, limit, offset int) ([]JudgeRunDTO, error) {
-    list, err := a.svc.ListBySubmission(ctx, submissionID, limit, offset)
-    if err != nil { return nil, err }
+func (a *JudgeRunHTTPAdapter) ListBySubmission(ctx context.Context, submissionID string, spec listquery.Spec) ([]JudgeRunDTO, string, error) {
+    list, next, err := a.svc.ListBySubmission(ctx, submissionID, spec)
+    if err != nil { return nil, "", err }
     out := make([]JudgeRunDTO, 0, len(list))
     for _, it := range list { out = append(out, toDTO(it)) }
-    return out, nil
+    return out, next, nil
 }
 
 func (a *JudgeRunHTTPAdapter) Get(ctx context.Context, id string) (JudgeRunDTO, error) {
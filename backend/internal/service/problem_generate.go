@@ -0,0 +1,64 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/YangYuS8/codyssey/backend/internal/aiclient"
+	"github.com/YangYuS8/codyssey/backend/internal/domain"
+)
+
+const (
+    maxPromptRunes        = 2000
+    minTitleRunes         = 3   // 与创建接口的标题校验一致
+    maxTitleRunes         = 100
+    unknownGeneratorModel = "unknown"
+)
+
+var (
+    // ErrGeneratorDisabled 未配置 AI 服务。
+    ErrGeneratorDisabled = errors.New("problem generator disabled")
+    // ErrGeneratedProblemInvalid AI 服务返回的题目缺少标题或描述。
+    ErrGeneratedProblemInvalid = errors.New("generated problem invalid")
+)
+
+// ProblemGenerator 按提示词生成题目（*aiclient.Client 实现）。
+type ProblemGenerator interface {
+    Generate(ctx context.Context, prompt string) (aiclient.GeneratedProblem, error)
+}
+
+// EnableGenerator 启用 AI 生成题目；生成结果以 draft 保存，需经发布审核后才对学生可见。
+func (s *ProblemService) EnableGenerator(g ProblemGenerator) { s.gen = g }
+
+// Generate 调用 AI 服务按提示词生成题目并保存为草稿，记录模型与提示词等来源信息。
+// AI 服务的错误原样返回（见 aiclient.ErrUnavailable / aiclient.ErrBadResponse）。
+func (s *ProblemService) Generate(ctx context.Context, prompt, requestedBy string) (domain.Problem, error) {
+    if s.gen == nil { return domain.Problem{}, ErrGeneratorDisabled }
+    prompt = strings.TrimSpace(prompt)
+    if prompt == "" { return domain.Problem{}, fmt.Errorf("%w: prompt is required", ErrInvalidProblem) }
+    if utf8.RuneCountInString(prompt) > maxPromptRunes {
+        return domain.Problem{}, fmt.Errorf("%w: prompt exceeds %d characters", ErrInvalidProblem, maxPromptRunes)
+    }
+    out, err := s.gen.Generate(ctx, prompt)
+    if err != nil { return domain.Problem{}, err }
+    title := truncateRunes(strings.TrimSpace(out.Title), maxTitleRunes)
+    desc := strings.TrimSpace(out.Description)
+    if utf8.RuneCountInString(title) < minTitleRunes || desc == "" {
+        return domain.Problem{}, fmt.Errorf("%w: title and description are required", ErrGeneratedProblemInvalid)
+    }
+    model := strings.TrimSpace(out.Model)
+    if model == "" { model = unknownGeneratorModel }
+    return s.Create(ctx, ProblemInput{
+        Title: title, Description: desc, AuthorID: requestedBy, Draft: true,
+        Provenance: &domain.ProblemProvenance{Origin: domain.ProblemOriginAI, Model: model, Prompt: prompt, RequestedBy: requestedBy, GeneratedAt: time.Now().UTC()},
+    })
+}
+
+func truncateRunes(s string, n int) string {
+    if utf8.RuneCountInString(s) <= n { return s }
+    return strings.TrimSpace(string([]rune(s)[:n]))
+}
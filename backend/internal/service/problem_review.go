@@ -0,0 +1,91 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/YangYuS8/codyssey/backend/internal/domain"
+	"github.com/YangYuS8/codyssey/backend/internal/listquery"
+	"github.com/YangYuS8/codyssey/backend/internal/repository"
+	"github.com/google/uuid"
+)
+
+// 发布审核流程动作；各动作所需权限由路由配置（submit / withdraw 需 problem.update，
+// approve / reject 需 problem.review，archive / restore 需 problem.archive）。
+const (
+    ProblemActionSubmit   = "submit"   // draft -> pending_review
+    ProblemActionWithdraw = "withdraw" // pending_review -> draft
+    ProblemActionApprove  = "approve"  // pending_review -> published
+    ProblemActionReject   = "reject"   // pending_review -> draft，必须附审核意见
+    ProblemActionArchive  = "archive"  // draft / published -> archived
+    ProblemActionRestore  = "restore"  // archived -> draft，重新发布需再次审核
+)
+
+type problemTransition struct {
+    from []string
+    to   string
+}
+
+var problemTransitions = map[string]problemTransition{
+    ProblemActionSubmit:   {from: []string{domain.ProblemStatusDraft}, to: domain.ProblemStatusPendingReview},
+    ProblemActionWithdraw: {from: []string{domain.ProblemStatusPendingReview}, to: domain.ProblemStatusDraft},
+    ProblemActionApprove:  {from: []string{domain.ProblemStatusPendingReview}, to: domain.ProblemStatusPublished},
+    ProblemActionReject:   {from: []string{domain.ProblemStatusPendingReview}, to: domain.ProblemStatusDraft},
+    ProblemActionArchive:  {from: []string{domain.ProblemStatusDraft, domain.ProblemStatusPublished}, to: domain.ProblemStatusArchived},
+    ProblemActionRestore:  {from: []string{domain.ProblemStatusArchived}, to: domain.ProblemStatusDraft},
+}
+
+const maxReviewCommentRunes = 2000
+
+var (
+    // ErrInvalidProblemTransition 当前状态不允许该动作（或动作未知）。
+    ErrInvalidProblemTransition = errors.New("invalid problem status transition")
+    // ErrReviewCommentRequired 驳回必须说明原因。
+    ErrReviewCommentRequired = errors.New("review comment required")
+    // ErrPublishedStatementLocked 已发布题目的题面只能由审核者（problem.review）修改。
+    ErrPublishedStatementLocked = errors.New("changing the statement of a published problem requires problem.review")
+)
+
+// statementLocked 启用审核流程时，已发布题目的题面（含限制与测试数据）修改后立即对学生生效，
+// 因此只允许审核者修改，避免出题人绕过审核直接上线新题面。
+func (s *ProblemService) statementLocked(p domain.Problem, canReview bool) bool {
+    return s.review != nil && p.Status == domain.ProblemStatusPublished && !canReview
+}
+
+// EnableReview 启用发布审核流程：新题目以 draft 创建，经审核通过后才对学生 / 访客可见，每次流转写入审计日志。
+// 未启用时题目创建即为 published。
+func (s *ProblemService) EnableReview(r repository.ProblemReviewRepository) { s.review = r }
+
+// ReviewEnabled 是否启用了发布审核流程。
+func (s *ProblemService) ReviewEnabled() bool { return s.review != nil }
+
+// Transition 对题目执行审核流程动作并记录操作人与意见；并发流转返回 ErrProblemConflict。
+func (s *ProblemService) Transition(ctx context.Context, id uuid.UUID, action, actorID, comment string) (domain.Problem, error) {
+    t, ok := problemTransitions[action]
+    if !ok { return domain.Problem{}, fmt.Errorf("%w: unknown action %q", ErrInvalidProblemTransition, action) }
+    comment = strings.TrimSpace(comment)
+    if action == ProblemActionReject && comment == "" { return domain.Problem{}, ErrReviewCommentRequired }
+    if utf8.RuneCountInString(comment) > maxReviewCommentRunes {
+        return domain.Problem{}, fmt.Errorf("%w: comment exceeds %d characters", ErrInvalidProblem, maxReviewCommentRunes)
+    }
+    p, err := s.repo.GetByID(ctx, id)
+    if err != nil { return domain.Problem{}, err }
+    allowed := false
+    for _, f := range t.from { if f == p.Status { allowed = true; break } }
+    if !allowed { return domain.Problem{}, fmt.Errorf("%w: cannot %s a %s problem", ErrInvalidProblemTransition, action, p.Status) }
+    l := domain.ProblemStatusLog{ID: uuid.New(), ProblemID: id, Action: action, FromStatus: p.Status, ToStatus: t.to,
+        ActorID: actorID, Comment: comment, CreatedAt: time.Now().UTC().Truncate(time.Microsecond)}
+    if err := s.review.TransitionStatus(ctx, l); err != nil { return domain.Problem{}, err }
+    p.Status = t.to
+    return withRendered(p), nil
+}
+
+// ListStatusLogs 题目的状态流转与审核意见（默认按时间正序）；题目不存在返回 ErrNotFound。
+func (s *ProblemService) ListStatusLogs(ctx context.Context, id uuid.UUID, spec listquery.Spec) ([]domain.ProblemStatusLog, string, error) {
+    if _, err := s.repo.GetByID(ctx, id); err != nil { return nil, "", err }
+    return s.review.ListStatusLogs(ctx, id, spec)
+}
@@ -2,9 +2,19 @@ package service
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
 
 	"github.com/YangYuS8/codyssey/backend/internal/domain"
+	"github.com/YangYuS8/codyssey/backend/internal/listquery"
+	"github.com/YangYuS8/codyssey/backend/internal/markdown"
 	"github.com/YangYuS8/codyssey/backend/internal/repository"
+	"github.com/YangYuS8/codyssey/backend/internal/textdiff"
 	"github.com/google/uuid"
 )
 
@@ -14,42 +24,395 @@ type ProblemRepo interface {
     GetByID(ctx context.Context, id uuid.UUID) (domain.Problem, error)
     Update(ctx context.Context, p domain.Problem) error
     Delete(ctx context.Context, id uuid.UUID) error
-    List(ctx context.Context, limit, offset int) ([]domain.Problem, error)
+    List(ctx context.Context, f repository.ProblemFilter, spec listquery.Spec) ([]domain.Problem, string, error)
 }
 
-type ProblemService struct { repo ProblemRepo }
+const (
+    maxProblemTags   = 10
+    maxTagNameRunes  = 32
+    maxSourceRunes   = 100
+    maxTimeLimitMS   = 60_000
+    maxMemoryLimitMB = 4096
+    maxMessageRunes  = 200
+    maxSamples       = 20
+    maxSectionBytes  = 64 << 10 // 描述及每个分节 / 样例字段的上限
+)
+
+// testDataHashPattern 测试数据摘要形如 "sha256:<hex>"。
+var testDataHashPattern = regexp.MustCompile(`^[a-z0-9]+:[0-9a-f]{16,128}$`)
+
+var (
+    // ErrInvalidProblem 难度 / 可见性 / 出处 / 标签个数 / 评测限制 / 测试数据摘要 / 题面分节不合法。
+    ErrInvalidProblem = errors.New("invalid problem")
+    // ErrUnknownTag 引用了未创建的标签。
+    ErrUnknownTag = errors.New("unknown problem tag")
+    // ErrInvalidTag 标签名为空、过长或含逗号（逗号用作查询参数分隔符）。
+    ErrInvalidTag = errors.New("invalid problem tag")
+)
+
+// ProblemInput 创建题目的字段；Visibility 为空时取 public，限制为 0 时取默认值。
+type ProblemInput struct {
+    Title       string
+    Description string
+    Tags        []string
+    Difficulty  string
+    Source      string
+    Visibility  string
+    TimeLimitMS   int
+    MemoryLimitMB int
+    TestDataHash  string
+    Statement     domain.ProblemStatement
+    AuthorID      string // 记入第 1 个修订
+    Draft         bool   // 总是以 draft 创建（如 AI 生成的题目），不论是否启用发布审核
+    Provenance    *domain.ProblemProvenance
+}
+
+// ProblemPatch 部分更新，nil 字段保持不变；Tags 非 nil 时整体替换。
+// Statement 非 nil 时整体替换各分节。题面（标题、描述、分节、限制、测试数据）有变化时追加修订，AuthorID / Message 记入该修订。
+type ProblemPatch struct {
+    Title       *string
+    Description *string
+    Tags        []string
+    Difficulty  *string
+    Source      *string
+    Visibility  *string
+    TimeLimitMS   *int
+    MemoryLimitMB *int
+    TestDataHash  *string
+    Statement     *domain.ProblemStatement
+    AuthorID      string
+    Message       string
+    // CanReview 调用者具备 problem.review，可修改已发布题目的题面
+    CanReview bool
+}
+
+type ProblemService struct {
+    repo ProblemRepo
+    tags repository.ProblemTagRepository // nil 时不校验标签是否存在，且不提供标签管理
+    revs repository.ProblemRevisionRepository // nil 时原地更新，不记录修订
+    review repository.ProblemReviewRepository // nil 时不启用发布审核，题目创建即发布
+    gen    ProblemGenerator // nil 时不提供 AI 生成题目
+}
 
 func NewProblemService(r ProblemRepo) *ProblemService { return &ProblemService{repo: r} }
 
-func (s *ProblemService) Create(ctx context.Context, title, desc string) (domain.Problem, error) {
-    p := domain.NewProblem(title, desc)
-    if err := s.repo.Create(ctx, p); err != nil { return domain.Problem{}, err }
-    return p, nil
+// EnableTags 启用标签词表：题目只能引用已创建的标签，并提供标签增删改查。
+func (s *ProblemService) EnableTags(r repository.ProblemTagRepository) { s.tags = r }
+
+// EnableRevisions 启用题面修订历史：创建题目生成第 1 个修订，题面变化时追加修订，支持对比与回滚。
+func (s *ProblemService) EnableRevisions(r repository.ProblemRevisionRepository) { s.revs = r }
+
+// TagsEnabled 是否启用了标签管理。
+func (s *ProblemService) TagsEnabled() bool { return s.tags != nil }
+
+func (s *ProblemService) Create(ctx context.Context, in ProblemInput) (domain.Problem, error) {
+    p := domain.NewProblem(in.Title, in.Description)
+    p.Difficulty, p.Source = in.Difficulty, strings.TrimSpace(in.Source)
+    if in.Visibility != "" { p.Visibility = in.Visibility }
+    if in.TimeLimitMS != 0 { p.TimeLimitMS = in.TimeLimitMS }
+    if in.MemoryLimitMB != 0 { p.MemoryLimitMB = in.MemoryLimitMB }
+    p.TestDataHash = in.TestDataHash
+    p.Statement = normalizeStatement(in.Statement)
+    if s.review != nil || in.Draft { p.Status = domain.ProblemStatusDraft }
+    p.Provenance = in.Provenance
+    tags, err := s.checkTags(ctx, in.Tags)
+    if err != nil { return domain.Problem{}, err }
+    p.Tags = tags
+    if err := validateProblem(p); err != nil { return domain.Problem{}, err }
+    if s.revs == nil {
+        if err := s.repo.Create(ctx, p); err != nil { return domain.Problem{}, err }
+        return withRendered(p), nil
+    }
+    p.Revision = 1
+    if err := s.revs.CreateWithRevision(ctx, p, domain.NewProblemRevision(p, 1, in.AuthorID, "initial revision")); err != nil { return domain.Problem{}, err }
+    return withRendered(p), nil
 }
 
+// Get 返回题目及渲染后的题面。
 func (s *ProblemService) Get(ctx context.Context, id uuid.UUID) (domain.Problem, error) {
-    return s.repo.GetByID(ctx, id)
+    p, err := s.repo.GetByID(ctx, id)
+    if err != nil { return domain.Problem{}, err }
+    return withRendered(p), nil
 }
 
-func (s *ProblemService) Update(ctx context.Context, id uuid.UUID, title *string, desc *string) (domain.Problem, error) {
+func (s *ProblemService) Update(ctx context.Context, id uuid.UUID, patch ProblemPatch) (domain.Problem, error) {
     existing, err := s.repo.GetByID(ctx, id)
     if err != nil { return domain.Problem{}, err }
-    if title != nil { existing.Title = *title }
-    if desc != nil { existing.Description = *desc }
-    if err := s.repo.Update(ctx, existing); err != nil { return domain.Problem{}, err }
-    return existing, nil
+    prev := existing
+    if patch.Title != nil { existing.Title = *patch.Title }
+    if patch.Description != nil { existing.Description = *patch.Description }
+    if patch.Difficulty != nil { existing.Difficulty = *patch.Difficulty }
+    if patch.Source != nil { existing.Source = strings.TrimSpace(*patch.Source) }
+    if patch.Visibility != nil { existing.Visibility = *patch.Visibility }
+    if patch.TimeLimitMS != nil { existing.TimeLimitMS = *patch.TimeLimitMS }
+    if patch.MemoryLimitMB != nil { existing.MemoryLimitMB = *patch.MemoryLimitMB }
+    if patch.TestDataHash != nil { existing.TestDataHash = *patch.TestDataHash }
+    if patch.Statement != nil { existing.Statement = normalizeStatement(*patch.Statement) }
+    if patch.Tags != nil {
+        tags, err := s.checkTags(ctx, patch.Tags)
+        if err != nil { return domain.Problem{}, err }
+        existing.Tags = tags
+    }
+    if err := validateProblem(existing); err != nil { return domain.Problem{}, err }
+    if utf8.RuneCountInString(patch.Message) > maxMessageRunes {
+        return domain.Problem{}, fmt.Errorf("%w: message exceeds %d characters", ErrInvalidProblem, maxMessageRunes)
+    }
+    if !prev.SameStatement(existing) && s.statementLocked(prev, patch.CanReview) { return domain.Problem{}, ErrPublishedStatementLocked }
+    if s.revs == nil || prev.SameStatement(existing) {
+        if err := s.repo.Update(ctx, existing); err != nil { return domain.Problem{}, err }
+        return withRendered(existing), nil
+    }
+    return s.appendRevision(ctx, existing, patch.AuthorID, patch.Message)
+}
+
+// appendRevision 以 p 的题面追加下一个修订并更新题目；并发修改返回 ErrProblemConflict。
+func (s *ProblemService) appendRevision(ctx context.Context, p domain.Problem, authorID, message string) (domain.Problem, error) {
+    p.Revision++
+    rev := domain.NewProblemRevision(p, p.Revision, authorID, strings.TrimSpace(message))
+    if err := s.revs.UpdateWithRevision(ctx, p, rev); err != nil { return domain.Problem{}, err }
+    return withRendered(p), nil
 }
 
 func (s *ProblemService) Delete(ctx context.Context, id uuid.UUID) error {
     return s.repo.Delete(ctx, id)
 }
 
-func (s *ProblemService) List(ctx context.Context, limit, offset int) ([]domain.Problem, error) {
-    if limit <= 0 { limit = 20 }
-    if offset < 0 { offset = 0 }
-    return s.repo.List(ctx, limit, offset)
+// List 按 repository.ProblemListSchema 解析出的条件与检索过滤分页查询，返回本页与下一页游标。
+func (s *ProblemService) List(ctx context.Context, f repository.ProblemFilter, spec listquery.Spec) ([]domain.Problem, string, error) {
+    tags, err := normalizeTags(f.Tags)
+    if err != nil { return nil, "", err }
+    f.Tags = tags
+    return s.repo.List(ctx, f, spec)
+}
+
+func validateProblem(p domain.Problem) error {
+    if p.TimeLimitMS < 1 || p.TimeLimitMS > maxTimeLimitMS {
+        return fmt.Errorf("%w: time_limit_ms must be between 1 and %d", ErrInvalidProblem, maxTimeLimitMS)
+    }
+    if p.MemoryLimitMB < 1 || p.MemoryLimitMB > maxMemoryLimitMB {
+        return fmt.Errorf("%w: memory_limit_mb must be between 1 and %d", ErrInvalidProblem, maxMemoryLimitMB)
+    }
+    if p.TestDataHash != "" && !testDataHashPattern.MatchString(p.TestDataHash) {
+        return fmt.Errorf("%w: test_data_hash must look like sha256:<hex>", ErrInvalidProblem)
+    }
+    switch p.Difficulty {
+    case "", domain.DifficultyEasy, domain.DifficultyMedium, domain.DifficultyHard:
+    default:
+        return fmt.Errorf("%w: difficulty must be one of easy, medium, hard", ErrInvalidProblem)
+    }
+    if p.Visibility != domain.VisibilityPublic && p.Visibility != domain.VisibilityPrivate {
+        return fmt.Errorf("%w: visibility must be public or private", ErrInvalidProblem)
+    }
+    if utf8.RuneCountInString(p.Source) > maxSourceRunes {
+        return fmt.Errorf("%w: source exceeds %d characters", ErrInvalidProblem, maxSourceRunes)
+    }
+    if len(p.Tags) > maxProblemTags {
+        return fmt.Errorf("%w: at most %d tags", ErrInvalidProblem, maxProblemTags)
+    }
+    return validateStatement(p.Description, p.Statement)
+}
+
+func validateStatement(description string, st domain.ProblemStatement) error {
+    if len(st.Samples) > maxSamples {
+        return fmt.Errorf("%w: at most %d samples", ErrInvalidProblem, maxSamples)
+    }
+    type field struct{ name, value string }
+    fields := []field{{"description", description}, {"statement.background", st.Background}, {"statement.input", st.Input},
+        {"statement.output", st.Output}, {"statement.notes", st.Notes}}
+    for i, sm := range st.Samples {
+        if strings.TrimSpace(sm.Input) == "" && strings.TrimSpace(sm.Output) == "" {
+            return fmt.Errorf("%w: statement.samples[%d] needs input or output", ErrInvalidProblem, i)
+        }
+        prefix := fmt.Sprintf("statement.samples[%d].", i)
+        fields = append(fields, field{prefix + "input", sm.Input}, field{prefix + "output", sm.Output}, field{prefix + "explanation", sm.Explanation})
+    }
+    for _, f := range fields {
+        if len(f.value) > maxSectionBytes { return fmt.Errorf("%w: %s exceeds %d bytes", ErrInvalidProblem, f.name, maxSectionBytes) }
+    }
+    return nil
+}
+
+// normalizeStatement 统一换行符，并保证 Samples 非 nil（JSON 输出为 []）。
+func normalizeStatement(st domain.ProblemStatement) domain.ProblemStatement {
+    nl := strings.NewReplacer("\r\n", "\n", "\r", "\n")
+    st.Background, st.Input, st.Output, st.Notes = nl.Replace(st.Background), nl.Replace(st.Input), nl.Replace(st.Output), nl.Replace(st.Notes)
+    samples := make([]domain.ProblemSample, len(st.Samples))
+    for i, sm := range st.Samples {
+        samples[i] = domain.ProblemSample{Input: nl.Replace(sm.Input), Output: nl.Replace(sm.Output), Explanation: nl.Replace(sm.Explanation)}
+    }
+    st.Samples = samples
+    return st
+}
+
+// withRendered 渲染题面描述与各分节为清洗后的 HTML（样例输入输出按原文放入 <pre>）。
+func withRendered(p domain.Problem) domain.Problem {
+    r := &domain.RenderedStatement{
+        Description: markdown.Render(p.Description),
+        Background:  markdown.Render(p.Statement.Background),
+        Input:       markdown.Render(p.Statement.Input),
+        Output:      markdown.Render(p.Statement.Output),
+        Samples:     make([]domain.RenderedSample, len(p.Statement.Samples)),
+        Notes:       markdown.Render(p.Statement.Notes),
+    }
+    for i, sm := range p.Statement.Samples {
+        r.Samples[i] = domain.RenderedSample{Input: markdown.Preformatted(sm.Input), Output: markdown.Preformatted(sm.Output), Explanation: markdown.Render(sm.Explanation)}
+    }
+    p.Rendered = r
+    return p
+}
+
+// normalizeTag 去除首尾空白后校验标签名。
+func normalizeTag(name string) (string, error) {
+    name = strings.TrimSpace(name)
+    if name == "" || utf8.RuneCountInString(name) > maxTagNameRunes || strings.Contains(name, ",") {
+        return "", fmt.Errorf("%w: name must be 1-%d characters without commas", ErrInvalidTag, maxTagNameRunes)
+    }
+    return name, nil
+}
+
+// normalizeTags 规范化标签列表：去空白、去重、排序。
+func normalizeTags(names []string) ([]string, error) {
+    seen := map[string]bool{}
+    out := make([]string, 0, len(names))
+    for _, n := range names {
+        n, err := normalizeTag(n)
+        if err != nil { return nil, err }
+        if seen[n] { continue }
+        seen[n] = true
+        out = append(out, n)
+    }
+    sort.Strings(out)
+    return out, nil
+}
+
+// checkTags 规范化并（启用标签词表时）确认标签均已创建。
+func (s *ProblemService) checkTags(ctx context.Context, names []string) ([]string, error) {
+    tags, err := normalizeTags(names)
+    if err != nil { return nil, err }
+    if s.tags == nil || len(tags) == 0 { return tags, nil }
+    missing, err := s.tags.MissingTags(ctx, tags)
+    if err != nil { return nil, err }
+    if len(missing) > 0 { return nil, fmt.Errorf("%w: %s", ErrUnknownTag, strings.Join(missing, ", ")) }
+    return tags, nil
+}
+
+func (s *ProblemService) ListTags(ctx context.Context) ([]domain.ProblemTag, error) {
+    return s.tags.ListTags(ctx)
+}
+
+func (s *ProblemService) CreateTag(ctx context.Context, name, desc string) (domain.ProblemTag, error) {
+    name, err := normalizeTag(name)
+    if err != nil { return domain.ProblemTag{}, err }
+    t := domain.ProblemTag{Name: name, Description: strings.TrimSpace(desc), CreatedAt: time.Now().UTC()}
+    if err := s.tags.CreateTag(ctx, t); err != nil { return domain.ProblemTag{}, err }
+    return t, nil
+}
+
+// UpdateTag 重命名或修改描述；重命名会同步替换所有题目上的旧标签名。newName 为空表示不改名。
+func (s *ProblemService) UpdateTag(ctx context.Context, name, newName string, desc *string) (domain.ProblemTag, error) {
+    tags, err := s.tags.ListTags(ctx)
+    if err != nil { return domain.ProblemTag{}, err }
+    var cur *domain.ProblemTag
+    for i := range tags { if tags[i].Name == name { cur = &tags[i]; break } }
+    if cur == nil { return domain.ProblemTag{}, repository.ErrTagNotFound }
+    next := *cur
+    if newName != "" {
+        if next.Name, err = normalizeTag(newName); err != nil { return domain.ProblemTag{}, err }
+    }
+    if desc != nil { next.Description = strings.TrimSpace(*desc) }
+    if err := s.tags.UpdateTag(ctx, name, next); err != nil { return domain.ProblemTag{}, err }
+    return next, nil
+}
+
+// DeleteTag 删除标签并从所有题目上移除。
+func (s *ProblemService) DeleteTag(ctx context.Context, name string) error {
+    return s.tags.DeleteTag(ctx, name)
+}
+
+// RevisionsEnabled 是否启用了修订历史。
+func (s *ProblemService) RevisionsEnabled() bool { return s.revs != nil }
+
+// ListRevisions 题目的修订列表（默认按修订号倒序）；题目不存在返回 ErrNotFound。
+func (s *ProblemService) ListRevisions(ctx context.Context, id uuid.UUID, spec listquery.Spec) ([]domain.ProblemRevision, string, error) {
+    if _, err := s.repo.GetByID(ctx, id); err != nil { return nil, "", err }
+    return s.revs.ListRevisions(ctx, id, spec)
+}
+
+func (s *ProblemService) GetRevision(ctx context.Context, id uuid.UUID, number int) (domain.ProblemRevision, error) {
+    return s.revs.GetRevision(ctx, id, number)
+}
+
+// FieldChange 修订间变化的单个字段。
+type FieldChange struct {
+    Field string `json:"field"`
+    From  any    `json:"from"`
+    To    any    `json:"to"`
+}
+
+// RevisionDiff 两个修订的差异：标量字段与题面分节逐个列出，描述给出按行的 unified diff（无变化时为空串）。
+type RevisionDiff struct {
+    ProblemID       uuid.UUID     `json:"problem_id"`
+    From            int           `json:"from"`
+    To              int           `json:"to"`
+    Changes         []FieldChange `json:"changes"`
+    DescriptionDiff string        `json:"description_diff"`
+}
+
+// DiffRevisions 对比修订 from 与 to（顺序任意，to 可早于 from）。
+func (s *ProblemService) DiffRevisions(ctx context.Context, id uuid.UUID, from, to int) (RevisionDiff, error) {
+    a, err := s.revs.GetRevision(ctx, id, from)
+    if err != nil { return RevisionDiff{}, err }
+    b, err := s.revs.GetRevision(ctx, id, to)
+    if err != nil { return RevisionDiff{}, err }
+    d := RevisionDiff{ProblemID: id, From: from, To: to, Changes: []FieldChange{}, DescriptionDiff: textdiff.Unified(a.Description, b.Description, 3)}
+    add := func(field string, x, y any) { if x != y { d.Changes = append(d.Changes, FieldChange{Field: field, From: x, To: y}) } }
+    add("title", a.Title, b.Title)
+    add("time_limit_ms", a.TimeLimitMS, b.TimeLimitMS)
+    add("memory_limit_mb", a.MemoryLimitMB, b.MemoryLimitMB)
+    add("test_data_hash", a.TestDataHash, b.TestDataHash)
+    if d.DescriptionDiff != "" { d.Changes = append(d.Changes, FieldChange{Field: "description"}) }
+    add("statement.background", a.Statement.Background, b.Statement.Background)
+    add("statement.input", a.Statement.Input, b.Statement.Input)
+    add("statement.output", a.Statement.Output, b.Statement.Output)
+    if !(domain.ProblemStatement{Samples: a.Statement.Samples}).Equal(domain.ProblemStatement{Samples: b.Statement.Samples}) {
+        d.Changes = append(d.Changes, FieldChange{Field: "statement.samples", From: a.Statement.Samples, To: b.Statement.Samples})
+    }
+    add("statement.notes", a.Statement.Notes, b.Statement.Notes)
+    return d, nil
+}
+
+// Rollback 以修订 number 的题面追加一个新修订（历史不被改写）；题面已与该修订一致时不产生修订。
+// 与 Update 相同，已发布题目只有审核者（canReview）可以回滚。
+func (s *ProblemService) Rollback(ctx context.Context, id uuid.UUID, number int, authorID string, canReview bool) (domain.Problem, error) {
+    existing, err := s.repo.GetByID(ctx, id)
+    if err != nil { return domain.Problem{}, err }
+    rev, err := s.revs.GetRevision(ctx, id, number)
+    if err != nil { return domain.Problem{}, err }
+    target := existing
+    rev.ApplyTo(&target)
+    if target.SameStatement(existing) { return withRendered(existing), nil }
+    if s.statementLocked(existing, canReview) { return domain.Problem{}, ErrPublishedStatementLocked }
+    return s.appendRevision(ctx, target, authorID, fmt.Sprintf("rollback to revision %d", number))
+}
+
+// SubmissionTarget 提交所针对的题目及其当前修订的 ID（供可见性校验与提交记录）；题目未启用修订时修订 ID 为空串，
+// ID 非法或题目不存在返回 ErrNotFound。
+func (s *ProblemService) SubmissionTarget(ctx context.Context, problemID string) (domain.Problem, string, error) {
+    id, err := uuid.Parse(problemID)
+    if err != nil { return domain.Problem{}, "", ErrNotFound }
+    p, err := s.repo.GetByID(ctx, id)
+    if err != nil { return domain.Problem{}, "", err }
+    if s.revs == nil || p.Revision == 0 { return p, "", nil }
+    rev, err := s.revs.GetRevision(ctx, id, p.Revision)
+    if err != nil { return domain.Problem{}, "", err }
+    return p, rev.ID.String(), nil
 }
 
 // 错误透传，这里预留做 error wrapping / metrics
 var ErrNotFound = repository.ErrNotFound
+
+var (
+    ErrProblemConflict  = repository.ErrProblemConflict
+    ErrRevisionNotFound = repository.ErrRevisionNotFound
+)
 // end
@@ -3,15 +3,19 @@ package service
 import (
 	"context"
 	"errors"
-	"os"
-	"strconv"
 	"strings"
 	"time"
 
 	"github.com/YangYuS8/codyssey/backend/internal/domain"
+	"github.com/YangYuS8/codyssey/backend/internal/listquery"
+	"github.com/YangYuS8/codyssey/backend/internal/events"
+	"github.com/YangYuS8/codyssey/backend/internal/logging"
 	"github.com/YangYuS8/codyssey/backend/internal/metrics"
 	"github.com/YangYuS8/codyssey/backend/internal/repository"
+	"github.com/YangYuS8/codyssey/backend/internal/tracing"
 	"github.com/google/uuid"
+	"go.opentelemetry.io/otel/attribute"
+	"go.uber.org/zap"
 )
 
 var (
@@ -53,38 +57,112 @@ type SubmissionRepo interface {
     Create(ctx context.Context, s domain.Submission) error
     GetByID(ctx context.Context, id string) (domain.Submission, error)
     UpdateStatus(ctx context.Context, id string, status string, expectedVersion int) error
-    List(ctx context.Context, f repository.SubmissionFilter, limit, offset int) ([]domain.Submission, error)
-    Count(ctx context.Context, f repository.SubmissionFilter) (int, error)
+    List(ctx context.Context, spec listquery.Spec) ([]domain.Submission, string, error)
+    Count(ctx context.Context, spec listquery.Spec) (int, error)
 }
 
 type SubmissionStatusLogRepo interface {
     Add(ctx context.Context, log domain.SubmissionStatusLog) error
-    ListBySubmission(ctx context.Context, submissionID string, limit, offset int) ([]domain.SubmissionStatusLog, error)
+    ListBySubmission(ctx context.Context, submissionID string, spec listquery.Spec) ([]domain.SubmissionStatusLog, string, error)
+    ListSince(ctx context.Context, since time.Time, submissionID string, limit int) ([]domain.SubmissionStatusLog, error)
 }
 
 type SubmissionService struct {
     repo    SubmissionRepo
     logRepo SubmissionStatusLogRepo
+    events  events.Publisher // nil 表示不推送实时事件
+    opts    SubmissionOptions
+    maxCodeBytes func() int // 运行时参数；nil 时使用 opts.MaxCodeBytes
+    problems func(ctx context.Context, problemID string) (domain.Problem, string, error) // nil 时不校验题目可见性、不记录修订
+    detection *AIDetectionService // nil 表示不做 AI 代码检测
 }
 
-func NewSubmissionService(repo SubmissionRepo, logRepo SubmissionStatusLogRepo) *SubmissionService { return &SubmissionService{repo: repo, logRepo: logRepo} }
+// SubmissionOptions 提交限制（来自 config.MaxSubmissionCodeBytes）。
+type SubmissionOptions struct {
+    MaxCodeBytes int // 代码长度上限；<=0 时为 128KB
+}
+
+func NewSubmissionService(repo SubmissionRepo, logRepo SubmissionStatusLogRepo, o SubmissionOptions) *SubmissionService {
+    if o.MaxCodeBytes <= 0 { o.MaxCodeBytes = 128 * 1024 }
+    return &SubmissionService{repo: repo, logRepo: logRepo, opts: o}
+}
+
+// EnableEvents 状态变更后发布 status_update（终态额外发布 completed）。
+func (s *SubmissionService) EnableEvents(p events.Publisher) { s.events = p }
+
+// UseMaxCodeBytes 每次提交时读取代码长度上限（运行时参数热更新）；返回 <=0 时回退到启动配置。
+func (s *SubmissionService) UseMaxCodeBytes(fn func() int) { s.maxCodeBytes = fn }
+
+// UseProblems 创建提交时读取题目（通常为 ProblemService.SubmissionTarget）：拒绝调用者不可见的题目，并记录当前修订 ID。
+// 题目不存在时不校验也不记录，保持原有行为。
+func (s *SubmissionService) UseProblems(fn func(ctx context.Context, problemID string) (domain.Problem, string, error)) { s.problems = fn }
+
+// EnableAIDetection 创建提交后按题目 / 比赛开关异步检测代码是否疑似 AI 生成；检测失败不影响提交与判题。
+func (s *SubmissionService) EnableAIDetection(d *AIDetectionService) { s.detection = d }
+
+func (s *SubmissionService) codeLimit() int {
+    if s.maxCodeBytes != nil {
+        if n := s.maxCodeBytes(); n > 0 { return n }
+    }
+    return s.opts.MaxCodeBytes
+}
+
+func isTerminalStatus(st string) bool { return isValidStatus(st) && len(allowedNext[st]) == 0 }
+
+// ErrProblemUnavailable 题目对调用者不可见（private 或未发布），按不存在处理。
+var ErrProblemUnavailable = errors.New("problem not found")
+
+// CreateSubmissionInput 创建提交的参数。
+type CreateSubmissionInput struct {
+    UserID    string
+    ProblemID string
+    Language  string
+    Code      string
+    // CanSeeHidden 调用者具备 problem.update 或 problem.review，可向 private / 未发布的题目提交（如出题验题）
+    CanSeeHidden bool
+}
 
+// Create 以普通调用者身份创建提交（不能提交到不可见的题目）。
 func (s *SubmissionService) Create(ctx context.Context, userID, problemID, language, code string) (domain.Submission, error) {
+    return s.Submit(ctx, CreateSubmissionInput{UserID: userID, ProblemID: problemID, Language: language, Code: code})
+}
+
+// Submit 创建提交并记录所属比赛与题目修订；题目对调用者不可见时返回 ErrProblemUnavailable。
+func (s *SubmissionService) Submit(ctx context.Context, in CreateSubmissionInput) (_ domain.Submission, err error) {
+    problemID, language, code := in.ProblemID, in.Language, in.Code
+    ctx, end := tracing.Start(ctx, "SubmissionService.Create", attribute.String("problem.id", problemID), attribute.String("submission.language", language))
+    defer end(&err)
     if strings.TrimSpace(code) == "" { return domain.Submission{}, ErrEmptyCode }
     if strings.TrimSpace(language) == "" { return domain.Submission{}, ErrLanguageRequired }
-    // 代码长度限制（优先使用环境变量 MAX_SUBMISSION_CODE_BYTES；否则默认 128KB）
-    maxBytes := 128 * 1024
-    if v := os.Getenv("MAX_SUBMISSION_CODE_BYTES"); v != "" { if n, err := strconv.Atoi(v); err == nil && n > 0 { maxBytes = n } }
-    if len(code) > maxBytes { return domain.Submission{}, errors.New("code too large") }
-    sub := domain.Submission{ID: uuid.New().String(), UserID: userID, ProblemID: problemID, Language: language, Code: code, Status: SubmissionStatusPending, CreatedAt: time.Now().UTC(), UpdatedAt: time.Now().UTC(), Version: 1}
+    if len(code) > s.codeLimit() { return domain.Submission{}, errors.New("code too large") }
+    sub := domain.Submission{ID: uuid.New().String(), UserID: in.UserID, ProblemID: problemID, Language: language, Code: code, Status: SubmissionStatusPending, CreatedAt: time.Now().UTC(), UpdatedAt: time.Now().UTC(), Version: 1}
+    if s.problems != nil {
+        p, rid, err := s.problems(ctx, problemID)
+        if err != nil && !errors.Is(err, repository.ErrNotFound) { return domain.Submission{}, err }
+        if err == nil && p.Hidden() && !in.CanSeeHidden { return domain.Submission{}, ErrProblemUnavailable }
+        sub.ProblemRevisionID = rid
+    }
     if err := s.repo.Create(ctx, sub); err != nil { return domain.Submission{}, err }
+    if s.detection != nil { s.detection.Enqueue(ctx, sub) }
     return sub, nil
 }
 
-func (s *SubmissionService) Get(ctx context.Context, id string) (domain.Submission, error) { return s.repo.GetByID(ctx, id) }
+func (s *SubmissionService) Get(ctx context.Context, id string) (_ domain.Submission, err error) {
+    ctx, end := tracing.Start(ctx, "SubmissionService.Get", attribute.String("submission.id", id))
+    defer end(&err)
+    return s.repo.GetByID(ctx, id)
+}
+
+// AICheck 提交的 AI 代码检测结果；未启用检测或该提交未检测时返回 repository.ErrNotFound。
+func (s *SubmissionService) AICheck(ctx context.Context, id string) (domain.SubmissionAICheck, error) {
+    if s.detection == nil { return domain.SubmissionAICheck{}, repository.ErrNotFound }
+    return s.detection.Get(ctx, id)
+}
 
 // UpdateStatus 带状态机校验 + 生成日志
-func (s *SubmissionService) UpdateStatus(ctx context.Context, id string, newStatus string) (domain.Submission, error) {
+func (s *SubmissionService) UpdateStatus(ctx context.Context, id string, newStatus string) (_ domain.Submission, err error) {
+    ctx, end := tracing.Start(ctx, "SubmissionService.UpdateStatus", attribute.String("submission.id", id), attribute.String("submission.status", newStatus))
+    defer end(&err)
     newStatus = strings.TrimSpace(newStatus)
     if !isValidStatus(newStatus) { return domain.Submission{}, ErrInvalidStatus }
     cur, err := s.repo.GetByID(ctx, id)
@@ -95,44 +173,91 @@ func (s *SubmissionService) UpdateStatus(ctx context.Context, id string, newStat
     for _, a := range allowed { if a == newStatus { ok = true; break } }
     if !ok { return domain.Submission{}, ErrInvalidStatusTransition }
     if err := s.repo.UpdateStatus(ctx, id, newStatus, cur.Version); err != nil {
-        if errors.Is(err, ErrSubmissionConflict) { metrics.IncSubmissionConflict() }
+        if errors.Is(err, ErrSubmissionConflict) {
+            metrics.IncSubmissionConflict()
+            logging.FromContext(ctx).Info("submission status update conflict", zap.String("submission_id", id), zap.Int("version", cur.Version), zap.String("to", newStatus))
+        }
         return domain.Submission{}, err
     }
     metrics.ObserveSubmissionTransition(fromStatus, newStatus)
+    if isTerminalStatus(newStatus) { metrics.IncJudgeVerdict(cur.ProblemID, newStatus) }
     cur.Status = newStatus
     cur.Version += 1
     cur.UpdatedAt = time.Now().UTC()
-    if s.logRepo != nil { _ = s.logRepo.Add(ctx, domain.SubmissionStatusLog{SubmissionID: cur.ID, FromStatus: fromStatus, ToStatus: newStatus}) }
+    // 日志时间即事件 ID，断线续传据此从日志补发（截断到微秒，与 timestamptz 精度一致）
+    entry := domain.SubmissionStatusLog{SubmissionID: cur.ID, FromStatus: fromStatus, ToStatus: newStatus, CreatedAt: cur.UpdatedAt.Truncate(time.Microsecond)}
+    if s.logRepo != nil {
+        // 状态已更新成功，日志写入失败不回滚，仅记录（影响审计与 SSE 续传）
+        if lerr := s.logRepo.Add(ctx, entry); lerr != nil {
+            logging.FromContext(ctx).Warn("submission status log write failed", zap.String("submission_id", cur.ID), zap.String("to", newStatus), zap.Error(lerr))
+        }
+    }
+    if s.events != nil {
+        for _, ev := range statusEvents(entry, cur.UserID) { s.events.Publish(ctx, ev) }
+    }
     return cur, nil
 }
 
-type SubmissionListFilter struct {
-    UserID    string
-    ProblemID string
-    Status    string
-    Limit     int
-    Offset    int
+// statusEvents 一条状态日志对应的事件序列。
+func statusEvents(l domain.SubmissionStatusLog, userID string) []events.Event {
+    id := events.IDAt(l.CreatedAt)
+    out := []events.Event{{ID: id, Type: events.TypeStatusUpdate, SubmissionID: l.SubmissionID, UserID: userID,
+        Payload: map[string]any{"status": l.ToStatus, "from": l.FromStatus}}}
+    if isTerminalStatus(l.ToStatus) {
+        out = append(out, events.Event{ID: id, Type: events.TypeCompleted, SubmissionID: l.SubmissionID, UserID: userID,
+            Payload: map[string]any{"status": l.ToStatus}})
+    }
+    return out
+}
+
+// maxReplayLogs 单次续传最多补发的日志条数，更早的变更由客户端重新拉取详情获得
+const maxReplayLogs = 200
+
+// EventsSince 由状态日志重建 since 之后的事件（断线续传）。submissionID 为空表示全部提交；
+// userID 非空时仅保留该用户的提交。
+func (s *SubmissionService) EventsSince(ctx context.Context, since time.Time, submissionID, userID string) (_ []events.Event, err error) {
+    ctx, end := tracing.Start(ctx, "SubmissionService.EventsSince")
+    defer end(&err)
+    if s.logRepo == nil { return nil, nil }
+    logs, err := s.logRepo.ListSince(ctx, since, submissionID, maxReplayLogs)
+    if err != nil { return nil, err }
+    owners := map[string]string{}
+    out := make([]events.Event, 0, len(logs))
+    for _, l := range logs {
+        owner, ok := owners[l.SubmissionID]
+        if !ok {
+            sub, err := s.repo.GetByID(ctx, l.SubmissionID)
+            if err != nil && !errors.Is(err, ErrSubmissionNotFound) { return nil, err }
+            owner = sub.UserID
+            owners[l.SubmissionID] = owner
+        }
+        if owner == "" || (userID != "" && owner != userID) { continue }
+        out = append(out, statusEvents(l, owner)...)
+    }
+    return out, nil
 }
 
-func (s *SubmissionService) List(ctx context.Context, f SubmissionListFilter) ([]domain.Submission, error) {
-    limit := f.Limit; offset := f.Offset
-    if limit <= 0 { limit = 20 }
-    return s.repo.List(ctx, repository.SubmissionFilter{UserID: f.UserID, ProblemID: f.ProblemID, Status: f.Status}, limit, offset)
+// List 按 repository.SubmissionListSchema 解析出的条件分页查询，返回本页与下一页游标。
+func (s *SubmissionService) List(ctx context.Context, spec listquery.Spec) (_ []domain.Submission, _ string, err error) {
+    ctx, end := tracing.Start(ctx, "SubmissionService.List")
+    defer end(&err)
+    return s.repo.List(ctx, spec)
 }
 
-// ListWithTotal 返回列表与符合过滤条件的总数（不受分页影响）。
-func (s *SubmissionService) ListWithTotal(ctx context.Context, f SubmissionListFilter) ([]domain.Submission, int, error) {
-    limit := f.Limit; offset := f.Offset
-    if limit <= 0 { limit = 20 }
-    filter := repository.SubmissionFilter{UserID: f.UserID, ProblemID: f.ProblemID, Status: f.Status}
-    total, err := s.repo.Count(ctx, filter)
-    if err != nil { return nil, 0, err }
-    items, err := s.repo.List(ctx, filter, limit, offset)
-    if err != nil { return nil, 0, err }
-    return items, total, nil
+// ListWithTotal 返回列表、下一页游标与符合过滤条件的总数（不受分页影响）。
+func (s *SubmissionService) ListWithTotal(ctx context.Context, spec listquery.Spec) (_ []domain.Submission, _ string, _ int, err error) {
+    ctx, end := tracing.Start(ctx, "SubmissionService.ListWithTotal")
+    defer end(&err)
+    total, err := s.repo.Count(ctx, spec)
+    if err != nil { return nil, "", 0, err }
+    items, next, err := s.repo.List(ctx, spec)
+    if err != nil { return nil, "", 0, err }
+    return items, next, total, nil
 }
 
-func (s *SubmissionService) ListStatusLogs(ctx context.Context, submissionID string, limit, offset int) ([]domain.SubmissionStatusLog, error) {
-    if s.logRepo == nil { return []domain.SubmissionStatusLog{}, nil }
-    return s.logRepo.ListBySubmission(ctx, submissionID, limit, offset)
+func (s *SubmissionService) ListStatusLogs(ctx context.Context, submissionID string, spec listquery.Spec) (_ []domain.SubmissionStatusLog, _ string, err error) {
+    ctx, end := tracing.Start(ctx, "SubmissionService.ListStatusLogs", attribute.String("submission.id", submissionID))
+    defer end(&err)
+    if s.logRepo == nil { return []domain.SubmissionStatusLog{}, "", nil }
+    return s.logRepo.ListBySubmission(ctx, submissionID, spec)
 }
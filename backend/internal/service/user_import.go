@@ -0,0 +1,226 @@
+package service
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/YangYuS8/codyssey/backend/internal/auth"
+	"github.com/YangYuS8/codyssey/backend/internal/domain"
+	"github.com/google/uuid"
+)
+
+// MaxImportRows 单次导入上限（不含表头）。
+const MaxImportRows = 2000
+
+// 导入 CSV 支持的列；username 必填，其余可省略。roles 列内多个角色以 ";" 分隔。
+var importColumns = map[string]bool{"username": true, "email": true, "display_name": true, "roles": true, "password": true}
+
+// 可通过导入分配的角色
+var importableRoles = map[string]bool{auth.RoleSystemAdmin: true, auth.RoleTeacher: true, auth.RoleStudent: true, auth.RoleContestant: true}
+
+var (
+    ErrImportInvalid  = errors.New("import contains invalid rows")
+    ErrImportEmpty    = errors.New("import file has no data rows")
+    ErrImportTooLarge = fmt.Errorf("import exceeds %d rows", MaxImportRows)
+)
+
+// ImportHeaderError 表头缺少 username 或含未知列。
+type ImportHeaderError struct{ Msg string }
+
+func (e *ImportHeaderError) Error() string { return "invalid csv header: " + e.Msg }
+
+type ImportOptions struct {
+    DryRun       bool
+    DefaultRoles []string // roles 列为空时使用，默认 student
+}
+
+// ImportRowError Line 为 CSV 中的行号（表头为第 1 行）。
+type ImportRowError struct {
+    Line     int    `json:"line"`
+    Username string `json:"username,omitempty"`
+    Field    string `json:"field"`
+    Message  string `json:"message"`
+}
+
+// ImportedUser 凭据清单中的一行；Password 仅在实际导入后出现。
+type ImportedUser struct {
+    Line              int      `json:"line"`
+    ID                string   `json:"id,omitempty"`
+    Username          string   `json:"username"`
+    Email             string   `json:"email,omitempty"`
+    DisplayName       string   `json:"display_name,omitempty"`
+    Roles             []string `json:"roles"`
+    Password          string   `json:"password,omitempty"`
+    PasswordGenerated bool     `json:"password_generated"`
+}
+
+type ImportResult struct {
+    DryRun  bool             `json:"dry_run"`
+    Total   int              `json:"total"`
+    Created int              `json:"created"`
+    Errors  []ImportRowError `json:"errors"`
+    Users   []ImportedUser   `json:"users"`
+}
+
+type importRow struct {
+    user     ImportedUser
+    password string
+}
+
+// ImportUsers 解析 CSV 并批量创建用户。全部行校验通过才会写库（单事务），
+// 否则返回 ErrImportInvalid 与逐行错误；DryRun 只校验不写库、不生成密码。
+func (s *UserService) ImportUsers(ctx context.Context, r io.Reader, opts ImportOptions) (ImportResult, error) {
+    res := ImportResult{DryRun: opts.DryRun, Errors: []ImportRowError{}, Users: []ImportedUser{}}
+    defaultRoles := opts.DefaultRoles
+    if len(defaultRoles) == 0 { defaultRoles = []string{auth.RoleStudent} }
+
+    cr := csv.NewReader(r)
+    cr.FieldsPerRecord = -1
+    cr.TrimLeadingSpace = true
+    header, err := cr.Read()
+    if err == io.EOF { return res, ErrImportEmpty }
+    if err != nil { return res, &ImportHeaderError{Msg: err.Error()} }
+    cols := map[string]int{}
+    for i, h := range header {
+        name := strings.ToLower(strings.TrimSpace(strings.TrimPrefix(h, "\ufeff"))) // Excel 导出的 UTF-8 BOM
+        if !importColumns[name] { return res, &ImportHeaderError{Msg: fmt.Sprintf("unknown column %q", h)} }
+        if _, dup := cols[name]; dup { return res, &ImportHeaderError{Msg: fmt.Sprintf("duplicate column %q", name)} }
+        cols[name] = i
+    }
+    if _, ok := cols["username"]; !ok { return res, &ImportHeaderError{Msg: "missing username column"} }
+
+    var rows []importRow
+    seenUser, seenEmail := map[string]int{}, map[string]int{}
+    for {
+        rec, err := cr.Read()
+        if err == io.EOF { break }
+        if err != nil {
+            // 格式错误（如未转义的引号）时不能调用 FieldPos，行号取自 ParseError
+            var pe *csv.ParseError
+            if !errors.As(err, &pe) { return res, err }
+            line := pe.StartLine
+            if line == 0 { line = pe.Line }
+            res.Errors = append(res.Errors, ImportRowError{Line: line, Field: "row", Message: err.Error()})
+            continue
+        }
+        line, _ := cr.FieldPos(0)
+        get := func(col string) string {
+            if i, ok := cols[col]; ok && i < len(rec) { return strings.TrimSpace(rec[i]) }
+            return ""
+        }
+        if strings.TrimSpace(strings.Join(rec, "")) == "" { continue } // 跳过空行
+        res.Total++
+        if res.Total > MaxImportRows { return ImportResult{DryRun: opts.DryRun, Errors: []ImportRowError{}, Users: []ImportedUser{}}, ErrImportTooLarge }
+
+        row := importRow{user: ImportedUser{Line: line, Username: get("username"), DisplayName: get("display_name")}, password: get("password")}
+        fail := func(field, msg string) { res.Errors = append(res.Errors, ImportRowError{Line: line, Username: row.user.Username, Field: field, Message: msg}) }
+        before := len(res.Errors)
+
+        switch n := len([]rune(row.user.Username)); {
+        case n == 0:
+            fail("username", "required")
+        case n < 3 || n > 50:
+            fail("username", "must be 3-50 characters")
+        default:
+            if prev, ok := seenUser[row.user.Username]; ok {
+                fail("username", fmt.Sprintf("duplicate of line %d", prev))
+            } else {
+                seenUser[row.user.Username] = line
+                if _, err := s.repo.GetByUsername(ctx, row.user.Username); err == nil {
+                    fail("username", "already exists")
+                } else if !errors.Is(err, ErrUserNotFound) { return res, err }
+            }
+        }
+        if email, err := normalizeEmail(get("email")); err != nil {
+            fail("email", "invalid email address")
+        } else if email != "" {
+            row.user.Email = email
+            key := strings.ToLower(email)
+            if prev, ok := seenEmail[key]; ok {
+                fail("email", fmt.Sprintf("duplicate of line %d", prev))
+            } else {
+                seenEmail[key] = line
+                if _, err := s.repo.GetByEmail(ctx, email); err == nil {
+                    fail("email", "already in use")
+                } else if !errors.Is(err, ErrUserNotFound) { return res, err }
+            }
+        }
+        if len([]rune(row.user.DisplayName)) > 64 { fail("display_name", "too long (max 64)") }
+        row.user.Roles = defaultRoles
+        if raw := get("roles"); raw != "" {
+            row.user.Roles = nil
+            for _, part := range strings.Split(raw, ";") {
+                role := strings.TrimSpace(part)
+                if role == "" { continue }
+                if !importableRoles[role] { fail("roles", fmt.Sprintf("unknown role %q", role)); continue }
+                row.user.Roles = append(row.user.Roles, role)
+            }
+        }
+        if row.password != "" {
+            if err := auth.ValidatePassword(row.password); err != nil { fail("password", fmt.Sprintf("must be at least %d characters", auth.MinPasswordLength)) }
+        } else {
+            row.user.PasswordGenerated = true
+        }
+        if len(res.Errors) == before { rows = append(rows, row) }
+    }
+    if res.Total == 0 && len(res.Errors) == 0 { return res, ErrImportEmpty }
+    if opts.DryRun {
+        for _, row := range rows { res.Users = append(res.Users, row.user) }
+        return res, nil
+    }
+    if len(res.Errors) > 0 { return res, ErrImportInvalid }
+
+    users, err := buildImportUsers(rows)
+    if err != nil { return res, err }
+    if err := s.repo.CreateBatch(ctx, users); err != nil { return res, err }
+    for i := range rows {
+        rows[i].user.ID = users[i].ID
+        rows[i].user.Password = rows[i].password
+        res.Users = append(res.Users, rows[i].user)
+    }
+    res.Created = len(users)
+    return res, nil
+}
+
+// buildImportUsers 生成缺省密码并并行计算 bcrypt（逐个串行时数百行需要数十秒）。
+func buildImportUsers(rows []importRow) ([]domain.User, error) {
+    now := time.Now().UTC()
+    users := make([]domain.User, len(rows))
+    for i := range rows {
+        if rows[i].password == "" {
+            p, err := RandomPassword(12)
+            if err != nil { return nil, err }
+            rows[i].password = p
+        }
+        u := rows[i].user
+        users[i] = domain.User{ID: uuid.New().String(), Username: u.Username, Roles: u.Roles, Email: u.Email, DisplayName: u.DisplayName, CreatedAt: now}
+    }
+    var (
+        wg       sync.WaitGroup
+        mu       sync.Mutex
+        firstErr error
+    )
+    next := make(chan int)
+    for w := 0; w < runtime.NumCPU(); w++ {
+        wg.Add(1)
+        go func() {
+            defer wg.Done()
+            for i := range next {
+                hash, err := auth.HashPassword(rows[i].password)
+                if err != nil { mu.Lock(); if firstErr == nil { firstErr = err }; mu.Unlock(); continue }
+                users[i].PasswordHash = hash
+            }
+        }()
+    }
+    for i := range rows { next <- i }
+    close(next)
+    wg.Wait()
+    return users, firstErr
+}
@@ -0,0 +1,108 @@
+package service_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/YangYuS8/codyssey/backend/internal/auth"
+	"github.com/YangYuS8/codyssey/backend/internal/repository"
+	"github.com/YangYuS8/codyssey/backend/internal/service"
+)
+
+func TestImportUsers_ValidationAndDryRun(t *testing.T) {
+    ctx := context.Background()
+    repo := repository.NewMemoryUserRepository()
+    svc := service.NewUserService(repo)
+    _, err := svc.Create(ctx, service.CreateUserInput{Username: "taken", Email: "taken@example.edu"})
+    require.NoError(t, err)
+
+    csv := "\ufeffUsername,email,display_name,roles,password\n" +
+        "s001,s001@example.edu,张三,,\n" +
+        "s002,S001@example.edu,李四,student;contestant,secret1\n" + // 邮箱与第 2 行重复（大小写不敏感）
+        "taken,,,,\n" +
+        "s003,bad-email,,wizard,123\n" +
+        "\n" +
+        "s001,,,,\n"
+    res, err := svc.ImportUsers(ctx, strings.NewReader(csv), service.ImportOptions{DryRun: true})
+    require.NoError(t, err)
+    require.True(t, res.DryRun)
+    require.Equal(t, 5, res.Total)
+    fields := map[int][]string{}
+    for _, e := range res.Errors { fields[e.Line] = append(fields[e.Line], e.Field) }
+    require.Equal(t, map[int][]string{
+        3: {"email"},
+        4: {"username"},
+        5: {"email", "roles", "password"},
+        7: {"username"},
+    }, fields)
+    require.Len(t, res.Users, 1)
+    require.Equal(t, []string{auth.RoleStudent}, res.Users[0].Roles)
+    require.True(t, res.Users[0].PasswordGenerated)
+    require.Empty(t, res.Users[0].Password, "dry run 不生成密码")
+
+    res, err = svc.ImportUsers(ctx, strings.NewReader(csv), service.ImportOptions{})
+    require.ErrorIs(t, err, service.ErrImportInvalid)
+    require.NotEmpty(t, res.Errors)
+    _, err = svc.GetByUsername(ctx, "s001")
+    require.ErrorIs(t, err, service.ErrUserNotFound, "存在错误行时不写入任何用户")
+}
+
+func TestImportUsers_CreatesWithPasswords(t *testing.T) {
+    ctx := context.Background()
+    svc := service.NewUserService(repository.NewMemoryUserRepository())
+    csv := "username,password,roles\nt100,given-pass,teacher\nt101,,\n"
+    res, err := svc.ImportUsers(ctx, strings.NewReader(csv), service.ImportOptions{})
+    require.NoError(t, err)
+    require.Equal(t, 2, res.Created)
+    require.Len(t, res.Users, 2)
+    require.Equal(t, "given-pass", res.Users[0].Password)
+    require.False(t, res.Users[0].PasswordGenerated)
+    require.Len(t, res.Users[1].Password, 12)
+    require.True(t, res.Users[1].PasswordGenerated)
+
+    for _, iu := range res.Users {
+        u, err := svc.GetByUsername(ctx, iu.Username)
+        require.NoError(t, err)
+        require.Equal(t, iu.ID, u.ID)
+        require.True(t, auth.CheckPassword(u.PasswordHash, iu.Password))
+    }
+    u, _ := svc.GetByUsername(ctx, "t100")
+    require.Equal(t, []string{auth.RoleTeacher}, u.Roles)
+}
+
+func TestImportUsers_BadInput(t *testing.T) {
+    ctx := context.Background()
+    svc := service.NewUserService(repository.NewMemoryUserRepository())
+    var headerErr *service.ImportHeaderError
+    _, err := svc.ImportUsers(ctx, strings.NewReader("email\na@example.edu\n"), service.ImportOptions{})
+    require.ErrorAs(t, err, &headerErr)
+    _, err = svc.ImportUsers(ctx, strings.NewReader("username,phone\nx,1\n"), service.ImportOptions{})
+    require.ErrorAs(t, err, &headerErr)
+    _, err = svc.ImportUsers(ctx, strings.NewReader("username\n"), service.ImportOptions{})
+    require.ErrorIs(t, err, service.ErrImportEmpty)
+    _, err = svc.ImportUsers(ctx, strings.NewReader(""), service.ImportOptions{})
+    require.ErrorIs(t, err, service.ErrImportEmpty)
+}
+
+// 格式错误的行（未转义的引号）记为逐行错误，不影响其余行的校验。
+func TestImportUsers_MalformedRow(t *testing.T) {
+    ctx := context.Background()
+    svc := service.NewUserService(repository.NewMemoryUserRepository())
+    csv := "username,display_name\n" +
+        "s001,张三\n" +
+        "s002,李\"四\n" +
+        "s003,\"王五\n"
+    var res service.ImportResult
+    var err error
+    require.NotPanics(t, func() { res, err = svc.ImportUsers(ctx, strings.NewReader(csv), service.ImportOptions{DryRun: true}) })
+    require.NoError(t, err)
+    require.Len(t, res.Users, 1)
+    require.Len(t, res.Errors, 2)
+    require.Equal(t, 3, res.Errors[0].Line)
+    require.Equal(t, "row", res.Errors[0].Field)
+    require.Equal(t, 4, res.Errors[1].Line)
+    require.Equal(t, "row", res.Errors[1].Field)
+}
@@ -2,28 +2,62 @@ package service
 
 import (
 	"context"
+	"crypto/rand"
+	"errors"
+	"math/big"
+	"net/mail"
+	"strings"
 	"time"
 
+	"github.com/YangYuS8/codyssey/backend/internal/auth"
 	"github.com/YangYuS8/codyssey/backend/internal/domain"
+	"github.com/YangYuS8/codyssey/backend/internal/listquery"
 	"github.com/YangYuS8/codyssey/backend/internal/repository"
 	"github.com/google/uuid"
 )
 
 type UserRepo interface {
     Create(ctx context.Context, u domain.User) error
+    CreateBatch(ctx context.Context, users []domain.User) error
     GetByID(ctx context.Context, id string) (domain.User, error)
     GetByUsername(ctx context.Context, username string) (domain.User, error)
+    GetByEmail(ctx context.Context, email string) (domain.User, error)
     UpdateRoles(ctx context.Context, id string, roles []string) error
+    UpdateProfile(ctx context.Context, u domain.User) error
+    UpdatePassword(ctx context.Context, id, hash string, changedAt time.Time) error
     Delete(ctx context.Context, id string) error
-    List(ctx context.Context, limit, offset int) ([]domain.User, error)
+    List(ctx context.Context, spec listquery.Spec) ([]domain.User, string, error)
 }
 
-type UserService struct { repo UserRepo }
+type UserService struct {
+    repo    UserRepo
+    account *accountMail // nil 表示未配置发信（邮箱验证 / 找回密码不可用）
+}
 
 func NewUserService(r UserRepo) *UserService { return &UserService{repo: r} }
 
-func (s *UserService) Create(ctx context.Context, username string, roles []string) (domain.User, error) {
-    u := domain.User{ID: uuid.New().String(), Username: username, Roles: roles, CreatedAt: time.Now().UTC()}
+// CreateUserInput 管理端创建用户；Password 为空表示不设本地密码（例如仅允许 LDAP 登录）。
+type CreateUserInput struct {
+    Username      string
+    Roles         []string
+    Password      string
+    Email         string
+    DisplayName   string
+    School        string
+    StudentNumber string
+}
+
+func (s *UserService) Create(ctx context.Context, in CreateUserInput) (domain.User, error) {
+    u := domain.User{ID: uuid.New().String(), Username: strings.TrimSpace(in.Username), Roles: in.Roles, CreatedAt: time.Now().UTC(),
+        DisplayName: strings.TrimSpace(in.DisplayName), School: strings.TrimSpace(in.School), StudentNumber: strings.TrimSpace(in.StudentNumber)}
+    email, err := normalizeEmail(in.Email)
+    if err != nil { return domain.User{}, err }
+    u.Email = email
+    if in.Password != "" {
+        hash, err := auth.HashPassword(in.Password)
+        if err != nil { return domain.User{}, err }
+        u.PasswordHash = hash
+    }
     if err := s.repo.Create(ctx, u); err != nil { return domain.User{}, err }
     return u, nil
 }
@@ -44,12 +78,115 @@ func (s *UserService) Delete(ctx context.Context, id string) error {
     return s.repo.Delete(ctx, id)
 }
 
-func (s *UserService) List(ctx context.Context, limit, offset int) ([]domain.User, error) {
-    return s.repo.List(ctx, limit, offset)
+func (s *UserService) List(ctx context.Context, spec listquery.Spec) ([]domain.User, string, error) {
+    return s.repo.List(ctx, spec)
+}
+
+// ProfilePatch 自助修改资料；nil 字段保持不变。
+type ProfilePatch struct {
+    DisplayName   *string
+    Email         *string
+    School        *string
+    StudentNumber *string
+}
+
+// UpdateProfile 修改邮箱会清除已验证状态。
+func (s *UserService) UpdateProfile(ctx context.Context, id string, p ProfilePatch) (domain.User, error) {
+    u, err := s.repo.GetByID(ctx, id)
+    if err != nil { return domain.User{}, err }
+    if p.DisplayName != nil {
+        v := strings.TrimSpace(*p.DisplayName)
+        if len([]rune(v)) > 64 { return domain.User{}, ErrProfileFieldTooLong }
+        u.DisplayName = v
+    }
+    if p.School != nil {
+        v := strings.TrimSpace(*p.School)
+        if len([]rune(v)) > 128 { return domain.User{}, ErrProfileFieldTooLong }
+        u.School = v
+    }
+    if p.StudentNumber != nil {
+        v := strings.TrimSpace(*p.StudentNumber)
+        if len(v) > 32 { return domain.User{}, ErrProfileFieldTooLong }
+        u.StudentNumber = v
+    }
+    if p.Email != nil {
+        email, err := normalizeEmail(*p.Email)
+        if err != nil { return domain.User{}, err }
+        if !strings.EqualFold(email, u.Email) { u.EmailVerified = false }
+        u.Email = email
+    }
+    if err := s.repo.UpdateProfile(ctx, u); err != nil { return domain.User{}, err }
+    return u, nil
+}
+
+// ChangePassword 需提供当前密码；外部目录用户没有本地密码，不可修改。
+func (s *UserService) ChangePassword(ctx context.Context, id, oldPassword, newPassword string) error {
+    u, err := s.repo.GetByID(ctx, id)
+    if err != nil { return err }
+    if u.PasswordHash == "" { return ErrNoLocalPassword }
+    if !auth.CheckPassword(u.PasswordHash, oldPassword) { return ErrWrongPassword }
+    return s.setPassword(ctx, id, newPassword)
+}
+
+// ResetPassword 管理员重置；newPassword 为空时生成随机密码并返回（仅此一次）。
+func (s *UserService) ResetPassword(ctx context.Context, id, newPassword string) (string, error) {
+    if _, err := s.repo.GetByID(ctx, id); err != nil { return "", err }
+    generated := ""
+    if newPassword == "" {
+        p, err := RandomPassword(12)
+        if err != nil { return "", err }
+        newPassword, generated = p, p
+    }
+    if err := s.setPassword(ctx, id, newPassword); err != nil { return "", err }
+    return generated, nil
+}
+
+func (s *UserService) setPassword(ctx context.Context, id, password string) error {
+    hash, err := auth.HashPassword(password)
+    if err != nil { return err }
+    now := time.Now().UTC()
+    if err := s.repo.UpdatePassword(ctx, id, hash, now); err != nil { return err }
+    // 未使用的找回密码令牌一并作废
+    if s.account != nil { return s.account.tokens.InvalidateUser(ctx, id, domain.UserTokenPasswordReset, now) }
+    return nil
+}
+
+const passwordAlphabet = "ABCDEFGHJKLMNPQRSTUVWXYZabcdefghijkmnpqrstuvwxyz23456789"
+
+// RandomPassword 生成不含易混淆字符（0/O、1/l/I）的随机密码。
+func RandomPassword(n int) (string, error) {
+    b := make([]byte, n)
+    max := big.NewInt(int64(len(passwordAlphabet)))
+    for i := range b {
+        k, err := rand.Int(rand.Reader, max)
+        if err != nil { return "", err }
+        b[i] = passwordAlphabet[k.Int64()]
+    }
+    return string(b), nil
+}
+
+// normalizeEmail 空串表示清除；否则必须是单个裸地址（不接受 "Name <addr>" 形式）。
+func normalizeEmail(raw string) (string, error) {
+    v := strings.TrimSpace(raw)
+    if v == "" { return "", nil }
+    a, err := mail.ParseAddress(v)
+    if err != nil || a.Address != v || len(v) > 254 { return "", ErrInvalidEmail }
+    return v, nil
 }
 
 // 错误透传：由 handler 统一转换为响应格式
 var (
     ErrUserNotFound   = repository.ErrUserNotFound
     ErrUserDuplicate  = repository.ErrUserDuplicate
+    ErrEmailDuplicate = repository.ErrEmailDuplicate
+    ErrWeakPassword   = auth.ErrWeakPassword
+    ErrTokenInvalid   = repository.ErrUserTokenInvalid
+
+    ErrInvalidEmail        = errors.New("invalid email address")
+    ErrProfileFieldTooLong = errors.New("profile field too long")
+    ErrWrongPassword       = errors.New("current password is incorrect")
+    ErrNoLocalPassword     = errors.New("account has no local password")
+    ErrMailDisabled        = errors.New("mail delivery not configured")
+    ErrNoEmail             = errors.New("no email address on account")
+    ErrEmailAlreadyVerified = errors.New("email already verified")
 )
@@ -0,0 +1,127 @@
+package service_test
+
+import (
+	"context"
+	"net/url"
+	"regexp"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/YangYuS8/codyssey/backend/internal/auth"
+	"github.com/YangYuS8/codyssey/backend/internal/mail"
+	"github.com/YangYuS8/codyssey/backend/internal/repository"
+	"github.com/YangYuS8/codyssey/backend/internal/service"
+)
+
+type recordSender struct{ sent []mail.Message }
+
+func (r *recordSender) Send(_ context.Context, m mail.Message) error { r.sent = append(r.sent, m); return nil }
+
+var tokenRe = regexp.MustCompile(`token=(\S+)`)
+
+func lastToken(t *testing.T, r *recordSender) string {
+    t.Helper()
+    require.NotEmpty(t, r.sent)
+    m := tokenRe.FindStringSubmatch(r.sent[len(r.sent)-1].Body)
+    require.Len(t, m, 2)
+    raw, err := url.QueryUnescape(m[1])
+    require.NoError(t, err)
+    return raw
+}
+
+func newMailUserService() (*service.UserService, *recordSender) {
+    svc := service.NewUserService(repository.NewMemoryUserRepository())
+    sender := &recordSender{}
+    svc.EnableMail(repository.NewMemoryUserTokenRepository(), sender, service.MailOptions{PublicBaseURL: "https://oj.example.edu/"})
+    return svc, sender
+}
+
+func strp(s string) *string { return &s }
+
+func TestUserService_ProfileAndPassword(t *testing.T) {
+    ctx := context.Background()
+    svc := service.NewUserService(repository.NewMemoryUserRepository())
+
+    _, err := svc.Create(ctx, service.CreateUserInput{Username: "weak", Password: "short"})
+    require.ErrorIs(t, err, service.ErrWeakPassword)
+    _, err = svc.Create(ctx, service.CreateUserInput{Username: "bad", Email: "not-an-email"})
+    require.ErrorIs(t, err, service.ErrInvalidEmail)
+
+    u, err := svc.Create(ctx, service.CreateUserInput{Username: "alice", Password: "correct-horse-1", Email: "Alice@Example.edu", Roles: []string{"student"}})
+    require.NoError(t, err)
+    require.Equal(t, "Alice@Example.edu", u.Email)
+    _, err = svc.Create(ctx, service.CreateUserInput{Username: "alice2", Email: "ALICE@example.edu"})
+    require.ErrorIs(t, err, service.ErrEmailDuplicate)
+
+    u, err = svc.UpdateProfile(ctx, u.ID, service.ProfilePatch{DisplayName: strp("Alice"), School: strp("CS")})
+    require.NoError(t, err)
+    require.Equal(t, "Alice", u.DisplayName)
+    require.Equal(t, "Alice@Example.edu", u.Email, "未提供的字段保持不变")
+
+    require.ErrorIs(t, svc.ChangePassword(ctx, u.ID, "wrong-password", "another-pass-2"), service.ErrWrongPassword)
+    require.ErrorIs(t, svc.ChangePassword(ctx, u.ID, "correct-horse-1", "short"), service.ErrWeakPassword)
+    require.NoError(t, svc.ChangePassword(ctx, u.ID, "correct-horse-1", "another-pass-2"))
+    got, _ := svc.Get(ctx, u.ID)
+    require.True(t, auth.CheckPassword(got.PasswordHash, "another-pass-2"))
+    require.NotNil(t, got.PasswordChangedAt)
+
+    generated, err := svc.ResetPassword(ctx, u.ID, "")
+    require.NoError(t, err)
+    require.Len(t, generated, 12)
+    got, _ = svc.Get(ctx, u.ID)
+    require.True(t, auth.CheckPassword(got.PasswordHash, generated))
+
+    ldapUser, err := svc.Create(ctx, service.CreateUserInput{Username: "bob"})
+    require.NoError(t, err)
+    require.ErrorIs(t, svc.ChangePassword(ctx, ldapUser.ID, "", "another-pass-2"), service.ErrNoLocalPassword)
+}
+
+func TestUserService_EmailVerificationAndReset(t *testing.T) {
+    ctx := context.Background()
+    svc, sender := newMailUserService()
+    u, err := svc.Create(ctx, service.CreateUserInput{Username: "carol", Password: "correct-horse-1", Email: "carol@example.edu"})
+    require.NoError(t, err)
+
+    // 未验证邮箱不发送找回密码邮件，也不暴露原因
+    require.NoError(t, svc.RequestPasswordReset(ctx, "carol"))
+    require.NoError(t, svc.RequestPasswordReset(ctx, "nobody"))
+    require.Empty(t, sender.sent)
+
+    require.NoError(t, svc.RequestEmailVerification(ctx, u.ID))
+    require.Contains(t, sender.sent[0].Body, "https://oj.example.edu/verify-email?token=")
+    verifyTok := lastToken(t, sender)
+    got, err := svc.VerifyEmail(ctx, verifyTok)
+    require.NoError(t, err)
+    require.True(t, got.EmailVerified)
+    _, err = svc.VerifyEmail(ctx, verifyTok)
+    require.ErrorIs(t, err, service.ErrTokenInvalid, "令牌只能使用一次")
+    require.ErrorIs(t, svc.RequestEmailVerification(ctx, u.ID), service.ErrEmailAlreadyVerified)
+
+    require.NoError(t, svc.RequestPasswordReset(ctx, "CAROL@example.edu"))
+    resetTok := lastToken(t, sender)
+    require.ErrorIs(t, svc.ResetPasswordWithToken(ctx, resetTok, "short"), service.ErrWeakPassword)
+    require.NoError(t, svc.ResetPasswordWithToken(ctx, resetTok, "brand-new-pass-3"), "弱密码不应消耗令牌")
+    require.ErrorIs(t, svc.ResetPasswordWithToken(ctx, resetTok, "brand-new-pass-4"), service.ErrTokenInvalid)
+    got, _ = svc.Get(ctx, u.ID)
+    require.True(t, auth.CheckPassword(got.PasswordHash, "brand-new-pass-3"))
+
+    // 修改邮箱后验证状态清除，旧验证令牌失效
+    require.NoError(t, svc.RequestPasswordReset(ctx, "carol"))
+    staleReset := lastToken(t, sender)
+    _, err = svc.UpdateProfile(ctx, u.ID, service.ProfilePatch{Email: strp("carol@new.example.edu")})
+    require.NoError(t, err)
+    require.NoError(t, svc.RequestEmailVerification(ctx, u.ID))
+    newTok := lastToken(t, sender)
+    _, err = svc.UpdateProfile(ctx, u.ID, service.ProfilePatch{Email: strp("carol@other.example.edu")})
+    require.NoError(t, err)
+    _, err = svc.VerifyEmail(ctx, newTok)
+    require.ErrorIs(t, err, service.ErrTokenInvalid)
+    got, _ = svc.Get(ctx, u.ID)
+    require.False(t, got.EmailVerified)
+
+    // 管理员重置密码后，尚未使用的找回令牌作废
+    _, err = svc.ResetPassword(ctx, u.ID, "admin-set-pass-5")
+    require.NoError(t, err)
+    require.ErrorIs(t, svc.ResetPasswordWithToken(ctx, staleReset, "brand-new-pass-6"), service.ErrTokenInvalid)
+}
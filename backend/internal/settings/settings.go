@@ -0,0 +1,180 @@
+// Package settings 运行时可热更新的参数：启动配置提供默认值，数据库中的覆盖值经管理 API 或配置文件修改，
+// 变更以不可变快照原子替换，订阅者（日志级别等）在替换后收到通知，请求路径直接读取当前快照。
+package settings
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"go.uber.org/zap/zapcore"
+
+	"github.com/YangYuS8/codyssey/backend/internal/domain"
+	"github.com/YangYuS8/codyssey/backend/internal/ratelimit"
+)
+
+// 可热更新的键
+const (
+    KeyMaxSubmissionCodeBytes = "max_submission_code_bytes"
+    KeyLogLevel               = "log_level"
+    KeyRateLimitPrefix        = "rate_limit."   // rate_limit.<group>，值同 RATE_LIMIT_* 策略格式
+)
+
+// ErrInvalidSetting 未知键或值不合法。
+var ErrInvalidSetting = errors.New("invalid runtime setting")
+
+// Definition 描述一个可热更新的键（管理 API 展示用）。
+type Definition struct {
+    Key         string `json:"key"`
+    Description string `json:"description"`
+}
+
+var rateLimitGroups = []string{ratelimit.GroupLogin, ratelimit.GroupSubmission, ratelimit.GroupJudgeEnqueue}
+
+// Definitions 全部可热更新的键。功能开关由 featureflag 按身份求值，不在运行时参数中。
+func Definitions() []Definition {
+    defs := []Definition{
+        {Key: KeyMaxSubmissionCodeBytes, Description: "提交代码长度上限（字节，正整数）"},
+        {Key: KeyLogLevel, Description: "日志级别 debug | info | warn | error"},
+    }
+    for _, g := range rateLimitGroups {
+        defs = append(defs, Definition{Key: KeyRateLimitPrefix + g, Description: "限流策略 N/duration（如 10/1m），off 禁用"})
+    }
+    return defs
+}
+
+// ValidateKey 只校验键名（恢复默认值时使用）。
+func ValidateKey(key string) error {
+    switch {
+    case key == KeyMaxSubmissionCodeBytes, key == KeyLogLevel:
+        return nil
+    case strings.HasPrefix(key, KeyRateLimitPrefix):
+        if isRateLimitGroup(strings.TrimPrefix(key, KeyRateLimitPrefix)) { return nil }
+    }
+    return fmt.Errorf("%w: unknown key %q", ErrInvalidSetting, key)
+}
+
+// Validate 校验单个键值。
+func Validate(key, value string) error {
+    if err := ValidateKey(key); err != nil { return err }
+    value = strings.TrimSpace(value)
+    bad := func(format string, args ...any) error { return fmt.Errorf("%w: %s: %s", ErrInvalidSetting, key, fmt.Sprintf(format, args...)) }
+    switch {
+    case key == KeyMaxSubmissionCodeBytes:
+        if n, err := strconv.Atoi(value); err != nil || n <= 0 { return bad("expected a positive integer") }
+    case key == KeyLogLevel:
+        if _, err := parseLevel(value); err != nil { return bad("expected debug, info, warn or error") }
+    case strings.HasPrefix(key, KeyRateLimitPrefix):
+        if _, err := ratelimit.ParsePolicy(strings.TrimPrefix(key, KeyRateLimitPrefix), value); err != nil { return bad("%v", err) }
+    }
+    return nil
+}
+
+func isRateLimitGroup(g string) bool {
+    for _, x := range rateLimitGroups { if x == g { return true } }
+    return false
+}
+
+func parseLevel(s string) (zapcore.Level, error) {
+    switch strings.ToLower(strings.TrimSpace(s)) {
+    case "debug": return zapcore.DebugLevel, nil
+    case "info": return zapcore.InfoLevel, nil
+    case "warn": return zapcore.WarnLevel, nil
+    case "error": return zapcore.ErrorLevel, nil
+    }
+    return zapcore.InfoLevel, fmt.Errorf("unknown level %q", s)
+}
+
+// Defaults 启动配置中的默认值。
+type Defaults struct {
+    MaxSubmissionCodeBytes int
+    LogLevel               string
+    RateLimits             map[string]string // 分组 -> 策略
+}
+
+func (d Defaults) values() map[string]string {
+    m := map[string]string{KeyMaxSubmissionCodeBytes: strconv.Itoa(d.MaxSubmissionCodeBytes), KeyLogLevel: d.LogLevel}
+    for g, spec := range d.RateLimits { m[KeyRateLimitPrefix+g] = spec }
+    return m
+}
+
+// Snapshot 某一版本的生效参数，构建后不可变，可在请求间安全共享。
+type Snapshot struct {
+    Version   int64
+    values    map[string]string
+    overrides map[string]domain.RuntimeSetting
+
+    maxCodeBytes int
+    logLevel     zapcore.Level
+    rateLimits   map[string]ratelimit.Policy
+}
+
+// buildSnapshot 合并默认值与覆盖值；不合法的覆盖值（如旧版本写入、键已下线）被忽略并返回以便记录。
+func buildSnapshot(version int64, defaults map[string]string, overrides []domain.RuntimeSetting) (*Snapshot, []error) {
+    s := &Snapshot{Version: version, values: map[string]string{}, overrides: map[string]domain.RuntimeSetting{},
+        rateLimits: map[string]ratelimit.Policy{}}
+    for k, v := range defaults { s.values[k] = v }
+    var skipped []error
+    for _, o := range overrides {
+        if err := Validate(o.Key, o.Value); err != nil { skipped = append(skipped, err); continue }
+        s.values[o.Key] = strings.TrimSpace(o.Value)
+        s.overrides[o.Key] = o
+    }
+    for k, v := range s.values {
+        switch {
+        case k == KeyMaxSubmissionCodeBytes:
+            s.maxCodeBytes, _ = strconv.Atoi(v)
+        case k == KeyLogLevel:
+            s.logLevel, _ = parseLevel(v)
+        case strings.HasPrefix(k, KeyRateLimitPrefix):
+            group := strings.TrimPrefix(k, KeyRateLimitPrefix)
+            p, _ := ratelimit.ParsePolicy(group, v)
+            p.ByIP = group == ratelimit.GroupLogin // 未认证入口总是按 IP 计数
+            s.rateLimits[group] = p
+        }
+    }
+    return s, skipped
+}
+
+// MaxSubmissionCodeBytes 提交代码长度上限。
+func (s *Snapshot) MaxSubmissionCodeBytes() int { return s.maxCodeBytes }
+
+// LogLevel 日志级别。
+func (s *Snapshot) LogLevel() zapcore.Level { return s.logLevel }
+
+// RateLimit 分组的限流策略；未配置时为禁用策略。
+func (s *Snapshot) RateLimit(group string) ratelimit.Policy {
+    if p, ok := s.rateLimits[group]; ok { return p }
+    return ratelimit.Policy{Group: group}
+}
+
+// Value 键的生效值及是否被覆盖。
+func (s *Snapshot) Value(key string) (value string, overridden bool) {
+    _, overridden = s.overrides[key]
+    return s.values[key], overridden
+}
+
+// Entry 管理 API 展示的单个键。
+type Entry struct {
+    Key        string  `json:"key"`
+    Value      string  `json:"value"`
+    Default    *string `json:"default"`
+    Overridden bool    `json:"overridden"`
+    UpdatedBy  string  `json:"updated_by,omitempty"`
+    Version    int64   `json:"version,omitempty"` // 最近一次覆盖写入的版本
+}
+
+// Entries 按键排序的全部生效值。
+func (s *Snapshot) Entries(defaults map[string]string) []Entry {
+    out := make([]Entry, 0, len(s.values))
+    for k, v := range s.values {
+        e := Entry{Key: k, Value: v}
+        if d, ok := defaults[k]; ok { d := d; e.Default = &d }
+        if o, ok := s.overrides[k]; ok { e.Overridden, e.UpdatedBy, e.Version = true, o.UpdatedBy, o.Version }
+        out = append(out, e)
+    }
+    sort.Slice(out, func(i, j int) bool { return out[i].Key < out[j].Key })
+    return out
+}
@@ -0,0 +1,150 @@
+package settings
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zapcore"
+
+	"github.com/YangYuS8/codyssey/backend/internal/ratelimit"
+	"github.com/YangYuS8/codyssey/backend/internal/repository"
+)
+
+func strp(s string) *string { return &s }
+
+func newTestStore(t *testing.T, repo repository.RuntimeSettingRepository) *Store {
+    t.Helper()
+    st, err := NewStore(context.Background(), repo, Defaults{
+        MaxSubmissionCodeBytes: 1024, LogLevel: "info",
+        RateLimits: map[string]string{ratelimit.GroupLogin: "10/1m", ratelimit.GroupSubmission: "off", ratelimit.GroupJudgeEnqueue: "off"},
+    }, nil)
+    require.NoError(t, err)
+    return st
+}
+
+func TestDefaultsAndOverrides(t *testing.T) {
+    ctx := context.Background()
+    st := newTestStore(t, repository.NewMemoryRuntimeSettingRepository())
+    snap := st.Current()
+    require.EqualValues(t, 0, snap.Version)
+    require.Equal(t, 1024, snap.MaxSubmissionCodeBytes())
+    require.Equal(t, zapcore.InfoLevel, snap.LogLevel())
+    require.True(t, snap.RateLimit(ratelimit.GroupLogin).ByIP)
+    require.False(t, snap.RateLimit(ratelimit.GroupSubmission).Enabled())
+
+    snap, err := st.Update(ctx, nil, map[string]*string{
+        KeyMaxSubmissionCodeBytes: strp("2048"), KeyLogLevel: strp("DEBUG"),
+        "rate_limit.submission": strp("5/1m"),
+    }, "u1", SourceAPI)
+    require.NoError(t, err)
+    require.EqualValues(t, 1, snap.Version)
+    require.Equal(t, 2048, snap.MaxSubmissionCodeBytes())
+    require.Equal(t, zapcore.DebugLevel, snap.LogLevel())
+    require.Equal(t, 5, snap.RateLimit(ratelimit.GroupSubmission).Burst)
+    v, overridden := snap.Value(KeyMaxSubmissionCodeBytes)
+    require.Equal(t, "2048", v)
+    require.True(t, overridden)
+
+    // 恢复默认值
+    snap, err = st.Update(ctx, nil, map[string]*string{KeyMaxSubmissionCodeBytes: nil}, "u1", SourceAPI)
+    require.NoError(t, err)
+    require.Equal(t, 1024, snap.MaxSubmissionCodeBytes())
+    _, overridden = snap.Value(KeyMaxSubmissionCodeBytes)
+    require.False(t, overridden)
+
+    hist, err := st.History(ctx, 10, 0)
+    require.NoError(t, err)
+    require.Len(t, hist, 4)
+    require.Equal(t, KeyMaxSubmissionCodeBytes, hist[0].Key)
+    require.Nil(t, hist[0].NewValue)
+    require.Equal(t, "2048", *hist[0].OldValue)
+    require.Equal(t, "u1", hist[0].ChangedBy)
+}
+
+func TestUpdateValidation(t *testing.T) {
+    st := newTestStore(t, repository.NewMemoryRuntimeSettingRepository())
+    _, err := st.Update(context.Background(), nil, map[string]*string{
+        KeyMaxSubmissionCodeBytes: strp("-1"), "rate_limit.unknown": strp("1/1s"), "nope": nil, KeyLogLevel: strp("warn"),
+    }, "u1", SourceAPI)
+    require.ErrorIs(t, err, ErrInvalidSetting)
+    require.Contains(t, err.Error(), "max_submission_code_bytes")
+    require.Contains(t, err.Error(), "rate_limit.unknown")
+    require.Contains(t, err.Error(), "nope")
+    // 任一键非法时整体不写入
+    require.EqualValues(t, 0, st.Current().Version)
+    require.Equal(t, zapcore.InfoLevel, st.Current().LogLevel())
+}
+
+func TestUpdateVersionConflictAndNoop(t *testing.T) {
+    ctx := context.Background()
+    st := newTestStore(t, repository.NewMemoryRuntimeSettingRepository())
+    stale := int64(0)
+    _, err := st.Update(ctx, &stale, map[string]*string{KeyLogLevel: strp("warn")}, "u1", SourceAPI)
+    require.NoError(t, err)
+    _, err = st.Update(ctx, &stale, map[string]*string{KeyLogLevel: strp("error")}, "u2", SourceAPI)
+    require.True(t, errors.Is(err, ErrVersionConflict))
+
+    // 与当前值相同：不产生新版本
+    snap, err := st.Update(ctx, nil, map[string]*string{KeyLogLevel: strp("warn"), KeyMaxSubmissionCodeBytes: nil}, "u1", SourceAPI)
+    require.NoError(t, err)
+    require.EqualValues(t, 1, snap.Version)
+}
+
+func TestSubscribeAndWatch(t *testing.T) {
+    repo := repository.NewMemoryRuntimeSettingRepository()
+    a := newTestStore(t, repo)
+    b := newTestStore(t, repo) // 模拟另一实例
+    var got []int64
+    b.Subscribe(func(s *Snapshot) { got = append(got, s.Version) })
+    require.Equal(t, []int64{0}, got)
+
+    ctx, cancel := context.WithCancel(context.Background())
+    done := make(chan struct{})
+    go func() { b.Watch(ctx, 5*time.Millisecond); close(done) }()
+    _, err := a.Update(context.Background(), nil, map[string]*string{KeyLogLevel: strp("error")}, "u1", SourceAPI)
+    require.NoError(t, err)
+    require.Eventually(t, func() bool { return b.Current().LogLevel() == zapcore.ErrorLevel }, time.Second, 5*time.Millisecond)
+    cancel()
+    <-done
+    require.Equal(t, []int64{0, 1}, got)
+}
+
+func TestWatchFile(t *testing.T) {
+    path := filepath.Join(t.TempDir(), "runtime.yaml")
+    require.NoError(t, os.WriteFile(path, []byte("max_submission_code_bytes: 4096\nrate_limit.submission: 3/1m\n"), 0o600))
+    repo := repository.NewMemoryRuntimeSettingRepository()
+    st := newTestStore(t, repo)
+    ctx, cancel := context.WithCancel(context.Background())
+    defer cancel()
+    go st.WatchFile(ctx, path, 5*time.Millisecond)
+    require.Eventually(t, func() bool { return st.Current().MaxSubmissionCodeBytes() == 4096 }, time.Second, 5*time.Millisecond)
+    require.Equal(t, 3, st.Current().RateLimit(ratelimit.GroupSubmission).Burst)
+
+    // 非法内容被拒绝，之前的值保持
+    require.NoError(t, os.WriteFile(path, []byte("max_submission_code_bytes: lots\n"), 0o600))
+    time.Sleep(30 * time.Millisecond)
+    require.Equal(t, 4096, st.Current().MaxSubmissionCodeBytes())
+
+    require.NoError(t, os.WriteFile(path, []byte("max_submission_code_bytes: null\nlog_level: warn\n"), 0o600))
+    require.Eventually(t, func() bool { return st.Current().MaxSubmissionCodeBytes() == 1024 }, time.Second, 5*time.Millisecond)
+    hist, err := st.History(context.Background(), 10, 0)
+    require.NoError(t, err)
+    require.Equal(t, "file:"+path, hist[0].ChangedBy)
+    require.Equal(t, SourceFile, hist[0].Source)
+}
+
+func TestInvalidStoredOverrideIgnored(t *testing.T) {
+    repo := repository.NewMemoryRuntimeSettingRepository()
+    // 绕过校验写入（如旧版本写入或键已下线）
+    _, err := repo.Apply(context.Background(), 0, map[string]*string{KeyMaxSubmissionCodeBytes: strp("zero"), "retired_key": strp("x")}, "u1", SourceAPI)
+    require.NoError(t, err)
+    st := newTestStore(t, repo)
+    require.Equal(t, 1024, st.Current().MaxSubmissionCodeBytes())
+    _, overridden := st.Current().Value(KeyMaxSubmissionCodeBytes)
+    require.False(t, overridden)
+}
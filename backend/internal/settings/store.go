@@ -0,0 +1,212 @@
+package settings
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.uber.org/zap"
+	"gopkg.in/yaml.v3"
+
+	"github.com/YangYuS8/codyssey/backend/internal/domain"
+	"github.com/YangYuS8/codyssey/backend/internal/repository"
+)
+
+// ErrVersionConflict 写入时携带的版本号已过期（其他管理员或实例先行修改）。
+var ErrVersionConflict = repository.ErrSettingsVersionConflict
+
+// 变更来源（记录在变更历史中）
+const (
+    SourceAPI  = "api"
+    SourceFile = "file"
+)
+
+// Store 当前生效快照的持有者。读取无锁（atomic.Pointer）；重新加载与订阅者通知串行执行，订阅者按版本顺序收到快照。
+type Store struct {
+    repo     repository.RuntimeSettingRepository
+    defaults map[string]string
+    logger   *zap.Logger
+
+    current atomic.Pointer[Snapshot]
+    mu      sync.Mutex // 串行化 Reload 与订阅者通知
+    subs    []func(*Snapshot)
+}
+
+// NewStore 以启动配置为默认值并加载一次覆盖值；logger 为 nil 时不输出。
+func NewStore(ctx context.Context, repo repository.RuntimeSettingRepository, defaults Defaults, logger *zap.Logger) (*Store, error) {
+    if logger == nil { logger = zap.NewNop() }
+    s := &Store{repo: repo, defaults: defaults.values(), logger: logger}
+    if err := s.Reload(ctx); err != nil { return nil, err }
+    return s, nil
+}
+
+// Current 当前生效快照，不为 nil。
+func (s *Store) Current() *Snapshot { return s.current.Load() }
+
+// Defaults 启动配置提供的默认值（键 -> 值）。
+func (s *Store) Defaults() map[string]string {
+    out := make(map[string]string, len(s.defaults))
+    for k, v := range s.defaults { out[k] = v }
+    return out
+}
+
+// Subscribe 注册变更回调：立即以当前快照调用一次，此后每次版本变化调用一次。回调应快速返回。
+func (s *Store) Subscribe(fn func(*Snapshot)) {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+    s.subs = append(s.subs, fn)
+    fn(s.Current())
+}
+
+// Reload 从存储加载覆盖值；版本未变化时不替换快照。
+func (s *Store) Reload(ctx context.Context) error {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+    overrides, version, err := s.repo.Load(ctx)
+    if err != nil { return fmt.Errorf("load runtime settings: %w", err) }
+    if cur := s.Current(); cur != nil && cur.Version == version { return nil }
+    snap, skipped := buildSnapshot(version, s.defaults, overrides)
+    for _, e := range skipped { s.logger.Warn("runtime setting ignored", zap.Int64("version", version), zap.Error(e)) }
+    s.current.Store(snap)
+    s.logger.Info("runtime settings applied", zap.Int64("version", version), zap.Int("overrides", len(snap.overrides)))
+    for _, fn := range s.subs { fn(snap) }
+    return nil
+}
+
+// Update 校验并写入一组变更（值为 nil 表示恢复默认值），成功后立即生效并返回新快照。
+// expectedVersion 为 nil 时基于当前快照版本；与当前覆盖值相同的变更被忽略，全部无变化时不产生新版本。
+func (s *Store) Update(ctx context.Context, expectedVersion *int64, changes map[string]*string, actor, source string) (*Snapshot, error) {
+    var errs []error
+    keys := make([]string, 0, len(changes))
+    for k := range changes { keys = append(keys, k) }
+    sort.Strings(keys)
+    for _, k := range keys {
+        v := changes[k]
+        var err error
+        if v == nil { err = ValidateKey(k) } else { err = Validate(k, *v) }
+        if err != nil { errs = append(errs, err) }
+    }
+    if len(errs) > 0 { return nil, errors.Join(errs...) }
+
+    cur := s.Current()
+    version := cur.Version
+    if expectedVersion != nil {
+        if *expectedVersion != cur.Version { return nil, ErrVersionConflict }
+        version = *expectedVersion
+    }
+    effective := map[string]*string{}
+    for _, k := range keys {
+        v := changes[k]
+        o, overridden := cur.overrides[k]
+        switch {
+        case v == nil && !overridden:
+            continue
+        case v != nil && overridden && o.Value == strings.TrimSpace(*v):
+            continue
+        }
+        if v != nil { t := strings.TrimSpace(*v); v = &t }
+        effective[k] = v
+    }
+    if len(effective) == 0 { return cur, nil }
+    if _, err := s.repo.Apply(ctx, version, effective, actor, source); err != nil { return nil, err }
+    s.logger.Info("runtime settings changed", zap.String("actor", actor), zap.String("source", source), zap.Strings("keys", sortedKeys(effective)))
+    if err := s.Reload(ctx); err != nil { return nil, err }
+    return s.Current(), nil
+}
+
+// History 按版本倒序的变更记录。
+func (s *Store) History(ctx context.Context, limit, offset int) ([]domain.RuntimeSettingChange, error) {
+    return s.repo.ListChanges(ctx, limit, offset)
+}
+
+// Watch 周期检查存储中的版本号，发现其他实例写入的变更后重新加载；ctx 取消时返回。
+func (s *Store) Watch(ctx context.Context, interval time.Duration) {
+    if interval <= 0 { return }
+    t := time.NewTicker(interval)
+    defer t.Stop()
+    for {
+        select {
+        case <-ctx.Done():
+            return
+        case <-t.C:
+        }
+        v, err := s.repo.Version(ctx)
+        if err != nil {
+            if ctx.Err() == nil { s.logger.Warn("runtime settings poll failed", zap.Error(err)) }
+            continue
+        }
+        if v == s.Current().Version { continue }
+        if err := s.Reload(ctx); err != nil && ctx.Err() == nil { s.logger.Warn("runtime settings reload failed", zap.Error(err)) }
+    }
+}
+
+// WatchFile 监视 YAML 文件（平铺的 键: 值，null 表示恢复默认值），启动时及文件修改后把其中的键写入存储，
+// 变更记录的操作者为 file:<path>。文件中未出现的键保持不变。解析或校验失败时记录日志并等待下一次修改。
+func (s *Store) WatchFile(ctx context.Context, path string, interval time.Duration) {
+    if path == "" || interval <= 0 { return }
+    var lastMod time.Time
+    var lastSize int64 = -1
+    check := func() {
+        fi, err := os.Stat(path)
+        if err != nil { s.logger.Warn("runtime settings file unavailable", zap.String("path", path), zap.Error(err)); return }
+        if fi.ModTime().Equal(lastMod) && fi.Size() == lastSize { return }
+        if err := s.applyFile(ctx, path); err != nil {
+            s.logger.Warn("runtime settings file rejected", zap.String("path", path), zap.Error(err))
+            // 版本冲突可重试；内容错误等文件再次修改
+            if errors.Is(err, ErrVersionConflict) { return }
+        }
+        lastMod, lastSize = fi.ModTime(), fi.Size()
+    }
+    check()
+    t := time.NewTicker(interval)
+    defer t.Stop()
+    for {
+        select {
+        case <-ctx.Done():
+            return
+        case <-t.C:
+            check()
+        }
+    }
+}
+
+func (s *Store) applyFile(ctx context.Context, path string) error {
+    changes, err := readFile(path)
+    if err != nil { return err }
+    _, err = s.Update(ctx, nil, changes, "file:"+path, SourceFile)
+    return err
+}
+
+// readFile 解析平铺的 YAML 映射；标量统一转为字符串。
+func readFile(path string) (map[string]*string, error) {
+    data, err := os.ReadFile(path)
+    if err != nil { return nil, err }
+    raw := map[string]any{}
+    if err := yaml.Unmarshal(data, &raw); err != nil { return nil, fmt.Errorf("parse %s: %w", path, err) }
+    out := make(map[string]*string, len(raw))
+    for k, v := range raw {
+        switch x := v.(type) {
+        case nil:
+            out[k] = nil
+        case map[string]any, []any:
+            return nil, fmt.Errorf("%w: %s: expected a scalar", ErrInvalidSetting, k)
+        default:
+            str := fmt.Sprint(x)
+            out[k] = &str
+        }
+    }
+    return out, nil
+}
+
+func sortedKeys(m map[string]*string) []string {
+    keys := make([]string, 0, len(m))
+    for k := range m { keys = append(keys, k) }
+    sort.Strings(keys)
+    return keys
+}
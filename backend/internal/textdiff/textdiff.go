@@ -0,0 +1,129 @@
+// Package textdiff 按行比较两段文本并输出统一格式（unified diff），用于题面修订对比。
+// 采用最长公共子序列：题面通常只有几十到几百行，O(n·m) 足够；超过 maxCells 时退化为整段替换。
+package textdiff
+
+import (
+	"fmt"
+	"strings"
+)
+
+const maxCells = 4_000_000
+
+// Kind 行操作：' ' 不变，'-' 删除，'+' 新增。
+type Kind byte
+
+const (
+    Equal  Kind = ' '
+    Delete Kind = '-'
+    Insert Kind = '+'
+)
+
+type Line struct {
+    Kind Kind
+    Text string
+}
+
+func splitLines(s string) []string {
+    if s == "" { return nil }
+    return strings.Split(strings.TrimSuffix(strings.ReplaceAll(s, "\r\n", "\n"), "\n"), "\n")
+}
+
+// Lines 返回把 a 变为 b 的逐行操作序列。
+func Lines(a, b string) []Line {
+    x, y := splitLines(a), splitLines(b)
+    var out []Line
+    // 公共前后缀不参与 LCS
+    pre := 0
+    for pre < len(x) && pre < len(y) && x[pre] == y[pre] { pre++ }
+    suf := 0
+    for suf < len(x)-pre && suf < len(y)-pre && x[len(x)-1-suf] == y[len(y)-1-suf] { suf++ }
+    for _, l := range x[:pre] { out = append(out, Line{Equal, l}) }
+    out = append(out, middle(x[pre:len(x)-suf], y[pre:len(y)-suf])...)
+    for _, l := range x[len(x)-suf:] { out = append(out, Line{Equal, l}) }
+    return out
+}
+
+func middle(x, y []string) []Line {
+    out := make([]Line, 0, len(x)+len(y))
+    if len(x)*len(y) > maxCells || len(x) == 0 || len(y) == 0 {
+        for _, l := range x { out = append(out, Line{Delete, l}) }
+        for _, l := range y { out = append(out, Line{Insert, l}) }
+        return out
+    }
+    // lcs[i][j] = x[i:] 与 y[j:] 的 LCS 长度
+    w := len(y) + 1
+    lcs := make([]int, (len(x)+1)*w)
+    for i := len(x) - 1; i >= 0; i-- {
+        for j := len(y) - 1; j >= 0; j-- {
+            if x[i] == y[j] {
+                lcs[i*w+j] = lcs[(i+1)*w+j+1] + 1
+            } else if lcs[(i+1)*w+j] >= lcs[i*w+j+1] {
+                lcs[i*w+j] = lcs[(i+1)*w+j]
+            } else {
+                lcs[i*w+j] = lcs[i*w+j+1]
+            }
+        }
+    }
+    i, j := 0, 0
+    for i < len(x) && j < len(y) {
+        switch {
+        case x[i] == y[j]:
+            out = append(out, Line{Equal, x[i]}); i++; j++
+        case lcs[(i+1)*w+j] >= lcs[i*w+j+1]:
+            out = append(out, Line{Delete, x[i]}); i++
+        default:
+            out = append(out, Line{Insert, y[j]}); j++
+        }
+    }
+    for ; i < len(x); i++ { out = append(out, Line{Delete, x[i]}) }
+    for ; j < len(y); j++ { out = append(out, Line{Insert, y[j]}) }
+    return out
+}
+
+// Unified 生成 unified diff（不含 ---/+++ 文件头），每个变更块前后保留 context 行；无差异时返回空串。
+func Unified(a, b string, context int) string {
+    lines := Lines(a, b)
+    var sb strings.Builder
+    for start := 0; start < len(lines); {
+        // 找到下一处变更
+        for start < len(lines) && lines[start].Kind == Equal { start++ }
+        if start == len(lines) { break }
+        lo := start - context
+        if lo < 0 { lo = 0 }
+        // 向后扩展：两处变更间的不变行不超过 2*context 时合并为同一块
+        hi, gap := start, 0
+        for k := start; k < len(lines); k++ {
+            if lines[k].Kind != Equal { hi, gap = k+1, 0; continue }
+            gap++
+            if gap > 2*context { break }
+        }
+        end := hi + context
+        if end > len(lines) { end = len(lines) }
+        // 计算块在新旧文本中的起始行号与行数
+        aLine, bLine := 1, 1
+        for _, l := range lines[:lo] {
+            if l.Kind != Insert { aLine++ }
+            if l.Kind != Delete { bLine++ }
+        }
+        aCount, bCount := 0, 0
+        for _, l := range lines[lo:end] {
+            if l.Kind != Insert { aCount++ }
+            if l.Kind != Delete { bCount++ }
+        }
+        fmt.Fprintf(&sb, "@@ -%s +%s @@\n", hunkRange(aLine, aCount), hunkRange(bLine, bCount))
+        for _, l := range lines[lo:end] {
+            sb.WriteByte(byte(l.Kind))
+            sb.WriteString(l.Text)
+            sb.WriteByte('\n')
+        }
+        start = end
+    }
+    return sb.String()
+}
+
+// hunkRange 与 GNU diff 一致：空范围的起始行号取前一行。
+func hunkRange(line, count int) string {
+    if count == 0 { line-- }
+    if count == 1 { return fmt.Sprint(line) }
+    return fmt.Sprintf("%d,%d", line, count)
+}
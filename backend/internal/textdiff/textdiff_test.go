@@ -0,0 +1,25 @@
+package textdiff
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestLines(t *testing.T) {
+    got := Lines("a\nb\nc\nd", "a\nc\nx\nd\n")
+    require.Equal(t, []Line{{Equal, "a"}, {Delete, "b"}, {Equal, "c"}, {Insert, "x"}, {Equal, "d"}}, got)
+    require.Empty(t, Lines("", ""))
+    require.Equal(t, []Line{{Insert, "new"}}, Lines("", "new"))
+}
+
+func TestUnified(t *testing.T) {
+    require.Empty(t, Unified("same\ntext", "same\ntext", 3))
+    a := strings.Join([]string{"1", "2", "3", "4", "5", "6", "7", "8", "9", "10"}, "\n")
+    b := strings.Join([]string{"1", "two", "3", "4", "5", "6", "7", "8", "9", "10", "11"}, "\n")
+    require.Equal(t, "@@ -1,3 +1,3 @@\n 1\n-2\n+two\n 3\n@@ -10 +10,2 @@\n 10\n+11\n", Unified(a, b, 1))
+    // 变更间隔不超过 2*context 时合并为一块
+    require.Equal(t, "@@ -1,10 +1,11 @@\n 1\n-2\n+two\n 3\n 4\n 5\n 6\n 7\n 8\n 9\n 10\n+11\n", Unified(a, b, 4))
+    require.Equal(t, "@@ -0,0 +1 @@\n+x\n", Unified("", "x", 3))
+}
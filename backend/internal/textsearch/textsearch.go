@@ -0,0 +1,113 @@
+// Package textsearch 不依赖 zhparser 等中文分词扩展的简易分词：拉丁字母与数字按词切分（小写），
+// 中日韩文字按单字 + 相邻二元组索引、查询时用二元组匹配，使中文标题无需分词词典也能检索。
+// 索引词经 array_to_tsvector 写入 Postgres tsvector 列（不再经过数据库的文本解析器），查询由 TSQuery 生成。
+package textsearch
+
+import (
+	"sort"
+	"strings"
+	"unicode"
+)
+
+const (
+    maxWordRunes   = 64 // 超长的拉丁词截断
+    maxQueryTokens = 16 // 查询词个数上限，多余的忽略
+)
+
+func isCJK(r rune) bool {
+    return unicode.In(r, unicode.Han, unicode.Hiragana, unicode.Katakana, unicode.Hangul)
+}
+
+func isWord(r rune) bool { return !isCJK(r) && (unicode.IsLetter(r) || unicode.IsDigit(r)) }
+
+// segments 把文本切成拉丁词与中日韩连续片段（各自为 rune 切片），其余字符为分隔。
+func segments(text string, fn func(run []rune, cjk bool)) {
+    var run []rune
+    cjk := false
+    flush := func() {
+        if len(run) > 0 { fn(run, cjk) }
+        run = run[:0]
+    }
+    for _, r := range text {
+        switch {
+        case isCJK(r):
+            if !cjk { flush() }
+            cjk = true
+            run = append(run, r)
+        case isWord(r):
+            if cjk { flush() }
+            cjk = false
+            run = append(run, unicode.ToLower(r))
+        default:
+            flush()
+        }
+    }
+    flush()
+}
+
+func word(run []rune) string {
+    if len(run) > maxWordRunes { run = run[:maxWordRunes] }
+    return string(run)
+}
+
+// IndexTokens 文本的索引词（去重、排序）：拉丁词，以及中日韩片段的每个单字与相邻二元组。
+func IndexTokens(parts ...string) []string {
+    set := map[string]struct{}{}
+    for _, p := range parts {
+        segments(p, func(run []rune, cjk bool) {
+            if !cjk { set[word(run)] = struct{}{}; return }
+            for i := range run {
+                set[string(run[i])] = struct{}{}
+                if i+1 < len(run) { set[string(run[i:i+2])] = struct{}{} }
+            }
+        })
+    }
+    out := make([]string, 0, len(set))
+    for t := range set { out = append(out, t) }
+    sort.Strings(out)
+    return out
+}
+
+// QueryTokens 查询词：拉丁词（匹配时按前缀），中日韩片段取相邻二元组（单字片段取单字）。
+func QueryTokens(q string) []string {
+    var out []string
+    seen := map[string]bool{}
+    add := func(t string) {
+        if seen[t] || len(out) >= maxQueryTokens { return }
+        seen[t] = true
+        out = append(out, t)
+    }
+    segments(q, func(run []rune, cjk bool) {
+        if !cjk || len(run) == 1 { add(word(run)); return }
+        for i := 0; i+1 < len(run); i++ { add(string(run[i : i+2])) }
+    })
+    return out
+}
+
+// prefix 拉丁词按前缀匹配（输入 "dyn" 可命中 "dynamic"），中日韩词精确匹配。
+func prefix(token string) bool {
+    for _, r := range token { return !isCJK(r) }
+    return false
+}
+
+// TSQuery 把查询词组合为 tsquery 文本（全部命中），如 'two':* & '两数'；tokens 为空时返回空串。
+// 词只含字母、数字与中日韩文字，无需转义。
+func TSQuery(tokens []string) string {
+    parts := make([]string, len(tokens))
+    for i, t := range tokens {
+        parts[i] = "'" + t + "'"
+        if prefix(t) { parts[i] += ":*" }
+    }
+    return strings.Join(parts, " & ")
+}
+
+// Match 索引词（IndexTokens 的结果）是否命中全部查询词，语义与 TSQuery 一致（内存实现用）。
+func Match(index, query []string) bool {
+    for _, q := range query {
+        i := sort.SearchStrings(index, q)
+        hit := i < len(index) && index[i] == q
+        if !hit && prefix(q) { hit = i < len(index) && strings.HasPrefix(index[i], q) }
+        if !hit { return false }
+    }
+    return true
+}
@@ -0,0 +1,30 @@
+package textsearch
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestIndexTokens(t *testing.T) {
+    require.Equal(t, []string{"2", "sum", "two", "两", "两数", "之", "之和", "和", "数", "数之"}, IndexTokens("Two Sum 2", "两数之和"))
+    require.Equal(t, []string{"a1", "b", "图", "图论", "论"}, IndexTokens("A1-b, 图论！"))
+    require.Empty(t, IndexTokens("  --  "))
+}
+
+func TestQueryTokensAndTSQuery(t *testing.T) {
+    require.Equal(t, []string{"两数", "数之", "之和"}, QueryTokens("两数之和"))
+    require.Equal(t, []string{"dp", "图"}, QueryTokens("DP 图 dp"))
+    require.Equal(t, "'dp':* & '图' & '最短' & '短路'", TSQuery(QueryTokens("dp 图 最短路")))
+    require.Empty(t, TSQuery(QueryTokens("!!")))
+}
+
+func TestMatch(t *testing.T) {
+    idx := IndexTokens("Dynamic Programming: 最长上升子序列", "NOIP 2019")
+    for _, q := range []string{"dyn", "programming 子序列", "上升", "序", "noip 2019", "最长上升"} {
+        require.True(t, Match(idx, QueryTokens(q)), q)
+    }
+    for _, q := range []string{"graph", "上子", "最短路", "ramming"} {
+        require.False(t, Match(idx, QueryTokens(q)), q)
+    }
+}
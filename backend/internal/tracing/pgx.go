@@ -0,0 +1,55 @@
+package tracing
+
+import (
+	"context"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// maxStatementLen db.query.text 截断长度（SQL 均为参数化语句，不含参数值）
+const maxStatementLen = 2048
+
+// PgxTracer 实现 pgx.QueryTracer，为每次 Query / QueryRow / Exec 创建客户端 span。
+// 仅在已有父 span（即请求链路内）时创建，后台清理任务等不产生孤立的根 span。
+type PgxTracer struct{}
+
+var _ pgx.QueryTracer = PgxTracer{}
+
+// querySpanKey 标记 TraceQueryStart 创建的 span，避免 TraceQueryEnd 误结束调用方的父 span。
+type querySpanKey struct{}
+
+func (PgxTracer) TraceQueryStart(ctx context.Context, conn *pgx.Conn, data pgx.TraceQueryStartData) context.Context {
+    if !trace.SpanContextFromContext(ctx).IsValid() { return ctx }
+    op := sqlOperation(data.SQL)
+    stmt := data.SQL
+    if len(stmt) > maxStatementLen { stmt = stmt[:maxStatementLen] }
+    ctx, span := Tracer().Start(ctx, "db "+op, trace.WithSpanKind(trace.SpanKindClient), trace.WithAttributes(
+        attribute.String("db.system.name", "postgresql"),
+        attribute.String("db.operation.name", op),
+        attribute.String("db.query.text", stmt),
+    ))
+    return context.WithValue(ctx, querySpanKey{}, span)
+}
+
+func (PgxTracer) TraceQueryEnd(ctx context.Context, conn *pgx.Conn, data pgx.TraceQueryEndData) {
+    span, ok := ctx.Value(querySpanKey{}).(trace.Span)
+    if !ok { return }
+    if data.Err != nil {
+        span.RecordError(data.Err)
+        span.SetStatus(codes.Error, data.Err.Error())
+    } else {
+        span.SetAttributes(attribute.Int64("db.response.rows_affected", data.CommandTag.RowsAffected()))
+    }
+    span.End()
+}
+
+// sqlOperation 取首个关键字（WITH 语句按 WITH 计），如 SELECT / INSERT。
+func sqlOperation(sql string) string {
+    f := strings.Fields(sql)
+    if len(f) == 0 { return "QUERY" }
+    return strings.ToUpper(f[0])
+}
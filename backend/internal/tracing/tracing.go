@@ -0,0 +1,113 @@
+// Package tracing OpenTelemetry 链路追踪：初始化导出器与采样、服务层 span 辅助函数、pgx 查询钩子与 zap 日志关联。
+// 未启用导出时使用全局 noop TracerProvider，各埋点开销可忽略。
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+)
+
+// InstrumentationName 本仓库埋点使用的 tracer 名称
+const InstrumentationName = "github.com/YangYuS8/codyssey/backend"
+
+// 导出器类型
+const (
+    ExporterNone   = "none"
+    ExporterStdout = "stdout"
+    ExporterOTLP   = "otlp"
+)
+
+type Options struct {
+    Exporter    string  // none | stdout | otlp
+    Endpoint    string  // OTLP/HTTP 地址：host:port 或完整 URL；空则使用 OTEL_EXPORTER_OTLP_* 环境变量
+    Insecure    bool    // OTLP 使用明文 HTTP
+    SampleRatio float64 // 根 span 采样比例 [0,1]；有上游 traceparent 时跟随上游决定
+    ServiceName string
+    Version     string
+    Env         string
+}
+
+// Setup 注册 W3C traceparent / baggage 传播器，并按配置安装全局 TracerProvider。
+// 返回的 shutdown 在停机时调用以刷出缓冲的 span。
+func Setup(ctx context.Context, o Options, logger *zap.Logger) (func(context.Context) error, error) {
+    if logger == nil { logger = zap.NewNop() }
+    otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+    var exp sdktrace.SpanExporter
+    var err error
+    switch o.Exporter {
+    case "", ExporterNone:
+        return func(context.Context) error { return nil }, nil
+    case ExporterStdout:
+        exp, err = stdouttrace.New(stdouttrace.WithWriter(os.Stdout))
+    case ExporterOTLP:
+        var opts []otlptracehttp.Option
+        if o.Endpoint != "" {
+            if strings.Contains(o.Endpoint, "://") {
+                opts = append(opts, otlptracehttp.WithEndpointURL(o.Endpoint))
+            } else {
+                opts = append(opts, otlptracehttp.WithEndpoint(o.Endpoint))
+            }
+        }
+        if o.Insecure { opts = append(opts, otlptracehttp.WithInsecure()) }
+        exp, err = otlptracehttp.New(ctx, opts...)
+    default:
+        return nil, fmt.Errorf("unknown tracing exporter %q", o.Exporter)
+    }
+    if err != nil { return nil, err }
+    res, err := resource.Merge(resource.Default(), resource.NewSchemaless(
+        attribute.String("service.name", o.ServiceName),
+        attribute.String("service.version", o.Version),
+        attribute.String("deployment.environment.name", o.Env),
+    ))
+    if err != nil { return nil, err }
+    tp := sdktrace.NewTracerProvider(
+        sdktrace.WithBatcher(exp),
+        sdktrace.WithResource(res),
+        sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(o.SampleRatio))),
+    )
+    otel.SetTracerProvider(tp)
+    otel.SetErrorHandler(otel.ErrorHandlerFunc(func(err error) { logger.Warn("otel error", zap.Error(err)) }))
+    return tp.Shutdown, nil
+}
+
+// Tracer 每次从全局 provider 获取，保证 Setup 之后创建的 span 使用已安装的导出器。
+func Tracer() trace.Tracer { return otel.Tracer(InstrumentationName) }
+
+// Start 开始一个内部 span，返回的 end 在方法返回时调用并记录错误：
+//   ctx, end := tracing.Start(ctx, "SubmissionService.Create"); defer end(&err)
+func Start(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, func(*error)) {
+    ctx, span := Tracer().Start(ctx, name, trace.WithAttributes(attrs...))
+    return ctx, func(errp *error) {
+        if errp != nil && *errp != nil {
+            span.RecordError(*errp)
+            span.SetStatus(codes.Error, (*errp).Error())
+        }
+        span.End()
+    }
+}
+
+// LogFields 返回当前 span 的 trace_id / span_id 字段，用于把 zap 日志与链路关联；无有效 span 时为空。
+func LogFields(ctx context.Context) []zap.Field {
+    sc := trace.SpanContextFromContext(ctx)
+    if !sc.IsValid() { return nil }
+    return []zap.Field{zap.String("trace_id", sc.TraceID().String()), zap.String("span_id", sc.SpanID().String())}
+}
+
+// Logger 附加链路字段后的 logger。
+func Logger(ctx context.Context, l *zap.Logger) *zap.Logger {
+    if f := LogFields(ctx); len(f) > 0 { return l.With(f...) }
+    return l
+}
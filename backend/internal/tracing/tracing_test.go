@@ -0,0 +1,86 @@
+package tracing
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func useRecorder(t *testing.T) *tracetest.SpanRecorder {
+    rec := tracetest.NewSpanRecorder()
+    tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(rec))
+    prev := otel.GetTracerProvider()
+    otel.SetTracerProvider(tp)
+    t.Cleanup(func() { otel.SetTracerProvider(prev); _ = tp.Shutdown(context.Background()) })
+    return rec
+}
+
+func TestStartRecordsError(t *testing.T) {
+    rec := useRecorder(t)
+    fn := func(ctx context.Context) (err error) {
+        _, end := Start(ctx, "Svc.Op")
+        defer end(&err)
+        return errors.New("boom")
+    }
+    require.Error(t, fn(context.Background()))
+
+    spans := rec.Ended()
+    require.Len(t, spans, 1)
+    require.Equal(t, "Svc.Op", spans[0].Name())
+    require.Equal(t, codes.Error, spans[0].Status().Code)
+    require.Equal(t, "boom", spans[0].Status().Description)
+}
+
+func TestLogFields(t *testing.T) {
+    useRecorder(t)
+    require.Empty(t, LogFields(context.Background()))
+
+    ctx, end := Start(context.Background(), "op")
+    defer end(nil)
+    f := LogFields(ctx)
+    require.Len(t, f, 2)
+    require.Equal(t, "trace_id", f[0].Key)
+    require.Len(t, f[0].String, 32)
+}
+
+func TestPgxTracerRequiresParent(t *testing.T) {
+    rec := useRecorder(t)
+    var tr PgxTracer
+    end := pgx.TraceQueryEndData{CommandTag: pgconn.NewCommandTag("UPDATE 2")}
+
+    // 无父 span：不创建 span
+    ctx := tr.TraceQueryStart(context.Background(), nil, pgx.TraceQueryStartData{SQL: "SELECT 1"})
+    tr.TraceQueryEnd(ctx, nil, end)
+    require.Empty(t, rec.Ended())
+
+    parent, finish := Start(context.Background(), "parent")
+    ctx = tr.TraceQueryStart(parent, nil, pgx.TraceQueryStartData{SQL: "  update users SET roles=$1 WHERE id=$2"})
+    tr.TraceQueryEnd(ctx, nil, end)
+    spans := rec.Ended()
+    require.Len(t, spans, 1, "parent span must stay open")
+    require.Equal(t, "db UPDATE", spans[0].Name())
+    require.Equal(t, trace.SpanContextFromContext(parent).SpanID(), spans[0].Parent().SpanID())
+    attrs := map[string]any{}
+    for _, kv := range spans[0].Attributes() { attrs[string(kv.Key)] = kv.Value.AsInterface() }
+    require.Equal(t, "UPDATE", attrs["db.operation.name"])
+    require.Equal(t, int64(2), attrs["db.response.rows_affected"])
+    finish(nil)
+    require.Len(t, rec.Ended(), 2)
+}
+
+func TestSetupNoneAndUnknown(t *testing.T) {
+    stop, err := Setup(context.Background(), Options{Exporter: ExporterNone}, nil)
+    require.NoError(t, err)
+    require.NoError(t, stop(context.Background()))
+    _, err = Setup(context.Background(), Options{Exporter: "jaeger"}, nil)
+    require.Error(t, err)
+}
@@ -2,7 +2,10 @@ package main
 
 import (
 	"context"
+	"errors"
+	"flag"
 	"log"
+	"os"
 	"time"
 
 	"github.com/YangYuS8/codyssey/backend/internal/config"
@@ -15,7 +18,11 @@ var buildVersion = "dev"
 
 func main() {
     _ = godotenv.Load()
-    cfg := config.Load()
+    cfg, err := config.Load(os.Args[1:])
+    if errors.Is(err, flag.ErrHelp) { os.Exit(0) }
+    if err != nil {
+        log.Fatalf("invalid configuration:\n%v", err)
+    }
     cfg.Version = buildVersion
 
     svc, err := server.New(cfg)
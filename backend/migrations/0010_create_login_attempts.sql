@@ -0,0 +1,12 @@
+-- +goose Up
+-- 登录失败计数与临时锁定（多实例共享）
+CREATE TABLE IF NOT EXISTS login_attempts (
+    key TEXT PRIMARY KEY,
+    failures INT NOT NULL DEFAULT 0,
+    last_failed_at TIMESTAMPTZ NOT NULL,
+    locked_until TIMESTAMPTZ NULL
+);
+CREATE INDEX IF NOT EXISTS idx_login_attempts_locked_until ON login_attempts(locked_until) WHERE locked_until IS NOT NULL;
+
+-- +goose Down
+DROP TABLE IF EXISTS login_attempts;
@@ -0,0 +1,12 @@
+-- +goose Up
+-- 令牌桶限流状态（多副本共享）；key 形如 "<group>:user:<id>" / "<group>:ip:<addr>"
+CREATE TABLE IF NOT EXISTS rate_limit_buckets (
+    key TEXT PRIMARY KEY,
+    tokens DOUBLE PRECISION NOT NULL,
+    allowed BOOLEAN NOT NULL,
+    updated_at TIMESTAMPTZ NOT NULL
+);
+CREATE INDEX IF NOT EXISTS idx_rate_limit_buckets_updated_at ON rate_limit_buckets(updated_at);
+
+-- +goose Down
+DROP TABLE IF EXISTS rate_limit_buckets;
@@ -0,0 +1,6 @@
+-- +goose Up
+-- 事件流断线续传按 created_at 扫描状态日志
+CREATE INDEX IF NOT EXISTS idx_submission_status_logs_created ON submission_status_logs(created_at);
+
+-- +goose Down
+DROP INDEX IF EXISTS idx_submission_status_logs_created;
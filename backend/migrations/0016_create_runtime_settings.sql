@@ -0,0 +1,32 @@
+-- +goose Up
+-- 运行时可调参数：覆盖值 + 全局版本号（单行，乐观锁）+ 变更记录
+CREATE TABLE IF NOT EXISTS runtime_settings (
+    key TEXT PRIMARY KEY,
+    value TEXT NOT NULL,
+    version BIGINT NOT NULL,
+    updated_by TEXT NOT NULL,
+    updated_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS runtime_settings_version (
+    id BOOLEAN PRIMARY KEY DEFAULT TRUE CHECK (id),
+    version BIGINT NOT NULL DEFAULT 0
+);
+INSERT INTO runtime_settings_version (id, version) VALUES (TRUE, 0) ON CONFLICT DO NOTHING;
+
+CREATE TABLE IF NOT EXISTS runtime_setting_changes (
+    id BIGSERIAL PRIMARY KEY,
+    version BIGINT NOT NULL,
+    key TEXT NOT NULL,
+    old_value TEXT NULL,
+    new_value TEXT NULL,
+    changed_by TEXT NOT NULL,
+    source TEXT NOT NULL,
+    changed_at TIMESTAMPTZ NOT NULL
+);
+CREATE INDEX IF NOT EXISTS idx_runtime_setting_changes_version ON runtime_setting_changes(version DESC, id DESC);
+
+-- +goose Down
+DROP TABLE IF EXISTS runtime_setting_changes;
+DROP TABLE IF EXISTS runtime_settings_version;
+DROP TABLE IF EXISTS runtime_settings;
@@ -0,0 +1,16 @@
+-- +goose Up
+-- 功能开关（灰度发布）：总开关 + 角色 / 用户白名单 / 百分比放量
+CREATE TABLE IF NOT EXISTS feature_flags (
+    key TEXT PRIMARY KEY,
+    description TEXT NOT NULL DEFAULT '',
+    enabled BOOLEAN NOT NULL DEFAULT FALSE,
+    roles TEXT[] NOT NULL DEFAULT '{}',
+    user_ids TEXT[] NOT NULL DEFAULT '{}',
+    percentage SMALLINT NOT NULL DEFAULT 0 CHECK (percentage BETWEEN 0 AND 100),
+    updated_by TEXT NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL,
+    updated_at TIMESTAMPTZ NOT NULL
+);
+
+-- +goose Down
+DROP TABLE IF EXISTS feature_flags;
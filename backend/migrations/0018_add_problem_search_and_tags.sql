@@ -0,0 +1,30 @@
+-- +goose Up
+-- 题目元数据（标签 / 难度 / 出处 / 可见性）与全文检索。
+-- search_vector 由应用写入（internal/textsearch 分词后 array_to_tsvector），不依赖 zhparser 等中文分词扩展。
+ALTER TABLE problems
+    ADD COLUMN IF NOT EXISTS tags TEXT[] NOT NULL DEFAULT '{}',
+    ADD COLUMN IF NOT EXISTS difficulty TEXT NOT NULL DEFAULT '',
+    ADD COLUMN IF NOT EXISTS source TEXT NOT NULL DEFAULT '',
+    ADD COLUMN IF NOT EXISTS visibility TEXT NOT NULL DEFAULT 'public',
+    ADD COLUMN IF NOT EXISTS search_vector TSVECTOR NOT NULL DEFAULT ''::tsvector;
+-- 存量题目先按 simple 配置占位回填；0026_reindex_problem_search.go 随后按应用分词重建
+UPDATE problems SET search_vector = to_tsvector('simple', lower(title || ' ' || description));
+CREATE INDEX IF NOT EXISTS idx_problems_search ON problems USING GIN (search_vector);
+CREATE INDEX IF NOT EXISTS idx_problems_tags ON problems USING GIN (tags);
+
+CREATE TABLE IF NOT EXISTS problem_tags (
+    name TEXT PRIMARY KEY,
+    description TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+-- +goose Down
+DROP TABLE IF EXISTS problem_tags;
+DROP INDEX IF EXISTS idx_problems_tags;
+DROP INDEX IF EXISTS idx_problems_search;
+ALTER TABLE problems
+    DROP COLUMN IF EXISTS search_vector,
+    DROP COLUMN IF EXISTS visibility,
+    DROP COLUMN IF EXISTS source,
+    DROP COLUMN IF EXISTS difficulty,
+    DROP COLUMN IF EXISTS tags;
@@ -0,0 +1,39 @@
+-- +goose Up
+-- 题面修订历史：题目保留当前题面与修订号，每次修改题面追加一条不可变修订；提交记录所针对的修订。
+ALTER TABLE problems
+    ADD COLUMN IF NOT EXISTS time_limit_ms INT NOT NULL DEFAULT 1000,
+    ADD COLUMN IF NOT EXISTS memory_limit_mb INT NOT NULL DEFAULT 256,
+    ADD COLUMN IF NOT EXISTS test_data_hash TEXT NOT NULL DEFAULT '',
+    ADD COLUMN IF NOT EXISTS revision INT NOT NULL DEFAULT 0;
+
+CREATE TABLE IF NOT EXISTS problem_revisions (
+    id UUID PRIMARY KEY,
+    problem_id UUID NOT NULL REFERENCES problems(id) ON DELETE CASCADE,
+    number INT NOT NULL,
+    title TEXT NOT NULL,
+    description TEXT NOT NULL,
+    time_limit_ms INT NOT NULL,
+    memory_limit_mb INT NOT NULL,
+    test_data_hash TEXT NOT NULL DEFAULT '',
+    author_id TEXT NOT NULL DEFAULT '',
+    message TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    UNIQUE (problem_id, number)
+);
+
+-- 存量题目以当前题面作为第 1 个修订
+INSERT INTO problem_revisions (id, problem_id, number, title, description, time_limit_ms, memory_limit_mb, test_data_hash, message, created_at)
+SELECT gen_random_uuid(), id, 1, title, description, time_limit_ms, memory_limit_mb, test_data_hash, 'initial revision', created_at
+FROM problems WHERE revision = 0;
+UPDATE problems SET revision = 1 WHERE revision = 0;
+
+ALTER TABLE submissions ADD COLUMN IF NOT EXISTS problem_revision_id UUID NULL;
+
+-- +goose Down
+ALTER TABLE submissions DROP COLUMN IF EXISTS problem_revision_id;
+DROP TABLE IF EXISTS problem_revisions;
+ALTER TABLE problems
+    DROP COLUMN IF EXISTS revision,
+    DROP COLUMN IF EXISTS test_data_hash,
+    DROP COLUMN IF EXISTS memory_limit_mb,
+    DROP COLUMN IF EXISTS time_limit_ms;
@@ -0,0 +1,8 @@
+-- +goose Up
+-- 结构化题面分节（背景、输入格式、输出格式、样例、提示），以 JSONB 存储，随修订一起记录。
+ALTER TABLE problems ADD COLUMN IF NOT EXISTS statement JSONB NOT NULL DEFAULT '{"samples":[]}';
+ALTER TABLE problem_revisions ADD COLUMN IF NOT EXISTS statement JSONB NOT NULL DEFAULT '{"samples":[]}';
+
+-- +goose Down
+ALTER TABLE problem_revisions DROP COLUMN IF EXISTS statement;
+ALTER TABLE problems DROP COLUMN IF EXISTS statement;
@@ -0,0 +1,22 @@
+-- +goose Up
+-- 题目发布审核流程：draft -> pending_review -> published -> archived；存量题目视为已发布。
+ALTER TABLE problems ADD COLUMN IF NOT EXISTS status TEXT NOT NULL DEFAULT 'published';
+CREATE INDEX IF NOT EXISTS idx_problems_status ON problems(status);
+
+-- 状态流转审计（与 submission_status_logs 相同的只追加模型），附审核意见
+CREATE TABLE IF NOT EXISTS problem_status_logs (
+    id UUID PRIMARY KEY,
+    problem_id UUID NOT NULL REFERENCES problems(id) ON DELETE CASCADE,
+    action TEXT NOT NULL,
+    from_status TEXT NOT NULL,
+    to_status TEXT NOT NULL,
+    actor_id TEXT NOT NULL DEFAULT '',
+    comment TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+CREATE INDEX IF NOT EXISTS idx_problem_status_logs_problem ON problem_status_logs(problem_id, created_at);
+
+-- +goose Down
+DROP TABLE IF EXISTS problem_status_logs;
+DROP INDEX IF EXISTS idx_problems_status;
+ALTER TABLE problems DROP COLUMN IF EXISTS status;
@@ -0,0 +1,6 @@
+-- +goose Up
+-- 题目来源元数据（AI 生成时记录模型与提示词）；手工创建的题目为 NULL
+ALTER TABLE problems ADD COLUMN IF NOT EXISTS provenance JSONB;
+
+-- +goose Down
+ALTER TABLE problems DROP COLUMN IF EXISTS provenance;
@@ -0,0 +1,35 @@
+-- +goose Up
+-- 提交所属比赛（可为空），用于按比赛开启 AI 代码检测
+ALTER TABLE submissions ADD COLUMN IF NOT EXISTS contest_id TEXT NOT NULL DEFAULT '';
+
+-- AI 代码检测开关：scope 为 problem / contest，提交命中任一已开启的范围即检测
+CREATE TABLE IF NOT EXISTS ai_detection_settings (
+    scope TEXT NOT NULL,
+    scope_id TEXT NOT NULL,
+    enabled BOOLEAN NOT NULL DEFAULT FALSE,
+    updated_by TEXT NOT NULL DEFAULT '',
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (scope, scope_id)
+);
+
+-- 每个提交至多一条检测结果；pending 记录在服务重启后重新入队
+CREATE TABLE IF NOT EXISTS submission_ai_checks (
+    submission_id UUID PRIMARY KEY REFERENCES submissions(id) ON DELETE CASCADE,
+    problem_id UUID NOT NULL,
+    contest_id TEXT NOT NULL DEFAULT '',
+    user_id UUID NOT NULL,
+    status TEXT NOT NULL DEFAULT 'pending',
+    score DOUBLE PRECISION NOT NULL DEFAULT 0,
+    suspicious BOOLEAN NOT NULL DEFAULT FALSE,
+    model TEXT NOT NULL DEFAULT '',
+    error TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    checked_at TIMESTAMPTZ
+);
+CREATE INDEX IF NOT EXISTS idx_submission_ai_checks_problem ON submission_ai_checks(problem_id, created_at);
+CREATE INDEX IF NOT EXISTS idx_submission_ai_checks_pending ON submission_ai_checks(created_at) WHERE status = 'pending';
+
+-- +goose Down
+DROP TABLE IF EXISTS submission_ai_checks;
+DROP TABLE IF EXISTS ai_detection_settings;
+ALTER TABLE submissions DROP COLUMN IF EXISTS contest_id;
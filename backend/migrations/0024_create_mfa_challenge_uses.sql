@@ -0,0 +1,10 @@
+-- +goose Up
+-- 已使用的 MFA 待验证令牌（jti）：令牌只能完成一次登录，过期后记录可清理
+CREATE TABLE IF NOT EXISTS mfa_challenge_uses (
+    jti TEXT PRIMARY KEY,
+    expires_at TIMESTAMPTZ NOT NULL
+);
+CREATE INDEX IF NOT EXISTS idx_mfa_challenge_uses_expires ON mfa_challenge_uses(expires_at);
+
+-- +goose Down
+DROP TABLE IF EXISTS mfa_challenge_uses;
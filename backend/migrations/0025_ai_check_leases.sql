@@ -0,0 +1,18 @@
+-- +goose Up
+-- 比赛范围的 AI 检测下线：提交所属比赛由客户端填写，学生可省略以绕过检测；待比赛实体落地、由服务端确定后再提供
+DELETE FROM ai_detection_settings WHERE scope = 'contest';
+ALTER TABLE submission_ai_checks DROP COLUMN IF EXISTS contest_id;
+ALTER TABLE submissions DROP COLUMN IF EXISTS contest_id;
+
+-- 检测租约：实例认领记录时置为 running 并设置到期时间，到期仍未完成的记录可被其他实例重新认领
+ALTER TABLE submission_ai_checks ADD COLUMN IF NOT EXISTS lease_until TIMESTAMPTZ;
+DROP INDEX IF EXISTS idx_submission_ai_checks_pending;
+CREATE INDEX IF NOT EXISTS idx_submission_ai_checks_unfinished ON submission_ai_checks(created_at) WHERE status IN ('pending', 'running');
+
+-- +goose Down
+DROP INDEX IF EXISTS idx_submission_ai_checks_unfinished;
+UPDATE submission_ai_checks SET status = 'pending' WHERE status = 'running';
+CREATE INDEX IF NOT EXISTS idx_submission_ai_checks_pending ON submission_ai_checks(created_at) WHERE status = 'pending';
+ALTER TABLE submission_ai_checks DROP COLUMN IF EXISTS lease_until;
+ALTER TABLE submission_ai_checks ADD COLUMN IF NOT EXISTS contest_id TEXT NOT NULL DEFAULT '';
+ALTER TABLE submissions ADD COLUMN IF NOT EXISTS contest_id TEXT NOT NULL DEFAULT '';
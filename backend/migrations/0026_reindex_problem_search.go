@@ -0,0 +1,36 @@
+package migrations
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/pressly/goose/v3"
+
+	"github.com/YangYuS8/codyssey/backend/internal/textsearch"
+)
+
+func init() {
+    goose.AddMigrationContext(upReindexProblemSearch, downReindexProblemSearch)
+}
+
+// upReindexProblemSearch 按应用分词（与 repository 写入时相同的字段）重建存量题目的 search_vector，
+// 取代 0018 中仅覆盖拉丁词的 to_tsvector('simple', ...) 近似回填，使迁移前录入的中文题目可被 ?q= 检索。
+func upReindexProblemSearch(ctx context.Context, tx *sql.Tx) error {
+    rows, err := tx.QueryContext(ctx, `SELECT id, title, description, COALESCE(statement->>'background',''), COALESCE(statement->>'notes',''), source FROM problems`)
+    if err != nil { return err }
+    tokens := map[string][]string{}
+    for rows.Next() {
+        var id, title, description, background, notes, source string
+        if err := rows.Scan(&id, &title, &description, &background, &notes, &source); err != nil { rows.Close(); return err }
+        tokens[id] = textsearch.IndexTokens(title, description, background, notes, source)
+    }
+    if err := rows.Close(); err != nil { return err }
+    if err := rows.Err(); err != nil { return err }
+    for id, ts := range tokens {
+        if _, err := tx.ExecContext(ctx, `UPDATE problems SET search_vector=array_to_tsvector($2::text[]) WHERE id=$1`, id, ts); err != nil { return err }
+    }
+    return nil
+}
+
+// downReindexProblemSearch 索引内容向下兼容，回滚无需处理。
+func downReindexProblemSearch(ctx context.Context, tx *sql.Tx) error { return nil }
@@ -4,6 +4,8 @@ import os
 
 app = FastAPI(title="Codyssey AI Service", version="0.1.0")
 
+MODEL_NAME = os.getenv("AI_MODEL_NAME", "stub")
+
 class AIGenerateRequest(BaseModel):
     prompt: str
 
@@ -11,6 +13,7 @@ class AIGenerateResponse(BaseModel):
     problem_id: str
     title: str
     description: str
+    model: str
 
 class AIDetectRequest(BaseModel):
     code: str
@@ -18,6 +21,7 @@ class AIDetectRequest(BaseModel):
 class AIDetectResponse(BaseModel):
     suspicious: bool
     score: float
+    model: str
 
 @app.get("/health")
 async def health():
@@ -29,7 +33,8 @@ async def ai_generate(req: AIGenerateRequest):
     return AIGenerateResponse(
         problem_id="stub-123",
         title=f"Generated Problem for: {req.prompt[:20]}",
-        description="This is a placeholder problem generated by AI stub."
+        description="This is a placeholder problem generated by AI stub.",
+        model=MODEL_NAME,
     )
 
 @app.post("/ai/detect", response_model=AIDetectResponse)
@@ -37,7 +42,7 @@ async def ai_detect(req: AIDetectRequest):
     # Stub detection logic
     code_len = len(req.code)
     score = min(0.99, 0.1 + code_len / 1000)
-    return AIDetectResponse(suspicious=score > 0.6, score=score)
+    return AIDetectResponse(suspicious=score > 0.6, score=score, model=MODEL_NAME)
 
 if __name__ == "__main__":
     port = int(os.getenv("PY_BACKEND_PORT", "8000"))